@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statsWindows are the rolling windows the stats endpoint reports, by name
+var statsWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// AdminStatsResponse is a snapshot of what the server has done and is doing
+type AdminStatsResponse struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Totals      JobStats            `json:"totals"`
+	Windows     map[string]JobStats `json:"windows"`
+	Queue       QueueStats          `json:"queue"`
+	WorkDir     WorkDirStats        `json:"work_dir"`
+}
+
+// QueueStats describes this instance's job queue
+type QueueStats struct {
+	Workers int `json:"workers"`
+
+	// Admitted counts jobs holding a place in the queue, from the start of their upload or
+	// download until they finish, out of Capacity
+	Admitted int `json:"admitted"`
+	Capacity int `json:"capacity"`
+
+	// Running and Waiting count jobs holding and waiting for a worker, by priority
+	Running map[string]int `json:"running"`
+	Waiting map[string]int `json:"waiting"`
+
+	LiveStreams int `json:"live_streams"`
+}
+
+// WorkDirStats describes the scratch space under TRANSCRIBER_WORK_DIR
+type WorkDirStats struct {
+	Path      string `json:"path"`
+	UsedBytes int64  `json:"used_bytes"`
+	Files     int    `json:"files"`
+	JobDirs   int    `json:"job_dirs"`
+	FreeBytes uint64 `json:"free_bytes"`
+}
+
+// adminAuth guards the admin endpoints with the bearer token in TRANSCRIBER_ADMIN_TOKEN. Without
+// a token configured they don't exist
+func adminAuth(c *gin.Context) {
+	if appConfig.AdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse{Error: "Admin API is disabled: set TRANSCRIBER_ADMIN_TOKEN to enable it"})
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(appConfig.AdminToken)) != 1 {
+		recordAudit(c.Request.Context(), AuditEvent{Action: AuditAuthFailed, Detail: c.Request.Method + " " + c.Request.URL.Path})
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "A valid admin token is required"})
+		return
+	}
+	c.Next()
+}
+
+// adminStats reports job totals and rolling windows from the job store, along with this
+// instance's queue and scratch space
+func adminStats(c *gin.Context) {
+	now := time.Now().UTC()
+	cutoffs := []time.Time{{}}
+	for _, window := range statsWindows {
+		cutoffs = append(cutoffs, now.Add(-window.duration))
+	}
+	stats, err := jobStore.JobStats(cutoffs...)
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job stats", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load job stats"})
+		return
+	}
+
+	response := AdminStatsResponse{
+		GeneratedAt: now,
+		Totals:      stats[0],
+		Windows:     map[string]JobStats{},
+		Queue:       queueStats(),
+		WorkDir:     workDirStats(c),
+	}
+	for i, window := range statsWindows {
+		response.Windows[window.name] = stats[i+1]
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// queueStats snapshots the queue of this instance
+func queueStats() QueueStats {
+	running, waiting := pipelineWorkers.snapshot()
+	stats := QueueStats{
+		Workers:  int(max(1, appConfig.Workers)),
+		Admitted: len(queueSlots),
+		Capacity: cap(queueSlots),
+		Running:  running,
+		Waiting:  waiting,
+	}
+	liveStreams.Range(func(_, _ any) bool {
+		stats.LiveStreams++
+		return true
+	})
+	return stats
+}
+
+// workDirStats measures the scratch space. Files that vanish while it is walked, as jobs clean
+// up, are skipped
+func workDirStats(c *gin.Context) WorkDirStats {
+	stats := WorkDirStats{Path: appConfig.WorkDir}
+	filepath.WalkDir(appConfig.WorkDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			stats.UsedBytes += info.Size()
+			stats.Files++
+		}
+		return nil
+	})
+	activeJobDirs.Range(func(_, _ any) bool {
+		stats.JobDirs++
+		return true
+	})
+
+	free, err := availableDiskSpace(appConfig.WorkDir)
+	if err != nil {
+		loggerFrom(c.Request.Context()).Warn("Unable to determine free space", "dir", appConfig.WorkDir, "error", err)
+	}
+	stats.FreeBytes = free
+	return stats
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// maxScriptBytes caps the script sent for alignment, which is longer than other form fields
+const maxScriptBytes = 1 << 20
+
+// AlignmentResponse times each word of a script against the audio it was spoken in
+type AlignmentResponse struct {
+	JobID           string                    `json:"job_id"`
+	Words           []transcriber.AlignedWord `json:"words"`
+	MatchedWords    int                       `json:"matched_words"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Usage           *UsageMetadata            `json:"usage,omitempty"`
+	Timings         *JobTimings               `json:"timings,omitempty"`
+}
+
+// alignTranscript returns word-level timestamps for a script the caller already has, such as a
+// corrected transcript or the text that was read aloud. The audio is transcribed with word
+// timestamps like any job, and the script's words are then aligned with the recognized ones
+func alignTranscript(c *gin.Context) {
+	// Turn work away up front rather than accept an upload we can't process soon
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer release()
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.MaxUploadBytes)
+	if c.Request.ContentLength > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, c.Request.ContentLength, appConfig.DiskExpansionFactor); err != nil {
+			c.JSON(http.StatusInsufficientStorage, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request must be multipart/form-data"})
+		return
+	}
+
+	var job *Job
+	var jobDir, inputPath string
+	defer func() {
+		if jobDir != "" {
+			removeJobDir(jobDir)
+		}
+	}()
+
+	fields := map[string]string{}
+	var script string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			err = uploadError(err)
+			if job != nil {
+				failJob(c, job, err)
+			} else {
+				respondWithError(c, err)
+			}
+			return
+		}
+
+		switch {
+		case part.FormName() == "text":
+			// The script may be sent as a field or as a text file
+			value, _ := io.ReadAll(io.LimitReader(part, maxScriptBytes+1))
+			script = string(value)
+		case part.FormName() != "file" || job != nil:
+			value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldBytes))
+			fields[part.FormName()] = string(value)
+		default:
+			if err := checkUploadExtension(part.FileName()); err != nil {
+				part.Close()
+				respondWithError(c, err)
+				return
+			}
+			job, jobDir, err = startJob(c.Request.Context(), uuid.New().String(), part.FileName())
+			if err != nil {
+				part.Close()
+				respondWithStartError(c, err)
+				return
+			}
+			tagJob(c, job.ID)
+
+			inputPath = filepath.Join(jobDir, "upload-"+filepath.Base(part.FileName()))
+			if err := saveUploadPart(part, inputPath); err != nil {
+				part.Close()
+				failJob(c, job, uploadError(err))
+				return
+			}
+		}
+		part.Close()
+	}
+	if job == nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No file provided"})
+		return
+	}
+
+	opts, err := formJobOptions(tenantFrom(c.Request.Context()), fields)
+	if err == nil {
+		err = validateScript(script)
+	}
+	if err != nil {
+		failJob(c, job, err)
+		return
+	}
+	opts.WordTimestamps = true
+
+	// Alignment needs the recognized words, which only the instance running the pipeline has, so
+	// it is never handed to the shared queue
+	result, err := processJob(c.Request.Context(), job, jobDir, inputPath, opts)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	words := transcriber.AlignScript(script, result.Words, result.DurationSeconds)
+	matched := 0
+	for _, word := range words {
+		if word.Matched {
+			matched++
+		}
+	}
+	c.JSON(http.StatusOK, AlignmentResponse{
+		JobID:           job.ID,
+		Words:           words,
+		MatchedWords:    matched,
+		DurationSeconds: result.DurationSeconds,
+		Usage:           jobUsage(job),
+		Timings:         job.Timings,
+	})
+}
+
+// validateScript checks the text sent for alignment, reporting problems as a 400
+func validateScript(script string) error {
+	if len(script) > maxScriptBytes {
+		return &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("text must be at most %d bytes", maxScriptBytes)}
+	}
+	if strings.TrimSpace(script) == "" {
+		return &pipelineError{Status: http.StatusBadRequest, Message: "text must contain the transcript to align"}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// amqpBus consumes requests from a durable AMQP queue, acknowledging each once its result is
+// published. Requests a worker took but didn't finish, because the connection dropped or the
+// instance stopped, are delivered again
+type amqpBus struct {
+	url      string
+	requests string
+	results  string
+
+	// mu guards the connection and the channel results are published on, which are replaced
+	// when the connection drops
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	publisher *amqp.Channel
+}
+
+// newAMQPBus connects to the AMQP broker
+func newAMQPBus(cfg Config) (*amqpBus, error) {
+	b := &amqpBus{url: cfg.BusURL, requests: cfg.BusRequests, results: cfg.BusResults}
+	if _, err := b.connection(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// connection returns the open connection, dialing again when it or its publishing channel has
+// closed. The request and result queues are declared on each connection, so they exist before
+// anything is consumed from or published to them
+func (b *amqpBus) connection() (*amqp.Connection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil && !b.conn.IsClosed() && !b.publisher.IsClosed() {
+		return b.conn, nil
+	}
+	if b.conn != nil {
+		b.conn.Close()
+	}
+
+	conn, err := amqp.Dial(b.url)
+	if err != nil {
+		return nil, err
+	}
+	publisher, err := conn.Channel()
+	if err == nil {
+		err = publisher.Confirm(false)
+	}
+	for _, queue := range []string{b.requests, b.results} {
+		if err == nil {
+			_, err = publisher.QueueDeclare(queue, true, false, false, false, nil)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	b.conn, b.publisher = conn, publisher
+	return conn, nil
+}
+
+// consume runs workers on the request queue, reconnecting every busReconnectInterval while the
+// broker can't be reached
+func (b *amqpBus) consume(ctx context.Context, workers int, handle func(context.Context, busMessage) bool) {
+	for ctx.Err() == nil {
+		if err := b.consumeOnce(ctx, workers, handle); err != nil {
+			slog.Error("Error consuming from AMQP", "queue", b.requests, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(busReconnectInterval):
+		}
+	}
+}
+
+// consumeOnce takes requests on one channel until ctx is canceled or the channel closes. The
+// broker hands out no more requests than there are workers, and gives back the ones left
+// unacknowledged when the channel closes
+func (b *amqpBus) consumeOnce(ctx context.Context, workers int, handle func(context.Context, busMessage) bool) error {
+	conn, err := b.connection()
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := ch.Qos(workers, 0, false); err != nil {
+		return err
+	}
+	deliveries, err := ch.Consume(b.requests, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery, ok := <-deliveries:
+					if !ok {
+						return
+					}
+					request := busMessage{Body: delivery.Body, ReplyTo: delivery.ReplyTo, CorrelationID: delivery.CorrelationId}
+					var err error
+					if handle(ctx, request) {
+						err = delivery.Ack(false)
+					} else {
+						err = delivery.Nack(false, true)
+					}
+					if err != nil {
+						slog.Warn("Unable to settle AMQP delivery; it will be delivered again", "error", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errors.New("channel closed by the broker")
+}
+
+// publish sends a persistent message to a queue through the default exchange, waiting for the
+// broker to confirm it
+func (b *amqpBus) publish(ctx context.Context, queue string, msg busMessage) error {
+	if _, err := b.connection(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	publisher := b.publisher
+	b.mu.Unlock()
+
+	confirmation, err := publisher.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: msg.CorrelationID,
+		Body:          msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errors.New("broker did not accept the message")
+	}
+	return nil
+}
+
+// Close closes the connection
+func (b *amqpBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn.Close()
+}
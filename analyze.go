@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// AnalysisResponse describes the technical quality of a file's audio
+type AnalysisResponse struct {
+	transcriber.AudioQuality
+	Source *SourceMetadata `json:"source,omitempty"`
+}
+
+// analyzeAudio measures the quality of an upload (multipart, like /api/transcribe) or a URL (JSON,
+// like /api/transcribe/url) and warns about anything likely to hurt the transcript, without
+// transcribing it. Nothing is recorded in the job history
+func analyzeAudio(c *gin.Context) {
+	// A URL request has to be well-formed before anything is downloaded
+	var request URLTranscriptionRequest
+	isURL := c.ContentType() == "application/json"
+	if isURL {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a url field"})
+			return
+		}
+		if request.Ingest != "" && request.Ingest != "direct" && request.Ingest != "yt-dlp" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown ingest mode %q: expected direct or yt-dlp", request.Ingest)})
+			return
+		}
+	}
+
+	// Decoding the whole file takes a place in the queue like any job
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer release()
+
+	jobDir, err := createJobDir(appConfig.WorkDir, uuid.New().String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create job directory"})
+		return
+	}
+	defer removeJobDir(jobDir)
+
+	var inputPath string
+	var source *SourceMetadata
+	opts := transcriber.TranscribeOptions{}
+	if isURL {
+		opts.AudioTrack = request.AudioTrack
+		opts.AudioLanguage = request.AudioLanguage
+		inputPath, source, err = fetchRequestedURL(c.Request.Context(), request, jobDir)
+	} else {
+		var fields map[string]string
+		inputPath, fields, err = receiveEstimateUpload(c, jobDir)
+		opts.AudioTrack = fields["audio_track"]
+		opts.AudioLanguage = fields["audio_language"]
+	}
+	if err == nil {
+		err = checkMediaContainer(inputPath)
+	}
+	if err == nil {
+		err = scanMedia(c.Request.Context(), inputPath)
+	}
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	tenant := tenantFrom(c.Request.Context())
+	quality, err := transcriberFor(tenant, "").AnalyzeQuality(c.Request.Context(), inputPath, opts)
+	if err != nil {
+		respondWithError(c, pipelineErrorFor(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, AnalysisResponse{AudioQuality: *quality, Source: source})
+}
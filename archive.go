@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// zipMagic starts every ZIP archive
+var zipMagic = []byte("PK\x03\x04")
+
+// ArchiveResponse reports each recording of a ZIP upload, keyed by its path in the archive
+type ArchiveResponse struct {
+	BatchID string                        `json:"batch_id"`
+	Results map[string]ArchiveEntryResult `json:"results"`
+}
+
+// ArchiveEntryResult is a recording's transcription, or the error that stopped it
+type ArchiveEntryResult struct {
+	*SuccessResponse
+	Error string `json:"error,omitempty"`
+}
+
+// saveArchive streams an uploaded ZIP archive into its own staging directory
+func saveArchive(part io.Reader) (string, error) {
+	stagingDir := filepath.Join(appConfig.WorkDir, "archive-"+uuid.New().String())
+	if err := os.MkdirAll(stagingDir, 0o700); err != nil {
+		return "", &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to create archive directory"}
+	}
+	archivePath := filepath.Join(stagingDir, "upload.zip")
+	if err := saveUploadPart(part, archivePath); err != nil {
+		os.RemoveAll(stagingDir)
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// expandArchive extracts the recordings in a ZIP archive into dir. Directories, links, and hidden
+// or macOS metadata files are skipped. The archive may hold at most TRANSCRIBER_MAX_BATCH_SIZE
+// recordings expanding to at most TRANSCRIBER_MAX_UPLOAD_BYTES, whatever its headers claim, and
+// no entry may point outside the archive. Files are written under generated names, so entry
+// names never reach the filesystem
+func expandArchive(archivePath, dir string) ([]batchFile, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "Invalid ZIP archive: " + err.Error()}
+	}
+	defer reader.Close()
+
+	var entries []*zip.File
+	var declared uint64
+	for _, entry := range reader.File {
+		name := path.Clean(strings.ReplaceAll(entry.Name, `\`, "/"))
+		if !entry.Mode().IsRegular() || strings.HasPrefix(path.Base(name), ".") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Archive entry %q points outside the archive", entry.Name)}
+		}
+		entries = append(entries, entry)
+		declared += entry.UncompressedSize64
+	}
+	if len(entries) == 0 {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "Archive has no files"}
+	}
+	if len(entries) > int(appConfig.MaxBatchSize) {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Too many files: an archive may have at most %d", appConfig.MaxBatchSize)}
+	}
+	tooLarge := &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Archive too large: it may expand to at most %d bytes", appConfig.MaxUploadBytes)}
+	if declared > uint64(appConfig.MaxUploadBytes) {
+		return nil, tooLarge
+	}
+	if err := checkDiskSpace(appConfig.WorkDir, int64(declared), appConfig.DiskExpansionFactor); err != nil {
+		return nil, &pipelineError{Status: http.StatusInsufficientStorage, Message: err.Error()}
+	}
+
+	// Sizes in the headers can lie, so the budget is enforced on the bytes actually written
+	remaining := appConfig.MaxUploadBytes
+	files := make([]batchFile, 0, len(entries))
+	for i, entry := range entries {
+		name := path.Clean(strings.ReplaceAll(entry.Name, `\`, "/"))
+		if err := checkUploadExtension(name); err != nil {
+			return nil, err
+		}
+		file := batchFile{Filename: name, Path: filepath.Join(dir, fmt.Sprintf("entry-%d", i))}
+		written, err := extractArchiveEntry(entry, file.Path, remaining)
+		if err != nil {
+			return nil, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Failed to extract %q: %v", entry.Name, err)}
+		}
+		if written > remaining {
+			return nil, tooLarge
+		}
+		remaining -= written
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// extractArchiveEntry writes up to limit+1 bytes of an entry to dest, so the caller can tell
+// when the limit was exceeded
+func extractArchiveEntry(entry *zip.File, dest string, limit int64) (int64, error) {
+	src, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	return io.Copy(out, io.LimitReader(src, limit+1))
+}
+
+// transcribeArchive transcribes each recording in an uploaded ZIP archive as its own job, in a
+// batch, and responds with every outcome once they have all finished
+func transcribeArchive(c *gin.Context, archivePath string, opts JobOptions) {
+	files, err := expandArchive(archivePath, filepath.Dir(archivePath))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	releases, err := admitJobs(c.Request.Context(), len(files))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	batchID := uuid.New().String()
+	ctx := withLogger(c.Request.Context(), loggerFrom(c.Request.Context()).With("batch_id", batchID))
+	response := ArchiveResponse{BatchID: batchID, Results: map[string]ArchiveEntryResult{}}
+	var mutex sync.Mutex
+	record := func(name string, result ArchiveEntryResult) {
+		mutex.Lock()
+		response.Results[name] = result
+		mutex.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(release func()) {
+			defer wg.Done()
+			defer release()
+			success, err := transcribeArchiveEntry(ctx, batchID, file, opts)
+			if err != nil {
+				record(file.Filename, ArchiveEntryResult{Error: errorMessage(err)})
+				return
+			}
+			record(file.Filename, ArchiveEntryResult{SuccessResponse: success})
+		}(releases[i])
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// transcribeArchiveEntry runs one extracted recording of an archive as a job in the batch
+func transcribeArchiveEntry(ctx context.Context, batchID string, file batchFile, opts JobOptions) (*SuccessResponse, error) {
+	job, jobDir, err := startBatchJob(ctx, uuid.New().String(), file.Filename, batchID)
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to start job"}
+	}
+	defer removeJobDir(jobDir)
+	ctx = withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID))
+
+	inputPath := filepath.Join(jobDir, "upload-"+path.Base(file.Filename))
+	if err := os.Rename(file.Path, inputPath); err != nil {
+		finishJob(ctx, job, nil, err)
+		return nil, err
+	}
+
+	result, err := executeJob(ctx, job, jobDir, inputPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	response := successResponse(job, result, opts, nil)
+	return &response, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Stretches of a recording the tagging model listens to: pauses between segments shorter than
+// minEventGapSeconds aren't worth tagging, and longer stretches are tagged in pieces of at most
+// maxEventSpanSeconds, so a few seconds of applause aren't lost in a minute of music
+const (
+	minEventGapSeconds  = 1
+	maxEventSpanSeconds = 10
+)
+
+// noSpeechEventLabel labels the segments the model judged not to be speech when there is no
+// tagging model to say what they are
+const noSpeechEventLabel = "Non-speech"
+
+// defaultAudioEventLabels are the sounds audio_events captions unless
+// TRANSCRIBER_AUDIO_EVENT_LABELS says otherwise
+var defaultAudioEventLabels = []string{"laughter", "applause", "music", "ringing", "ringtone", "cheering", "crying", "cough", "dog", "knock"}
+
+// taggingClient sends audio clips to the tagging model
+var taggingClient = &http.Client{
+	Timeout:   transcriber.DefaultRequestTimeout,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// taggingResponse is the body the tagging model answers a clip with
+type taggingResponse struct {
+	Labels []struct {
+		Label string  `json:"label"`
+		Score float64 `json:"score"`
+	} `json:"labels"`
+}
+
+// tagClip sends a WAV clip to the tagging model and returns the most likely of its labels
+// TRANSCRIBER_AUDIO_EVENT_LABELS keeps, or "" when none is likely enough. The clip is decrypted
+// with cipher when it is set
+func tagClip(ctx context.Context, clipPath string, cipher *transcriber.FileCipher) (string, float64, error) {
+	var clip io.ReadCloser
+	var err error
+	if cipher != nil {
+		clip, err = cipher.Open(clipPath)
+	} else {
+		clip, err = os.Open(clipPath)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer clip.Close()
+	body, err := io.ReadAll(clip)
+	if err != nil {
+		return "", 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, appConfig.AudioTaggingURL, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "audio/wav")
+	resp, err := taggingClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", 0, fmt.Errorf("tagging service returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	var result taggingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, err
+	}
+
+	var label string
+	score := appConfig.AudioEventThreshold
+	for _, tag := range result.Labels {
+		if tag.Score >= score && keepsEventLabel(tag.Label) {
+			label, score = strings.TrimSpace(tag.Label), tag.Score
+		}
+	}
+	if label == "" {
+		return "", 0, nil
+	}
+	return label, score, nil
+}
+
+// keepsEventLabel reports whether a tagging model's label names one of the sounds in
+// TRANSCRIBER_AUDIO_EVENT_LABELS, so "Telephone bell ringing" is kept for "ringing"
+func keepsEventLabel(label string) bool {
+	label = strings.ToLower(label)
+	for _, allowed := range appConfig.AudioEventLabels {
+		if allowed != "" && strings.Contains(label, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// noSpeechEvents returns an event for each segment the model judged not to be speech
+func noSpeechEvents(segments []transcriber.Segment) []transcriber.AudioEvent {
+	var events []transcriber.AudioEvent
+	for _, segment := range segments {
+		if segment.NoSpeech {
+			events = append(events, transcriber.AudioEvent{Label: noSpeechEventLabel, Start: segment.Start, End: segment.End})
+		}
+	}
+	return mergeEvents(events)
+}
+
+// mergeEvents joins events with the same label that follow on from each other, such as the
+// pieces of one long stretch of music, keeping the higher score
+func mergeEvents(events []transcriber.AudioEvent) []transcriber.AudioEvent {
+	var merged []transcriber.AudioEvent
+	for _, event := range events {
+		if last := len(merged) - 1; last >= 0 && merged[last].Label == event.Label && event.Start <= merged[last].End+0.01 {
+			merged[last].End = max(merged[last].End, event.End)
+			merged[last].Score = max(merged[last].Score, event.Score)
+			continue
+		}
+		merged = append(merged, event)
+	}
+	return merged
+}
+
+// tagAudioEvents finds the sounds other than speech in a job's recording, such as laughter or
+// applause, by sending the stretches between its segments to the tagging model. Without one, or
+// without the media to cut, the segments the model judged not to be speech are marked instead.
+// Like a failed summary, a failure is recorded on the job rather than failing it
+func tagAudioEvents(ctx context.Context, job *Job, opts JobOptions, inputPath string, result *transcriber.Result) {
+	if appConfig.AudioTaggingURL == "" || inputPath == "" {
+		job.Events = noSpeechEvents(result.Segments)
+		return
+	}
+	spans := transcriber.EventSpans(result.Segments, result.DurationSeconds, minEventGapSeconds, maxEventSpanSeconds)
+	if len(spans) == 0 {
+		return
+	}
+
+	tagCtx, cancel := jobContext(ctx)
+	defer cancel()
+	clipDir, err := os.MkdirTemp(filepath.Dir(inputPath), "events-")
+	if err != nil {
+		job.EventsError = "Failed to tag audio events: " + err.Error()
+		return
+	}
+	defer os.RemoveAll(clipDir)
+
+	// The clips of an encrypted job are encrypted with its key, like the rest of its files
+	clipOpts := transcriber.ClipOptions{AudioTrack: opts.AudioTrack, AudioLanguage: opts.AudioLanguage}
+	if encryption := jobEncryptionFor(inputPath); encryption != nil {
+		clipOpts.Cipher = encryption.cipher
+	}
+	clips, err := transcriber.ExtractClips(tagCtx, inputPath, clipDir, spans, clipOpts)
+	if err != nil {
+		loggerFrom(ctx).Warn("Error cutting audio for event tagging", "error", err)
+		job.EventsError = "Failed to tag audio events: " + err.Error()
+		return
+	}
+
+	var events []transcriber.AudioEvent
+	for i, clip := range clips {
+		label, score, err := tagClip(tagCtx, clip, clipOpts.Cipher)
+		if err != nil {
+			loggerFrom(ctx).Warn("Error tagging audio events", "error", err)
+			job.EventsError = "Failed to tag audio events: " + err.Error()
+			return
+		}
+		if label != "" {
+			events = append(events, transcriber.AudioEvent{Label: label, Start: spans[i].Start, End: spans[i].End, Score: score})
+		}
+	}
+	job.Events = mergeEvents(events)
+	loggerFrom(ctx).Info("Tagged audio events", "spans", len(spans), "events", len(job.Events))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/peer"
+)
+
+// Audit actions, named for what happened to what
+const (
+	AuditJobSubmitted         = "job.submitted"
+	AuditJobCompleted         = "job.completed"
+	AuditJobFailed            = "job.failed"
+	AuditJobCanceled          = "job.canceled"
+	AuditTranscriptionRead    = "transcription.read"
+	AuditTranscriptionsListed = "transcriptions.listed"
+	AuditTranscriptsSearched  = "transcriptions.searched"
+	AuditTranscriptionEdited  = "transcription.edited"
+	AuditTranscriptionDeleted = "transcription.deleted"
+	AuditBatchRead            = "batch.read"
+	AuditAuthFailed           = "auth.failed"
+	AuditLogRead              = "audit.read"
+	AuditConfigReloaded       = "config.reloaded"
+)
+
+// auditActions lists every audit action
+var auditActions = []string{
+	AuditJobSubmitted, AuditJobCompleted, AuditJobFailed, AuditJobCanceled, AuditTranscriptionRead, AuditTranscriptionsListed,
+	AuditTranscriptsSearched, AuditTranscriptionEdited, AuditTranscriptionDeleted, AuditBatchRead, AuditAuthFailed, AuditLogRead,
+	AuditConfigReloaded,
+}
+
+// AuditEvent is an entry in the audit trail: who did what to which job, and when
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Action    string    `json:"action"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	JobID     string    `json:"job_id,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Filename  string    `json:"filename,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Model     string    `json:"model,omitempty"`
+
+	// Detail is what else the action needs to be understood: the format a transcript was read in,
+	// the query a listing ran, or why a job failed
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditListResponse is a page of audit events
+type AuditListResponse struct {
+	Events   []*AuditEvent `json:"events"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// auditActorKey is the context key for who made the request being audited
+type auditActorKey struct{}
+
+// auditActor is who made a request: where it came from and the ID it was logged under
+type auditActor struct {
+	clientIP  string
+	requestID string
+}
+
+// auditContext records the client IP and request ID of a REST request for the audit trail. It runs
+// after requestLogger, which assigns the request ID
+func auditContext(c *gin.Context) {
+	actor := auditActor{clientIP: c.ClientIP(), requestID: c.Writer.Header().Get(requestIDHeader)}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), auditActorKey{}, actor))
+	c.Next()
+}
+
+// recordAudit appends an event to the audit trail, filling in who made the request in ctx: the
+// REST client recorded by auditContext or the gRPC peer. A failure to record is logged rather than
+// failing the request
+func recordAudit(ctx context.Context, event AuditEvent) {
+	event.CreatedAt = time.Now().UTC()
+	if event.TenantID == "" {
+		event.TenantID = tenantIDFrom(ctx)
+	}
+	if actor, ok := ctx.Value(auditActorKey{}).(auditActor); ok {
+		event.ClientIP = actor.clientIP
+		event.RequestID = actor.requestID
+	} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		event.ClientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(event.ClientIP); err == nil {
+			event.ClientIP = host
+		}
+	}
+
+	if err := jobStore.RecordAuditEvent(&event); err != nil {
+		loggerFrom(ctx).Error("Error recording audit event", "action", event.Action, "job_id", event.JobID, "error", err)
+	}
+}
+
+// auditJob records an action on a job, along with the job's file, provider, and model
+func auditJob(ctx context.Context, action string, job *Job, detail string) {
+	recordAudit(ctx, AuditEvent{
+		Action:   action,
+		TenantID: job.TenantID,
+		JobID:    job.ID,
+		Filename: job.Filename,
+		Provider: job.Provider,
+		Model:    job.Model,
+		Detail:   detail,
+	})
+}
+
+// adminAudit lists the audit trail, newest first, filtered by action, tenant, job, client IP, and
+// time. Reading it is itself audited
+func adminAudit(c *gin.Context) {
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "page must be a positive integer"})
+		return
+	}
+	pageSize, err := parsePositiveInt(c.Query("page_size"), defaultPageSize)
+	if err != nil || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("page_size must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	filter := AuditFilter{
+		Action:   c.Query("action"),
+		TenantID: c.Query("tenant_id"),
+		JobID:    c.Query("job_id"),
+		ClientIP: c.Query("client_ip"),
+		Limit:    pageSize,
+		Offset:   (page - 1) * pageSize,
+	}
+	if filter.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "from " + err.Error()})
+		return
+	}
+	if filter.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "to " + err.Error()})
+		return
+	}
+
+	recordAudit(c.Request.Context(), AuditEvent{Action: AuditLogRead, Detail: c.Request.URL.RawQuery})
+	events, total, err := jobStore.ListAuditEvents(filter)
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error listing audit events", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuditListResponse{
+		Events:   events,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// azureStore serves azblob://container/blob URIs from the storage account in
+// TRANSCRIBER_AZURE_ACCOUNT_NAME, authenticating with TRANSCRIBER_AZURE_ACCOUNT_KEY when set
+// and the default Azure credential chain (environment, managed identity, CLI) otherwise
+type azureStore struct{}
+
+func (azureStore) Open(ctx context.Context, container, blob string) (io.ReadCloser, int64, error) {
+	client, err := newAzureClient()
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to configure Azure client: %w", err)
+	}
+
+	resp, err := client.DownloadStream(ctx, container, blob, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+			return nil, 0, fmt.Errorf("%w: %v", errObjectNotFound, err)
+		}
+		return nil, 0, err
+	}
+
+	size := int64(-1)
+	if resp.ContentLength != nil {
+		size = *resp.ContentLength
+	}
+
+	return resp.Body, size, nil
+}
+
+func (azureStore) List(ctx context.Context, container, prefix string) ([]ObjectInfo, error) {
+	client, err := newAzureClient()
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure Azure client: %w", err)
+	}
+
+	var objects []ObjectInfo
+	pages := client.NewListBlobsFlatPager(container, &azblob.ListBlobsFlatOptions{Prefix: &prefix})
+	for pages.More() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range page.Segment.BlobItems {
+			object := ObjectInfo{Key: *blob.Name}
+			if blob.Properties != nil {
+				if blob.Properties.ContentLength != nil {
+					object.Size = *blob.Properties.ContentLength
+				}
+				if blob.Properties.LastModified != nil {
+					object.ModTime = *blob.Properties.LastModified
+				}
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// newAzureClient builds a blob client from the server configuration
+func newAzureClient() (*azblob.Client, error) {
+	if appConfig.AzureAccountName == "" {
+		return nil, fmt.Errorf("TRANSCRIBER_AZURE_ACCOUNT_NAME is not set")
+	}
+
+	serviceURL := appConfig.AzureEndpoint
+	if serviceURL == "" {
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", appConfig.AzureAccountName)
+	}
+
+	if appConfig.AzureAccountKey != "" {
+		credential, err := azblob.NewSharedKeyCredential(appConfig.AzureAccountName, appConfig.AzureAccountKey)
+		if err != nil {
+			return nil, err
+		}
+		return azblob.NewClientWithSharedKeyCredential(serviceURL, credential, nil)
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewClient(serviceURL, credential, nil)
+}
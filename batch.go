@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Batch statuses, derived from the statuses of a batch's jobs
+const (
+	BatchStatusProcessing = "processing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusPartial    = "partial"
+	BatchStatusFailed     = "failed"
+)
+
+// BatchURLRequest is the JSON body accepted by the batch endpoint. The options of a URL request
+// apply to every URL
+type BatchURLRequest struct {
+	URLs []string `json:"urls"`
+	URLTranscriptionRequest
+}
+
+// BatchResponse is returned when a batch is accepted
+type BatchResponse struct {
+	BatchID string   `json:"batch_id"`
+	JobIDs  []string `json:"job_ids"`
+}
+
+// BatchStatusResponse reports the aggregate status of a batch and its jobs, without transcripts
+type BatchStatusResponse struct {
+	BatchID    string `json:"batch_id"`
+	Status     string `json:"status"`
+	Total      int    `json:"total"`
+	Processing int    `json:"processing"`
+	Completed  int    `json:"completed"`
+	Failed     int    `json:"failed"`
+	Canceled   int    `json:"canceled"`
+	Jobs       []*Job `json:"jobs"`
+}
+
+// batchFile is an uploaded file waiting for its job
+type batchFile struct {
+	Filename string
+	Path     string
+}
+
+// transcribeBatch accepts several files (multipart) or URLs (JSON) at once and transcribes each
+// as its own background job, returning the job IDs and a batch ID to poll
+func transcribeBatch(c *gin.Context) {
+	batchID := uuid.New().String()
+	if c.ContentType() == "application/json" {
+		transcribeURLBatch(c, batchID)
+		return
+	}
+
+	// Cap the request body so oversized uploads never reach the disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.MaxUploadBytes)
+	if c.Request.ContentLength > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, c.Request.ContentLength, appConfig.DiskExpansionFactor); err != nil {
+			c.JSON(http.StatusInsufficientStorage, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request must be multipart/form-data or JSON with a urls field"})
+		return
+	}
+
+	// Files are staged until the options, which may come after them, have been read
+	stagingDir := filepath.Join(appConfig.WorkDir, "batch-"+batchID)
+	if err := os.MkdirAll(stagingDir, 0o700); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create batch directory"})
+		return
+	}
+	defer os.RemoveAll(stagingDir)
+
+	var files []batchFile
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			respondWithError(c, uploadError(err))
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldBytes))
+			fields[part.FormName()] = string(value)
+			part.Close()
+			continue
+		}
+
+		if len(files) == int(appConfig.MaxBatchSize) {
+			part.Close()
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Too many files: a batch may have at most %d", appConfig.MaxBatchSize)})
+			return
+		}
+		if err := checkUploadExtension(part.FileName()); err != nil {
+			part.Close()
+			respondWithError(c, err)
+			return
+		}
+		file := batchFile{
+			Filename: part.FileName(),
+			Path:     filepath.Join(stagingDir, fmt.Sprintf("%d-%s", len(files), filepath.Base(part.FileName()))),
+		}
+		err = saveUploadPart(part, file.Path)
+		part.Close()
+		if err != nil {
+			respondWithError(c, uploadError(err))
+			return
+		}
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No files provided"})
+		return
+	}
+	opts, err := formJobOptions(tenantFrom(c.Request.Context()), fields)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	releases, err := admitJobs(c.Request.Context(), len(files))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// The jobs outlive the request, but their spans and logs still belong to it
+	ctx := withLogger(context.WithoutCancel(c.Request.Context()), loggerFrom(c.Request.Context()).With("batch_id", batchID))
+	jobIDs := make([]string, 0, len(files))
+	for i, file := range files {
+		job, jobDir, err := startBatchJob(ctx, uuid.New().String(), file.Filename, batchID)
+		if err != nil {
+			releaseJobs(releases[i:])
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start job"})
+			return
+		}
+		jobIDs = append(jobIDs, job.ID)
+		jobCtx := withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID))
+
+		inputPath := filepath.Join(jobDir, "upload-"+filepath.Base(file.Filename))
+		if err := os.Rename(file.Path, inputPath); err != nil {
+			finishJob(jobCtx, job, nil, err)
+			removeJobDir(jobDir)
+			releases[i]()
+			continue
+		}
+
+		go func(release func()) {
+			defer release()
+			defer removeJobDir(jobDir)
+			executeJob(jobCtx, job, jobDir, inputPath, opts)
+		}(releases[i])
+	}
+
+	respondWithBatch(c, batchID, jobIDs)
+}
+
+// transcribeURLBatch starts a background job for each URL in a JSON batch request
+func transcribeURLBatch(c *gin.Context, batchID string) {
+	var request BatchURLRequest
+	if err := decodeWithProfile(c.Request.Body, &request); err != nil || len(request.URLs) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a urls field"})
+		return
+	}
+	if len(request.URLs) > int(appConfig.MaxBatchSize) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Too many URLs: a batch may have at most %d", appConfig.MaxBatchSize)})
+		return
+	}
+	opts, err := urlJobOptions(tenantFrom(c.Request.Context()), request.URLTranscriptionRequest)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	releases, err := admitJobs(c.Request.Context(), len(request.URLs))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// The jobs outlive the request, but their spans and logs still belong to it
+	ctx := withLogger(context.WithoutCancel(c.Request.Context()), loggerFrom(c.Request.Context()).With("batch_id", batchID))
+	jobIDs := make([]string, 0, len(request.URLs))
+	for i, url := range request.URLs {
+		job, jobDir, err := startBatchJob(ctx, uuid.New().String(), url, batchID)
+		if err != nil {
+			releaseJobs(releases[i:])
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start job"})
+			return
+		}
+		jobIDs = append(jobIDs, job.ID)
+		jobCtx := withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID))
+
+		urlRequest := request.URLTranscriptionRequest
+		urlRequest.URL = url
+		go runURLJob(jobCtx, job, jobDir, urlRequest, opts, releases[i])
+	}
+
+	respondWithBatch(c, batchID, jobIDs)
+}
+
+// runURLJob downloads and transcribes the media of a background job, then gives back its place
+// in the queue
+func runURLJob(ctx context.Context, job *Job, jobDir string, request URLTranscriptionRequest, opts JobOptions, release func()) {
+	defer release()
+	defer removeJobDir(jobDir)
+	ctx, untrack := trackJob(ctx, job.ID)
+	defer untrack()
+	inputPath, _, err := fetchRequestedURL(ctx, request, jobDir)
+	if err != nil {
+		finishJob(ctx, job, nil, jobError(ctx, err))
+		return
+	}
+	executeJob(ctx, job, jobDir, inputPath, opts)
+}
+
+// admitJobs reserves a place in the queue for each job of a batch, or for none of them
+func admitJobs(ctx context.Context, n int) ([]func(), error) {
+	releases := make([]func(), 0, n)
+	for range n {
+		release, err := admitJob(ctx)
+		if err != nil {
+			releaseJobs(releases)
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// releaseJobs gives back places reserved by admitJobs for jobs that never started
+func releaseJobs(releases []func()) {
+	for _, release := range releases {
+		release()
+	}
+}
+
+// respondWithBatch reports an accepted batch, pointing at its status
+func respondWithBatch(c *gin.Context, batchID string, jobIDs []string) {
+	c.Header("Location", "/api/batches/"+batchID)
+	c.JSON(http.StatusAccepted, BatchResponse{BatchID: batchID, JobIDs: jobIDs})
+}
+
+// getBatch reports the aggregate status of a batch along with each of its jobs
+func getBatch(c *gin.Context) {
+	batchID := c.Param("id")
+	jobs, total, err := jobStore.ListJobs(JobFilter{
+		TenantID: tenantIDFrom(c.Request.Context()),
+		BatchID:  batchID,
+		SortBy:   "created_at",
+		Limit:    int(appConfig.MaxBatchSize),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load batch"})
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Batch not found"})
+		return
+	}
+	recordAudit(c.Request.Context(), AuditEvent{Action: AuditBatchRead, Detail: "batch_id=" + batchID})
+
+	response := BatchStatusResponse{BatchID: batchID, Total: total, Jobs: jobs}
+	for _, job := range jobs {
+		switch job.Status {
+		case JobStatusCompleted:
+			response.Completed++
+		case JobStatusFailed:
+			response.Failed++
+		case JobStatusCanceled:
+			response.Canceled++
+		default:
+			response.Processing++
+		}
+	}
+	response.Status = batchStatus(response)
+	c.JSON(http.StatusOK, response)
+}
+
+// batchStatus is processing until every job has finished, then completed, failed, or partial
+// when only some jobs failed. Canceled jobs count as failed
+func batchStatus(batch BatchStatusResponse) string {
+	switch {
+	case batch.Processing > 0:
+		return BatchStatusProcessing
+	case batch.Failed+batch.Canceled == 0:
+		return BatchStatusCompleted
+	case batch.Completed == 0:
+		return BatchStatusFailed
+	default:
+		return BatchStatusPartial
+	}
+}
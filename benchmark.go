@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// BenchmarkReport is the outcome of running one file through several models
+type BenchmarkReport struct {
+	File            string            `json:"file"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Reference       string            `json:"reference,omitempty"`
+	Results         []BenchmarkResult `json:"results"`
+}
+
+// BenchmarkResult is how one model did over its runs. Latency is the wall-clock time of the whole
+// pipeline, preprocessing included, and the real-time factor is the mean latency over the audio's
+// duration. WordErrorRate is only measured against a reference transcript
+type BenchmarkResult struct {
+	Provider          string   `json:"provider"`
+	Model             string   `json:"model"`
+	Runs              int      `json:"runs"`
+	Failures          int      `json:"failures"`
+	LatencySeconds    float64  `json:"latency_seconds"`
+	MinLatencySeconds float64  `json:"min_latency_seconds"`
+	MaxLatencySeconds float64  `json:"max_latency_seconds"`
+	RealTimeFactor    float64  `json:"real_time_factor"`
+	EstimatedCostUSD  float64  `json:"estimated_cost_usd"`
+	WordErrorRate     *float64 `json:"word_error_rate,omitempty"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// runBenchmarkCommand implements `benchmark <file>`: it transcribes a local file with each
+// configured provider and model in turn and reports their latency, cost, and word error rate
+// against an optional reference transcript. Returns the exit code
+func runBenchmarkCommand(args []string) int {
+	fs := flag.NewFlagSet("benchmark", flag.ContinueOnError)
+	reference := fs.String("reference", "", "text file with the correct transcript, to measure each model's word error rate against")
+	models := fs.String("models", "", "comma-separated provider:model pairs to compare (default every allowed model of every provider with an API key)")
+	runs := fs.Int("runs", 1, "times to transcribe the file with each model; latency is averaged over them")
+	prompt := fs.String("prompt", "", "domain terms, names, or acronyms to bias every model toward")
+	format := fs.String("format", "table", "output format: table or json")
+	output := fs.String("output", "", "write the report to this file instead of stdout")
+	verbose := fs.Bool("verbose", false, "log each run and the pipeline's stage timings to stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-transcriber benchmark <file> [--reference transcript.txt] [--models groq:whisper-large-v3,openai:whisper-1] [--runs n]")
+		fs.PrintDefaults()
+	}
+
+	// Allow flags both before and after the file name
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q: expected table or json\n", *format)
+		return 2
+	}
+	if *runs < 1 {
+		fmt.Fprintln(os.Stderr, "Invalid --runs: must be at least 1")
+		return 2
+	}
+	if err := transcriber.ValidatePrompt(*prompt); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --prompt: %v\n", err)
+		return 2
+	}
+	selections, err := benchmarkModels(*models)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --models: %v\n", err)
+		return 2
+	}
+
+	report := BenchmarkReport{File: files[0], Reference: *reference, Results: []BenchmarkResult{}}
+	if _, err := os.Stat(report.File); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read %s: %v\n", report.File, err)
+		return 1
+	}
+	var referenceText string
+	if *reference != "" {
+		data, err := os.ReadFile(*reference)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read %s: %v\n", *reference, err)
+			return 1
+		}
+		referenceText = string(data)
+	}
+
+	// Keep stderr quiet apart from problems unless asked for progress
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	// Ctrl-C stops the run in progress and still cleans up its job directory
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	ctx = withLogger(ctx, logger)
+
+	// Models run one after another so they don't slow each other down
+	succeeded := false
+	for _, selection := range selections {
+		result := BenchmarkResult{Provider: selection.Provider, Model: selection.Model, Runs: *runs}
+		var latencies, errorRates []float64
+		for run := 1; run <= *runs && ctx.Err() == nil; run++ {
+			logger.Info("Benchmarking", "provider", selection.Provider, "model", selection.Model, "run", run)
+			started := time.Now()
+			transcript, err := benchmarkRun(ctx, report.File, JobOptions{Provider: selection.Provider, Model: selection.Model, Prompt: *prompt})
+			if err != nil {
+				result.Failures++
+				result.Error = err.Error()
+				continue
+			}
+			latencies = append(latencies, time.Since(started).Seconds())
+			report.DurationSeconds = transcript.DurationSeconds
+			result.EstimatedCostUSD = estimateCost(transcript.Model, transcript)
+			if *reference != "" {
+				errorRates = append(errorRates, transcriber.DiffTranscripts(referenceText, transcript.Transcription).WordErrorRate)
+			}
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "Benchmark interrupted")
+			return 1
+		}
+
+		if len(latencies) > 0 {
+			succeeded = true
+			result.LatencySeconds = roundSeconds(mean(latencies))
+			result.MinLatencySeconds = roundSeconds(slices.Min(latencies))
+			result.MaxLatencySeconds = roundSeconds(slices.Max(latencies))
+			if report.DurationSeconds > 0 {
+				result.RealTimeFactor = math.Round(mean(latencies)/report.DurationSeconds*1e4) / 1e4
+			}
+		}
+		if len(errorRates) > 0 {
+			errorRate := math.Round(mean(errorRates)*1e4) / 1e4
+			result.WordErrorRate = &errorRate
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create %s: %v\n", *output, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := writeBenchmarkReport(out, *format, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write report: %v\n", err)
+		return 1
+	}
+	if !succeeded {
+		return 1
+	}
+	return 0
+}
+
+// benchmarkModels parses --models into the provider and model of each entry, validated like a
+// request's. A bare model is taken to be the default provider's. Without any, every allowed model
+// of every provider with an API key is benchmarked
+func benchmarkModels(list string) ([]modelSelection, error) {
+	var selections []modelSelection
+	entries := splitLabelList(list)
+	if len(entries) == 0 {
+		providers := providersFor(nil)
+		for _, provider := range providerNames() {
+			if _, ok := providers.transcribers[provider]; !ok {
+				continue
+			}
+			for _, model := range providers.allowedModels[provider] {
+				entries = append(entries, provider+":"+model)
+			}
+		}
+	}
+	for _, entry := range entries {
+		provider, model, ok := strings.Cut(entry, ":")
+		if !ok {
+			provider, model = "", entry
+		}
+		selection, err := parseModelSelection(nil, provider, model, 0)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	return selections, nil
+}
+
+// benchmarkRun transcribes the file once in a job directory of its own
+func benchmarkRun(ctx context.Context, inputPath string, opts JobOptions) (*transcriber.Result, error) {
+	jobDir, err := createJobDir(appConfig.WorkDir, uuid.New().String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create job directory: %w", err)
+	}
+	defer removeJobDir(jobDir)
+	return runPipeline(ctx, jobDir, inputPath, opts)
+}
+
+// mean averages values, of which there is at least one
+func mean(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// roundSeconds rounds a latency to the millisecond
+func roundSeconds(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
+
+// writeBenchmarkReport writes the report as JSON or as a table with a row for each model
+func writeBenchmarkReport(out io.Writer, format string, report BenchmarkReport) error {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "PROVIDER\tMODEL\tRUNS\tFAILED\tLATENCY\tMIN\tMAX\tRTF\tCOST USD\tWER\tERROR")
+	for _, result := range report.Results {
+		wer := "-"
+		if result.WordErrorRate != nil {
+			wer = fmt.Sprintf("%.2f%%", *result.WordErrorRate*100)
+		}
+		latency, minLatency, maxLatency, rtf, cost := "-", "-", "-", "-", "-"
+		if result.Failures < result.Runs {
+			latency = fmt.Sprintf("%.2fs", result.LatencySeconds)
+			minLatency = fmt.Sprintf("%.2fs", result.MinLatencySeconds)
+			maxLatency = fmt.Sprintf("%.2fs", result.MaxLatencySeconds)
+			rtf = fmt.Sprintf("%.3f", result.RealTimeFactor)
+			cost = fmt.Sprintf("%.6f", result.EstimatedCostUSD)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", result.Provider, result.Model, result.Runs,
+			result.Failures, latency, minLatency, maxLatency, rtf, cost, wer, result.Error)
+	}
+	return table.Flush()
+}
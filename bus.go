@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// busQueueGroup is the NATS queue group instances share, so each request goes to one of them
+	busQueueGroup = "audio-transcriber"
+
+	// busAdmitInterval is how long a bus worker waits before trying again when the job queue is full
+	busAdmitInterval = time.Second
+
+	// busPublishTimeout bounds publishing one result
+	busPublishTimeout = 30 * time.Second
+
+	// busReconnectInterval is how long to wait before reconnecting after the bus connection drops
+	busReconnectInterval = 5 * time.Second
+)
+
+// bus is where transcription requests come in and results go out, or nil when no bus is configured
+var bus messageBus
+
+// busStopped is closed once the bus workers have published the results of the requests they took
+var busStopped = make(chan struct{})
+
+// messageBus is a NATS or AMQP server transcription requests are consumed from and results
+// published to
+type messageBus interface {
+	// consume hands requests to handle, workers at a time, until ctx is canceled, reconnecting
+	// when the connection drops. A request is acknowledged once handle returns true; false gives
+	// it back to be delivered again
+	consume(ctx context.Context, workers int, handle func(context.Context, busMessage) bool)
+
+	// publish sends a message to a subject or queue
+	publish(ctx context.Context, to string, msg busMessage) error
+
+	Close() error
+}
+
+// busMessage is a request or result on the bus. ReplyTo and CorrelationID are the NATS reply
+// subject, or the AMQP properties of the same names
+type busMessage struct {
+	Body          []byte
+	ReplyTo       string
+	CorrelationID string
+}
+
+// BusRequest is the JSON body of a transcription request on the bus: the body of a URL request,
+// whose url is usually an object-store URI, and an ID that the result echoes. A request delivered
+// again with the same request_id gets the job the first delivery created, as Idempotency-Key does
+type BusRequest struct {
+	RequestID string `json:"request_id"`
+	URLTranscriptionRequest
+}
+
+// BusResult is published for each request once its job has finished, or straight away for a
+// request that couldn't be started. The job's fields are those of a Kafka message
+type BusResult struct {
+	RequestID string `json:"request_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	*KafkaJobMessage
+}
+
+// newMessageBus connects to the configured bus, or returns nil when none is configured
+func newMessageBus(cfg Config) (messageBus, error) {
+	if cfg.BusURL == "" {
+		return nil, nil
+	}
+	// The URL may hold credentials, so it's left out of errors
+	u, err := url.Parse(cfg.BusURL)
+	if err != nil {
+		return nil, errors.New("TRANSCRIBER_BUS_URL is not a valid URL")
+	}
+	if cfg.BusRequests == "" || cfg.BusResults == "" {
+		return nil, errors.New("TRANSCRIBER_BUS_REQUESTS and TRANSCRIBER_BUS_RESULTS must be set along with TRANSCRIBER_BUS_URL")
+	}
+	switch u.Scheme {
+	case "nats", "tls":
+		return newNATSBus(cfg)
+	case "amqp", "amqps":
+		return newAMQPBus(cfg)
+	default:
+		return nil, fmt.Errorf("unknown bus scheme %q: expected nats, tls, amqp, or amqps", u.Scheme)
+	}
+}
+
+// runBus consumes transcription requests with workers workers until ctx is canceled
+func runBus(ctx context.Context, workers int) {
+	defer close(busStopped)
+	slog.Info("Consuming transcription requests from the bus", "requests", appConfig.BusRequests, "results", appConfig.BusResults, "workers", workers)
+	bus.consume(ctx, workers, handleBusRequest)
+}
+
+// handleBusRequest runs the job a request on the bus asks for and publishes its result. It
+// returns false to have the request delivered again, when it couldn't be looked at or ctx was
+// canceled before its job started
+func handleBusRequest(ctx context.Context, msg busMessage) bool {
+	var request BusRequest
+	if err := decodeWithProfile(bytes.NewReader(msg.Body), &request); err != nil || request.URL == "" {
+		slog.Warn("Rejected bus request without a url")
+		publishBusResult(ctx, msg, BusResult{RequestID: request.RequestID, Status: JobStatusFailed, Error: "Request must be JSON with a url field"})
+		return true
+	}
+	logger := slog.Default().With("request_id", request.RequestID)
+	ctx = withLogger(ctx, logger)
+	reject := func(err error) bool {
+		logger.Warn("Rejected bus request", "error", err)
+		publishBusResult(ctx, msg, BusResult{RequestID: request.RequestID, Status: JobStatusFailed, Error: err.Error()})
+		return true
+	}
+
+	// Requests have no tenant, so they get the server's providers and limits
+	opts, err := urlJobOptions(nil, request.URLTranscriptionRequest)
+	if err != nil {
+		return reject(err)
+	}
+	key, err := parseIdempotencyKey(request.RequestID)
+	if err != nil {
+		return reject(err)
+	}
+	prior, err := findIdempotentJob(ctx, key)
+	if err != nil {
+		logger.Error("Error looking up bus request", "error", err)
+		return false
+	}
+	if prior != nil {
+		if prior.Status != JobStatusCompleted {
+			logger.Info("Bus request is already being processed", "job_id", prior.ID)
+			return true
+		}
+		publishBusResult(ctx, msg, busJobResult(request.RequestID, prior, opts))
+		return true
+	}
+
+	release, err := admitBusJob(ctx)
+	if err != nil {
+		return false
+	}
+	job, jobDir, err := startIdempotentJob(ctx, uuid.New().String(), request.URL, key)
+	if err != nil {
+		release()
+		var pipelineErr *pipelineError
+		if errors.As(err, &pipelineErr) {
+			// Another delivery of the request started its job first
+			return true
+		}
+		return false
+	}
+
+	// A job that has started runs to the end even when the bus is stopping, so its result is
+	// published rather than the request being run twice
+	jobCtx := withLogger(context.WithoutCancel(ctx), logger.With("job_id", job.ID))
+	runURLJob(jobCtx, job, jobDir, request.URLTranscriptionRequest, opts, release)
+	if finished, err := jobStore.GetJob(job.ID); err == nil {
+		job = finished
+	}
+	publishBusResult(jobCtx, msg, busJobResult(request.RequestID, job, opts))
+	return true
+}
+
+// admitBusJob waits for a place in the job queue, which requests from HTTP clients may be
+// holding, until ctx is canceled
+func admitBusJob(ctx context.Context) (func(), error) {
+	for ctx.Err() == nil {
+		release, err := admitJob(ctx)
+		if err == nil {
+			return release, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(busAdmitInterval):
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// busJobResult describes a finished job for the bus, its transcript filtered the way the job asks
+func busJobResult(requestID string, job *Job, opts JobOptions) BusResult {
+	message := kafkaJobMessage(filterJob(job, opts.ProfanityFilter))
+	return BusResult{RequestID: requestID, Status: job.Status, Error: job.Error, KafkaJobMessage: &message}
+}
+
+// publishBusResult publishes a result to where the request asked for replies, or to BusResults
+func publishBusResult(ctx context.Context, request busMessage, result BusResult) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		loggerFrom(ctx).Error("Unable to encode bus result", "error", err)
+		return
+	}
+	to := request.ReplyTo
+	if to == "" {
+		to = appConfig.BusResults
+	}
+
+	publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), busPublishTimeout)
+	defer cancel()
+	if err := bus.publish(publishCtx, to, busMessage{Body: body, CorrelationID: request.CorrelationID}); err != nil {
+		loggerFrom(ctx).Error("Unable to publish bus result", "to", to, "error", err)
+		return
+	}
+	loggerFrom(ctx).Info("Published bus result", "to", to, "status", result.Status)
+}
+
+// closeBus waits for the bus workers to publish the results of the jobs they started, then
+// closes the connection. ctx passed to runBus must be canceled first
+func closeBus() {
+	<-busStopped
+	if err := bus.Close(); err != nil {
+		slog.Warn("Error closing bus connection", "error", err)
+	}
+}
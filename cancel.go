@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errJobCanceled is the error of a job stopped by a cancel request, and the cause its context
+// is canceled with
+var errJobCanceled = &pipelineError{Status: http.StatusConflict, Message: "Transcription canceled"}
+
+// runningJob is a job queued or running on this instance that a cancel request can stop
+type runningJob struct {
+	cancel context.CancelCauseFunc
+
+	// done is closed once the job's outcome has been recorded
+	done chan struct{}
+}
+
+// runningJobs holds the jobs queued or running on this instance, by job ID
+var runningJobs sync.Map
+
+// trackJob makes a job cancelable through ctx until the returned function is called, which must
+// be after its outcome is recorded. A job that is already tracked, such as a URL job that was
+// downloading its media, stays cancelable through its first registration
+func trackJob(ctx context.Context, jobID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	running := &runningJob{cancel: cancel, done: make(chan struct{})}
+	if _, loaded := runningJobs.LoadOrStore(jobID, running); loaded {
+		return ctx, func() { cancel(nil) }
+	}
+	return ctx, func() {
+		runningJobs.Delete(jobID)
+		close(running.done)
+		cancel(nil)
+	}
+}
+
+// cancelLocalJob cancels a job queued or running on this instance, returning it, or nil when
+// the job isn't here
+func cancelLocalJob(jobID string) *runningJob {
+	value, ok := runningJobs.Load(jobID)
+	if !ok {
+		return nil
+	}
+	running := value.(*runningJob)
+	running.cancel(errJobCanceled)
+	return running
+}
+
+// jobError is err, or errJobCanceled when err came from the job being canceled by a request
+func jobError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), errJobCanceled) {
+		return errJobCanceled
+	}
+	return err
+}
+
+// cancelTranscription cancels a queued or running job: its ffmpeg processes are killed, its chunk
+// requests abandoned, its directory removed, and it is recorded as canceled. A job on this
+// instance is answered once it has stopped; with a shared queue, one that may be on another
+// instance is answered with 202 as soon as the cancellation has been sent
+func cancelTranscription(c *gin.Context) {
+	job, err := getTenantJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	cancelJob(c, job)
+}
+
+// cancelJob cancels a job loaded by a cancel request and answers the request
+func cancelJob(c *gin.Context, job *Job) {
+	if job.Status != JobStatusProcessing {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription has already " + job.Status})
+		return
+	}
+
+	if running := cancelLocalJob(job.ID); running != nil {
+		auditJob(c.Request.Context(), AuditJobCanceled, job, "")
+		select {
+		case <-running.done:
+		case <-c.Request.Context().Done():
+			return
+		}
+		job, err := jobStore.GetJob(job.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+		return
+	}
+
+	if distQueue == nil {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription can't be canceled until its media has been received"})
+		return
+	}
+	if err := distQueue.cancel(c.Request.Context(), job.ID); err != nil {
+		loggerFrom(c.Request.Context()).Error("Error sending job cancellation", "job_id", job.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to cancel transcription"})
+		return
+	}
+	auditJob(c.Request.Context(), AuditJobCanceled, job, "")
+	c.JSON(http.StatusAccepted, job)
+}
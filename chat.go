@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// chatSnippetLength is how much of the transcript, in characters, a chat notification quotes
+	chatSnippetLength = 300
+
+	// chatTimeout bounds each webhook post
+	chatTimeout = 10 * time.Second
+
+	// discordMaxLength is the longest message Discord accepts, in characters
+	discordMaxLength = 2000
+)
+
+// chatClient posts to Slack and Discord webhooks. Requested webhooks are limited to the services'
+// own hosts, so unlike downloads it needn't guard against private addresses
+var chatClient = &http.Client{
+	Timeout:   chatTimeout,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// chatWebhookHosts are the hosts a per-request webhook URL may point at, by service
+var chatWebhookHosts = map[string][]string{
+	"slack":   {"hooks.slack.com"},
+	"discord": {"discord.com", "discordapp.com"},
+}
+
+// parseWebhookURL validates a requested Slack or Discord webhook URL. Only https URLs on the
+// service's own hosts are accepted, so the field can't be used to make the server post elsewhere
+func parseWebhookURL(service, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	parsed, err := url.Parse(value)
+	if err == nil && parsed.Scheme == "https" && parsed.User == nil && parsed.Port() == "" {
+		for _, host := range chatWebhookHosts[service] {
+			if parsed.Hostname() == host {
+				return value, nil
+			}
+		}
+	}
+	return "", &pipelineError{
+		Status:  http.StatusBadRequest,
+		Message: fmt.Sprintf("Invalid %s_webhook_url: expected an https URL on %s", service, strings.Join(chatWebhookHosts[service], " or ")),
+	}
+}
+
+// notifyChat posts a finished job to the configured Slack and Discord webhooks and to any the
+// request named, in the background. Failed posts are retried by deliverWebhook
+func notifyChat(ctx context.Context, job *Job, opts JobOptions) {
+	job = filterJob(job, opts.ProfanityFilter)
+	ctx = context.WithoutCancel(ctx)
+	post := func(service, webhookURL string, payload any) {
+		go deliverWebhook(ctx, job, service, webhookURL, payload)
+	}
+
+	for _, webhookURL := range uniqueURLs(appConfig.SlackWebhookURL, opts.SlackWebhookURL) {
+		post("slack", webhookURL, map[string]string{"text": chatNotification(job, escapeSlack)})
+	}
+	for _, webhookURL := range uniqueURLs(appConfig.DiscordWebhookURL, opts.DiscordWebhookURL) {
+		// Without allowed_mentions Discord would ping anyone a transcript happens to @mention
+		content := []rune(chatNotification(job, func(s string) string { return s }))
+		if len(content) > discordMaxLength {
+			content = append(content[:discordMaxLength-1], '…')
+		}
+		post("discord", webhookURL, map[string]any{
+			"content":          string(content),
+			"allowed_mentions": map[string][]string{"parse": {}},
+		})
+	}
+}
+
+// uniqueURLs drops empty and repeated webhook URLs
+func uniqueURLs(urls ...string) []string {
+	var unique []string
+	for _, u := range urls {
+		if u != "" && !slices.Contains(unique, u) {
+			unique = append(unique, u)
+		}
+	}
+	return unique
+}
+
+// chatNotification describes a finished job in a few lines: its outcome, ID, and duration, then a
+// snippet of the transcript or the error, and a link to the job when TRANSCRIBER_PUBLIC_URL is set.
+// escape makes user-supplied text safe for the service's markup
+func chatNotification(job *Job, escape func(string) string) string {
+	var message strings.Builder
+	if job.Status == JobStatusCompleted {
+		fmt.Fprintf(&message, "Transcription completed: %s\n", escape(job.Filename))
+		fmt.Fprintf(&message, "Job %s, %s of audio\n", job.ID, time.Duration(job.DurationSeconds*float64(time.Second)).Round(time.Second))
+		if snippet := transcriptSnippet(job.Transcript); snippet != "" {
+			fmt.Fprintf(&message, "> %s\n", escape(snippet))
+		}
+	} else {
+		fmt.Fprintf(&message, "Transcription failed: %s\n", escape(job.Filename))
+		fmt.Fprintf(&message, "Job %s: %s\n", job.ID, escape(job.Error))
+	}
+	if appConfig.PublicURL != "" {
+		fmt.Fprintf(&message, "%s/api/transcriptions/%s\n", strings.TrimSuffix(appConfig.PublicURL, "/"), job.ID)
+	}
+	return strings.TrimSuffix(message.String(), "\n")
+}
+
+// transcriptSnippet is the start of a transcript on one line, cut at a word boundary
+func transcriptSnippet(transcript string) string {
+	snippet := strings.Join(strings.Fields(transcript), " ")
+	if utf8.RuneCountInString(snippet) <= chatSnippetLength {
+		return snippet
+	}
+	snippet = string([]rune(snippet)[:chatSnippetLength])
+	if space := strings.LastIndex(snippet, " "); space > 0 {
+		snippet = snippet[:space]
+	}
+	return snippet + "…"
+}
+
+// escapeSlack escapes the characters Slack treats as markup in message text
+func escapeSlack(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// postWebhook posts a JSON body to a webhook, returning a webhookStatusError when it answers with
+// an error status
+func postWebhook(ctx context.Context, webhookURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := chatClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return &webhookStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(reply))}
+	}
+	return nil
+}
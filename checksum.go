@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+const (
+	// contentSHA256Header carries the hex SHA-256 of an uploaded file, checked against the bytes
+	// received before the file is processed
+	contentSHA256Header = "X-Content-SHA256"
+
+	// contentSHA256Field is the form field alternative to contentSHA256Header
+	contentSHA256Field = "content_sha256"
+)
+
+// parseContentSHA256 validates a client-supplied SHA-256 checksum sent as name, returning it in
+// lowercase. "" means the request has none
+func parseContentSHA256(name, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+	if decoded, err := hex.DecodeString(value); err != nil || len(decoded) != 32 {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: name + " must be a SHA-256 digest of 64 hexadecimal characters"}
+	}
+	return value, nil
+}
+
+// verifyContentSHA256 compares the SHA-256 of the bytes received with the one the client sent,
+// either as a header or a form field, failing with 422 when they differ
+func verifyContentSHA256(header, field string, received hash.Hash) error {
+	expected, err := parseContentSHA256(contentSHA256Header, header)
+	if err == nil && expected == "" {
+		expected, err = parseContentSHA256(contentSHA256Field, field)
+	}
+	if err != nil || expected == "" {
+		return err
+	}
+	if actual := hex.EncodeToString(received.Sum(nil)); actual != expected {
+		return &pipelineError{
+			Status:  http.StatusUnprocessableEntity,
+			Message: fmt.Sprintf("Checksum mismatch: the upload's SHA-256 is %s, but %s was expected", actual, expected),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// stageScan is the virus scan that runs before the pipeline's own stages
+const stageScan transcriber.Stage = "scan"
+
+const (
+	// clamdChunkBytes is how much of a file goes into each INSTREAM chunk
+	clamdChunkBytes = 64 << 10
+
+	// clamdTimeout bounds a whole scan, and clamdPingTimeout the startup check
+	clamdTimeout     = 10 * time.Minute
+	clamdPingTimeout = 5 * time.Second
+)
+
+// errScannerUnavailable fails a job whose media couldn't be scanned. Scanning is required once
+// enabled, so media that clamd didn't vouch for is never processed
+var errScannerUnavailable = &pipelineError{Status: http.StatusServiceUnavailable, Stage: stageScan, Message: "Virus scanner unavailable, try again later"}
+
+// clamdNetwork splits a clamd address into the network and address to dial: unix:///path or a
+// bare /path for a socket, tcp://host:port or a bare host:port otherwise
+func clamdNetwork(addr string) (string, string) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return "unix", path
+	}
+	if strings.HasPrefix(addr, "/") {
+		return "unix", addr
+	}
+	return "tcp", strings.TrimPrefix(addr, "tcp://")
+}
+
+// clamdCommand sends a command to clamd and returns its reply, with the body written by send in
+// between when there is one
+func clamdCommand(ctx context.Context, command string, send func(io.Writer) error) (string, error) {
+	network, addr := clamdNetwork(appConfig.ClamAVAddr)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// A canceled job stops its scan rather than waiting on clamd
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := io.WriteString(conn, "z"+command+"\x00"); err != nil {
+		return "", err
+	}
+	if send != nil {
+		if err := send(conn); err != nil {
+			return "", err
+		}
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// pingClamd checks that clamd is answering
+func pingClamd(ctx context.Context) error {
+	reply, err := clamdCommand(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected reply from clamd: %q", reply)
+	}
+	return nil
+}
+
+// scanFile streams a file to clamd, returning the name of the signature it matched, or "" when
+// it is clean
+func scanFile(ctx context.Context, path string) (string, error) {
+	file, err := openJobFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reply, err := clamdCommand(ctx, "INSTREAM", func(w io.Writer) error {
+		buf := make([]byte, 4+clamdChunkBytes)
+		for {
+			n, err := file.Read(buf[4:])
+			if n > 0 {
+				binary.BigEndian.PutUint32(buf, uint32(n))
+				if _, err := w.Write(buf[:4+n]); err != nil {
+					return err
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		// A zero-length chunk ends the stream
+		_, err := w.Write([]byte{0, 0, 0, 0})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Replies look like "stream: OK", "stream: <signature> FOUND", or "<reason> ERROR"
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	}
+	return "", fmt.Errorf("clamd: %s", reply)
+}
+
+// scanMedia rejects media that clamd finds infected before anything else reads it, and fails
+// closed when clamd can't be reached. It does nothing unless scanning is enabled
+func scanMedia(ctx context.Context, path string) error {
+	if !appConfig.ClamAVEnabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, clamdTimeout)
+	defer cancel()
+
+	signature, err := scanFile(ctx, path)
+	if errors.Is(err, context.Canceled) {
+		return pipelineErrorFor(err)
+	}
+	if err != nil {
+		loggerFrom(ctx).Error("Unable to scan media", "error", err)
+		return errScannerUnavailable
+	}
+	if signature != "" {
+		loggerFrom(ctx).Warn("Rejected infected media", "signature", signature)
+		return &pipelineError{Status: http.StatusUnprocessableEntity, Stage: stageScan, Message: "File rejected: malware detected (" + signature + ")"}
+	}
+	return nil
+}
+
+// checkClamAV warns at startup when scanning is enabled but clamd isn't answering. Jobs fail
+// until it does
+func checkClamAV(cfg Config) {
+	if !cfg.ClamAVEnabled {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clamdPingTimeout)
+	defer cancel()
+	if err := pingClamd(ctx); err != nil {
+		slog.Warn("clamd isn't answering; uploads are rejected until it is", "addr", cfg.ClamAVAddr, "error", err)
+		return
+	}
+	slog.Info("Scanning media with clamd", "addr", cfg.ClamAVAddr)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// runTranscribeCommand implements `transcribe <file>`: it runs the full pipeline on a local file
+// without starting the server and writes the result to stdout or --output. Returns the exit code
+func runTranscribeCommand(args []string) int {
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text, json, readable, srt, vtt, markdown, lrc, words, or chapters")
+	output := fs.String("output", "", "write the result to this file instead of stdout")
+	audioTrack := fs.String("audio-track", "", "zero-based position of the audio stream to transcribe")
+	audioLanguage := fs.String("audio-language", "", "language tag of the audio stream to transcribe")
+	normalize := fs.Bool("normalize", false, "apply EBU R128 loudness normalization before transcribing")
+	denoise := fs.Bool("denoise", false, "filter out constant background noise before transcribing")
+	audioFilters := fs.String("audio-filters", "", "extra ffmpeg audio filter chain, e.g. highpass=f=100,dynaudnorm")
+	skipNonSpeech := fs.Bool("skip-non-speech", false, "leave long stretches of music and silence, such as intros and outros, out of the transcription")
+	removeSilence := fs.Bool("remove-silence", false, "cut long silences out of the audio before it is sent, keeping timestamps on the original timeline")
+	speed := fs.Float64("speed", 0, "play the audio up to 3 times faster before sending it, to cut cost at some loss of accuracy")
+	start := fs.String("start", "", "transcribe only from this point, in seconds or as [hh:]mm:ss")
+	end := fs.String("end", "", "transcribe only up to this point, in seconds or as [hh:]mm:ss")
+	prompt := fs.String("prompt", "", "domain terms, names, or acronyms to bias the transcription toward")
+	codeSwitching := fs.Bool("code-switching", false, "identify the language of every part of a recording that mixes languages and tag each segment with it")
+	language := fs.String("language", "", "spoken language as an ISO-639-1 code such as de, or auto to detect it (default TRANSCRIBER_LANGUAGE, or en)")
+	splitChannels := fs.Bool("split-channels", false, "transcribe each channel, such as the two sides of a stereo call, separately and label each speaker")
+	channelLabels := fs.String("channel-labels", "", "comma-separated speaker labels for the left and right channels (default Agent,Customer)")
+	hallucinations := fs.String("hallucinations", "", "flag or remove segments the model likely made up, such as \"Thanks for watching!\" over silence: flag or remove")
+	redact := fs.Bool("redact", false, "mask emails, phone numbers, card numbers, and (with TRANSCRIBER_REDACT_NAMES) names")
+	profanity := fs.String("profanity-filter", "", "mask or remove profanity in the output: mask or remove")
+	minConfidence := fs.Float64("min-confidence", 0, "flag segments whose confidence is below this, from 0 to 1, and mark their text with ⟦…⟧")
+	summarize := fs.Bool("summarize", false, "add a summary of the transcript to json output")
+	keywords := fs.Bool("keywords", false, "add the transcript's key phrases to json output")
+	provider := fs.String("provider", "", "transcription provider to use (default the server's TRANSCRIBER_PROVIDER)")
+	model := fs.String("model", "", "model to transcribe with, from TRANSCRIBER_ALLOWED_MODELS (default the provider's default)")
+	temperature := fs.Float64("temperature", 0, "sampling temperature from 0 to 1")
+	verbose := fs.Bool("verbose", false, "log stage timings and chunk outcomes to stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-transcriber transcribe <file> [--format text|json|readable|srt|vtt|markdown|lrc|words|chapters] [--output path]")
+		fs.PrintDefaults()
+	}
+
+	// Allow flags both before and after the file name
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	if _, ok := formatContentTypes[*format]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format %q: expected text, json, readable, srt, vtt, markdown, lrc, words, or chapters\n", *format)
+		return 2
+	}
+	if *summarize && *format != "json" {
+		fmt.Fprintln(os.Stderr, "--summarize requires --format json")
+		return 2
+	}
+	if *keywords && *format != "json" {
+		fmt.Fprintln(os.Stderr, "--keywords requires --format json")
+		return 2
+	}
+	if err := transcriber.ValidateAudioFilters(*audioFilters); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --audio-filters: %v\n", err)
+		return 2
+	}
+	labels, err := parseChannelLabels(splitLabelList(*channelLabels))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --channel-labels: %v\n", err)
+		return 2
+	}
+	profanityMode, err := parseProfanityFilter(*profanity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --profanity-filter: %v\n", err)
+		return 2
+	}
+	hallucinationMode, err := parseHallucinations(*hallucinations)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --hallucinations: %v\n", err)
+		return 2
+	}
+	if !validMinConfidence(*minConfidence) {
+		fmt.Fprintln(os.Stderr, "Invalid --min-confidence: must be a number between 0 and 1")
+		return 2
+	}
+	if err := transcriber.ValidateSpeed(*speed); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --speed: %v\n", err)
+		return 2
+	}
+	startSeconds, endSeconds, err := parseTimeRange(*start, *end)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := transcriber.ValidatePrompt(*prompt); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --prompt: %v\n", err)
+		return 2
+	}
+	spokenLanguage, err := parseLanguage(*language)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	selection, err := parseModelSelection(nil, *provider, *model, *temperature)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid model selection: %v\n", err)
+		return 2
+	}
+	if *codeSwitching {
+		if err := checkCodeSwitching(selection, spokenLanguage); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	inputPath := files[0]
+	if _, err := os.Stat(inputPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read %s: %v\n", inputPath, err)
+		return 1
+	}
+
+	jobDir, err := createJobDir(appConfig.WorkDir, uuid.New().String())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create job directory: %v\n", err)
+		return 1
+	}
+	defer removeJobDir(jobDir)
+
+	// Keep stderr quiet apart from problems unless asked for progress
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	// Ctrl-C stops the pipeline's ffmpeg processes and chunk requests, and still cleans up the job directory
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = withLogger(ctx, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	result, err := runPipeline(ctx, jobDir, inputPath, JobOptions{
+		AudioTrack:     *audioTrack,
+		AudioLanguage:  *audioLanguage,
+		Normalize:      *normalize,
+		Denoise:        *denoise,
+		AudioFilters:   *audioFilters,
+		SkipNonSpeech:  *skipNonSpeech,
+		RemoveSilence:  *removeSilence,
+		Speed:          *speed,
+		Start:          startSeconds,
+		End:            endSeconds,
+		Provider:       selection.Provider,
+		Model:          selection.Model,
+		Temperature:    selection.Temperature,
+		Prompt:         *prompt,
+		Language:       spokenLanguage,
+		CodeSwitching:  *codeSwitching,
+		SplitChannels:  *splitChannels,
+		ChannelLabels:  labels,
+		WordTimestamps: wordFormat(*format),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Transcription failed: %v\n", err)
+		return 1
+	}
+
+	if hallucinationMode != "" {
+		filterHallucinations(ctx, result, hallucinationMode)
+	}
+	if *redact {
+		if err := redactResult(ctx, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Redaction failed: %v\n", err)
+			return 1
+		}
+	}
+
+	var summary string
+	if *summarize && strings.TrimSpace(result.Transcription) != "" {
+		if summary, err = summarizeTranscript(ctx, result.Transcription); err != nil {
+			fmt.Fprintf(os.Stderr, "Summary failed: %v\n", err)
+			return 1
+		}
+	}
+
+	var keywordList []transcriber.Keyword
+	if *keywords {
+		keywordList = transcriber.ExtractKeywords(result.Segments, int(appConfig.KeywordLimit))
+	}
+
+	stats := transcriber.TranscriptStats(result.Transcription, result.Segments)
+	result = filterResult(result, profanityMode)
+	summary = profanityFilter.Apply(summary, profanityMode)
+	result, _ = flagResult(result, *minConfidence)
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create %s: %v\n", *output, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := writeCLIResult(out, *format, selection.Provider, result, summary, keywordList, stats); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write result: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeCLIResult writes a pipeline result in the requested format
+func writeCLIResult(out io.Writer, format, provider string, result *transcriber.Result, summary string, keywords []transcriber.Keyword, stats transcriber.Stats) error {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Transcription   string                `json:"transcription"`
+			ReadableText    string                `json:"readable_text"`
+			Summary         string                `json:"summary,omitempty"`
+			Keywords        []transcriber.Keyword `json:"keywords,omitempty"`
+			Segments        []transcriber.Segment `json:"segments"`
+			DurationSeconds float64               `json:"duration_seconds"`
+			Usage           *UsageMetadata        `json:"usage"`
+			Stats           transcriber.Stats     `json:"stats"`
+		}{result.Transcription, readableText(result.Transcription, transcriber.MarkLowConfidence(result.Segments)), summary, keywords, result.Segments, result.DurationSeconds, &UsageMetadata{
+			DurationSeconds:  result.DurationSeconds,
+			Chunks:           result.Chunks,
+			Provider:         provider,
+			Model:            result.Model,
+			EstimatedCostUSD: estimateCost(result.Model, result),
+		}, stats})
+	}
+
+	rendered := renderTranscript(format, result.Transcription, transcriber.MarkLowConfidence(result.Segments), result.Words)
+	if format == "text" || format == "readable" {
+		rendered += "\n"
+	}
+	_, err := io.WriteString(out, rendered)
+	return err
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"audio-transcriber/pkg/transcriber"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ComparisonResponse holds the transcripts of one file by two provider and model combinations,
+// and how far they agree
+type ComparisonResponse struct {
+	A ComparisonResult `json:"a"`
+	B ComparisonResult `json:"b"`
+
+	// Agreement compares b's transcript with a's word by word, when both succeeded
+	Agreement *transcriber.TranscriptDiff `json:"agreement,omitempty"`
+}
+
+// ComparisonResult is one side of a comparison: its job's response, or why it failed
+type ComparisonResult struct {
+	*SuccessResponse
+	JobID    string `json:"job_id"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Error    string `json:"error,omitempty"`
+}
+
+// compareModels transcribes an upload with two provider and model combinations at once, picked
+// with provider_a, model_a, provider_b, and model_b, each as its own job, and measures how far the
+// transcripts agree. The cache is never used, so both sides are transcribed fresh
+func compareModels(c *gin.Context) {
+	// Both jobs are admitted up front rather than accepting an upload we can't process soon
+	releases, err := admitJobs(c.Request.Context(), 2)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer releaseJobs(releases)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.MaxUploadBytes)
+	if c.Request.ContentLength > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, c.Request.ContentLength, 2*appConfig.DiskExpansionFactor); err != nil {
+			c.JSON(http.StatusInsufficientStorage, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request must be multipart/form-data"})
+		return
+	}
+
+	var jobA *Job
+	var jobDirA, inputPathA string
+	defer func() {
+		if jobDirA != "" {
+			removeJobDir(jobDirA)
+		}
+	}()
+
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			err = uploadError(err)
+			if jobA != nil {
+				failJob(c, jobA, err)
+			} else {
+				respondWithError(c, err)
+			}
+			return
+		}
+
+		if part.FormName() != "file" || jobA != nil {
+			value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldBytes))
+			fields[part.FormName()] = string(value)
+			part.Close()
+			continue
+		}
+		if err := checkUploadExtension(part.FileName()); err != nil {
+			part.Close()
+			respondWithError(c, err)
+			return
+		}
+		jobA, jobDirA, err = startJob(c.Request.Context(), uuid.New().String(), part.FileName())
+		if err != nil {
+			part.Close()
+			respondWithStartError(c, err)
+			return
+		}
+		inputPathA = filepath.Join(jobDirA, "upload-"+filepath.Base(part.FileName()))
+		err = saveUploadPart(part, inputPathA)
+		part.Close()
+		if err != nil {
+			failJob(c, jobA, uploadError(err))
+			return
+		}
+	}
+	if jobA == nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No file provided"})
+		return
+	}
+
+	optsA, optsB, err := comparisonJobOptions(tenantFrom(c.Request.Context()), fields)
+	if err != nil {
+		failJob(c, jobA, err)
+		return
+	}
+
+	// The second job transcribes its own link to the same upload
+	jobB, jobDirB, err := startJob(c.Request.Context(), uuid.New().String(), jobA.Filename)
+	if err != nil {
+		failJob(c, jobA, err)
+		return
+	}
+	defer removeJobDir(jobDirB)
+	inputPathB := filepath.Join(jobDirB, filepath.Base(inputPathA))
+	if err := linkOrCopy(inputPathA, inputPathB); err != nil {
+		err = &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to copy upload"}
+		finishJob(c.Request.Context(), jobB, nil, err)
+		failJob(c, jobA, err)
+		return
+	}
+
+	// Each side logs under its own job ID
+	ctx, logger := c.Request.Context(), loggerFrom(c.Request.Context())
+	var wg sync.WaitGroup
+	var resultA, resultB *transcriber.Result
+	var errA, errB error
+	wg.Go(func() {
+		resultA, errA = executeJob(withLogger(ctx, logger.With("job_id", jobA.ID)), jobA, jobDirA, inputPathA, optsA)
+	})
+	wg.Go(func() {
+		resultB, errB = executeJob(withLogger(ctx, logger.With("job_id", jobB.ID)), jobB, jobDirB, inputPathB, optsB)
+	})
+	wg.Wait()
+	if errA != nil && errB != nil {
+		respondWithError(c, errA)
+		return
+	}
+
+	response := ComparisonResponse{
+		A: comparisonResult(jobA, resultA, optsA, errA),
+		B: comparisonResult(jobB, resultB, optsB, errB),
+	}
+	if errA == nil && errB == nil {
+		agreement := transcriber.DiffTranscripts(response.A.Transcription, response.B.Transcription)
+		response.Agreement = &agreement
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// comparisonJobOptions validates the options of a comparison, which both sides share apart from
+// their provider and model
+func comparisonJobOptions(tenant *Tenant, fields map[string]string) (JobOptions, JobOptions, error) {
+	opts, err := formJobOptions(tenant, fields)
+	if err != nil {
+		return JobOptions{}, JobOptions{}, err
+	}
+	opts.UseCache = false
+	sides := [2]JobOptions{opts, opts}
+	for i, suffix := range []string{"_a", "_b"} {
+		selection, err := parseModelSelection(tenant, fields["provider"+suffix], fields["model"+suffix], opts.Temperature)
+		if err != nil {
+			return JobOptions{}, JobOptions{}, err
+		}
+		sides[i].Provider, sides[i].Model = selection.Provider, selection.Model
+	}
+	if sides[0].Provider == sides[1].Provider && sides[0].Model == sides[1].Model {
+		return JobOptions{}, JobOptions{}, &pipelineError{Status: http.StatusBadRequest, Message: "provider_a and model_a must pick a different provider or model than provider_b and model_b"}
+	}
+	return sides[0], sides[1], nil
+}
+
+// comparisonResult describes one side of a comparison
+func comparisonResult(job *Job, result *transcriber.Result, opts JobOptions, err error) ComparisonResult {
+	side := ComparisonResult{JobID: job.ID, Provider: opts.Provider, Model: opts.Model}
+	if err != nil {
+		side.Error = errorMessage(err)
+		return side
+	}
+	response := successResponse(job, result, opts, nil)
+	side.SuccessResponse = &response
+	return side
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encodings responses can be compressed with
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// minCompressSize is the smallest response worth compressing; smaller ones go out as they are
+const minCompressSize = 1024
+
+// responseEncodings are the encodings TRANSCRIBER_RESPONSE_COMPRESSION allows, most preferred first
+var responseEncodings []string
+
+// initCompression checks the configured response encodings
+func initCompression(config Config) error {
+	if len(config.ResponseCompression) == 1 && config.ResponseCompression[0] == "none" {
+		return nil
+	}
+	for _, encoding := range config.ResponseCompression {
+		if encoding != encodingGzip && encoding != encodingZstd {
+			return fmt.Errorf("unknown response compression %q: expected gzip or zstd", encoding)
+		}
+	}
+	responseEncodings = config.ResponseCompression
+	return nil
+}
+
+// compressResponse compresses a response with the first configured encoding the request's
+// Accept-Encoding allows, once it has grown past minCompressSize. Responses that are already
+// encoded, aren't text, or stream events are left alone
+func compressResponse(c *gin.Context) {
+	if len(responseEncodings) == 0 {
+		c.Next()
+		return
+	}
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+	if encoding == "" || c.Request.Method == http.MethodHead {
+		c.Next()
+		return
+	}
+
+	writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+	c.Writer = writer
+	defer func() {
+		writer.close()
+		c.Writer = writer.ResponseWriter
+	}()
+	c.Next()
+}
+
+// negotiateEncoding picks the first of responseEncodings an Accept-Encoding header allows, or ""
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, encoding := range responseEncodings {
+		if accepted[encoding] || accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressible reports whether a response of the given Content-Type is text worth compressing.
+// Event streams and NDJSON are flushed a line at a time, so they are sent as they are
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "text/event-stream") || strings.HasPrefix(contentType, ndjsonContentType) {
+		return false
+	}
+	return strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml") || strings.HasPrefix(contentType, "application/x-subrip")
+}
+
+// flushWriter is an encoder that can push out what it has compressed so far
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressWriter holds a response back until it is big enough to compress, then compresses the
+// rest of it as it is written
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	buffer   []byte
+	started  bool
+	encoder  flushWriter
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.started {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= minCompressSize {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what has been written so far, compressing it if the response qualifies
+func (w *compressWriter) Flush() {
+	if !w.started {
+		w.start()
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start decides whether the response is compressed and writes out what was held back
+func (w *compressWriter) start() error {
+	w.started = true
+	header := w.Header()
+	status := w.Status()
+	if header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) &&
+		status != http.StatusNoContent && status != http.StatusNotModified && status != http.StatusPartialContent {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if w.encoding == encodingZstd {
+			encoder, err := zstd.NewWriter(w.ResponseWriter)
+			if err != nil {
+				return err
+			}
+			w.encoder = encoder
+		} else {
+			w.encoder = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+
+	buffered := w.buffer
+	w.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buffered)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buffered)
+	return err
+}
+
+// close finishes the response: one that never grew past minCompressSize goes out uncompressed
+func (w *compressWriter) close() {
+	if !w.started {
+		w.started = true
+		if len(w.buffer) > 0 {
+			w.ResponseWriter.Write(w.buffer)
+		}
+		return
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// parseMinConfidence validates a requested min_confidence, from 0 to 1; empty means segments
+// aren't flagged
+func parseMinConfidence(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	minConfidence, err := strconv.ParseFloat(value, 64)
+	if err != nil || !validMinConfidence(minConfidence) {
+		return 0, &pipelineError{Status: http.StatusBadRequest, Message: "min_confidence must be a number between 0 and 1"}
+	}
+	return minConfidence, nil
+}
+
+// validMinConfidence reports whether minConfidence is between 0 and 1
+func validMinConfidence(minConfidence float64) bool {
+	return minConfidence >= 0 && minConfidence <= 1
+}
+
+// flagResult returns a copy of result whose segments scored below minConfidence are flagged, and
+// whose transcript wraps them in ⟦…⟧, along with how many were flagged. Like profanity
+// filtering, this only shapes the response
+func flagResult(result *transcriber.Result, minConfidence float64) (*transcriber.Result, int) {
+	if minConfidence == 0 {
+		return result, 0
+	}
+	flagged := *result
+	var count int
+	flagged.Segments, count = transcriber.FlagLowConfidence(result.Segments, minConfidence)
+	if count > 0 {
+		flagged.Transcription = transcriber.JoinSegments(transcriber.MarkLowConfidence(flagged.Segments))
+	}
+	return &flagged, count
+}
+
+// flagJob is flagResult for a stored job, recording the count in its LowConfidence
+func flagJob(job *Job, minConfidence float64) *Job {
+	if minConfidence == 0 {
+		return job
+	}
+	flagged := *job
+	flagged.Segments, flagged.LowConfidence = transcriber.FlagLowConfidence(job.Segments, minConfidence)
+	if flagged.LowConfidence > 0 {
+		flagged.Transcript = transcriber.JoinSegments(transcriber.MarkLowConfidence(flagged.Segments))
+	}
+	return &flagged
+}
@@ -0,0 +1,745 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings for the server
+type Config struct {
+	// WorkDir is the root under which every job gets its own scratch directory
+	WorkDir string
+
+	// DiskExpansionFactor is how many times the upload size must be free in WorkDir before a job is accepted
+	DiskExpansionFactor float64
+
+	// MemoryProcessing writes the preprocessed audio and chunks of jobs that fit in MemoryLimitBytes
+	// to MemoryDir, a tmpfs, instead of their directory in WorkDir
+	MemoryProcessing bool
+	MemoryDir        string
+
+	// MemoryLimitBytes caps the intermediate audio held in MemoryDir across jobs. A job reserves its
+	// input size times DiskExpansionFactor, and runs in WorkDir when that doesn't fit
+	MemoryLimitBytes int64
+
+	// AudioDir is where the media of jobs submitted with retain_audio is kept, by job ID
+	AudioDir string
+
+	// VideoDir is where the copies of videos made for jobs submitted with subtitles are kept
+	VideoDir string
+
+	// MaxUploadBytes is the largest request body accepted by the upload endpoint
+	MaxUploadBytes int64
+
+	// MaxBatchSize is the most files or URLs a batch request may have
+	MaxBatchSize int64
+
+	// DownloadTimeout bounds how long fetching a remote URL may take
+	DownloadTimeout time.Duration
+
+	// PreprocessTimeout and ChunkingTimeout bound the ffmpeg runs that preprocess and chunk the
+	// audio; zero means no limit
+	PreprocessTimeout time.Duration
+	ChunkingTimeout   time.Duration
+
+	// ChunkTimeout bounds each attempt at transcribing a chunk, from upload to response
+	ChunkTimeout time.Duration
+
+	// MaxChunkBytes is the largest chunk file sent to the provider, under its upload limit
+	MaxChunkBytes int64
+
+	// JobTimeout bounds a job's whole pipeline once a worker picks it up; zero means no limit
+	JobTimeout time.Duration
+
+	// LiveSegmentSeconds is how much of a live stream is transcribed at a time
+	LiveSegmentSeconds float64
+
+	// NonSpeechSeconds is the shortest stretch of music or silence skip_non_speech leaves out
+	NonSpeechSeconds float64
+
+	// SilenceSeconds is the shortest silence remove_silence cuts out
+	SilenceSeconds float64
+
+	// LiveMaxDuration stops a live stream that is still running after this long; zero means no limit
+	LiveMaxDuration time.Duration
+
+	// AllowPrivateURLs permits remote URLs that resolve to loopback or private addresses
+	AllowPrivateURLs bool
+
+	// LocalRoots are the directories file:// inputs may be read from; empty disables them
+	LocalRoots []string
+
+	// WatchDirs are directories scanned for new recordings to transcribe; empty disables watching
+	WatchDirs []string
+
+	// WatchInterval is how often the watched directories are scanned. Files changed more recently
+	// than that are left for the next scan, since they may still be being copied in
+	WatchInterval time.Duration
+
+	// WatchExtensions are the extensions of the files in watched directories that are transcribed
+	WatchExtensions []string
+
+	// WatchOutputs are the formats written next to each watched file once it is transcribed, plus
+	// json for the job itself; "none" only records the job
+	WatchOutputs []string
+
+	// WatchOptions are the options watched files are transcribed with, as a query string of the
+	// upload form's fields, like "summarize=true&redact=true"
+	WatchOptions string
+
+	// WatchTenant is the tenant watched files' jobs belong to; empty leaves them without one
+	WatchTenant string
+
+	// SchedulesFile is a JSON file of recurring runs that transcribe new podcast episodes or new
+	// objects under a storage prefix on a cron schedule; empty disables them
+	SchedulesFile string
+
+	// S3Region overrides the AWS region used for s3:// inputs
+	S3Region string
+
+	// S3Endpoint points s3:// inputs at an S3-compatible service instead of AWS
+	S3Endpoint string
+
+	// S3AccessKeyID and S3SecretAccessKey are static credentials for s3:// inputs;
+	// when empty the default AWS credential chain (including IAM roles) is used
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	// ResultsBucket is the S3 bucket, optionally with a key prefix as bucket/prefix, that jobs
+	// submitted with store_results write their transcripts to. It uses the S3 settings above
+	ResultsBucket string
+
+	// ResultsFormats are the transcript formats stored for each of those jobs
+	ResultsFormats []string
+
+	// ResultsURLExpiry is how long the presigned URLs to stored results work
+	ResultsURLExpiry time.Duration
+
+	// KafkaBrokers are the Kafka brokers completed jobs are published through; empty disables
+	// publishing
+	KafkaBrokers []string
+
+	// KafkaTopic is the topic completed jobs are published to
+	KafkaTopic string
+
+	// KafkaTLS connects to the brokers over TLS
+	KafkaTLS bool
+
+	// KafkaUsername and KafkaPassword authenticate with SASL when set, using KafkaSASLMechanism:
+	// plain, scram-sha-256, or scram-sha-512
+	KafkaUsername      string
+	KafkaPassword      string
+	KafkaSASLMechanism string
+
+	// TwilioAccountSID and TwilioAuthToken verify Twilio's recording callbacks and fetch the
+	// recordings; the callback endpoint is only served when the token is set
+	TwilioAccountSID string
+	TwilioAuthToken  string
+
+	// TwilioWebhookURL is posted the result of each recording's job
+	TwilioWebhookURL string
+
+	// TwilioTenant is the tenant recording jobs belong to; empty leaves them without one
+	TwilioTenant string
+
+	// ZoomSecretToken verifies Zoom's recording webhooks; the webhook endpoint is only served when
+	// it is set
+	ZoomSecretToken string
+
+	// ZoomAccountID, ZoomClientID, and ZoomClientSecret are the account's server-to-server OAuth
+	// app, used to list meeting participants and to download recordings whose webhook has no
+	// download token. ZoomAccountID also limits webhooks to that account
+	ZoomAccountID    string
+	ZoomClientID     string
+	ZoomClientSecret string
+
+	// ZoomWebhookURL is posted the result of each recording's job
+	ZoomWebhookURL string
+
+	// ZoomTenant is the tenant recording jobs belong to; empty leaves them without one
+	ZoomTenant string
+
+	// ZoomSplitSpeakers transcribes each participant's own audio file as a channel labeled
+	// with their name, when the account records them, instead of the mixed audio
+	ZoomSplitSpeakers bool
+
+	// BusURL is the NATS (nats://) or AMQP (amqp://, amqps://) server transcription requests are
+	// consumed from and results published to; empty disables the intake
+	BusURL string
+
+	// BusRequests is the subject or queue requests are consumed from, and BusResults the one
+	// results are published to when a request doesn't name where to reply
+	BusRequests string
+	BusResults  string
+
+	// GCSCredentialsFile is a service account key for gs:// inputs; when empty
+	// Application Default Credentials are used
+	GCSCredentialsFile string
+
+	// AzureAccountName is the storage account azblob:// inputs are read from
+	AzureAccountName string
+
+	// AzureAccountKey is a shared key for the storage account; when empty the
+	// default Azure credential chain is used
+	AzureAccountKey string
+
+	// AzureEndpoint overrides the blob service URL (e.g. for Azurite)
+	AzureEndpoint string
+
+	// YtDlpPath is the yt-dlp executable used for the yt-dlp ingestion mode
+	YtDlpPath string
+
+	// FFmpegPath and FFprobePath are the executables the pipeline runs
+	FFmpegPath  string
+	FFprobePath string
+
+	// HWAccel is the ffmpeg hardware acceleration method video is decoded with: a method such as
+	// "vaapi", "cuda", or "videotoolbox", "auto" for the first that works, or empty for software
+	HWAccel string
+
+	// ClamAVEnabled scans every uploaded or downloaded file with clamd before it is processed,
+	// rejecting infected ones
+	ClamAVEnabled bool
+
+	// ClamAVAddr is clamd's socket: unix:///path, tcp://host:port, or a bare path or host:port
+	ClamAVAddr string
+
+	// FFmpegCheck makes startup fail when ffmpeg or ffprobe can't run or lacks a filter or codec
+	// the pipeline needs; when false the problem is only logged
+	FFmpegCheck bool
+
+	// DatabaseDriver selects where jobs are stored: "sqlite" or "postgres"
+	DatabaseDriver string
+
+	// DatabaseDSN is the SQLite file path or Postgres connection string
+	DatabaseDSN string
+
+	// GroqAPIKey authenticates requests to the Groq transcription API
+	GroqAPIKey string
+
+	// OpenAIAPIKey authenticates requests to the OpenAI transcription API; the openai provider is
+	// only available when it is set
+	OpenAIAPIKey string
+
+	// GroqURL and OpenAIURL point the providers at other OpenAI-compatible servers, such as a
+	// self-hosted faster-whisper-server or LocalAI, by base URL or transcription endpoint
+	GroqURL   string
+	OpenAIURL string
+
+	// SecretsBackend reads the provider API keys from a secrets manager, "vault" or "aws", instead
+	// of the environment. Keys the secret doesn't hold still come from the environment
+	SecretsBackend string
+
+	// SecretsRefresh is how often the secret is read again so rotated keys are picked up;
+	// zero only reads it at startup
+	SecretsRefresh time.Duration
+
+	// VaultAddr, VaultToken, and VaultSecretPath locate the secret in HashiCorp Vault. The path is
+	// the API path, e.g. "secret/data/transcriber" for a KV version 2 engine mounted at secret/
+	VaultAddr       string
+	VaultToken      string
+	VaultSecretPath string
+
+	// AWSSecretID is the name or ARN of the secret in AWS Secrets Manager, and AWSSecretRegion
+	// overrides the region of the standard AWS chain, which also supplies the credentials
+	AWSSecretID     string
+	AWSSecretRegion string
+
+	// Provider and Model transcribe jobs that don't pick their own. An empty Model means the
+	// provider's first allowed model
+	Provider string
+	Model    string
+
+	// AllowedModels are the "provider:model" pairs requests may pick
+	AllowedModels []string
+
+	// Language is the spoken language of jobs that don't give their own, or auto to let the model
+	// identify it
+	Language string
+
+	// LanguageModels pick the model for the parts of code-switched recordings identified as a
+	// language, as "language=model" entries
+	LanguageModels []string
+
+	// SummaryURL is the OpenAI-compatible chat completions endpoint used for summaries and name redaction
+	SummaryURL string
+
+	// SummaryModel is the chat model that writes summaries and finds names to redact
+	SummaryModel string
+
+	// SummaryAPIKey authenticates summary requests; defaults to the server's Groq API key
+	SummaryAPIKey string
+
+	// SummaryPrompt is a text/template for the summary request, given the transcript as {{.Transcript}}
+	SummaryPrompt string
+
+	// TranslationBackend translates transcripts for translate_to: llm for the summary chat model, or
+	// deepl for DeepL's API at DeepLURL, authenticated with DeepLAPIKey
+	TranslationBackend string
+	DeepLURL           string
+	DeepLAPIKey        string
+
+	// SMTPHost and SMTPPort are the mail server that sends notify_email messages; email delivery
+	// is disabled when SMTPHost is empty. STARTTLS is used whenever the server offers it
+	SMTPHost string
+	SMTPPort string
+
+	// SMTPUsername and SMTPPassword log in to the mail server; when empty no login is attempted
+	SMTPUsername string
+	SMTPPassword string
+
+	// SMTPFrom is the sender address of notification emails
+	SMTPFrom string
+
+	// SlackWebhookURL and DiscordWebhookURL are posted a message whenever a job completes or fails
+	SlackWebhookURL   string
+	DiscordWebhookURL string
+
+	// WebhookRetryPeriod is how long a webhook post that fails is retried, with backoff, before it
+	// is kept as a dead letter; zero gives up after the first attempt
+	WebhookRetryPeriod time.Duration
+
+	// PublicURL is the address clients reach this server at, used to link to jobs from notifications
+	PublicURL string
+
+	// AdminToken is the bearer token the admin endpoints require; they are disabled when it is empty
+	AdminToken string
+
+	// ProfilesFile is a JSON file of named sets of job options that requests select with profile
+	ProfilesFile string
+
+	// TenantsFile is a JSON file of tenants, each with its own API keys, jobs, providers, quotas, and
+	// retention. When it is set every API request needs a tenant's key
+	TenantsFile string
+
+	// RetentionTTL is how long finished jobs are kept; zero keeps them forever
+	RetentionTTL time.Duration
+
+	// RetentionInterval is how often the retention job looks for expired jobs
+	RetentionInterval time.Duration
+
+	// OrphanMaxAge is how long scratch files nothing writes to are left before they are swept as
+	// the leftovers of a crash; zero disables the sweeper
+	OrphanMaxAge time.Duration
+
+	// OrphanSweepInterval is how often the sweeper looks for them, after the sweep at startup
+	OrphanSweepInterval time.Duration
+
+	// Workers is how many jobs run the pipeline at once across the whole server
+	Workers int64
+
+	// QueueSize is how many more jobs may wait for a worker before new ones are turned away
+	QueueSize int64
+
+	// MaxUpstreamRequests caps the provider requests in flight across every job; 0 for no cap
+	MaxUpstreamRequests int64
+
+	// RequestsPerSecond caps how fast provider requests start across every job; 0 for no cap
+	RequestsPerSecond float64
+
+	// QueueRetryAfter is the Retry-After sent when the queue is full
+	QueueRetryAfter time.Duration
+
+	// PriorityShares caps the percentage of workers each priority may hold, as "priority=percent" entries
+	PriorityShares []string
+
+	// CostPerMinute overrides the USD price per audio minute used for cost estimates, as "model=price" entries
+	CostPerMinute []string
+
+	// RedactNames also masks people's names when redacting, by asking the summary chat model for them
+	RedactNames bool
+
+	// RedactAll redacts every job, so no unredacted transcript is ever stored
+	RedactAll bool
+
+	// Vocabulary lists domain terms sent in every chunk's prompt to bias the transcription model
+	Vocabulary []string
+
+	// ProfanityWordlist is a file of words for profanity_filter, one per line; empty uses the built-in list
+	ProfanityWordlist string
+
+	// Dictionary is a file of domain terms, one per line, whose near-miss spellings are corrected in
+	// every transcript
+	Dictionary string
+
+	// KeywordLimit is how many keywords are returned when a request asks for them
+	KeywordLimit int64
+
+	// ChapterMinLength is the shortest chapter chapter detection makes
+	ChapterMinLength time.Duration
+
+	// SpeakerEmbeddingURL is the service that turns a voice clip into an embedding, for enrolling
+	// speakers and identifying them in transcripts
+	SpeakerEmbeddingURL string
+
+	// SpeakerThreshold is how similar, from 0 to 1, a segment's voice must be to an enrolled
+	// speaker's to be labeled with them
+	SpeakerThreshold float64
+
+	// AudioTaggingURL is the audio-tagging model that labels the sounds between speech, for
+	// audio_events
+	AudioTaggingURL string
+
+	// AudioEventLabels are the labels audio_events keeps, matched case-insensitively against
+	// the tagging model's, so a caption reads "[Laughter]" but not "[Silence]" or "[Speech]"
+	AudioEventLabels []string
+
+	// AudioEventThreshold is how sure, from 0 to 1, the tagging model must be of a label to keep it
+	AudioEventThreshold float64
+
+	// ChannelLabels name the left and right channels when a request splits channels without labels of its own
+	ChannelLabels []string
+
+	// RNNoiseModel is an RNNoise model file that denoising uses instead of ffmpeg's FFT denoiser
+	RNNoiseModel string
+
+	// ParagraphGap is the pause between segments that starts a new paragraph in readable text
+	ParagraphGap time.Duration
+
+	// AllowedExtensions are the extensions uploaded files may have; empty accepts any
+	AllowedExtensions []string
+
+	// AllowedContainers are the sniffed container formats media may be in; empty accepts any
+	AllowedContainers []string
+
+	// MaxDuration rejects media longer than this at the ffprobe stage; zero allows any length
+	MaxDuration time.Duration
+
+	// RedisURL enables the shared job queue so any instance can run a job accepted by another;
+	// empty runs every job on the instance that accepted it
+	RedisURL string
+
+	// QueueWorker makes this instance claim jobs from the shared queue; disable it for API-only instances
+	QueueWorker bool
+
+	// GRPCAddr is where the gRPC API listens; empty disables it
+	GRPCAddr string
+
+	// ReadyCheckProvider makes /readyz also ping the transcription API
+	ReadyCheckProvider bool
+
+	// ListenAddr is the host:port the REST API listens on, or unix:/path for a Unix socket. A
+	// socket passed by systemd socket activation takes its place
+	ListenAddr string
+
+	// ListenSocketMode is the permissions of the Unix socket ListenAddr names
+	ListenSocketMode os.FileMode
+
+	// TrustedProxies are the proxy IPs or CIDRs whose X-Forwarded-For/X-Real-IP headers are believed
+	TrustedProxies []string
+
+	// TrustedPlatform names a CDN or platform whose client IP header is trusted: cloudflare, google, or a header name
+	TrustedPlatform string
+
+	// CORSOrigins are the browser origins allowed to call the API; each may contain one * wildcard.
+	// The default is the Vite dev server
+	CORSOrigins []string
+
+	// CORSOriginPattern is a regular expression that whole origins are also matched against
+	CORSOriginPattern string
+
+	// CORSAllowAll accepts requests from any origin (for development)
+	CORSAllowAll bool
+
+	// WebUI serves the upload page at /
+	WebUI bool
+
+	// ResponseCompression are the encodings transcripts may be compressed with, most preferred
+	// first; "none" turns compression off
+	ResponseCompression []string
+
+	// TLSCertFile and TLSKeyFile serve the API over HTTPS with a fixed certificate
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// AutocertDomains serves HTTPS with Let's Encrypt certificates obtained for these hostnames
+	AutocertDomains []string
+
+	// AutocertEmail is the contact address registered with Let's Encrypt
+	AutocertEmail string
+
+	// AutocertCacheDir stores issued certificates so restarts don't request new ones
+	AutocertCacheDir string
+
+	// HTTPRedirectAddr, when TLS is on, serves plain HTTP that redirects to HTTPS and answers ACME challenges
+	HTTPRedirectAddr string
+
+	// LogFormat is "text" or "json"
+	LogFormat string
+
+	// LogLevel is the minimum level logged: debug, info, warn, or error
+	LogLevel string
+
+	// ShutdownTimeout is how long in-flight jobs may keep running after SIGTERM before they are canceled
+	ShutdownTimeout time.Duration
+
+	// ResumeJobs checkpoints each job's finished chunks and runs jobs interrupted by a crash or
+	// shutdown again on restart, reusing those chunks
+	ResumeJobs bool
+
+	// EncryptTempFiles encrypts each job's media, preprocessed audio, and chunks on disk with a
+	// key that only lives in memory for the job's lifetime
+	EncryptTempFiles bool
+}
+
+// appConfig is the configuration the server was started with
+var appConfig Config
+
+// loadConfig reads the server configuration from the environment
+func loadConfig() Config {
+	return Config{
+		WorkDir:             getEnv("TRANSCRIBER_WORK_DIR", os.TempDir()),
+		AudioDir:            getEnv("TRANSCRIBER_AUDIO_DIR", ""),
+		VideoDir:            getEnv("TRANSCRIBER_VIDEO_DIR", ""),
+		DiskExpansionFactor: getEnvFloat("TRANSCRIBER_DISK_EXPANSION_FACTOR", 3.0),
+		MemoryProcessing:    getEnvBool("TRANSCRIBER_MEMORY_PROCESSING", false),
+		MemoryDir:           getEnv("TRANSCRIBER_MEMORY_DIR", "/dev/shm"),
+		MemoryLimitBytes:    getEnvInt64("TRANSCRIBER_MEMORY_LIMIT_BYTES", 256<<20),
+		MaxUploadBytes:      getEnvInt64("TRANSCRIBER_MAX_UPLOAD_BYTES", 1<<30),
+		MaxBatchSize:        getEnvInt64("TRANSCRIBER_MAX_BATCH_SIZE", 20),
+		DownloadTimeout:     getEnvDuration("TRANSCRIBER_DOWNLOAD_TIMEOUT", 10*time.Minute),
+		PreprocessTimeout:   getEnvDuration("TRANSCRIBER_PREPROCESS_TIMEOUT", 30*time.Minute),
+		ChunkingTimeout:     getEnvDuration("TRANSCRIBER_CHUNKING_TIMEOUT", 10*time.Minute),
+		ChunkTimeout:        getEnvDuration("TRANSCRIBER_CHUNK_TIMEOUT", 2*time.Minute),
+		MaxChunkBytes:       getEnvInt64("TRANSCRIBER_MAX_CHUNK_BYTES", 24_000_000),
+		JobTimeout:          getEnvDuration("TRANSCRIBER_JOB_TIMEOUT", 0),
+		LiveSegmentSeconds:  getEnvFloat("TRANSCRIBER_LIVE_SEGMENT_SECONDS", 10),
+		NonSpeechSeconds:    getEnvFloat("TRANSCRIBER_NON_SPEECH_SECONDS", 20),
+		SilenceSeconds:      getEnvFloat("TRANSCRIBER_SILENCE_SECONDS", 2),
+		LiveMaxDuration:     getEnvDuration("TRANSCRIBER_LIVE_MAX_DURATION", 4*time.Hour),
+		AllowPrivateURLs:    getEnvBool("TRANSCRIBER_ALLOW_PRIVATE_URLS", false),
+		LocalRoots:          getEnvList("TRANSCRIBER_LOCAL_ROOTS", nil),
+		WatchDirs:           getEnvList("TRANSCRIBER_WATCH_DIRS", nil),
+		WatchInterval:       getEnvDuration("TRANSCRIBER_WATCH_INTERVAL", 10*time.Second),
+		WatchExtensions:     lowerList(getEnvList("TRANSCRIBER_WATCH_EXTENSIONS", mediaExtensions)),
+		WatchOutputs:        lowerList(getEnvList("TRANSCRIBER_WATCH_OUTPUTS", []string{"text", "srt", "json"})),
+		WatchOptions:        getEnv("TRANSCRIBER_WATCH_OPTIONS", ""),
+		WatchTenant:         getEnv("TRANSCRIBER_WATCH_TENANT", ""),
+		SchedulesFile:       getEnv("TRANSCRIBER_SCHEDULES_FILE", ""),
+		S3Region:            getEnv("TRANSCRIBER_S3_REGION", ""),
+		S3Endpoint:          getEnv("TRANSCRIBER_S3_ENDPOINT", ""),
+		S3AccessKeyID:       getEnv("TRANSCRIBER_S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:   getEnv("TRANSCRIBER_S3_SECRET_ACCESS_KEY", ""),
+		ResultsBucket:       getEnv("TRANSCRIBER_RESULTS_BUCKET", ""),
+		ResultsFormats:      lowerList(getEnvList("TRANSCRIBER_RESULTS_FORMATS", []string{"text", "srt", "vtt"})),
+		ResultsURLExpiry:    getEnvDuration("TRANSCRIBER_RESULTS_URL_EXPIRY", time.Hour),
+		KafkaBrokers:        getEnvList("TRANSCRIBER_KAFKA_BROKERS", nil),
+		KafkaTopic:          getEnv("TRANSCRIBER_KAFKA_TOPIC", "transcriptions"),
+		KafkaTLS:            getEnvBool("TRANSCRIBER_KAFKA_TLS", false),
+		KafkaUsername:       getEnv("TRANSCRIBER_KAFKA_USERNAME", ""),
+		KafkaPassword:       getEnv("TRANSCRIBER_KAFKA_PASSWORD", ""),
+		KafkaSASLMechanism:  getEnv("TRANSCRIBER_KAFKA_SASL_MECHANISM", "plain"),
+		TwilioAccountSID:    getEnv("TRANSCRIBER_TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:     getEnv("TRANSCRIBER_TWILIO_AUTH_TOKEN", ""),
+		TwilioWebhookURL:    getEnv("TRANSCRIBER_TWILIO_WEBHOOK_URL", ""),
+		TwilioTenant:        getEnv("TRANSCRIBER_TWILIO_TENANT", ""),
+		ZoomSecretToken:     getEnv("TRANSCRIBER_ZOOM_SECRET_TOKEN", ""),
+		ZoomAccountID:       getEnv("TRANSCRIBER_ZOOM_ACCOUNT_ID", ""),
+		ZoomClientID:        getEnv("TRANSCRIBER_ZOOM_CLIENT_ID", ""),
+		ZoomClientSecret:    getEnv("TRANSCRIBER_ZOOM_CLIENT_SECRET", ""),
+		ZoomWebhookURL:      getEnv("TRANSCRIBER_ZOOM_WEBHOOK_URL", ""),
+		ZoomTenant:          getEnv("TRANSCRIBER_ZOOM_TENANT", ""),
+		ZoomSplitSpeakers:   getEnvBool("TRANSCRIBER_ZOOM_SPLIT_SPEAKERS", false),
+		BusURL:              getEnv("TRANSCRIBER_BUS_URL", ""),
+		BusRequests:         getEnv("TRANSCRIBER_BUS_REQUESTS", "transcription.requests"),
+		BusResults:          getEnv("TRANSCRIBER_BUS_RESULTS", "transcription.results"),
+		GCSCredentialsFile:  getEnv("TRANSCRIBER_GCS_CREDENTIALS_FILE", ""),
+		AzureAccountName:    getEnv("TRANSCRIBER_AZURE_ACCOUNT_NAME", ""),
+		AzureAccountKey:     getEnv("TRANSCRIBER_AZURE_ACCOUNT_KEY", ""),
+		AzureEndpoint:       getEnv("TRANSCRIBER_AZURE_ENDPOINT", ""),
+		YtDlpPath:           getEnv("TRANSCRIBER_YTDLP_PATH", "yt-dlp"),
+		FFmpegPath:          getEnv("TRANSCRIBER_FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:         getEnv("TRANSCRIBER_FFPROBE_PATH", "ffprobe"),
+		HWAccel:             getEnv("TRANSCRIBER_HWACCEL", ""),
+		FFmpegCheck:         getEnvBool("TRANSCRIBER_FFMPEG_CHECK", true),
+		ClamAVEnabled:       getEnvBool("TRANSCRIBER_CLAMAV_ENABLED", false),
+		ClamAVAddr:          getEnv("TRANSCRIBER_CLAMAV_ADDR", "unix:///var/run/clamav/clamd.ctl"),
+		DatabaseDriver:      getEnv("TRANSCRIBER_DB_DRIVER", "sqlite"),
+		DatabaseDSN:         getEnv("TRANSCRIBER_DB_DSN", "transcriber.db"),
+		GroqAPIKey:          getEnv("GROQ_API_KEY", ""),
+		OpenAIAPIKey:        getEnv("OPENAI_API_KEY", ""),
+		GroqURL:             getEnv("TRANSCRIBER_GROQ_URL", ""),
+		OpenAIURL:           getEnv("TRANSCRIBER_OPENAI_URL", ""),
+		SecretsBackend:      getEnv("TRANSCRIBER_SECRETS_BACKEND", ""),
+		SecretsRefresh:      getEnvDuration("TRANSCRIBER_SECRETS_REFRESH_INTERVAL", 5*time.Minute),
+		VaultAddr:           getEnv("VAULT_ADDR", ""),
+		VaultToken:          getEnv("VAULT_TOKEN", ""),
+		VaultSecretPath:     getEnv("TRANSCRIBER_VAULT_SECRET_PATH", ""),
+		AWSSecretID:         getEnv("TRANSCRIBER_AWS_SECRET_ID", ""),
+		AWSSecretRegion:     getEnv("TRANSCRIBER_AWS_SECRET_REGION", ""),
+		Provider:            getEnv("TRANSCRIBER_PROVIDER", "groq"),
+		Model:               getEnv("TRANSCRIBER_MODEL", ""),
+		AllowedModels:       getEnvList("TRANSCRIBER_ALLOWED_MODELS", defaultAllowedModels),
+		Language:            strings.ToLower(getEnv("TRANSCRIBER_LANGUAGE", "en")),
+		LanguageModels:      getEnvList("TRANSCRIBER_LANGUAGE_MODELS", nil),
+		SummaryURL:          getEnv("TRANSCRIBER_SUMMARY_URL", defaultSummaryURL),
+		SummaryModel:        getEnv("TRANSCRIBER_SUMMARY_MODEL", defaultSummaryModel),
+		SummaryAPIKey:       getEnv("TRANSCRIBER_SUMMARY_API_KEY", ""),
+		SummaryPrompt:       getEnv("TRANSCRIBER_SUMMARY_PROMPT", defaultSummaryPrompt),
+		TranslationBackend:  strings.ToLower(getEnv("TRANSCRIBER_TRANSLATION_BACKEND", translationLLM)),
+		DeepLURL:            getEnv("TRANSCRIBER_DEEPL_URL", defaultDeepLURL),
+		DeepLAPIKey:         getEnv("TRANSCRIBER_DEEPL_API_KEY", ""),
+		SMTPHost:            getEnv("TRANSCRIBER_SMTP_HOST", ""),
+		SMTPPort:            getEnv("TRANSCRIBER_SMTP_PORT", "587"),
+		SMTPUsername:        getEnv("TRANSCRIBER_SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("TRANSCRIBER_SMTP_PASSWORD", ""),
+		SMTPFrom:            getEnv("TRANSCRIBER_SMTP_FROM", ""),
+		SlackWebhookURL:     getEnv("TRANSCRIBER_SLACK_WEBHOOK_URL", ""),
+		DiscordWebhookURL:   getEnv("TRANSCRIBER_DISCORD_WEBHOOK_URL", ""),
+		WebhookRetryPeriod:  getEnvDuration("TRANSCRIBER_WEBHOOK_RETRY_PERIOD", 24*time.Hour),
+		PublicURL:           getEnv("TRANSCRIBER_PUBLIC_URL", ""),
+		AdminToken:          getEnv("TRANSCRIBER_ADMIN_TOKEN", ""),
+		TenantsFile:         getEnv("TRANSCRIBER_TENANTS_FILE", ""),
+		ProfilesFile:        getEnv("TRANSCRIBER_PROFILES_FILE", ""),
+		RetentionTTL:        getEnvDuration("TRANSCRIBER_RETENTION_TTL", 0),
+		RetentionInterval:   getEnvDuration("TRANSCRIBER_RETENTION_INTERVAL", time.Hour),
+		OrphanMaxAge:        getEnvDuration("TRANSCRIBER_ORPHAN_MAX_AGE", 24*time.Hour),
+		OrphanSweepInterval: getEnvDuration("TRANSCRIBER_ORPHAN_SWEEP_INTERVAL", time.Hour),
+		Workers:             getEnvInt64("TRANSCRIBER_WORKERS", 2),
+		QueueSize:           getEnvInt64("TRANSCRIBER_QUEUE_SIZE", 10),
+		MaxUpstreamRequests: getEnvInt64("TRANSCRIBER_MAX_CONCURRENT_REQUESTS", 10),
+		RequestsPerSecond:   getEnvFloat("TRANSCRIBER_REQUESTS_PER_SECOND", 0),
+		QueueRetryAfter:     getEnvDuration("TRANSCRIBER_QUEUE_RETRY_AFTER", 30*time.Second),
+		PriorityShares:      getEnvList("TRANSCRIBER_PRIORITY_SHARES", []string{"batch=50"}),
+		CostPerMinute:       getEnvList("TRANSCRIBER_COST_PER_MINUTE", nil),
+		RedactNames:         getEnvBool("TRANSCRIBER_REDACT_NAMES", false),
+		RedactAll:           getEnvBool("TRANSCRIBER_REDACT_ALL", false),
+		Vocabulary:          getEnvList("TRANSCRIBER_VOCABULARY", nil),
+		ProfanityWordlist:   getEnv("TRANSCRIBER_PROFANITY_WORDLIST", ""),
+		Dictionary:          getEnv("TRANSCRIBER_DICTIONARY", ""),
+		KeywordLimit:        getEnvInt64("TRANSCRIBER_KEYWORD_LIMIT", 10),
+		ChapterMinLength:    getEnvDuration("TRANSCRIBER_CHAPTER_MIN_LENGTH", time.Minute),
+		SpeakerEmbeddingURL: getEnv("TRANSCRIBER_SPEAKER_EMBEDDING_URL", ""),
+		SpeakerThreshold:    getEnvFloat("TRANSCRIBER_SPEAKER_THRESHOLD", 0.75),
+		AudioTaggingURL:     getEnv("TRANSCRIBER_AUDIO_TAGGING_URL", ""),
+		AudioEventLabels:    lowerList(getEnvList("TRANSCRIBER_AUDIO_EVENT_LABELS", defaultAudioEventLabels)),
+		AudioEventThreshold: getEnvFloat("TRANSCRIBER_AUDIO_EVENT_THRESHOLD", 0.5),
+		ChannelLabels:       getEnvList("TRANSCRIBER_CHANNEL_LABELS", []string{"Agent", "Customer"}),
+		RNNoiseModel:        getEnv("TRANSCRIBER_RNNOISE_MODEL", ""),
+		ParagraphGap:        getEnvDuration("TRANSCRIBER_PARAGRAPH_GAP", 2*time.Second),
+		AllowedExtensions:   lowerList(getEnvList("TRANSCRIBER_ALLOWED_EXTENSIONS", nil)),
+		AllowedContainers:   lowerList(getEnvList("TRANSCRIBER_ALLOWED_CONTAINERS", nil)),
+		MaxDuration:         getEnvDuration("TRANSCRIBER_MAX_DURATION", 0),
+		RedisURL:            getEnv("TRANSCRIBER_REDIS_URL", ""),
+		QueueWorker:         getEnvBool("TRANSCRIBER_QUEUE_WORKER", true),
+		GRPCAddr:            getEnv("TRANSCRIBER_GRPC_ADDR", ""),
+		ReadyCheckProvider:  getEnvBool("TRANSCRIBER_READY_CHECK_PROVIDER", false),
+		ListenAddr:          getEnv("TRANSCRIBER_LISTEN_ADDR", ":8080"),
+		ListenSocketMode:    getEnvFileMode("TRANSCRIBER_LISTEN_SOCKET_MODE", 0o660),
+		TrustedProxies:      getEnvList("TRANSCRIBER_TRUSTED_PROXIES", nil),
+		TrustedPlatform:     getEnv("TRANSCRIBER_TRUSTED_PLATFORM", ""),
+		CORSOrigins:         getEnvList("TRANSCRIBER_CORS_ORIGINS", []string{"http://localhost:5173"}),
+		CORSOriginPattern:   getEnv("TRANSCRIBER_CORS_ORIGIN_PATTERN", ""),
+		CORSAllowAll:        getEnvBool("TRANSCRIBER_CORS_ALLOW_ALL", false),
+		WebUI:               getEnvBool("TRANSCRIBER_WEB_UI", true),
+		ResponseCompression: getEnvList("TRANSCRIBER_RESPONSE_COMPRESSION", []string{encodingGzip}),
+		TLSCertFile:         getEnv("TRANSCRIBER_TLS_CERT_FILE", ""),
+		TLSKeyFile:          getEnv("TRANSCRIBER_TLS_KEY_FILE", ""),
+		AutocertDomains:     getEnvList("TRANSCRIBER_AUTOCERT_DOMAINS", nil),
+		AutocertEmail:       getEnv("TRANSCRIBER_AUTOCERT_EMAIL", ""),
+		AutocertCacheDir:    getEnv("TRANSCRIBER_AUTOCERT_CACHE_DIR", "autocert-cache"),
+		HTTPRedirectAddr:    getEnv("TRANSCRIBER_HTTP_REDIRECT_ADDR", ""),
+		LogFormat:           getEnv("TRANSCRIBER_LOG_FORMAT", "text"),
+		LogLevel:            getEnv("TRANSCRIBER_LOG_LEVEL", "info"),
+		ShutdownTimeout:     getEnvDuration("TRANSCRIBER_SHUTDOWN_TIMEOUT", 30*time.Second),
+		ResumeJobs:          getEnvBool("TRANSCRIBER_RESUME_JOBS", true),
+		EncryptTempFiles:    getEnvBool("TRANSCRIBER_ENCRYPT_TEMP_FILES", false),
+	}
+}
+
+// getEnv returns the value of an environment variable or a fallback if unset
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList returns a comma-separated environment variable as a list, or a fallback if unset
+func getEnvList(key string, fallback []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvFloat returns an environment variable parsed as a float or a fallback if unset or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("Invalid configuration value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvInt64 returns an environment variable parsed as an integer or a fallback if unset or invalid
+func getEnvInt64(key string, fallback int64) int64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		slog.Warn("Invalid configuration value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvDuration returns an environment variable parsed as a duration (e.g. "90s") or a fallback if unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid configuration value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvFileMode returns an environment variable parsed as octal permissions (e.g. "0660") or a
+// fallback if unset or invalid
+func getEnvFileMode(key string, fallback os.FileMode) os.FileMode {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || parsed > 0o777 {
+		slog.Warn("Invalid configuration value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return os.FileMode(parsed)
+}
+
+// getEnvBool returns an environment variable parsed as a boolean or a fallback if unset or invalid
+func getEnvBool(key string, fallback bool) bool {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid configuration value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
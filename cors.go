@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// corsHandler is the CORS middleware for the current policy, replaced when it is reloaded
+var corsHandler atomic.Pointer[gin.HandlerFunc]
+
+// initCORS builds the CORS middleware for config's policy and makes it current
+func initCORS(config Config) error {
+	corsCfg, err := corsConfig(config)
+	if err != nil {
+		return err
+	}
+	useCORSPolicy(corsCfg)
+	return nil
+}
+
+// useCORSPolicy makes corsCfg the policy requests are checked against from now on
+func useCORSPolicy(corsCfg cors.Config) {
+	handler := cors.New(corsCfg)
+	corsHandler.Store(&handler)
+}
+
+// handleCORS applies the current CORS policy
+func handleCORS(c *gin.Context) {
+	(*corsHandler.Load())(c)
+}
+
+// corsConfig builds the CORS policy from the configured origins, origin pattern, or allow-all mode
+func corsConfig(config Config) (cors.Config, error) {
+	corsCfg := cors.DefaultConfig()
+	corsCfg.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"}
+	corsCfg.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "X-Request-ID", "X-Content-SHA256", "X-Filename"}
+	corsCfg.ExposeHeaders = []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Transcription-Location", "X-Request-ID", "X-Job-ID"}
+
+	if config.CORSAllowAll {
+		corsCfg.AllowAllOrigins = true
+		return corsCfg, nil
+	}
+
+	// Entries like https://*.example.com match any subdomain
+	for _, origin := range config.CORSOrigins {
+		if strings.Count(origin, "*") > 1 {
+			return cors.Config{}, fmt.Errorf("invalid CORS origin %q: only one * is allowed", origin)
+		}
+	}
+	corsCfg.AllowOrigins = config.CORSOrigins
+	corsCfg.AllowWildcard = true
+
+	if config.CORSOriginPattern != "" {
+		// Anchor the pattern so it has to match the whole origin, not just part of it
+		pattern, err := regexp.Compile("^(?:" + config.CORSOriginPattern + ")$")
+		if err != nil {
+			return cors.Config{}, fmt.Errorf("invalid TRANSCRIBER_CORS_ORIGIN_PATTERN: %w", err)
+		}
+		corsCfg.AllowOriginFunc = pattern.MatchString
+	}
+
+	if err := corsCfg.Validate(); err != nil {
+		return cors.Config{}, fmt.Errorf("invalid CORS settings: %w", err)
+	}
+	return corsCfg, nil
+}
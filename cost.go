@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// defaultCostPerMinute holds Groq's and OpenAI's list prices in USD per minute of audio
+var defaultCostPerMinute = map[string]float64{
+	"whisper-large-v3":           0.111 / 60,
+	"whisper-large-v3-turbo":     0.04 / 60,
+	"distil-whisper-large-v3-en": 0.02 / 60,
+	"whisper-1":                  0.006,
+}
+
+// costPerMinute is the price per audio minute of each model, after configured overrides
+var costPerMinute = defaultCostPerMinute
+
+// UsageMetadata describes what a transcription consumed, so callers can display and track spend
+type UsageMetadata struct {
+	DurationSeconds  float64 `json:"duration_seconds"`
+	Chunks           int     `json:"chunks"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// initCostRates applies "model=price" overrides to the default per-minute prices, skipping
+// invalid entries with a warning
+func initCostRates(entries []string) {
+	costPerMinute = map[string]float64{}
+	for model, price := range defaultCostPerMinute {
+		costPerMinute[model] = price
+	}
+	for _, entry := range entries {
+		model, value, _ := strings.Cut(entry, "=")
+		price, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if strings.TrimSpace(model) == "" || err != nil || price < 0 {
+			slog.Warn("Ignoring invalid cost rate", "entry", entry)
+			continue
+		}
+		costPerMinute[strings.TrimSpace(model)] = price
+	}
+}
+
+// estimateCost prices a result at the model's per-minute rate. Cached results cost nothing, and
+// models without a known rate are estimated at zero
+func estimateCost(model string, result *transcriber.Result) float64 {
+	if result.Cached {
+		return 0
+	}
+	return costFor(model, result.AudioSeconds)
+}
+
+// costFor prices the given seconds of audio at the model's per-minute rate
+func costFor(model string, audioSeconds float64) float64 {
+	cost := audioSeconds / 60 * costPerMinute[model]
+
+	// Fractions of a cent below this are noise
+	return math.Round(cost*1e6) / 1e6
+}
+
+// jobUsage reports the usage recorded for a finished job
+func jobUsage(job *Job) *UsageMetadata {
+	return &UsageMetadata{
+		DurationSeconds:  job.DurationSeconds,
+		Chunks:           job.Chunks,
+		Provider:         job.Provider,
+		Model:            job.Model,
+		EstimatedCostUSD: job.EstimatedCost,
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// adminDashboardPage shows the queue, jobs, and throughput from the admin endpoints, which it
+// calls with the admin token entered on the page
+//
+//go:embed web/admin.html
+var adminDashboardPage []byte
+
+// throughputWindows are the periods the throughput endpoint covers, by name, and how long each of
+// their intervals is
+var throughputWindows = map[string]struct {
+	duration time.Duration
+	bucket   time.Duration
+}{
+	"1h":  {time.Hour, 5 * time.Minute},
+	"24h": {24 * time.Hour, time.Hour},
+	"7d":  {7 * 24 * time.Hour, 6 * time.Hour},
+}
+
+// ThroughputResponse counts the jobs that finished in each interval of a window
+type ThroughputResponse struct {
+	GeneratedAt   time.Time          `json:"generated_at"`
+	Window        string             `json:"window"`
+	BucketSeconds int                `json:"bucket_seconds"`
+	Buckets       []ThroughputBucket `json:"buckets"`
+}
+
+// serveAdminDashboard serves the admin dashboard. The page itself holds nothing secret; like the
+// admin endpoints it reads from, it doesn't exist without TRANSCRIBER_ADMIN_TOKEN
+func serveAdminDashboard(c *gin.Context) {
+	if appConfig.AdminToken == "" {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Admin API is disabled: set TRANSCRIBER_ADMIN_TOKEN to enable it"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", adminDashboardPage)
+}
+
+// adminJobs lists the jobs of every tenant, newest first, filtered by status and tenant_id
+func adminJobs(c *gin.Context) {
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "page must be a positive integer"})
+		return
+	}
+	pageSize, err := parsePositiveInt(c.Query("page_size"), defaultPageSize)
+	if err != nil || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("page_size must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	jobs, total, err := jobStore.ListJobs(JobFilter{
+		TenantID: c.Query("tenant_id"),
+		Status:   c.Query("status"),
+		SortBy:   "created_at",
+		Desc:     true,
+		Limit:    pageSize,
+		Offset:   (page - 1) * pageSize,
+	})
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error listing jobs", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list transcriptions"})
+		return
+	}
+	c.JSON(http.StatusOK, TranscriptionListResponse{
+		Transcriptions: jobs,
+		Total:          total,
+		Page:           page,
+		PageSize:       pageSize,
+	})
+}
+
+// adminThroughput counts the jobs that finished in each interval of the last hour, day, or week
+func adminThroughput(c *gin.Context) {
+	name := c.DefaultQuery("window", "24h")
+	window, ok := throughputWindows[name]
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown window %q: expected 1h, 24h, or 7d", name)})
+		return
+	}
+	now := time.Now().UTC()
+	buckets, err := jobStore.JobThroughput(now.Add(-window.duration), window.bucket)
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job throughput", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load job throughput"})
+		return
+	}
+	c.JSON(http.StatusOK, ThroughputResponse{
+		GeneratedAt:   now,
+		Window:        name,
+		BucketSeconds: int(window.bucket.Seconds()),
+		Buckets:       buckets,
+	})
+}
+
+// adminCancelJob cancels any tenant's queued or running job, as the cancel endpoint does
+func adminCancelJob(c *gin.Context) {
+	job, err := jobStore.GetJob(c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	cancelJob(c, job)
+}
+
+// adminRetryJob runs a finished job again from its retained audio, as a new job of the same tenant
+// with the options it ran with. The new job is answered with 202 and runs in the background
+func adminRetryJob(c *gin.Context) {
+	previous, err := jobStore.GetJob(c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	if previous.Status == JobStatusProcessing {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription is still processing"})
+		return
+	}
+	audioPath := retainedAudioPath(previous.ID)
+	if _, err := os.Stat(audioPath); !previous.AudioRetained || err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription has no retained audio: only jobs submitted with retain_audio=true can be retried"})
+		return
+	}
+
+	// The job outlives the request, and counts against its tenant's quotas as if the tenant had
+	// submitted it
+	ctx := context.WithoutCancel(c.Request.Context())
+	tenant := tenantsByID[previous.TenantID]
+	if tenant != nil {
+		ctx = withTenant(ctx, tenant)
+	}
+	opts, err := retryJobOptions(tenant, previous)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	release, err := admitJob(ctx)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	job, jobDir, err := createJob(ctx, &Job{ID: uuid.New().String(), Filename: previous.Filename})
+	if err != nil {
+		release()
+		respondWithStartError(c, err)
+		return
+	}
+	tagJob(c, job.ID)
+	jobCtx := withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID))
+	inputPath := filepath.Join(jobDir, "retained-audio")
+	if err := linkOrCopy(audioPath, inputPath); err != nil {
+		finishJob(jobCtx, job, nil, &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to read retained audio"})
+		removeJobDir(jobDir)
+		release()
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to read retained audio"})
+		return
+	}
+	loggerFrom(jobCtx).Info("Retrying job", "previous_job_id", previous.ID)
+
+	go func() {
+		defer release()
+		defer removeJobDir(jobDir)
+		executeJob(jobCtx, job, jobDir, inputPath, opts)
+	}()
+	c.JSON(http.StatusAccepted, job)
+}
+
+// retryJobOptions are the options a retry of previous runs with: the ones it ran with, checked
+// against the tenant's current providers. Jobs retained before their options were stored only
+// have what the job row records
+func retryJobOptions(tenant *Tenant, previous *Job) (JobOptions, error) {
+	opts := JobOptions{Redact: previous.Redacted}
+	if previous.Options != nil {
+		opts = *previous.Options
+	}
+	selection, err := parseModelSelection(tenant, previous.Provider, previous.Model, opts.Temperature)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	opts.Provider, opts.Model, opts.Temperature = selection.Provider, selection.Model, selection.Temperature
+	opts.RetainAudio = true
+	opts.UploadMs = 0
+	return opts, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestRetryJobOptions(t *testing.T) {
+	useTestConfig(t)
+
+	tests := []struct {
+		name     string
+		previous Job
+		check    func(JobOptions) bool
+	}{
+		{"stored options", Job{Provider: "groq", Options: &JobOptions{Language: "fr", Keywords: true, Punctuation: "rules", UploadMs: 12}}, func(opts JobOptions) bool {
+			return opts.Language == "fr" && opts.Keywords && opts.Punctuation == "rules" && opts.RetainAudio && opts.UploadMs == 0
+		}},
+		{"no stored options", Job{Provider: "groq", Redacted: true}, func(opts JobOptions) bool {
+			return opts.Redact && opts.RetainAudio && opts.Provider == "groq" && opts.Model != ""
+		}},
+		{"job's model over stored", Job{Provider: "groq", Model: "whisper-large-v3", Options: &JobOptions{Model: "distil-whisper-large-v3-en"}}, func(opts JobOptions) bool {
+			return opts.Model == "whisper-large-v3"
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := retryJobOptions(nil, &test.previous)
+			if err != nil {
+				t.Fatalf("retryJobOptions: %v", err)
+			}
+			if !test.check(opts) {
+				t.Errorf("unexpected options %+v", opts)
+			}
+		})
+	}
+
+	if _, err := retryJobOptions(nil, &Job{Provider: "nobody"}); err == nil {
+		t.Error("retryJobOptions accepted an unknown provider")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// dictionary corrects near-miss spellings of the operator's domain terms in every transcript. It
+// is empty unless TRANSCRIBER_DICTIONARY is set
+var dictionary = transcriber.NewDictionary(nil)
+
+// initDictionary loads the dictionary at path, leaving it empty when path is empty
+func initDictionary(path string) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	terms, err := transcriber.ReadDictionary(file)
+	if err != nil {
+		return err
+	}
+	dictionary = transcriber.NewDictionary(terms)
+	return nil
+}
+
+// correctSpelling replaces near-miss spellings of dictionary terms in a finished transcript's
+// segments, rebuilding its text when any were made, and records the corrections on the job
+func correctSpelling(job *Job, result *transcriber.Result) {
+	segments, corrections := dictionary.Correct(result.Segments)
+	if len(corrections) == 0 {
+		return
+	}
+	result.Segments = segments
+	result.Transcription = transcriber.JoinSegments(segments)
+	job.Corrections = corrections
+}
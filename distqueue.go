@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Redis keys shared by every instance
+const (
+	// redisQueuePrefix + priority lists jobs waiting for a worker; producers push on the left,
+	// workers take from the right
+	redisQueuePrefix = "transcriber:queue:"
+
+	// redisProcessingKey lists jobs a worker has claimed, until it finishes them
+	redisProcessingKey = "transcriber:processing"
+
+	// redisLeasePrefix + job ID exists while the claiming worker is alive
+	redisLeasePrefix = "transcriber:lease:"
+
+	// redisOutcomePrefix + job ID receives the outcome for the instance waiting on the job
+	redisOutcomePrefix = "transcriber:outcome:"
+
+	// redisCancelPrefix + job ID marks a job canceled before a worker claimed it
+	redisCancelPrefix = "transcriber:cancel:"
+
+	// redisCancelChannel carries the IDs of canceled jobs to the instance running them
+	redisCancelChannel = "transcriber:cancel"
+)
+
+const (
+	// leaseTTL is how long a claimed job survives without its worker renewing the lease
+	leaseTTL = 30 * time.Second
+
+	// outcomeTTL is how long an outcome waits for its requester to collect it
+	outcomeTTL = time.Hour
+
+	// redisPollTimeout bounds each blocking Redis call so shutdown is noticed promptly
+	redisPollTimeout = 5 * time.Second
+
+	// redisClaimInterval is how often an idle worker checks the lower-priority queues
+	redisClaimInterval = time.Second
+)
+
+// distQueue is the shared Redis queue, or nil when jobs run on the instance that accepted them
+var distQueue *redisQueue
+
+// queuedJob is a job handed to the shared queue. Its files live in the shared work directory
+type queuedJob struct {
+	JobID     string     `json:"job_id"`
+	JobDir    string     `json:"job_dir"`
+	InputPath string     `json:"input_path"`
+	Options   JobOptions `json:"options"`
+
+	// Trace carries the requester's trace context so the worker's spans join it
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// jobOutcome tells the waiting instance how a job ended; the transcript itself is in the job store
+type jobOutcome struct {
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Cached bool   `json:"cached,omitempty"`
+}
+
+// redisQueue distributes jobs across instances through Redis lists
+type redisQueue struct {
+	client     *redis.Client
+	instanceID string
+}
+
+// newRedisQueue connects to the Redis server at url (redis:// or rediss://)
+func newRedisQueue(ctx context.Context, url string) (*redisQueue, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	pingCtx, cancel := context.WithTimeout(ctx, redisPollTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &redisQueue{client: client, instanceID: uuid.New().String()}, nil
+}
+
+// redisQueueKey returns the list holding waiting jobs of the given priority
+func redisQueueKey(priority string) string {
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	return redisQueuePrefix + priority
+}
+
+// Ping verifies the Redis connection is usable
+func (q *redisQueue) Ping(ctx context.Context) error {
+	return q.client.Ping(ctx).Err()
+}
+
+// Close releases the Redis connections
+func (q *redisQueue) Close() error {
+	return q.client.Close()
+}
+
+// execute enqueues a job and waits for whichever instance claims it to finish. The worker records
+// the outcome in the job store; job is refreshed from there afterwards
+func (q *redisQueue) execute(ctx context.Context, job *Job, jobDir, inputPath string, opts JobOptions) (*transcriber.Result, error) {
+	queued := queuedJob{
+		JobID:     job.ID,
+		JobDir:    jobDir,
+		InputPath: inputPath,
+		Options:   opts,
+		Trace:     map[string]string{},
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(queued.Trace))
+
+	payload, err := json.Marshal(queued)
+	if err != nil {
+		finishJob(ctx, job, nil, err)
+		return nil, err
+	}
+	if err := q.client.LPush(ctx, redisQueueKey(opts.Priority), payload).Err(); err != nil {
+		err = &pipelineError{Status: http.StatusServiceUnavailable, Message: "Failed to enqueue job: " + err.Error()}
+		finishJob(ctx, job, nil, err)
+		return nil, err
+	}
+	loggerFrom(ctx).Info("Job enqueued")
+
+	// Waiting only stops for shutdown: another instance owns the job and its directory now, and
+	// there is no way to tell it the client went away
+	outcome, err := q.waitForOutcome(jobsCtx, job.ID)
+	if err != nil {
+		return nil, pipelineErrorFor(err)
+	}
+	if outcome.Error != "" {
+		return nil, &pipelineError{Status: outcome.Status, Message: outcome.Error}
+	}
+
+	finished, err := jobStore.GetJob(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	*job = *finished
+	return &transcriber.Result{
+		Transcription:   finished.Transcript,
+		Segments:        finished.Segments,
+		Words:           finished.Words,
+		DurationSeconds: finished.DurationSeconds,
+		AudioHash:       finished.AudioHash,
+		Cached:          outcome.Cached,
+		Chunks:          finished.Chunks,
+	}, nil
+}
+
+// waitForOutcome blocks until the job's outcome is published or ctx is canceled
+func (q *redisQueue) waitForOutcome(ctx context.Context, jobID string) (*jobOutcome, error) {
+	key := redisOutcomePrefix + jobID
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		values, err := q.client.BLPop(ctx, redisPollTimeout, key).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		var outcome jobOutcome
+		if err := json.Unmarshal([]byte(values[1]), &outcome); err != nil {
+			return nil, err
+		}
+		return &outcome, nil
+	}
+}
+
+// start runs workers goroutines that claim jobs until ctx is canceled, plus the lease reaper and
+// the listener for cancellations
+func (q *redisQueue) start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go q.work(ctx)
+	}
+	go q.reapExpiredLeases(ctx)
+	go q.watchCancellations(ctx)
+}
+
+// cancel asks whichever instance has a job to stop it. A job still waiting in the queue is
+// marked so the worker that claims it records it as canceled instead of running it
+func (q *redisQueue) cancel(ctx context.Context, jobID string) error {
+	pipe := q.client.TxPipeline()
+	pipe.Set(ctx, redisCancelPrefix+jobID, q.instanceID, outcomeTTL)
+	pipe.Publish(ctx, redisCancelChannel, jobID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// watchCancellations cancels the jobs running on this instance that other instances were asked
+// to cancel, until ctx is canceled
+func (q *redisQueue) watchCancellations(ctx context.Context) {
+	subscription := q.client.Subscribe(ctx, redisCancelChannel)
+	defer subscription.Close()
+	messages := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-messages:
+			if cancelLocalJob(message.Payload) != nil {
+				slog.Info("Job canceled by another instance", "job_id", message.Payload)
+			}
+		}
+	}
+}
+
+// work claims and processes jobs one at a time until ctx is canceled
+func (q *redisQueue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		payload, err := q.claim(ctx)
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Error claiming job from Redis", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		q.process(payload)
+	}
+}
+
+// claim moves the oldest waiting job of the most urgent priority this instance has room for onto
+// the processing list. High-priority jobs are waited for; the other queues are polled every
+// redisClaimInterval. It returns redis.Nil when nothing was claimed
+func (q *redisQueue) claim(ctx context.Context) (string, error) {
+	for _, priority := range jobPriorities {
+		if !pipelineWorkers.hasRoom(priority) {
+			continue
+		}
+		payload, err := q.client.LMove(ctx, redisQueueKey(priority), redisProcessingKey, "RIGHT", "LEFT").Result()
+		if !errors.Is(err, redis.Nil) {
+			return payload, err
+		}
+	}
+
+	if !pipelineWorkers.hasRoom(PriorityHigh) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(redisClaimInterval):
+		}
+		return "", redis.Nil
+	}
+	return q.client.BLMove(ctx, redisQueueKey(PriorityHigh), redisProcessingKey, "RIGHT", "LEFT", redisClaimInterval).Result()
+}
+
+// process runs a claimed job, records it, publishes its outcome, and removes it from the processing list
+func (q *redisQueue) process(payload string) {
+	inFlightJobs.Add(1)
+	defer inFlightJobs.Done()
+
+	// The job is only dropped from the processing list once it is done, so a crash leaves it for the reaper
+	defer func() {
+		if err := q.client.LRem(context.Background(), redisProcessingKey, 1, payload).Err(); err != nil {
+			slog.Error("Error removing finished job from Redis", "error", err)
+		}
+	}()
+
+	var queued queuedJob
+	if err := json.Unmarshal([]byte(payload), &queued); err != nil {
+		slog.Error("Dropping malformed job from Redis", "error", err)
+		return
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(queued.Trace))
+	logger := slog.Default().With("job_id", queued.JobID)
+	ctx = withLogger(ctx, logger)
+
+	stopLease := q.holdLease(queued.JobID)
+	defer stopLease()
+
+	job, err := jobStore.GetJob(queued.JobID)
+	if err != nil {
+		logger.Error("Error loading claimed job", "error", err)
+		q.publishOutcome(queued.JobID, &jobOutcome{Status: http.StatusInternalServerError, Error: "Failed to load job"})
+		return
+	}
+
+	if q.client.Exists(ctx, redisCancelPrefix+queued.JobID).Val() > 0 {
+		finishJob(ctx, job, nil, errJobCanceled)
+		q.publishOutcome(queued.JobID, &jobOutcome{Status: errJobCanceled.Status, Error: errJobCanceled.Message})
+		return
+	}
+
+	logger.Info("Job claimed", "instance", q.instanceID)
+	result, err := processJob(ctx, job, queued.JobDir, queued.InputPath, queued.Options)
+	if errors.Is(err, errJobInterrupted) {
+		// Another instance picks the job up from its checkpointed chunks
+		if err := q.client.RPush(context.Background(), redisQueueKey(queued.Options.Priority), payload).Err(); err != nil {
+			logger.Error("Error requeuing interrupted job", "error", err)
+		}
+		return
+	}
+
+	outcome := &jobOutcome{}
+	if err != nil {
+		outcome.Status = http.StatusInternalServerError
+		outcome.Error = err.Error()
+		var pipelineErr *pipelineError
+		if errors.As(err, &pipelineErr) {
+			outcome.Status = pipelineErr.Status
+		}
+	} else {
+		outcome.Cached = result.Cached
+	}
+	q.publishOutcome(queued.JobID, outcome)
+}
+
+// publishOutcome hands a job's outcome to the instance waiting on it
+func (q *redisQueue) publishOutcome(jobID string, outcome *jobOutcome) {
+	payload, _ := json.Marshal(outcome)
+	key := redisOutcomePrefix + jobID
+
+	ctx := context.Background()
+	pipe := q.client.TxPipeline()
+	pipe.LPush(ctx, key, payload)
+	pipe.Expire(ctx, key, outcomeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Error publishing job outcome", "job_id", jobID, "error", err)
+	}
+}
+
+// holdLease marks the job as owned by a live worker until the returned function is called
+func (q *redisQueue) holdLease(jobID string) func() {
+	key := redisLeasePrefix + jobID
+	ctx := context.Background()
+	q.client.Set(ctx, key, q.instanceID, leaseTTL)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				q.client.Expire(ctx, key, leaseTTL)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		q.client.Del(ctx, key)
+	}
+}
+
+// reapExpiredLeases puts claimed jobs whose worker died back on the queue. A job is only
+// requeued after its lease has been missing on two scans in a row, which leaves room for
+// a worker that has just claimed it to take the lease
+func (q *redisQueue) reapExpiredLeases(ctx context.Context) {
+	ticker := time.NewTicker(leaseTTL)
+	defer ticker.Stop()
+
+	suspects := map[string]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		payloads, err := q.client.LRange(ctx, redisProcessingKey, 0, -1).Result()
+		if err != nil {
+			slog.Error("Error listing claimed jobs", "error", err)
+			continue
+		}
+
+		next := map[string]bool{}
+		for _, payload := range payloads {
+			var queued queuedJob
+			if err := json.Unmarshal([]byte(payload), &queued); err != nil {
+				continue
+			}
+			if q.client.Exists(ctx, redisLeasePrefix+queued.JobID).Val() > 0 {
+				continue
+			}
+			if !suspects[payload] {
+				next[payload] = true
+				continue
+			}
+
+			// Whichever instance removes the entry requeues it, so it can't be requeued twice
+			if removed, _ := q.client.LRem(ctx, redisProcessingKey, 1, payload).Result(); removed > 0 {
+				slog.Warn("Requeuing job whose worker stopped", "job_id", queued.JobID)
+				q.client.RPush(ctx, redisQueueKey(queued.Options.Priority), payload)
+			}
+		}
+		suspects = next
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// documentContentTypes maps each document format to the Content-Type it is served with. Unlike
+// the plain formats, documents are binary and are laid out from the whole job, not just its text
+var documentContentTypes = map[string]string{
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"pdf":  "application/pdf",
+}
+
+// transcriptDocument is a finished job laid out for delivery as a document: a title, a line of
+// details, the summary if there is one, and the transcript in timestamped paragraphs
+type transcriptDocument struct {
+	Title      string
+	Details    string
+	Summary    string
+	Paragraphs []transcriber.Paragraph
+
+	// Timed is false for transcripts without segments, whose one paragraph has no timestamp
+	Timed bool
+}
+
+// newTranscriptDocument lays out a finished job
+func newTranscriptDocument(job *Job) transcriptDocument {
+	doc := transcriptDocument{
+		Title:   job.Filename,
+		Details: fmt.Sprintf("Transcribed %s · %s of audio", job.CreatedAt.UTC().Format("2 January 2006"), time.Duration(job.DurationSeconds*float64(time.Second)).Round(time.Second)),
+		Summary: job.Summary,
+	}
+	if doc.Title == "" {
+		doc.Title = "Transcript"
+	}
+	if len(job.Segments) > 0 {
+		doc.Paragraphs = transcriber.Paragraphs(job.Segments, appConfig.ParagraphGap)
+		doc.Timed = true
+	} else if text := strings.TrimSpace(job.Transcript); text != "" {
+		doc.Paragraphs = []transcriber.Paragraph{{Text: text}}
+	}
+	return doc
+}
+
+// heading is the line above a paragraph: its timestamp and speaker, either of which may be missing
+func (doc transcriptDocument) heading(paragraph transcriber.Paragraph) string {
+	var parts []string
+	if doc.Timed {
+		parts = append(parts, transcriber.FormatTimestamp(paragraph.Start, ".")[:8])
+	}
+	if paragraph.Speaker != "" {
+		parts = append(parts, paragraph.Speaker)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// documentFilename names the download of a job's transcript in a document format
+func documentFilename(job *Job, format string) string {
+	name := strings.TrimSuffix(filepath.Base(job.Filename), filepath.Ext(job.Filename))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = job.ID
+	}
+	return name + "." + format
+}
+
+// renderDocument renders a finished job in one of the document formats (docx or pdf)
+func renderDocument(format string, job *Job) ([]byte, error) {
+	doc := newTranscriptDocument(job)
+	if format == "pdf" {
+		return renderPDF(doc), nil
+	}
+	return renderDOCX(doc)
+}
+
+// docxContentTypes and docxRels are the fixed parts of a DOCX package
+const (
+	docxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+		`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+		`</Types>`
+
+	docxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+		`</Relationships>`
+)
+
+// renderDOCX writes a document as a Word file: a minimal WordprocessingML package with direct
+// formatting, so it opens the same in Word, LibreOffice, and Google Docs without a style sheet
+func renderDOCX(doc transcriptDocument) ([]byte, error) {
+	var body strings.Builder
+	docxParagraph(&body, 240, doc.Title, `<w:b/><w:sz w:val="36"/>`)
+	docxParagraph(&body, 360, doc.Details, `<w:color w:val="666666"/><w:sz w:val="20"/>`)
+	if doc.Summary != "" {
+		docxParagraph(&body, 120, "Summary", `<w:b/><w:sz w:val="26"/>`)
+		for _, line := range strings.Split(doc.Summary, "\n") {
+			docxParagraph(&body, 120, line, "")
+		}
+		docxParagraph(&body, 120, "Transcript", `<w:b/><w:sz w:val="26"/>`)
+	}
+	for _, paragraph := range doc.Paragraphs {
+		if heading := doc.heading(paragraph); heading != "" {
+			docxParagraph(&body, 0, heading, `<w:b/><w:color w:val="444444"/><w:sz w:val="20"/>`)
+		}
+		docxParagraph(&body, 200, paragraph.Text, "")
+	}
+
+	var core strings.Builder
+	core.WriteString(xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>`)
+	xml.EscapeText(&core, []byte(doc.Title))
+	core.WriteString(`</dc:title></cp:coreProperties>`)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRels},
+		{"docProps/core.xml", core.String()},
+		{"word/document.xml", xml.Header + `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			body.String() +
+			`<w:sectPr><w:pgSz w:w="12240" w:h="15840"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr>` +
+			`</w:body></w:document>`},
+	}
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for _, part := range parts {
+		w, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// docxParagraph writes a paragraph of text with the given run properties, followed by after
+// twentieths of a point of space
+func docxParagraph(w *strings.Builder, after int, text, properties string) {
+	fmt.Fprintf(w, `<w:p><w:pPr><w:spacing w:before="0" w:after="%d"/></w:pPr><w:r>`, after)
+	if properties != "" {
+		w.WriteString(`<w:rPr>` + properties + `</w:rPr>`)
+	}
+	w.WriteString(`<w:t xml:space="preserve">`)
+	xml.EscapeText(w, []byte(text))
+	w.WriteString(`</w:t></w:r></w:p>`)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// allowedDownloadTypes are the Content-Type prefixes accepted from remote URLs
+var allowedDownloadTypes = []string{
+	"audio/",
+	"video/",
+	"application/ogg",
+	"application/octet-stream",
+}
+
+// fetchInput copies the media referenced by rawURL into jobDir, dispatching on the URL scheme
+func fetchInput(ctx context.Context, rawURL, jobDir string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "Invalid URL: " + err.Error()}
+	}
+
+	if store, ok := objectStores[parsed.Scheme]; ok {
+		return downloadObject(ctx, store, parsed, jobDir)
+	}
+	if parsed.Scheme == "file" {
+		return copyLocalFile(ctx, parsed, jobDir)
+	}
+	return downloadURL(ctx, rawURL, jobDir)
+}
+
+// downloadURL fetches a remote http(s) media file into jobDir, enforcing the configured size
+// and time limits, and returns the path it was saved to
+func downloadURL(ctx context.Context, rawURL, jobDir string) (string, error) {
+	return downloadURLWithAuth(ctx, rawURL, jobDir, "")
+}
+
+// downloadURLWithAuth is downloadURL for media that needs credentials, sent as the Authorization
+// header when authorization isn't empty
+func downloadURLWithAuth(ctx context.Context, rawURL, jobDir, authorization string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "URL must be an absolute http or https URL"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appConfig.DownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "Invalid URL: " + err.Error()}
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := downloadClient().Do(req)
+	if err != nil {
+		return "", &pipelineError{Status: http.StatusBadGateway, Message: "Failed to download URL: " + err.Error()}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &pipelineError{Status: http.StatusBadGateway, Message: fmt.Sprintf("Remote server returned status %d", resp.StatusCode)}
+	}
+
+	// Only accept responses that claim to be media
+	contentType := resp.Header.Get("Content-Type")
+	if !isAllowedDownloadType(contentType) {
+		return "", &pipelineError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("Unsupported content type %q: expected audio or video", contentType)}
+	}
+
+	// Check the advertised size up front, and enforce it while copying in case it lied
+	if resp.ContentLength > appConfig.MaxUploadBytes {
+		return "", &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Remote file too large: maximum size is %d bytes", appConfig.MaxUploadBytes)}
+	}
+	if resp.ContentLength > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, resp.ContentLength, appConfig.DiskExpansionFactor); err != nil {
+			return "", &pipelineError{Status: http.StatusInsufficientStorage, Message: err.Error()}
+		}
+	}
+
+	outputPath := filepath.Join(jobDir, "download-"+downloadFilename(parsed))
+	outputFile, err := createJobFile(outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer outputFile.Close()
+
+	written, err := io.Copy(outputFile, io.LimitReader(resp.Body, appConfig.MaxUploadBytes+1))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "", &pipelineError{Status: http.StatusGatewayTimeout, Message: "Timed out downloading URL"}
+		}
+		return "", &pipelineError{Status: http.StatusBadGateway, Message: "Failed to download URL: " + err.Error()}
+	}
+	if written > appConfig.MaxUploadBytes {
+		return "", &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Remote file too large: maximum size is %d bytes", appConfig.MaxUploadBytes)}
+	}
+	if err := outputFile.Close(); err != nil {
+		return "", err
+	}
+
+	return outputPath, nil
+}
+
+// basicAuthorization returns the Authorization header for HTTP basic authentication
+func basicAuthorization(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// isAllowedDownloadType reports whether a Content-Type header looks like audio or video
+func isAllowedDownloadType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range allowedDownloadTypes {
+		if strings.HasPrefix(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadFilename derives a safe local filename from the last path segment of a URL
+func downloadFilename(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "" || name == "." || name == "/" {
+		return "media"
+	}
+	return filepath.Base(name)
+}
+
+// downloadClient returns an HTTP client that refuses to connect to private addresses
+// unless TRANSCRIBER_ALLOW_PRIVATE_URLS is set, so the endpoint can't be used to probe
+// the internal network. Requests go through the proxy in HTTP_PROXY or HTTPS_PROXY when one
+// is set: the proxy may be on a private address, but the hosts requested through it may not
+func downloadClient() *http.Client {
+	if appConfig.AllowPrivateURLs {
+		return &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+	}
+
+	dialer := &net.Dialer{
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("refusing to connect to private address %s", host)
+			}
+			return nil
+		},
+	}
+	proxyAddrs := proxyAddresses()
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: func(req *http.Request) (*url.URL, error) {
+				proxyURL, err := http.ProxyFromEnvironment(req)
+				if err != nil || proxyURL == nil {
+					return proxyURL, err
+				}
+				// The proxy connects to the host itself, so check the host before asking it to
+				if err := checkPublicHost(req.Context(), req.URL.Hostname()); err != nil {
+					return nil, err
+				}
+				return proxyURL, nil
+			},
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				if proxyAddrs[address] {
+					return (&net.Dialer{}).DialContext(ctx, network, address)
+				}
+				return dialer.DialContext(ctx, network, address)
+			},
+		},
+	}
+}
+
+// proxyAddresses returns the host:port of the proxies HTTP_PROXY and HTTPS_PROXY name, if any
+func proxyAddresses() map[string]bool {
+	addresses := map[string]bool{}
+	for _, scheme := range []string{"http", "https"} {
+		proxyURL, err := http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: scheme, Host: "example.com"}})
+		if err != nil || proxyURL == nil {
+			continue
+		}
+		port := proxyURL.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[proxyURL.Scheme]
+		}
+		addresses[net.JoinHostPort(proxyURL.Hostname(), port)] = true
+	}
+	return addresses
+}
+
+// checkPublicHost resolves host and refuses it when any of its addresses is private
+func checkPublicHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if isPrivateIP(ip) {
+			return fmt.Errorf("refusing to connect to private address %s", host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return fmt.Errorf("refusing to connect to private address %s", addr.IP)
+		}
+	}
+	return nil
+}
+
+// isPrivateIP reports whether ip is a loopback, private, link-local, or unspecified address
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()
+}
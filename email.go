@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// emailEnabled reports whether a mail server and sender are configured for notify_email
+func emailEnabled() bool {
+	return appConfig.SMTPHost != "" && appConfig.SMTPFrom != ""
+}
+
+// parseNotifyEmail validates a requested notification address, reporting problems as a 400
+func parseNotifyEmail(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if !emailEnabled() {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "notify_email is not available: this server has no mail server configured"}
+	}
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "Invalid notify_email: " + err.Error()}
+	}
+	return address.Address, nil
+}
+
+// notifyByEmail emails a finished job to the address its request asked for: the transcript with
+// an SRT attachment when it completed, or the error when it failed. The message is sent in the
+// background, and failures to send are only logged
+func notifyByEmail(ctx context.Context, job *Job, opts JobOptions) {
+	if opts.NotifyEmail == "" {
+		return
+	}
+	logger := loggerFrom(ctx)
+	message, err := jobEmail(filterJob(job, opts.ProfanityFilter), opts.NotifyEmail)
+	if err != nil {
+		logger.Error("Error writing notification email", "error", err)
+		return
+	}
+
+	go func() {
+		start := time.Now()
+		if err := sendEmail(opts.NotifyEmail, message); err != nil {
+			logger.Error("Error sending notification email", "duration", time.Since(start), "error", err)
+			return
+		}
+		logger.Info("Notification email sent", "duration", time.Since(start))
+	}()
+}
+
+// jobEmail writes the notification message for a finished job
+func jobEmail(job *Job, to string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	subject := "Transcript ready: " + job.Filename
+	var text strings.Builder
+	fmt.Fprintf(&text, "File: %s\nJob: %s\n", job.Filename, job.ID)
+	if job.Status == JobStatusCompleted {
+		fmt.Fprintf(&text, "Duration: %s\n", time.Duration(job.DurationSeconds*float64(time.Second)).Round(time.Second))
+		if job.Summary != "" {
+			fmt.Fprintf(&text, "\nSummary:\n%s\n", job.Summary)
+		}
+		fmt.Fprintf(&text, "\nTranscript:\n%s\n", readableText(job.Transcript, job.Segments))
+	} else {
+		subject = "Transcription failed: " + job.Filename
+		fmt.Fprintf(&text, "\nThe transcription failed: %s\n", job.Error)
+	}
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoder := quotedprintable.NewWriter(part)
+	if _, err := encoder.Write([]byte(text.String())); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	if job.Status == JobStatusCompleted && len(job.Segments) > 0 {
+		name := strings.TrimSuffix(filepath.Base(job.Filename), filepath.Ext(job.Filename)) + ".srt"
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType("application/x-subrip", map[string]string{"name": name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(part, []byte(transcriber.RenderSRT(captionedSegments("srt", job)))); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", appConfig.SMTPFrom)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// writeBase64Lines writes data base64-encoded in 76-character lines, as MIME requires
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// sendEmail delivers a message through the configured mail server
+func sendEmail(to string, message []byte) error {
+	from, err := mail.ParseAddress(appConfig.SMTPFrom)
+	if err != nil {
+		return fmt.Errorf("invalid TRANSCRIBER_SMTP_FROM: %w", err)
+	}
+	var auth smtp.Auth
+	if appConfig.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", appConfig.SMTPUsername, appConfig.SMTPPassword, appConfig.SMTPHost)
+	}
+	return smtp.SendMail(net.JoinHostPort(appConfig.SMTPHost, appConfig.SMTPPort), auth, from.Address, []string{to}, message)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// jobEncryption holds the key an encrypted job's files are sealed with, which only ever lives in
+// memory, and which of its files are sealed already
+type jobEncryption struct {
+	cipher *transcriber.FileCipher
+
+	mu     sync.Mutex
+	sealed map[string]bool
+}
+
+// jobEncryptions holds the encryption of each job directory with TRANSCRIBER_ENCRYPT_TEMP_FILES
+// on, until removeJobDir forgets it along with the key
+var jobEncryptions sync.Map
+
+// encryptJobDir gives a job directory its own key, so the files written to it are encrypted
+func encryptJobDir(jobDir string) error {
+	cipher, err := transcriber.NewFileCipher()
+	if err != nil {
+		return err
+	}
+	jobEncryptions.Store(jobDir, &jobEncryption{cipher: cipher, sealed: map[string]bool{}})
+	return nil
+}
+
+// jobEncryptionFor returns the encryption of the job directory a file is in, or nil when it is in
+// none that is encrypted
+func jobEncryptionFor(path string) *jobEncryption {
+	value, ok := jobEncryptions.Load(filepath.Dir(path))
+	if !ok {
+		return nil
+	}
+	return value.(*jobEncryption)
+}
+
+// createJobFile creates a file, encrypting what is written to it when it is in an encrypted job's
+// directory, so media never reaches the disk in plaintext
+func createJobFile(path string) (io.WriteCloser, error) {
+	encryption := jobEncryptionFor(path)
+	if encryption == nil {
+		return os.Create(path)
+	}
+	file, err := encryption.cipher.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	encryption.mu.Lock()
+	encryption.sealed[path] = true
+	encryption.mu.Unlock()
+	return file, nil
+}
+
+// openJobFile opens a file for reading, decrypting it when it was written encrypted
+func openJobFile(path string) (io.ReadCloser, error) {
+	encryption := jobEncryptionFor(path)
+	if encryption != nil {
+		encryption.mu.Lock()
+		sealed := encryption.sealed[path]
+		encryption.mu.Unlock()
+		if sealed {
+			return encryption.cipher.Open(path)
+		}
+	}
+	return os.Open(path)
+}
+
+// sealJobInput encrypts a job's input in place when the job is encrypted and the input arrived
+// some way that couldn't encrypt it as it was written, such as a tus upload or a batch file. It
+// returns the cipher the job's files are encrypted with, or nil for a job that isn't
+func sealJobInput(jobDir, inputPath string) (*transcriber.FileCipher, error) {
+	value, ok := jobEncryptions.Load(jobDir)
+	if !ok {
+		return nil, nil
+	}
+	encryption := value.(*jobEncryption)
+	encryption.mu.Lock()
+	defer encryption.mu.Unlock()
+	if !encryption.sealed[inputPath] {
+		if err := encryption.cipher.EncryptFile(inputPath); err != nil {
+			return nil, err
+		}
+		encryption.sealed[inputPath] = true
+	}
+	return encryption.cipher, nil
+}
+
+// writeJobFile writes data to a file the way createJobFile creates it
+func writeJobFile(path string, data []byte) error {
+	file, err := createJobFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// EstimateResponse predicts what transcribing a file would take and cost
+type EstimateResponse struct {
+	UsageMetadata
+	EstimatedProcessingSeconds float64         `json:"estimated_processing_seconds"`
+	Source                     *SourceMetadata `json:"source,omitempty"`
+}
+
+// estimateTranscription probes an upload (multipart, like /api/transcribe) or a URL (JSON, like
+// /api/transcribe/url) and predicts its duration, chunks, processing time, and cost without
+// transcribing it. Nothing is recorded in the job history
+func estimateTranscription(c *gin.Context) {
+	// A URL request has to be well-formed before anything is downloaded
+	var request URLTranscriptionRequest
+	var selection modelSelection
+	tenant := tenantFrom(c.Request.Context())
+	isURL := c.ContentType() == "application/json"
+	if isURL {
+		if err := decodeWithProfile(c.Request.Body, &request); err != nil || request.URL == "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a url field"})
+			return
+		}
+		if request.Profile != "" {
+			if _, err := findProfile(request.Profile); err != nil {
+				respondWithError(c, err)
+				return
+			}
+		}
+		if request.Ingest != "" && request.Ingest != "direct" && request.Ingest != "yt-dlp" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown ingest mode %q: expected direct or yt-dlp", request.Ingest)})
+			return
+		}
+		var temperature float64
+		if request.Temperature != nil {
+			temperature = *request.Temperature
+		}
+		var err error
+		if selection, err = parseModelSelection(tenant, request.Provider, request.Model, temperature); err != nil {
+			respondWithError(c, err)
+			return
+		}
+	}
+
+	// Probing and downloading take a place in the queue like any job
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer release()
+
+	jobDir, err := createJobDir(appConfig.WorkDir, uuid.New().String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create job directory"})
+		return
+	}
+	defer removeJobDir(jobDir)
+
+	var inputPath string
+	var source *SourceMetadata
+	opts := transcriber.TranscribeOptions{}
+	if isURL {
+		opts.AudioTrack = request.AudioTrack
+		opts.AudioLanguage = request.AudioLanguage
+		opts.SplitChannels = request.SplitChannels
+		opts.Start, opts.End = request.Start, request.End
+		inputPath, source, err = fetchRequestedURL(c.Request.Context(), request, jobDir)
+	} else {
+		var fields map[string]string
+		inputPath, fields, err = receiveEstimateUpload(c, jobDir)
+		if err == nil {
+			fields, err = profileFields(fields)
+		}
+		opts.AudioTrack = fields["audio_track"]
+		opts.AudioLanguage = fields["audio_language"]
+		opts.SplitChannels = fields["split_channels"] == "true"
+		if err == nil {
+			opts.Start, opts.End, err = parseTimeRange(fields["start"], fields["end"])
+		}
+		if err == nil {
+			selection, err = parseModelFields(tenant, fields)
+		}
+	}
+	if err == nil {
+		err = checkMediaContainer(inputPath)
+	}
+	if err == nil {
+		err = scanMedia(c.Request.Context(), inputPath)
+	}
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	estimate, err := transcriberFor(tenant, selection.Provider).Estimate(c.Request.Context(), inputPath, opts)
+	if err != nil {
+		respondWithError(c, pipelineErrorFor(err))
+		return
+	}
+
+	model := selection.Model
+	c.JSON(http.StatusOK, EstimateResponse{
+		UsageMetadata: UsageMetadata{
+			DurationSeconds:  estimate.DurationSeconds,
+			Chunks:           estimate.Chunks,
+			Provider:         selection.Provider,
+			Model:            model,
+			EstimatedCostUSD: costFor(model, estimate.AudioSeconds),
+		},
+		EstimatedProcessingSeconds: estimate.ProcessingSeconds,
+		Source:                     source,
+	})
+}
+
+// receiveEstimateUpload saves the file part of a multipart upload into jobDir and returns its
+// path along with the other form fields
+func receiveEstimateUpload(c *gin.Context, jobDir string) (string, map[string]string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.MaxUploadBytes)
+	if c.Request.ContentLength > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, c.Request.ContentLength, 1); err != nil {
+			return "", nil, &pipelineError{Status: http.StatusInsufficientStorage, Message: err.Error()}
+		}
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return "", nil, &pipelineError{Status: http.StatusBadRequest, Message: "Request must be multipart/form-data or JSON with a url field"}
+	}
+
+	var inputPath string
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, uploadError(err)
+		}
+
+		if part.FormName() != "file" || inputPath != "" {
+			value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldBytes))
+			fields[part.FormName()] = string(value)
+			part.Close()
+			continue
+		}
+
+		if err := checkUploadExtension(part.FileName()); err != nil {
+			part.Close()
+			return "", nil, err
+		}
+		inputPath = filepath.Join(jobDir, "upload-"+filepath.Base(part.FileName()))
+		err = saveUploadPart(part, inputPath)
+		part.Close()
+		if err != nil {
+			return "", nil, uploadError(err)
+		}
+	}
+
+	if inputPath == "" {
+		return "", nil, &pipelineError{Status: http.StatusBadRequest, Message: "No file provided"}
+	}
+	return inputPath, fields, nil
+}
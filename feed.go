@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxFeedBytes caps how much of an RSS feed is read
+const maxFeedBytes = 10 << 20
+
+// FeedRequest is the JSON body accepted by the feed endpoint. url is the RSS feed, and the
+// options of a URL request apply to every episode
+type FeedRequest struct {
+	// OnlyNew skips episodes that already have a job which didn't fail
+	OnlyNew bool `json:"only_new"`
+
+	// Limit is the most episodes to transcribe, taken from the top of the feed; defaults to
+	// TRANSCRIBER_MAX_BATCH_SIZE, which is also the most it may be
+	Limit int `json:"limit"`
+
+	URLTranscriptionRequest
+}
+
+// FeedResponse reports the episodes of a feed that are being transcribed
+type FeedResponse struct {
+	BatchID  string        `json:"batch_id,omitempty"`
+	Title    string        `json:"title"`
+	Episodes []FeedEpisode `json:"episodes"`
+	Skipped  int           `json:"skipped"`
+}
+
+// FeedEpisode is an episode of a feed and the job transcribing it
+type FeedEpisode struct {
+	GUID  string `json:"guid"`
+	Title string `json:"title"`
+	JobID string `json:"job_id"`
+}
+
+// rssFeed is the part of an RSS 2.0 podcast feed the endpoint reads
+type rssFeed struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// rssItem is an episode of an RSS feed
+type rssItem struct {
+	Title     string `xml:"title"`
+	GUID      string `xml:"guid"`
+	Enclosure struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+}
+
+// guid identifies an episode by its GUID, or by its enclosure URL for feeds that leave GUIDs out
+func (item rssItem) guid() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	return strings.TrimSpace(item.Enclosure.URL)
+}
+
+// transcribeFeed reads a podcast RSS feed and starts a background job, in a batch, for each
+// episode's audio. Jobs record the feed URL and episode GUID, so a feed can be polled with
+// only_new to pick up episodes published since the last run
+func transcribeFeed(c *gin.Context) {
+	var request FeedRequest
+	if err := decodeWithProfile(c.Request.Body, &request); err != nil || request.URL == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a url field"})
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = int(appConfig.MaxBatchSize)
+	}
+	if request.Limit < 1 || request.Limit > int(appConfig.MaxBatchSize) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", appConfig.MaxBatchSize)})
+		return
+	}
+	opts, err := urlJobOptions(tenantFrom(c.Request.Context()), request.URLTranscriptionRequest)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	feed, err := fetchFeed(c.Request.Context(), request.URL)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	var done map[string]bool
+	if request.OnlyNew {
+		if done, err = jobStore.FeedEpisodeGUIDs(tenantIDFrom(c.Request.Context()), request.URL); err != nil {
+			loggerFrom(c.Request.Context()).Error("Error loading feed episodes", "feed_url", request.URL, "error", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load earlier episodes"})
+			return
+		}
+	}
+
+	// Feeds list the newest episodes first, so the limit keeps the most recent ones
+	response := FeedResponse{Title: strings.TrimSpace(feed.Channel.Title), Episodes: []FeedEpisode{}}
+	var episodes []rssItem
+	for _, item := range feed.Channel.Items {
+		if item.Enclosure.URL == "" {
+			continue
+		}
+		if done[item.guid()] {
+			response.Skipped++
+			continue
+		}
+		if len(episodes) < request.Limit {
+			episodes = append(episodes, item)
+		}
+	}
+	if len(episodes) == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	releases, err := admitJobs(c.Request.Context(), len(episodes))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// The jobs outlive the request, but their spans and logs still belong to it
+	response.BatchID = uuid.New().String()
+	ctx := withLogger(context.WithoutCancel(c.Request.Context()), loggerFrom(c.Request.Context()).With("batch_id", response.BatchID))
+	for i, item := range episodes {
+		title := strings.TrimSpace(item.Title)
+		filename := title
+		if filename == "" {
+			filename = item.Enclosure.URL
+		}
+		job, jobDir, err := createJob(ctx, &Job{
+			ID:          uuid.New().String(),
+			Filename:    filename,
+			BatchID:     response.BatchID,
+			FeedURL:     request.URL,
+			EpisodeGUID: item.guid(),
+		})
+		if err != nil {
+			releaseJobs(releases[i:])
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start job"})
+			return
+		}
+		response.Episodes = append(response.Episodes, FeedEpisode{GUID: job.EpisodeGUID, Title: title, JobID: job.ID})
+
+		// Enclosures are plain media files, so they are always downloaded directly
+		urlRequest := request.URLTranscriptionRequest
+		urlRequest.URL = item.Enclosure.URL
+		urlRequest.Ingest = ""
+		go runURLJob(withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID)), job, jobDir, urlRequest, opts, releases[i])
+	}
+
+	c.Header("Location", "/api/batches/"+response.BatchID)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// fetchFeed downloads and parses an RSS feed, with the same address, time, and size limits as
+// media downloads
+func fetchFeed(ctx context.Context, rawURL string) (*rssFeed, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "URL must be an absolute http or https URL"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appConfig.DownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "Invalid URL: " + err.Error()}
+	}
+	resp, err := downloadClient().Do(req)
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusBadGateway, Message: "Failed to download feed: " + err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &pipelineError{Status: http.StatusBadGateway, Message: fmt.Sprintf("Remote server returned status %d", resp.StatusCode)}
+	}
+
+	// A feed cut off at the limit fails to parse
+	var feed rssFeed
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxFeedBytes)).Decode(&feed); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &pipelineError{Status: http.StatusGatewayTimeout, Message: "Timed out downloading feed"}
+		}
+		return nil, &pipelineError{Status: http.StatusUnprocessableEntity, Message: "Invalid RSS feed: " + err.Error()}
+	}
+	return &feed, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// formatContentTypes maps each transcript format to the Content-Type it is served with
+var formatContentTypes = map[string]string{
+	"text":     "text/plain; charset=utf-8",
+	"readable": "text/plain; charset=utf-8",
+	"json":     "application/json; charset=utf-8",
+	"srt":      "application/x-subrip; charset=utf-8",
+	"vtt":      "text/vtt; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"lrc":      "text/plain; charset=utf-8",
+	"words":    "application/json; charset=utf-8",
+	"chapters": "text/plain; charset=utf-8",
+}
+
+// wordFormat reports whether a format is built from word timestamps, which a job only has when
+// it was submitted with word_timestamps
+func wordFormat(format string) bool {
+	return format == "lrc" || format == "words"
+}
+
+// renderTranscript renders a transcript in one of the plain formats (text, readable, srt, vtt,
+// markdown, lrc, words, or chapters)
+func renderTranscript(format, text string, segments []transcriber.Segment, words []transcriber.Word) string {
+	switch format {
+	case "readable":
+		return readableText(text, segments)
+	case "srt":
+		return transcriber.RenderSRT(segments)
+	case "vtt":
+		return transcriber.RenderVTT(segments)
+	case "markdown":
+		return markdownText(text, segments)
+	case "lrc":
+		return transcriber.RenderLRC(segments, words)
+	case "chapters":
+		return transcriber.RenderYouTubeChapters(transcriber.DetectChapters(segments, appConfig.ChapterMinLength))
+	case "words":
+		if words == nil {
+			words = []transcriber.Word{}
+		}
+		encoded, _ := json.Marshal(words)
+		return string(encoded)
+	default:
+		return text
+	}
+}
+
+// captionedSegments returns a job's segments for rendering in format, with the sounds tagged by
+// audio_events as cues among them in the caption formats
+func captionedSegments(format string, job *Job) []transcriber.Segment {
+	if format == "srt" || format == "vtt" {
+		return transcriber.CaptionSegments(job.Segments, job.Events)
+	}
+	return job.Segments
+}
+
+// readableText breaks a transcript into paragraphs at pauses of at least the configured gap.
+// Transcripts without segments are returned as they are
+func readableText(text string, segments []transcriber.Segment) string {
+	if len(segments) == 0 {
+		return text
+	}
+	return transcriber.RenderParagraphs(segments, appConfig.ParagraphGap)
+}
+
+// markdownText renders a transcript as Markdown meeting notes, with paragraphs split at the
+// configured gap. Transcripts without segments have no timings or speakers, so they are returned
+// as they are
+func markdownText(text string, segments []transcriber.Segment) string {
+	if len(segments) == 0 {
+		return text
+	}
+	return transcriber.RenderMarkdown(segments, appConfig.ParagraphGap)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// gcsStore serves gs://bucket/object URIs using Application Default Credentials, or the
+// service account key in TRANSCRIBER_GCS_CREDENTIALS_FILE when set
+type gcsStore struct{}
+
+func (gcsStore) Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	client, err := newGCSClient(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to configure GCS client: %w", err)
+	}
+
+	reader, err := client.Bucket(bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		client.Close()
+		if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+			return nil, 0, fmt.Errorf("%w: %v", errObjectNotFound, err)
+		}
+		return nil, 0, err
+	}
+
+	return &gcsReader{Reader: reader, client: client}, reader.Attrs.Size, nil
+}
+
+func (gcsStore) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	client, err := newGCSClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure GCS client: %w", err)
+	}
+	defer client.Close()
+
+	var objects []ObjectInfo
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, ObjectInfo{Key: attrs.Name, Size: attrs.Size, ModTime: attrs.Updated})
+	}
+}
+
+// newGCSClient builds a storage client from the server configuration
+func newGCSClient(ctx context.Context) (*storage.Client, error) {
+	var clientOptions []option.ClientOption
+	if appConfig.GCSCredentialsFile != "" {
+		clientOptions = append(clientOptions, option.WithAuthCredentialsFile(option.ServiceAccount, appConfig.GCSCredentialsFile))
+	}
+	return storage.NewClient(ctx, clientOptions...)
+}
+
+// gcsReader closes the storage client along with the object reader
+type gcsReader struct {
+	*storage.Reader
+	client *storage.Client
+}
+
+func (r *gcsReader) Close() error {
+	err := r.Reader.Close()
+	r.client.Close()
+	return err
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"audio-transcriber/pkg/transcriber"
+	transcriberv1 "audio-transcriber/proto/transcriber/v1"
+)
+
+// grpcServer implements the TranscriberService on top of the same pipeline as the REST API
+type grpcServer struct {
+	transcriberv1.UnimplementedTranscriberServiceServer
+}
+
+// startGRPCServer serves the TranscriberService on addr in the background
+func startGRPCServer(addr string) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave headroom over the upload limit for the rest of the request message
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(appConfig.MaxUploadBytes)+1<<20),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(grpcTenantUnary),
+		grpc.StreamInterceptor(grpcTenantStream),
+	)
+	transcriberv1.RegisterTranscriberServiceServer(server, grpcServer{})
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// grpcTenant identifies the tenant of a call by the API key in its authorization ("Bearer ...")
+// or x-api-key metadata, when tenants are configured, as tenantAuth does for REST
+func grpcTenant(ctx context.Context, method string) (context.Context, error) {
+	if len(tenantsByID) == 0 {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	tenant := tenantForKey(requestAPIKey(first("authorization"), first("x-api-key")))
+	if tenant == nil {
+		recordAudit(ctx, AuditEvent{Action: AuditAuthFailed, Detail: method})
+		return nil, status.Error(codes.Unauthenticated, "a valid API key is required")
+	}
+	ctx = withTenant(ctx, tenant)
+	return withLogger(ctx, loggerFrom(ctx).With("tenant", tenant.ID)), nil
+}
+
+// grpcTenantUnary and grpcTenantStream run grpcTenant ahead of every call
+func grpcTenantUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := grpcTenant(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcTenantStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := grpcTenant(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, tenantServerStream{ServerStream: stream, ctx: ctx})
+}
+
+// tenantServerStream is a server stream whose context carries the caller's tenant
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s tenantServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (grpcServer) Transcribe(ctx context.Context, req *transcriberv1.TranscribeRequest) (*transcriberv1.TranscribeResponse, error) {
+	return runGRPCJob(ctx, req, nil)
+}
+
+func (grpcServer) TranscribeStream(req *transcriberv1.TranscribeRequest, stream transcriberv1.TranscriberService_TranscribeStreamServer) error {
+	var sendErr error
+	onSegments := func(segments []transcriber.Segment) {
+		for _, segment := range segments {
+			if sendErr != nil {
+				return
+			}
+			sendErr = stream.Send(&transcriberv1.TranscribeStreamResponse{
+				Event: &transcriberv1.TranscribeStreamResponse_Segment{Segment: toProtoSegment(segment)},
+			})
+		}
+	}
+
+	result, err := runGRPCJob(stream.Context(), req, onSegments)
+	if err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+
+	return stream.Send(&transcriberv1.TranscribeStreamResponse{
+		Event: &transcriberv1.TranscribeStreamResponse_Result{Result: result},
+	})
+}
+
+// runGRPCJob records a job, stages the request's media in its directory, and runs the pipeline
+func runGRPCJob(ctx context.Context, req *transcriberv1.TranscribeRequest, onSegments func([]transcriber.Segment)) (*transcriberv1.TranscribeResponse, error) {
+	filename := req.GetFilename()
+	switch {
+	case len(req.GetAudio()) > 0:
+		if int64(len(req.GetAudio())) > appConfig.MaxUploadBytes {
+			return nil, status.Errorf(codes.ResourceExhausted, "audio too large: maximum size is %d bytes", appConfig.MaxUploadBytes)
+		}
+		if filename == "" {
+			filename = "audio"
+		}
+		if err := checkUploadExtension(filename); err != nil {
+			return nil, grpcError(err)
+		}
+	case req.GetUrl() != "":
+		if filename == "" {
+			filename = req.GetUrl()
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "either audio or url must be set")
+	}
+
+	priority, err := parsePriority(req.GetPriority())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	audioFilters, err := parseAudioFilters(req.GetAudioFilters())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	channelLabels, err := parseChannelLabels(req.GetChannelLabels())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	profanityMode, err := parseProfanityFilter(req.GetProfanityFilter())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	prompt, err := parsePrompt(req.GetPrompt())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !validMinConfidence(req.GetMinConfidence()) {
+		return nil, status.Error(codes.InvalidArgument, "min_confidence must be a number between 0 and 1")
+	}
+	selection, err := parseModelSelection(tenantFrom(ctx), req.GetProvider(), req.GetModel(), req.GetTemperature())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	release, err := admitJob(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	defer release()
+
+	job, jobDir, err := startJob(ctx, uuid.New().String(), filename)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to start job")
+	}
+	ctx = withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID))
+	defer removeJobDir(jobDir)
+
+	// Stage the media in the job directory
+	var inputPath string
+	if len(req.GetAudio()) > 0 {
+		inputPath = filepath.Join(jobDir, "upload-"+filepath.Base(filename))
+		err = writeJobFile(inputPath, req.GetAudio())
+	} else {
+		inputPath, err = fetchInput(ctx, req.GetUrl(), jobDir)
+	}
+	if err != nil {
+		finishJob(ctx, job, nil, err)
+		return nil, grpcError(err)
+	}
+
+	opts := JobOptions{
+		AudioTrack:      req.GetAudioTrack(),
+		AudioLanguage:   req.GetAudioLanguage(),
+		UseCache:        !req.GetDisableCache(),
+		Normalize:       req.GetNormalize(),
+		Denoise:         req.GetDenoise(),
+		AudioFilters:    audioFilters,
+		Provider:        selection.Provider,
+		Model:           selection.Model,
+		Temperature:     selection.Temperature,
+		Prompt:          prompt,
+		SplitChannels:   req.GetSplitChannels(),
+		Summarize:       req.GetSummarize(),
+		Keywords:        req.GetKeywords(),
+		Redact:          req.GetRedact(),
+		ProfanityFilter: profanityMode,
+		MinConfidence:   req.GetMinConfidence(),
+		ChannelLabels:   channelLabels,
+		Priority:        priority,
+		OnSegments:      onSegments,
+	}
+	result, err := executeJob(ctx, job, jobDir, inputPath, opts)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	result = filterResult(result, profanityMode)
+	job = filterJob(job, profanityMode)
+	result, lowConfidence := flagResult(result, req.GetMinConfidence())
+
+	response := &transcriberv1.TranscribeResponse{
+		JobId:                 job.ID,
+		Transcription:         result.Transcription,
+		ReadableText:          readableText(result.Transcription, transcriber.MarkLowConfidence(result.Segments)),
+		Redacted:              job.Redacted,
+		Summary:               job.Summary,
+		SummaryError:          job.SummaryError,
+		DurationSeconds:       result.DurationSeconds,
+		Cached:                result.Cached,
+		Usage:                 toProtoUsage(jobUsage(job)),
+		LowConfidenceSegments: int32(lowConfidence),
+	}
+	for _, segment := range result.Segments {
+		response.Segments = append(response.Segments, toProtoSegment(segment))
+	}
+	for _, keyword := range job.Keywords {
+		response.Keywords = append(response.Keywords, &transcriberv1.Keyword{
+			Phrase:     keyword.Phrase,
+			Score:      keyword.Score,
+			Count:      int32(keyword.Count),
+			Timestamps: keyword.Timestamps,
+		})
+	}
+	return response, nil
+}
+
+// toProtoSegment converts a pipeline segment to its protobuf form
+func toProtoSegment(segment transcriber.Segment) *transcriberv1.Segment {
+	return &transcriberv1.Segment{
+		Id:            int32(segment.ID),
+		Start:         segment.Start,
+		End:           segment.End,
+		Text:          segment.Text,
+		Speaker:       segment.Speaker,
+		Confidence:    segment.Confidence,
+		LowConfidence: segment.LowConfidence,
+	}
+}
+
+// toProtoUsage converts usage metadata to its protobuf form
+func toProtoUsage(usage *UsageMetadata) *transcriberv1.Usage {
+	return &transcriberv1.Usage{
+		DurationSeconds:  usage.DurationSeconds,
+		Chunks:           int32(usage.Chunks),
+		Provider:         usage.Provider,
+		Model:            usage.Model,
+		EstimatedCostUsd: usage.EstimatedCostUSD,
+	}
+}
+
+// grpcError maps a pipelineError's HTTP status onto the closest gRPC status code
+func grpcError(err error) error {
+	var pipelineErr *pipelineError
+	if !errors.As(err, &pipelineErr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	code := codes.Internal
+	switch pipelineErr.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusRequestEntityTooLarge, http.StatusInsufficientStorage, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, pipelineErr.Message)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Hallucination modes: suspect segments are marked with why, or taken out of the transcript
+const (
+	hallucinationsFlag   = "flag"
+	hallucinationsRemove = "remove"
+)
+
+// parseHallucinations validates the hallucinations option of a request: flag, remove, or "" to
+// leave suspect segments alone
+func parseHallucinations(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "", hallucinationsFlag, hallucinationsRemove:
+		return mode, nil
+	default:
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Unknown hallucinations %q: expected flag or remove", value),
+		}
+	}
+}
+
+// filterHallucinations finds the segments of a finished transcript the model likely made up, and
+// marks them or, in remove mode, takes them and their words out and rebuilds the text without
+// them. It returns how many it found
+func filterHallucinations(ctx context.Context, result *transcriber.Result, mode string) int {
+	segments, count := transcriber.DetectHallucinations(result.Segments)
+	if count == 0 {
+		return 0
+	}
+	loggerFrom(ctx).Info("Found likely hallucinations", "segments", count, "mode", mode)
+	if mode == hallucinationsFlag {
+		result.Segments = segments
+		return count
+	}
+	result.Segments, result.Words = transcriber.RemoveHallucinations(segments, result.Words)
+	result.Transcription = transcriber.JoinSegments(result.Segments)
+	return count
+}
@@ -0,0 +1,869 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// URLTranscriptionRequest is the JSON body accepted by the remote URL endpoint
+type URLTranscriptionRequest struct {
+	URL               string   `json:"url" binding:"required"`
+	Ingest            string   `json:"ingest"`
+	Profile           string   `json:"profile"`
+	AudioTrack        string   `json:"audio_track"`
+	AudioLanguage     string   `json:"audio_language"`
+	Cache             *bool    `json:"cache"`
+	Normalize         bool     `json:"normalize"`
+	Denoise           bool     `json:"denoise"`
+	AudioFilters      string   `json:"audio_filters"`
+	SkipNonSpeech     bool     `json:"skip_non_speech"`
+	RemoveSilence     bool     `json:"remove_silence"`
+	Speed             float64  `json:"speed"`
+	Start             float64  `json:"start"`
+	End               float64  `json:"end"`
+	Provider          string   `json:"provider"`
+	Model             string   `json:"model"`
+	Temperature       *float64 `json:"temperature"`
+	Prompt            string   `json:"prompt"`
+	Language          string   `json:"language"`
+	CodeSwitching     bool     `json:"code_switching"`
+	SplitChannels     bool     `json:"split_channels"`
+	WordTimestamps    bool     `json:"word_timestamps"`
+	Summarize         bool     `json:"summarize"`
+	Sentiment         bool     `json:"sentiment"`
+	TranslateTo       string   `json:"translate_to"`
+	Keywords          bool     `json:"keywords"`
+	Chapters          bool     `json:"chapters"`
+	Punctuation       string   `json:"punctuation"`
+	Hallucinations    string   `json:"hallucinations"`
+	Redact            bool     `json:"redact"`
+	ProfanityFilter   string   `json:"profanity_filter"`
+	MinConfidence     float64  `json:"min_confidence"`
+	ChannelLabels     []string `json:"channel_labels"`
+	IdentifySpeakers  bool     `json:"identify_speakers"`
+	AudioEvents       bool     `json:"audio_events"`
+	Priority          string   `json:"priority"`
+	NotifyEmail       string   `json:"notify_email"`
+	SlackWebhookURL   string   `json:"slack_webhook_url"`
+	DiscordWebhookURL string   `json:"discord_webhook_url"`
+	RetainAudio       bool     `json:"retain_audio"`
+	Subtitles         string   `json:"subtitles"`
+	StoreResults      bool     `json:"store_results"`
+}
+
+func transcribeAudio(c *gin.Context) {
+	// A retry of a submission gets the job the original created rather than a second one
+	idempotencyKey, err := parseIdempotencyKey(c.GetHeader(idempotencyKeyHeader))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if _, err := parseContentSHA256(contentSHA256Header, c.GetHeader(contentSHA256Header)); err != nil {
+		respondWithError(c, err)
+		return
+	}
+	prior, err := findIdempotentJob(c.Request.Context(), idempotencyKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to look up Idempotency-Key"})
+		return
+	}
+	if prior != nil {
+		replayUpload(c, prior)
+		return
+	}
+
+	// Whatever the request takes hold of is given back here, however it ends. Deferred calls
+	// also run when a handler panics, before recoverPanics answers it
+	var release func()
+	var job *Job
+	var jobDir, tempRawAudioFile, archivePath string
+	defer func() {
+		if jobDir != "" {
+			removeJobDir(jobDir)
+		}
+		if archivePath != "" {
+			os.RemoveAll(filepath.Dir(archivePath))
+		}
+		if release != nil {
+			release()
+		}
+	}()
+
+	// Turn work away up front rather than accept an upload we can't process soon
+	release, err = admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// Cap the request body so oversized uploads never reach the disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.MaxUploadBytes)
+
+	// Make sure there is room for the upload and everything ffmpeg derives from it
+	if c.Request.ContentLength > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, c.Request.ContentLength, appConfig.DiskExpansionFactor); err != nil {
+			c.JSON(http.StatusInsufficientStorage, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	// Read the form part by part so the file streams straight to disk instead of being buffered
+	var reader *multipart.Reader
+	if !isRawUpload(c) {
+		reader, err = c.Request.MultipartReader()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request must be multipart/form-data, or the file itself with an audio or video Content-Type"})
+			return
+		}
+	}
+
+	fields := map[string]string{}
+	received := sha256.New()
+	if reader == nil {
+		// Clients that can't build a form send the file as the whole body, with its options in
+		// the query string
+		fields = rawUploadFields(c)
+		filename := rawUploadFilename(c)
+		if err := checkUploadExtension(filename); err != nil {
+			respondWithError(c, err)
+			return
+		}
+		job, jobDir, err = startIdempotentJob(c.Request.Context(), uuid.New().String(), filename, idempotencyKey)
+		if err != nil {
+			respondWithStartError(c, err)
+			return
+		}
+		tagJob(c, job.ID)
+		tempRawAudioFile = filepath.Join(jobDir, "upload-"+filename)
+		if err := saveUploadPart(io.TeeReader(c.Request.Body, received), tempRawAudioFile); err != nil {
+			failJob(c, job, uploadError(err))
+			return
+		}
+	}
+	for reader != nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			err = uploadError(err)
+			if job != nil {
+				failJob(c, job, err)
+			} else {
+				respondWithError(c, err)
+			}
+			return
+		}
+
+		if part.FormName() != "file" || job != nil || archivePath != "" {
+			value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldBytes))
+			fields[part.FormName()] = string(value)
+			part.Close()
+			continue
+		}
+
+		// A ZIP archive becomes a job per recording in it rather than a job of its own. Every byte
+		// of the file is hashed on its way to disk for a client-supplied checksum to be checked
+		file := bufio.NewReader(io.TeeReader(part, received))
+		if magic, _ := file.Peek(len(zipMagic)); bytes.Equal(magic, zipMagic) {
+			archivePath, err = saveArchive(file)
+			part.Close()
+			if err != nil {
+				respondWithError(c, uploadError(err))
+				return
+			}
+			continue
+		}
+
+		if err := checkUploadExtension(part.FileName()); err != nil {
+			part.Close()
+			respondWithError(c, err)
+			return
+		}
+
+		// Record the job and give it its own workspace so cleanup is a single call
+		job, jobDir, err = startIdempotentJob(c.Request.Context(), uuid.New().String(), part.FileName(), idempotencyKey)
+		if err != nil {
+			part.Close()
+			respondWithStartError(c, err)
+			return
+		}
+		tagJob(c, job.ID)
+
+		// Save uploaded file to the job directory
+		tempRawAudioFile = filepath.Join(jobDir, "upload-"+filepath.Base(part.FileName()))
+		err = saveUploadPart(file, tempRawAudioFile)
+		part.Close()
+		if err != nil {
+			failJob(c, job, uploadError(err))
+			return
+		}
+	}
+
+	checksumErr := verifyContentSHA256(c.GetHeader(contentSHA256Header), fields[contentSHA256Field], received)
+	if archivePath != "" {
+		if checksumErr != nil {
+			respondWithError(c, checksumErr)
+			return
+		}
+		if idempotencyKey != "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Idempotency-Key is not supported for ZIP archives"})
+			return
+		}
+		opts, err := formJobOptions(tenantFrom(c.Request.Context()), fields)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		transcribeArchive(c, archivePath, opts)
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No file provided"})
+		return
+	}
+	if checksumErr != nil {
+		failJob(c, job, checksumErr)
+		return
+	}
+
+	opts, err := formJobOptions(tenantFrom(c.Request.Context()), fields)
+	if err != nil {
+		failJob(c, job, err)
+		return
+	}
+	if wantsStream(c, fields) {
+		respondWithStream(c, job, jobDir, tempRawAudioFile, opts, nil)
+		return
+	}
+	respondWithPipeline(c, job, jobDir, tempRawAudioFile, opts, nil)
+}
+
+// maxFormFieldBytes caps how much of a non-file form field is read
+const maxFormFieldBytes = 64 << 10
+
+// rawFilenameHeader names the file of a raw body upload, as do the filename query parameter and
+// a Content-Disposition header
+const rawFilenameHeader = "X-Filename"
+
+// isRawUpload reports whether a request sends the file itself as its body, with an audio or
+// video Content-Type, rather than in a multipart form
+func isRawUpload(c *gin.Context) bool {
+	return isAllowedDownloadType(c.GetHeader("Content-Type"))
+}
+
+// rawUploadFields returns the options of a raw body upload, which come as query parameters named
+// like the form fields
+func rawUploadFields(c *gin.Context) map[string]string {
+	fields := map[string]string{}
+	for key, values := range c.Request.URL.Query() {
+		fields[key] = values[0]
+	}
+	return fields
+}
+
+// rawUploadFilename returns the name a raw body upload gives its file, or "upload" when it gives
+// none
+func rawUploadFilename(c *gin.Context) string {
+	name := cmp.Or(c.GetHeader(rawFilenameHeader), c.Query("filename"))
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Disposition")); err == nil {
+			name = params["filename"]
+		}
+	}
+	name = filepath.Base(name)
+	if name == "." || name == string(filepath.Separator) {
+		return "upload"
+	}
+	return name
+}
+
+// saveUploadPart streams a multipart file part to disk, encrypted when it goes into an encrypted
+// job's directory
+func saveUploadPart(part io.Reader, path string) error {
+	tempFile, err := createJobFile(path)
+	if err != nil {
+		return &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to create temp file"}
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, part); err != nil {
+		return err
+	}
+	return tempFile.Close()
+}
+
+// uploadError turns an error from reading the request body into a pipelineError with a useful status
+func uploadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("File too large: maximum upload size is %d bytes", maxBytesErr.Limit)}
+	}
+	var pipelineErr *pipelineError
+	if errors.As(err, &pipelineErr) {
+		return err
+	}
+	return &pipelineError{Status: http.StatusBadRequest, Message: "Failed to read upload: " + err.Error()}
+}
+
+func transcribeURL(c *gin.Context) {
+	var request URLTranscriptionRequest
+	if err := decodeWithProfile(c.Request.Body, &request); err != nil || request.URL == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a url field"})
+		return
+	}
+	opts, err := urlJobOptions(tenantFrom(c.Request.Context()), request)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	idempotencyKey, err := parseIdempotencyKey(c.GetHeader(idempotencyKeyHeader))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	prior, err := findIdempotentJob(c.Request.Context(), idempotencyKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to look up Idempotency-Key"})
+		return
+	}
+	if prior != nil {
+		replayJob(c, prior, opts)
+		return
+	}
+
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer release()
+
+	// Record the job and give it its own workspace so cleanup is a single call
+	job, jobDir, err := startIdempotentJob(c.Request.Context(), uuid.New().String(), request.URL, idempotencyKey)
+	if err != nil {
+		respondWithStartError(c, err)
+		return
+	}
+	tagJob(c, job.ID)
+	defer removeJobDir(jobDir)
+
+	// Fetch the remote media into the job directory
+	downloadedFile, source, err := fetchRequestedURL(c.Request.Context(), request, jobDir)
+	if err != nil {
+		failJob(c, job, err)
+		return
+	}
+
+	respondWithPipeline(c, job, jobDir, downloadedFile, opts, source)
+}
+
+// formJobOptions validates the options of a multipart upload from a tenant, or nil without tenants
+func formJobOptions(tenant *Tenant, fields map[string]string) (JobOptions, error) {
+	fields, err := profileFields(fields)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	priority, err := parsePriority(fields["priority"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	audioFilters, err := parseAudioFilters(fields["audio_filters"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	speed, err := parseSpeed(fields["speed"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	start, end, err := parseTimeRange(fields["start"], fields["end"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	channelLabels, err := parseChannelLabels(splitLabelList(fields["channel_labels"]))
+	if err != nil {
+		return JobOptions{}, err
+	}
+	profanityMode, err := parseProfanityFilter(fields["profanity_filter"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	minConfidence, err := parseMinConfidence(fields["min_confidence"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	prompt, err := parsePrompt(fields["prompt"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	language, err := parseLanguage(fields["language"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	selection, err := parseModelFields(tenant, fields)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	codeSwitching := fields["code_switching"] == "true"
+	if codeSwitching {
+		if err := checkCodeSwitching(selection, language); err != nil {
+			return JobOptions{}, err
+		}
+	}
+	notifyEmail, err := parseNotifyEmail(fields["notify_email"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	slackWebhook, err := parseWebhookURL("slack", fields["slack_webhook_url"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	discordWebhook, err := parseWebhookURL("discord", fields["discord_webhook_url"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	identifySpeakers := fields["identify_speakers"] == "true"
+	if identifySpeakers && appConfig.SpeakerEmbeddingURL == "" {
+		return JobOptions{}, errNoSpeakerEmbedding
+	}
+	retainAudio := fields["retain_audio"] == "true"
+	if retainAudio && appConfig.EncryptTempFiles {
+		return JobOptions{}, errRetainEncrypted
+	}
+	if retainAudio && appConfig.RedisURL != "" {
+		return JobOptions{}, errRetainSharedQueue
+	}
+	subtitles, err := parseSubtitles(fields["subtitles"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	storeResults := fields["store_results"] == "true"
+	if storeResults && appConfig.ResultsBucket == "" {
+		return JobOptions{}, errNoResultsBucket
+	}
+	punctuation, err := parsePunctuation(fields["punctuation"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	hallucinations, err := parseHallucinations(fields["hallucinations"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+	translateTo, err := parseTranslateTo(fields["translate_to"])
+	if err != nil {
+		return JobOptions{}, err
+	}
+
+	return JobOptions{
+		AudioTrack:        fields["audio_track"],
+		AudioLanguage:     fields["audio_language"],
+		UseCache:          fields["cache"] != "false",
+		Normalize:         fields["normalize"] == "true",
+		Denoise:           fields["denoise"] == "true",
+		AudioFilters:      audioFilters,
+		SkipNonSpeech:     fields["skip_non_speech"] == "true",
+		RemoveSilence:     fields["remove_silence"] == "true",
+		Speed:             speed,
+		Start:             start,
+		End:               end,
+		Provider:          selection.Provider,
+		Model:             selection.Model,
+		Temperature:       selection.Temperature,
+		Prompt:            prompt,
+		Language:          language,
+		CodeSwitching:     codeSwitching,
+		SplitChannels:     fields["split_channels"] == "true",
+		WordTimestamps:    fields["word_timestamps"] == "true",
+		Summarize:         fields["summarize"] == "true",
+		Sentiment:         fields["sentiment"] == "true",
+		TranslateTo:       translateTo,
+		Keywords:          fields["keywords"] == "true",
+		Chapters:          fields["chapters"] == "true",
+		Punctuation:       punctuation,
+		Hallucinations:    hallucinations,
+		Redact:            fields["redact"] == "true",
+		ProfanityFilter:   profanityMode,
+		MinConfidence:     minConfidence,
+		ChannelLabels:     channelLabels,
+		IdentifySpeakers:  identifySpeakers,
+		AudioEvents:       fields["audio_events"] == "true",
+		Priority:          priority,
+		NotifyEmail:       notifyEmail,
+		SlackWebhookURL:   slackWebhook,
+		DiscordWebhookURL: discordWebhook,
+		RetainAudio:       retainAudio,
+		Subtitles:         subtitles,
+		StoreResults:      storeResults,
+	}, nil
+}
+
+// urlJobOptions validates the options of a URL request from a tenant, or nil without tenants,
+// including its ingest mode
+func urlJobOptions(tenant *Tenant, request URLTranscriptionRequest) (JobOptions, error) {
+	if request.Profile != "" {
+		if _, err := findProfile(request.Profile); err != nil {
+			return JobOptions{}, err
+		}
+	}
+	if request.Ingest != "" && request.Ingest != "direct" && request.Ingest != "yt-dlp" {
+		return JobOptions{}, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Unknown ingest mode %q: expected direct or yt-dlp", request.Ingest)}
+	}
+	priority, err := parsePriority(request.Priority)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	audioFilters, err := parseAudioFilters(request.AudioFilters)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	if err := checkSpeed(request.Speed); err != nil {
+		return JobOptions{}, err
+	}
+	if err := checkTimeRange(request.Start, request.End); err != nil {
+		return JobOptions{}, err
+	}
+	channelLabels, err := parseChannelLabels(request.ChannelLabels)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	profanityMode, err := parseProfanityFilter(request.ProfanityFilter)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	if !validMinConfidence(request.MinConfidence) {
+		return JobOptions{}, &pipelineError{Status: http.StatusBadRequest, Message: "min_confidence must be a number between 0 and 1"}
+	}
+	prompt, err := parsePrompt(request.Prompt)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	language, err := parseLanguage(request.Language)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	var temperature float64
+	if request.Temperature != nil {
+		temperature = *request.Temperature
+	}
+	selection, err := parseModelSelection(tenant, request.Provider, request.Model, temperature)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	if request.CodeSwitching {
+		if err := checkCodeSwitching(selection, language); err != nil {
+			return JobOptions{}, err
+		}
+	}
+	notifyEmail, err := parseNotifyEmail(request.NotifyEmail)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	slackWebhook, err := parseWebhookURL("slack", request.SlackWebhookURL)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	discordWebhook, err := parseWebhookURL("discord", request.DiscordWebhookURL)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	if request.IdentifySpeakers && appConfig.SpeakerEmbeddingURL == "" {
+		return JobOptions{}, errNoSpeakerEmbedding
+	}
+	if request.RetainAudio && appConfig.EncryptTempFiles {
+		return JobOptions{}, errRetainEncrypted
+	}
+	if request.RetainAudio && appConfig.RedisURL != "" {
+		return JobOptions{}, errRetainSharedQueue
+	}
+	subtitles, err := parseSubtitles(request.Subtitles)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	if request.StoreResults && appConfig.ResultsBucket == "" {
+		return JobOptions{}, errNoResultsBucket
+	}
+	punctuation, err := parsePunctuation(request.Punctuation)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	hallucinations, err := parseHallucinations(request.Hallucinations)
+	if err != nil {
+		return JobOptions{}, err
+	}
+	translateTo, err := parseTranslateTo(request.TranslateTo)
+	if err != nil {
+		return JobOptions{}, err
+	}
+
+	return JobOptions{
+		AudioTrack:        request.AudioTrack,
+		AudioLanguage:     request.AudioLanguage,
+		UseCache:          request.Cache == nil || *request.Cache,
+		Normalize:         request.Normalize,
+		Denoise:           request.Denoise,
+		AudioFilters:      audioFilters,
+		SkipNonSpeech:     request.SkipNonSpeech,
+		RemoveSilence:     request.RemoveSilence,
+		Speed:             request.Speed,
+		Start:             request.Start,
+		End:               request.End,
+		Provider:          selection.Provider,
+		Model:             selection.Model,
+		Temperature:       selection.Temperature,
+		Prompt:            prompt,
+		Language:          language,
+		CodeSwitching:     request.CodeSwitching,
+		SplitChannels:     request.SplitChannels,
+		WordTimestamps:    request.WordTimestamps,
+		Summarize:         request.Summarize,
+		Sentiment:         request.Sentiment,
+		TranslateTo:       translateTo,
+		Keywords:          request.Keywords,
+		Chapters:          request.Chapters,
+		Punctuation:       punctuation,
+		Hallucinations:    hallucinations,
+		Redact:            request.Redact,
+		ProfanityFilter:   profanityMode,
+		MinConfidence:     request.MinConfidence,
+		ChannelLabels:     channelLabels,
+		IdentifySpeakers:  request.IdentifySpeakers,
+		AudioEvents:       request.AudioEvents,
+		Priority:          priority,
+		NotifyEmail:       notifyEmail,
+		SlackWebhookURL:   slackWebhook,
+		DiscordWebhookURL: discordWebhook,
+		RetainAudio:       request.RetainAudio,
+		Subtitles:         subtitles,
+		StoreResults:      request.StoreResults,
+	}, nil
+}
+
+// fetchRequestedURL downloads the media a URL request points at into jobDir, through yt-dlp when
+// asked, and stops early if shutdown cancels in-flight jobs
+func fetchRequestedURL(ctx context.Context, request URLTranscriptionRequest, jobDir string) (string, *SourceMetadata, error) {
+	ctx, cancel := jobContext(ctx)
+	defer cancel()
+	if request.Ingest == "yt-dlp" {
+		return downloadWithYtDlp(ctx, request.URL, jobDir)
+	}
+	path, err := fetchInput(ctx, request.URL, jobDir)
+	return path, nil, err
+}
+
+// startJob records a new job and creates its scratch directory
+func startJob(ctx context.Context, id, filename string) (*Job, string, error) {
+	return startBatchJob(ctx, id, filename, "")
+}
+
+// startBatchJob is startJob for a job submitted as part of a batch
+func startBatchJob(ctx context.Context, id, filename, batchID string) (*Job, string, error) {
+	return createJob(ctx, &Job{ID: id, Filename: filename, BatchID: batchID})
+}
+
+// createJob records a job described by the caller as processing with the default provider and
+// model of the request's tenant, and creates its scratch directory
+func createJob(ctx context.Context, job *Job) (*Job, string, error) {
+	logger := loggerFrom(ctx).With("job_id", job.ID)
+	tenant := tenantFrom(ctx)
+	providers := providersFor(tenant)
+	job.TenantID = tenantIDFrom(ctx)
+	job.Status = JobStatusProcessing
+	job.Provider = providers.defaultProvider
+	job.Model = transcriberFor(tenant, providers.defaultProvider).Model()
+	job.CreatedAt = time.Now().UTC()
+	if err := jobStore.CreateJob(job); err != nil {
+		logger.Error("Error recording job", "error", err)
+		return nil, "", err
+	}
+	auditJob(ctx, AuditJobSubmitted, job, "")
+
+	jobDir, err := createJobDir(appConfig.WorkDir, job.ID)
+	if err == nil && appConfig.EncryptTempFiles {
+		if err = encryptJobDir(jobDir); err != nil {
+			removeJobDir(jobDir)
+		}
+	}
+	if err != nil {
+		finishJob(withLogger(ctx, logger), job, nil, err)
+		return nil, "", err
+	}
+
+	logger.Info("Job started", "filename", job.Filename)
+	return job, jobDir, nil
+}
+
+// finishJob records the outcome of a job
+func finishJob(ctx context.Context, job *Job, result *transcriber.Result, err error) {
+	logger := loggerFrom(ctx)
+	completedAt := time.Now().UTC()
+	job.CompletedAt = &completedAt
+	switch {
+	case errors.Is(err, errJobCanceled):
+		job.Status = JobStatusCanceled
+		job.Error = err.Error()
+	case err != nil:
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		job.FailedStage = string(failedStage(err))
+	default:
+		job.Status = JobStatusCompleted
+		job.Transcript = result.Transcription
+		job.Segments = result.Segments
+		job.Words = result.Words
+		job.AudioHash = result.AudioHash
+		job.DurationSeconds = result.DurationSeconds
+		job.Chunks = result.Chunks
+		job.Skipped = result.Skipped
+		job.SilenceRemoved = result.SilenceRemoved
+		job.EstimatedCost = estimateCost(job.Model, result)
+	}
+
+	if err := jobStore.UpdateJob(job); err != nil {
+		logger.Error("Error saving job", "error", err)
+	}
+	if err := jobStore.DeleteResumableJob(job.ID); err != nil {
+		logger.Error("Error forgetting resumable job", "error", err)
+	}
+	observeJob(job)
+
+	// The request that canceled a job is what the audit trail records for it
+	duration := completedAt.Sub(job.CreatedAt)
+	switch job.Status {
+	case JobStatusCanceled:
+		logger.Info("Job canceled", "duration", duration)
+	case JobStatusFailed:
+		auditJob(ctx, AuditJobFailed, job, job.Error)
+		logger.Warn("Job failed", "duration", duration, "error", err)
+	default:
+		auditJob(ctx, AuditJobCompleted, job, "")
+		logger.Info("Job completed", "duration", duration, "cached", result.Cached)
+	}
+}
+
+// failJob records a failed job and reports the error to the client
+func failJob(c *gin.Context, job *Job, err error) {
+	finishJob(c.Request.Context(), job, nil, err)
+	respondWithError(c, err)
+}
+
+// respondWithPipeline runs the transcription pipeline, records the outcome, and writes it to the response
+func respondWithPipeline(c *gin.Context, job *Job, jobDir, inputPath string, opts JobOptions, source *SourceMetadata) {
+	result, err := executeJob(c.Request.Context(), job, jobDir, inputPath, opts)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// Return the combined transcription
+	c.JSON(http.StatusOK, successResponse(job, result, opts, source))
+}
+
+// successResponse describes a finished job, filtering profanity and flagging low-confidence
+// segments as the job asks. Its stats count the transcript as it was, before either
+func successResponse(job *Job, result *transcriber.Result, opts JobOptions, source *SourceMetadata) SuccessResponse {
+	stats := transcriber.TranscriptStats(result.Transcription, result.Segments)
+	result = filterResult(result, opts.ProfanityFilter)
+	job = filterJob(job, opts.ProfanityFilter)
+	result, lowConfidence := flagResult(result, opts.MinConfidence)
+	response := SuccessResponse{
+		JobID:            job.ID,
+		Transcription:    result.Transcription,
+		ReadableText:     readableText(result.Transcription, transcriber.MarkLowConfidence(result.Segments)),
+		LowConfidence:    lowConfidence,
+		Hallucinated:     job.HallucinatedSegments,
+		Summary:          job.Summary,
+		SummaryError:     job.SummaryError,
+		Translation:      job.Translation,
+		TranslationError: job.TranslationError,
+		SpeakersError:    job.SpeakersError,
+		SentimentError:   job.SentimentError,
+		Skipped:          result.Skipped,
+		SilenceRemoved:   result.SilenceRemoved,
+		Events:           job.Events,
+		EventsError:      job.EventsError,
+		Keywords:         job.Keywords,
+		Chapters:         job.Chapters,
+		Corrections:      job.Corrections,
+		Redacted:         job.Redacted,
+		Cached:           result.Cached,
+		Source:           source,
+		Usage:            jobUsage(job),
+		Stats:            &stats,
+		Timings:          job.Timings,
+		Words:            result.Words,
+		VideoURL:         subtitledVideoURL(job),
+		SubtitlesError:   job.SubtitlesError,
+		ResultURLs:       job.ResultURLs,
+		ResultsError:     job.ResultsError,
+	}
+	// Stored results are linked to instead of embedded
+	if len(job.ResultURLs) > 0 {
+		response.Transcription, response.ReadableText, response.Words = "", "", nil
+	}
+	return response
+}
+
+// respondWithStartError reports a job that couldn't be started, passing on a pipelineError such as
+// a conflicting Idempotency-Key
+func respondWithStartError(c *gin.Context, err error) {
+	var pipelineErr *pipelineError
+	if errors.As(err, &pipelineErr) {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start job"})
+}
+
+// respondWithError writes err as an ErrorResponse, using its status when it is a pipelineError
+func respondWithError(c *gin.Context, err error) {
+	var pipelineErr *pipelineError
+	if errors.As(err, &pipelineErr) {
+		if pipelineErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(pipelineErr.RetryAfter)))
+		}
+		c.JSON(pipelineErr.Status, ErrorResponse{Error: pipelineErr.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+}
+
+// failedStage is the pipeline stage err came from, or "" when it didn't come from the pipeline
+func failedStage(err error) transcriber.Stage {
+	var pipelineErr *pipelineError
+	if errors.As(err, &pipelineErr) {
+		return pipelineErr.Stage
+	}
+	var stageErr *transcriber.StageError
+	if errors.As(err, &stageErr) {
+		return stageErr.Stage
+	}
+	return ""
+}
+
+// errorMessage is the message respondWithError would report for err
+func errorMessage(err error) string {
+	var pipelineErr *pipelineError
+	if errors.As(err, &pipelineErr) {
+		return pipelineErr.Message
+	}
+	return err.Error()
+}
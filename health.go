@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Component statuses reported by the health endpoints
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// readyCheckTimeout bounds each readiness check so a hung dependency can't stall the probe
+const readyCheckTimeout = 5 * time.Second
+
+// ComponentHealth is the status of a single dependency
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthResponse is returned by /healthz and /readyz
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// healthz reports that the process is up and serving requests
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: HealthStatusOK})
+}
+
+// readyz reports whether every dependency a job needs is available, with per-component details
+func readyz(c *gin.Context) {
+	checks := map[string]func(context.Context) error{
+		"database": jobStore.Ping,
+	}
+	// Without the startup check, the server is meant to run on WAV files alone if need be
+	if appConfig.FFmpegCheck {
+		checks["ffmpeg"] = lookPathCheck(appConfig.FFmpegPath)
+		checks["ffprobe"] = lookPathCheck(appConfig.FFprobePath)
+	}
+	if appConfig.ReadyCheckProvider {
+		checks["provider"] = transcriberFor(nil, "").Ping
+	}
+	if distQueue != nil {
+		checks["redis"] = distQueue.Ping
+	}
+	if appConfig.ClamAVEnabled {
+		checks["clamav"] = pingClamd
+	}
+
+	response := HealthResponse{Status: HealthStatusOK, Components: map[string]ComponentHealth{}}
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readyCheckTimeout)
+		err := check(ctx)
+		cancel()
+
+		if err != nil {
+			response.Status = HealthStatusUnavailable
+			response.Components[name] = ComponentHealth{Status: HealthStatusUnavailable, Error: err.Error()}
+			continue
+		}
+		response.Components[name] = ComponentHealth{Status: HealthStatusOK}
+	}
+
+	status := http.StatusOK
+	if response.Status != HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+// ffmpegCheckTimeout bounds the startup check of ffmpeg and ffprobe
+const ffmpegCheckTimeout = 30 * time.Second
+
+// checkFFmpeg points the pipeline at the configured ffmpeg and ffprobe and makes sure they run
+// and have everything it needs, exiting when they don't unless the check is turned off. It then
+// sets up the configured hardware acceleration, if its device opens
+func checkFFmpeg(cfg Config) {
+	transcriber.FFmpegPath = cfg.FFmpegPath
+	transcriber.FFprobePath = cfg.FFprobePath
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpegCheckTimeout)
+	defer cancel()
+	version, err := transcriber.CheckFFmpeg(ctx)
+	switch {
+	case err == nil:
+		slog.Info("Found ffmpeg", "path", cfg.FFmpegPath, "version", version)
+	case cfg.FFmpegCheck:
+		fatal("ffmpeg isn't usable; set TRANSCRIBER_FFMPEG_PATH and TRANSCRIBER_FFPROBE_PATH to a full build", "error", err)
+	default:
+		slog.Warn("ffmpeg isn't usable; jobs that need it will fail, and WAV files are decoded in-process if it is missing", "error", err)
+	}
+
+	// Hardware acceleration is only ever a speedup, so without it video is decoded in software
+	accel, err := transcriber.DetectHWAccel(ctx, cfg.HWAccel)
+	switch {
+	case err != nil:
+		slog.Warn("Hardware acceleration isn't available; decoding video in software", "hwaccel", cfg.HWAccel, "error", err)
+	case accel != "":
+		slog.Info("Decoding video with hardware acceleration", "hwaccel", accel)
+	case cfg.HWAccel == "auto":
+		slog.Info("No hardware acceleration method works; decoding video in software")
+	}
+	transcriber.HWAccel = accel
+}
+
+// lookPathCheck returns a check that the named executable is on the PATH, or exists when it is a path
+func lookPathCheck(name string) func(context.Context) error {
+	return func(context.Context) error {
+		_, err := exec.LookPath(name)
+		return err
+	}
+}
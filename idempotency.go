@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+const (
+	// idempotencyKeyHeader names a submission so retries of it return the job it created
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader marks a response that replays an earlier submission's job
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength caps Idempotency-Key values, which are stored with the job
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyKeyInUse is the error for a submission whose key belongs to a job still being processed
+func idempotencyKeyInUse() error {
+	return &pipelineError{
+		Status:     http.StatusConflict,
+		Message:    "A request with this Idempotency-Key is still being processed",
+		RetryAfter: appConfig.QueueRetryAfter,
+	}
+}
+
+// parseIdempotencyKey validates an Idempotency-Key header; "" means the request has none
+func parseIdempotencyKey(value string) (string, error) {
+	if len(value) > maxIdempotencyKeyLength {
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength),
+		}
+	}
+	return value, nil
+}
+
+// findIdempotentJob returns the job an earlier submission with the same key created, or nil when
+// there is none. A failed job gives its key up, so retrying a submission that failed, for example
+// because the connection dropped mid-upload, runs it again
+func findIdempotentJob(ctx context.Context, key string) (*Job, error) {
+	if key == "" {
+		return nil, nil
+	}
+	job, err := jobStore.FindJobByIdempotencyKey(tenantIDFrom(ctx), key)
+	if errors.Is(err, errJobNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == JobStatusFailed || job.Status == JobStatusCanceled {
+		return nil, jobStore.ClearIdempotencyKey(job.ID)
+	}
+	return job, nil
+}
+
+// startIdempotentJob is startJob for a submission that may carry an idempotency key. When a
+// concurrent submission with the same key won the race to create its job, idempotencyKeyInUse
+// is returned
+func startIdempotentJob(ctx context.Context, id, filename, key string) (*Job, string, error) {
+	job, jobDir, err := createJob(ctx, &Job{ID: id, Filename: filename, IdempotencyKey: key})
+	if err != nil && key != "" {
+		if prior, findErr := findIdempotentJob(ctx, key); findErr == nil && prior != nil {
+			return nil, "", idempotencyKeyInUse()
+		}
+	}
+	return job, jobDir, err
+}
+
+// replayJob answers a retried submission with the job the original created: its transcript once
+// it has completed, or a 409 while it is still being processed. The retry's own profanity_filter
+// and min_confidence shape the response
+func replayJob(c *gin.Context, job *Job, opts JobOptions) {
+	tagJob(c, job.ID)
+	c.Header(idempotentReplayedHeader, "true")
+	if job.Status != JobStatusCompleted {
+		respondWithError(c, idempotencyKeyInUse())
+		return
+	}
+
+	result := &transcriber.Result{
+		Transcription:   job.Transcript,
+		Segments:        job.Segments,
+		DurationSeconds: job.DurationSeconds,
+		AudioHash:       job.AudioHash,
+		Chunks:          job.Chunks,
+		Model:           job.Model,
+	}
+	job.ResultURLs = presignJobResults(c.Request.Context(), job)
+	c.JSON(http.StatusOK, successResponse(job, result, opts, nil))
+}
+
+// replayUpload is replayJob for a multipart upload. The form is still read to the end, with the
+// file discarded, so the client finishes sending it and its profanity_filter and min_confidence
+// fields are honored
+func replayUpload(c *gin.Context, job *Job) {
+	fields := map[string]string{}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.MaxUploadBytes)
+	if reader, err := c.Request.MultipartReader(); err == nil {
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if name := part.FormName(); name == "profanity_filter" || name == "min_confidence" {
+				value, _ := io.ReadAll(io.LimitReader(part, maxFormFieldBytes))
+				fields[name] = string(value)
+			} else {
+				io.Copy(io.Discard, part)
+			}
+			part.Close()
+		}
+	}
+	profanityMode, err := parseProfanityFilter(fields["profanity_filter"])
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	minConfidence, err := parseMinConfidence(fields["min_confidence"])
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	replayJob(c, job, JobOptions{ProfanityFilter: profanityMode, MinConfidence: minConfidence})
+}
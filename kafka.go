@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+const (
+	// kafkaPublishTimeout bounds publishing one job, retries included
+	kafkaPublishTimeout = 30 * time.Second
+
+	// kafkaMaxTranscriptBytes is the longest transcript a message carries. Longer ones are left
+	// for consumers to fetch, since brokers reject messages over 1 MB by default
+	kafkaMaxTranscriptBytes = 512 << 10
+)
+
+// kafkaWriter publishes completed jobs, or is nil when no brokers are configured
+var kafkaWriter *kafka.Writer
+
+// kafkaPublishes tracks the messages being published, so shutdown can wait for them
+var kafkaPublishes sync.WaitGroup
+
+// KafkaJobMessage is published for each completed job, keyed by its ID
+type KafkaJobMessage struct {
+	JobID           string                `json:"job_id"`
+	TenantID        string                `json:"tenant_id,omitempty"`
+	Filename        string                `json:"filename"`
+	Provider        string                `json:"provider"`
+	Model           string                `json:"model"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Redacted        bool                  `json:"redacted"`
+	BatchID         string                `json:"batch_id,omitempty"`
+	Summary         string                `json:"summary,omitempty"`
+	Keywords        []transcriber.Keyword `json:"keywords,omitempty"`
+	CreatedAt       time.Time             `json:"created_at"`
+	CompletedAt     *time.Time            `json:"completed_at,omitempty"`
+
+	// Transcript is the plain transcript, unless it was stored with store_results or is longer
+	// than kafkaMaxTranscriptBytes
+	Transcript string `json:"transcript,omitempty"`
+
+	// StoredResults are the s3:// URIs of the formats stored with store_results
+	StoredResults map[string]string `json:"stored_results,omitempty"`
+
+	// URL is the API path the whole job can be fetched from
+	URL string `json:"url"`
+}
+
+// newKafkaWriter returns the producer for the configured brokers and topic, or nil when no
+// brokers are configured
+func newKafkaWriter(cfg Config) (*kafka.Writer, error) {
+	if len(cfg.KafkaBrokers) == 0 {
+		return nil, nil
+	}
+	if cfg.KafkaTopic == "" {
+		return nil, fmt.Errorf("TRANSCRIBER_KAFKA_TOPIC must be set along with TRANSCRIBER_KAFKA_BROKERS")
+	}
+	transport := &kafka.Transport{}
+	if cfg.KafkaTLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if cfg.KafkaUsername != "" {
+		mechanism, err := kafkaSASLMechanism(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+
+	// Messages from jobs finishing together are batched, without holding any back for long
+	return &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Topic:        cfg.KafkaTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+		Transport:    transport,
+	}, nil
+}
+
+// kafkaSASLMechanism returns the configured SASL mechanism, PLAIN by default
+func kafkaSASLMechanism(cfg Config) (sasl.Mechanism, error) {
+	switch strings.ToLower(cfg.KafkaSASLMechanism) {
+	case "", "plain":
+		return plain.Mechanism{Username: cfg.KafkaUsername, Password: cfg.KafkaPassword}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.KafkaUsername, cfg.KafkaPassword)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.KafkaUsername, cfg.KafkaPassword)
+	default:
+		return nil, fmt.Errorf("unknown Kafka SASL mechanism %q: expected plain, scram-sha-256, or scram-sha-512", cfg.KafkaSASLMechanism)
+	}
+}
+
+// publishJob publishes a completed job to Kafka in the background, its transcript filtered the
+// way the job asks. A message that can't be published once the producer's retries run out is
+// logged and dropped
+func publishJob(ctx context.Context, job *Job, opts JobOptions) {
+	if kafkaWriter == nil || job.Status != JobStatusCompleted {
+		return
+	}
+	value, err := json.Marshal(kafkaJobMessage(filterJob(job, opts.ProfanityFilter)))
+	if err != nil {
+		loggerFrom(ctx).Error("Unable to encode Kafka message", "error", err)
+		return
+	}
+
+	kafkaPublishes.Add(1)
+	go func() {
+		defer kafkaPublishes.Done()
+		publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), kafkaPublishTimeout)
+		defer cancel()
+		err := kafkaWriter.WriteMessages(publishCtx, kafka.Message{Key: []byte(job.ID), Value: value})
+		if err != nil {
+			loggerFrom(ctx).Error("Unable to publish job to Kafka", "topic", kafkaWriter.Topic, "error", err)
+			return
+		}
+		loggerFrom(ctx).Info("Published job to Kafka", "topic", kafkaWriter.Topic)
+	}()
+}
+
+// kafkaJobMessage describes a completed job for Kafka, pointing to its stored results instead of
+// carrying the transcript when there are any
+func kafkaJobMessage(job *Job) KafkaJobMessage {
+	message := KafkaJobMessage{
+		JobID:           job.ID,
+		TenantID:        job.TenantID,
+		Filename:        job.Filename,
+		Provider:        job.Provider,
+		Model:           job.Model,
+		DurationSeconds: job.DurationSeconds,
+		Redacted:        job.Redacted,
+		BatchID:         job.BatchID,
+		Summary:         job.Summary,
+		Keywords:        job.Keywords,
+		CreatedAt:       job.CreatedAt,
+		CompletedAt:     job.CompletedAt,
+		URL:             "/api/transcriptions/" + job.ID,
+	}
+	switch {
+	case len(job.StoredResults) > 0:
+		bucket, _ := resultsLocation()
+		message.StoredResults = map[string]string{}
+		for format, key := range job.StoredResults {
+			message.StoredResults[format] = "s3://" + bucket + "/" + key
+		}
+	case len(job.Transcript) <= kafkaMaxTranscriptBytes:
+		message.Transcript = job.Transcript
+	}
+	return message
+}
+
+// closeKafka waits for the messages being published and closes the producer
+func closeKafka() {
+	kafkaPublishes.Wait()
+	if err := kafkaWriter.Close(); err != nil {
+		slog.Warn("Error closing Kafka producer", "error", err)
+	}
+}
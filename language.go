@@ -0,0 +1,215 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// maxLanguageSampleSeconds caps how much audio a language detection may send to the provider
+const maxLanguageSampleSeconds = 120
+
+// LanguageResponse is the language spoken in a sample of the audio
+type LanguageResponse struct {
+	Language      string          `json:"language"`
+	Name          string          `json:"name"`
+	Confidence    *float64        `json:"confidence,omitempty"`
+	SampleSeconds float64         `json:"sample_seconds"`
+	Transcription string          `json:"transcription"`
+	Source        *SourceMetadata `json:"source,omitempty"`
+	Usage         *UsageMetadata  `json:"usage"`
+}
+
+// detectLanguage identifies the language spoken in an upload (multipart, like /api/transcribe) or
+// a URL (JSON, like /api/transcribe/url) by transcribing a short sample from its start, so files
+// can be routed before they are transcribed. Nothing is recorded in the job history
+func detectLanguage(c *gin.Context) {
+	sampleSeconds, err := parseSampleSeconds(c.Query("sample_seconds"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// A URL request has to be well-formed before anything is downloaded
+	var request URLTranscriptionRequest
+	var selection modelSelection
+	var requestedModel string
+	tenant := tenantFrom(c.Request.Context())
+	isURL := c.ContentType() == "application/json"
+	if isURL {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a url field"})
+			return
+		}
+		if request.Ingest != "" && request.Ingest != "direct" && request.Ingest != "yt-dlp" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown ingest mode %q: expected direct or yt-dlp", request.Ingest)})
+			return
+		}
+		requestedModel = request.Model
+		if selection, err = parseModelSelection(tenant, request.Provider, request.Model, 0); err != nil {
+			respondWithError(c, err)
+			return
+		}
+	}
+
+	// Detection transcribes, so it takes a place in the queue like any job
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer release()
+
+	jobDir, err := createJobDir(appConfig.WorkDir, uuid.New().String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create job directory"})
+		return
+	}
+	defer removeJobDir(jobDir)
+
+	var inputPath string
+	var source *SourceMetadata
+	opts := transcriber.DetectLanguageOptions{SampleSeconds: sampleSeconds, Logger: loggerFrom(c.Request.Context())}
+	if isURL {
+		opts.AudioTrack = request.AudioTrack
+		opts.AudioLanguage = request.AudioLanguage
+		inputPath, source, err = fetchRequestedURL(c.Request.Context(), request, jobDir)
+	} else {
+		var fields map[string]string
+		inputPath, fields, err = receiveEstimateUpload(c, jobDir)
+		opts.AudioTrack = fields["audio_track"]
+		opts.AudioLanguage = fields["audio_language"]
+		requestedModel = fields["model"]
+		if err == nil {
+			selection, err = parseModelSelection(tenant, fields["provider"], fields["model"], 0)
+		}
+	}
+	if err == nil {
+		opts.Model, err = languageDetectionModel(tenant, selection, requestedModel)
+	}
+	if err == nil {
+		err = checkMediaContainer(inputPath)
+	}
+	if err == nil {
+		err = scanMedia(c.Request.Context(), inputPath)
+	}
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	detected, err := transcriberFor(tenant, selection.Provider).DetectLanguage(c.Request.Context(), inputPath, jobDir, opts)
+	if err != nil {
+		respondWithError(c, pipelineErrorFor(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, LanguageResponse{
+		Language:      detected.Language,
+		Name:          detected.Name,
+		Confidence:    detected.Confidence,
+		SampleSeconds: detected.SampleSeconds,
+		Transcription: detected.Transcription,
+		Source:        source,
+		Usage: &UsageMetadata{
+			DurationSeconds:  detected.SampleSeconds,
+			Chunks:           1,
+			Provider:         selection.Provider,
+			Model:            opts.Model,
+			EstimatedCostUSD: costFor(opts.Model, detected.SampleSeconds),
+		},
+	})
+}
+
+// parseSampleSeconds validates a requested sample length; empty means the library's default
+func parseSampleSeconds(value string) (float64, error) {
+	if value == "" {
+		return transcriber.DefaultLanguageSampleSeconds, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(seconds > 0 && seconds <= maxLanguageSampleSeconds) {
+		return 0, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("sample_seconds must be a number of seconds up to %d", maxLanguageSampleSeconds)}
+	}
+	return seconds, nil
+}
+
+// languageDetectionModel picks the model a sample is identified with. A model that only knows one
+// language can't tell languages apart, so when none was asked for and the provider's default is
+// one, the first allowed multilingual model is used instead
+func languageDetectionModel(tenant *Tenant, selection modelSelection, requested string) (string, error) {
+	multilingual := func(model string) bool {
+		capability, ok := modelCapabilities[selection.Provider+":"+model]
+		return !ok || len(capability.Languages) != 1
+	}
+	if multilingual(selection.Model) {
+		return selection.Model, nil
+	}
+	if requested == "" {
+		for _, model := range providersFor(tenant).allowedModels[selection.Provider] {
+			if multilingual(model) {
+				return model, nil
+			}
+		}
+	}
+	return "", &pipelineError{
+		Status:  http.StatusBadRequest,
+		Message: fmt.Sprintf("Model %q only knows one language, so it can't detect languages: choose a multilingual model", selection.Model),
+	}
+}
+
+// languageModels are the models TRANSCRIBER_LANGUAGE_MODELS picks for the parts of code-switched
+// recordings identified as each language, by ISO-639-1 code
+var languageModels map[string]string
+
+// initLanguageModels reads the "language=model" entries of TRANSCRIBER_LANGUAGE_MODELS
+func initLanguageModels(entries []string) error {
+	models := map[string]string{}
+	for _, entry := range entries {
+		language, model, _ := strings.Cut(entry, "=")
+		language, model = strings.ToLower(strings.TrimSpace(language)), strings.TrimSpace(model)
+		if language == "" || language == transcriber.LanguageAuto || transcriber.ValidateLanguage(language) != nil || model == "" {
+			return fmt.Errorf("invalid TRANSCRIBER_LANGUAGE_MODELS entry %q: expected language=model, such as en=distil-whisper-large-v3-en", entry)
+		}
+		models[language] = model
+	}
+	languageModels = models
+	return nil
+}
+
+// jobLanguageModels returns the models the parts of a code-switching job may be routed to: those
+// of TRANSCRIBER_LANGUAGE_MODELS the job's provider allows and that know the language
+func jobLanguageModels(opts JobOptions) map[string]string {
+	if !opts.CodeSwitching || len(languageModels) == 0 {
+		return nil
+	}
+	providers := providersFor(tenantsByID[opts.Tenant])
+	provider := cmp.Or(opts.Provider, providers.defaultProvider)
+	models := map[string]string{}
+	for language, model := range languageModels {
+		languages := modelCapabilities[provider+":"+model].Languages
+		if slices.Contains(providers.allowedModels[provider], model) && (languages == nil || slices.Contains(languages, language)) {
+			models[language] = model
+		}
+	}
+	return models
+}
+
+// checkCodeSwitching rejects code switching with a language, since it leaves out the language hint,
+// and with a model known to transcribe only one language, since it can't tell languages apart
+func checkCodeSwitching(selection modelSelection, language string) error {
+	if language != "" && language != transcriber.LanguageAuto {
+		return &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("code_switching identifies the language of each part of the recording, so it can't be combined with language %q", language)}
+	}
+	if languages := modelCapabilities[selection.Provider+":"+selection.Model].Languages; len(languages) == 1 {
+		return &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Model %q only transcribes %s, so it can't tell languages apart: pick a multilingual model for code_switching", selection.Model, languages[0])}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemdListenFDsStart is the first file descriptor systemd passes sockets on
+const systemdListenFDsStart = 3
+
+// listen opens the REST API's listener: the socket systemd passed in when the process was socket
+// activated, a Unix socket when ListenAddr is unix:/path, and a TCP port otherwise
+func listen(config Config) (net.Listener, error) {
+	listener, err := systemdListener()
+	if listener != nil || err != nil {
+		return listener, err
+	}
+	if path, ok := unixSocketPath(config.ListenAddr); ok {
+		return listenUnix(path, config.ListenSocketMode)
+	}
+	return net.Listen("tcp", config.ListenAddr)
+}
+
+// unixSocketPath returns the path of a unix:/path or unix:///path listen address
+func unixSocketPath(addr string) (string, bool) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return "", false
+	}
+	if strings.HasPrefix(path, "//") {
+		path = path[2:]
+	}
+	return path, true
+}
+
+// listenUnix listens on a Unix socket at path with the given permissions, replacing a socket left
+// behind by a previous run. The socket is removed again when the listener is closed
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// systemdListener returns the first socket systemd passed to the process under socket activation,
+// following sd_listen_fds(3), or nil when it wasn't socket activated. The variables saying so are
+// cleared, so the processes the pipeline runs don't take the sockets for theirs
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || count < 1 {
+		return nil, errors.New("socket activated without a socket: LISTEN_FDS is unset or zero")
+	}
+
+	// The sockets are duplicated into the listener, so the descriptors systemd passed are closed,
+	// along with any beyond the first, which nothing uses
+	var listener net.Listener
+	for fd := systemdListenFDsStart; fd < systemdListenFDsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), "systemd socket")
+		if listener == nil {
+			listener, err = net.FileListener(file)
+		}
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket from systemd can't be listened on: %w", err)
+		}
+	}
+	return listener, nil
+}
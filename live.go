@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// liveStreamSchemes are the URL schemes a live stream may be pulled from; HLS playlists are http(s)
+var liveStreamSchemes = []string{"rtsp", "rtsps", "rtmp", "rtmps", "http", "https"}
+
+// LiveStreamResponse is returned when a live stream starts being transcribed
+type LiveStreamResponse struct {
+	JobID     string `json:"job_id"`
+	EventsURL string `json:"events_url"`
+}
+
+// liveStream is a running live stream job and the partial transcript clients are following
+type liveStream struct {
+	job  *Job
+	opts JobOptions
+
+	// stop is closed to end the stream; done is closed once its job has been recorded
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu          sync.Mutex
+	segments    []transcriber.Segment
+	subscribers map[chan struct{}]struct{}
+}
+
+// liveStreams holds the live streams this instance is transcribing, by job ID
+var liveStreams sync.Map
+
+// startLiveStream starts transcribing a live RTSP, RTMP, or HLS stream in the background. Partial
+// transcripts are streamed from the events endpoint until the stream is stopped or ends
+func startLiveStream(c *gin.Context) {
+	var request URLTranscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a url field"})
+		return
+	}
+	streamURL, err := checkStreamURL(c.Request.Context(), request.URL)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if request.SplitChannels {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "split_channels is not supported for live streams"})
+		return
+	}
+	opts, err := urlJobOptions(tenantFrom(c.Request.Context()), request)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// The stream outlives the request, but its spans and logs still belong to it. Credentials
+	// in the URL are kept out of the job history
+	ctx := context.WithoutCancel(c.Request.Context())
+	job, jobDir, err := startJob(ctx, uuid.New().String(), streamURL.Redacted())
+	if err != nil {
+		release()
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start job"})
+		return
+	}
+
+	stream := &liveStream{
+		job:         job,
+		opts:        opts,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		subscribers: map[chan struct{}]struct{}{},
+	}
+	stream.opts.OnSegments = stream.publish
+	liveStreams.Store(job.ID, stream)
+	go runLiveStream(withLogger(ctx, loggerFrom(ctx).With("job_id", job.ID)), stream, jobDir, streamURL.String(), release)
+
+	eventsURL := "/api/streams/" + job.ID + "/events"
+	c.Header("Location", eventsURL)
+	c.JSON(http.StatusAccepted, LiveStreamResponse{JobID: job.ID, EventsURL: eventsURL})
+}
+
+// checkStreamURL validates a live stream URL and, unless TRANSCRIBER_ALLOW_PRIVATE_URLS is set,
+// rejects hosts that resolve to private addresses. ffmpeg makes its own connections, so the host
+// is checked up front rather than at dial time as downloads are
+func checkStreamURL(ctx context.Context, rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || !slices.Contains(liveStreamSchemes, parsed.Scheme) || parsed.Hostname() == "" {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "URL must be an absolute rtsp, rtmp, or http(s) URL"}
+	}
+	if appConfig.AllowPrivateURLs {
+		return parsed, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "Failed to resolve stream host: " + err.Error()}
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return nil, &pipelineError{Status: http.StatusBadRequest, Message: "Refusing to read a stream from private address " + addr.IP.String()}
+		}
+	}
+	return parsed, nil
+}
+
+// runLiveStream transcribes a live stream until it is stopped, runs out of time, or ends, then
+// records the job like any other. Live streams hold a place in the queue but not a pipeline
+// worker, since they would keep it for as long as the stream runs
+func runLiveStream(ctx context.Context, stream *liveStream, jobDir, streamURL string, release func()) {
+	defer release()
+	defer removeJobDir(jobDir)
+	defer close(stream.done)
+	defer liveStreams.Delete(stream.job.ID)
+	ctx, untrack := trackJob(ctx, stream.job.ID)
+	defer untrack()
+
+	if appConfig.LiveMaxDuration > 0 {
+		timer := time.AfterFunc(appConfig.LiveMaxDuration, stream.end)
+		defer timer.Stop()
+	}
+
+	opts := prepareJob(stream.job, stream.opts)
+	pipelineCtx, cancel := jobContext(ctx)
+	defer cancel()
+	result, err := transcriberFor(tenantsByID[opts.Tenant], opts.Provider).TranscribeLive(pipelineCtx, streamURL, jobDir, stream.stop, transcribeOptions(ctx, opts))
+	if err != nil {
+		err = pipelineErrorFor(err)
+	}
+	completeJob(ctx, stream.job, opts, "", result, err)
+}
+
+// publish adds segments to the partial transcript and wakes every client following it
+func (s *liveStream) publish(segments []transcriber.Segment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.segments = append(s.segments, segments...)
+	for notify := range s.subscribers {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel that is signaled whenever segments are published, starting
+// signaled so a new client catches up on what it missed
+func (s *liveStream) subscribe() (chan struct{}, func()) {
+	notify := make(chan struct{}, 1)
+	notify <- struct{}{}
+	s.mu.Lock()
+	s.subscribers[notify] = struct{}{}
+	s.mu.Unlock()
+	return notify, func() {
+		s.mu.Lock()
+		delete(s.subscribers, notify)
+		s.mu.Unlock()
+	}
+}
+
+// since returns the segments published after the first n
+func (s *liveStream) since(n int) []transcriber.Segment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.segments[n:])
+}
+
+// end asks the stream to stop after transcribing what it has already received
+func (s *liveStream) end() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// liveStreamEvents streams a live job's partial transcript as server-sent events: a segments
+// event with every segment so far, more as they are transcribed, and a done event with the
+// finished job. A stream that has already finished only gets the done event
+func liveStreamEvents(c *gin.Context) {
+	value, ok := liveStreams.Load(c.Param("id"))
+	if ok && !tenantOwns(c.Request.Context(), value.(*liveStream).job.TenantID) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Stream not found"})
+		return
+	}
+	if !ok {
+		job, err := getTenantJob(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, errJobNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Stream not found"})
+			return
+		}
+		if err != nil {
+			loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load stream"})
+			return
+		}
+		auditJob(c.Request.Context(), AuditTranscriptionRead, job, "format=events")
+		c.SSEvent("done", job)
+		return
+	}
+	stream := value.(*liveStream)
+	auditJob(c.Request.Context(), AuditTranscriptionRead, stream.job, "format=events")
+	notify, unsubscribe := stream.subscribe()
+	defer unsubscribe()
+
+	// Keep proxies from holding events back
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	sent := 0
+	c.Stream(func(io.Writer) bool {
+		select {
+		case <-notify:
+		case <-stream.done:
+			if segments := stream.since(sent); len(segments) > 0 {
+				c.SSEvent("segments", segments)
+			}
+			c.SSEvent("done", filterJob(stream.job, stream.opts.ProfanityFilter))
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+		segments := stream.since(sent)
+		sent += len(segments)
+		if len(segments) > 0 {
+			c.SSEvent("segments", segments)
+		}
+		return true
+	})
+}
+
+// stopLiveStream stops a live stream and responds with its finished job once the last segment
+// has been transcribed
+func stopLiveStream(c *gin.Context) {
+	value, ok := liveStreams.Load(c.Param("id"))
+	if !ok || !tenantOwns(c.Request.Context(), value.(*liveStream).job.TenantID) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Live stream not found"})
+		return
+	}
+	stream := value.(*liveStream)
+	stream.end()
+
+	select {
+	case <-stream.done:
+		c.JSON(http.StatusOK, filterJob(stream.job, stream.opts.ProfanityFilter))
+	case <-c.Request.Context().Done():
+	}
+}
+
+// stopLiveStreams asks every live stream to stop, so shutdown can wait for them to wrap up
+// instead of canceling them
+func stopLiveStreams() {
+	liveStreams.Range(func(_, value any) bool {
+		value.(*liveStream).end()
+		return true
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// localRoot is a directory file:// inputs may be read from
+type localRoot struct {
+	path string
+	root *os.Root
+}
+
+// localRoots are opened once at startup from TRANSCRIBER_LOCAL_ROOTS. Files are opened through
+// os.Root, so neither ".." nor a symlink can reach outside them, even if one is swapped in while
+// the path is being checked
+var localRoots []localRoot
+
+// initLocalRoots opens the directories file:// inputs may be read from
+func initLocalRoots(cfg Config) error {
+	for _, dir := range cfg.LocalRoots {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		root, err := os.OpenRoot(abs)
+		if err != nil {
+			return err
+		}
+		localRoots = append(localRoots, localRoot{path: abs, root: root})
+	}
+	return nil
+}
+
+// copyLocalFile copies a file:// input from under one of TRANSCRIBER_LOCAL_ROOTS into jobDir, so
+// the pipeline never changes or removes the original
+func copyLocalFile(ctx context.Context, parsed *url.URL, jobDir string) (string, error) {
+	if len(localRoots) == 0 {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "file:// URLs are disabled: set TRANSCRIBER_LOCAL_ROOTS to allow them"}
+	}
+	root, rel, err := localRootFor(parsed)
+	if err != nil {
+		return "", err
+	}
+	return copyFromRoot(ctx, root, rel, jobDir)
+}
+
+// copyFromRoot copies the file at rel under root into jobDir
+func copyFromRoot(ctx context.Context, root localRoot, rel, jobDir string) (string, error) {
+	name := filepath.Join(root.path, rel)
+
+	// Checked before opening too, since opening a named pipe blocks until something writes to it
+	info, err := root.root.Stat(rel)
+	if err == nil && !info.Mode().IsRegular() {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("%s is not a regular file", name)}
+	}
+	file, err := root.root.Open(rel)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", &pipelineError{Status: http.StatusNotFound, Message: fmt.Sprintf("File %s not found", name)}
+		}
+		return "", &pipelineError{Status: http.StatusForbidden, Message: fmt.Sprintf("File %s can't be read", name)}
+	}
+	defer file.Close()
+	if info, err = file.Stat(); err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("%s is not a regular file", name)}
+	}
+	if info.Size() > appConfig.MaxUploadBytes {
+		return "", &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("File too large: maximum size is %d bytes", appConfig.MaxUploadBytes)}
+	}
+	if err := checkDiskSpace(appConfig.WorkDir, info.Size(), appConfig.DiskExpansionFactor); err != nil {
+		return "", &pipelineError{Status: http.StatusInsufficientStorage, Message: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appConfig.DownloadTimeout)
+	defer cancel()
+	outputPath := filepath.Join(jobDir, "file-"+filepath.Base(rel))
+	outputFile, err := createJobFile(outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer outputFile.Close()
+	written, err := io.Copy(outputFile, contextReader{ctx: ctx, r: io.LimitReader(file, appConfig.MaxUploadBytes+1)})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "", &pipelineError{Status: http.StatusGatewayTimeout, Message: "Timed out copying file"}
+		}
+		return "", &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to copy file: " + err.Error()}
+	}
+	if written > appConfig.MaxUploadBytes {
+		return "", &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("File too large: maximum size is %d bytes", appConfig.MaxUploadBytes)}
+	}
+	if err := outputFile.Close(); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// localRootFor finds the root a file:// URL's path is under and the path relative to it. Paths
+// must be absolute, with no "." or ".." segments, so what is checked is what gets opened
+func localRootFor(parsed *url.URL) (localRoot, string, error) {
+	invalid := &pipelineError{Status: http.StatusBadRequest, Message: "file:// URLs must have an absolute path, like file:///mnt/recordings/call.wav"}
+	if parsed.Opaque != "" || (parsed.Host != "" && parsed.Host != "localhost") || !strings.HasPrefix(parsed.Path, "/") {
+		return localRoot{}, "", invalid
+	}
+	if strings.ContainsRune(parsed.Path, 0) || slices.ContainsFunc(strings.Split(parsed.Path, "/"), func(segment string) bool {
+		return segment == "." || segment == ".."
+	}) {
+		return localRoot{}, "", invalid
+	}
+
+	path := filepath.FromSlash(parsed.Path)
+	for _, root := range localRoots {
+		rel, err := filepath.Rel(root.path, path)
+		if err == nil && rel != "." && filepath.IsLocal(rel) {
+			return root, rel, nil
+		}
+	}
+	return localRoot{}, "", &pipelineError{Status: http.StatusForbidden, Message: fmt.Sprintf("%s is not under TRANSCRIBER_LOCAL_ROOTS", parsed.Path)}
+}
+
+// contextReader stops a copy once ctx is canceled, so a file on a slow share can't outlast the
+// job's timeout
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
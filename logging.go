@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Headers carrying the IDs that appear in every log line of a request
+const (
+	requestIDHeader = "X-Request-ID"
+	jobIDHeader     = "X-Job-ID"
+)
+
+// maxRequestIDLength caps client-supplied request IDs so they can't bloat the logs
+const maxRequestIDLength = 128
+
+// initLogging installs the default slog logger in the configured format and level
+func initLogging(config Config) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		slog.Warn("Invalid log level, using info", "level", config.LogLevel)
+		level = slog.LevelInfo
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if strings.EqualFold(config.LogFormat, "json") {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a copy of ctx carrying logger
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger carried by ctx, or the default logger
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// requestLogger tags each request with an ID (the caller's X-Request-ID or a new one), echoes
+// it in the response, and logs the request once it completes
+func requestLogger(c *gin.Context) {
+	requestID := c.GetHeader(requestIDHeader)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = uuid.New().String()
+	}
+	c.Header(requestIDHeader, requestID)
+
+	logger := slog.Default().With("request_id", requestID)
+	c.Request = c.Request.WithContext(withLogger(c.Request.Context(), logger))
+
+	start := time.Now()
+	c.Next()
+
+	attrs := []any{
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"status", c.Writer.Status(),
+		"duration", time.Since(start),
+		"client_ip", c.ClientIP(),
+	}
+	if jobID := c.Writer.Header().Get(jobIDHeader); jobID != "" {
+		attrs = append(attrs, "job_id", jobID)
+	}
+	logger.Info("Request handled", attrs...)
+}
+
+// tagJob adds the job ID to the response headers and to every later log line of the request
+func tagJob(c *gin.Context, jobID string) {
+	c.Header(jobIDHeader, jobID)
+	ctx := c.Request.Context()
+	c.Request = c.Request.WithContext(withLogger(ctx, loggerFrom(ctx).With("job_id", jobID)))
+}
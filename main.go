@@ -1,30 +1,21 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"mime/multipart"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"strconv"
-	"strings"
-	"sync"
-	"time"
+	"syscall"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-)
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"google.golang.org/grpc"
 
-// TranscriptionResponse represents the response from Groq API
-type TranscriptionResponse struct {
-	Text string `json:"text"`
-}
+	"audio-transcriber/pkg/transcriber"
+)
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
@@ -33,374 +24,384 @@ type ErrorResponse struct {
 
 // SuccessResponse represents a successful transcription response
 type SuccessResponse struct {
+	JobID         string `json:"job_id"`
 	Transcription string `json:"transcription"`
-}
+	ReadableText  string `json:"readable_text,omitempty"`
+	Summary       string `json:"summary,omitempty"`
+	SummaryError  string `json:"summary_error,omitempty"`
 
-func main() {
-	r := gin.Default()
+	// Translation is the transcript in the language asked for with translate_to, and
+	// TranslationError says why it couldn't be made
+	Translation      *Translation `json:"translation,omitempty"`
+	TranslationError string       `json:"translation_error,omitempty"`
 
-	// Configure CORS
-	config := cors.DefaultConfig()
+	// SpeakersError says why enrolled speakers couldn't be identified with identify_speakers, and
+	// SentimentError why segments couldn't be rated with sentiment
+	SpeakersError  string `json:"speakers_error,omitempty"`
+	SentimentError string `json:"sentiment_error,omitempty"`
+
+	// Skipped are the stretches of music and silence skip_non_speech left out, and SilenceRemoved
+	// how many seconds of silence remove_silence cut
+	Skipped        []transcriber.NonSpeech `json:"skipped,omitempty"`
+	SilenceRemoved float64                 `json:"silence_removed_seconds,omitempty"`
 
-	// I tested this from my Vite/Vue app
-	config.AllowOrigins = []string{"http://localhost:5173"}
-	config.AllowMethods = []string{"GET", "POST", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	r.Use(cors.New(config))
+	// Events are the sounds other than speech tagged with audio_events, and EventsError says why
+	// they couldn't be
+	Events      []transcriber.AudioEvent `json:"events,omitempty"`
+	EventsError string                   `json:"events_error,omitempty"`
 
-	// Set up routes
-	r.POST("/api/transcribe", transcribeAudio)
+	Keywords      []transcriber.Keyword    `json:"keywords,omitempty"`
+	Chapters      []transcriber.Chapter    `json:"chapters,omitempty"`
+	Corrections   []transcriber.Correction `json:"corrections,omitempty"`
+	LowConfidence int                      `json:"low_confidence_segments,omitempty"`
+	Hallucinated  int                      `json:"hallucinated_segments,omitempty"`
+	Redacted      bool                     `json:"redacted,omitempty"`
+	Cached        bool                     `json:"cached,omitempty"`
+	Source        *SourceMetadata          `json:"source,omitempty"`
+	Usage         *UsageMetadata           `json:"usage,omitempty"`
+	Stats         *transcriber.Stats       `json:"stats,omitempty"`
+	Timings       *JobTimings              `json:"timings,omitempty"`
+	Words         []transcriber.Word       `json:"words,omitempty"`
 
-	// Start server
-	r.Run(":8080")
+	// VideoURL downloads the copy of the video with subtitles, made when the job asked for one,
+	// and SubtitlesError says why it couldn't be made
+	VideoURL       string `json:"video_url,omitempty"`
+	SubtitlesError string `json:"subtitles_error,omitempty"`
+
+	// ResultURLs are presigned download URLs for the transcript in each format stored with
+	// store_results, given instead of the transcript, and ResultsError says why they couldn't be
+	// stored
+	ResultURLs   map[string]string `json:"result_urls,omitempty"`
+	ResultsError string            `json:"results_error,omitempty"`
 }
 
-func transcribeAudio(c *gin.Context) {
-	// Get file from request
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No file provided"})
-		return
-	}
-	defer file.Close()
-
-	tempFiles := []string{}
-	
-	// Create temp directory if it doesn't exist
-	tempDir := os.TempDir()
-	
-	// Save uploaded file to temp location
-	tempRawAudioFile := filepath.Join(tempDir, uuid.New().String()+"-"+header.Filename)
-	tempFile, err := os.Create(tempRawAudioFile)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create temp file"})
-		return
+func main() {
+	// Settings in TRANSCRIBER_CONFIG_FILE go over the environment
+	if err := loadConfigFile(); err != nil {
+		fatal("Unable to read config file", "error", err)
 	}
-	
-	_, err = io.Copy(tempFile, file)
-	tempFile.Close()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save uploaded file"})
-		return
-	}
-	
-	tempFiles = append(tempFiles, tempRawAudioFile)
-	
-	// Preprocess audio file
-	tempPreProcessedAudioFile := filepath.Join(tempDir, uuid.New().String()+"-preprocessed.flac")
-	err = preprocessAudioFile(tempRawAudioFile, tempPreProcessedAudioFile)
-	if err != nil {
-		deleteFiles(tempFiles)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to preprocess audio: " + err.Error()})
-		return
-	}
-	tempFiles = append(tempFiles, tempPreProcessedAudioFile)
-	
-	// Get audio chunk data
-	chunkData, err := getAudioChunkData(tempPreProcessedAudioFile)
-	if err != nil {
-		deleteFiles(tempFiles)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to analyze audio: " + err.Error()})
-		return
+	appConfig = loadConfig()
+	initLogging(appConfig)
+	if err := os.MkdirAll(appConfig.WorkDir, 0o700); err != nil {
+		fatal("Unable to create work directory", "dir", appConfig.WorkDir, "error", err)
+	}
+	if appConfig.AudioDir == "" {
+		appConfig.AudioDir = filepath.Join(appConfig.WorkDir, "retained-audio")
+	}
+	if err := os.MkdirAll(appConfig.AudioDir, 0o700); err != nil {
+		fatal("Unable to create retained audio directory", "dir", appConfig.AudioDir, "error", err)
+	}
+	if appConfig.VideoDir == "" {
+		appConfig.VideoDir = filepath.Join(appConfig.WorkDir, "subtitled-video")
+	}
+	if err := os.MkdirAll(appConfig.VideoDir, 0o700); err != nil {
+		fatal("Unable to create subtitled video directory", "dir", appConfig.VideoDir, "error", err)
+	}
+	if appConfig.MemoryProcessing {
+		if err := os.MkdirAll(appConfig.MemoryDir, 0o700); err != nil {
+			fatal("Unable to create memory work directory", "dir", appConfig.MemoryDir, "error", err)
+		}
+		slog.Info("Processing small jobs in memory", "dir", appConfig.MemoryDir, "limit_bytes", appConfig.MemoryLimitBytes)
 	}
-	
-	// Chunkify audio file
-	chunks, err := chunkifyAudioFile(tempPreProcessedAudioFile, chunkData)
+	checkFFmpeg(appConfig)
+	checkClamAV(appConfig)
+
+	// Read the provider API keys from a secrets manager when one is configured
+	secretStore, err := newSecretStore(appConfig)
 	if err != nil {
-		deleteFiles(tempFiles)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to chunk audio: " + err.Error()})
-		return
-	}
-	tempFiles = append(tempFiles, chunks...)
-	
-	// Transcribe chunks in parallel
-	apiKey := "" // Get your own, friend. :)
-	apiURL := "https://api.groq.com/openai/v1/audio/transcriptions"
-	
-	// Use a WaitGroup to track when all goroutines are done
-	var wg sync.WaitGroup
-
-	// Use a buffered channel as a semaphore to limit concurrency
-	// Process 5 chunks at a time
-	semaphore := make(chan struct{}, 5)
-	
-	// Create a mutex to protect concurrent writes to the results slice
-	var mutex sync.Mutex
-	transcriptionResults := make([]string, len(chunks))
-	
-	for i, chunk := range chunks {
-		wg.Add(1)
-		go func(i int, chunkPath string) {
-			defer wg.Done()
-			
-			// Acquire a token from the semaphore
-			semaphore <- struct{}{}
-
-			// Release the token when done
-			defer func() { <-semaphore }()
-			
-			transcriptionText, err := transcribeChunk(chunkPath, apiURL, apiKey)
-			
-			mutex.Lock()
-			if err != nil {
-				log.Printf("Error transcribing chunk %d: %v", i, err)
-				transcriptionResults[i] = ""
-			} else {
-				transcriptionResults[i] = transcriptionText
-			}
-			mutex.Unlock()
-		}(i, chunk)
-	}
-	
-	// Wait for all transcription tasks to complete
-	wg.Wait()
-	
-	// Filter out empty (failed) transcriptions and combine
-	var validTranscriptions []string
-	for _, text := range transcriptionResults {
-		if text != "" {
-			validTranscriptions = append(validTranscriptions, text)
+		fatal("Invalid secrets manager configuration", "error", err)
+	}
+	if secretStore != nil {
+		if _, err := fetchSecret(context.Background(), secretStore); err != nil {
+			fatal("Unable to read secrets", "backend", appConfig.SecretsBackend, "error", err)
 		}
 	}
-	
-	// Clean up temp files
-	deleteFiles(tempFiles)
-	
-	// Return the combined transcription
-	c.JSON(http.StatusOK, SuccessResponse{Transcription: strings.Join(validTranscriptions, "")})
-}
 
-func preprocessAudioFile(inputFilePath, outputFilePath string) error {
-	cmd := exec.Command(
-		"ffmpeg",
-		"-i", inputFilePath,
-		"-ar", "16000",
-		"-ac", "1",
-		"-c:a", "flac",
-		"-map", "0:a",
-		outputFilePath,
-	)
-	
-	return cmd.Run()
-}
+	if err := initProviders(appConfig); err != nil {
+		fatal("Invalid transcription provider", "error", err)
+	}
+	initJobQueue(int(appConfig.Workers), int(appConfig.QueueSize), appConfig.PriorityShares)
+	initCostRates(appConfig.CostPerMinute)
+	if err := initSummaryPrompt(appConfig.SummaryPrompt); err != nil {
+		fatal("Invalid summary prompt template", "error", err)
+	}
+	if err := checkTranslationBackend(appConfig); err != nil {
+		fatal("Invalid translation backend", "error", err)
+	}
+	if err := checkResultsConfig(appConfig); err != nil {
+		fatal("Invalid results storage configuration", "error", err)
+	}
+	if err := initProfanityFilter(appConfig.ProfanityWordlist); err != nil {
+		fatal("Unable to load profanity wordlist", "path", appConfig.ProfanityWordlist, "error", err)
+	}
+	if err := initDictionary(appConfig.Dictionary); err != nil {
+		fatal("Unable to load dictionary", "path", appConfig.Dictionary, "error", err)
+	}
 
-// ChunkData represents information about audio chunks
-type ChunkData struct {
-	DurationMs float64
-	ChunkMs    float64
-	OverlapMs  float64
-	TotalChunks int
-}
+	// Keys of encrypted jobs never leave this instance and are gone after a restart, so another
+	// worker couldn't read a job's files and nothing could resume it
+	if appConfig.EncryptTempFiles {
+		if appConfig.RedisURL != "" {
+			fatal("TRANSCRIBER_ENCRYPT_TEMP_FILES can't be used with TRANSCRIBER_REDIS_URL")
+		}
+		if appConfig.ResumeJobs {
+			slog.Info("Resuming interrupted jobs is disabled while temp files are encrypted")
+			appConfig.ResumeJobs = false
+		}
+	}
 
-func getAudioChunkData(filePath string) (ChunkData, error) {
-	// Set default chunk parameters in seconds
-	chunkLength := 120.0
-	overlap := 1.0
-	
-	// Run ffprobe to get audio duration
-	cmd := exec.Command(
-		"ffprobe",
-		"-v", "error",
-		"-show_entries", "format=duration",
-		"-of", "json",
-		filePath,
-	)
-	
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		return ChunkData{}, err
+	// Run a one-off transcription or benchmark instead of the server when asked
+	if len(os.Args) > 1 && os.Args[1] == "transcribe" {
+		os.Exit(runTranscribeCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "benchmark" {
+		os.Exit(runBenchmarkCommand(os.Args[2:]))
 	}
-	
-	// Parse the JSON output
-	var result struct {
-		Format struct {
-			Duration string `json:"duration"`
-		} `json:"format"`
+
+	if err := initTenants(appConfig); err != nil {
+		fatal("Unable to load tenants", "path", appConfig.TenantsFile, "error", err)
+	}
+	if err := initProfiles(appConfig); err != nil {
+		fatal("Unable to load profiles", "path", appConfig.ProfilesFile, "error", err)
+	}
+	if err := checkTwilioConfig(appConfig); err != nil {
+		fatal("Invalid Twilio configuration", "error", err)
+	}
+	if err := checkZoomConfig(appConfig); err != nil {
+		fatal("Invalid Zoom configuration", "error", err)
+	}
+	if err := initLocalRoots(appConfig); err != nil {
+		fatal("Unable to open TRANSCRIBER_LOCAL_ROOTS", "error", err)
+	}
+	if err := initWatchDirs(appConfig); err != nil {
+		fatal("Invalid watch folder configuration", "error", err)
 	}
-	
-	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
-		return ChunkData{}, err
+	if err := initSchedules(appConfig); err != nil {
+		fatal("Unable to load schedules", "path", appConfig.SchedulesFile, "error", err)
 	}
-	
-	duration, err := strconv.ParseFloat(result.Format.Duration, 64)
+
+	store, err := openJobStore(appConfig.DatabaseDriver, appConfig.DatabaseDSN)
 	if err != nil {
-		return ChunkData{}, fmt.Errorf("unable to parse duration: %w", err)
-	}
-	
-	durationMs := duration * 1000
-	chunkMs := chunkLength * 1000
-	overlapMs := overlap * 1000
-	totalChunks := int(durationMs/(chunkMs-overlapMs)) + 1
-	
-	return ChunkData{
-		DurationMs:  durationMs,
-		ChunkMs:     chunkMs,
-		OverlapMs:   overlapMs,
-		TotalChunks: totalChunks,
-	}, nil
-}
+		fatal("Unable to open job database", "error", err)
+	}
+	defer store.Close()
+	jobStore = store
 
-func chunkifyAudioFile(filePath string, chunkData ChunkData) ([]string, error) {
-	chunkIdentifier := uuid.New().String()
-	chunks := make([]string, 0, chunkData.TotalChunks)
-	
-	// Use a WaitGroup to track when all goroutines are done
-	var wg sync.WaitGroup
-
-	// Create a buffered channel as a semaphore for concurrency control
-	numCPU := len(os.Getenv("GOMAXPROCS"))
-	if numCPU <= 0 {
-		numCPU = 4 // Default to 4 if GOMAXPROCS is not set
-	}
-	semaphore := make(chan struct{}, numCPU)
-	
-	// Create a mutex to protect concurrent writes to the chunks slice
-	var mutex sync.Mutex
-	var errors []string
-	
-	tempDir := os.TempDir()
-	
-	for i := 0; i < chunkData.TotalChunks; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			
-			// Acquire a token from the semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-			
-			startMs := float64(i) * (chunkData.ChunkMs - chunkData.OverlapMs)
-			endMs := startMs + chunkData.ChunkMs
-			if endMs > chunkData.DurationMs {
-				endMs = chunkData.DurationMs
-			}
-			
-			segmentDurationSec := (endMs - startMs) / 1000
-			startSec := startMs / 1000
-			
-			outputPath := filepath.Join(tempDir, fmt.Sprintf("%s_%d.flac", chunkIdentifier, i+1))
-			
-			err := createAudioChunkFile(filePath, outputPath, startSec, segmentDurationSec)
-			
-			mutex.Lock()
-			if err != nil {
-				errors = append(errors, fmt.Sprintf("Error creating chunk %d: %v", i, err))
-			} else {
-				chunks = append(chunks, outputPath)
-			}
-			mutex.Unlock()
-		}(i)
-	}
-	
-	// Wait for all chunk creation tasks to complete
-	wg.Wait()
-	
-	if len(errors) > 0 {
-		return chunks, fmt.Errorf("some chunks failed: %s", strings.Join(errors, "; "))
-	}
-	
-	return chunks, nil
-}
+	// SIGINT or SIGTERM starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-func createAudioChunkFile(filePath, outputPath string, startSeconds, duration float64) error {
-	cmd := exec.Command(
-		"ffmpeg",
-		"-i", filePath,
-		"-ss", fmt.Sprintf("%f", startSeconds),
-		"-t", fmt.Sprintf("%f", duration),
-		outputPath,
-	)
-	
-	return cmd.Run()
-}
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := initTracing(ctx)
+	if err != nil {
+		fatal("Unable to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Pick up rotated API keys in the background
+	if secretStore != nil && appConfig.SecretsRefresh > 0 {
+		go refreshSecrets(ctx, secretStore, appConfig.SecretsRefresh)
+	}
+
+	// Purge old transcripts in the background when a retention period is configured
+	if retentionEnabled(appConfig.RetentionTTL) {
+		go runRetention(ctx, appConfig.RetentionTTL, appConfig.RetentionInterval)
+	}
 
-func transcribeChunk(chunkPath, apiURL, apiKey string) (string, error) {
-	// Create a buffer to store our request body as bytes
-	var requestBody bytes.Buffer
-	
-	// Create a multipart writer
-	multipartWriter := multipart.NewWriter(&requestBody)
-	
-	// Add the file
-	fileWriter, err := multipartWriter.CreateFormFile("file", "chunk.flac")
+	// Retry webhook posts that failed
+	go runWebhookRetries(ctx)
+
+	// SIGHUP reloads the settings that can change without a restart
+	go reloadOnSignal(ctx)
+
+	// Publish completed jobs to Kafka when brokers are configured
+	kafkaWriter, err = newKafkaWriter(appConfig)
 	if err != nil {
-		return "", err
+		fatal("Invalid Kafka configuration", "error", err)
+	}
+	if kafkaWriter != nil {
+		defer closeKafka()
 	}
-	
-	// Open the file
-	file, err := os.Open(chunkPath)
+
+	// Share the job queue with other instances when Redis is configured
+	if appConfig.RedisURL != "" {
+		distQueue, err = newRedisQueue(ctx, appConfig.RedisURL)
+		if err != nil {
+			fatal("Unable to connect to Redis", "error", err)
+		}
+		defer distQueue.Close()
+		if appConfig.QueueWorker {
+			distQueue.start(ctx, int(appConfig.Workers))
+		}
+	}
+
+	// Pick up the jobs a crash or restart interrupted. With a shared queue, Redis hands them to
+	// another worker instead
+	if appConfig.ResumeJobs && distQueue == nil {
+		resumeInterruptedJobs()
+	}
+
+	// Take transcription requests from NATS or AMQP when a bus is configured
+	bus, err = newMessageBus(appConfig)
 	if err != nil {
-		return "", err
+		fatal("Unable to connect to the message bus", "error", err)
 	}
-	defer file.Close()
-	
-	// Copy the file data to the form
-	if _, err = io.Copy(fileWriter, file); err != nil {
-		return "", err
+	if bus != nil {
+		defer closeBus()
+		go runBus(ctx, int(appConfig.Workers))
 	}
-	
-	// Add other form fields
-	if err = multipartWriter.WriteField("model", "distil-whisper-large-v3-en"); err != nil {
-		return "", err
+
+	// Transcribe recordings dropped into watched directories
+	if len(watchRoots) > 0 {
+		go runWatcher(ctx)
 	}
-	if err = multipartWriter.WriteField("temperature", "0"); err != nil {
-		return "", err
+
+	// Start recurring runs from the schedules file
+	runSchedules(ctx)
+
+	// Clear out the scratch files of jobs a crash or kill cut short, once resumed jobs have
+	// claimed theirs
+	if appConfig.OrphanMaxAge > 0 {
+		go runOrphanSweeper(ctx, appConfig.OrphanMaxAge, appConfig.OrphanSweepInterval)
 	}
-	if err = multipartWriter.WriteField("response_format", "verbose_json"); err != nil {
-		return "", err
+
+	// Serve gRPC alongside REST when an address is configured
+	var grpcSrv *grpc.Server
+	if appConfig.GRPCAddr != "" {
+		grpcSrv, err = startGRPCServer(appConfig.GRPCAddr)
+		if err != nil {
+			fatal("Unable to start gRPC server", "addr", appConfig.GRPCAddr, "error", err)
+		}
 	}
-	if err = multipartWriter.WriteField("language", "en"); err != nil {
-		return "", err
+
+	r := gin.New()
+
+	// Only believe forwarded client IPs from proxies we've been told about
+	if err := configureTrustedProxies(r, appConfig); err != nil {
+		fatal("Unable to configure trusted proxies", "error", err)
 	}
-	
-	// Close the multipart writer to set the terminating boundary
-	if err = multipartWriter.Close(); err != nil {
-		return "", err
+	r.Use(requestLogger, auditContext, metricsMiddleware)
+	r.Use(otelgin.Middleware(tracingServiceName))
+
+	// Recover inside the logging, metrics, and tracing middleware so a panicked request is
+	// recorded as the 500 it is answered with
+	r.Use(recoverPanics)
+
+	// Configure CORS
+	if err := initCORS(appConfig); err != nil {
+		fatal("Unable to configure CORS", "error", err)
 	}
-	
-	// Create the request
-	req, err := http.NewRequest("POST", apiURL, &requestBody)
-	if err != nil {
-		return "", err
-	}
-	
-	// Set headers
-	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	
-	// Set timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	
-	// Make the request
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-	
-	// Parse response
-	var result TranscriptionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
-	
-	return result.Text, nil
-}
+	r.Use(handleCORS)
+
+	if err := initCompression(appConfig); err != nil {
+		fatal("Unable to configure response compression", "error", err)
+	}
+
+	// Liveness and readiness probes
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", readyz)
+	r.GET("/metrics", metricsHandler)
+
+	// API description for generating clients, and a UI for browsing it
+	r.GET("/api/openapi.json", serveOpenAPI)
+	r.GET("/docs", serveDocs)
+
+	// A page for transcribing files from the browser without a separate frontend
+	if appConfig.WebUI {
+		r.GET("/", serveWebUI)
+	}
+
+	// A dashboard over the admin endpoints
+	r.GET("/admin", serveAdminDashboard)
 
-func deleteFiles(files []string) {
-	for _, file := range files {
-		if err := os.Remove(file); err != nil {
-			log.Printf("Error deleting file %s: %v", file, err)
+	// Set up routes, behind a tenant's API key when TRANSCRIBER_TENANTS_FILE is set. Routes that
+	// return transcripts compress them for clients that accept it
+	api := r.Group("/api", tenantAuth)
+	api.POST("/transcribe", compressResponse, transcribeAudio)
+	api.PUT("/transcribe", compressResponse, transcribeAudio)
+	api.POST("/transcribe/url", compressResponse, transcribeURL)
+	api.POST("/transcribe/batch", transcribeBatch)
+	api.POST("/feeds", transcribeFeed)
+	api.GET("/batches/:id", compressResponse, getBatch)
+	api.POST("/align", alignTranscript)
+	api.POST("/detect-language", detectLanguage)
+	api.POST("/analyze", analyzeAudio)
+	api.POST("/compare", compressResponse, compareModels)
+	api.POST("/estimate", estimateTranscription)
+	api.GET("/models", listModels)
+	api.GET("/profiles", listProfiles)
+	api.POST("/streams", startLiveStream)
+	api.GET("/streams/:id/events", liveStreamEvents)
+	api.DELETE("/streams/:id", stopLiveStream)
+	api.GET("/transcriptions", compressResponse, listTranscriptions)
+	api.GET("/search", compressResponse, searchTranscriptions)
+	api.GET("/transcriptions/:id", compressResponse, getTranscription)
+	api.PATCH("/transcriptions/:id", correctTranscription)
+	api.DELETE("/transcriptions/:id", deleteTranscription)
+	api.POST("/transcriptions/:id/cancel", cancelTranscription)
+	api.POST("/transcriptions/:id/retranscribe", compressResponse, retranscribeTranscription)
+	api.POST("/transcriptions/:id/rerun", compressResponse, retranscribeTranscription)
+	api.GET("/transcriptions/:id/video", getSubtitledVideo)
+	api.GET("/transcriptions/:id/events", transcriptionEvents)
+	api.GET("/speakers", listSpeakers)
+	api.POST("/speakers", enrollSpeaker)
+	api.POST("/speakers/:id/samples", addSpeakerSample)
+	api.DELETE("/speakers/:id", deleteSpeaker)
+
+	// Twilio's recording callbacks, verified by their signature rather than an API key
+	if appConfig.TwilioAuthToken != "" {
+		r.POST("/api/twilio/recordings", twilioRecording)
+	}
+
+	// Zoom's recording webhooks, verified by their signature rather than an API key
+	if appConfig.ZoomSecretToken != "" {
+		r.POST("/api/zoom/recordings", zoomRecordingCompleted)
+	}
+
+	// Operational endpoints, behind TRANSCRIBER_ADMIN_TOKEN
+	admin := r.Group("/api/admin", adminAuth)
+	admin.GET("/stats", adminStats)
+	admin.GET("/throughput", adminThroughput)
+	admin.GET("/jobs", adminJobs)
+	admin.POST("/jobs/:id/cancel", adminCancelJob)
+	admin.POST("/jobs/:id/retry", adminRetryJob)
+	admin.GET("/audit", adminAudit)
+	admin.GET("/webhooks", adminWebhookDeliveries)
+	admin.POST("/webhooks/:id/retry", adminRetryWebhook)
+	admin.GET("/schedules", adminSchedules)
+	admin.POST("/reload", adminReloadConfig)
+
+	// Resumable uploads (tus protocol)
+	uploads := api.Group("/uploads", tusMiddleware)
+	uploads.OPTIONS("", tusOptions)
+	uploads.POST("", tusCreate)
+	uploads.HEAD("/:id", tusHead)
+	uploads.PATCH("/:id", tusPatch)
+	uploads.DELETE("/:id", tusDelete)
+
+	// Start server, over HTTPS when certificates are configured
+	srv := &http.Server{Addr: appConfig.ListenAddr, Handler: r}
+	redirectSrv, err := configureTLS(srv, appConfig)
+	if err != nil {
+		fatal("Unable to configure TLS", "error", err)
+	}
+	go func() {
+		if err := listenAndServe(srv, appConfig); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server error", "error", err)
 		}
+	}()
+	if redirectSrv != nil {
+		defer redirectSrv.Close()
+		go func() {
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("HTTP redirect server error", "addr", redirectSrv.Addr, "error", err)
+			}
+		}()
 	}
-}
\ No newline at end of file
+
+	// Wait for a signal, then let in-flight jobs finish before exiting
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down; waiting for in-flight jobs", "timeout", appConfig.ShutdownTimeout)
+	shutdown(srv, grpcSrv, appConfig.ShutdownTimeout)
+}
@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+// useTestConfig loads the configuration from the environment with a work directory and a Groq key
+// for the test, and sets up the providers from it
+func useTestConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("GROQ_API_KEY", "test-key")
+	t.Setenv("TRANSCRIBER_WORK_DIR", t.TempDir())
+	appConfig = loadConfig()
+	if err := initProviders(appConfig); err != nil {
+		t.Fatalf("initProviders: %v", err)
+	}
+}
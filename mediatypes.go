@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// sniffBytes is how much of a file is read to recognize its container
+const sniffBytes = 64
+
+// mediaSignatures recognize containers by their leading bytes, checked in order. Offset is where
+// magic starts
+var mediaSignatures = []struct {
+	container string
+	offset    int
+	magic     []byte
+}{
+	{"flac", 0, []byte("fLaC")},
+	{"ogg", 0, []byte("OggS")},
+	{"mp3", 0, []byte("ID3")},
+	{"matroska", 0, []byte{0x1a, 0x45, 0xdf, 0xa3}},
+	{"asf", 0, []byte{0x30, 0x26, 0xb2, 0x75, 0x8e, 0x66, 0xcf, 0x11}},
+	{"amr", 0, []byte("#!AMR")},
+	{"caf", 0, []byte("caff")},
+	{"mp4", 4, []byte("ftyp")},
+	{"mp4", 4, []byte("moov")},
+	{"mp4", 4, []byte("mdat")},
+	{"mp4", 4, []byte("wide")},
+	{"mp4", 4, []byte("free")},
+}
+
+// sniffContainer names the container of a file from its first bytes, or returns "" when it isn't
+// one it knows
+func sniffContainer(head []byte) string {
+	if len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) {
+		switch string(head[8:12]) {
+		case "WAVE":
+			return "wav"
+		case "AVI ":
+			return "avi"
+		}
+	}
+	if len(head) >= 12 && bytes.Equal(head[:4], []byte("FORM")) && (bytes.Equal(head[8:12], []byte("AIFF")) || bytes.Equal(head[8:12], []byte("AIFC"))) {
+		return "aiff"
+	}
+	for _, signature := range mediaSignatures {
+		end := signature.offset + len(signature.magic)
+		if len(head) >= end && bytes.Equal(head[signature.offset:end], signature.magic) {
+			return signature.container
+		}
+	}
+	// MPEG audio frames start with 11 set sync bits; layer 0 there means an ADTS AAC stream
+	if len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0 {
+		if head[1]&0x06 == 0 {
+			return "aac"
+		}
+		return "mp3"
+	}
+	return ""
+}
+
+// mediaExtensions are the extensions of the recordings picked up from watched directories and
+// scheduled prefixes unless configured otherwise
+var mediaExtensions = []string{"mp3", "wav", "m4a", "flac", "ogg", "opus", "aac", "webm", "mp4", "mov", "mkv"}
+
+// checkUploadExtension rejects an uploaded file whose extension isn't in
+// TRANSCRIBER_ALLOWED_EXTENSIONS, before any of it is saved. Names without an extension are left
+// to the container check
+func checkUploadExtension(filename string) error {
+	if len(appConfig.AllowedExtensions) == 0 {
+		return nil
+	}
+	extension := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if extension == "" || slices.Contains(appConfig.AllowedExtensions, extension) {
+		return nil
+	}
+	return &pipelineError{
+		Status:  http.StatusUnsupportedMediaType,
+		Stage:   transcriber.StageValidate,
+		Message: fmt.Sprintf("Unsupported file type %q: accepted extensions are %s", filepath.Base(filename), strings.Join(appConfig.AllowedExtensions, ", ")),
+	}
+}
+
+// checkMediaContainer rejects media whose sniffed container isn't in
+// TRANSCRIBER_ALLOWED_CONTAINERS, before ffmpeg or ffprobe reads it
+func checkMediaContainer(path string) error {
+	if len(appConfig.AllowedContainers) == 0 {
+		return nil
+	}
+	file, err := openJobFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+
+	container := sniffContainer(head[:n])
+	if container != "" && slices.Contains(appConfig.AllowedContainers, container) {
+		return nil
+	}
+	detected := container
+	if detected == "" {
+		detected = "unrecognized"
+	}
+	return &pipelineError{
+		Status:  http.StatusUnsupportedMediaType,
+		Stage:   transcriber.StageValidate,
+		Message: fmt.Sprintf("Unsupported media format (%s): accepted formats are %s", detected, strings.Join(appConfig.AllowedContainers, ", ")),
+	}
+}
+
+// lowerList lowercases configured names and strips the leading dot of extensions
+func lowerList(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		lowered = append(lowered, strings.ToLower(strings.TrimPrefix(value, ".")))
+	}
+	return lowered
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// memoryWorkUsed is how many bytes of TRANSCRIBER_MEMORY_LIMIT_BYTES the jobs processing in
+// memory have reserved
+var (
+	memoryWorkMu   sync.Mutex
+	memoryWorkUsed int64
+)
+
+// reserveMemoryWorkDir creates a directory in TRANSCRIBER_MEMORY_DIR for the intermediate audio of
+// the job in jobDir, and returns it with a func that removes it and releases its reservation. It
+// returns "" when memory processing is off or the job doesn't fit in what is left of the limit,
+// and the job works in jobDir as usual
+func reserveMemoryWorkDir(ctx context.Context, jobDir, inputPath string) (string, func()) {
+	if !appConfig.MemoryProcessing {
+		return "", nil
+	}
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return "", nil
+	}
+	reserved := int64(float64(info.Size()) * appConfig.DiskExpansionFactor)
+
+	memoryWorkMu.Lock()
+	if memoryWorkUsed+reserved > appConfig.MemoryLimitBytes {
+		memoryWorkMu.Unlock()
+		loggerFrom(ctx).Debug("Processing on disk, the job doesn't fit in the memory limit", "reserved", reserved)
+		return "", nil
+	}
+	memoryWorkUsed += reserved
+	memoryWorkMu.Unlock()
+	memoryWorkBytes.Add(float64(reserved))
+
+	release := func() {
+		memoryWorkMu.Lock()
+		memoryWorkUsed -= reserved
+		memoryWorkMu.Unlock()
+		memoryWorkBytes.Sub(float64(reserved))
+	}
+
+	memoryDir := filepath.Join(appConfig.MemoryDir, "transcriber-"+filepath.Base(jobDir))
+	if err := os.MkdirAll(memoryDir, 0o700); err != nil {
+		release()
+		loggerFrom(ctx).Warn("Unable to create memory work directory, processing on disk", "dir", memoryDir, "error", err)
+		return "", nil
+	}
+	return memoryDir, func() {
+		if err := os.RemoveAll(memoryDir); err != nil {
+			slog.Error("Error removing memory work directory", "dir", memoryDir, "error", err)
+		}
+		release()
+	}
+}
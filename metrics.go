@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// durationBuckets spans quick API calls up to hour-long transcriptions
+var durationBuckets = prometheus.ExponentialBuckets(0.05, 2.5, 12)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "transcriber_http_requests_total",
+		Help: "HTTP requests handled, by route, method, and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcriber_http_request_duration_seconds",
+		Help:    "Time spent handling HTTP requests, by route and method.",
+		Buckets: durationBuckets,
+	}, []string{"route", "method"})
+
+	jobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "transcriber_jobs_total",
+		Help: "Jobs finished, by final status.",
+	}, []string{"status"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcriber_job_duration_seconds",
+		Help:    "Time from job creation to completion, by final status.",
+		Buckets: durationBuckets,
+	}, []string{"status"})
+
+	jobsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "transcriber_jobs_in_flight",
+		Help: "Jobs currently holding a scratch directory.",
+	})
+
+	memoryWorkBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "transcriber_memory_work_bytes",
+		Help: "Bytes of TRANSCRIBER_MEMORY_LIMIT_BYTES reserved by jobs processing in memory.",
+	})
+
+	jobsQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "transcriber_jobs_queued",
+		Help: "Jobs waiting for a free pipeline worker, by priority.",
+	}, []string{"priority"})
+
+	stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcriber_stage_duration_seconds",
+		Help:    "Time spent in each pipeline stage (validate, preprocess, hash, analyze, chunk, transcribe).",
+		Buckets: durationBuckets,
+	}, []string{"stage"})
+
+	upstreamRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "transcriber_upstream_request_duration_seconds",
+		Help:    "Latency of per-chunk requests to the transcription API.",
+		Buckets: durationBuckets,
+	})
+
+	upstreamRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "transcriber_upstream_requests_total",
+		Help: "Per-chunk requests to the transcription API, by HTTP status code (\"error\" when no response was received).",
+	}, []string{"code"})
+)
+
+func init() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "transcriber_upstream_requests_in_flight",
+		Help: "Requests to the provider holding one of the TRANSCRIBER_MAX_CONCURRENT_REQUESTS slots.",
+	}, func() float64 {
+		return float64(requestLimiter.InFlight())
+	})
+
+	// Scratch space is measured on scrape rather than tracked on every write
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "transcriber_work_dir_used_bytes",
+		Help: "Bytes used by job directories and pending uploads in the work directory.",
+	}, func() float64 {
+		return float64(workDirUsage(appConfig.WorkDir))
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "transcriber_work_dir_available_bytes",
+		Help: "Free space on the filesystem holding the work directory.",
+	}, func() float64 {
+		available, err := availableDiskSpace(appConfig.WorkDir)
+		if err != nil {
+			return 0
+		}
+		return float64(available)
+	})
+}
+
+// metricsHandler serves the Prometheus metrics
+var metricsHandler = gin.WrapH(promhttp.Handler())
+
+// metricsMiddleware records the count and latency of every request by its route pattern
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	// Unmatched paths share a label so scanners can't blow up the series count
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequestsTotal.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+	httpRequestDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+}
+
+// observeJob records a finished job
+func observeJob(job *Job) {
+	jobsTotal.WithLabelValues(job.Status).Inc()
+	if job.CompletedAt != nil {
+		jobDuration.WithLabelValues(job.Status).Observe(job.CompletedAt.Sub(job.CreatedAt).Seconds())
+	}
+}
+
+// pipelineMetrics feeds the transcriber's measurements into Prometheus
+type pipelineMetrics struct{}
+
+func (pipelineMetrics) ObserveStage(stage transcriber.Stage, duration time.Duration) {
+	stageDuration.WithLabelValues(string(stage)).Observe(duration.Seconds())
+}
+
+func (pipelineMetrics) ObserveChunkRequest(duration time.Duration, statusCode int) {
+	upstreamRequestDuration.Observe(duration.Seconds())
+	code := "error"
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	upstreamRequestsTotal.WithLabelValues(code).Inc()
+}
+
+// workDirUsage sums the size of every job directory and pending upload under workDir
+func workDirUsage(workDir string) int64 {
+	roots, _ := filepath.Glob(filepath.Join(workDir, "job-*"))
+	roots = append(roots, filepath.Join(workDir, "uploads"))
+
+	var total int64
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil && !entry.IsDir() {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
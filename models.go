@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelsResponse describes the providers and models requests may pick
+type ModelsResponse struct {
+	DefaultProvider string         `json:"default_provider"`
+	Providers       []ProviderInfo `json:"providers"`
+}
+
+// ProviderInfo is a configured provider and its allowed models
+type ProviderInfo struct {
+	Name    string      `json:"name"`
+	Default bool        `json:"default"`
+	Models  []ModelInfo `json:"models"`
+}
+
+// ModelInfo is an allowed model and what it can do
+type ModelInfo struct {
+	ID        string        `json:"id"`
+	Default   bool          `json:"default"`
+	Languages []string      `json:"languages"`
+	Features  ModelFeatures `json:"features"`
+}
+
+// ModelFeatures are the capabilities a model itself supports
+type ModelFeatures struct {
+	Diarization    bool `json:"diarization"`
+	Translation    bool `json:"translation"`
+	WordTimestamps bool `json:"word_timestamps"`
+}
+
+// modelCapability is what is known about a model
+type modelCapability struct {
+	Languages []string
+	Features  ModelFeatures
+}
+
+// whisperLanguages are the ISO-639-1 codes of every language the multilingual Whisper models know
+var whisperLanguages = []string{
+	"af", "am", "ar", "as", "az", "ba", "be", "bg", "bn", "bo", "br", "bs", "ca", "cs", "cy", "da",
+	"de", "el", "en", "es", "et", "eu", "fa", "fi", "fo", "fr", "gl", "gu", "ha", "haw", "he", "hi",
+	"hr", "ht", "hu", "hy", "id", "is", "it", "ja", "jw", "ka", "kk", "km", "kn", "ko", "la", "lb",
+	"ln", "lo", "lt", "lv", "mg", "mi", "mk", "ml", "mn", "mr", "ms", "mt", "my", "ne", "nl", "nn",
+	"no", "oc", "pa", "pl", "ps", "pt", "ro", "ru", "sa", "sd", "si", "sk", "sl", "sn", "so", "sq",
+	"sr", "su", "sv", "sw", "ta", "te", "tg", "th", "tk", "tl", "tr", "tt", "uk", "ur", "uz", "vi",
+	"yi", "yo", "zh",
+}
+
+// modelCapabilities are keyed by "provider:model"; allowed models missing from it are listed with
+// no languages or features
+var modelCapabilities = map[string]modelCapability{
+	"groq:distil-whisper-large-v3-en": {
+		Languages: []string{"en"},
+		Features:  ModelFeatures{WordTimestamps: true},
+	},
+	"groq:whisper-large-v3": {
+		Languages: whisperLanguages,
+		Features:  ModelFeatures{Translation: true, WordTimestamps: true},
+	},
+	"groq:whisper-large-v3-turbo": {
+		Languages: whisperLanguages,
+		Features:  ModelFeatures{WordTimestamps: true},
+	},
+	"openai:whisper-1": {
+		Languages: whisperLanguages,
+		Features:  ModelFeatures{Translation: true, WordTimestamps: true},
+	},
+}
+
+// listModels reports the providers configured for the caller, the models each allows, and their
+// capabilities so clients can offer only the choices the server will accept
+func listModels(c *gin.Context) {
+	providers := providersFor(tenantFrom(c.Request.Context()))
+	response := ModelsResponse{DefaultProvider: providers.defaultProvider, Providers: []ProviderInfo{}}
+	for _, provider := range providerNames() {
+		t, ok := providers.transcribers[provider]
+		if !ok {
+			continue
+		}
+
+		info := ProviderInfo{Name: provider, Default: provider == providers.defaultProvider, Models: []ModelInfo{}}
+		for _, model := range providers.allowedModels[provider] {
+			capability := modelCapabilities[provider+":"+model]
+			languages := capability.Languages
+			if languages == nil {
+				languages = []string{}
+			}
+			info.Models = append(info.Models, ModelInfo{
+				ID:        model,
+				Default:   model == t.Model(),
+				Languages: languages,
+				Features:  capability.Features,
+			})
+		}
+		response.Providers = append(response.Providers, info)
+	}
+	c.JSON(http.StatusOK, response)
+}
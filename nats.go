@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsBus consumes requests from a NATS subject as a member of busQueueGroup. Core NATS keeps no
+// messages, so a request published while no instance is subscribed is dropped
+type natsBus struct {
+	conn *nats.Conn
+}
+
+// newNATSBus connects to the NATS server, retrying for as long as the connection is down
+func newNATSBus(cfg Config) (*natsBus, error) {
+	conn, err := nats.Connect(cfg.BusURL,
+		nats.Name(busQueueGroup),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			slog.Warn("Disconnected from NATS", "error", err)
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			slog.Info("Reconnected to NATS")
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &natsBus{conn: conn}, nil
+}
+
+// consume subscribes once per worker, so the server spreads requests across the workers of every
+// instance. On shutdown the subscriptions are drained: requests already delivered to this
+// instance that no job was started for are published again for another instance to take
+func (b *natsBus) consume(ctx context.Context, workers int, handle func(context.Context, busMessage) bool) {
+	subscriptions := make([]*nats.Subscription, 0, workers)
+	for range workers {
+		sub, err := b.conn.QueueSubscribe(appConfig.BusRequests, busQueueGroup, func(msg *nats.Msg) {
+			request := busMessage{Body: msg.Data, ReplyTo: msg.Reply, CorrelationID: msg.Header.Get("Correlation-Id")}
+			if handle(ctx, request) {
+				return
+			}
+			if err := b.conn.PublishMsg(&nats.Msg{Subject: msg.Subject, Reply: msg.Reply, Header: msg.Header, Data: msg.Data}); err != nil {
+				slog.Error("Unable to give back a bus request", "error", err)
+			}
+		})
+		if err != nil {
+			slog.Error("Unable to subscribe to NATS", "subject", appConfig.BusRequests, "error", err)
+			break
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	<-ctx.Done()
+	for _, sub := range subscriptions {
+		closed := sub.StatusChanged(nats.SubscriptionClosed)
+		if err := sub.Drain(); err != nil {
+			slog.Warn("Error draining NATS subscription", "error", err)
+			continue
+		}
+		<-closed
+	}
+}
+
+// publish sends a message to a NATS subject, waiting for the server to have it
+func (b *natsBus) publish(ctx context.Context, subject string, msg busMessage) error {
+	out := nats.NewMsg(subject)
+	out.Data = msg.Body
+	if msg.CorrelationID != "" {
+		out.Header.Set("Correlation-Id", msg.CorrelationID)
+	}
+	if err := b.conn.PublishMsg(out); err != nil {
+		return err
+	}
+	return b.conn.FlushWithContext(ctx)
+}
+
+// Close closes the connection once published messages are sent
+func (b *natsBus) Close() error {
+	return b.conn.Drain()
+}
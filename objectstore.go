@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errObjectNotFound is returned (wrapped) by an ObjectStore when the object doesn't exist
+var errObjectNotFound = errors.New("object not found")
+
+// ObjectStore reads objects out of a cloud storage bucket
+type ObjectStore interface {
+	// Open returns a reader for the object and its size in bytes, or -1 if the size is unknown
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error)
+
+	// List returns every object in the bucket whose key starts with prefix
+	List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
+}
+
+// ObjectInfo describes an object in a bucket
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// objectStores maps URI schemes to the store that serves them
+var objectStores = map[string]ObjectStore{
+	"s3":     s3Store{},
+	"gs":     gcsStore{},
+	"azblob": azureStore{},
+}
+
+// downloadObject copies a scheme://bucket/key object into jobDir and returns the path it was saved to
+func downloadObject(ctx context.Context, store ObjectStore, parsed *url.URL, jobDir string) (string, error) {
+	bucket := parsed.Host
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if bucket == "" || key == "" {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("URI must look like %s://bucket/key", parsed.Scheme)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appConfig.DownloadTimeout)
+	defer cancel()
+
+	body, size, err := store.Open(ctx, bucket, key)
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return "", &pipelineError{Status: http.StatusNotFound, Message: fmt.Sprintf("Object %s not found", parsed.String())}
+		}
+		return "", &pipelineError{Status: http.StatusBadGateway, Message: "Failed to fetch object: " + err.Error()}
+	}
+	defer body.Close()
+
+	if size > appConfig.MaxUploadBytes {
+		return "", &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Object too large: maximum size is %d bytes", appConfig.MaxUploadBytes)}
+	}
+	if size > 0 {
+		if err := checkDiskSpace(appConfig.WorkDir, size, appConfig.DiskExpansionFactor); err != nil {
+			return "", &pipelineError{Status: http.StatusInsufficientStorage, Message: err.Error()}
+		}
+	}
+
+	outputPath := filepath.Join(jobDir, parsed.Scheme+"-"+filepath.Base(path.Base(key)))
+	outputFile, err := createJobFile(outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer outputFile.Close()
+
+	written, err := io.Copy(outputFile, io.LimitReader(body, appConfig.MaxUploadBytes+1))
+	if err != nil {
+		return "", &pipelineError{Status: http.StatusBadGateway, Message: "Failed to download object: " + err.Error()}
+	}
+	if written > appConfig.MaxUploadBytes {
+		return "", &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Object too large: maximum size is %d bytes", appConfig.MaxUploadBytes)}
+	}
+	if err := outputFile.Close(); err != nil {
+		return "", err
+	}
+
+	return outputPath, nil
+}
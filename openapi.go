@@ -0,0 +1,664 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec builds the OpenAPI document once, on first request. Schemas are generated from the
+// request and response types, so they can't drift from what the handlers send
+var openAPISpec = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(buildOpenAPISpec())
+})
+
+// serveOpenAPI serves the OpenAPI 3 document describing the HTTP API
+func serveOpenAPI(c *gin.Context) {
+	spec, err := openAPISpec()
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error building OpenAPI document", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to build API description"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the OpenAPI document
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Audio Transcriber API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// serveDocs serves Swagger UI for browsing and trying out the API
+func serveDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+// openAPISchemas generates component schemas from Go types, following their json tags
+type openAPISchemas map[string]any
+
+// ref returns a schema for t, adding named structs to the components and referring to them.
+// Fields are required unless they are omitempty, except in request bodies, where only fields
+// with binding:"required" are
+func (s openAPISchemas) ref(t reflect.Type, request bool) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.ref(t.Elem(), request)
+	case reflect.Slice:
+		if t == reflect.TypeFor[json.RawMessage]() {
+			return map[string]any{}
+		}
+		return map[string]any{"type": "array", "items": s.ref(t.Elem(), request)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.ref(t.Elem(), request)}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Struct:
+		if t == reflect.TypeFor[time.Time]() {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if _, ok := s[t.Name()]; !ok {
+			// Claim the name first so recursive types refer to themselves
+			s[t.Name()] = nil
+			properties, required := map[string]any{}, []string{}
+			s.addFields(t, request, properties, &required)
+			schema := map[string]any{"type": "object", "properties": properties}
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+			s[t.Name()] = schema
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// addFields adds the JSON fields of struct t to properties, flattening embedded structs as
+// encoding/json does
+func (s openAPISchemas) addFields(t reflect.Type, request bool, properties map[string]any, required *[]string) {
+	for field := range t.Fields() {
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			s.addFields(embedded, request, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.ref(field.Type, request)
+		omitEmpty := strings.Contains(options, "omitempty") || strings.Contains(options, "omitzero")
+		if request && strings.Contains(field.Tag.Get("binding"), "required") || !request && !omitEmpty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// formSchema describes the multipart form of an upload: the file fields plus the options of a
+// URL request other than the URL itself. Form values are text, so labels are comma-separated
+func (s openAPISchemas) formSchema(files map[string]any) map[string]any {
+	properties := map[string]any{}
+	for name, schema := range files {
+		properties[name] = schema
+	}
+	for field := range reflect.TypeFor[URLTranscriptionRequest]().Fields() {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "url" || name == "ingest" {
+			continue
+		}
+		schema := s.ref(field.Type, true)
+		if field.Type.Kind() == reflect.Slice {
+			schema = map[string]any{"type": "string", "description": "Comma-separated list"}
+		}
+		properties[name] = schema
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": []string{"file"}}
+}
+
+// jsonContent describes a JSON body of schema
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// openAPIResponse describes a response with an optional body
+func openAPIResponse(description string, content map[string]any) map[string]any {
+	response := map[string]any{"description": description}
+	if content != nil {
+		response["content"] = content
+	}
+	return response
+}
+
+// openAPIParam describes a path, query, or header parameter
+func openAPIParam(in, name, description string, schema map[string]any) map[string]any {
+	return map[string]any{"in": in, "name": name, "description": description, "required": in == "path", "schema": schema}
+}
+
+// buildOpenAPISpec describes every route registered in main
+func buildOpenAPISpec() map[string]any {
+	schemas := openAPISchemas{}
+	ref := func(v any) map[string]any { return schemas.ref(reflect.TypeOf(v), false) }
+	body := func(v any) map[string]any { return schemas.ref(reflect.TypeOf(v), true) }
+	str := map[string]any{"type": "string"}
+	integer := map[string]any{"type": "integer", "minimum": 1}
+	binary := map[string]any{"type": "string", "format": "binary"}
+	enum := func(values ...string) map[string]any { return map[string]any{"type": "string", "enum": values} }
+	id := openAPIParam("path", "id", "Job ID", str)
+	idempotencyKey := openAPIParam("header", idempotencyKeyHeader, "Retries with the same key return the job the first request created instead of starting another", map[string]any{"type": "string", "maxLength": maxIdempotencyKeyLength})
+
+	errorResponse := openAPIResponse("Error", jsonContent(ref(ErrorResponse{})))
+	withErrors := func(responses map[string]any, statuses ...string) map[string]any {
+		for _, status := range statuses {
+			responses[status] = errorResponse
+		}
+		return responses
+	}
+	operation := func(tag, summary, description string, fields map[string]any) map[string]any {
+		fields["tags"] = []string{tag}
+		fields["summary"] = summary
+		if description != "" {
+			fields["description"] = description
+		}
+		return fields
+	}
+	uploadForm := map[string]any{"multipart/form-data": map[string]any{"schema": schemas.formSchema(map[string]any{"file": binary})}}
+	sha256Digest := map[string]any{"type": "string", "pattern": "^[0-9a-fA-F]{64}$"}
+	transcribeForm := map[string]any{
+		"multipart/form-data":      map[string]any{"schema": schemas.formSchema(map[string]any{"file": binary, contentSHA256Field: sha256Digest})},
+		"audio/*":                  map[string]any{"schema": binary},
+		"video/*":                  map[string]any{"schema": binary},
+		"application/octet-stream": map[string]any{"schema": binary},
+	}
+	compareForm := schemas.formSchema(map[string]any{"file": binary, "provider_a": str, "model_a": str, "provider_b": str, "model_b": str})
+	alignForm := schemas.formSchema(map[string]any{"file": binary, "text": str})
+	alignForm["required"] = []string{"file", "text"}
+	speakerID := openAPIParam("path", "id", "Speaker ID", str)
+	sampleForm := func(fields map[string]any, required ...string) map[string]any {
+		return map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{"type": "object", "properties": fields, "required": required}}}
+	}
+
+	// A stored job renders in every plain and document format, each under its own media type
+	var formats []string
+	renderings := map[string]any{}
+	for format, contentType := range formatContentTypes {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		formats = append(formats, format)
+		renderings[mediaType] = map[string]any{"schema": str}
+	}
+	for format, contentType := range documentContentTypes {
+		formats = append(formats, format)
+		renderings[contentType] = map[string]any{"schema": binary}
+	}
+	renderings["application/json"] = map[string]any{"schema": ref(Job{})}
+	slices.Sort(formats)
+	tusHeaders := func(names ...string) []any {
+		params := []any{openAPIParam("header", "Tus-Resumable", "tus protocol version", enum(tusVersion))}
+		for _, name := range names {
+			params = append(params, openAPIParam("header", name, "", str))
+		}
+		return params
+	}
+
+	transcribe := operation("Transcription", "Transcribe an uploaded file",
+		"Transcribes the file and responds when it is done. The file is a multipart/form-data field, or the whole body of a POST or PUT with an audio or video Content-Type, whose options are then query parameters named like the form fields. A ZIP archive in a form is transcribed as a batch, one job per recording, and answered with an ArchiveResponse. With stream=true the response is NDJSON instead: a StreamChunk line as each chunk is transcribed, then a StreamResult or StreamError line.", map[string]any{
+			"parameters": []any{
+				idempotencyKey,
+				openAPIParam("header", contentSHA256Header, "Hex SHA-256 of the uploaded file, here or as a form field; a file that doesn't match is rejected with 422", sha256Digest),
+				openAPIParam("header", rawFilenameHeader, "Name of the file sent as the body, also taken from the filename query parameter or a Content-Disposition header", str),
+				openAPIParam("query", "stream", "Set to true, here or as a form field, to stream each chunk's transcript as it is ready", map[string]any{"type": "boolean"}),
+			},
+			"requestBody": map[string]any{"required": true, "content": transcribeForm},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("The transcription, or each recording's for a ZIP archive", map[string]any{
+					"application/json": map[string]any{"schema": map[string]any{"oneOf": []any{ref(SuccessResponse{}), ref(ArchiveResponse{})}}},
+					ndjsonContentType:  map[string]any{"schema": map[string]any{"oneOf": []any{ref(StreamChunk{}), ref(StreamResult{}), ref(StreamError{})}}},
+				}),
+			}, "400", "409", "413", "415", "422", "429", "500", "502", "503", "504", "507"),
+		})
+
+	retranscribe := operation("Jobs", "Transcribe a job's retained audio again",
+		"Runs the audio kept for a completed job submitted with retain_audio through the pipeline again as a new job, with the settings in the body, and compares the new transcript with the stored one word by word. The cache is skipped unless cache is true.", map[string]any{
+			"parameters":  []any{id},
+			"requestBody": map[string]any{"content": jsonContent(body(RetranscriptionRequest{}))},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("The new job's transcript and its differences from the previous one", jsonContent(ref(RetranscriptionResponse{}))),
+			}, "400", "404", "409", "429", "500", "502", "504"),
+		})
+
+	paths := map[string]any{
+		"/healthz": map[string]any{"get": operation("Health", "Liveness probe", "", map[string]any{
+			"responses": map[string]any{"200": openAPIResponse("The process is serving requests", jsonContent(ref(HealthResponse{})))},
+		})},
+		"/readyz": map[string]any{"get": operation("Health", "Readiness probe", "Checks every dependency a job needs.", map[string]any{
+			"responses": map[string]any{
+				"200": openAPIResponse("Every dependency is available", jsonContent(ref(HealthResponse{}))),
+				"503": openAPIResponse("A dependency is unavailable", jsonContent(ref(HealthResponse{}))),
+			},
+		})},
+		"/metrics": map[string]any{"get": operation("Health", "Prometheus metrics", "", map[string]any{
+			"responses": map[string]any{"200": openAPIResponse("Metrics in the text exposition format", map[string]any{"text/plain": map[string]any{"schema": str}})},
+		})},
+		"/api/transcribe": map[string]any{"post": transcribe, "put": transcribe},
+		"/api/transcribe/url": map[string]any{"post": operation("Transcription", "Transcribe media at a URL", "", map[string]any{
+			"parameters":  []any{idempotencyKey},
+			"requestBody": map[string]any{"required": true, "content": jsonContent(body(URLTranscriptionRequest{}))},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("The transcription", jsonContent(ref(SuccessResponse{}))),
+			}, "400", "409", "413", "415", "422", "429", "500", "502", "503", "504"),
+		})},
+		"/api/transcribe/batch": map[string]any{"post": operation("Batches", "Transcribe several files or URLs in the background",
+			"Upload several file fields as multipart/form-data, or send JSON with a urls field.", map[string]any{
+				"requestBody": map[string]any{"required": true, "content": map[string]any{
+					"multipart/form-data": map[string]any{"schema": schemas.formSchema(map[string]any{"file": map[string]any{"type": "array", "items": binary}})},
+					"application/json":    map[string]any{"schema": body(BatchURLRequest{})},
+				}},
+				"responses": withErrors(map[string]any{
+					"202": openAPIResponse("The batch was accepted", jsonContent(ref(BatchResponse{}))),
+				}, "400", "413", "415", "429", "500", "507"),
+			})},
+		"/api/feeds": map[string]any{"post": operation("Batches", "Transcribe the episodes of a podcast feed", "", map[string]any{
+			"requestBody": map[string]any{"required": true, "content": jsonContent(body(FeedRequest{}))},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("No episodes needed transcribing", jsonContent(ref(FeedResponse{}))),
+				"202": openAPIResponse("The episodes are being transcribed as a batch", jsonContent(ref(FeedResponse{}))),
+			}, "400", "422", "429", "500", "502", "504"),
+		})},
+		"/api/batches/{id}": map[string]any{"get": operation("Batches", "Get the status of a batch", "", map[string]any{
+			"parameters": []any{openAPIParam("path", "id", "Batch ID", str)},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("The batch and its jobs, without transcripts", jsonContent(ref(BatchStatusResponse{}))),
+			}, "404", "500"),
+		})},
+		"/api/estimate": map[string]any{"post": operation("Transcription", "Estimate the duration and cost of a transcription",
+			"Accepts the same upload or URL request as the transcription endpoints, without transcribing it.", map[string]any{
+				"requestBody": map[string]any{"required": true, "content": map[string]any{
+					"multipart/form-data": uploadForm["multipart/form-data"],
+					"application/json":    map[string]any{"schema": body(URLTranscriptionRequest{})},
+				}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The estimate", jsonContent(ref(EstimateResponse{}))),
+				}, "400", "413", "415", "422", "429", "500", "502"),
+			})},
+		"/api/align": map[string]any{"post": operation("Transcription", "Align a transcript with audio",
+			"Transcribes the file with word timestamps and aligns each word of text with the recognized words. Words that weren't recognized get times estimated from their neighbors and matched set to false.", map[string]any{
+				"requestBody": map[string]any{"required": true, "content": map[string]any{
+					"multipart/form-data": map[string]any{"schema": alignForm},
+				}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("Each word of text with its timing", jsonContent(ref(AlignmentResponse{}))),
+				}, "400", "413", "415", "422", "429", "500", "502"),
+			})},
+		"/api/detect-language": map[string]any{"post": operation("Transcription", "Detect the language spoken in the audio",
+			"Accepts the same upload or URL request as the transcription endpoints and transcribes only a sample from the start of the audio. Nothing is recorded in the job history.", map[string]any{
+				"parameters": []any{openAPIParam("query", "sample_seconds", "Seconds of audio to listen to, up to 120 (default 30)", map[string]any{"type": "number"})},
+				"requestBody": map[string]any{"required": true, "content": map[string]any{
+					"multipart/form-data": uploadForm["multipart/form-data"],
+					"application/json":    map[string]any{"schema": body(URLTranscriptionRequest{})},
+				}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The detected language", jsonContent(ref(LanguageResponse{}))),
+				}, "400", "413", "415", "422", "429", "500", "502", "504"),
+			})},
+		"/api/analyze": map[string]any{"post": operation("Transcription", "Analyze the quality of the audio",
+			"Accepts the same upload or URL request as the transcription endpoints and measures the audio without transcribing it. warnings lists anything likely to hurt the transcript. Nothing is recorded in the job history.", map[string]any{
+				"requestBody": map[string]any{"required": true, "content": map[string]any{
+					"multipart/form-data": uploadForm["multipart/form-data"],
+					"application/json":    map[string]any{"schema": body(URLTranscriptionRequest{})},
+				}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The audio's measurements and warnings", jsonContent(ref(AnalysisResponse{}))),
+				}, "400", "413", "415", "422", "429", "500", "502", "504"),
+			})},
+		"/api/compare": map[string]any{"post": operation("Transcription", "Compare two models on the same upload",
+			"Transcribes the upload with provider_a and model_a and with provider_b and model_b at the same time, each as its own job, without the cache. agreement compares b's transcript with a's word by word when both succeed; a side that failed has an error instead.", map[string]any{
+				"requestBody": map[string]any{"required": true, "content": map[string]any{"multipart/form-data": map[string]any{"schema": compareForm}}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("Both transcripts and their agreement", jsonContent(ref(ComparisonResponse{}))),
+				}, "400", "413", "415", "422", "429", "500", "502", "504", "507"),
+			})},
+		"/api/models": map[string]any{"get": operation("Transcription", "List the providers and models requests may pick", "", map[string]any{
+			"responses": map[string]any{"200": openAPIResponse("The configured providers", jsonContent(ref(ModelsResponse{})))},
+		})},
+		"/api/profiles": map[string]any{"get": operation("Transcription", "List the profiles requests may select", "", map[string]any{
+			"responses": map[string]any{"200": openAPIResponse("The configured profiles", jsonContent(ref(ProfilesResponse{})))},
+		})},
+		"/api/streams": map[string]any{"post": operation("Streams", "Transcribe a live RTSP, RTMP, or HLS stream", "", map[string]any{
+			"requestBody": map[string]any{"required": true, "content": jsonContent(body(URLTranscriptionRequest{}))},
+			"responses": withErrors(map[string]any{
+				"202": openAPIResponse("The stream is being transcribed", jsonContent(ref(LiveStreamResponse{}))),
+			}, "400", "429", "500"),
+		})},
+		"/api/streams/{id}/events": map[string]any{"get": operation("Streams", "Follow a live stream's partial transcript",
+			"Server-sent events: segments events carry arrays of Segment as they are transcribed, and a done event carries the finished Job.", map[string]any{
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("An event stream", map[string]any{"text/event-stream": map[string]any{"schema": str}}),
+				}, "404", "500"),
+			})},
+		"/api/streams/{id}": map[string]any{"delete": operation("Streams", "Stop a live stream", "", map[string]any{
+			"parameters": []any{id},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("The finished job", jsonContent(ref(Job{}))),
+			}, "404"),
+		})},
+		"/api/transcriptions": map[string]any{"get": operation("Jobs", "List stored jobs", "", map[string]any{
+			"parameters": []any{
+				openAPIParam("query", "page", "Page number", integer),
+				openAPIParam("query", "page_size", "Jobs per page", integer),
+				openAPIParam("query", "status", "Only jobs with this status", enum(JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusCanceled)),
+				openAPIParam("query", "filename", "Only jobs whose filename contains this text", str),
+				openAPIParam("query", "batch_id", "Only jobs in this batch", str),
+				openAPIParam("query", "feed_url", "Only episodes of this feed", str),
+				openAPIParam("query", "episode_guid", "Only jobs for this episode", str),
+				openAPIParam("query", "call_sid", "Only jobs for recordings of this Twilio call", str),
+				openAPIParam("query", "schedule", "Only jobs started by runs of this schedule", str),
+				openAPIParam("query", "from", "Only jobs created at or after this RFC 3339 time or date", str),
+				openAPIParam("query", "to", "Only jobs created before this RFC 3339 time, or on or before this date", str),
+				openAPIParam("query", "sort", "Field to sort by", enum(slices.Sorted(maps.Keys(jobSortColumns))...)),
+				openAPIParam("query", "order", "Sort order", enum("desc", "asc")),
+			},
+			"responses": withErrors(map[string]any{
+				"200": openAPIResponse("A page of jobs", jsonContent(ref(TranscriptionListResponse{}))),
+			}, "400", "500"),
+		})},
+		"/api/search": map[string]any{"get": operation("Jobs", "Search stored transcripts",
+			"Finds completed jobs whose transcripts contain every word of q, best matches first.", map[string]any{
+				"parameters": []any{
+					openAPIParam("query", "q", "Words to search for", str),
+					openAPIParam("query", "page", "Page number", integer),
+					openAPIParam("query", "page_size", "Results per page", integer),
+					openAPIParam("query", "profanity_filter", "Filter profanity", enum("mask", "remove")),
+				},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("A page of matching jobs", jsonContent(ref(SearchResponse{}))),
+				}, "400", "500"),
+			})},
+		"/api/transcriptions/{id}": map[string]any{
+			"get": operation("Jobs", "Get a stored job", "Formats other than json need a completed job, and lrc and words one submitted with word_timestamps; words is a JSON array of {word, start, end}.", map[string]any{
+				"parameters": []any{
+					id,
+					openAPIParam("query", "format", "How to render the job", enum(formats...)),
+					openAPIParam("query", "profanity_filter", "Filter profanity", enum("mask", "remove")),
+					openAPIParam("query", "min_confidence", "Flag segments scored below this, from 0 to 1, and mark their text with ⟦…⟧", map[string]any{"type": "number", "minimum": 0, "maximum": 1}),
+					openAPIParam("query", "translated", "Render the translation made with translate_to instead of the transcript", map[string]any{"type": "boolean"}),
+				},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The job, or its transcript in the requested format", renderings),
+				}, "400", "404", "409", "500"),
+			}),
+			"patch": operation("Jobs", "Correct a stored transcript",
+				"Replaces the text of segments of a completed job, keeping the original, and rebuilds the transcript from them.", map[string]any{
+					"parameters":  []any{id},
+					"requestBody": map[string]any{"required": true, "content": jsonContent(body(TranscriptCorrectionRequest{}))},
+					"responses": withErrors(map[string]any{
+						"200": openAPIResponse("The corrected job", jsonContent(ref(Job{}))),
+					}, "400", "404", "409", "500"),
+				}),
+			"delete": operation("Jobs", "Delete a stored job", "", map[string]any{
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"204": openAPIResponse("The job was deleted", nil),
+				}, "404", "500"),
+			}),
+		},
+		"/api/transcriptions/{id}/cancel": map[string]any{"post": operation("Jobs", "Cancel a queued or running job",
+			"Stops the job's ffmpeg processes and chunk requests, removes its files, and records it as canceled. A job on the instance that answers is returned once it has stopped; with a Redis queue, one that may be running elsewhere is returned with 202 as soon as the cancellation is sent.", map[string]any{
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The canceled job", jsonContent(ref(Job{}))),
+					"202": openAPIResponse("The cancellation was sent to the instance running the job", jsonContent(ref(Job{}))),
+				}, "404", "409", "500"),
+			})},
+		"/api/transcriptions/{id}/retranscribe": map[string]any{"post": retranscribe},
+		"/api/transcriptions/{id}/rerun":        map[string]any{"post": retranscribe},
+		"/api/transcriptions/{id}/video": map[string]any{"get": operation("Jobs", "Download a job's subtitled video",
+			"Returns the MP4 copy of the video made for a job submitted with subtitles, with the transcript burned in or as a soft subtitle track.", map[string]any{
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The subtitled video", map[string]any{"video/mp4": map[string]any{"schema": binary}}),
+				}, "404", "500"),
+			})},
+		"/api/transcriptions/{id}/events": map[string]any{"get": operation("Jobs", "Follow a job's progress",
+			"Server-sent events: progress events carry the job's JobProgress whenever it changes, and a done event carries the finished Job.", map[string]any{
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("An event stream", map[string]any{"text/event-stream": map[string]any{"schema": str}}),
+				}, "404", "500"),
+			})},
+		"/api/speakers": map[string]any{
+			"get": operation("Speakers", "List enrolled speakers", "", map[string]any{
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The speakers the caller's tenant has enrolled", jsonContent(ref(SpeakerListResponse{}))),
+				}, "500"),
+			}),
+			"post": operation("Speakers", "Enroll a speaker",
+				"Embeds the voice in the first minute of a sample of at least 3 seconds, so jobs submitted with identify_speakers label the segments the speaker says with their name.", map[string]any{
+					"requestBody": map[string]any{"required": true, "content": sampleForm(map[string]any{"file": binary, "name": str}, "file", "name")},
+					"responses": withErrors(map[string]any{
+						"201": openAPIResponse("The enrolled speaker", jsonContent(ref(Speaker{}))),
+					}, "400", "409", "413", "415", "422", "500", "502", "507"),
+				}),
+		},
+		"/api/speakers/{id}": map[string]any{"delete": operation("Speakers", "Remove an enrolled speaker", "", map[string]any{
+			"parameters": []any{speakerID},
+			"responses": withErrors(map[string]any{
+				"204": openAPIResponse("The speaker was removed", nil),
+			}, "404", "500"),
+		})},
+		"/api/speakers/{id}/samples": map[string]any{"post": operation("Speakers", "Add a voice sample to a speaker",
+			"The speaker's embedding becomes the mean of all of their samples', so they are recognized in more settings.", map[string]any{
+				"parameters":  []any{speakerID},
+				"requestBody": map[string]any{"required": true, "content": sampleForm(map[string]any{"file": binary}, "file")},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The speaker", jsonContent(ref(Speaker{}))),
+				}, "400", "404", "409", "413", "415", "422", "500", "502", "507"),
+			})},
+		"/api/admin/stats": map[string]any{"get": operation("Admin", "Get job and queue statistics",
+			"Job totals and rolling windows come from the job store; the queue and scratch space are this instance's.", map[string]any{
+				"security": []any{map[string]any{"adminToken": []string{}}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The statistics", jsonContent(ref(AdminStatsResponse{}))),
+				}, "401", "404", "500"),
+			})},
+		"/api/admin/throughput": map[string]any{"get": operation("Admin", "Get job throughput over time",
+			"Counts the jobs that finished in each interval of the window by outcome, oldest first: 5-minute intervals for 1h, hourly for 24h, and 6-hourly for 7d.", map[string]any{
+				"security":   []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{openAPIParam("query", "window", "Period to cover, 24h by default", enum("1h", "24h", "7d"))},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The throughput", jsonContent(ref(ThroughputResponse{}))),
+				}, "400", "401", "404", "500"),
+			})},
+		"/api/admin/jobs": map[string]any{"get": operation("Admin", "List the jobs of every tenant",
+			"Jobs are listed newest first, without their transcripts.", map[string]any{
+				"security": []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{
+					openAPIParam("query", "page", "Page number", integer),
+					openAPIParam("query", "page_size", "Jobs per page", integer),
+					openAPIParam("query", "status", "Only jobs with this status", enum(JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusCanceled)),
+					openAPIParam("query", "tenant_id", "Only jobs of this tenant", str),
+				},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("A page of jobs", jsonContent(ref(TranscriptionListResponse{}))),
+				}, "400", "401", "404", "500"),
+			})},
+		"/api/admin/jobs/{id}/cancel": map[string]any{"post": operation("Admin", "Cancel any tenant's job",
+			"Cancels a queued or running job as the cancel endpoint does, whichever tenant it belongs to.", map[string]any{
+				"security":   []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The canceled job", jsonContent(ref(Job{}))),
+					"202": openAPIResponse("The cancellation was sent to the instance running the job", jsonContent(ref(Job{}))),
+				}, "401", "404", "409", "500"),
+			})},
+		"/api/admin/jobs/{id}/retry": map[string]any{"post": operation("Admin", "Retry a job from its retained audio",
+			"Runs the audio kept for a finished job submitted with retain_audio again as a new job of the same tenant, with the same provider and model. The new job runs in the background.", map[string]any{
+				"security":   []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{id},
+				"responses": withErrors(map[string]any{
+					"202": openAPIResponse("The new job, now processing", jsonContent(ref(Job{}))),
+				}, "400", "401", "404", "409", "429", "500", "503"),
+			})},
+		"/api/admin/audit": map[string]any{"get": operation("Admin", "List the audit trail",
+			"Events are listed newest first. Reading the trail is recorded in it as audit.read.", map[string]any{
+				"security": []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{
+					openAPIParam("query", "page", "Page number", integer),
+					openAPIParam("query", "page_size", "Events per page", integer),
+					openAPIParam("query", "action", "Only events of this action", enum(auditActions...)),
+					openAPIParam("query", "tenant_id", "Only events of this tenant", str),
+					openAPIParam("query", "job_id", "Only events about this job", str),
+					openAPIParam("query", "client_ip", "Only events from this client IP", str),
+					openAPIParam("query", "from", "Only events at or after this RFC 3339 time or date", str),
+					openAPIParam("query", "to", "Only events before this RFC 3339 time, or on or before this date", str),
+				},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("A page of audit events", jsonContent(ref(AuditListResponse{}))),
+				}, "400", "401", "404", "500"),
+			})},
+		"/api/admin/webhooks": map[string]any{"get": operation("Admin", "List failed webhook deliveries",
+			"Deliveries are listed newest first: the dead letters that are no longer retried, or with status=pending those still being retried.", map[string]any{
+				"security": []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{
+					openAPIParam("query", "page", "Page number", integer),
+					openAPIParam("query", "page_size", "Deliveries per page", integer),
+					openAPIParam("query", "status", "Which deliveries to list", enum(WebhookFailed, WebhookPending)),
+					openAPIParam("query", "job_id", "Only deliveries about this job", str),
+				},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("A page of webhook deliveries", jsonContent(ref(WebhookDeliveryListResponse{}))),
+				}, "400", "401", "404", "500"),
+			})},
+		"/api/admin/webhooks/{id}/retry": map[string]any{"post": operation("Admin", "Retry a webhook delivery",
+			"Posts the delivery again right away and removes it once it goes through. A delivery that fails again is kept with the new error.", map[string]any{
+				"security":   []any{map[string]any{"adminToken": []string{}}},
+				"parameters": []any{openAPIParam("path", "id", "Delivery ID", integer)},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The delivery went through", jsonContent(ref(WebhookDelivery{}))),
+				}, "401", "404", "500", "502"),
+			})},
+		"/api/admin/schedules": map[string]any{"get": operation("Admin", "List schedules",
+			"Lists the schedules in TRANSCRIBER_SCHEDULES_FILE, when each next runs, and its 20 most recent runs, newest first. A run's jobs are its batch, or can be listed with /api/transcriptions?schedule=.", map[string]any{
+				"security": []any{map[string]any{"adminToken": []string{}}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The schedules", jsonContent(ref(ScheduleListResponse{}))),
+				}, "401", "404", "500"),
+			})},
+		"/api/admin/reload": map[string]any{"post": operation("Admin", "Reload the configuration",
+			"Reads TRANSCRIBER_CONFIG_FILE again, as SIGHUP does, and applies the CORS policy, upstream request limits, provider API keys, and default provider, model, and allowed models without dropping jobs in flight. Other changed settings are listed as needing a restart.", map[string]any{
+				"security": []any{map[string]any{"adminToken": []string{}}},
+				"responses": withErrors(map[string]any{
+					"200": openAPIResponse("The changed settings", jsonContent(ref(ConfigReloadResponse{}))),
+				}, "400", "401", "404", "500"),
+			})},
+		"/api/uploads": map[string]any{
+			"options": operation("Uploads", "Describe the tus server", "", map[string]any{
+				"responses": map[string]any{"204": openAPIResponse("Supported versions and extensions in the Tus-* headers", nil)},
+			}),
+			"post": operation("Uploads", "Create a resumable upload",
+				"Upload-Metadata takes the options of an upload as base64-encoded values, plus filename.", map[string]any{
+					"parameters": tusHeaders("Upload-Length", "Upload-Metadata"),
+					"responses": withErrors(map[string]any{
+						"201": openAPIResponse("The upload was created; its URL is in the Location header", nil),
+					}, "400", "412", "413", "415", "429", "500"),
+				}),
+		},
+		"/api/uploads/{id}": map[string]any{
+			"head": operation("Uploads", "Get the offset of a resumable upload", "", map[string]any{
+				"parameters": append([]any{openAPIParam("path", "id", "Upload ID", str)}, tusHeaders()...),
+				"responses": map[string]any{
+					"200": openAPIResponse("The offset so far, in the Upload-Offset header", nil),
+					"404": openAPIResponse("Upload not found", nil),
+				},
+			}),
+			"patch": operation("Uploads", "Append to a resumable upload",
+				"The job starts in the background once the last byte arrives.", map[string]any{
+					"parameters":  append([]any{openAPIParam("path", "id", "Upload ID", str)}, tusHeaders("Upload-Offset")...),
+					"requestBody": map[string]any{"required": true, "content": map[string]any{"application/offset+octet-stream": map[string]any{"schema": binary}}},
+					"responses": withErrors(map[string]any{
+						"204": openAPIResponse("The bytes were stored; the new offset is in the Upload-Offset header", nil),
+					}, "400", "404", "409", "413", "415", "500"),
+				}),
+			"delete": operation("Uploads", "Abandon a resumable upload", "", map[string]any{
+				"parameters": append([]any{openAPIParam("path", "id", "Upload ID", str)}, tusHeaders()...),
+				"responses": map[string]any{
+					"204": openAPIResponse("The upload was removed", nil),
+					"404": openAPIResponse("Upload not found", nil),
+				},
+			}),
+		},
+	}
+
+	// A batch takes urls in place of the url of the request it embeds
+	schemas["BatchURLRequest"].(map[string]any)["required"] = []string{"urls"}
+
+	// With tenants configured every API operation but the admin ones needs a tenant's key
+	if len(tenantsByID) > 0 {
+		for path, item := range paths {
+			if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/api/admin/") {
+				continue
+			}
+			for method, op := range item.(map[string]any) {
+				if method == "options" {
+					continue
+				}
+				op := op.(map[string]any)
+				op["security"] = []any{map[string]any{"apiKey": []string{}}, map[string]any{"apiKeyHeader": []string{}}}
+				withErrors(op["responses"].(map[string]any), "401")
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Audio Transcriber API",
+			"version":     "1.0.0",
+			"description": "Transcribes audio and video files with Whisper models, splitting long recordings into chunks.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"adminToken":   map[string]any{"type": "http", "scheme": "bearer", "description": "TRANSCRIBER_ADMIN_TOKEN"},
+				"apiKey":       map[string]any{"type": "http", "scheme": "bearer", "description": "A tenant's API key"},
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key", "description": "A tenant's API key"},
+			},
+		},
+	}
+}
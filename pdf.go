@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// PDF page geometry, in points: US Letter with one-inch margins
+const (
+	pdfPageWidth  = 612
+	pdfPageHeight = 792
+	pdfMargin     = 72
+)
+
+// pdfFont is one of the standard PDF fonts, which every viewer has, so nothing is embedded
+type pdfFont struct {
+	// resource is the name content streams select the font by
+	resource string
+
+	// widths are the advances of the printable ASCII characters, in thousandths of the font size.
+	// Other characters are measured as fallback wide
+	widths   [95]int
+	fallback int
+}
+
+var (
+	pdfRegular = &pdfFont{resource: "F1", fallback: 556, widths: [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}}
+	pdfBold = &pdfFont{resource: "F2", fallback: 611, widths: [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}}
+)
+
+// width measures WinAnsi-encoded text set in the font at size
+func (f *pdfFont) width(text []byte, size float64) float64 {
+	total := 0
+	for _, b := range text {
+		if b >= 32 && b < 127 {
+			total += f.widths[b-32]
+		} else {
+			total += f.fallback
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfLine is a line of text placed on a page
+type pdfLine struct {
+	font  *pdfFont
+	size  float64
+	gray  float64
+	y     float64
+	text  []byte
+	right bool
+}
+
+// pdfLayout flows text onto pages from the top margin down, starting a new page when one fills
+type pdfLayout struct {
+	pages [][]pdfLine
+	y     float64
+}
+
+// add wraps text to the page width and adds its lines, then space points of gap
+func (l *pdfLayout) add(text string, font *pdfFont, size, gray, space float64) {
+	leading := size * 1.35
+	for _, line := range wrapPDFText(winAnsi(text), font, size, pdfPageWidth-2*pdfMargin) {
+		if len(l.pages) == 0 || l.y-leading < pdfMargin {
+			l.pages = append(l.pages, nil)
+			l.y = pdfPageHeight - pdfMargin
+		}
+		l.y -= leading
+		page := len(l.pages) - 1
+		l.pages[page] = append(l.pages[page], pdfLine{font: font, size: size, gray: gray, y: l.y, text: line})
+	}
+	l.y -= space
+}
+
+// keep starts a new page unless height points are left, so a heading isn't stranded at the bottom
+func (l *pdfLayout) keep(height float64) {
+	if len(l.pages) > 0 && l.y-height < pdfMargin {
+		l.pages = append(l.pages, nil)
+		l.y = pdfPageHeight - pdfMargin
+	}
+}
+
+// renderPDF writes a document as a PDF in the standard Helvetica fonts, with page numbers
+func renderPDF(doc transcriptDocument) []byte {
+	var layout pdfLayout
+	layout.add(doc.Title, pdfBold, 18, 0, 4)
+	layout.add(doc.Details, pdfRegular, 10, 0.4, 18)
+	if doc.Summary != "" {
+		layout.add("Summary", pdfBold, 13, 0, 4)
+		for _, line := range strings.Split(doc.Summary, "\n") {
+			layout.add(line, pdfRegular, 11, 0, 4)
+		}
+		layout.keep(40)
+		layout.add("Transcript", pdfBold, 13, 0, 6)
+	}
+	for _, paragraph := range doc.Paragraphs {
+		if heading := doc.heading(paragraph); heading != "" {
+			layout.keep(30)
+			layout.add(heading, pdfBold, 9, 0.3, 1)
+		}
+		layout.add(paragraph.Text, pdfRegular, 11, 0, 10)
+	}
+	if len(layout.pages) == 0 {
+		layout.pages = [][]pdfLine{nil}
+	}
+
+	// Objects 1 and 2 are the catalog and page tree, 3 and 4 the fonts, and each page is then a
+	// page object followed by its content stream
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	var kids []string
+	for i := range layout.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(layout.pages)))
+	objects = append(objects,
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, lines := range layout.pages {
+		footer := []byte(fmt.Sprintf("%d / %d", i+1, len(layout.pages)))
+		lines = append(lines, pdfLine{font: pdfRegular, size: 9, gray: 0.4, y: pdfMargin / 2, text: footer, right: true})
+
+		var content bytes.Buffer
+		for _, line := range lines {
+			x := float64(pdfMargin)
+			if line.right {
+				x = pdfPageWidth - pdfMargin - line.font.width(line.text, line.size)
+			}
+			fmt.Fprintf(&content, "%.2f g BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", line.gray, line.font.resource, line.size, x, line.y, escapePDFString(line.text))
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// winAnsi encodes text for the standard fonts, replacing characters they don't have with ?
+func winAnsi(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch r {
+		case '\t', '\n', '\r':
+			r = ' '
+		}
+		b, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok || b < 32 {
+			b = '?'
+		}
+		encoded = append(encoded, b)
+	}
+	return encoded
+}
+
+// wrapPDFText breaks text into lines no wider than width, at spaces where it can and mid-word
+// where a single word is too long
+func wrapPDFText(text []byte, font *pdfFont, size, width float64) [][]byte {
+	var lines [][]byte
+	var line []byte
+	for _, word := range bytes.Fields(text) {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+		if font.width(candidate, size) <= width {
+			line = candidate
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+		for font.width(word, size) > width {
+			n := 1
+			for n < len(word) && font.width(word[:n+1], size) <= width {
+				n++
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escapePDFString escapes text for a PDF literal string, writing bytes outside printable ASCII
+// as octal escapes so the file stays 7-bit outside its header
+func escapePDFString(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
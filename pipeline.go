@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// JobOptions holds the per-request settings that shape how a job is processed
+type JobOptions struct {
+	// AudioTrack is the position of the audio stream to use among the file's audio streams
+	AudioTrack string `json:"audio_track,omitempty"`
+
+	// AudioLanguage selects the audio stream by its language tag when AudioTrack is empty
+	AudioLanguage string `json:"audio_language,omitempty"`
+
+	// UseCache returns a previous transcript of identical audio instead of transcribing again
+	UseCache bool `json:"use_cache"`
+
+	// Normalize evens out the loudness of the audio before it is transcribed
+	Normalize bool `json:"normalize,omitempty"`
+
+	// Denoise filters out constant background noise before the audio is transcribed
+	Denoise bool `json:"denoise,omitempty"`
+
+	// AudioFilters is an extra, allowlisted ffmpeg filter chain applied during preprocessing
+	AudioFilters string `json:"audio_filters,omitempty"`
+
+	// SkipNonSpeech leaves long stretches of music and silence out of what is transcribed
+	SkipNonSpeech bool `json:"skip_non_speech,omitempty"`
+
+	// RemoveSilence cuts silences out of the audio before it is chunked, putting the returned
+	// times back on the recording's timeline
+	RemoveSilence bool `json:"remove_silence,omitempty"`
+
+	// Speed plays the audio faster, from 1 to transcriber.MaxSpeed, so less of it is sent, trading
+	// some accuracy for cost; returned times are in real time. Zero means real time
+	Speed float64 `json:"speed,omitempty"`
+
+	// Start and End, in seconds, transcribe only that part of the recording; returned times are
+	// still on the recording's timeline. End zero means the end of the recording
+	Start float64 `json:"start,omitempty"`
+	End   float64 `json:"end,omitempty"`
+
+	// Provider and Model pick who transcribes the job, from the configured allowlist; empty means
+	// the server default
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	// Temperature is the sampling temperature, from 0 (the default) to 1
+	Temperature float64 `json:"temperature,omitempty"`
+
+	// Prompt is passed to the model with every chunk, after the server's vocabulary, to bias it
+	// toward the caller's domain terms and spelling
+	Prompt string `json:"prompt,omitempty"`
+
+	// Language is the spoken language, an ISO-639-1 code or auto; empty means the server default
+	Language string `json:"language,omitempty"`
+
+	// CodeSwitching is for recordings that mix languages: the language of every part is identified
+	// and tagged on its segments, and parts are routed to TRANSCRIBER_LANGUAGE_MODELS
+	CodeSwitching bool `json:"code_switching,omitempty"`
+
+	// SplitChannels transcribes the channels of a recording, such as the two sides of a stereo
+	// call, separately and interleaves them, labeling each segment's speaker with ChannelLabels
+	SplitChannels bool     `json:"split_channels,omitempty"`
+	ChannelLabels []string `json:"channel_labels,omitempty"`
+
+	// IdentifySpeakers labels segments with the tenant's enrolled speakers they sound like
+	IdentifySpeakers bool `json:"identify_speakers,omitempty"`
+
+	// AudioEvents tags the sounds between speech, such as laughter or applause, which captions
+	// show as cues like "[Laughter]"
+	AudioEvents bool `json:"audio_events,omitempty"`
+
+	// WordTimestamps asks the provider for the timing of every word, which alignment needs
+	WordTimestamps bool `json:"word_timestamps,omitempty"`
+
+	// Redact masks emails, phone numbers, card numbers, and (when configured) names in the
+	// transcript before it is stored or returned
+	Redact bool `json:"redact,omitempty"`
+
+	// ProfanityFilter masks or removes profanity in what is returned for this request. The stored
+	// transcript is left unfiltered
+	ProfanityFilter transcriber.ProfanityMode `json:"profanity_filter,omitempty"`
+
+	// MinConfidence flags the segments scored below it, from 0 to 1, in what is returned for this
+	// request, and wraps their text in ⟦…⟧. Zero flags nothing
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+
+	// Chapters splits the finished transcript into titled chapters where the topic shifts
+	Chapters bool `json:"chapters,omitempty"`
+
+	// Hallucinations marks the segments the model likely made up, such as "Thanks for watching!"
+	// over silence or a phrase stuck in a loop ("flag"), or takes them out of the transcript
+	// ("remove")
+	Hallucinations string `json:"hallucinations,omitempty"`
+
+	// Punctuation restores the punctuation and casing of the finished transcript, by rule
+	// ("rules") or with the summary chat model ("llm")
+	Punctuation string `json:"punctuation,omitempty"`
+
+	// Summarize asks a chat model for a summary of the finished transcript
+	Summarize bool `json:"summarize,omitempty"`
+
+	// Sentiment asks the chat model to rate how positive or negative each segment sounds
+	Sentiment bool `json:"sentiment,omitempty"`
+
+	// TranslateTo translates the finished transcript, segment for segment, into the language with
+	// this tag
+	TranslateTo string `json:"translate_to,omitempty"`
+
+	// Keywords extracts the transcript's key phrases and when they are mentioned
+	Keywords bool `json:"keywords,omitempty"`
+
+	// Priority decides how soon the job gets a worker: high, normal (the default), or batch
+	Priority string `json:"priority,omitempty"`
+
+	// NotifyEmail is emailed the transcript, or the error, when the job finishes
+	NotifyEmail string `json:"notify_email,omitempty"`
+
+	// SlackWebhookURL and DiscordWebhookURL are posted a message when the job finishes, along with
+	// the server-wide webhooks
+	SlackWebhookURL   string `json:"slack_webhook_url,omitempty"`
+	DiscordWebhookURL string `json:"discord_webhook_url,omitempty"`
+
+	// RetainAudio keeps the job's media once it completes or fails, so it can be transcribed again
+	RetainAudio bool `json:"retain_audio,omitempty"`
+
+	// Subtitles makes a copy of the job's video with the transcript burned in or as a soft
+	// subtitle track
+	Subtitles transcriber.SubtitleMode `json:"subtitles,omitempty"`
+
+	// StoreResults writes the finished transcript to the results bucket, to be downloaded through
+	// presigned URLs rather than embedded in responses
+	StoreResults bool `json:"store_results,omitempty"`
+
+	// Tenant is the ID of the tenant the job belongs to, which picks the providers it is
+	// transcribed with and the transcripts its cache may reuse. It comes from the job record
+	Tenant string `json:"-"`
+
+	// JobID is the ID of the job, whose chunks are checkpointed under it so the job can resume
+	// after a restart. It comes from the job record
+	JobID string `json:"-"`
+
+	// UploadMs is how long the job's media took to save or download, measured by executeJob so it
+	// travels with the job to the instance that runs it
+	UploadMs float64 `json:"upload_ms,omitempty"`
+
+	// OnSegments, when set, receives stitched segments in timeline order as chunks finish.
+	// It is not called for jobs that run on another instance through the shared queue
+	OnSegments func([]transcriber.Segment) `json:"-"`
+}
+
+// parseAudioFilters validates a requested filter chain, reporting problems as a 400
+func parseAudioFilters(chain string) (string, error) {
+	chain = strings.TrimSpace(chain)
+	if err := transcriber.ValidateAudioFilters(chain); err != nil {
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid audio_filters: %s (allowed filters: %s)", err, strings.Join(transcriber.AllowedAudioFilters(), ", ")),
+		}
+	}
+	return chain, nil
+}
+
+// parseSpeed validates a requested speed from a form field or upload metadata; empty means real
+// time
+func parseSpeed(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid speed %q: expected a number between 1 and %g", value, transcriber.MaxSpeed)}
+	}
+	return speed, checkSpeed(speed)
+}
+
+// checkSpeed reports a speed outside 1 to transcriber.MaxSpeed as a 400
+func checkSpeed(speed float64) error {
+	if err := transcriber.ValidateSpeed(speed); err != nil {
+		return &pipelineError{Status: http.StatusBadRequest, Message: "Invalid speed: " + err.Error()}
+	}
+	return nil
+}
+
+// parseTimeRange validates the start and end of the part of a recording to transcribe, from form
+// fields or upload metadata, each in seconds or as [hh:]mm:ss; empty means the whole recording
+func parseTimeRange(start, end string) (float64, float64, error) {
+	startSeconds, err := parseTimestamp("start", start)
+	if err != nil {
+		return 0, 0, err
+	}
+	endSeconds, err := parseTimestamp("end", end)
+	if err != nil {
+		return 0, 0, err
+	}
+	return startSeconds, endSeconds, checkTimeRange(startSeconds, endSeconds)
+}
+
+// parseTimestamp parses one end of a time range, such as "90", "1:30", or "0:01:30.5"
+func parseTimestamp(name, value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	invalid := &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid %s %q: expected seconds or a time such as 1:02:03.5", name, value)}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, invalid
+	}
+	var seconds float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 || (i > 0 && n >= 60) || (i < len(parts)-1 && strings.Contains(part, ".")) {
+			return 0, invalid
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+// checkTimeRange reports a time range that starts before 0 or ends before it starts as a 400
+func checkTimeRange(start, end float64) error {
+	if err := transcriber.ValidateTimeRange(start, end); err != nil {
+		return &pipelineError{Status: http.StatusBadRequest, Message: "Invalid time range: " + err.Error()}
+	}
+	return nil
+}
+
+// parsePrompt validates a requested prompt, reporting one that is too long as a 400
+func parsePrompt(prompt string) (string, error) {
+	prompt = strings.TrimSpace(prompt)
+	if err := transcriber.ValidatePrompt(prompt); err != nil {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "Invalid prompt: " + err.Error()}
+	}
+	return prompt, nil
+}
+
+// parseLanguage validates a requested spoken language, which is case-insensitive
+func parseLanguage(language string) (string, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if err := transcriber.ValidateLanguage(language); err != nil {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid language %q: expected an ISO-639-1 code such as en or de, or auto", language)}
+	}
+	return language, nil
+}
+
+// maxChannelLabels is the most channels split channels transcribes, each as its own pass
+const maxChannelLabels = 32
+
+// parseChannelLabels validates the labels requested for split channels, defaulting to the
+// configured ones when none are given
+func parseChannelLabels(labels []string) ([]string, error) {
+	if len(labels) == 0 {
+		return appConfig.ChannelLabels, nil
+	}
+	if len(labels) < 2 || len(labels) > maxChannelLabels {
+		return nil, &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("channel_labels must name from 2 to %d channels, in order (left, right for stereo), got %d", maxChannelLabels, len(labels)),
+		}
+	}
+	for i, label := range labels {
+		labels[i] = strings.TrimSpace(label)
+		if labels[i] == "" {
+			return nil, &pipelineError{Status: http.StatusBadRequest, Message: "channel_labels must not be empty"}
+		}
+	}
+	return labels, nil
+}
+
+// splitLabelList splits a comma-separated channel_labels form or metadata value
+func splitLabelList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// newTranscriber builds a provider's pipeline from the server configuration. An empty model uses
+// the library default
+func newTranscriber(config Config, apiURL, apiKey, model string) *transcriber.Transcriber {
+	return transcriber.New(transcriber.Options{
+		APIURL:             apiURL,
+		APIKey:             apiKey,
+		Model:              model,
+		Language:           config.Language,
+		MaxDuration:        config.MaxDuration,
+		RNNoiseModel:       config.RNNoiseModel,
+		Vocabulary:         config.Vocabulary,
+		PreprocessTimeout:  config.PreprocessTimeout,
+		ChunkingTimeout:    config.ChunkingTimeout,
+		RequestTimeout:     config.ChunkTimeout,
+		MaxChunkBytes:      config.MaxChunkBytes,
+		LiveSegmentSeconds: config.LiveSegmentSeconds,
+		NonSpeechSeconds:   config.NonSpeechSeconds,
+		SilenceSeconds:     config.SilenceSeconds,
+		Metrics:            pipelineMetrics{},
+		RequestLimiter:     requestLimiter,
+		HTTPClient:         &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	})
+}
+
+// streamedSegments returns the job's OnSegments callback, redacting what it can, filtering
+// profanity and hallucinations, and flagging low-confidence segments as the job asks. Names are
+// only known once the whole transcript is in, so when they are redacted, segments aren't streamed
+// at all and only arrive with the final result
+func streamedSegments(opts JobOptions) func([]transcriber.Segment) {
+	if opts.OnSegments == nil || (!opts.Redact && opts.ProfanityFilter == "" && opts.MinConfidence == 0 && opts.Hallucinations == "") {
+		return opts.OnSegments
+	}
+	if opts.Redact && appConfig.RedactNames {
+		return nil
+	}
+	return func(segments []transcriber.Segment) {
+		filtered := profanityFilter.ApplySegments(segments, opts.ProfanityFilter)
+		if opts.Redact {
+			for i := range filtered {
+				filtered[i].Text = transcriber.RedactText(filtered[i].Text, nil)
+			}
+		}
+		if opts.MinConfidence > 0 {
+			filtered, _ = transcriber.FlagLowConfidence(filtered, opts.MinConfidence)
+		}
+		if opts.Hallucinations != "" {
+			filtered, _ = transcriber.DetectHallucinations(filtered)
+		}
+		if opts.Hallucinations == hallucinationsRemove {
+			filtered = slices.DeleteFunc(filtered, func(segment transcriber.Segment) bool { return segment.Hallucination != "" })
+			if len(filtered) == 0 {
+				return
+			}
+		}
+		opts.OnSegments(filtered)
+	}
+}
+
+// pipelineError is a pipeline failure along with the HTTP status it should be reported as
+type pipelineError struct {
+	Status  int
+	Message string
+
+	// RetryAfter, when set, tells the client how long to wait before trying again
+	RetryAfter time.Duration
+
+	// Stage is the pipeline stage that failed, when the error came from one
+	Stage transcriber.Stage
+}
+
+func (e *pipelineError) Error() string {
+	return e.Message
+}
+
+// runPipeline validates, preprocesses, chunks, and transcribes a media file saved in jobDir.
+// The pipeline stops, killing its ffmpeg processes and abandoning chunk requests, when ctx is
+// canceled (the client went away), shutdown cancels in-flight jobs, or it runs past JobTimeout
+func runPipeline(ctx context.Context, jobDir, inputPath string, opts JobOptions) (*transcriber.Result, error) {
+	transcribeOpts := transcribeOptions(ctx, opts)
+
+	pipelineCtx, cancel := jobContext(ctx)
+	defer cancel()
+
+	// An encrypted job's media is sealed before anything reads it, if it wasn't as it arrived
+	cipher, err := sealJobInput(jobDir, inputPath)
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to encrypt media: " + err.Error()}
+	}
+	transcribeOpts.Cipher = cipher
+
+	// Media of an unaccepted format or infected is turned away before it takes a worker or
+	// reaches ffmpeg
+	if err := checkMediaContainer(inputPath); err != nil {
+		return nil, err
+	}
+	if err := scanMedia(pipelineCtx, inputPath); err != nil {
+		return nil, err
+	}
+
+	// Wait our turn so the number of pipelines (and provider calls) stays bounded server-wide
+	queuedAt := time.Now()
+	releaseWorker, err := waitForWorker(pipelineCtx, opts.Priority)
+	if timings := jobTimingsFrom(ctx); timings != nil {
+		timings.QueueMs = milliseconds(time.Since(queuedAt))
+	}
+	if err != nil {
+		return nil, pipelineErrorFor(err)
+	}
+	defer releaseWorker()
+
+	// The job timeout starts once the job has a worker, so time spent queued doesn't count
+	if appConfig.JobTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		pipelineCtx, cancelTimeout = context.WithTimeout(pipelineCtx, appConfig.JobTimeout)
+		defer cancelTimeout()
+	}
+
+	// Small jobs keep their intermediate audio in memory when there's room
+	workDir := jobDir
+	if memoryDir, release := reserveMemoryWorkDir(pipelineCtx, jobDir, inputPath); memoryDir != "" {
+		defer release()
+		workDir = memoryDir
+	}
+
+	result, err := transcriberFor(tenantsByID[opts.Tenant], opts.Provider).Transcribe(pipelineCtx, inputPath, workDir, transcribeOpts)
+	if err != nil {
+		return nil, pipelineErrorFor(err)
+	}
+	return result, nil
+}
+
+// transcribeOptions translates a job's options into the library's
+func transcribeOptions(ctx context.Context, opts JobOptions) transcriber.TranscribeOptions {
+	transcribeOpts := transcriber.TranscribeOptions{
+		AudioTrack:    opts.AudioTrack,
+		AudioLanguage: opts.AudioLanguage,
+		Normalize:     opts.Normalize,
+		Denoise:       opts.Denoise,
+		AudioFilters:  opts.AudioFilters,
+		Model:         opts.Model,
+		Temperature:   opts.Temperature,
+		Prompt:        opts.Prompt,
+		Language:      opts.Language,
+		SplitChannels: opts.SplitChannels,
+		ChannelLabels: opts.ChannelLabels,
+		OnSegments:    streamedSegments(opts),
+		Logger:        loggerFrom(ctx),
+
+		WordTimestamps: opts.WordTimestamps,
+		SkipNonSpeech:  opts.SkipNonSpeech,
+		RemoveSilence:  opts.RemoveSilence,
+		Speed:          opts.Speed,
+		Start:          opts.Start,
+		End:            opts.End,
+		CodeSwitching:  opts.CodeSwitching,
+		LanguageModels: jobLanguageModels(opts),
+	}
+	if opts.UseCache && jobStore != nil {
+		transcribeOpts.Cache = jobStoreCache{tenantID: opts.Tenant, logger: loggerFrom(ctx)}
+	}
+	if opts.JobID != "" && appConfig.ResumeJobs && jobStore != nil {
+		transcribeOpts.Checkpoint = jobCheckpoint{jobID: opts.JobID}
+	}
+	if opts.JobID != "" && jobStore != nil {
+		transcribeOpts.OnProgress = jobProgressRecorder(ctx, opts.JobID)
+	}
+	return transcribeOpts
+}
+
+// statusClientClosedRequest is recorded for jobs whose client went away before they finished. No
+// one receives it, but it keeps them apart from server errors in the job history and metrics
+const statusClientClosedRequest = 499
+
+// pipelineErrorFor maps a transcriber.StageError onto the status and message the API reports
+func pipelineErrorFor(err error) error {
+	if errors.Is(err, context.Canceled) {
+		if jobsCtx.Err() != nil {
+			return &pipelineError{Status: http.StatusServiceUnavailable, Message: "Transcription canceled: server is shutting down"}
+		}
+		return &pipelineError{Status: statusClientClosedRequest, Message: "Transcription canceled: client disconnected"}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		var stageErr *transcriber.StageError
+		var timeoutErr *transcriber.TimeoutError
+		if errors.As(err, &stageErr) && errors.As(err, &timeoutErr) {
+			return &pipelineError{Status: http.StatusGatewayTimeout, Stage: stageErr.Stage, Message: fmt.Sprintf(
+				"Transcription timed out: the %s stage took longer than %s", stageErr.Stage, timeoutErr.Timeout)}
+		}
+		return &pipelineError{Status: http.StatusGatewayTimeout, Message: fmt.Sprintf("Transcription timed out: the job took longer than %s", appConfig.JobTimeout)}
+	}
+
+	var stageErr *transcriber.StageError
+	if !errors.As(err, &stageErr) {
+		return err
+	}
+
+	var durationErr *transcriber.DurationLimitError
+	if errors.As(err, &durationErr) {
+		return &pipelineError{Status: http.StatusUnprocessableEntity, Stage: stageErr.Stage, Message: fmt.Sprintf(
+			"Audio too long: file is %s, and the maximum this server accepts is %s", durationErr.Duration, durationErr.Limit)}
+	}
+	var rangeErr *transcriber.TimeRangeError
+	if errors.As(err, &rangeErr) {
+		return &pipelineError{Status: http.StatusUnprocessableEntity, Stage: stageErr.Stage, Message: fmt.Sprintf(
+			"Start is past the end of the recording: start is %gs, and the file is %gs long", rangeErr.Start, rangeErr.Duration)}
+	}
+
+	switch stageErr.Stage {
+	case transcriber.StageValidate:
+		return &pipelineError{Status: http.StatusUnprocessableEntity, Stage: stageErr.Stage, Message: "Invalid media file: " + stageErr.Err.Error()}
+	case transcriber.StageSelectStream:
+		return &pipelineError{Status: http.StatusUnprocessableEntity, Stage: stageErr.Stage, Message: stageErr.Err.Error()}
+	case transcriber.StagePreprocess:
+		return &pipelineError{Status: http.StatusInternalServerError, Stage: stageErr.Stage, Message: "Failed to preprocess audio: " + stageErr.Err.Error()}
+	case transcriber.StageHash:
+		return &pipelineError{Status: http.StatusInternalServerError, Stage: stageErr.Stage, Message: "Failed to hash audio: " + stageErr.Err.Error()}
+	case transcriber.StageAnalyze:
+		return &pipelineError{Status: http.StatusInternalServerError, Stage: stageErr.Stage, Message: "Failed to analyze audio: " + stageErr.Err.Error()}
+	case transcriber.StageChunk:
+		return &pipelineError{Status: http.StatusInternalServerError, Stage: stageErr.Stage, Message: "Failed to chunk audio: " + stageErr.Err.Error()}
+	case transcriber.StageTranscribe:
+		return &pipelineError{Status: http.StatusBadGateway, Stage: stageErr.Stage, Message: "Transcription request failed: " + stageErr.Err.Error()}
+	case transcriber.StageIngest:
+		return &pipelineError{Status: http.StatusBadGateway, Stage: stageErr.Stage, Message: "Failed to read live stream: " + stageErr.Err.Error()}
+	}
+	return &pipelineError{Status: http.StatusInternalServerError, Stage: stageErr.Stage, Message: err.Error()}
+}
+
+// jobStoreCache serves cached transcripts from a tenant's completed jobs with the same audio hash
+type jobStoreCache struct {
+	tenantID string
+	logger   *slog.Logger
+}
+
+func (c jobStoreCache) Lookup(audioHash, model string) (*transcriber.Result, bool) {
+	cached, err := jobStore.FindCompletedJobByHash(c.tenantID, audioHash, model)
+	if err != nil {
+		if !errors.Is(err, errJobNotFound) {
+			c.logger.Error("Error looking up cached transcript", "error", err)
+		}
+		return nil, false
+	}
+
+	// Identified speakers and sentiment ratings were added for the earlier job, and are added again
+	// only if this one asks. Split channels, whose speakers are their channels, aren't cached
+	transcript, segments := cached.Transcript, cached.Segments
+	identified := false
+	for i := range segments {
+		identified = identified || segments[i].Speaker != ""
+		segments[i].Speaker, segments[i].Sentiment = "", nil
+	}
+	if identified {
+		transcript = transcriber.JoinSegments(segments)
+	}
+	return &transcriber.Result{
+		Transcription:   transcript,
+		Segments:        segments,
+		DurationSeconds: cached.DurationSeconds,
+	}, true
+}
@@ -0,0 +1,185 @@
+package transcriber
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AlignedWord is a word of a script with when it is spoken in the audio
+type AlignedWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+
+	// Matched is false for a word that wasn't recognized in the audio, such as one the model
+	// misheard, whose times are estimated from the words around it
+	Matched bool `json:"matched"`
+}
+
+// alignmentBand is how far, in words, an alignment may stray from the diagonal between the script
+// and the recognized words. It bounds memory and time for long recordings while allowing for
+// passages that were skipped or ad-libbed
+const alignmentBand = 500
+
+// Alignment directions, recorded for every cell so the best path can be traced back
+const (
+	alignUp   byte = iota // the script word isn't in the audio
+	alignLeft             // the recognized word isn't in the script
+	alignDiag             // the script word is the recognized word
+)
+
+// AlignScript times every word of script, such as a corrected transcript or the text that was
+// read aloud, against the words recognized in the audio (Result.Words). Words are matched in order,
+// ignoring case and punctuation, so the script keeps its own spelling and punctuation. Words that
+// don't match share out the time between the matched words around them, and those after the
+// last match run up to duration
+func AlignScript(script string, recognized []Word, duration float64) []AlignedWord {
+	scriptWords := strings.Fields(script)
+	aligned := make([]AlignedWord, len(scriptWords))
+	for i, word := range scriptWords {
+		aligned[i].Word = word
+	}
+	if len(scriptWords) == 0 {
+		return aligned
+	}
+
+	matches := matchWords(normalizedWords(scriptWords), normalizedWords(recognizedText(recognized)))
+	for i, match := range matches {
+		if match >= 0 {
+			aligned[i].Start = recognized[match].Start
+			aligned[i].End = recognized[match].End
+			aligned[i].Matched = true
+		}
+	}
+	if len(recognized) > 0 {
+		duration = max(duration, recognized[len(recognized)-1].End)
+	}
+	interpolateUnmatched(aligned, duration)
+	return aligned
+}
+
+// recognizedText returns the text of each recognized word
+func recognizedText(words []Word) []string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Word
+	}
+	return texts
+}
+
+// normalizedWords lowercases words and strips everything but letters and numbers, so "Hello," and
+// "hello" match. A word of only punctuation normalizes to "" and matches nothing
+func normalizedWords(words []string) []string {
+	normalized := make([]string, len(words))
+	for i, word := range words {
+		normalized[i] = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, word)
+	}
+	return normalized
+}
+
+// matchWords finds the longest common subsequence of the script's and the recognized words within
+// alignmentBand of the diagonal, returning for each script word the index of the recognized word
+// it matched, or -1
+func matchWords(script, recognized []string) []int {
+	n, m := len(script), len(recognized)
+	band := max(alignmentBand, m/n+1)
+	lo := func(i int) int { return max(0, i*m/n-band) }
+	hi := func(i int) int { return min(m, i*m/n+band) }
+
+	// Scores are kept for two rows at a time; directions for the whole band
+	const unreachable = -1 << 30
+	directions := make([][]byte, n+1)
+	previous := make([]int32, hi(0)-lo(0)+1)
+	directions[0] = make([]byte, len(previous))
+	for j := range directions[0] {
+		directions[0][j] = alignLeft
+	}
+	score := func(row []int32, rowLo, j int) int32 {
+		if j < rowLo || j >= rowLo+len(row) {
+			return unreachable
+		}
+		return row[j-rowLo]
+	}
+	for i := 1; i <= n; i++ {
+		rowLo, previousLo := lo(i), lo(i-1)
+		current := make([]int32, hi(i)-rowLo+1)
+		directions[i] = make([]byte, len(current))
+		for j := rowLo; j <= hi(i); j++ {
+			best, direction := score(previous, previousLo, j), alignUp
+			if left := score(current, rowLo, j-1); j > rowLo && left > best {
+				best, direction = left, alignLeft
+			}
+			if j > 0 && script[i-1] != "" && script[i-1] == recognized[j-1] {
+				if diagonal := score(previous, previousLo, j-1); diagonal != unreachable && diagonal+1 >= best {
+					best, direction = diagonal+1, alignDiag
+				}
+			}
+			current[j-rowLo] = best
+			directions[i][j-rowLo] = direction
+		}
+		previous = current
+	}
+
+	matches := make([]int, n)
+	for i := range matches {
+		matches[i] = -1
+	}
+	for i, j := n, m; i > 0; {
+		direction := alignUp
+		if j > 0 {
+			direction = directions[i][j-lo(i)]
+		}
+		switch direction {
+		case alignDiag:
+			matches[i-1] = j - 1
+			i, j = i-1, j-1
+		case alignLeft:
+			j--
+		default:
+			i--
+		}
+	}
+	return matches
+}
+
+// interpolateUnmatched spreads each run of unmatched words over the gap between the matched words
+// on either side of it, in proportion to their length
+func interpolateUnmatched(aligned []AlignedWord, duration float64) {
+	for i := 0; i < len(aligned); {
+		if aligned[i].Matched {
+			i++
+			continue
+		}
+		end := i
+		for end < len(aligned) && !aligned[end].Matched {
+			end++
+		}
+
+		from, to := 0.0, duration
+		if i > 0 {
+			from = aligned[i-1].End
+		}
+		if end < len(aligned) {
+			to = aligned[end].Start
+		}
+		to = max(to, from)
+
+		letters := 0
+		for _, word := range aligned[i:end] {
+			letters += max(1, utf8.RuneCountInString(word.Word))
+		}
+		position := from
+		for k := i; k < end; k++ {
+			aligned[k].Start = position
+			position += (to - from) * float64(max(1, utf8.RuneCountInString(aligned[k].Word))) / float64(letters)
+			aligned[k].End = position
+		}
+		i = end
+	}
+}
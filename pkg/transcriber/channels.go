@@ -0,0 +1,98 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultChannelLabels name the left and right channels of a call recording
+var DefaultChannelLabels = []string{"Agent", "Customer"}
+
+// transcribeChannels preprocesses and transcribes each channel of a stream on its own, then
+// interleaves the transcripts by segment start time
+func (t *Transcriber) transcribeChannels(ctx context.Context, logger *slog.Logger, inputPath, workDir string, stream StreamInfo, filters audioFilters, opts TranscribeOptions) (*Result, error) {
+	labels := opts.ChannelLabels
+	if len(labels) == 0 {
+		labels = DefaultChannelLabels
+	}
+	if len(labels) < 2 {
+		return nil, &StageError{Stage: StageValidate, Err: fmt.Errorf("split channels needs at least 2 channel labels, got %d", len(labels))}
+	}
+	if stream.Channels != len(labels) {
+		return nil, &StageError{Stage: StageSelectStream, Err: fmt.Errorf("split channels needs an audio stream with %d channels, one for each label, but stream %d has %d channel(s)", len(labels), stream.Index, stream.Channels)}
+	}
+
+	combined := &Result{Segments: []Segment{}, Model: t.model(opts)}
+	for channel, label := range labels {
+		channelLogger := logger.With("channel", label)
+		channelDir := filepath.Join(workDir, "channel-"+strconv.Itoa(channel))
+		if err := os.MkdirAll(channelDir, 0o700); err != nil {
+			return nil, &StageError{Stage: StagePreprocess, Err: err}
+		}
+
+		// Keep only this channel instead of mixing them all down to mono
+		channelFilters := filters
+		channelFilters.Channel = channel + 1
+
+		preprocessedPath := filepath.Join(channelDir, "preprocessed.flac")
+		start := time.Now()
+		err := timedStage(ctx, StagePreprocess, t.opts.PreprocessTimeout, func(ctx context.Context) error {
+			return preprocessAudioFile(ctx, inputPath, preprocessedPath, stream.Index, channelFilters)
+		})
+		t.observeStage(ctx, channelLogger, StagePreprocess, start)
+		if err != nil {
+			return nil, err
+		}
+
+		// Each channel's chunks are checkpointed under the hash of its own audio
+		request := t.chunkRequest(opts)
+		var checkpoint *chunkCheckpoint
+		if opts.Checkpoint != nil {
+			audioHash, err := hashFile(ctx, preprocessedPath)
+			if err != nil {
+				return nil, &StageError{Stage: StageHash, Err: err}
+			}
+			checkpoint = t.openCheckpoint(opts.Checkpoint, channelLogger, audioHash, request)
+		}
+		progress := progressReporter{onProgress: opts.OnProgress, part: channel, parts: len(labels)}
+		result, err := t.transcribeAudio(ctx, channelLogger, preprocessedPath, channelDir, request, checkpoint, nil, progress)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, segment := range result.Segments {
+			segment.Speaker = label
+			combined.Segments = append(combined.Segments, segment)
+		}
+		combined.Words = append(combined.Words, result.Words...)
+		combined.DurationSeconds = max(combined.DurationSeconds, result.DurationSeconds)
+		combined.Chunks += result.Chunks
+		combined.AudioSeconds += result.AudioSeconds
+		combined.SilenceRemoved += result.SilenceRemoved
+	}
+
+	// Interleave the channels; segments that start together keep the order of their channels
+	stitchStart := time.Now()
+	sort.SliceStable(combined.Segments, func(i, j int) bool {
+		return combined.Segments[i].Start < combined.Segments[j].Start
+	})
+	for i := range combined.Segments {
+		combined.Segments[i].ID = i
+	}
+	sort.SliceStable(combined.Words, func(i, j int) bool {
+		return combined.Words[i].Start < combined.Words[j].Start
+	})
+	combined.Transcription = JoinSegments(combined.Segments)
+	timingRecorderFrom(ctx).stitch(time.Since(stitchStart))
+
+	if opts.OnSegments != nil && len(combined.Segments) > 0 {
+		opts.OnSegments(combined.Segments)
+	}
+	return combined, nil
+}
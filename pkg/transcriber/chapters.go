@@ -0,0 +1,161 @@
+package transcriber
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chapter is a stretch of a recording about one topic, titled with its key phrase
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
+}
+
+const (
+	// chapterWindowSeconds is how much speech on each side of a possible boundary is compared to
+	// tell whether the topic changes there
+	chapterWindowSeconds = 90.0
+
+	// chapterPauseSeconds is the pause that counts fully toward a boundary; shorter ones count in
+	// proportion
+	chapterPauseSeconds = 3.0
+
+	// chapterPauseWeight is how much a pause counts toward a boundary, against the change in
+	// vocabulary around it
+	chapterPauseWeight = 0.3
+)
+
+// DetectChapters splits a transcript into chapters where the topic shifts, in the manner of
+// TextTiling: each gap between segments is scored by how different the words spoken in the
+// window before it are from those in the window after, plus how long the pause is, and the best
+// scoring gaps at least minLength apart and from either end become chapter boundaries. Each
+// chapter is titled with its top key phrase. The first chapter starts at zero, as players expect
+func DetectChapters(segments []Segment, minLength time.Duration) []Chapter {
+	if len(segments) == 0 {
+		return nil
+	}
+	words := make([][]string, len(segments))
+	for i, segment := range segments {
+		for _, phrase := range candidatePhrases(segment.Text) {
+			words[i] = append(words[i], phrase...)
+		}
+	}
+
+	type gap struct {
+		index int
+		score float64
+	}
+	var gaps []gap
+	var sum, sumSquares float64
+	for i := 1; i < len(segments); i++ {
+		at := segments[i].Start
+		before, after := map[string]float64{}, map[string]float64{}
+		for j := i - 1; j >= 0 && segments[j].Start >= at-chapterWindowSeconds; j-- {
+			for _, word := range words[j] {
+				before[word]++
+			}
+		}
+		for j := i; j < len(segments) && segments[j].Start < at+chapterWindowSeconds; j++ {
+			for _, word := range words[j] {
+				after[word]++
+			}
+		}
+		shift := 0.0
+		if len(before) > 0 && len(after) > 0 {
+			shift = 1 - cosineSimilarity(before, after)
+		}
+		pause := min(max(segments[i].Start-segments[i-1].End, 0)/chapterPauseSeconds, 1)
+		score := (1-chapterPauseWeight)*shift + chapterPauseWeight*pause
+		gaps = append(gaps, gap{index: i, score: score})
+		sum += score
+		sumSquares += score * score
+	}
+
+	// Only gaps scoring well above the rest are boundaries, so a recording about one thing stays
+	// one chapter
+	var boundaries []int
+	if len(gaps) > 0 {
+		mean := sum / float64(len(gaps))
+		threshold := mean + math.Sqrt(max(sumSquares/float64(len(gaps))-mean*mean, 0))/2
+		slices.SortStableFunc(gaps, func(a, b gap) int { return cmp.Compare(b.score, a.score) })
+		end := segments[len(segments)-1].End
+		for _, g := range gaps {
+			if g.score <= threshold {
+				break
+			}
+			at := segments[g.index].Start
+			if at < minLength.Seconds() || end-at < minLength.Seconds() {
+				continue
+			}
+			if slices.ContainsFunc(boundaries, func(b int) bool { return math.Abs(segments[b].Start-at) < minLength.Seconds() }) {
+				continue
+			}
+			boundaries = append(boundaries, g.index)
+		}
+		slices.Sort(boundaries)
+	}
+
+	var chapters []Chapter
+	used := map[string]bool{}
+	first := 0
+	for i, last := range append(boundaries, len(segments)) {
+		chapter := Chapter{Start: segments[first].Start, End: segments[last-1].End}
+		if i == 0 {
+			chapter.Start = 0
+		}
+		chapter.Title = chapterTitle(segments[first:last], used, i+1)
+		chapters = append(chapters, chapter)
+		first = last
+	}
+	return chapters
+}
+
+// cosineSimilarity compares two word counts, from 0 for no words in common to 1 for the same mix
+func cosineSimilarity(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for word, count := range a {
+		dot += count * b[word]
+		normA += count * count
+	}
+	for _, count := range b {
+		normB += count * count
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// chapterTitle titles a chapter with its top key phrase that no earlier chapter is titled with,
+// capitalized, or "Chapter n" when it has none
+func chapterTitle(segments []Segment, used map[string]bool, n int) string {
+	for _, keyword := range ExtractKeywords(segments, 5) {
+		if !used[keyword.Phrase] {
+			used[keyword.Phrase] = true
+			first, size := utf8.DecodeRuneInString(keyword.Phrase)
+			return string(unicode.ToUpper(first)) + keyword.Phrase[size:]
+		}
+	}
+	return fmt.Sprintf("Chapter %d", n)
+}
+
+// RenderYouTubeChapters renders chapters as the timestamped lines YouTube reads from a video's
+// description, such as "04:12 Quarterly results". Hours are added to every line when the last
+// chapter starts an hour or more in
+func RenderYouTubeChapters(chapters []Chapter) string {
+	hours := len(chapters) > 0 && chapters[len(chapters)-1].Start >= 3600
+	var b strings.Builder
+	for _, chapter := range chapters {
+		seconds := int64(chapter.Start)
+		if hours {
+			fmt.Fprintf(&b, "%d:%02d:%02d %s\n", seconds/3600, seconds/60%60, seconds%60, chapter.Title)
+		} else {
+			fmt.Fprintf(&b, "%02d:%02d %s\n", seconds/60, seconds%60, chapter.Title)
+		}
+	}
+	return b.String()
+}
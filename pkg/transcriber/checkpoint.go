@@ -0,0 +1,84 @@
+package transcriber
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// Checkpoint keeps the transcript of each chunk of a file as it finishes, so a transcription that
+// was interrupted, by a crash or a restart, can start again without sending (and paying for) the
+// chunks that were already done. Chunks are saved under a key covering the preprocessed audio and
+// every setting that shapes their transcripts, so they are only reused for the same audio sent the
+// same way
+type Checkpoint interface {
+	// LoadChunks returns the chunks saved under key, by chunk index
+	LoadChunks(key string) (map[int][]byte, error)
+
+	// SaveChunk saves the transcript of the chunk at index under key
+	SaveChunk(key string, index int, data []byte) error
+}
+
+// chunkCheckpoint is a file's Checkpoint along with the key its chunks are saved under and the
+// chunks that were already saved when it was opened. A nil *chunkCheckpoint saves nothing
+type chunkCheckpoint struct {
+	store  Checkpoint
+	key    string
+	saved  map[int]*chunkTranscription
+	logger *slog.Logger
+}
+
+// openCheckpoint loads the chunks saved for preprocessed audio with the given hash, or returns
+// nil without a Checkpoint. Failing to load only means every chunk is sent again
+func (t *Transcriber) openCheckpoint(store Checkpoint, logger *slog.Logger, audioHash string, request chunkRequest) *chunkCheckpoint {
+	if store == nil {
+		return nil
+	}
+	settings := fmt.Sprintf("%s\x00%s\x00%s\x00%g\x00%t\x00%t\x00%t\x00%t\x00%g\x00%g\x00%g\x00%s\x00%v", audioHash, request.Model, request.Prompt,
+		request.Temperature, request.WordTimestamps, request.DetectLanguage, request.SkipNonSpeech, request.RemoveSilence, t.opts.ChunkSeconds, t.opts.OverlapSeconds, t.opts.SilenceSeconds,
+		cmp.Or(request.Language, t.opts.Language), request.LanguageModels)
+	sum := sha256.Sum256([]byte(settings))
+	checkpoint := &chunkCheckpoint{store: store, key: hex.EncodeToString(sum[:]), saved: map[int]*chunkTranscription{}, logger: logger}
+
+	chunks, err := store.LoadChunks(checkpoint.key)
+	if err != nil {
+		logger.Warn("Unable to load chunk checkpoint", "error", err)
+		return checkpoint
+	}
+	for index, data := range chunks {
+		var transcription chunkTranscription
+		if err := json.Unmarshal(data, &transcription); err == nil {
+			checkpoint.saved[index] = &transcription
+		}
+	}
+	if len(checkpoint.saved) > 0 {
+		logger.Info("Resuming from chunk checkpoint", "chunks", len(checkpoint.saved))
+	}
+	return checkpoint
+}
+
+// load returns the saved transcript of the chunk at index, or nil when it wasn't saved
+func (c *chunkCheckpoint) load(index int) *chunkTranscription {
+	if c == nil {
+		return nil
+	}
+	return c.saved[index]
+}
+
+// save records the transcript of the chunk at index. Failing to save only means the chunk would
+// be sent again if the file is resumed
+func (c *chunkCheckpoint) save(index int, transcription *chunkTranscription) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(transcription)
+	if err == nil {
+		err = c.store.SaveChunk(c.key, index, data)
+	}
+	if err != nil {
+		c.logger.Warn("Unable to save chunk checkpoint", "chunk", index, "error", err)
+	}
+}
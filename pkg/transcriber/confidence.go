@@ -0,0 +1,36 @@
+package transcriber
+
+// LowConfidenceOpen and LowConfidenceClose wrap the text of low-confidence segments by
+// MarkLowConfidence, so they stand out in plain-text renderings
+const (
+	LowConfidenceOpen  = "⟦"
+	LowConfidenceClose = "⟧"
+)
+
+// FlagLowConfidence returns a copy of segments with LowConfidence set on those whose Confidence is
+// below minConfidence, and how many that is. Segments the API didn't score are never flagged
+func FlagLowConfidence(segments []Segment, minConfidence float64) ([]Segment, int) {
+	flagged := make([]Segment, len(segments))
+	count := 0
+	for i, segment := range segments {
+		segment.LowConfidence = segment.Confidence != nil && *segment.Confidence < minConfidence
+		if segment.LowConfidence {
+			count++
+		}
+		flagged[i] = segment
+	}
+	return flagged, count
+}
+
+// MarkLowConfidence returns a copy of segments with the text of those flagged LowConfidence
+// wrapped in LowConfidenceOpen and LowConfidenceClose
+func MarkLowConfidence(segments []Segment) []Segment {
+	marked := make([]Segment, len(segments))
+	for i, segment := range segments {
+		if segment.LowConfidence {
+			segment.Text = LowConfidenceOpen + segment.Text + LowConfidenceClose
+		}
+		marked[i] = segment
+	}
+	return marked
+}
@@ -0,0 +1,209 @@
+package transcriber
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// dictionaryFuzzyLength is the shortest term whose near misses are corrected. Shorter terms
+	// only have their casing fixed, since short words are too often a letter away from ordinary ones
+	dictionaryFuzzyLength = 5
+
+	// dictionaryErrorShare is how many of a term's letters may be wrong in a near miss: one in four
+	dictionaryErrorShare = 4
+)
+
+// Correction is a spelling in a transcript replaced with a dictionary term
+type Correction struct {
+	// Segment is the index of the corrected segment, and Start when it starts
+	Segment int     `json:"segment"`
+	Start   float64 `json:"start"`
+
+	Original  string `json:"original"`
+	Corrected string `json:"corrected"`
+}
+
+// dictionaryTerm is a term with the key its near misses are measured against: its letters and
+// digits, lowercased
+type dictionaryTerm struct {
+	text  string
+	key   []rune
+	words int
+}
+
+// Dictionary corrects a transcript's near-miss spellings of domain terms, such as drug names,
+// product SKUs, and people's names, that a model has no way of knowing how to spell
+type Dictionary struct {
+	terms    []dictionaryTerm
+	maxWords int
+}
+
+// NewDictionary builds a dictionary of terms, spelled and cased as they should appear. A term may
+// be several words long
+func NewDictionary(terms []string) *Dictionary {
+	d := &Dictionary{}
+	for _, term := range terms {
+		words := strings.Fields(term)
+		key := dictionaryKey(words)
+		if len(key) == 0 {
+			continue
+		}
+		d.terms = append(d.terms, dictionaryTerm{text: strings.Join(words, " "), key: key, words: len(words)})
+		d.maxWords = max(d.maxWords, len(words))
+	}
+	return d
+}
+
+// ReadDictionary reads dictionary terms with one per line, skipping blank lines and # comments
+func ReadDictionary(r io.Reader) ([]string, error) {
+	return readWordList(r)
+}
+
+// Correct returns a copy of segments with near-miss spellings of the dictionary's terms replaced
+// by the terms, and the corrections made. A run of up to two words more or one fewer than a term
+// can match it, so "lip it or" becomes "Lipitor", and a run matches when at most one in four of the term's
+// letters differ, ignoring case and punctuation. Terms under five letters only match exactly,
+// fixing their casing. Masks like [EMAIL] are never corrected
+func (d *Dictionary) Correct(segments []Segment) ([]Segment, []Correction) {
+	corrected := slices.Clone(segments)
+	var corrections []Correction
+	if len(d.terms) == 0 {
+		return corrected, corrections
+	}
+	for i := range corrected {
+		words := strings.Fields(corrected[i].Text)
+		changed := false
+		for at := 0; at < len(words); at++ {
+			match, ok := d.bestMatch(words, at)
+			if !ok {
+				continue
+			}
+			// A closer match starting at the next word means this one only swallowed a word
+			// in front of the term
+			if next, ok := d.bestMatch(words, at+1); ok && next.distance < match.distance {
+				continue
+			}
+
+			original := strings.Join(words[at:at+match.length], " ")
+			lead, core, trail := splitPunctuation(original)
+			replacement := lead + match.term.text + trail
+			if replacement == original {
+				continue
+			}
+			corrections = append(corrections, Correction{
+				Segment:   i,
+				Start:     corrected[i].Start,
+				Original:  core,
+				Corrected: match.term.text,
+			})
+			words = slices.Replace(words, at, at+match.length, replacement)
+			changed = true
+		}
+		if changed {
+			corrected[i].Text = strings.Join(words, " ")
+		}
+	}
+	return corrected, corrections
+}
+
+// dictionaryMatch is a run of words matching a term, length words long
+type dictionaryMatch struct {
+	term     dictionaryTerm
+	length   int
+	distance int
+}
+
+// bestMatch finds the term that the run of words starting at words[at] is closest to, preferring
+// longer runs when two are as close
+func (d *Dictionary) bestMatch(words []string, at int) (dictionaryMatch, bool) {
+	var best dictionaryMatch
+	found := false
+	for length := 1; length <= d.maxWords+2 && at+length <= len(words); length++ {
+		if strings.HasPrefix(words[at+length-1], "[") {
+			break
+		}
+		_, core, _ := splitPunctuation(strings.Join(words[at:at+length], " "))
+		key := dictionaryKey(strings.Fields(core))
+		for _, term := range d.terms {
+			if term.words < length-1 || length > term.words+2 {
+				continue
+			}
+			allowed := 0
+			if len(term.key) >= dictionaryFuzzyLength {
+				allowed = len(term.key) / dictionaryErrorShare
+			}
+			if abs(len(key)-len(term.key)) > allowed {
+				continue
+			}
+			distance := editDistance(key, term.key)
+			if distance > allowed {
+				continue
+			}
+			if !found || distance < best.distance || distance == best.distance && length > best.length {
+				best = dictionaryMatch{term: term, length: length, distance: distance}
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+// splitPunctuation splits the punctuation off either end of text, along with a trailing
+// possessive "'s", so a corrected term keeps them
+func splitPunctuation(text string) (lead, core, trail string) {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }
+	start := strings.IndexFunc(text, isWord)
+	if start < 0 {
+		return text, "", ""
+	}
+	end := strings.LastIndexFunc(text, isWord) + 1
+	core = text[start:end]
+	for _, possessive := range []string{"'s", "’s"} {
+		if stem, ok := strings.CutSuffix(core, possessive); ok && stem != "" {
+			core, end = stem, end-len(possessive)
+			break
+		}
+	}
+	return text[:start], core, text[end:]
+}
+
+// dictionaryKey joins words into their letters and digits, lowercased
+func dictionaryKey(words []string) []rune {
+	var key []rune
+	for _, word := range normalizedWords(words) {
+		key = append(key, []rune(word)...)
+	}
+	return key
+}
+
+// editDistance counts the letters inserted, deleted, or substituted to turn a into b
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,95 @@
+package transcriber
+
+import (
+	"math"
+	"strings"
+)
+
+// DiffOp says whether a run of words is in both transcripts compared, or only in one of them
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffDelete DiffOp = "delete"
+	DiffInsert DiffOp = "insert"
+)
+
+// WordChange is a run of words that two transcripts share, that only the previous one has
+// (delete), or that only the current one has (insert)
+type WordChange struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// TranscriptDiff compares a current transcript with a previous one word by word
+type TranscriptDiff struct {
+	Changes []WordChange `json:"changes"`
+
+	// MatchedWords, DeletedWords, and InsertedWords count the words in each kind of change
+	MatchedWords  int `json:"matched_words"`
+	DeletedWords  int `json:"deleted_words"`
+	InsertedWords int `json:"inserted_words"`
+
+	// WordErrorRate is the share of the previous transcript's words that the current one
+	// substitutes, deletes, or inserts, counting a word deleted and one inserted in the same
+	// place as one substitution
+	WordErrorRate float64 `json:"word_error_rate"`
+}
+
+// DiffTranscripts compares current with previous word by word, matching words in order while
+// ignoring case and punctuation as AlignScript does. Matched words keep the current transcript's
+// spelling
+func DiffTranscripts(previous, current string) TranscriptDiff {
+	previousWords, currentWords := strings.Fields(previous), strings.Fields(current)
+	diff := TranscriptDiff{Changes: []WordChange{}}
+	if len(previousWords) == 0 {
+		diff.add(DiffInsert, currentWords)
+		return diff
+	}
+
+	var errors, deleted, inserted int
+	endRun := func() {
+		errors += max(deleted, inserted)
+		deleted, inserted = 0, 0
+	}
+	next := 0
+	for i, match := range matchWords(normalizedWords(previousWords), normalizedWords(currentWords)) {
+		if match < 0 {
+			diff.add(DiffDelete, previousWords[i:i+1])
+			deleted++
+			continue
+		}
+		diff.add(DiffInsert, currentWords[next:match])
+		inserted += match - next
+		endRun()
+		diff.add(DiffEqual, currentWords[match:match+1])
+		next = match + 1
+	}
+	diff.add(DiffInsert, currentWords[next:])
+	inserted += len(currentWords) - next
+	endRun()
+
+	diff.WordErrorRate = math.Round(float64(errors)/float64(len(previousWords))*1e4) / 1e4
+	return diff
+}
+
+// add appends words as a change, merging them into the last change when it is of the same kind
+func (d *TranscriptDiff) add(op DiffOp, words []string) {
+	if len(words) == 0 {
+		return
+	}
+	switch op {
+	case DiffEqual:
+		d.MatchedWords += len(words)
+	case DiffDelete:
+		d.DeletedWords += len(words)
+	case DiffInsert:
+		d.InsertedWords += len(words)
+	}
+	text := strings.Join(words, " ")
+	if last := len(d.Changes) - 1; last >= 0 && d.Changes[last].Op == op {
+		d.Changes[last].Text += " " + text
+		return
+	}
+	d.Changes = append(d.Changes, WordChange{Op: op, Text: text})
+}
@@ -0,0 +1,410 @@
+package transcriber
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// A FileCipher encrypts files at rest with AES-256-GCM under a random key that only ever lives in
+// the FileCipher, so nothing written with it can be read once it is gone. Files are split into
+// segments sealed one by one, which streams them in both directions and lets ffmpeg and ffprobe
+// seek in them; each segment's nonce carries its position and whether it is the last, so
+// segments can't be reordered, dropped, or truncated without being noticed
+type FileCipher struct {
+	aead cipher.AEAD
+}
+
+const (
+	// encryptedSegmentBytes is how much plaintext each sealed segment holds
+	encryptedSegmentBytes = 64 << 10
+
+	// encryptedPrefixBytes is the random nonce prefix at the start of each file; the other 5
+	// bytes of a segment's nonce are its index and the last-segment flag
+	encryptedPrefixBytes = 7
+)
+
+// errCorruptFile is returned when an encrypted file was modified, truncated, or sealed with
+// another key
+var errCorruptFile = errors.New("encrypted file is corrupt or was written with another key")
+
+// NewFileCipher returns a FileCipher with a new random key
+func NewFileCipher() (*FileCipher, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileCipher{aead: aead}, nil
+}
+
+// nonce returns the nonce of segment index of a file
+func (c *FileCipher) nonce(prefix []byte, index int64, last bool) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptedPrefixBytes:], uint32(index))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// Create creates or truncates the file at path and returns a writer that encrypts what is written
+// to it. The file is only complete once the writer is closed
+func (c *FileCipher) Create(path string) (io.WriteCloser, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, encryptedPrefixBytes)
+	if _, err := rand.Read(prefix); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Write(prefix); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &encryptingWriter{cipher: c, file: file, prefix: prefix, buffer: make([]byte, 0, encryptedSegmentBytes)}, nil
+}
+
+// encryptingWriter seals segments as they fill up. A full segment is only sealed once more data
+// arrives, since the last one has to be sealed as such
+type encryptingWriter struct {
+	cipher *FileCipher
+	file   *os.File
+	prefix []byte
+	buffer []byte
+	index  int64
+	closed bool
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(w.buffer) == encryptedSegmentBytes {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buffer[len(w.buffer):encryptedSegmentBytes], p)
+		w.buffer = w.buffer[:len(w.buffer)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// seal encrypts and writes the buffered segment
+func (w *encryptingWriter) seal(last bool) error {
+	sealed := w.cipher.aead.Seal(nil, w.cipher.nonce(w.prefix, w.index, last), w.buffer, nil)
+	if _, err := w.file.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// Close seals the last segment and closes the file
+func (w *encryptingWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.seal(true)
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// EncryptedFile is a file written by a FileCipher, read back as plaintext. Reads may be
+// sequential or at any offset, and ReadAt may be called concurrently
+type EncryptedFile struct {
+	cipher   *FileCipher
+	file     *os.File
+	prefix   []byte
+	size     int64
+	segments int64
+	offset   int64
+
+	// The last segment decrypted is kept, since reads tend to come in runs within one
+	mu           sync.Mutex
+	cachedIndex  int64
+	cachedPlain  []byte
+	cachedFilled bool
+}
+
+// Open opens a file written by Create for reading
+func (c *FileCipher) Open(path string) (*EncryptedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	prefix := make([]byte, encryptedPrefixBytes)
+	if _, err := io.ReadFull(file, prefix); err != nil {
+		file.Close()
+		return nil, errCorruptFile
+	}
+
+	// Every segment carries a tag, and every one but the last is full
+	sealedSegment := int64(encryptedSegmentBytes + c.aead.Overhead())
+	body := info.Size() - encryptedPrefixBytes
+	segments := (body + sealedSegment - 1) / sealedSegment
+	size := body - segments*int64(c.aead.Overhead())
+	if segments == 0 || size < 0 {
+		file.Close()
+		return nil, errCorruptFile
+	}
+	return &EncryptedFile{cipher: c, file: file, prefix: prefix, size: size, segments: segments}, nil
+}
+
+// Size is the length of the plaintext
+func (f *EncryptedFile) Size() int64 {
+	return f.size
+}
+
+// segment returns the plaintext of segment index
+func (f *EncryptedFile) segment(index int64) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cachedFilled && f.cachedIndex == index {
+		return f.cachedPlain, nil
+	}
+
+	overhead := int64(f.cipher.aead.Overhead())
+	sealedSegment := int64(encryptedSegmentBytes) + overhead
+	start := encryptedPrefixBytes + index*sealedSegment
+	length := min(sealedSegment, encryptedPrefixBytes+f.size+f.segments*overhead-start)
+	sealed := make([]byte, length)
+	if _, err := f.file.ReadAt(sealed, start); err != nil {
+		return nil, err
+	}
+	plain, err := f.cipher.aead.Open(sealed[:0], f.cipher.nonce(f.prefix, index, index == f.segments-1), sealed, nil)
+	if err != nil {
+		return nil, errCorruptFile
+	}
+	f.cachedIndex, f.cachedPlain, f.cachedFilled = index, plain, true
+	return plain, nil
+}
+
+func (f *EncryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	read := 0
+	for read < len(p) {
+		if off >= f.size {
+			return read, io.EOF
+		}
+		plain, err := f.segment(off / encryptedSegmentBytes)
+		if err != nil {
+			return read, err
+		}
+		n := copy(p[read:], plain[off%encryptedSegmentBytes:])
+		read += n
+		off += int64(n)
+	}
+	return read, nil
+}
+
+func (f *EncryptedFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *EncryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *EncryptedFile) Close() error {
+	return f.file.Close()
+}
+
+// EncryptFile replaces the plaintext file at path with its encryption
+func (c *FileCipher) EncryptFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	sealedPath := path + ".sealed"
+	out, err := c.Create(sealedPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(sealedPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(sealedPath)
+		return err
+	}
+	return os.Rename(sealedPath, path)
+}
+
+// fileCipherKey is the context key of the FileCipher a transcription's files are encrypted with
+type fileCipherKey struct{}
+
+// withFileCipher makes the pipeline functions called with ctx read and write encrypted files.
+// A nil cipher leaves them plaintext
+func withFileCipher(ctx context.Context, c *FileCipher) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, fileCipherKey{}, c)
+}
+
+// fileCipherFrom returns the FileCipher set on ctx, or nil when files are plaintext
+func fileCipherFrom(ctx context.Context) *FileCipher {
+	c, _ := ctx.Value(fileCipherKey{}).(*FileCipher)
+	return c
+}
+
+// mediaFile is an open file of a transcription, plaintext or decrypted
+type mediaFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+	Size() int64
+}
+
+// plainFile is a plaintext mediaFile
+type plainFile struct {
+	*os.File
+	size int64
+}
+
+func (f plainFile) Size() int64 {
+	return f.size
+}
+
+// openMedia opens a file of the transcription, decrypting it when ctx has a FileCipher
+func openMedia(ctx context.Context, path string) (mediaFile, error) {
+	if c := fileCipherFrom(ctx); c != nil {
+		return c.Open(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return plainFile{File: file, size: info.Size()}, nil
+}
+
+// createMedia creates a file of the transcription, encrypting it when ctx has a FileCipher
+func createMedia(ctx context.Context, path string) (io.WriteCloser, error) {
+	if c := fileCipherFrom(ctx); c != nil {
+		return c.Create(path)
+	}
+	return os.Create(path)
+}
+
+// mediaInput returns what ffmpeg or ffprobe should read path as: the path itself, or for an
+// encrypted file, a URL on a loopback server that decrypts it, with ranges so they can seek.
+// The URL holds a random token, and the server stops when stop is called
+func mediaInput(ctx context.Context, path string) (input string, stop func(), err error) {
+	c := fileCipherFrom(ctx)
+	if c == nil {
+		return path, func() {}, nil
+	}
+	file, err := c.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		file.Close()
+		return "", nil, err
+	}
+	token := make([]byte, 16)
+	rand.Read(token)
+	route := "/" + hex.EncodeToString(token)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != route {
+				http.NotFound(w, r)
+				return
+			}
+			// Each request reads through its own section, so concurrent ranges don't share an offset
+			http.ServeContent(w, r, "", time.Time{}, io.NewSectionReader(file, 0, file.Size()))
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+	return "http://" + listener.Addr().String() + route, func() {
+		server.Close()
+		file.Close()
+	}, nil
+}
+
+// runToMedia runs an ffmpeg command whose output goes to stdout, streaming it into path through
+// createMedia
+func runToMedia(ctx context.Context, spanName string, cmd *exec.Cmd, path string) error {
+	out, err := createMedia(ctx, path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriterSize(out, encryptedSegmentBytes)
+	cmd.Stdout = writer
+	if err := runCommand(ctx, spanName, cmd); err != nil {
+		out.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package transcriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Stage names the pipeline step an error came from
+type Stage string
+
+// Pipeline stages, in the order they run
+const (
+	StageValidate     Stage = "validate"
+	StageSelectStream Stage = "select_stream"
+	StagePreprocess   Stage = "preprocess"
+	StageHash         Stage = "hash"
+	StageAnalyze      Stage = "analyze"
+	StageChunk        Stage = "chunk"
+	StageTranscribe   Stage = "transcribe"
+
+	// StageIngest is pulling a live stream, which stands in for every other stage
+	StageIngest Stage = "ingest"
+)
+
+// StageError is returned by Transcribe when a pipeline step fails. Errors from
+// StageValidate and StageSelectStream mean the input itself is unusable
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return string(e.Stage) + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// DurationLimitError is returned from StageValidate when the media is longer than Options.MaxDuration
+type DurationLimitError struct {
+	Duration time.Duration
+	Limit    time.Duration
+}
+
+func (e *DurationLimitError) Error() string {
+	return fmt.Sprintf("media is %s long, which exceeds the maximum of %s", e.Duration, e.Limit)
+}
+
+// TimeRangeError is returned from StageValidate when TranscribeOptions.Start is at or past the
+// end of the media, which is Duration seconds long
+type TimeRangeError struct {
+	Start    float64
+	Duration float64
+}
+
+func (e *TimeRangeError) Error() string {
+	return fmt.Sprintf("start (%gs) is past the end of the media (%gs)", e.Start, e.Duration)
+}
+
+// TimeoutError is wrapped in a StageError when a stage runs past its timeout in Options, or
+// returned from a chunk request that does. It matches context.DeadlineExceeded with errors.Is
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// timedStage runs fn with ctx bounded by timeout (when it is positive) and wraps any error with
+// the stage, reporting a TimeoutError when the stage ran out of time rather than ctx being done
+func timedStage(ctx context.Context, stage Stage, timeout time.Duration, fn func(context.Context) error) error {
+	stageCtx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(stageCtx)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == nil && errors.Is(stageCtx.Err(), context.DeadlineExceeded) {
+		return &StageError{Stage: stage, Err: &TimeoutError{Timeout: timeout}}
+	}
+	return stageError(ctx, stage, err)
+}
+
+// withTimeout bounds ctx by timeout, or only by ctx itself when timeout isn't positive
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// stageError wraps err with the stage it came from, unless the stage only failed because
+// ctx was canceled, in which case the context error is returned instead
+func stageError(ctx context.Context, stage Stage, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return &StageError{Stage: stage, Err: err}
+}
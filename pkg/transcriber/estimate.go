@@ -0,0 +1,87 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Rough throughput figures behind Estimate's processing time
+const (
+	// estimatedPreprocessSpeed is how many seconds of audio ffmpeg decodes and resamples per second
+	estimatedPreprocessSpeed = 100.0
+
+	// estimatedChunkSeconds is the typical time the API takes to transcribe one chunk
+	estimatedChunkSeconds = 6.0
+)
+
+// Estimate predicts what transcribing a file would involve
+type Estimate struct {
+	// DurationSeconds is the length of the media as reported by ffprobe
+	DurationSeconds float64
+
+	// Chunks is how many chunks would be sent to the API
+	Chunks int
+
+	// AudioSeconds is how much audio would be sent to the API
+	AudioSeconds float64
+
+	// ProcessingSeconds is a rough prediction of how long the pipeline would take once it starts
+	ProcessingSeconds float64
+}
+
+// Estimate probes the media file at inputPath and plans its chunks without preprocessing or
+// transcribing anything. It fails the same way Transcribe would for unreadable media or an
+// audio track that doesn't exist
+func (t *Transcriber) Estimate(ctx context.Context, inputPath string, opts TranscribeOptions) (*Estimate, error) {
+	if err := ValidateTimeRange(opts.Start, opts.End); err != nil {
+		return nil, &StageError{Stage: StageValidate, Err: err}
+	}
+	mediaInfo, err := ValidateMedia(ctx, inputPath)
+	if err == nil {
+		err = checkTimeRange(mediaInfo, opts.Start)
+	}
+	if err == nil {
+		err = t.checkDuration(mediaInfo, opts)
+	}
+	if err != nil {
+		return nil, stageError(ctx, StageValidate, err)
+	}
+	stream, err := SelectAudioStream(mediaInfo, opts.AudioTrack, opts.AudioLanguage)
+	if err != nil {
+		return nil, &StageError{Stage: StageSelectStream, Err: err}
+	}
+	passes := 1
+	if opts.SplitChannels {
+		passes = len(opts.ChannelLabels)
+		if passes == 0 {
+			passes = len(DefaultChannelLabels)
+		}
+		if stream.Channels != passes {
+			return nil, &StageError{Stage: StageSelectStream, Err: fmt.Errorf("split channels needs an audio stream with %d channels, one for each label, but stream %d has %d channel(s)", passes, stream.Index, stream.Channels)}
+		}
+	}
+
+	duration, err := strconv.ParseFloat(mediaInfo.Duration, 64)
+	if err != nil {
+		return nil, &StageError{Stage: StageAnalyze, Err: fmt.Errorf("unable to parse duration %q: %w", mediaInfo.Duration, err)}
+	}
+	if opts.End > 0 {
+		duration = min(duration, opts.End)
+	}
+	duration -= opts.Start
+
+	plan := planChunks(duration, t.opts.ChunkSeconds, t.opts.OverlapSeconds)
+
+	// Chunks are transcribed in waves of MaxConcurrentChunks, once per channel with SplitChannels
+	waves := math.Ceil(float64(plan.TotalChunks) / float64(t.opts.MaxConcurrentChunks))
+	processing := float64(passes) * (duration/estimatedPreprocessSpeed + waves*estimatedChunkSeconds)
+
+	return &Estimate{
+		DurationSeconds:   duration,
+		Chunks:            passes * plan.TotalChunks,
+		AudioSeconds:      float64(passes) * duration,
+		ProcessingSeconds: math.Round(processing),
+	}, nil
+}
@@ -0,0 +1,75 @@
+package transcriber
+
+import (
+	"cmp"
+	"slices"
+)
+
+// AudioEvent is a sound other than speech heard in a recording, such as laughter, applause, or a
+// phone ringing, with times relative to the start of the audio
+type AudioEvent struct {
+	Label string  `json:"label"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+
+	// Score is how sure the tagging model was of the label, from 0 to 1, or 0 when the event is a
+	// segment the transcription model judged not to be speech
+	Score float64 `json:"score,omitempty"`
+}
+
+// EventSpans returns the stretches of a recording that may hold sounds other than speech: the
+// gaps of at least minGap seconds between its segments and at either end, and the segments the
+// model judged not to be speech. Long stretches are split into spans of at most maxSpan seconds,
+// so each can be tagged on its own
+func EventSpans(segments []Segment, duration, minGap, maxSpan float64) []ClipSpan {
+	var stretches []ClipSpan
+	speechEnd := 0.0
+	for _, segment := range segments {
+		if segment.NoSpeech {
+			stretches = append(stretches, ClipSpan{Start: segment.Start, End: segment.End})
+			continue
+		}
+		if segment.Start-speechEnd >= minGap {
+			stretches = append(stretches, ClipSpan{Start: speechEnd, End: segment.Start})
+		}
+		speechEnd = max(speechEnd, segment.End)
+	}
+	if duration-speechEnd >= minGap {
+		stretches = append(stretches, ClipSpan{Start: speechEnd, End: duration})
+	}
+	slices.SortFunc(stretches, func(a, b ClipSpan) int { return cmp.Compare(a.Start, b.Start) })
+
+	var spans []ClipSpan
+	for _, stretch := range stretches {
+		for start := stretch.Start; stretch.End-start > 0; start += maxSpan {
+			spans = append(spans, ClipSpan{Start: start, End: min(start+maxSpan, stretch.End)})
+		}
+	}
+	return spans
+}
+
+// CaptionSegments returns segments with a cue for each event, its label in brackets like
+// "[Laughter]", in time order, so captions show sounds as well as speech. Segments the model
+// judged not to be speech are dropped where events were tagged over them
+func CaptionSegments(segments []Segment, events []AudioEvent) []Segment {
+	if len(events) == 0 {
+		return segments
+	}
+	captions := make([]Segment, 0, len(segments)+len(events))
+	for _, segment := range segments {
+		covered := segment.NoSpeech && slices.ContainsFunc(events, func(event AudioEvent) bool {
+			return event.Start < segment.End && event.End > segment.Start
+		})
+		if !covered {
+			captions = append(captions, segment)
+		}
+	}
+	for _, event := range events {
+		captions = append(captions, Segment{Start: event.Start, End: event.End, Text: "[" + event.Label + "]"})
+	}
+	slices.SortStableFunc(captions, func(a, b Segment) int { return cmp.Compare(a.Start, b.Start) })
+	for i := range captions {
+		captions[i].ID = i
+	}
+	return captions
+}
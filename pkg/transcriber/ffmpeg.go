@@ -0,0 +1,436 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hashFile returns the hex-encoded SHA-256 of a file's contents, decrypted when it is encrypted
+func hashFile(ctx context.Context, filePath string) (string, error) {
+	file, err := openMedia(ctx, filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// loudnormFilter normalizes loudness to EBU R128 at a level suited to speech
+const loudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// afftdnFilter is the FFT denoiser used when no RNNoise model is configured
+const afftdnFilter = "afftdn=nf=-25"
+
+// audioFilters holds the optional filters applied while preprocessing
+type audioFilters struct {
+	// Channel, when set, keeps only this 1-based channel instead of mixing every channel down to mono
+	Channel int
+
+	Denoise   bool
+	Normalize bool
+
+	// Custom is a caller-supplied chain already checked by ValidateAudioFilters
+	Custom string
+
+	// RNNoiseModel switches denoising from afftdn to arnndn with this model file
+	RNNoiseModel string
+
+	// Speed, above 1, speeds the audio up with atempo without changing its pitch
+	Speed float64
+
+	// Start and End, when set, cut the stream down to that range of seconds before any filter
+	Start float64
+	End   float64
+}
+
+// trimmed reports whether only part of the stream is kept
+func (f audioFilters) trimmed() bool {
+	return f.Start > 0 || f.End > 0
+}
+
+// length returns how many seconds of the stream to keep from Start, at most seconds when that is
+// set, or 0 for all the rest
+func (f audioFilters) length(seconds float64) float64 {
+	if f.End > 0 && (seconds == 0 || f.End-f.Start < seconds) {
+		return f.End - f.Start
+	}
+	return seconds
+}
+
+// chain returns the ffmpeg -af filter graph, or "" when no filter is enabled. Noise is removed
+// first, then custom filters run, and normalizing comes last so it sees the final signal and
+// the noise floor isn't amplified along with the speech. Speeding up comes after all of them, so
+// they work on the audio as it was recorded
+func (f audioFilters) chain() string {
+	var filters []string
+	if f.Channel > 0 {
+		filters = append(filters, fmt.Sprintf("pan=mono|c0=c%d", f.Channel-1))
+	}
+	if f.Denoise {
+		if f.RNNoiseModel != "" {
+			filters = append(filters, "arnndn=m="+escapeFilterValue(f.RNNoiseModel))
+		} else {
+			filters = append(filters, afftdnFilter)
+		}
+	}
+	if f.Custom != "" {
+		filters = append(filters, f.Custom)
+	}
+	if f.Normalize {
+		filters = append(filters, loudnormFilter)
+	}
+	// atempo takes at most 2 at a time, so faster speeds are chained
+	for speed := f.Speed; speed > 1; speed /= 2 {
+		filters = append(filters, "atempo="+strconv.FormatFloat(min(speed, 2), 'g', -1, 64))
+	}
+	return strings.Join(filters, ",")
+}
+
+// escapeFilterValue quotes characters that are special in an ffmpeg filter graph
+func escapeFilterValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`, `,`, `\,`).Replace(value)
+}
+
+func preprocessAudioFile(ctx context.Context, inputFilePath, outputFilePath string, streamIndex int, filters audioFilters) error {
+	return preprocessAudioSample(ctx, inputFilePath, outputFilePath, streamIndex, filters, 0)
+}
+
+// isPreprocessed reports whether a file is already what preprocessing would turn it into: a lone
+// 16 kHz mono stream of FLAC, or of 16-bit PCM WAV, which is no larger than the transcode. An
+// encrypted file is chunked in-process, so only WAV will do
+func isPreprocessed(ctx context.Context, info MediaInfo, stream StreamInfo) bool {
+	if len(info.Streams) != 1 || stream.SampleRate != nativeSampleRate || stream.Channels != 1 {
+		return false
+	}
+	switch {
+	case info.FormatName == "wav" && stream.CodecName == "pcm_s16le":
+		return true
+	case info.FormatName == "flac" && stream.CodecName == "flac":
+		return fileCipherFrom(ctx) == nil
+	}
+	return false
+}
+
+// usePreprocessed stands in for preprocessAudioFile when isPreprocessed holds, hard-linking the
+// file into place, or copying it where that isn't possible
+func usePreprocessed(ctx context.Context, inputFilePath, outputFilePath string) error {
+	if err := os.Link(inputFilePath, outputFilePath); err == nil {
+		return nil
+	}
+	input, err := openMedia(ctx, inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	output, err := createMedia(ctx, outputFilePath)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	if _, err := io.Copy(output, input); err != nil {
+		return err
+	}
+	return output.Close()
+}
+
+// preprocessAudioSample is preprocessAudioFile for only the first seconds of the stream, or of the
+// range filters keep, or all of it when seconds is zero
+func preprocessAudioSample(ctx context.Context, inputFilePath, outputFilePath string, streamIndex int, filters audioFilters, seconds float64) error {
+	seconds = filters.length(seconds)
+	if useNativeAudio(ctx, FFmpegPath, inputFilePath) {
+		if filters.Denoise || filters.Normalize || filters.Custom != "" || filters.Speed > 1 {
+			return fmt.Errorf("denoise, normalize, speed, and audio filters need ffmpeg (%s), which isn't installed", FFmpegPath)
+		}
+		return preprocessWAV(ctx, inputFilePath, outputFilePath, filters.Channel, filters.Start, seconds)
+	}
+	input, stop, err := mediaInput(ctx, inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	// Seeking the input skips straight to the range rather than decoding what comes before it
+	var args []string
+	if filters.Start > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%f", filters.Start))
+	}
+	args = append(args,
+		"-i", input,
+		"-vn",
+	)
+	if seconds > 0 {
+		args = append(args, "-t", fmt.Sprintf("%f", seconds))
+	}
+	if chain := filters.chain(); chain != "" {
+		args = append(args, "-af", chain)
+	}
+	args = append(args,
+		"-ar", "16000",
+		"-ac", "1",
+		"-map", fmt.Sprintf("0:%d", streamIndex),
+	)
+
+	// Encrypted output streams through stdout as WAV, which, unlike FLAC, needs no seeking back to
+	// finish its header, and is then chunked in-process
+	if fileCipherFrom(ctx) != nil {
+		args = append(args, "-c:a", "pcm_s16le", "-f", "wav", "pipe:1")
+		return runToMedia(ctx, "ffmpeg preprocess", exec.CommandContext(ctx, FFmpegPath, args...), outputFilePath)
+	}
+	args = append(args, "-c:a", "flac", outputFilePath)
+	cmd := exec.CommandContext(ctx, FFmpegPath, args...)
+
+	return runCommand(ctx, "ffmpeg preprocess", cmd)
+}
+
+// chunkData represents information about audio chunks
+type chunkData struct {
+	DurationMs  float64
+	ChunkMs     float64
+	OverlapMs   float64
+	TotalChunks int
+}
+
+// audioChunk is a slice of the preprocessed audio and where it starts in the original
+type audioChunk struct {
+	Path     string
+	StartSec float64
+}
+
+// getAudioChunkData plans the chunks of a preprocessed file, shortened from chunkLength when the
+// file's bitrate would take them over maxBytes
+func getAudioChunkData(ctx context.Context, filePath string, chunkLength, overlap float64, maxBytes int64) (chunkData, error) {
+	if fileCipherFrom(ctx) != nil || useNativeAudio(ctx, FFprobePath, filePath) {
+		file, format, err := openWAV(ctx, filePath)
+		if err != nil {
+			return chunkData{}, err
+		}
+		file.Close()
+		return fitChunks(planChunks(format.duration(), chunkLength, overlap), file.Size(), maxBytes), nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return chunkData{}, err
+	}
+
+	// Run ffprobe to get audio duration
+	cmd := exec.CommandContext(
+		ctx,
+		FFprobePath,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "json",
+		filePath,
+	)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err = runCommand(ctx, "ffprobe duration", cmd)
+	if err != nil {
+		return chunkData{}, err
+	}
+
+	// Parse the JSON output
+	var result struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		return chunkData{}, err
+	}
+
+	duration, err := strconv.ParseFloat(result.Format.Duration, 64)
+	if err != nil {
+		return chunkData{}, fmt.Errorf("unable to parse duration: %w", err)
+	}
+
+	return fitChunks(planChunks(duration, chunkLength, overlap), info.Size(), maxBytes), nil
+}
+
+// planChunks works out how audio of the given duration is split into overlapping chunks
+func planChunks(duration, chunkLength, overlap float64) chunkData {
+	durationMs := duration * 1000
+	chunkMs := chunkLength * 1000
+	overlapMs := overlap * 1000
+	totalChunks := int(durationMs/(chunkMs-overlapMs)) + 1
+
+	return chunkData{
+		DurationMs:  durationMs,
+		ChunkMs:     chunkMs,
+		OverlapMs:   overlapMs,
+		TotalChunks: totalChunks,
+	}
+}
+
+// chunkSizeMargin is the share of the byte budget a chunk is planned to fill, leaving room for
+// passages that compress worse than the file's average
+const chunkSizeMargin = 0.8
+
+// minChunkSeconds is the shortest a chunk is made, beyond its overlap, to fit the byte budget
+const minChunkSeconds = 1.0
+
+// fitChunks replans chunks of a file of size bytes shorter when, at the file's average bitrate,
+// they would come out over maxBytes. The overlap is kept as it is
+func fitChunks(data chunkData, size, maxBytes int64) chunkData {
+	if maxBytes <= 0 || size <= 0 || data.DurationMs <= 0 {
+		return data
+	}
+	fitMs := float64(maxBytes) * chunkSizeMargin / (float64(size) / data.DurationMs)
+	if data.ChunkMs <= fitMs {
+		return data
+	}
+	chunkMs := max(fitMs, data.OverlapMs+minChunkSeconds*1000)
+	return planChunks(data.DurationMs/1000, chunkMs/1000, data.OverlapMs/1000)
+}
+
+// maxChunkOutputs bounds how many chunks one ffmpeg run writes, since it holds every output
+// file open at once
+const maxChunkOutputs = 200
+
+// chunkifyAudioFile splits the spans of a file into chunks of the length planned by data,
+// decoding it once: a single ffmpeg run writes every chunk, each an output that starts and stops
+// at its boundaries, so the overlap between them is kept. Very long files take one run per
+// maxChunkOutputs chunks. A chunk that still comes out over maxBytes is split into shorter ones
+func chunkifyAudioFile(ctx context.Context, filePath, outputDir string, data chunkData, spans []ClipSpan, maxBytes int64) ([]audioChunk, error) {
+	// Work out every chunk's boundaries up front, planning each span as if it were a file of its own
+	var chunks []audioChunk
+	var durations []float64
+	for _, span := range spans {
+		plan := data
+		if span.Start > 0 || span.End*1000 < data.DurationMs {
+			plan = planChunks(span.End-span.Start, data.ChunkMs/1000, data.OverlapMs/1000)
+		}
+		for i := range plan.TotalChunks {
+			startMs := float64(i) * (plan.ChunkMs - plan.OverlapMs)
+			endMs := min(startMs+plan.ChunkMs, plan.DurationMs)
+			chunks = append(chunks, audioChunk{
+				Path:     filepath.Join(outputDir, fmt.Sprintf("chunk_%d.flac", len(chunks)+1)),
+				StartSec: span.Start + startMs/1000,
+			})
+			durations = append(durations, (endMs-startMs)/1000)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	if err := cutChunks(ctx, filePath, chunks, durations); err != nil {
+		return nil, err
+	}
+	if maxBytes <= 0 {
+		return chunks, nil
+	}
+
+	var fitted []audioChunk
+	for i, chunk := range chunks {
+		pieces, err := fitChunkFile(ctx, filePath, chunk, durations[i], data.OverlapMs/1000, maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", i, err)
+		}
+		fitted = append(fitted, pieces...)
+	}
+	return fitted, nil
+}
+
+// fitChunkFile returns chunk, lasting duration seconds, as it is when its file is no larger than
+// maxBytes, and otherwise replaces it with as many shorter chunks, overlapping as chunks do, as
+// should fit, splitting those again if they still don't
+func fitChunkFile(ctx context.Context, filePath string, chunk audioChunk, duration, overlap float64, maxBytes int64) ([]audioChunk, error) {
+	file, err := openMedia(ctx, chunk.Path)
+	if err != nil {
+		return nil, err
+	}
+	size := file.Size()
+	file.Close()
+	if size <= maxBytes {
+		return []audioChunk{chunk}, nil
+	}
+
+	count := int(math.Ceil(float64(size) / (float64(maxBytes) * chunkSizeMargin)))
+	length := (duration + float64(count-1)*overlap) / float64(count)
+	if length < overlap+minChunkSeconds {
+		return nil, fmt.Errorf("%d bytes is over the %d-byte limit, and %.1f seconds is too short to split", size, maxBytes, duration)
+	}
+	pieces := make([]audioChunk, count)
+	durations := make([]float64, count)
+	end := chunk.StartSec + duration
+	for i := range pieces {
+		start := chunk.StartSec + float64(i)*(length-overlap)
+		pieces[i] = audioChunk{
+			Path:     fmt.Sprintf("%s_%d.flac", strings.TrimSuffix(chunk.Path, ".flac"), i+1),
+			StartSec: start,
+		}
+		durations[i] = min(length, end-start)
+	}
+	if err := cutChunks(ctx, filePath, pieces, durations); err != nil {
+		return nil, err
+	}
+	os.Remove(chunk.Path)
+
+	var fitted []audioChunk
+	for i, piece := range pieces {
+		more, err := fitChunkFile(ctx, filePath, piece, durations[i], overlap, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		fitted = append(fitted, more...)
+	}
+	return fitted, nil
+}
+
+// cutChunks writes chunks of a file, each lasting durations seconds from its start
+func cutChunks(ctx context.Context, filePath string, chunks []audioChunk, durations []float64) error {
+	// WAV files chunked in-process are cut straight from their samples, with nothing to decode
+	if fileCipherFrom(ctx) != nil || useNativeAudio(ctx, FFmpegPath, filePath) {
+		for i, chunk := range chunks {
+			if err := cutWAV(ctx, filePath, chunk.Path, chunk.StartSec, durations[i]); err != nil {
+				return fmt.Errorf("creating chunk %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	for first := 0; first < len(chunks); first += maxChunkOutputs {
+		last := min(first+maxChunkOutputs, len(chunks))
+		if err := runChunkOutputs(ctx, filePath, chunks[first:last], durations[first:last]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runChunkOutputs writes chunks, lasting durations seconds, in one ffmpeg run. The input is
+// seeked to the first chunk, so each later one starts relative to it
+func runChunkOutputs(ctx context.Context, filePath string, chunks []audioChunk, durations []float64) error {
+	base := chunks[0].StartSec
+	args := []string{"-hide_banner", "-nostats"}
+	if base > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%f", base))
+	}
+	args = append(args, "-i", filePath)
+	for i, chunk := range chunks {
+		args = append(args,
+			"-map", "0:a:0",
+			"-ss", fmt.Sprintf("%f", chunk.StartSec-base),
+			"-t", fmt.Sprintf("%f", durations[i]),
+			chunk.Path,
+		)
+	}
+	return runCommand(ctx, "ffmpeg chunk", exec.CommandContext(ctx, FFmpegPath, args...))
+}
@@ -0,0 +1,83 @@
+package transcriber
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on a caller-supplied filter chain
+const (
+	maxAudioFiltersLength = 512
+	maxAudioFilters       = 16
+)
+
+// allowedAudioFilters are the ffmpeg filters TranscribeOptions.AudioFilters may use. They only
+// shape the audio in place: none of them read files, load plugins, open sockets, or change the
+// timeline, which would throw segment timestamps off
+var allowedAudioFilters = map[string]bool{
+	"acompressor": true,
+	"adeclick":    true,
+	"adeclip":     true,
+	"afftdn":      true,
+	"agate":       true,
+	"alimiter":    true,
+	"anlmdn":      true,
+	"bandpass":    true,
+	"bandreject":  true,
+	"bass":        true,
+	"compand":     true,
+	"deesser":     true,
+	"dynaudnorm":  true,
+	"equalizer":   true,
+	"highpass":    true,
+	"highshelf":   true,
+	"loudnorm":    true,
+	"lowpass":     true,
+	"lowshelf":    true,
+	"speechnorm":  true,
+	"treble":      true,
+	"volume":      true,
+}
+
+// audioFilterPattern matches a single filter: a name and optional plain key=value or positional
+// arguments. Quotes, escapes, brackets, and semicolons are rejected so a chain can't add inputs,
+// labels, or further graph segments
+var audioFilterPattern = regexp.MustCompile(`^([a-z0-9_]+)(?:=([A-Za-z0-9_.:=+\-|/]+))?$`)
+
+// ValidateAudioFilters checks a comma-separated ffmpeg audio filter chain such as
+// "highpass=f=100,dynaudnorm" against the allowlist. An empty chain is valid
+func ValidateAudioFilters(chain string) error {
+	if chain == "" {
+		return nil
+	}
+	if len(chain) > maxAudioFiltersLength {
+		return fmt.Errorf("filter chain is longer than %d characters", maxAudioFiltersLength)
+	}
+
+	filters := strings.Split(chain, ",")
+	if len(filters) > maxAudioFilters {
+		return fmt.Errorf("filter chain has more than %d filters", maxAudioFilters)
+	}
+	for _, filter := range filters {
+		match := audioFilterPattern.FindStringSubmatch(filter)
+		if match == nil {
+			return fmt.Errorf("filter %q is malformed: expected name or name=options using letters, digits, and . : = + - | /", filter)
+		}
+		if !allowedAudioFilters[match[1]] {
+			return fmt.Errorf("filter %q is not allowed", match[1])
+		}
+	}
+	return nil
+}
+
+// AllowedAudioFilters lists the filter names ValidateAudioFilters accepts, sorted
+func AllowedAudioFilters() []string {
+	names := make([]string, 0, len(allowedAudioFilters))
+	for name := range allowedAudioFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,166 @@
+package transcriber
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Paragraph is a run of segments from one speaker without a long pause, as laid out in
+// readable renderings
+type Paragraph struct {
+	Start   float64
+	Speaker string
+	Text    string
+}
+
+// Paragraphs groups segments into paragraphs, starting a new one wherever the pause between two
+// segments is at least gap or the speaker changes
+func Paragraphs(segments []Segment, gap time.Duration) []Paragraph {
+	var paragraphs []Paragraph
+	var current []string
+	for i, segment := range segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+		if i > 0 && len(current) > 0 {
+			previous := segments[i-1]
+			pause := time.Duration((segment.Start - previous.End) * float64(time.Second))
+			if pause >= gap || segment.Speaker != previous.Speaker {
+				paragraphs[len(paragraphs)-1].Text = strings.Join(current, " ")
+				current = nil
+			}
+		}
+		if len(current) == 0 {
+			paragraphs = append(paragraphs, Paragraph{Start: segment.Start, Speaker: segment.Speaker})
+		}
+		current = append(current, text)
+	}
+	if len(current) > 0 {
+		paragraphs[len(paragraphs)-1].Text = strings.Join(current, " ")
+	}
+	return paragraphs
+}
+
+// RenderParagraphs joins segments into readable text, broken into paragraphs as Paragraphs does.
+// Each paragraph with a speaker is prefixed with it
+func RenderParagraphs(segments []Segment, gap time.Duration) string {
+	var paragraphs []string
+	for _, paragraph := range Paragraphs(segments, gap) {
+		text := paragraph.Text
+		if paragraph.Speaker != "" {
+			text = paragraph.Speaker + ": " + text
+		}
+		paragraphs = append(paragraphs, text)
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// RenderMarkdown renders segments as Markdown meeting notes, broken into paragraphs as Paragraphs
+// does. Each speaker turn starts with a heading naming the speaker and its [hh:mm:ss] start time;
+// without speaker labels every paragraph gets a heading of its own
+func RenderMarkdown(segments []Segment, gap time.Duration) string {
+	var b strings.Builder
+	paragraphs := Paragraphs(segments, gap)
+	for i, paragraph := range paragraphs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		timestamp := "[" + FormatTimestamp(paragraph.Start, ".")[:8] + "]"
+		if paragraph.Speaker == "" {
+			fmt.Fprintf(&b, "### %s\n\n", timestamp)
+		} else if i == 0 || paragraph.Speaker != paragraphs[i-1].Speaker {
+			fmt.Fprintf(&b, "### %s %s\n\n", escapeMarkdown(paragraph.Speaker), timestamp)
+		}
+		b.WriteString(escapeMarkdown(paragraph.Text) + "\n")
+	}
+	return b.String()
+}
+
+// markdownEscaper backslash-escapes the characters Markdown could read as formatting or links
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+// escapeMarkdown escapes transcript text for Markdown, so it is shown as it was spoken
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// RenderSRT renders segments as SubRip subtitles, prefixing each cue with its speaker if it has one
+func RenderSRT(segments []Segment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		text := segment.Text
+		if segment.Speaker != "" {
+			text = segment.Speaker + ": " + text
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, FormatTimestamp(segment.Start, ","), FormatTimestamp(segment.End, ","), text)
+	}
+	return b.String()
+}
+
+// RenderVTT renders segments as WebVTT subtitles, tagging each cue with its speaker's voice if it has one
+func RenderVTT(segments []Segment) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, segment := range segments {
+		text := segment.Text
+		if segment.Speaker != "" {
+			text = "<v " + segment.Speaker + ">" + text
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", FormatTimestamp(segment.Start, "."), FormatTimestamp(segment.End, "."), text)
+	}
+	return b.String()
+}
+
+// RenderLRC renders timed words as enhanced LRC for karaoke players, which highlight each word as
+// it is sung or spoken: a line per segment, starting at the segment's time, with each word
+// preceded by its start time and the line closed by its last word's end. Words go on the line of
+// the last segment that starts before them, and segments without words are rendered as plain lines
+func RenderLRC(segments []Segment, words []Word) string {
+	words = slices.Clone(words)
+	slices.SortStableFunc(words, func(a, b Word) int { return cmp.Compare(a.Start, b.Start) })
+
+	var b strings.Builder
+	next := 0
+	for i, segment := range segments {
+		var line []Word
+		for next < len(words) && (i == len(segments)-1 || words[next].Start < segments[i+1].Start) {
+			line = append(line, words[next])
+			next++
+		}
+		b.WriteString("[" + formatLRCTimestamp(segment.Start) + "]")
+		if len(line) == 0 {
+			b.WriteString(strings.TrimSpace(segment.Text) + "\n")
+			continue
+		}
+		for j, word := range line {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString("<" + formatLRCTimestamp(word.Start) + ">" + word.Word)
+		}
+		b.WriteString("<" + formatLRCTimestamp(line[len(line)-1].End) + ">\n")
+	}
+	return b.String()
+}
+
+// formatLRCTimestamp renders seconds as LRC's mm:ss.xx, with minutes going past 99 when they must
+func formatLRCTimestamp(seconds float64) string {
+	totalCs := int64(max(seconds, 0)*100 + 0.5)
+	return fmt.Sprintf("%02d:%02d.%02d", totalCs/6000, totalCs/100%60, totalCs%100)
+}
+
+// FormatTimestamp renders seconds as HH:MM:SS followed by the millisecond separator and milliseconds
+func FormatTimestamp(seconds float64, millisSeparator string) string {
+	totalMs := int64(seconds*1000 + 0.5)
+	hours := totalMs / 3_600_000
+	minutes := totalMs / 60_000 % 60
+	secs := totalMs / 1000 % 60
+	millis := totalMs % 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", hours, minutes, secs, millisSeparator, millis)
+}
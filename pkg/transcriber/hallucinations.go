@@ -0,0 +1,144 @@
+package transcriber
+
+import (
+	"slices"
+	"strings"
+)
+
+// Reasons DetectHallucinations gives for suspecting a segment
+const (
+	// HallucinationNoSpeech is a segment the model itself judged to hold no speech
+	HallucinationNoSpeech = "no_speech"
+
+	// HallucinationBoilerplate is a stock phrase Whisper learned from subtitled videos, such as
+	// "Thanks for watching!" or a subtitler's credit
+	HallucinationBoilerplate = "boilerplate"
+
+	// HallucinationRepetition is a segment stuck repeating a phrase, or repeating the segments
+	// before it
+	HallucinationRepetition = "repetition"
+)
+
+// A segment repeats itself when a phrase of up to maxRepeatedPhrase words comes minPhraseRepeats
+// times or more in a row, running to at least minRepeatedWords words and half the segment. The same
+// text in minSegmentRepeats segments in a row repeats too
+const (
+	maxRepeatedPhrase = 4
+	minPhraseRepeats  = 4
+	minRepeatedWords  = 8
+	minSegmentRepeats = 3
+)
+
+// boilerplatePhrases are whole segments Whisper makes up over silence and noise, normalized as by
+// normalizedWords. Short phrases like "Thank you." are often really said, so they aren't here
+var boilerplatePhrases = toSet([]string{
+	"thanks for watching",
+	"thank you for watching",
+	"thanks for watching and see you next time",
+	"thank you so much for watching",
+	"please subscribe",
+	"please like and subscribe",
+	"like and subscribe",
+	"dont forget to like and subscribe",
+	"subscribe to my channel",
+	"please subscribe to my channel",
+	"see you in the next video",
+	"see you next time",
+})
+
+// subtitleCredits mark a segment holding the credit of the subtitles Whisper learned from
+var subtitleCredits = []string{"amaraorg", "subtitles by", "subtitled by", "captions by", "transcribed by", "translated by"}
+
+// DetectHallucinations returns a copy of segments with Hallucination set on those that look made
+// up rather than heard, and how many that is: segments the model judged not to be speech, stock
+// phrases from subtitled videos, and segments stuck in a loop. It only looks at the text and the
+// scores the API returned, so it runs on any finished transcript
+func DetectHallucinations(segments []Segment) ([]Segment, int) {
+	flagged := slices.Clone(segments)
+	count := 0
+	for i := range flagged {
+		flagged[i].Hallucination = hallucinationReason(flagged, i)
+		if flagged[i].Hallucination != "" {
+			count++
+		}
+	}
+	return flagged, count
+}
+
+// hallucinationReason returns why segments[i] is suspect, or "" when it isn't
+func hallucinationReason(segments []Segment, i int) string {
+	segment := segments[i]
+	words := slices.DeleteFunc(normalizedWords(strings.Fields(segment.Text)), func(word string) bool { return word == "" })
+	text := strings.Join(words, " ")
+	switch {
+	case segment.NoSpeech:
+		return HallucinationNoSpeech
+	case boilerplatePhrases[text] || slices.ContainsFunc(subtitleCredits, func(credit string) bool { return strings.Contains(text, credit) }):
+		return HallucinationBoilerplate
+	case text != "" && repeatsSegments(segments, i):
+		return HallucinationRepetition
+	case repeatsPhrase(words):
+		return HallucinationRepetition
+	}
+	return ""
+}
+
+// repeatsSegments reports whether segments[i] is in a run of at least minSegmentRepeats segments
+// with the same words, other than the first of them
+func repeatsSegments(segments []Segment, i int) bool {
+	if i == 0 || !SameWords(segments[i-1].Text, segments[i].Text) {
+		return false
+	}
+	run := 1
+	for j := i - 1; j >= 0 && SameWords(segments[j].Text, segments[i].Text); j-- {
+		run++
+	}
+	for j := i + 1; j < len(segments) && SameWords(segments[j].Text, segments[i].Text); j++ {
+		run++
+	}
+	return run >= minSegmentRepeats
+}
+
+// repeatsPhrase reports whether a segment's normalized words are mostly one phrase said over and
+// over
+func repeatsPhrase(words []string) bool {
+	for n := 1; n <= maxRepeatedPhrase; n++ {
+		for start := 0; start+n <= len(words); start++ {
+			repeats := 1
+			for next := start + n; next+n <= len(words) && slices.Equal(words[start:start+n], words[next:next+n]); next += n {
+				repeats++
+			}
+			repeated := repeats * n
+			if repeats >= minPhraseRepeats && repeated >= minRepeatedWords && 2*repeated >= len(words) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RemoveHallucinations returns segments without those DetectHallucinations flagged, numbered
+// afresh, and words without those whose middle falls in a removed segment
+func RemoveHallucinations(segments []Segment, words []Word) ([]Segment, []Word) {
+	kept := make([]Segment, 0, len(segments))
+	var removed []Segment
+	for _, segment := range segments {
+		if segment.Hallucination != "" {
+			removed = append(removed, segment)
+			continue
+		}
+		segment.ID = len(kept)
+		kept = append(kept, segment)
+	}
+	if len(removed) == 0 || words == nil {
+		return kept, words
+	}
+	keptWords := make([]Word, 0, len(words))
+	for _, word := range words {
+		middle := (word.Start + word.End) / 2
+		if !slices.ContainsFunc(removed, func(segment Segment) bool { return middle >= segment.Start && middle < segment.End }) {
+			keptWords = append(keptWords, word)
+		}
+	}
+	return kept, keptWords
+}
@@ -0,0 +1,72 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// HWAccel is the ffmpeg hardware acceleration method video is decoded with, such as "vaapi",
+// "cuda", or "videotoolbox", as DetectHWAccel returns it. Empty decodes in software. Set it
+// before the first Transcriber is used
+var HWAccel string
+
+// hwAccelPreference is the order "auto" tries methods in, dedicated decoders before generic ones
+var hwAccelPreference = []string{"videotoolbox", "cuda", "qsv", "vaapi", "d3d11va", "dxva2"}
+
+// DetectHWAccel returns the hardware acceleration method to set HWAccel to for the one requested:
+// a method from ffmpeg -hwaccels, which it checks is compiled in and has a device that opens, or
+// "auto" for the first of those that works, which is "" when none does. An empty request returns ""
+func DetectHWAccel(ctx context.Context, requested string) (string, error) {
+	if requested == "" {
+		return "", nil
+	}
+	output, err := toolOutput(ctx, FFmpegPath, "-hide_banner", "-hwaccels")
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg (%s) can't list its hardware acceleration methods: %w", FFmpegPath, err)
+	}
+	available := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		// The methods follow a "Hardware acceleration methods:" heading, one per line
+		if name := strings.TrimSpace(line); name != "" && !strings.HasSuffix(name, ":") {
+			available[name] = true
+		}
+	}
+
+	if requested != "auto" {
+		if !available[requested] {
+			return "", fmt.Errorf("ffmpeg (%s) wasn't built with hardware acceleration method %q", FFmpegPath, requested)
+		}
+		if err := openHWDevice(ctx, requested); err != nil {
+			return "", fmt.Errorf("%s device can't be opened: %w", requested, err)
+		}
+		return requested, nil
+	}
+	for _, method := range hwAccelPreference {
+		if available[method] && openHWDevice(ctx, method) == nil {
+			return method, nil
+		}
+	}
+	return "", nil
+}
+
+// openHWDevice checks that ffmpeg can open a device of a hardware acceleration method, which
+// fails when the driver or the hardware is missing even though the method is compiled in
+func openHWDevice(ctx context.Context, method string) error {
+	_, err := toolOutput(ctx, FFmpegPath,
+		"-hide_banner", "-v", "error",
+		"-init_hw_device", method,
+		"-f", "lavfi", "-i", "nullsrc=s=16x16:d=0.04",
+		"-f", "null", "-",
+	)
+	return err
+}
+
+// hwAccelArgs returns the input options that have ffmpeg decode the next input's video with
+// HWAccel. Frames are copied back to memory, so software filters and encoders still work on them
+func hwAccelArgs() []string {
+	if HWAccel == "" {
+		return nil
+	}
+	return []string{"-hwaccel", HWAccel}
+}
@@ -0,0 +1,48 @@
+package transcriber
+
+import "sync"
+
+// inflightKey identifies a transcription that a file with identical audio can share
+type inflightKey struct {
+	audioHash string
+	model     string
+}
+
+// inflightCall is a transcription in progress that files with identical audio wait for
+type inflightCall struct {
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+// inflightCalls tracks the transcriptions a Transcriber has in progress, so identical audio that
+// arrives meanwhile waits for the result instead of being sent to the API again
+type inflightCalls struct {
+	mu    sync.Mutex
+	calls map[inflightKey]*inflightCall
+}
+
+// join returns the call already transcribing key, or starts one that the caller leads and must
+// finish
+func (f *inflightCalls) join(key inflightKey) (call *inflightCall, leader bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if call, ok := f.calls[key]; ok {
+		return call, false
+	}
+	if f.calls == nil {
+		f.calls = map[inflightKey]*inflightCall{}
+	}
+	call = &inflightCall{done: make(chan struct{})}
+	f.calls[key] = call
+	return call, true
+}
+
+// finish hands the leader's outcome to every file waiting on call and stops tracking it
+func (f *inflightCalls) finish(key inflightKey, call *inflightCall, result *Result, err error) {
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+	call.result, call.err = result, err
+	close(call.done)
+}
@@ -0,0 +1,151 @@
+package transcriber
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Keyword is a key phrase of the transcript along with where it is mentioned
+type Keyword struct {
+	Phrase string  `json:"phrase"`
+	Score  float64 `json:"score"`
+	Count  int     `json:"count"`
+
+	// Timestamps are the start times, in seconds, of the first few segments mentioning the phrase
+	Timestamps []float64 `json:"timestamps"`
+}
+
+const (
+	// maxKeywordWords caps phrase length; RAKE otherwise favors long run-on phrases
+	maxKeywordWords = 3
+
+	// maxKeywordTimestamps caps how many mentions are reported for each keyword
+	maxKeywordTimestamps = 5
+)
+
+// keywordStopwords split the text into candidate phrases. Spoken filler words are included
+// because transcripts are full of them
+var keywordStopwords = toSet(strings.Fields(`
+	a about above after again against all also am an and any are aren't as at be because been
+	before being below between both but by can can't cannot could couldn't did didn't do does
+	doesn't doing don't down during each few for from further get got gonna had hadn't has hasn't
+	have haven't having he he'd he'll he's her here here's hers herself him himself his how how's
+	i i'd i'll i'm i've if in into is isn't it it's its itself just know let's like me more most
+	mustn't my myself no nor not now of off oh ok okay on once one only or other ought our ours
+	ourselves out over own really right said same say says shan't she she'd she'll she's should
+	shouldn't so some such than that that's the their theirs them themselves then there there's
+	these they they'd they'll they're they've thing things think this those through to too um uh
+	under until up us very want was wasn't we we'd we'll we're we've well were weren't what what's
+	when when's where where's which while who who's whom why why's will with won't would wouldn't
+	yeah yes you you'd you'll you're you've your yours yourself yourselves
+`))
+
+func toSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// ExtractKeywords finds up to limit key phrases in the segments with RAKE (Rapid Automatic
+// Keyword Extraction): phrases are the runs of words between stopwords and punctuation, each
+// word is scored by how many other words it appears alongside relative to how often it
+// appears, and each phrase by the sum of its word scores times how often it is mentioned
+func ExtractKeywords(segments []Segment, limit int) []Keyword {
+	type candidate struct {
+		words      []string
+		count      int
+		timestamps []float64
+	}
+	candidates := map[string]*candidate{}
+	var order []string
+	frequency := map[string]int{}
+	degree := map[string]int{}
+
+	for _, segment := range segments {
+		for _, words := range candidatePhrases(segment.Text) {
+			for _, word := range words {
+				frequency[word]++
+				degree[word] += len(words)
+			}
+
+			phrase := strings.Join(words, " ")
+			c, ok := candidates[phrase]
+			if !ok {
+				c = &candidate{words: words}
+				candidates[phrase] = c
+				order = append(order, phrase)
+			}
+			c.count++
+			if len(c.timestamps) < maxKeywordTimestamps && (len(c.timestamps) == 0 || c.timestamps[len(c.timestamps)-1] != segment.Start) {
+				c.timestamps = append(c.timestamps, segment.Start)
+			}
+		}
+	}
+
+	keywords := make([]Keyword, 0, len(candidates))
+	for _, phrase := range order {
+		c := candidates[phrase]
+		var score float64
+		for _, word := range c.words {
+			score += float64(degree[word]) / float64(frequency[word])
+		}
+		keywords = append(keywords, Keyword{
+			Phrase:     phrase,
+			Score:      math.Round(score*float64(c.count)*100) / 100,
+			Count:      c.count,
+			Timestamps: c.timestamps,
+		})
+	}
+
+	// Ties go to the phrase mentioned first
+	sort.SliceStable(keywords, func(i, j int) bool {
+		return keywords[i].Score > keywords[j].Score
+	})
+	if len(keywords) > limit {
+		keywords = keywords[:limit]
+	}
+	return keywords
+}
+
+// candidatePhrases splits text into lowercase runs of non-stopwords, breaking at punctuation and
+// cutting runs longer than maxKeywordWords. Words without letters, like numbers, also break runs
+func candidatePhrases(text string) [][]string {
+	var phrases [][]string
+	var current []string
+	flush := func() {
+		for len(current) > 0 {
+			n := min(len(current), maxKeywordWords)
+			phrases = append(phrases, current[:n])
+			current = current[n:]
+		}
+		current = nil
+	}
+
+	for _, token := range strings.Fields(strings.ToLower(text)) {
+		word := strings.TrimFunc(token, isWordBreak)
+		// Curly apostrophes are common in transcripts and should match the stopword list
+		word = strings.ReplaceAll(word, "’", "'")
+
+		if word == "" || keywordStopwords[word] || len([]rune(word)) < 2 || !strings.ContainsFunc(word, unicode.IsLetter) {
+			flush()
+			continue
+		}
+		current = append(current, word)
+
+		// Punctuation after the word ends the phrase
+		if strings.TrimRightFunc(token, isWordBreak) != token {
+			flush()
+		}
+	}
+	flush()
+	return phrases
+}
+
+// isWordBreak reports whether r is punctuation or a symbol rather than part of a word
+func isWordBreak(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
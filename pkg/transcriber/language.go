@@ -0,0 +1,169 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultLanguageSampleSeconds is how much audio DetectLanguage listens to unless told otherwise
+const DefaultLanguageSampleSeconds = 30
+
+// DetectLanguageOptions holds the per-file settings for DetectLanguage
+type DetectLanguageOptions struct {
+	// AudioTrack and AudioLanguage pick the audio stream, as for Transcribe
+	AudioTrack    string
+	AudioLanguage string
+
+	// Model overrides Options.Model; it must be a multilingual model
+	Model string
+
+	// SampleSeconds is how much audio, from the start, is sent to the API; defaults to
+	// DefaultLanguageSampleSeconds
+	SampleSeconds float64
+
+	// Logger receives stage timings; defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+// DetectedLanguage is the language the model heard in a sample of the audio
+type DetectedLanguage struct {
+	// Language is the ISO-639-1 code of the language, or the API's name for it when that isn't a
+	// language Whisper knows
+	Language string
+
+	// Name is the language's English name, such as "english"
+	Name string
+
+	// Confidence is how sure the model was of the sample's transcript, from 0 to 1, averaged over its
+	// segments by length, or nil when the API didn't score them. The API doesn't score the language
+	// itself, but a sample transcribed in the wrong language scores poorly
+	Confidence *float64
+
+	// SampleSeconds is how much audio was sent to the API
+	SampleSeconds float64
+
+	// Transcription is what the model heard in the sample
+	Transcription string
+}
+
+// DetectLanguage identifies the spoken language of the media file at inputPath from a short sample,
+// without transcribing the rest. The sample is transcribed once with no language hint, so it costs
+// as much as SampleSeconds of transcription. Intermediate files are written to workDir
+func (t *Transcriber) DetectLanguage(ctx context.Context, inputPath, workDir string, opts DetectLanguageOptions) (*DetectedLanguage, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.SampleSeconds <= 0 {
+		opts.SampleSeconds = DefaultLanguageSampleSeconds
+	}
+
+	start := time.Now()
+	mediaInfo, err := ValidateMedia(ctx, inputPath)
+	t.observeStage(ctx, logger, StageValidate, start)
+	if err != nil {
+		return nil, stageError(ctx, StageValidate, err)
+	}
+	audioStream, err := SelectAudioStream(mediaInfo, opts.AudioTrack, opts.AudioLanguage)
+	if err != nil {
+		return nil, &StageError{Stage: StageSelectStream, Err: err}
+	}
+
+	samplePath := filepath.Join(workDir, "sample.flac")
+	start = time.Now()
+	err = timedStage(ctx, StagePreprocess, t.opts.PreprocessTimeout, func(ctx context.Context) error {
+		return preprocessAudioSample(ctx, inputPath, samplePath, audioStream.Index, audioFilters{}, opts.SampleSeconds)
+	})
+	t.observeStage(ctx, logger, StagePreprocess, start)
+	if err != nil {
+		return nil, err
+	}
+
+	model := opts.Model
+	if model == "" {
+		model = t.opts.Model
+	}
+	start = time.Now()
+	sample, err := t.transcribeChunk(ctx, samplePath, chunkRequest{Model: model, DetectLanguage: true})
+	t.observeStage(ctx, logger, StageTranscribe, start)
+	if err != nil {
+		return nil, stageError(ctx, StageTranscribe, err)
+	}
+	if sample.Language == "" {
+		return nil, &StageError{Stage: StageTranscribe, Err: fmt.Errorf("the API didn't report a language; model %s may not support language detection", model)}
+	}
+
+	detected := &DetectedLanguage{
+		SampleSeconds: opts.SampleSeconds,
+		Transcription: strings.TrimSpace(sample.Text),
+		Confidence:    sampleConfidence(sample.Segments),
+	}
+	detected.Language, detected.Name = whisperLanguage(sample.Language)
+	if sample.Duration > 0 {
+		detected.SampleSeconds = min(detected.SampleSeconds, sample.Duration)
+	}
+	return detected, nil
+}
+
+// sampleConfidence averages the confidence of the scored segments, weighted by their length
+func sampleConfidence(segments []chunkSegment) *float64 {
+	var total, seconds float64
+	for _, segment := range segments {
+		confidence := segment.confidence()
+		if confidence == nil {
+			continue
+		}
+		length := max(segment.End-segment.Start, 0.1)
+		total += *confidence * length
+		seconds += length
+	}
+	if seconds == 0 {
+		return nil
+	}
+	average := math.Round(total/seconds*1000) / 1000
+	return &average
+}
+
+// whisperLanguage returns the ISO-639-1 code and English name of a language the API reported,
+// which may be either. Languages Whisper doesn't know are returned as reported
+func whisperLanguage(reported string) (code, name string) {
+	reported = strings.ToLower(strings.TrimSpace(reported))
+	if name, ok := whisperLanguageNames[reported]; ok {
+		return reported, name
+	}
+	for code, name := range whisperLanguageNames {
+		if name == reported {
+			return code, name
+		}
+	}
+	return reported, reported
+}
+
+// whisperLanguageNames maps the ISO-639-1 codes of the languages Whisper knows to the English names
+// the API reports them by
+var whisperLanguageNames = map[string]string{
+	"af": "afrikaans", "am": "amharic", "ar": "arabic", "as": "assamese", "az": "azerbaijani",
+	"ba": "bashkir", "be": "belarusian", "bg": "bulgarian", "bn": "bengali", "bo": "tibetan",
+	"br": "breton", "bs": "bosnian", "ca": "catalan", "cs": "czech", "cy": "welsh", "da": "danish",
+	"de": "german", "el": "greek", "en": "english", "es": "spanish", "et": "estonian", "eu": "basque",
+	"fa": "persian", "fi": "finnish", "fo": "faroese", "fr": "french", "gl": "galician",
+	"gu": "gujarati", "ha": "hausa", "haw": "hawaiian", "he": "hebrew", "hi": "hindi",
+	"hr": "croatian", "ht": "haitian creole", "hu": "hungarian", "hy": "armenian", "id": "indonesian",
+	"is": "icelandic", "it": "italian", "ja": "japanese", "jw": "javanese", "ka": "georgian",
+	"kk": "kazakh", "km": "khmer", "kn": "kannada", "ko": "korean", "la": "latin",
+	"lb": "luxembourgish", "ln": "lingala", "lo": "lao", "lt": "lithuanian", "lv": "latvian",
+	"mg": "malagasy", "mi": "maori", "mk": "macedonian", "ml": "malayalam", "mn": "mongolian",
+	"mr": "marathi", "ms": "malay", "mt": "maltese", "my": "myanmar", "ne": "nepali", "nl": "dutch",
+	"nn": "nynorsk", "no": "norwegian", "oc": "occitan", "pa": "punjabi", "pl": "polish",
+	"ps": "pashto", "pt": "portuguese", "ro": "romanian", "ru": "russian", "sa": "sanskrit",
+	"sd": "sindhi", "si": "sinhala", "sk": "slovak", "sl": "slovenian", "sn": "shona", "so": "somali",
+	"sq": "albanian", "sr": "serbian", "su": "sundanese", "sv": "swedish", "sw": "swahili",
+	"ta": "tamil", "te": "telugu", "tg": "tajik", "th": "thai", "tk": "turkmen", "tl": "tagalog",
+	"tr": "turkish", "tt": "tatar", "uk": "ukrainian", "ur": "urdu", "uz": "uzbek", "vi": "vietnamese",
+	"yi": "yiddish", "yo": "yoruba", "yue": "cantonese", "zh": "chinese",
+}
@@ -0,0 +1,119 @@
+package transcriber
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RequestLimiter caps how many API requests are in flight at once and how fast they start, across
+// every file and every Transcriber it is shared by, so concurrent jobs can't multiply the load on
+// the provider. MaxConcurrentChunks still bounds each file on its own. The zero of either limit
+// means no limit, and a nil *RequestLimiter limits nothing. It is safe for concurrent use
+type RequestLimiter struct {
+	mu sync.Mutex
+
+	// slots holds a token for every request in flight; nil without a concurrency limit
+	slots chan struct{}
+
+	// A token bucket of one second's worth of requests paces how fast they start
+	rate     float64
+	burst    float64
+	tokens   float64
+	refilled time.Time
+}
+
+// NewRequestLimiter returns a limiter allowing at most maxConcurrent requests in flight and
+// starting at most perSecond of them each second on average, in bursts of up to a second's worth
+func NewRequestLimiter(maxConcurrent int, perSecond float64) *RequestLimiter {
+	l := &RequestLimiter{}
+	l.SetLimits(maxConcurrent, perSecond)
+	return l
+}
+
+// SetLimits changes the limits for requests that start from now on. Requests already in flight
+// finish under the concurrency limit they started with and don't count against a new one
+func (l *RequestLimiter) SetLimits(maxConcurrent int, perSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if maxConcurrent != cap(l.slots) {
+		l.slots = nil
+		if maxConcurrent > 0 {
+			l.slots = make(chan struct{}, maxConcurrent)
+		}
+	}
+	if perSecond != l.rate {
+		l.rate = perSecond
+		l.burst = math.Max(1, perSecond)
+		l.tokens = l.burst
+		l.refilled = time.Now()
+	}
+}
+
+// Acquire blocks until a request may start, or ctx is done, and returns the function that must
+// be called once the request has finished
+func (l *RequestLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	slots := l.slots
+	l.mu.Unlock()
+
+	release = func() {}
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+			release = func() { <-slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := l.take(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// InFlight returns how many requests currently hold a slot
+func (l *RequestLimiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.slots)
+}
+
+// take waits for a token from the bucket. Tokens are reserved ahead of time, so waiting callers
+// are served in the order they arrived; one that gives up returns its token
+func (l *RequestLimiter) take(ctx context.Context) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.refilled).Seconds()*l.rate)
+	l.refilled = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
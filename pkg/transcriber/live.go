@@ -0,0 +1,208 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// livePollInterval is how often the segments directory is checked for a newly completed segment
+const livePollInterval = 250 * time.Millisecond
+
+// TranscribeLive pulls a live RTSP, RTMP, or HLS stream with ffmpeg and cuts it into segments of
+// Options.LiveSegmentSeconds, written to workDir. Each segment is transcribed as soon as ffmpeg
+// moves on to the next one, and its segments are passed to opts.OnSegments. Closing stop ends
+// the stream: ffmpeg finishes the segment it is writing, that last segment is transcribed, and
+// the transcript of the whole stream is returned. The same happens when the stream ends by itself.
+//
+// Only the first audio stream is used, and the stream is never cached, so AudioTrack,
+// AudioLanguage, SplitChannels, and Cache are ignored. If ctx is canceled, ffmpeg and API requests
+// are stopped and ctx.Err() is returned
+func (t *Transcriber) TranscribeLive(ctx context.Context, streamURL, workDir string, stop <-chan struct{}, opts TranscribeOptions) (*Result, error) {
+	ctx, span := tracer.Start(ctx, "TranscribeLive", trace.WithAttributes(
+		attribute.String("transcriber.model", t.model(opts)),
+	))
+	result, err := t.transcribeLive(ctx, streamURL, workDir, stop, opts)
+	if result != nil {
+		span.SetAttributes(attribute.Float64("transcriber.audio_duration_seconds", result.DurationSeconds))
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Transcriber) transcribeLive(ctx context.Context, streamURL, workDir string, stop <-chan struct{}, opts TranscribeOptions) (*Result, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if err := validateOptions(opts); err != nil {
+		return nil, err
+	}
+
+	filters := audioFilters{
+		Denoise:      opts.Denoise,
+		Normalize:    opts.Normalize,
+		Custom:       opts.AudioFilters,
+		RNNoiseModel: t.opts.RNNoiseModel,
+	}
+	cmd := exec.CommandContext(ctx, FFmpegPath, liveSegmentArgs(streamURL, workDir, t.opts.LiveSegmentSeconds, filters)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, &StageError{Stage: StageIngest, Err: err}
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, &StageError{Stage: StageIngest, Err: err}
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	// ffmpeg finishes the segment it is writing and exits when it reads q
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			io.WriteString(stdin, "q")
+			stdin.Close()
+		case <-done:
+		}
+	}()
+
+	request := t.chunkRequest(opts)
+	stitcher := &segmentStitcher{}
+	var texts []string
+	var offset float64
+	var ingestErr error
+	running := true
+	ticker := time.NewTicker(livePollInterval)
+	defer ticker.Stop()
+	for next := 0; ; {
+		// A segment is complete once ffmpeg has started the one after it, or has exited
+		path := liveSegmentPath(workDir, next)
+		if fileExists(liveSegmentPath(workDir, next+1)) || (!running && fileExists(path)) {
+			chunk := audioChunk{Path: path, StartSec: offset}
+			chunkStart := time.Now()
+			transcription, err := t.transcribeChunk(ctx, path, request)
+			if ctx.Err() != nil {
+				if running {
+					<-exited
+				}
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				logger.Error("Live segment transcription failed", "segment", next, "duration", time.Since(chunkStart), "error", err)
+			} else {
+				logger.Info("Live segment transcribed", "segment", next, "duration", time.Since(chunkStart))
+				texts = append(texts, transcription.Text)
+			}
+			if added := stitcher.add(chunk, transcription); opts.OnSegments != nil && len(added) > 0 {
+				opts.OnSegments(added)
+			}
+
+			offset += t.segmentDuration(ctx, path)
+			os.Remove(path)
+			next++
+			continue
+		}
+		if !running {
+			break
+		}
+
+		select {
+		case err := <-exited:
+			running = false
+			if err != nil && ctx.Err() == nil {
+				ingestErr = err
+				if msg := strings.TrimSpace(stderr.String()); msg != "" {
+					ingestErr = errors.New(msg)
+				}
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			<-exited
+			return nil, ctx.Err()
+		}
+	}
+	t.observeStage(ctx, logger, StageIngest, start)
+
+	// A stream that drops after some audio came through still has a transcript worth keeping
+	if ingestErr != nil {
+		if offset == 0 {
+			return nil, &StageError{Stage: StageIngest, Err: ingestErr}
+		}
+		logger.Warn("Live stream ended with an error", "error", ingestErr)
+	}
+
+	return &Result{
+		Transcription:   strings.Join(texts, ""),
+		Segments:        stitcher.segments,
+		DurationSeconds: offset,
+		Chunks:          len(texts),
+		AudioSeconds:    offset,
+		Model:           request.Model,
+	}, nil
+}
+
+// liveSegmentArgs has ffmpeg pull the first audio stream of streamURL and write it to workDir as
+// numbered mono 16kHz FLAC segments
+func liveSegmentArgs(streamURL, workDir string, segmentSeconds float64, filters audioFilters) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+
+	// RTSP over UDP drops packets on lossy networks, and gaps turn into garbled words
+	if strings.HasPrefix(streamURL, "rtsp") {
+		args = append(args, "-rtsp_transport", "tcp")
+	}
+	args = append(args, "-i", streamURL, "-vn", "-map", "0:a:0")
+	if chain := filters.chain(); chain != "" {
+		args = append(args, "-af", chain)
+	}
+	return append(args,
+		"-ar", "16000",
+		"-ac", "1",
+		"-c:a", "flac",
+		"-f", "segment",
+		"-segment_time", strconv.FormatFloat(segmentSeconds, 'f', -1, 64),
+		"-reset_timestamps", "1",
+		filepath.Join(workDir, "live-%06d.flac"),
+	)
+}
+
+// liveSegmentPath is where ffmpeg writes the nth segment of a live stream
+func liveSegmentPath(workDir string, n int) string {
+	return filepath.Join(workDir, fmt.Sprintf("live-%06d.flac", n))
+}
+
+// segmentDuration probes how long a finished segment is, since segments are only cut on packet
+// boundaries, falling back to the requested length when ffprobe can't tell
+func (t *Transcriber) segmentDuration(ctx context.Context, path string) float64 {
+	info, err := ProbeMedia(ctx, path)
+	if err == nil {
+		if seconds, err := strconv.ParseFloat(info.Duration, 64); err == nil {
+			return seconds
+		}
+	}
+	return t.opts.LiveSegmentSeconds
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,206 @@
+package transcriber
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"os/exec"
+)
+
+// NonSpeech is a long stretch of a recording without speech, such as a podcast's music intro,
+// that was left out of the chunks sent to the API
+type NonSpeech struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+
+	// Kind is "silence" when the stretch is quiet throughout, and "music" for any other sound
+	// that doesn't come and go the way speech does
+	Kind string `json:"kind"`
+}
+
+// Non-speech detection measures the level of every nonSpeechFrameSeconds of audio and judges
+// each nonSpeechWindowFrames frames together. Speech stops between syllables and words, so many of
+// a window's frames are well below its average level; music and noise hold steadier. A window
+// quieter throughout than silenceLevel is silence, and one with under lowEnergyShare of its frames
+// below half its average level isn't speech
+const (
+	nonSpeechFrameSeconds = 0.02
+	nonSpeechWindowFrames = 50
+	silenceLevel          = 0.003
+	lowEnergyShare        = 0.1
+)
+
+// nonSpeechMargin is how much of a stretch without speech is still sent on either side of it,
+// so the words around it aren't cut off
+const nonSpeechMargin = 1.0
+
+// DetectNonSpeech finds the stretches of a preprocessed 16 kHz mono file, of at least minSeconds
+// apart from a margin either side, that hold music, noise, or silence rather than speech. It's a
+// heuristic on how the level of the audio varies, so it is meant for long intros, outros, and
+// breaks rather than the pauses within speech
+func DetectNonSpeech(ctx context.Context, filePath string, minSeconds float64) ([]NonSpeech, error) {
+	meter := &levelMeter{frameSamples: int(nonSpeechFrameSeconds * nativeSampleRate)}
+	if err := measureLevels(ctx, filePath, meter); err != nil {
+		return nil, err
+	}
+	duration := float64(meter.samples) / nativeSampleRate
+	windowSeconds := nonSpeechWindowFrames * nonSpeechFrameSeconds
+
+	var stretches []NonSpeech
+	var current *NonSpeech
+	for start := 0; start < len(meter.levels); start += nonSpeechWindowFrames {
+		kind := windowKind(meter.levels[start:min(start+nonSpeechWindowFrames, len(meter.levels))])
+		windowStart := float64(start) * nonSpeechFrameSeconds
+		switch {
+		case kind == "":
+			current = nil
+		case current == nil:
+			stretches = append(stretches, NonSpeech{Start: windowStart, End: windowStart + windowSeconds, Kind: kind})
+			current = &stretches[len(stretches)-1]
+		default:
+			current.End = windowStart + windowSeconds
+			if kind == "music" {
+				current.Kind = "music"
+			}
+		}
+	}
+
+	// A stretch at either end of the recording has nothing to cut off there
+	var skipped []NonSpeech
+	for _, stretch := range stretches {
+		stretch.End = min(stretch.End, duration)
+		if stretch.Start > 0 {
+			stretch.Start += nonSpeechMargin
+		}
+		if stretch.End < duration {
+			stretch.End -= nonSpeechMargin
+		}
+		if stretch.End-stretch.Start >= minSeconds {
+			skipped = append(skipped, stretch)
+		}
+	}
+	return skipped, nil
+}
+
+// windowKind judges a window of frame levels: "silence", "music", or "" when it may hold speech
+func windowKind(levels []float64) string {
+	var sum, loudest float64
+	for _, level := range levels {
+		sum += level
+		loudest = max(loudest, level)
+	}
+	if loudest < silenceLevel {
+		return "silence"
+	}
+	mean := sum / float64(len(levels))
+	low := 0
+	for _, level := range levels {
+		if level < mean/2 {
+			low++
+		}
+	}
+	if float64(low) < lowEnergyShare*float64(len(levels)) {
+		return "music"
+	}
+	return ""
+}
+
+// speechSpans returns the stretches of a recording of the given duration that aren't skipped
+func speechSpans(skipped []NonSpeech, duration float64) []ClipSpan {
+	var spans []ClipSpan
+	start := 0.0
+	for _, stretch := range skipped {
+		if stretch.Start > start {
+			spans = append(spans, ClipSpan{Start: start, End: stretch.Start})
+		}
+		start = stretch.End
+	}
+	if duration > start {
+		spans = append(spans, ClipSpan{Start: start, End: duration})
+	}
+	return spans
+}
+
+// measureLevels feeds the samples of a preprocessed file to meter, read in-process from WAV files
+// (as encrypted ones always are) and decoded with ffmpeg otherwise
+func measureLevels(ctx context.Context, filePath string, meter *levelMeter) error {
+	if fileCipherFrom(ctx) == nil && !isWAVFile(ctx, filePath) {
+		cmd := exec.CommandContext(ctx, FFmpegPath, "-i", filePath, "-ac", "1", "-ar", "16000", "-f", "s16le", "pipe:1")
+		cmd.Stdout = meter
+		if err := runCommand(ctx, "ffmpeg levels", cmd); err != nil {
+			return err
+		}
+		meter.flush()
+		return nil
+	}
+
+	file, format, err := openWAV(ctx, filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(format.DataOffset, io.SeekStart); err != nil {
+		return err
+	}
+	input := bufio.NewReaderSize(io.LimitReader(file, format.DataSize), 1<<16)
+	frame := make([]byte, format.BlockAlign)
+	for n := 0; ; n++ {
+		if n%nativeSampleRate == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := io.ReadFull(input, frame); err != nil {
+			break
+		}
+		meter.add(format.sample(frame, 0))
+	}
+	meter.flush()
+	return nil
+}
+
+// levelMeter takes the RMS level of each frameSamples samples it is given, either one at a time
+// with add or as 16-bit little-endian PCM written to it
+type levelMeter struct {
+	frameSamples int
+	levels       []float64
+	samples      int
+
+	sum     float64
+	count   int
+	partial []byte
+}
+
+// add measures one sample, scaled to -1..1
+func (m *levelMeter) add(sample float64) {
+	m.sum += sample * sample
+	m.count++
+	m.samples++
+	if m.count == m.frameSamples {
+		m.flush()
+	}
+}
+
+// flush ends the frame being measured
+func (m *levelMeter) flush() {
+	if m.count > 0 {
+		m.levels = append(m.levels, math.Sqrt(m.sum/float64(m.count)))
+	}
+	m.sum, m.count = 0, 0
+}
+
+// Write measures 16-bit little-endian PCM, keeping a sample split across writes for the next
+func (m *levelMeter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(m.partial) > 0 {
+		p = append(m.partial, p...)
+		m.partial = nil
+	}
+	for ; len(p) >= 2; p = p[2:] {
+		m.add(float64(int16(binary.LittleEndian.Uint16(p))) / (1 << 15))
+	}
+	if len(p) > 0 {
+		m.partial = append(m.partial, p...)
+	}
+	return n, nil
+}
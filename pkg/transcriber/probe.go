@@ -0,0 +1,213 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MediaInfo describes the container and streams ffprobe found in a file
+type MediaInfo struct {
+	FormatName string
+	Duration   string
+	Streams    []StreamInfo
+
+	// BitRate is the container's overall bitrate in bits per second, or zero when unknown
+	BitRate int
+}
+
+// StreamInfo describes a single stream inside a media file
+type StreamInfo struct {
+	Index     int
+	CodecType string
+	CodecName string
+	Language  string
+
+	// Channels is the number of audio channels; zero for other stream types
+	Channels int
+
+	// SampleRate (Hz) and BitRate (bits per second) are zero for other stream types, or when
+	// the container doesn't record them
+	SampleRate int
+	BitRate    int
+
+	// AttachedPicture marks a video stream that is only cover art, such as an MP3's album art
+	AttachedPicture bool
+}
+
+// AudioStreams returns only the audio streams in the file
+func (m MediaInfo) AudioStreams() []StreamInfo {
+	var audio []StreamInfo
+	for _, stream := range m.Streams {
+		if stream.CodecType == "audio" {
+			audio = append(audio, stream)
+		}
+	}
+	return audio
+}
+
+// VideoStreams returns the video streams in the file, leaving out cover art
+func (m MediaInfo) VideoStreams() []StreamInfo {
+	var video []StreamInfo
+	for _, stream := range m.Streams {
+		if stream.CodecType == "video" && !stream.AttachedPicture {
+			video = append(video, stream)
+		}
+	}
+	return video
+}
+
+// Codecs returns the codec names of every stream, for use in error messages
+func (m MediaInfo) Codecs() string {
+	var codecs []string
+	for _, stream := range m.Streams {
+		codecs = append(codecs, fmt.Sprintf("%s:%s", stream.CodecType, stream.CodecName))
+	}
+	if len(codecs) == 0 {
+		return "none"
+	}
+	return strings.Join(codecs, ", ")
+}
+
+// ProbeMedia runs ffprobe against a file and returns its container and stream details. Without
+// ffprobe, WAV files are read in-process
+func ProbeMedia(ctx context.Context, filePath string) (MediaInfo, error) {
+	if !haveExecutable(FFprobePath) {
+		if isWAVFile(ctx, filePath) {
+			return probeWAV(ctx, filePath)
+		}
+		return MediaInfo{}, fmt.Errorf("ffprobe (%s) isn't installed, and without it only WAV files can be read", FFprobePath)
+	}
+	input, stop, err := mediaInput(ctx, filePath)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	defer stop()
+	cmd := exec.CommandContext(
+		ctx,
+		FFprobePath,
+		"-v", "error",
+		"-show_entries", "format=format_name,duration,bit_rate:stream=index,codec_type,codec_name,channels,sample_rate,bit_rate:stream_tags=language:stream_disposition=attached_pic",
+		"-of", "json",
+		input,
+	)
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := runCommand(ctx, "ffprobe media", cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return MediaInfo{}, fmt.Errorf("%s", msg)
+		}
+		return MediaInfo{}, err
+	}
+
+	var result struct {
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+			BitRate    string `json:"bit_rate"`
+		} `json:"format"`
+		Streams []struct {
+			Index      int    `json:"index"`
+			CodecType  string `json:"codec_type"`
+			CodecName  string `json:"codec_name"`
+			Channels   int    `json:"channels"`
+			SampleRate string `json:"sample_rate"`
+			BitRate    string `json:"bit_rate"`
+			Tags       struct {
+				Language string `json:"language"`
+			} `json:"tags"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		return MediaInfo{}, err
+	}
+
+	info := MediaInfo{
+		FormatName: result.Format.FormatName,
+		Duration:   result.Format.Duration,
+	}
+	// ffprobe reports rates as strings, and leaves them out when unknown
+	info.BitRate, _ = strconv.Atoi(result.Format.BitRate)
+	for _, stream := range result.Streams {
+		sampleRate, _ := strconv.Atoi(stream.SampleRate)
+		bitRate, _ := strconv.Atoi(stream.BitRate)
+		info.Streams = append(info.Streams, StreamInfo{
+			Index:      stream.Index,
+			CodecType:  stream.CodecType,
+			CodecName:  stream.CodecName,
+			Language:   stream.Tags.Language,
+			Channels:   stream.Channels,
+			SampleRate: sampleRate,
+			BitRate:    bitRate,
+
+			AttachedPicture: stream.Disposition.AttachedPic == 1,
+		})
+	}
+
+	return info, nil
+}
+
+// ValidateMedia rejects files ffprobe can't read or that contain no audio
+func ValidateMedia(ctx context.Context, filePath string) (MediaInfo, error) {
+	info, err := ProbeMedia(ctx, filePath)
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("file could not be read as media: %v", err)
+	}
+
+	if len(info.AudioStreams()) == 0 {
+		return info, fmt.Errorf("no audio stream found (container: %s, codecs: %s)", info.FormatName, info.Codecs())
+	}
+
+	return info, nil
+}
+
+// SelectAudioStream picks the audio stream to transcribe, either by its position among
+// the audio streams (track) or by language tag, defaulting to the first audio stream
+func SelectAudioStream(info MediaInfo, track, language string) (StreamInfo, error) {
+	audio := info.AudioStreams()
+	if len(audio) == 0 {
+		return StreamInfo{}, fmt.Errorf("no audio stream found (container: %s, codecs: %s)", info.FormatName, info.Codecs())
+	}
+
+	if track != "" {
+		n, err := strconv.Atoi(track)
+		if err != nil || n < 0 || n >= len(audio) {
+			return StreamInfo{}, fmt.Errorf("audio_track %q is invalid: file has %d audio stream(s) [%s]", track, len(audio), describeStreams(audio))
+		}
+		return audio[n], nil
+	}
+
+	if language != "" {
+		for _, stream := range audio {
+			if strings.EqualFold(stream.Language, language) {
+				return stream, nil
+			}
+		}
+		return StreamInfo{}, fmt.Errorf("no audio stream with language %q: available [%s]", language, describeStreams(audio))
+	}
+
+	return audio[0], nil
+}
+
+// describeStreams renders audio streams as "track:codec(language)" for error messages
+func describeStreams(streams []StreamInfo) string {
+	var parts []string
+	for i, stream := range streams {
+		language := stream.Language
+		if language == "" {
+			language = "und"
+		}
+		parts = append(parts, fmt.Sprintf("%d:%s(%s)", i, stream.CodecName, language))
+	}
+	return strings.Join(parts, ", ")
+}
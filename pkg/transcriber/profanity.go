@@ -0,0 +1,122 @@
+package transcriber
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProfanityMode says what a ProfanityFilter does with the words it matches
+type ProfanityMode string
+
+const (
+	// ProfanityMask keeps a word's first letter and replaces the rest with asterisks
+	ProfanityMask ProfanityMode = "mask"
+
+	// ProfanityRemove drops the word entirely
+	ProfanityRemove ProfanityMode = "remove"
+)
+
+// DefaultProfanityWords is used when no wordlist is configured. A trailing * matches any ending
+var DefaultProfanityWords = []string{
+	"arse*", "ass", "asses", "asshole*", "bastard*", "bitch*", "bollock*", "bullshit*", "cock", "cocks",
+	"cocksucker*", "crap", "crappy", "cunt*", "damn", "damned", "dick", "dicks", "dickhead*", "fag", "fags",
+	"faggot*", "fuck*", "goddamn*", "motherfuck*", "nigga*", "nigger*", "piss", "pissed", "prick*", "pussy",
+	"shit*", "slut*", "twat*", "wank*", "whore*",
+}
+
+// ProfanityFilter masks or removes words from a wordlist, matching whole words and ignoring case
+type ProfanityFilter struct {
+	pattern *regexp.Regexp
+
+	// removal also matches the space before each word, so removing it leaves no gap
+	removal *regexp.Regexp
+}
+
+// NewProfanityFilter builds a filter for the given words. A word ending in * matches any word
+// starting with the rest of it, so "fuck*" also catches "fucking"
+func NewProfanityFilter(words []string) *ProfanityFilter {
+	var alternatives []string
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		stem, wildcard := strings.CutSuffix(word, "*")
+		if stem == "" {
+			continue
+		}
+		alternative := regexp.QuoteMeta(stem)
+		if wildcard {
+			alternative += `\w*`
+		}
+		alternatives = append(alternatives, alternative)
+	}
+	if len(alternatives) == 0 {
+		return &ProfanityFilter{}
+	}
+	alternation := `\b(?:` + strings.Join(alternatives, "|") + `)\b`
+	return &ProfanityFilter{
+		pattern: regexp.MustCompile(`(?i)` + alternation),
+		removal: regexp.MustCompile(`(?i)[ \t]*` + alternation),
+	}
+}
+
+// ReadProfanityWords reads a wordlist with one word per line, skipping blank lines and # comments
+func ReadProfanityWords(r io.Reader) ([]string, error) {
+	return readWordList(r)
+}
+
+// readWordList reads one entry per line, trimmed, skipping blank lines and # comments
+func readWordList(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
+
+// Apply filters text according to mode
+func (f *ProfanityFilter) Apply(text string, mode ProfanityMode) string {
+	if f.pattern == nil {
+		return text
+	}
+	switch mode {
+	case ProfanityMask:
+		return f.pattern.ReplaceAllStringFunc(text, func(word string) string {
+			first, size := utf8.DecodeRuneInString(word)
+			return string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+		})
+	case ProfanityRemove:
+		return f.removal.ReplaceAllString(text, "")
+	default:
+		return text
+	}
+}
+
+// ApplyWords returns a copy of words with each word filtered according to mode, leaving out the
+// words that mode removes
+func (f *ProfanityFilter) ApplyWords(words []Word, mode ProfanityMode) []Word {
+	filtered := make([]Word, 0, len(words))
+	for _, word := range words {
+		word.Word = strings.TrimSpace(f.Apply(word.Word, mode))
+		if word.Word != "" {
+			filtered = append(filtered, word)
+		}
+	}
+	return filtered
+}
+
+// ApplySegments returns a copy of segments with each segment's text filtered according to mode
+func (f *ProfanityFilter) ApplySegments(segments []Segment, mode ProfanityMode) []Segment {
+	filtered := make([]Segment, len(segments))
+	for i, segment := range segments {
+		segment.Text = f.Apply(segment.Text, mode)
+		filtered[i] = segment
+	}
+	return filtered
+}
@@ -0,0 +1,113 @@
+package transcriber
+
+import "time"
+
+// progressLatencyWindow is how many of the most recent chunk requests the ETA is estimated from
+const progressLatencyWindow = 5
+
+// Progress is how far the transcription of a file has got once its audio is chunked
+type Progress struct {
+	// ChunksDone of Chunks have been transcribed. With SplitChannels every channel's chunks count
+	ChunksDone int
+	Chunks     int
+
+	// Fraction is the share of the audio transcribed so far, from 0 to 1, each chunk weighted by
+	// how much of the timeline it covers
+	Fraction float64
+
+	// ETA is the estimated time left, from the latency of the most recent chunk requests and how
+	// many are sent at once. It is zero until a chunk has been answered by the API
+	ETA time.Duration
+}
+
+// progressReporter passes a file's Progress to onProgress as its chunks finish. A file
+// transcribed in parts of equal length, such as the channels of SplitChannels, reports as part
+// of parts
+type progressReporter struct {
+	onProgress  func(Progress)
+	part, parts int
+}
+
+// progressTracker works out a file's Progress as its chunks finish. It isn't safe for concurrent
+// use; transcribeAudio calls it under its lock
+type progressTracker struct {
+	progressReporter
+	concurrency int
+
+	// spans is how much of the timeline each chunk covers, up to the start of the next one
+	spans       []float64
+	duration    float64
+	done        int
+	doneSeconds float64
+
+	// latencies are those of the most recent chunk requests, oldest first
+	latencies []time.Duration
+}
+
+// track starts tracking the progress of chunks of audio lasting duration seconds, reporting that
+// none are done yet. It returns nil when there's no one to report to
+func (r progressReporter) track(chunks []audioChunk, duration float64, concurrency int) *progressTracker {
+	if r.onProgress == nil || len(chunks) == 0 {
+		return nil
+	}
+	if r.parts < 1 {
+		r.parts = 1
+	}
+	p := &progressTracker{progressReporter: r, concurrency: concurrency, spans: make([]float64, len(chunks)), duration: duration}
+	for i, chunk := range chunks {
+		end := duration
+		if i+1 < len(chunks) {
+			end = chunks[i+1].StartSec
+		}
+		p.spans[i] = max(end-chunk.StartSec, 0)
+	}
+	p.report()
+	return p
+}
+
+// chunkDone records that chunk i finished after a request taking latency, or with a zero latency
+// when it was restored from a checkpoint, and reports the progress
+func (p *progressTracker) chunkDone(i int, latency time.Duration) {
+	if p == nil {
+		return
+	}
+	p.done++
+	p.doneSeconds += p.spans[i]
+	if latency > 0 {
+		p.latencies = append(p.latencies, latency)
+		if len(p.latencies) > progressLatencyWindow {
+			p.latencies = p.latencies[1:]
+		}
+	}
+	p.report()
+}
+
+// report passes the progress so far to onProgress
+func (p *progressTracker) report() {
+	chunks := len(p.spans)
+	fraction := 1.0
+	if p.duration > 0 {
+		fraction = min(p.doneSeconds/p.duration, 1)
+	} else if chunks > 0 {
+		fraction = float64(p.done) / float64(chunks)
+	}
+	progress := Progress{
+		ChunksDone: p.part*chunks + p.done,
+		Chunks:     p.parts * chunks,
+		Fraction:   (float64(p.part) + fraction) / float64(p.parts),
+	}
+
+	// Chunks are sent concurrency at a time, so what's left takes about as many rounds as that
+	// divides into, each as long as a recent request
+	remaining := progress.Chunks - progress.ChunksDone
+	if len(p.latencies) > 0 && remaining > 0 {
+		var total time.Duration
+		for _, latency := range p.latencies {
+			total += latency
+		}
+		parallel := max(min(p.concurrency, remaining), 1)
+		rounds := (remaining + parallel - 1) / parallel
+		progress.ETA = time.Duration(rounds) * total / time.Duration(len(p.latencies))
+	}
+	p.onProgress(progress)
+}
@@ -0,0 +1,396 @@
+package transcriber
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// chunkTranscription is the verbose_json response from the transcription API
+type chunkTranscription struct {
+	Text     string         `json:"text"`
+	Segments []chunkSegment `json:"segments"`
+	Words    []Word         `json:"words"`
+
+	// Language is the language the model transcribed in, and Duration the length of the chunk
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"`
+}
+
+// chunkSegment is a segment of the verbose_json response along with the model's scores for it
+type chunkSegment struct {
+	Segment
+
+	// AvgLogprob is the average log probability of the segment's tokens, and NoSpeechProb the
+	// probability that the segment holds no speech at all
+	AvgLogprob   *float64 `json:"avg_logprob"`
+	NoSpeechProb float64  `json:"no_speech_prob"`
+}
+
+// confidence turns the segment's scores into a probability that its text is right: the average
+// per-token probability, discounted by the chance the segment is not speech. It is rounded to
+// three decimal places, and nil when the API didn't send avg_logprob
+func (s chunkSegment) confidence() *float64 {
+	if s.AvgLogprob == nil {
+		return nil
+	}
+	confidence := math.Exp(min(*s.AvgLogprob, 0)) * (1 - min(max(s.NoSpeechProb, 0), 1))
+	confidence = math.Round(confidence*1000) / 1000
+	return &confidence
+}
+
+// noSpeech reports whether the model judged the segment not to be speech, by Whisper's own rule:
+// a no-speech probability over 0.6 for text it wasn't sure of either
+func (s chunkSegment) noSpeech() bool {
+	return s.NoSpeechProb > 0.6 && s.AvgLogprob != nil && *s.AvgLogprob < -1
+}
+
+// chunkRequest holds the settings sent with every chunk of a file
+type chunkRequest struct {
+	Model       string
+	Prompt      string
+	Temperature float64
+
+	// WordTimestamps asks for the timing of every word as well as of every segment
+	WordTimestamps bool
+
+	// DetectLanguage leaves out Options.Language so the model identifies the language itself
+	DetectLanguage bool
+
+	// SkipNonSpeech leaves stretches of music and silence out of the file's chunks, and
+	// RemoveSilence cuts silences out of its audio before it is chunked
+	SkipNonSpeech bool
+	RemoveSilence bool
+
+	// Speed is how much faster than real time the file's audio was played
+	Speed float64
+
+	// Start is where in the recording the file's audio was cut from
+	Start float64
+
+	// Language is the language hint, overriding Options.Language
+	Language string
+
+	// LanguageModels re-transcribes chunks with the model for the language they were identified as
+	LanguageModels map[string]string
+}
+
+// chunkRequest builds the per-chunk settings for a file
+func (t *Transcriber) chunkRequest(opts TranscribeOptions) chunkRequest {
+	return chunkRequest{
+		Model:       t.model(opts),
+		Prompt:      t.chunkPrompt(opts.Prompt),
+		Temperature: opts.Temperature,
+
+		WordTimestamps: opts.WordTimestamps,
+		SkipNonSpeech:  opts.SkipNonSpeech && !opts.SplitChannels,
+		RemoveSilence:  opts.RemoveSilence,
+		Speed:          opts.Speed,
+		Start:          opts.Start,
+		Language:       opts.Language,
+		DetectLanguage: opts.CodeSwitching,
+		LanguageModels: opts.LanguageModels,
+	}
+}
+
+// routeChunk transcribes a chunk again with the model request.LanguageModels picks for the language
+// it was identified as, hinting it with the language. The first transcript is kept when no other
+// model is picked or the second request fails
+func (t *Transcriber) routeChunk(ctx context.Context, logger *slog.Logger, index int, chunkPath string, request chunkRequest, first *chunkTranscription) *chunkTranscription {
+	language, _ := whisperLanguage(first.Language)
+	model := request.LanguageModels[language]
+	if model == "" || model == request.Model {
+		return first
+	}
+
+	routed := request
+	routed.Model, routed.Language, routed.DetectLanguage, routed.LanguageModels = model, language, false, nil
+	transcription, err := t.transcribeChunk(ctx, chunkPath, routed)
+	if err != nil {
+		logger.Warn("Routed chunk transcription failed; keeping the first transcript", "chunk", index, "model", model, "language", language, "error", err)
+		return first
+	}
+	transcription.Language = first.Language
+	return transcription
+}
+
+// ValidateTimeRange checks that a range to transcribe starts at 0 or later and, unless its end is
+// zero for the end of the recording, ends after it starts
+func ValidateTimeRange(start, end float64) error {
+	if start < 0 || math.IsNaN(start) || math.IsInf(start, 0) {
+		return fmt.Errorf("start must be 0 or more seconds, got %g", start)
+	}
+	if end < 0 || math.IsNaN(end) || math.IsInf(end, 0) || (end > 0 && end <= start) {
+		return fmt.Errorf("end must be after start (%g), got %g", start, end)
+	}
+	return nil
+}
+
+// checkTimeRange reports a start at or past the end of the media. Media whose container doesn't
+// report a duration is let through, and comes out empty
+func checkTimeRange(info MediaInfo, start float64) error {
+	duration, err := strconv.ParseFloat(info.Duration, 64)
+	if err != nil || start < duration {
+		return nil
+	}
+	return &TimeRangeError{Start: start, Duration: duration}
+}
+
+// ValidateTemperature checks that a sampling temperature is between 0 and 1
+func ValidateTemperature(temperature float64) error {
+	if temperature < 0 || temperature > 1 || math.IsNaN(temperature) {
+		return fmt.Errorf("temperature must be between 0 and 1, got %g", temperature)
+	}
+	return nil
+}
+
+// ValidateSpeed checks that a speed factor is 0, meaning real time, or between 1 and MaxSpeed
+func ValidateSpeed(speed float64) error {
+	if speed != 0 && (speed < 1 || speed > MaxSpeed || math.IsNaN(speed)) {
+		return fmt.Errorf("speed must be between 1 and %g, got %g", MaxSpeed, speed)
+	}
+	return nil
+}
+
+// ValidateLanguage checks that a language hint is an ISO-639-1 code such as de, LanguageAuto, or
+// "" for the default. Whisper also knows a few languages only by three-letter codes, such as haw
+func ValidateLanguage(language string) error {
+	if language == "" || language == LanguageAuto {
+		return nil
+	}
+	if len(language) < 2 || len(language) > 3 || strings.TrimFunc(language, func(r rune) bool { return r >= 'a' && r <= 'z' }) != "" {
+		return fmt.Errorf("%q is not a language code such as en or de, or auto", language)
+	}
+	return nil
+}
+
+// ValidatePrompt checks that a caller's prompt fits in MaxPromptLength
+func ValidatePrompt(prompt string) error {
+	if length := utf8.RuneCountInString(prompt); length > MaxPromptLength {
+		return fmt.Errorf("prompt is %d characters, and at most %d are allowed", length, MaxPromptLength)
+	}
+	return nil
+}
+
+// chunkPrompt merges Options.Vocabulary with the caller's prompt. The caller's prompt goes last,
+// where the model pays the most attention, and vocabulary terms that don't fit in
+// MaxPromptLength are left out, last ones first
+func (t *Transcriber) chunkPrompt(prompt string) string {
+	prompt = strings.TrimSpace(prompt)
+	budget := MaxPromptLength - utf8.RuneCountInString(prompt)
+	if prompt != "" {
+		budget -= len(". ")
+	}
+
+	var terms []string
+	used := 0
+	for _, term := range t.opts.Vocabulary {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		cost := utf8.RuneCountInString(term)
+		if len(terms) > 0 {
+			cost += len(", ")
+		}
+		if used+cost > budget {
+			break
+		}
+		terms = append(terms, term)
+		used += cost
+	}
+
+	if len(terms) == 0 {
+		return prompt
+	}
+	vocabulary := strings.Join(terms, ", ")
+	if prompt == "" {
+		return vocabulary
+	}
+	return vocabulary + ". " + prompt
+}
+
+// transcribeChunk sends one chunk to the transcription API with the file's settings, waiting for
+// rate-limit quota and a slot from Options.RequestLimiter before each attempt and retrying after a
+// 429 once the API says to
+func (t *Transcriber) transcribeChunk(ctx context.Context, chunkPath string, request chunkRequest) (*chunkTranscription, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		release, err := t.opts.RequestLimiter.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result, delay, err := t.sendChunk(ctx, chunkPath, request)
+		release()
+		if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, &TimeoutError{Timeout: t.opts.RequestTimeout}
+		}
+		if !errors.Is(err, errRateLimited) || attempt == maxRateLimitRetries || delay > maxRateLimitDelay {
+			return result, err
+		}
+	}
+}
+
+// sendChunk makes one transcription request for a chunk, bounded by Options.RequestTimeout. When
+// the API answers 429, it also returns how long to wait before retrying
+func (t *Transcriber) sendChunk(ctx context.Context, chunkPath string, request chunkRequest) (*chunkTranscription, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.opts.RequestTimeout)
+	defer cancel()
+
+	// Open the file
+	file, err := openMedia(ctx, chunkPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	// Stream the multipart body through a pipe so the chunk is never held in memory
+	bodyReader, bodyWriter := io.Pipe()
+	defer bodyReader.Close()
+	multipartWriter := multipart.NewWriter(bodyWriter)
+	go func() {
+		bodyWriter.CloseWithError(t.writeChunkForm(multipartWriter, file, request))
+	}()
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.opts.APIURL, bodyReader)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+	t.authorize(req)
+
+	// Make the request
+	start := time.Now()
+	resp, err := t.opts.HTTPClient.Do(req)
+	if err != nil {
+		t.observeChunkRequest(start, 0)
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	t.observeChunkRequest(start, resp.StatusCode)
+	delay := t.limiter.observe(resp.StatusCode, resp.Header)
+
+	// Check status code
+	if resp.StatusCode == http.StatusTooManyRequests {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, delay, fmt.Errorf("%w: API returned 429, body: %s", errRateLimited, string(bodyBytes))
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, 0, fmt.Errorf("API returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	// Parse response
+	var result chunkTranscription
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, err
+	}
+
+	return &result, 0, nil
+}
+
+// observeChunkRequest reports how long an API request took and how it was answered
+func (t *Transcriber) observeChunkRequest(start time.Time, statusCode int) {
+	if t.opts.Metrics != nil {
+		t.opts.Metrics.ObserveChunkRequest(time.Since(start), statusCode)
+	}
+}
+
+// writeChunkForm writes the chunk and the transcription settings as a multipart form
+func (t *Transcriber) writeChunkForm(multipartWriter *multipart.Writer, file io.Reader, request chunkRequest) error {
+	// Add the file, named for its format: FLAC from ffmpeg, or WAV when it was preprocessed
+	// without ffmpeg
+	name := "chunk.flac"
+	reader := bufio.NewReader(file)
+	if header, _ := reader.Peek(12); isWAVHeader(header) {
+		name = "chunk.wav"
+	}
+	fileWriter, err := multipartWriter.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+
+	// Copy the file data to the form
+	if _, err = io.Copy(fileWriter, reader); err != nil {
+		return err
+	}
+
+	// Add other form fields
+	if err = multipartWriter.WriteField("model", request.Model); err != nil {
+		return err
+	}
+	if err = multipartWriter.WriteField("temperature", strconv.FormatFloat(request.Temperature, 'f', -1, 64)); err != nil {
+		return err
+	}
+	if err = multipartWriter.WriteField("response_format", "verbose_json"); err != nil {
+		return err
+	}
+	if language := cmp.Or(request.Language, t.opts.Language); !request.DetectLanguage && language != LanguageAuto {
+		if err = multipartWriter.WriteField("language", language); err != nil {
+			return err
+		}
+	}
+	if request.WordTimestamps {
+		for _, granularity := range []string{"segment", "word"} {
+			if err = multipartWriter.WriteField("timestamp_granularities[]", granularity); err != nil {
+				return err
+			}
+		}
+	}
+	if request.Prompt != "" {
+		if err = multipartWriter.WriteField("prompt", request.Prompt); err != nil {
+			return err
+		}
+	}
+
+	// Close the multipart writer to set the terminating boundary
+	return multipartWriter.Close()
+}
+
+// Ping checks that the transcription API is reachable and accepts the API key by listing
+// the models served next to APIURL (e.g. /openai/v1/models for Groq)
+func (t *Transcriber) Ping(ctx context.Context) error {
+	modelsURL := strings.TrimSuffix(t.opts.APIURL, "/audio/transcriptions") + "/models"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL, nil)
+	if err != nil {
+		return err
+	}
+	t.authorize(req)
+
+	resp, err := t.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned non-200 status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// authorize sends the API key with req. Without one, as for self-hosted servers that don't check,
+// no Authorization header is sent at all
+func (t *Transcriber) authorize(req *http.Request) {
+	if key := *t.apiKey.Load(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+}
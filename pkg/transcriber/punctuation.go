@@ -0,0 +1,102 @@
+package transcriber
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sentencePauseSeconds is how long a pause after an unpunctuated segment has to be for
+// RestorePunctuation to end a sentence there
+const sentencePauseSeconds = 0.5
+
+// questionStarts are the words that usually open a question, so a sentence starting with one ends
+// with a question mark rather than a period
+var questionStarts = map[string]bool{
+	"who": true, "whom": true, "whose": true, "what": true, "when": true, "where": true, "why": true,
+	"how": true, "which": true, "is": true, "are": true, "am": true, "was": true, "were": true,
+	"do": true, "does": true, "did": true, "can": true, "could": true, "would": true, "will": true,
+	"should": true, "shall": true, "may": true, "have": true, "has": true, "isnt": true, "arent": true,
+	"dont": true, "doesnt": true, "didnt": true, "cant": true, "wont": true, "wouldnt": true,
+}
+
+// RestorePunctuation returns a copy of segments with casing and sentence ends restored by rule,
+// for providers that return lowercase, sparsely punctuated text. A segment that doesn't end in
+// punctuation ends its sentence when the speaker changes, the recording ends, or a pause of
+// sentencePauseSeconds follows, with a question mark when the sentence opens with a word like
+// "what" or "did" and a period otherwise. Sentences and a lone "I" are capitalized. Punctuation
+// that is already there is kept, so punctuated text passes through as it is
+func RestorePunctuation(segments []Segment) []Segment {
+	restored := slices.Clone(segments)
+	sentenceStart, opener := true, ""
+	for i := range restored {
+		words := strings.Fields(restored[i].Text)
+		if len(words) == 0 {
+			continue
+		}
+		if i > 0 && restored[i].Speaker != restored[i-1].Speaker {
+			sentenceStart = true
+		}
+		for j, word := range words {
+			if sentenceStart {
+				word = capitalizeWord(word)
+				opener = normalizedWords([]string{word})[0]
+			}
+			if lower := strings.ToLower(word); lower == "i" || strings.HasPrefix(lower, "i'") || strings.HasPrefix(lower, "i’") {
+				word = capitalizeWord(word)
+			}
+			words[j] = word
+			sentenceStart = endsSentence(word)
+		}
+
+		last := words[len(words)-1]
+		next := i + 1
+		for next < len(restored) && strings.TrimSpace(restored[next].Text) == "" {
+			next++
+		}
+		boundary := next == len(restored) ||
+			restored[next].Speaker != restored[i].Speaker ||
+			restored[next].Start-restored[i].End >= sentencePauseSeconds
+		if boundary && !sentenceStart && !strings.ContainsAny(lastRune(last), ",;:—-") {
+			if questionStarts[opener] {
+				words[len(words)-1] = last + "?"
+			} else {
+				words[len(words)-1] = last + "."
+			}
+			sentenceStart = true
+		}
+		restored[i].Text = strings.Join(words, " ")
+	}
+	return restored
+}
+
+// SameWords reports whether two texts have the same words in the same order, ignoring case and
+// punctuation, so a rewrite that only restored punctuation can be told from one that changed what
+// was said
+func SameWords(a, b string) bool {
+	return slices.Equal(normalizedWords(strings.Fields(a)), normalizedWords(strings.Fields(b)))
+}
+
+// capitalizeWord upper-cases a word's first letter, after any opening quote or bracket. Words
+// that start with anything else, such as a number, are left alone
+func capitalizeWord(word string) string {
+	start := len(word) - len(strings.TrimLeft(word, `"'(“‘`))
+	r, size := utf8.DecodeRuneInString(word[start:])
+	if !unicode.IsLower(r) {
+		return word
+	}
+	return word[:start] + string(unicode.ToUpper(r)) + word[start+size:]
+}
+
+// endsSentence reports whether a word ends in a sentence's closing punctuation, before any
+// closing quote or bracket
+func endsSentence(word string) bool {
+	return strings.ContainsAny(lastRune(strings.TrimRight(word, `"')”’`)), ".?!…")
+}
+
+// lastRune returns the last character of s, or "" when it is empty
+func lastRune(s string) string {
+	_, size := utf8.DecodeLastRuneInString(s)
+	return s[len(s)-size:]
+}
@@ -0,0 +1,245 @@
+package transcriber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Thresholds below which AnalyzeQuality warns that a recording may transcribe poorly
+const (
+	// qualityMinSampleRate is the rate audio is resampled to for the model; lower rates have
+	// already lost the upper frequencies of speech
+	qualityMinSampleRate = 16000
+
+	// qualityMinBitRate is the bitrate below which lossy codecs audibly smear consonants
+	qualityMinBitRate = 32000
+
+	// qualityMinSNR is the signal-to-noise ratio, in dB, below which background noise competes with speech
+	qualityMinSNR = 15.0
+
+	// qualityMinRMS is the average level, in dBFS, below which speech is too quiet to hear clearly
+	qualityMinRMS = -40.0
+
+	// qualityMaxClippedPercent is the share of samples at full scale above which clipping is reported
+	qualityMaxClippedPercent = 0.01
+
+	// qualityMaxSilencePercent is the share of silence above which there may be little to transcribe
+	qualityMaxSilencePercent = 80.0
+)
+
+// Silence, for SilencePercent, is audio quieter than silenceThreshold for at least silenceMinSeconds
+const (
+	silenceThreshold  = "-50dB"
+	silenceMinSeconds = 0.5
+)
+
+// clippingLevel is the peak level, in dBFS, at which samples are taken to be clipped
+const clippingLevel = -0.1
+
+// AudioQuality describes the technical quality of a recording's audio stream
+type AudioQuality struct {
+	DurationSeconds float64 `json:"duration_seconds"`
+	Codec           string  `json:"codec"`
+	SampleRate      int     `json:"sample_rate"`
+	Channels        int     `json:"channels"`
+
+	// BitRate is the stream's bitrate in bits per second, or the container's when the stream's
+	// isn't recorded, or zero when neither is
+	BitRate int `json:"bit_rate"`
+
+	// PeakDB and RMSDB are the peak and average levels in dBFS, nil for digital silence
+	PeakDB *float64 `json:"peak_db"`
+	RMSDB  *float64 `json:"rms_db"`
+
+	// SNR is a rough signal-to-noise ratio in dB: the average level over the noise floor, the
+	// level of the quietest stretches. It is nil when the noise floor is digital silence
+	SNR *float64 `json:"snr_db"`
+
+	// ClippedPercent is the share of samples at full scale, and Clipping reports whether it is
+	// enough to distort speech
+	Clipping       bool    `json:"clipping"`
+	ClippedPercent float64 `json:"clipped_percent"`
+
+	// SilencePercent is the share of the recording that is silent for half a second or more
+	SilencePercent float64 `json:"silence_percent"`
+
+	// Warnings explains, in plain words, each way the recording may transcribe poorly
+	Warnings []string `json:"warnings"`
+}
+
+// AnalyzeQuality measures the audio stream of the media file at inputPath with FFmpeg's astats and
+// silencedetect filters, without transcribing it. Only opts.AudioTrack and opts.AudioLanguage are
+// used. The whole stream is decoded, so it takes about as long as preprocessing
+func (t *Transcriber) AnalyzeQuality(ctx context.Context, inputPath string, opts TranscribeOptions) (*AudioQuality, error) {
+	mediaInfo, err := ValidateMedia(ctx, inputPath)
+	if err == nil {
+		err = t.checkDuration(mediaInfo, TranscribeOptions{})
+	}
+	if err != nil {
+		return nil, stageError(ctx, StageValidate, err)
+	}
+	stream, err := SelectAudioStream(mediaInfo, opts.AudioTrack, opts.AudioLanguage)
+	if err != nil {
+		return nil, &StageError{Stage: StageSelectStream, Err: err}
+	}
+
+	quality := &AudioQuality{
+		Codec:      stream.CodecName,
+		SampleRate: stream.SampleRate,
+		Channels:   stream.Channels,
+		BitRate:    stream.BitRate,
+		Warnings:   []string{},
+	}
+	if quality.BitRate == 0 {
+		quality.BitRate = mediaInfo.BitRate
+	}
+	quality.DurationSeconds, _ = strconv.ParseFloat(mediaInfo.Duration, 64)
+
+	var stats []byte
+	err = timedStage(ctx, StageAnalyze, t.opts.PreprocessTimeout, func(ctx context.Context) error {
+		var measureErr error
+		stats, measureErr = measureAudio(ctx, inputPath, stream.Index)
+		return measureErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	parseAudioStats(stats, quality)
+	quality.Warnings = qualityWarnings(quality)
+	return quality, nil
+}
+
+// measureAudio decodes one stream through silencedetect and astats and returns their log output
+func measureAudio(ctx context.Context, inputPath string, streamIndex int) ([]byte, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		FFmpegPath,
+		"-hide_banner", "-nostats",
+		"-i", inputPath,
+		"-map", fmt.Sprintf("0:%d", streamIndex),
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%g,astats", silenceThreshold, silenceMinSeconds),
+		"-f", "null", "-",
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runCommand(ctx, "ffmpeg analyze", cmd); err != nil {
+		tail := tailBuffer{}
+		tail.Write(stderr.Bytes())
+		if line := tail.lastLine(); line != "" {
+			return nil, fmt.Errorf("%w: %s", err, line)
+		}
+		return nil, err
+	}
+	return stderr.Bytes(), nil
+}
+
+// parseAudioStats fills in quality from the log lines of silencedetect and from the "Overall"
+// section astats prints when the stream ends
+func parseAudioStats(output []byte, quality *AudioQuality) {
+	var silence, silenceStart float64
+	silent, overall := false, false
+	stats := map[string]float64{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		_, message, ok := strings.Cut(line, "] ")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "silencedetect"):
+			if value, ok := strings.CutPrefix(message, "silence_start: "); ok {
+				silenceStart, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+				silent = true
+			} else if _, value, ok := strings.Cut(message, "silence_duration: "); ok {
+				if seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					silence += seconds
+				}
+				silent = false
+			}
+		case strings.Contains(line, "astats"):
+			if strings.TrimSpace(message) == "Overall" {
+				overall = true
+				continue
+			}
+			if key, value, ok := strings.Cut(message, ":"); ok && overall {
+				if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					stats[key] = number
+				}
+			}
+		}
+	}
+	// A recording that ends in silence never logs its silence_end
+	if silent && quality.DurationSeconds > silenceStart {
+		silence += quality.DurationSeconds - max(silenceStart, 0)
+	}
+	if quality.DurationSeconds > 0 {
+		quality.SilencePercent = roundTo(min(silence/quality.DurationSeconds*100, 100), 1)
+	}
+
+	quality.PeakDB = finiteStat(stats, "Peak level dB")
+	quality.RMSDB = finiteStat(stats, "RMS level dB")
+	if noise := finiteStat(stats, "Noise floor dB"); noise != nil && quality.RMSDB != nil {
+		snr := roundTo(*quality.RMSDB-*noise, 1)
+		quality.SNR = &snr
+	}
+
+	// Every sample at the peak level counts as clipped once the peak reaches full scale
+	samples := stats["Number of samples"] * float64(max(quality.Channels, 1))
+	if quality.PeakDB != nil && *quality.PeakDB >= clippingLevel && samples > 0 {
+		quality.ClippedPercent = roundTo(stats["Peak count"]/samples*100, 3)
+		quality.Clipping = quality.ClippedPercent >= qualityMaxClippedPercent
+	}
+}
+
+// finiteStat returns an astats value rounded for display, or nil when it is missing or infinite,
+// as levels are for digital silence
+func finiteStat(stats map[string]float64, key string) *float64 {
+	value, ok := stats[key]
+	if !ok || math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil
+	}
+	value = roundTo(value, 1)
+	return &value
+}
+
+// roundTo rounds value to the given number of decimal places, turning -0 into 0
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale)/scale + 0
+}
+
+// qualityWarnings lists the ways a recording measured by AnalyzeQuality may transcribe poorly
+func qualityWarnings(quality *AudioQuality) []string {
+	warnings := []string{}
+	if quality.RMSDB == nil {
+		return append(warnings, "The audio is silent: there is nothing to transcribe")
+	}
+	if quality.SampleRate > 0 && quality.SampleRate < qualityMinSampleRate {
+		warnings = append(warnings, fmt.Sprintf("The sample rate is %d Hz, below the 16 kHz the model listens at, so speech may be muffled", quality.SampleRate))
+	}
+	if quality.BitRate > 0 && quality.BitRate < qualityMinBitRate {
+		warnings = append(warnings, fmt.Sprintf("The bitrate is %d kb/s; heavy compression can blur consonants", quality.BitRate/1000))
+	}
+	if quality.SNR != nil && *quality.SNR < qualityMinSNR {
+		warnings = append(warnings, fmt.Sprintf("Background noise is only %.0f dB below the speech; denoising may help", *quality.SNR))
+	}
+	if *quality.RMSDB < qualityMinRMS {
+		warnings = append(warnings, fmt.Sprintf("The audio is very quiet (%.0f dBFS on average); normalizing may help", *quality.RMSDB))
+	}
+	if quality.Clipping {
+		warnings = append(warnings, fmt.Sprintf("%.2f%% of samples are clipped; the distortion can cause misrecognized words", quality.ClippedPercent))
+	}
+	if quality.SilencePercent > qualityMaxSilencePercent {
+		warnings = append(warnings, fmt.Sprintf("%.0f%% of the recording is silence", quality.SilencePercent))
+	}
+	return warnings
+}
@@ -0,0 +1,149 @@
+package transcriber
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateLimitDelay is how long to back off after a 429 that doesn't say how long to wait
+	defaultRateLimitDelay = 5 * time.Second
+
+	// maxRateLimitDelay is the longest a chunk waits for quota; a longer wait (a daily limit, say)
+	// fails the chunk instead of stalling the job
+	maxRateLimitDelay = 2 * time.Minute
+
+	// maxRateLimitRetries is how many times a chunk is retried after a 429
+	maxRateLimitRetries = 5
+)
+
+// errRateLimited is wrapped by the error for a chunk the API answered with 429
+var errRateLimited = errors.New("rate limited")
+
+// rateLimiter schedules every API request of a Transcriber from the provider's rate-limit headers.
+// When quota runs out, or a 429 arrives, requests pause until it resets; when quota is low but not
+// gone, requests are spread evenly over what is left of the window instead of being sent in a burst
+type rateLimiter struct {
+	mu sync.Mutex
+
+	// resumeAt holds every request back until quota resets
+	resumeAt time.Time
+
+	// interval spaces requests apart while quota is low, counting from the last request sent
+	interval time.Duration
+	lastSent time.Time
+
+	// lowQuota is the remaining request count below which requests are spaced out
+	lowQuota int
+}
+
+// wait blocks until the next request may be sent, or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.resumeAt.After(slot) {
+		slot = l.resumeAt
+	}
+	if next := l.lastSent.Add(l.interval); l.interval > 0 && next.After(slot) {
+		slot = next
+	}
+	l.lastSent = slot
+	l.mu.Unlock()
+
+	if !slot.After(now) {
+		return nil
+	}
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observe updates the schedule from a response and returns how long to wait before retrying a 429
+func (l *rateLimiter) observe(statusCode int, header http.Header) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+
+	var delay time.Duration
+	if statusCode == http.StatusTooManyRequests {
+		delay = retryAfter(header, now)
+		if delay <= 0 {
+			delay = resetDelay(header, "requests")
+		}
+		if delay <= 0 {
+			delay = defaultRateLimitDelay
+		}
+		l.pauseUntil(now.Add(delay))
+	}
+
+	// Exhausted token quota pauses requests just like exhausted request quota
+	if remaining, ok := remainingQuota(header, "tokens"); ok && remaining == 0 {
+		l.pauseUntil(now.Add(resetDelay(header, "tokens")))
+	}
+
+	remaining, ok := remainingQuota(header, "requests")
+	if !ok {
+		return delay
+	}
+	reset := resetDelay(header, "requests")
+	switch {
+	case remaining == 0:
+		l.pauseUntil(now.Add(reset))
+	case remaining < l.lowQuota && reset > 0:
+		l.interval = reset / time.Duration(remaining)
+	default:
+		l.interval = 0
+	}
+	return delay
+}
+
+// pauseUntil holds requests back until at least t, but no more than maxRateLimitDelay from now
+func (l *rateLimiter) pauseUntil(t time.Time) {
+	if limit := time.Now().Add(maxRateLimitDelay); t.After(limit) {
+		t = limit
+	}
+	if t.After(l.resumeAt) {
+		l.resumeAt = t
+	}
+}
+
+// retryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// remainingQuota reads x-ratelimit-remaining-<kind>
+func remainingQuota(header http.Header, kind string) (int, bool) {
+	remaining, err := strconv.Atoi(header.Get("X-Ratelimit-Remaining-" + kind))
+	return remaining, err == nil
+}
+
+// resetDelay reads x-ratelimit-reset-<kind>, which Groq and OpenAI send as a duration like "7.66s"
+// or "2m59.56s"
+func resetDelay(header http.Header, kind string) time.Duration {
+	reset, err := time.ParseDuration(header.Get("X-Ratelimit-Reset-" + kind))
+	if err != nil {
+		return 0
+	}
+	return reset
+}
@@ -0,0 +1,156 @@
+package transcriber
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Masks that replace redacted personal information
+const (
+	RedactedEmail = "[EMAIL]"
+	RedactedPhone = "[PHONE]"
+	RedactedCard  = "[CARD]"
+	RedactedName  = "[NAME]"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+	// cardPattern finds runs of 13 to 19 digits, optionally grouped with spaces or dashes;
+	// only those passing the Luhn check are masked
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+	// phonePattern finds North American numbers, with or without a country code, and other
+	// numbers written with a leading + and country code
+	phonePattern = regexp.MustCompile(`(?:\+?1[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b|\+\d{1,3}(?:[ .-]?\(?\d{1,4}\)?){2,5}\d`)
+)
+
+// RedactText masks email addresses, phone numbers, and credit card numbers in text, along with
+// any of the given names
+func RedactText(text string, names []string) string {
+	text = emailPattern.ReplaceAllString(text, RedactedEmail)
+	text = cardPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if luhnValid(match) {
+			return RedactedCard
+		}
+		return match
+	})
+	text = phonePattern.ReplaceAllString(text, RedactedPhone)
+	if pattern := namePattern(names); pattern != nil {
+		text = pattern.ReplaceAllString(text, RedactedName)
+	}
+	return text
+}
+
+// Redact masks personal information in a result's transcription, segments, and words, as
+// RedactText does
+func Redact(result *Result, names []string) {
+	result.Transcription = RedactText(result.Transcription, names)
+	for i := range result.Segments {
+		result.Segments[i].Text = RedactText(result.Segments[i].Text, names)
+	}
+	result.Words = redactWords(result.Words, names)
+}
+
+// redactWords masks personal information in timed words. Numbers and names are often spread over
+// several words, so the words are searched as one text, and the words a match covers are merged
+// into a single word holding the mask and spanning their times
+func redactWords(words []Word, names []string) []Word {
+	if len(words) == 0 {
+		return words
+	}
+	var text strings.Builder
+	offsets := make([]int, len(words))
+	for i, word := range words {
+		if i > 0 {
+			text.WriteByte(' ')
+		}
+		offsets[i] = text.Len()
+		text.WriteString(word.Word)
+	}
+	joined := text.String()
+
+	// masks[i] is the mask that replaces the words from i up to ends[i]
+	masks := make([]string, len(words))
+	ends := make([]int, len(words))
+	mask := func(start, end int, placeholder string) {
+		first := sort.SearchInts(offsets, start+1) - 1
+		last := sort.SearchInts(offsets, end) - 1
+		if masks[first] == "" {
+			masks[first], ends[first] = placeholder, last
+		}
+	}
+	for _, match := range emailPattern.FindAllStringIndex(joined, -1) {
+		mask(match[0], match[1], RedactedEmail)
+	}
+	for _, match := range cardPattern.FindAllStringIndex(joined, -1) {
+		if luhnValid(joined[match[0]:match[1]]) {
+			mask(match[0], match[1], RedactedCard)
+		}
+	}
+	for _, match := range phonePattern.FindAllStringIndex(joined, -1) {
+		mask(match[0], match[1], RedactedPhone)
+	}
+	if pattern := namePattern(names); pattern != nil {
+		for _, match := range pattern.FindAllStringIndex(joined, -1) {
+			mask(match[0], match[1], RedactedName)
+		}
+	}
+
+	redacted := make([]Word, 0, len(words))
+	for i := 0; i < len(words); i++ {
+		if masks[i] == "" {
+			redacted = append(redacted, words[i])
+			continue
+		}
+		// A match that starts inside another and runs past it is merged into it
+		end := ends[i]
+		for j := i + 1; j <= end; j++ {
+			if masks[j] != "" {
+				end = max(end, ends[j])
+			}
+		}
+		redacted = append(redacted, Word{Word: masks[i], Start: words[i].Start, End: words[end].End})
+		i = end
+	}
+	return redacted
+}
+
+// namePattern matches any of the names as whole words, ignoring case, or is nil when there are none.
+// Longer names come first so "Mary Ann" is masked as one name rather than leaving "Ann"
+func namePattern(names []string) *regexp.Regexp {
+	var quoted []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			quoted = append(quoted, regexp.QuoteMeta(name))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used by card numbers
+func luhnValid(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
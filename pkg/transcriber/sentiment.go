@@ -0,0 +1,19 @@
+package transcriber
+
+// SentimentLabel is whether a segment sounds positive, neutral, or negative
+type SentimentLabel string
+
+const (
+	SentimentPositive SentimentLabel = "positive"
+	SentimentNeutral  SentimentLabel = "neutral"
+	SentimentNegative SentimentLabel = "negative"
+)
+
+// Sentiment is the tone of a segment and how strongly it comes across
+type Sentiment struct {
+	Label SentimentLabel `json:"label"`
+
+	// Intensity is how strongly the segment is positive or negative, from 0 (mildly) to 1
+	// (strongly, such as an angry complaint). Neutral segments are near 0
+	Intensity float64 `json:"intensity"`
+}
@@ -0,0 +1,170 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+)
+
+// silencePadSeconds is how much of each silence RemoveSilence keeps on either side, so words
+// aren't clipped and the model still hears a pause between them
+const silencePadSeconds = 0.25
+
+// timeline maps times in audio with its silences cut out back onto the recording's own
+type timeline struct {
+	// spans are the stretches of the recording that were kept, and offsets where each starts in
+	// the cut audio
+	spans   []ClipSpan
+	offsets []float64
+
+	// duration is the length of the whole recording, and kept the length of the cut audio
+	duration float64
+	kept     float64
+}
+
+// original returns where a time in the cut audio falls in the recording. A nil timeline, for
+// audio nothing was cut from, returns times as they are
+func (tl *timeline) original(seconds float64) float64 {
+	if tl == nil {
+		return seconds
+	}
+	i := 0
+	for i+1 < len(tl.spans) && tl.offsets[i+1] <= seconds {
+		i++
+	}
+	return min(tl.spans[i].Start+seconds-tl.offsets[i], tl.duration)
+}
+
+// removed returns how many seconds of silence were cut
+func (tl *timeline) removed() float64 {
+	if tl == nil {
+		return 0
+	}
+	return tl.duration - tl.kept
+}
+
+// findSilences returns the stretches of frame levels, each frameSeconds long, that stay below
+// silenceLevel for at least minSeconds, less silencePadSeconds either side of them. Silence at the
+// start or end of the recording is cut in full
+func findSilences(levels []float64, frameSeconds, minSeconds float64) []NonSpeech {
+	var silences []NonSpeech
+	quiet := -1
+	for i := 0; i <= len(levels); i++ {
+		if i < len(levels) && levels[i] < silenceLevel {
+			if quiet < 0 {
+				quiet = i
+			}
+			continue
+		}
+		if quiet >= 0 && float64(i-quiet)*frameSeconds >= minSeconds {
+			silence := NonSpeech{Start: float64(quiet) * frameSeconds, End: float64(i) * frameSeconds, Kind: "silence"}
+			if quiet > 0 {
+				silence.Start += silencePadSeconds
+			}
+			if i < len(levels) {
+				silence.End -= silencePadSeconds
+			}
+			silences = append(silences, silence)
+		}
+		quiet = -1
+	}
+	return silences
+}
+
+// removeSilence writes a preprocessed 16 kHz mono file to outputPath with its silences of at least
+// minSeconds cut out, and returns the timeline of what was kept, or nil, writing nothing, when
+// there are no such silences. WAV files are cut in-process, and others with ffmpeg's atrim and
+// concat, which also cut on the sample
+func removeSilence(ctx context.Context, inputPath, outputPath string, minSeconds float64) (*timeline, error) {
+	meter := &levelMeter{frameSamples: int(nonSpeechFrameSeconds * nativeSampleRate)}
+	if err := measureLevels(ctx, inputPath, meter); err != nil {
+		return nil, err
+	}
+	silences := findSilences(meter.levels, nonSpeechFrameSeconds, minSeconds)
+	if len(silences) == 0 {
+		return nil, nil
+	}
+
+	// Spans are cut on whole samples, so the timeline matches the cut audio exactly
+	duration := float64(meter.samples) / nativeSampleRate
+	tl := &timeline{duration: duration}
+	for _, span := range speechSpans(silences, duration) {
+		first := int64(math.Round(span.Start * nativeSampleRate))
+		last := int64(math.Round(span.End * nativeSampleRate))
+		if last <= first {
+			continue
+		}
+		tl.spans = append(tl.spans, ClipSpan{Start: float64(first) / nativeSampleRate, End: float64(last) / nativeSampleRate})
+		tl.offsets = append(tl.offsets, tl.kept)
+		tl.kept += float64(last-first) / nativeSampleRate
+	}
+	if len(tl.spans) == 0 {
+		// Nothing but silence: keep a moment of it, so there is still audio to chunk
+		tl.spans = []ClipSpan{{End: min(duration, silencePadSeconds)}}
+		tl.offsets = []float64{0}
+		tl.kept = tl.spans[0].End
+	}
+
+	if fileCipherFrom(ctx) != nil || isWAVFile(ctx, inputPath) {
+		return tl, compactWAV(ctx, inputPath, outputPath, tl.spans)
+	}
+	var graph strings.Builder
+	fmt.Fprintf(&graph, "[0:a]asplit=%d", len(tl.spans))
+	for i := range tl.spans {
+		fmt.Fprintf(&graph, "[in%d]", i)
+	}
+	graph.WriteString(";")
+	for i, span := range tl.spans {
+		fmt.Fprintf(&graph, "[in%d]atrim=start_sample=%d:end_sample=%d,asetpts=PTS-STARTPTS[out%d];",
+			i, int64(math.Round(span.Start*nativeSampleRate)), int64(math.Round(span.End*nativeSampleRate)), i)
+	}
+	for i := range tl.spans {
+		fmt.Fprintf(&graph, "[out%d]", i)
+	}
+	fmt.Fprintf(&graph, "concat=n=%d:v=0:a=1[kept]", len(tl.spans))
+	cmd := exec.CommandContext(ctx, FFmpegPath,
+		"-i", inputPath,
+		"-filter_complex", graph.String(),
+		"-map", "[kept]",
+		"-c:a", "flac", outputPath,
+	)
+	return tl, runCommand(ctx, "ffmpeg remove silence", cmd)
+}
+
+// compactWAV copies the spans of a WAV file, one after another, into a new WAV file of the same
+// format
+func compactWAV(ctx context.Context, inputPath, outputPath string, spans []ClipSpan) error {
+	file, format, err := openWAV(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	type section struct{ offset, size int64 }
+	var sections []section
+	var size int64
+	for _, span := range spans {
+		first := min(int64(math.Round(span.Start*float64(format.SampleRate))), format.frames())
+		last := min(int64(math.Round(span.End*float64(format.SampleRate))), format.frames())
+		sections = append(sections, section{format.DataOffset + first*int64(format.BlockAlign), (last - first) * int64(format.BlockAlign)})
+		size += (last - first) * int64(format.BlockAlign)
+	}
+
+	out, err := createMedia(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := writeWAVHeader(out, format.Encoding, format.Channels, format.SampleRate, format.BitsPerSample, size); err != nil {
+		return err
+	}
+	for _, s := range sections {
+		if _, err := io.Copy(out, io.NewSectionReader(file, s.offset, s.size)); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
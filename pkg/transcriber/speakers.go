@@ -0,0 +1,81 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// ClipSpan is a stretch of a recording, in seconds from its start
+type ClipSpan struct {
+	Start float64
+	End   float64
+}
+
+// ClipOptions holds the per-file settings for ExtractClips
+type ClipOptions struct {
+	// AudioTrack and AudioLanguage pick the audio stream, as for Transcribe
+	AudioTrack    string
+	AudioLanguage string
+
+	// Cipher, when set, is what the file at inputPath was encrypted with, and the clips are
+	// encrypted with it too
+	Cipher *FileCipher
+}
+
+// ExtractClips writes each span of the media file at inputPath to workDir as a 16 kHz mono WAV
+// clip, such as for a speaker embedding model, and returns their paths in the order of spans.
+// The audio is decoded once, with ffmpeg or in-process for a WAV file without it, then cut
+func ExtractClips(ctx context.Context, inputPath, workDir string, spans []ClipSpan, opts ClipOptions) ([]string, error) {
+	ctx = withFileCipher(ctx, opts.Cipher)
+	info, err := ValidateMedia(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := SelectAudioStream(info, opts.AudioTrack, opts.AudioLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	audioPath := filepath.Join(workDir, "clips.wav")
+	if err := decodeWAV(ctx, inputPath, audioPath, stream.Index); err != nil {
+		return nil, err
+	}
+	defer os.Remove(audioPath)
+
+	paths := make([]string, len(spans))
+	for i, span := range spans {
+		if span.End <= span.Start {
+			return nil, fmt.Errorf("clip %d ends at %.3fs, before it starts at %.3fs", i, span.End, span.Start)
+		}
+		paths[i] = filepath.Join(workDir, fmt.Sprintf("clip_%04d.wav", i))
+		if err := cutWAV(ctx, audioPath, paths[i], span.Start, span.End-span.Start); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// decodeWAV writes a stream of a media file to outputPath as 16 kHz mono 16-bit PCM WAV, which
+// cutWAV can cut without ffmpeg
+func decodeWAV(ctx context.Context, inputPath, outputPath string, streamIndex int) error {
+	if useNativeAudio(ctx, FFmpegPath, inputPath) {
+		return preprocessWAV(ctx, inputPath, outputPath, 0, 0, 0)
+	}
+	input, stop, err := mediaInput(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	cmd := exec.CommandContext(ctx, FFmpegPath,
+		"-i", input,
+		"-vn",
+		"-ar", "16000",
+		"-ac", "1",
+		"-map", fmt.Sprintf("0:%d", streamIndex),
+		"-c:a", "pcm_s16le", "-f", "wav", "pipe:1",
+	)
+	return runToMedia(ctx, "ffmpeg decode", cmd, outputPath)
+}
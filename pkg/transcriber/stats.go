@@ -0,0 +1,112 @@
+package transcriber
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stats describes how much was said in a transcript and how fast
+type Stats struct {
+	Words      int `json:"words"`
+	Characters int `json:"characters"`
+
+	// TalkSeconds is the time covered by segments of speech, leaving out the pauses between them
+	// and segments the model judged not to be speech, and WordsPerMinute the speaking rate over it
+	TalkSeconds    float64 `json:"talk_seconds"`
+	WordsPerMinute float64 `json:"words_per_minute"`
+
+	// Speakers break the talk time down by speaker, most talkative first, when segments are
+	// labelled with one
+	Speakers []SpeakerStats `json:"speakers,omitempty"`
+
+	// Languages break the talk time down by language, most spoken first, when segments are
+	// tagged with one
+	Languages []LanguageStats `json:"languages,omitempty"`
+}
+
+// LanguageStats is how much of the transcript was in one language, by its ISO-639-1 code
+type LanguageStats struct {
+	Language    string  `json:"language"`
+	TalkSeconds float64 `json:"talk_seconds"`
+	TalkPercent float64 `json:"talk_percent"`
+}
+
+// SpeakerStats is how much one speaker said. TalkPercent is their share of the transcript's
+// TalkSeconds, so the shares of every speaker add up to 100 unless some speech is unlabelled
+type SpeakerStats struct {
+	Speaker        string  `json:"speaker"`
+	Words          int     `json:"words"`
+	TalkSeconds    float64 `json:"talk_seconds"`
+	TalkPercent    float64 `json:"talk_percent"`
+	WordsPerMinute float64 `json:"words_per_minute"`
+}
+
+// TranscriptStats counts the words and characters of a transcript, and works out the speaking
+// rate and the talk time of each speaker and language from its segments. Words are runs of
+// characters between spaces, so counts are rough for languages written without them
+func TranscriptStats(transcript string, segments []Segment) Stats {
+	transcript = strings.TrimSpace(transcript)
+	stats := Stats{
+		Words:      len(strings.Fields(transcript)),
+		Characters: utf8.RuneCountInString(transcript),
+	}
+
+	var spokenWords int
+	bySpeaker := map[string]*SpeakerStats{}
+	byLanguage := map[string]float64{}
+	for _, segment := range segments {
+		if segment.NoSpeech || segment.End <= segment.Start {
+			continue
+		}
+		seconds := segment.End - segment.Start
+		words := len(strings.Fields(segment.Text))
+		stats.TalkSeconds += seconds
+		spokenWords += words
+		if segment.Language != "" {
+			byLanguage[segment.Language] += seconds
+		}
+		if segment.Speaker == "" {
+			continue
+		}
+		speaker, ok := bySpeaker[segment.Speaker]
+		if !ok {
+			speaker = &SpeakerStats{Speaker: segment.Speaker}
+			bySpeaker[segment.Speaker] = speaker
+		}
+		speaker.Words += words
+		speaker.TalkSeconds += seconds
+	}
+	stats.WordsPerMinute = wordsPerMinute(spokenWords, stats.TalkSeconds)
+
+	for _, speaker := range bySpeaker {
+		speaker.TalkPercent = roundTo(speaker.TalkSeconds/stats.TalkSeconds*100, 1)
+		speaker.WordsPerMinute = wordsPerMinute(speaker.Words, speaker.TalkSeconds)
+		speaker.TalkSeconds = roundTo(speaker.TalkSeconds, 2)
+		stats.Speakers = append(stats.Speakers, *speaker)
+	}
+	slices.SortFunc(stats.Speakers, func(a, b SpeakerStats) int {
+		return cmp.Or(cmp.Compare(b.TalkSeconds, a.TalkSeconds), cmp.Compare(a.Speaker, b.Speaker))
+	})
+	for language, seconds := range byLanguage {
+		stats.Languages = append(stats.Languages, LanguageStats{
+			Language:    language,
+			TalkSeconds: roundTo(seconds, 2),
+			TalkPercent: roundTo(seconds/stats.TalkSeconds*100, 1),
+		})
+	}
+	slices.SortFunc(stats.Languages, func(a, b LanguageStats) int {
+		return cmp.Or(cmp.Compare(b.TalkSeconds, a.TalkSeconds), cmp.Compare(a.Language, b.Language))
+	})
+	stats.TalkSeconds = roundTo(stats.TalkSeconds, 2)
+	return stats
+}
+
+// wordsPerMinute is the rate of so many words over so many seconds, or 0 without any time
+func wordsPerMinute(words int, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return roundTo(float64(words)/seconds*60, 1)
+}
@@ -0,0 +1,150 @@
+package transcriber
+
+import (
+	"slices"
+	"strings"
+)
+
+// Segment is a timed span of the transcript, with times relative to the start of the audio
+type Segment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+
+	// Language is the ISO-639-1 code of the language the segment was transcribed in, when the
+	// model identified it rather than being told
+	Language string `json:"language,omitempty"`
+
+	// Speaker labels the channel the segment came from when channels are transcribed separately,
+	// or the enrolled speaker it was identified as
+	Speaker string `json:"speaker,omitempty"`
+
+	// Confidence is how sure the model was of the segment, from 0 to 1, or nil when the API
+	// didn't score it. It stays that of OriginalText when the segment is Edited
+	Confidence *float64 `json:"confidence,omitempty"`
+
+	// NoSpeech marks a segment the model judged to hold no speech, which is often text it made up
+	// over music or noise
+	NoSpeech bool `json:"no_speech,omitempty"`
+
+	// Hallucination is why DetectHallucinations suspects the model made the segment up, such as
+	// HallucinationRepetition, or "" when it doesn't or wasn't asked
+	Hallucination string `json:"hallucination,omitempty"`
+
+	// Sentiment is how positive or negative the segment sounds, when it was analyzed. It stays that
+	// of OriginalText when the segment is Edited
+	Sentiment *Sentiment `json:"sentiment,omitempty"`
+
+	// LowConfidence is set by FlagLowConfidence on segments scored below the caller's threshold
+	LowConfidence bool `json:"low_confidence,omitempty"`
+
+	// Edited marks a segment whose Text was corrected after transcription, and OriginalText is
+	// what the model transcribed
+	Edited       bool   `json:"edited,omitempty"`
+	OriginalText string `json:"original_text,omitempty"`
+}
+
+// Word is a single recognized word with its timing, relative to the start of the audio
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// JoinSegments builds a transcript from its segments: a "Speaker: text" line per segment when
+// they are labeled with speakers, as split channels are, otherwise their text run together. A
+// segment left without a label among labeled ones gets a line of just its text
+func JoinSegments(segments []Segment) string {
+	labeled := slices.ContainsFunc(segments, func(segment Segment) bool { return segment.Speaker != "" })
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		switch {
+		case labeled && segment.Speaker != "":
+			parts = append(parts, segment.Speaker+": "+segment.Text)
+		case labeled:
+			parts = append(parts, segment.Text)
+		case segment.Text != "":
+			parts = append(parts, segment.Text)
+		}
+	}
+	if labeled {
+		return strings.Join(parts, "\n")
+	}
+	return strings.Join(parts, " ")
+}
+
+// segmentStitcher shifts each chunk's segments onto the timeline of the full recording and
+// drops segments that fall inside the overlap already covered by the previous chunk.
+// Chunks must be added in order
+type segmentStitcher struct {
+	segments []Segment
+	lastEnd  float64
+
+	// words are stitched the same way when word timestamps were requested
+	words       []Word
+	lastWordEnd float64
+
+	// timeline, when silences were cut from the audio, puts times back on the recording's,
+	// speed, when the audio was sped up, scales them back to real time, and offset, when only
+	// part of the recording was transcribed, is where that part starts
+	timeline *timeline
+	speed    float64
+	offset   float64
+
+	// tagLanguage labels segments with the language the model identified for their chunk
+	tagLanguage bool
+}
+
+// scale returns how much times in the sped-up audio are stretched to real time
+func (s *segmentStitcher) scale() float64 {
+	return max(s.speed, 1)
+}
+
+// realTime returns where a time in the audio sent to the API falls in the recording
+func (s *segmentStitcher) realTime(seconds float64) float64 {
+	return s.timeline.original(seconds)*s.scale() + s.offset
+}
+
+// add stitches one chunk's segments (result may be nil for a failed chunk) and returns the ones kept
+func (s *segmentStitcher) add(chunk audioChunk, result *chunkTranscription) []Segment {
+	if s.segments == nil {
+		s.segments = []Segment{}
+	}
+	if result == nil {
+		return nil
+	}
+
+	var language string
+	if s.tagLanguage {
+		language, _ = whisperLanguage(result.Language)
+	}
+	first := len(s.segments)
+	for _, segment := range result.Segments {
+		start := s.realTime(segment.Start + chunk.StartSec)
+		end := s.realTime(segment.End + chunk.StartSec)
+		if len(s.segments) > 0 && end <= s.lastEnd {
+			continue
+		}
+		s.segments = append(s.segments, Segment{
+			ID:         len(s.segments),
+			Start:      start,
+			End:        end,
+			Text:       strings.TrimSpace(segment.Text),
+			Language:   language,
+			Confidence: segment.confidence(),
+			NoSpeech:   segment.noSpeech(),
+		})
+		s.lastEnd = end
+	}
+	for _, word := range result.Words {
+		start := s.realTime(word.Start + chunk.StartSec)
+		end := s.realTime(word.End + chunk.StartSec)
+		if len(s.words) > 0 && end <= s.lastWordEnd {
+			continue
+		}
+		s.words = append(s.words, Word{Word: strings.TrimSpace(word.Word), Start: start, End: end})
+		s.lastWordEnd = end
+	}
+	return s.segments[first:]
+}
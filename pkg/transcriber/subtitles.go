@@ -0,0 +1,93 @@
+package transcriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// SubtitleMode is how SubtitleVideo adds a transcript's subtitles to a video
+type SubtitleMode string
+
+const (
+	// SubtitlesBurn draws the subtitles into the picture, re-encoding the video, so every player
+	// shows them
+	SubtitlesBurn SubtitleMode = "burn"
+
+	// SubtitlesSoft adds them as a subtitle track that viewers can turn on and off, copying the
+	// video as it is
+	SubtitlesSoft SubtitleMode = "soft"
+)
+
+// ErrNoVideo is returned by SubtitleVideo for media without a video stream, such as an audio file
+// or one whose only picture is cover art
+var ErrNoVideo = errors.New("the media has no video stream to add subtitles to")
+
+// SubtitleVideo writes an MP4 copy of the video at inputPath to outputPath with segments as its
+// subtitles, burned in or as a soft track as mode says. Its audio is encoded as AAC, so the copy
+// plays anywhere. Burning in needs an FFmpeg built with libass and libx264, and decodes the video
+// with HWAccel when it is set, in software if that fails
+func SubtitleVideo(ctx context.Context, inputPath, outputPath string, segments []Segment, mode SubtitleMode) error {
+	if mode != SubtitlesBurn && mode != SubtitlesSoft {
+		return fmt.Errorf("unknown subtitle mode %q", mode)
+	}
+	info, err := ProbeMedia(ctx, inputPath)
+	if err != nil {
+		return fmt.Errorf("file could not be read as media: %v", err)
+	}
+	video := info.VideoStreams()
+	if len(video) == 0 {
+		return ErrNoVideo
+	}
+	if !haveExecutable(FFmpegPath) {
+		return fmt.Errorf("adding subtitles to a video needs ffmpeg (%s), which isn't installed", FFmpegPath)
+	}
+
+	// The subtitles are read from an SRT file next to the output, removed once ffmpeg is done
+	subtitlesPath := outputPath + ".srt"
+	if err := os.WriteFile(subtitlesPath, []byte(RenderSRT(segments)), 0o600); err != nil {
+		return err
+	}
+	defer os.Remove(subtitlesPath)
+
+	input, stop, err := mediaInput(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	args := []string{"-i", input}
+	if mode == SubtitlesSoft {
+		args = append(args, "-i", subtitlesPath)
+	}
+	args = append(args,
+		"-map", fmt.Sprintf("0:%d", video[0].Index),
+		"-map", "0:a?",
+	)
+	if mode == SubtitlesBurn {
+		args = append(args,
+			"-vf", "subtitles=filename="+escapeFilterValue(subtitlesPath),
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p",
+		)
+	} else {
+		args = append(args, "-map", "1:0", "-c:v", "copy", "-c:s", "mov_text")
+	}
+	args = append(args, "-c:a", "aac", "-movflags", "+faststart", "-f", "mp4", outputPath)
+
+	// Only burning in decodes the video; a soft track copies it
+	var accel []string
+	if mode == SubtitlesBurn {
+		accel = hwAccelArgs()
+	}
+	err = runCommand(ctx, "ffmpeg subtitles", exec.CommandContext(ctx, FFmpegPath, append(accel, args...)...))
+	if err != nil && accel != nil && ctx.Err() == nil {
+		// A device that opened at startup can still fail on a codec or profile it doesn't support
+		err = runCommand(ctx, "ffmpeg subtitles", exec.CommandContext(ctx, FFmpegPath, args...))
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+	return nil
+}
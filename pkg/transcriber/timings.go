@@ -0,0 +1,113 @@
+package transcriber
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Timings breaks down where a transcription's time went, so slowness can be pinned on local
+// ffmpeg work or on the provider. Stages that run once per channel are summed over the channels
+type Timings struct {
+	Validate   time.Duration
+	Preprocess time.Duration
+	Hash       time.Duration
+	Analyze    time.Duration
+	Chunk      time.Duration
+	Transcribe time.Duration
+
+	// Stitch is the time spent merging the chunks' transcripts into one
+	Stitch time.Duration
+
+	// ChunkLatency is the spread of how long the provider took to answer each chunk, retries
+	// included. Chunks restored from a checkpoint aren't counted
+	ChunkLatency LatencySummary
+}
+
+// LatencySummary is the spread of a set of request durations
+type LatencySummary struct {
+	Count int
+	Min   time.Duration
+	Avg   time.Duration
+	Max   time.Duration
+}
+
+// timingRecorder collects the Timings of one transcription as its stages and chunks finish
+type timingRecorder struct {
+	mu         sync.Mutex
+	timings    Timings
+	chunkTotal time.Duration
+}
+
+// timingRecorderKey is the context key of the timingRecorder of a transcription
+type timingRecorderKey struct{}
+
+// withTimingRecorder makes the stages run with ctx record their timings in the returned recorder
+func withTimingRecorder(ctx context.Context) (context.Context, *timingRecorder) {
+	recorder := &timingRecorder{}
+	return context.WithValue(ctx, timingRecorderKey{}, recorder), recorder
+}
+
+// timingRecorderFrom returns the timingRecorder set on ctx, or nil when nothing records timings.
+// A nil recorder ignores what it is given
+func timingRecorderFrom(ctx context.Context) *timingRecorder {
+	recorder, _ := ctx.Value(timingRecorderKey{}).(*timingRecorder)
+	return recorder
+}
+
+// stage adds the duration of a run of stage
+func (r *timingRecorder) stage(stage Stage, duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch stage {
+	case StageValidate:
+		r.timings.Validate += duration
+	case StagePreprocess:
+		r.timings.Preprocess += duration
+	case StageHash:
+		r.timings.Hash += duration
+	case StageAnalyze:
+		r.timings.Analyze += duration
+	case StageChunk:
+		r.timings.Chunk += duration
+	case StageTranscribe:
+		r.timings.Transcribe += duration
+	}
+}
+
+// stitch adds time spent merging transcripts
+func (r *timingRecorder) stitch(duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings.Stitch += duration
+}
+
+// chunk records how long the provider took to answer a chunk
+func (r *timingRecorder) chunk(duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latency := &r.timings.ChunkLatency
+	if latency.Count == 0 || duration < latency.Min {
+		latency.Min = duration
+	}
+	latency.Max = max(latency.Max, duration)
+	latency.Count++
+	r.chunkTotal += duration
+	latency.Avg = r.chunkTotal / time.Duration(latency.Count)
+}
+
+// result returns the timings recorded so far
+func (r *timingRecorder) result() Timings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timings
+}
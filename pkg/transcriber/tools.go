@@ -0,0 +1,91 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// FFmpegPath and FFprobePath are the executables the pipeline runs. A name without a slash is
+// looked up in PATH. Set them before the first Transcriber is used
+var (
+	FFmpegPath  = "ffmpeg"
+	FFprobePath = "ffprobe"
+)
+
+// ffmpegRequirements lists what the pipeline asks of FFmpeg, by the -filters, -encoders,
+// -decoders, and -muxers listing each name appears in. Decoders are the common audio codecs;
+// rarer ones only fail the uploads that use them
+var ffmpegRequirements = map[string][]string{
+	"filters":  {"pan", "afftdn", "loudnorm", "silencedetect", "astats", "asplit", "atrim", "asetpts", "concat", "atempo"},
+	"encoders": {"flac", "pcm_s16le"},
+	"decoders": {"mp3", "aac", "flac", "opus", "vorbis", "pcm_s16le"},
+	"muxers":   {"flac", "wav", "segment", "null"},
+}
+
+// CheckFFmpeg verifies that FFmpegPath and FFprobePath run and that FFmpeg has every filter,
+// codec, and muxer the pipeline needs, so a broken install is found at startup instead of by the
+// first job. It returns FFmpeg's version line
+func CheckFFmpeg(ctx context.Context) (string, error) {
+	if _, err := toolOutput(ctx, FFprobePath, "-version"); err != nil {
+		return "", fmt.Errorf("ffprobe (%s) can't be run: %w", FFprobePath, err)
+	}
+	version, err := toolOutput(ctx, FFmpegPath, "-hide_banner", "-version")
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg (%s) can't be run: %w", FFmpegPath, err)
+	}
+	version, _, _ = strings.Cut(version, "\n")
+
+	var missing []string
+	for _, listing := range []string{"filters", "encoders", "decoders", "muxers"} {
+		output, err := toolOutput(ctx, FFmpegPath, "-hide_banner", "-"+listing)
+		if err != nil {
+			return "", fmt.Errorf("ffmpeg (%s) can't list its %s: %w", FFmpegPath, listing, err)
+		}
+		available := listedNames(output)
+		var absent []string
+		for _, name := range ffmpegRequirements[listing] {
+			if !available[name] {
+				absent = append(absent, name)
+			}
+		}
+		if len(absent) > 0 {
+			missing = append(missing, fmt.Sprintf("%s %s", listing, strings.Join(absent, ", ")))
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("ffmpeg (%s, %s) is missing %s", FFmpegPath, version, strings.Join(missing, "; "))
+	}
+	return version, nil
+}
+
+// toolOutput runs an executable and returns its standard output, with the last line it wrote to
+// standard error in the error when it fails
+func toolOutput(ctx context.Context, path string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := runCommand(ctx, "check "+path, cmd); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// listedNames collects the names from an ffmpeg -filters, -encoders, -decoders, or -muxers
+// listing, where each entry is a column of flags followed by the name
+func listedNames(listing string) map[string]bool {
+	names := map[string]bool{}
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// Muxers may list several comma-separated names for one entry
+		for _, name := range strings.Split(fields[1], ",") {
+			names[name] = true
+		}
+	}
+	return names
+}
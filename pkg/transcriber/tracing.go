@@ -0,0 +1,77 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates spans for the pipeline from the global OpenTelemetry tracer provider,
+// so tracing is a no-op until the application installs one
+var tracer = otel.Tracer("audio-transcriber/pkg/transcriber")
+
+// endSpan marks span as failed when err is set, then ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// runCommand runs an ffmpeg or ffprobe invocation inside its own span. Unless the caller collects
+// standard error itself, the last thing the command wrote there is added to its error, since an
+// exit status alone says nothing about what went wrong
+func runCommand(ctx context.Context, spanName string, cmd *exec.Cmd) error {
+	_, span := tracer.Start(ctx, spanName, trace.WithAttributes(
+		attribute.StringSlice("process.command_args", cmd.Args),
+	))
+	var stderr *tailBuffer
+	if cmd.Stderr == nil {
+		stderr = &tailBuffer{}
+		cmd.Stderr = stderr
+	}
+	err := cmd.Run()
+	if err != nil && stderr != nil {
+		if line := stderr.lastLine(); line != "" {
+			err = fmt.Errorf("%w: %s", err, line)
+		}
+	}
+	endSpan(span, err)
+	return err
+}
+
+// tailBufferSize is how much of a command's standard error runCommand keeps
+const tailBufferSize = 4096
+
+// tailBuffer keeps the last tailBufferSize bytes written to it, so a long ffmpeg run's progress
+// output doesn't pile up in memory
+type tailBuffer struct {
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > tailBufferSize {
+		b.data = append(b.data[:0], b.data[len(b.data)-tailBufferSize:]...)
+	}
+	return len(p), nil
+}
+
+// lastLine returns the last non-empty line written, treating ffmpeg's carriage-return progress
+// updates as lines of their own
+func (b *tailBuffer) lastLine() string {
+	lines := strings.FieldsFunc(string(b.data), func(r rune) bool { return r == '\n' || r == '\r' })
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MergeTracks writes the first audio stream of each input, such as the separate recording of each
+// participant in a meeting, to output as one channel of a 16 kHz WAV, in order, so the channels
+// can be transcribed with SplitChannels. Each track starts offsets[i] into the result, and shorter
+// ones are padded with silence to the longest. Encrypted inputs are read with cipher, which is
+// nil when they are plaintext
+func MergeTracks(ctx context.Context, inputPaths []string, offsets []time.Duration, output io.Writer, cipher *FileCipher) error {
+	if len(inputPaths) < 2 || len(offsets) != len(inputPaths) {
+		return fmt.Errorf("merging tracks needs at least 2 inputs, each with an offset")
+	}
+	if !haveExecutable(FFmpegPath) {
+		return fmt.Errorf("merging tracks needs ffmpeg (%s), which isn't installed", FFmpegPath)
+	}
+	ctx = withFileCipher(ctx, cipher)
+
+	// apad makes every track endless, so the merge is cut where the last track ends instead of
+	// stopping with the shortest
+	var end float64
+	for i, inputPath := range inputPaths {
+		info, err := ProbeMedia(ctx, inputPath)
+		if err != nil {
+			return fmt.Errorf("track %d could not be read as media: %v", i+1, err)
+		}
+		duration, err := strconv.ParseFloat(info.Duration, 64)
+		if err != nil {
+			return fmt.Errorf("track %d has no duration", i+1)
+		}
+		end = max(end, offsets[i].Seconds()+duration)
+	}
+
+	var args, filters []string
+	var labels strings.Builder
+	for i, inputPath := range inputPaths {
+		input, stop, err := mediaInput(ctx, inputPath)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		args = append(args, "-i", input)
+		filters = append(filters, fmt.Sprintf("[%d:a:0]aformat=channel_layouts=mono,aresample=16000,adelay=%d,apad[t%d]",
+			i, offsets[i].Milliseconds(), i))
+		fmt.Fprintf(&labels, "[t%d]", i)
+	}
+	filters = append(filters, fmt.Sprintf("%samerge=inputs=%d,atrim=end=%f[out]", labels.String(), len(inputPaths), end))
+	args = append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[out]",
+		"-c:a", "pcm_s16le",
+		"-f", "wav", "pipe:1",
+	)
+	cmd := exec.CommandContext(ctx, FFmpegPath, args...)
+	cmd.Stdout = output
+	return runCommand(ctx, "ffmpeg merge tracks", cmd)
+}
@@ -0,0 +1,739 @@
+// Package transcriber turns audio and video files into text. Files are preprocessed with
+// ffmpeg, split into overlapping chunks, transcribed in parallel by an OpenAI-compatible
+// transcription API (Groq by default), and stitched back into a single timed transcript.
+//
+// ffmpeg and ffprobe must be on the PATH.
+package transcriber
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults used for any Options field left at its zero value
+const (
+	DefaultAPIURL              = "https://api.groq.com/openai/v1/audio/transcriptions"
+	DefaultModel               = "distil-whisper-large-v3-en"
+	DefaultLanguage            = "en"
+	DefaultChunkSeconds        = 120.0
+	DefaultOverlapSeconds      = 1.0
+	DefaultMaxConcurrentChunks = 5
+	DefaultRequestTimeout      = 30 * time.Second
+	DefaultLiveSegmentSeconds  = 10.0
+	DefaultNonSpeechSeconds    = 20.0
+	DefaultSilenceSeconds      = 2.0
+
+	// DefaultMaxChunkBytes keeps chunks under the 25 MB upload limit of Groq and OpenAI
+	DefaultMaxChunkBytes = 24_000_000
+)
+
+// MaxPromptLength is the longest prompt, in characters, sent with each chunk. Whisper only reads
+// the last 224 tokens of a prompt, which is roughly this many characters of English
+const MaxPromptLength = 896
+
+// LanguageAuto, as a language, sends no language hint, so the model identifies the language of
+// each chunk itself
+const LanguageAuto = "auto"
+
+// CodeSwitchingChunkSeconds is the longest chunk sent with CodeSwitching. Whisper identifies the
+// language from a chunk's first 30 seconds, so longer chunks would hide switches within them
+const CodeSwitchingChunkSeconds = 30
+
+// MaxSpeed is the fastest TranscribeOptions.Speed. Beyond about three times real time Whisper
+// starts dropping words
+const MaxSpeed = 3.0
+
+// Options configures a Transcriber
+type Options struct {
+	// APIKey is sent as a bearer token to the transcription API, until SetAPIKey replaces it
+	APIKey string
+
+	// APIURL is the OpenAI-compatible audio transcription endpoint
+	APIURL string
+
+	// Model is the transcription model name
+	Model string
+
+	// Language is the ISO-639-1 language hint sent with every chunk, or LanguageAuto for none
+	Language string
+
+	// ChunkSeconds is the length of each chunk sent to the API
+	ChunkSeconds float64
+
+	// OverlapSeconds is how much consecutive chunks overlap, so words on a boundary aren't lost
+	OverlapSeconds float64
+
+	// MaxChunkBytes is the largest chunk file sent to the API. Chunks are planned shorter than
+	// ChunkSeconds when the audio's bitrate would take them over it, and any that still come out
+	// too large are split; defaults to DefaultMaxChunkBytes
+	MaxChunkBytes int64
+
+	// MaxConcurrentChunks limits how many chunks of a file are transcribed at once
+	MaxConcurrentChunks int
+
+	// RequestLimiter, when set, is shared with other Transcribers and their files to cap the API
+	// requests they have in flight and how fast they start, on top of MaxConcurrentChunks
+	RequestLimiter *RequestLimiter
+
+	// RNNoiseModel is an RNNoise model file used for Denoise instead of ffmpeg's FFT denoiser
+	RNNoiseModel string
+
+	// MaxDuration rejects media longer than this before any preprocessing; zero allows any length
+	MaxDuration time.Duration
+
+	// Vocabulary lists domain terms, product names, and acronyms sent in every chunk's prompt
+	// to bias the model toward spelling them correctly
+	Vocabulary []string
+
+	// PreprocessTimeout bounds each ffmpeg preprocessing run; zero means no limit
+	PreprocessTimeout time.Duration
+
+	// ChunkingTimeout bounds analyzing the preprocessed audio and, separately, splitting it into
+	// chunks; zero means no limit
+	ChunkingTimeout time.Duration
+
+	// RequestTimeout bounds each attempt at transcribing a chunk, from upload to response;
+	// defaults to DefaultRequestTimeout
+	RequestTimeout time.Duration
+
+	// LiveSegmentSeconds is how much of a live stream is cut off and transcribed at a time, which
+	// is roughly how far partial transcripts lag behind the stream
+	LiveSegmentSeconds float64
+
+	// NonSpeechSeconds is the shortest stretch of music or silence SkipNonSpeech leaves out
+	NonSpeechSeconds float64
+
+	// SilenceSeconds is the shortest silence RemoveSilence cuts out
+	SilenceSeconds float64
+
+	// HTTPClient is used for API requests; defaults to http.DefaultClient's settings
+	HTTPClient *http.Client
+
+	// Metrics, when set, receives stage timings and API request outcomes
+	Metrics Metrics
+}
+
+// Metrics receives measurements from the pipeline. Methods are called concurrently
+type Metrics interface {
+	// ObserveStage is called after each pipeline stage that ran, whether or not it succeeded
+	ObserveStage(stage Stage, duration time.Duration)
+
+	// ObserveChunkRequest is called after each API request with the HTTP status code,
+	// or 0 when no response was received
+	ObserveChunkRequest(duration time.Duration, statusCode int)
+}
+
+// Transcriber runs the preprocess/chunk/transcribe/stitch pipeline. It is safe for concurrent use
+type Transcriber struct {
+	opts Options
+
+	// limiter paces API requests from every file this Transcriber handles
+	limiter *rateLimiter
+
+	// inflight shares transcriptions in progress with files of identical audio
+	inflight inflightCalls
+
+	// apiKey is the bearer token requests are sent with
+	apiKey atomic.Pointer[string]
+}
+
+// New returns a Transcriber, filling in defaults for any unset options
+func New(opts Options) *Transcriber {
+	if opts.APIURL == "" {
+		opts.APIURL = DefaultAPIURL
+	}
+	if opts.Model == "" {
+		opts.Model = DefaultModel
+	}
+	if opts.Language == "" {
+		opts.Language = DefaultLanguage
+	}
+	if opts.ChunkSeconds <= 0 {
+		opts.ChunkSeconds = DefaultChunkSeconds
+	}
+	if opts.OverlapSeconds < 0 || opts.OverlapSeconds >= opts.ChunkSeconds {
+		opts.OverlapSeconds = DefaultOverlapSeconds
+	}
+	if opts.MaxChunkBytes <= 0 {
+		opts.MaxChunkBytes = DefaultMaxChunkBytes
+	}
+	if opts.MaxConcurrentChunks <= 0 {
+		opts.MaxConcurrentChunks = DefaultMaxConcurrentChunks
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = DefaultRequestTimeout
+	}
+	if opts.LiveSegmentSeconds <= 0 {
+		opts.LiveSegmentSeconds = DefaultLiveSegmentSeconds
+	}
+	if opts.NonSpeechSeconds <= 0 {
+		opts.NonSpeechSeconds = DefaultNonSpeechSeconds
+	}
+	if opts.SilenceSeconds <= 0 {
+		opts.SilenceSeconds = DefaultSilenceSeconds
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{}
+	}
+	t := &Transcriber{opts: opts, limiter: &rateLimiter{lowQuota: opts.MaxConcurrentChunks}}
+	t.apiKey.Store(&opts.APIKey)
+	return t
+}
+
+// SetAPIKey replaces the API key for requests sent from now on, such as when the key is rotated.
+// Requests already in flight finish with the old one
+func (t *Transcriber) SetAPIKey(key string) {
+	t.apiKey.Store(&key)
+}
+
+// checkDuration enforces MaxDuration on the part of the media opts transcribes. Media whose
+// container doesn't report a duration is let through
+func (t *Transcriber) checkDuration(info MediaInfo, opts TranscribeOptions) error {
+	if t.opts.MaxDuration <= 0 {
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(info.Duration, 64)
+	if err != nil {
+		return nil
+	}
+	if opts.End > 0 {
+		seconds = min(seconds, opts.End)
+	}
+	seconds -= opts.Start
+	duration := time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	if duration > t.opts.MaxDuration {
+		return &DurationLimitError{Duration: duration, Limit: t.opts.MaxDuration}
+	}
+	return nil
+}
+
+// Model returns the transcription model this Transcriber uses by default
+func (t *Transcriber) Model() string {
+	return t.opts.Model
+}
+
+// model returns the transcription model to use for a file
+func (t *Transcriber) model(opts TranscribeOptions) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return t.opts.Model
+}
+
+// observeStage logs, reports, and records in the transcription's Timings how long a stage took
+// since start
+func (t *Transcriber) observeStage(ctx context.Context, logger *slog.Logger, stage Stage, start time.Time) {
+	duration := time.Since(start)
+	logger.Info("Stage finished", "stage", stage, "duration", duration)
+	timingRecorderFrom(ctx).stage(stage, duration)
+	if t.opts.Metrics != nil {
+		t.opts.Metrics.ObserveStage(stage, duration)
+	}
+}
+
+// TranscribeOptions holds the per-file settings for Transcribe
+type TranscribeOptions struct {
+	// AudioTrack is the position of the audio stream to use among the file's audio streams
+	AudioTrack string
+
+	// AudioLanguage selects the audio stream by its language tag when AudioTrack is empty
+	AudioLanguage string
+
+	// Normalize applies EBU R128 loudness normalization while preprocessing, which helps quiet recordings
+	Normalize bool
+
+	// Denoise removes constant background noise (hum, traffic) while preprocessing
+	Denoise bool
+
+	// SplitChannels transcribes the channels of a recording separately, such as the agent and
+	// customer sides of a call, and interleaves them by time with each segment's Speaker set from
+	// ChannelLabels. Cache is not consulted, and OnSegments is called once with every segment when
+	// every channel is done
+	SplitChannels bool
+
+	// ChannelLabels names each channel in order for SplitChannels, and there must be one for each
+	// channel; DefaultChannelLabels, for the left and right of a stereo stream, when empty
+	ChannelLabels []string
+
+	// AudioFilters is an extra ffmpeg filter chain applied while preprocessing, such as
+	// "highpass=f=100,dynaudnorm". Only filters allowed by ValidateAudioFilters may be used
+	AudioFilters string
+
+	// Model overrides Options.Model for this file
+	Model string
+
+	// Temperature is the sampling temperature, from 0 to 1. Zero, the default, gives the most
+	// repeatable transcripts; above zero Cache is not consulted
+	Temperature float64
+
+	// Prompt is passed to the model with every chunk, after Options.Vocabulary, to bias it toward
+	// the caller's terms and style. At most MaxPromptLength characters. Cache is not consulted
+	// when it is set, since a different prompt can produce a different transcript
+	Prompt string
+
+	// WordTimestamps asks the API for the timing of every word, returned in Result.Words. Cache
+	// is not consulted, since cached transcripts don't have them
+	WordTimestamps bool
+
+	// SkipNonSpeech leaves stretches of music and silence of at least Options.NonSpeechSeconds,
+	// such as a podcast's intro and outro, out of the chunks sent to the API, so the model isn't
+	// asked to make words of them and they aren't paid for. They are returned in Result.Skipped.
+	// Cache is not consulted, and it doesn't apply to SplitChannels
+	SkipNonSpeech bool
+
+	// RemoveSilence cuts silences of at least Options.SilenceSeconds out of the audio before it
+	// is chunked, so sparse recordings such as dictation send far less audio to the API. Returned
+	// times are put back on the recording's timeline, and the seconds cut are returned in
+	// Result.SilenceRemoved
+	RemoveSilence bool
+
+	// Speed, from 1 to MaxSpeed, plays the audio that much faster before it is chunked, so less
+	// of it is sent to the API, at the cost of some accuracy on fast or unclear speech. Returned
+	// times are put back in real time. Zero means 1, and it doesn't apply to live streams
+	Speed float64
+
+	// Start and End, in seconds, transcribe only that part of the recording, cut out before it is
+	// preprocessed. Returned times are still on the recording's timeline, and
+	// Result.DurationSeconds is the length of the part. End zero means the end of the recording,
+	// and Cache is not consulted when Start is set
+	Start float64
+	End   float64
+
+	// Language overrides Options.Language for this file: an ISO-639-1 code, or LanguageAuto to
+	// let the model identify it. Cache is not consulted when it is set
+	Language string
+
+	// CodeSwitching is for recordings that mix languages, such as a call in English and Spanish.
+	// The audio is sent in chunks of at most CodeSwitchingChunkSeconds without a language hint,
+	// whatever Language says, so the model identifies the language of each, and LanguageModels
+	// picks the model for it. Cache is not consulted when it is set
+	CodeSwitching bool
+
+	// LanguageModels, with CodeSwitching, are the models of this provider that transcribe chunks
+	// identified as a language, keyed by its ISO-639-1 code, such as an English-only model for
+	// "en". Those chunks are transcribed again by that model with the language as a hint, so they
+	// are sent twice; when that fails, the first transcript is kept
+	LanguageModels map[string]string
+
+	// Cache, when set, is consulted with the hash of the preprocessed audio before transcribing, and
+	// identical audio this Transcriber is already transcribing for another file is waited for
+	// rather than sent again
+	Cache Cache
+
+	// OnSegments, when set, receives stitched segments in timeline order as chunks finish
+	OnSegments func([]Segment)
+
+	// OnProgress, when set, receives the file's Progress once its audio is chunked and again as
+	// each chunk finishes. It isn't called for a cached result
+	OnProgress func(Progress)
+
+	// Cipher, when set, is what the file at inputPath was encrypted with, and every file the
+	// pipeline writes to workDir is encrypted with it too. Preprocessed audio is then WAV rather
+	// than FLAC, chunked in-process, and ffmpeg and ffprobe read their input from a loopback
+	// server that decrypts it
+	Cipher *FileCipher
+
+	// Checkpoint, when set, saves each chunk's transcript as it finishes and supplies the ones
+	// saved by an earlier, interrupted attempt at the same file instead of sending them again
+	Checkpoint Checkpoint
+
+	// Logger receives stage timings and chunk outcomes; defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+// Cache looks up earlier results for identical preprocessed audio
+type Cache interface {
+	// Lookup returns a previous result for the audio hash and model, if there is one
+	Lookup(audioHash, model string) (*Result, bool)
+}
+
+// Result is the output of a successful transcription
+type Result struct {
+	Transcription   string
+	Segments        []Segment
+	DurationSeconds float64
+
+	// Words are the recognized words with their timings, when WordTimestamps was set
+	Words []Word
+
+	// AudioHash is the hex-encoded SHA-256 of the preprocessed audio
+	AudioHash string
+
+	// Cached is true when the result came from the Cache, or from a concurrent transcription of
+	// identical audio, rather than the API
+	Cached bool
+
+	// Chunks is how many chunks were sent to the API; zero for a cached result
+	Chunks int
+
+	// AudioSeconds is how much audio was sent to the API: DurationSeconds, or twice that with
+	// SplitChannels, less any Skipped or SilenceRemoved, and zero for a cached result
+	AudioSeconds float64
+
+	// SilenceRemoved is how many seconds of silence RemoveSilence cut out, over every channel
+	// with SplitChannels
+	SilenceRemoved float64
+
+	// Skipped are the stretches without speech SkipNonSpeech left out of the chunks
+	Skipped []NonSpeech
+
+	// Model is the transcription model that produced the result
+	Model string
+
+	// Timings breaks down how long the stages of this transcription took
+	Timings Timings
+}
+
+// Transcribe validates, preprocesses, chunks, and transcribes the media file at inputPath.
+// Intermediate files are written to workDir, which the caller owns and cleans up. Chunks
+// that fail to transcribe are logged and left out of the result. If ctx is canceled, running
+// ffmpeg processes and API requests are stopped and ctx.Err() is returned
+func (t *Transcriber) Transcribe(ctx context.Context, inputPath, workDir string, opts TranscribeOptions) (*Result, error) {
+	ctx, span := tracer.Start(ctx, "Transcribe", trace.WithAttributes(
+		attribute.String("transcriber.model", t.model(opts)),
+	))
+	ctx, recorder := withTimingRecorder(ctx)
+	result, err := t.transcribe(ctx, inputPath, workDir, opts)
+	if result != nil {
+		result.Timings = recorder.result()
+		span.SetAttributes(
+			attribute.Bool("transcriber.cached", result.Cached),
+			attribute.Float64("transcriber.audio_duration_seconds", result.DurationSeconds),
+		)
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Transcriber) transcribe(ctx context.Context, inputPath, workDir string, opts TranscribeOptions) (*Result, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if err := validateOptions(opts); err != nil {
+		return nil, err
+	}
+	ctx = withFileCipher(ctx, opts.Cipher)
+
+	// Reject anything ffprobe can't make sense of before doing real work
+	start := time.Now()
+	mediaInfo, err := ValidateMedia(ctx, inputPath)
+	if err == nil {
+		err = checkTimeRange(mediaInfo, opts.Start)
+	}
+	if err == nil {
+		err = t.checkDuration(mediaInfo, opts)
+	}
+	t.observeStage(ctx, logger, StageValidate, start)
+	if err != nil {
+		return nil, stageError(ctx, StageValidate, err)
+	}
+
+	// Pick which audio stream to transcribe (video files often carry several)
+	audioStream, err := SelectAudioStream(mediaInfo, opts.AudioTrack, opts.AudioLanguage)
+	if err != nil {
+		return nil, &StageError{Stage: StageSelectStream, Err: err}
+	}
+
+	filters := audioFilters{
+		Denoise:      opts.Denoise,
+		Normalize:    opts.Normalize,
+		Custom:       opts.AudioFilters,
+		RNNoiseModel: t.opts.RNNoiseModel,
+		Speed:        opts.Speed,
+		Start:        opts.Start,
+		End:          opts.End,
+	}
+	if opts.SplitChannels {
+		return t.transcribeChannels(ctx, logger, inputPath, workDir, audioStream, filters, opts)
+	}
+
+	// Preprocess audio file, unless it's already 16 kHz mono and only needs to be put in place
+	preprocessedPath := filepath.Join(workDir, "preprocessed.flac")
+	start = time.Now()
+	err = timedStage(ctx, StagePreprocess, t.opts.PreprocessTimeout, func(ctx context.Context) error {
+		if filters.chain() == "" && !filters.trimmed() && isPreprocessed(ctx, mediaInfo, audioStream) {
+			logger.Info("Audio is already 16 kHz mono, skipping the transcode", "format", mediaInfo.FormatName)
+			return usePreprocessed(ctx, inputPath, preprocessedPath)
+		}
+		return preprocessAudioFile(ctx, inputPath, preprocessedPath, audioStream.Index, filters)
+	})
+	t.observeStage(ctx, logger, StagePreprocess, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Identical preprocessed audio always produces the same transcript, so reuse it when we can
+	start = time.Now()
+	audioHash, err := hashFile(ctx, preprocessedPath)
+	t.observeStage(ctx, logger, StageHash, start)
+	if err != nil {
+		return nil, &StageError{Stage: StageHash, Err: err}
+	}
+	if opts.Cache == nil || opts.Prompt != "" || opts.Temperature != 0 || opts.WordTimestamps || opts.SkipNonSpeech || opts.Start > 0 || opts.Language != "" || opts.CodeSwitching {
+		return t.transcribeHashed(ctx, logger, preprocessedPath, workDir, audioHash, opts)
+	}
+	if cached, ok := opts.Cache.Lookup(audioHash, t.model(opts)); ok {
+		logger.Info("Reusing cached transcript", "audio_hash", audioHash)
+		return t.reuseResult(cached, audioHash, opts), nil
+	}
+
+	// Identical audio may already be on its way to the API for another file, in which case its
+	// result is shared rather than paid for twice. If that transcription fails, this file is sent
+	// after all
+	key := inflightKey{audioHash: audioHash, model: t.model(opts)}
+	for {
+		call, leader := t.inflight.join(key)
+		if leader {
+			result, err := t.transcribeHashed(ctx, logger, preprocessedPath, workDir, audioHash, opts)
+			t.inflight.finish(key, call, result, err)
+			return result, err
+		}
+
+		logger.Info("Waiting for identical audio already being transcribed", "audio_hash", audioHash)
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err == nil {
+			logger.Info("Reusing transcript of identical audio", "audio_hash", audioHash)
+			return t.reuseResult(call.result, audioHash, opts), nil
+		}
+	}
+}
+
+// transcribeHashed sends preprocessed audio with the given hash to the API
+func (t *Transcriber) transcribeHashed(ctx context.Context, logger *slog.Logger, preprocessedPath, workDir, audioHash string, opts TranscribeOptions) (*Result, error) {
+	request := t.chunkRequest(opts)
+	checkpoint := t.openCheckpoint(opts.Checkpoint, logger, audioHash, request)
+	result, err := t.transcribeAudio(ctx, logger, preprocessedPath, workDir, request, checkpoint, opts.OnSegments, progressReporter{onProgress: opts.OnProgress})
+	if err != nil {
+		return nil, err
+	}
+	result.AudioHash = audioHash
+	return result, nil
+}
+
+// reuseResult returns the transcript of identical audio as this file's result, without any API
+// usage, passing its segments to OnSegments
+func (t *Transcriber) reuseResult(reused *Result, audioHash string, opts TranscribeOptions) *Result {
+	segments := slices.Clone(reused.Segments)
+	if opts.OnSegments != nil && len(segments) > 0 {
+		opts.OnSegments(segments)
+	}
+	return &Result{
+		Transcription:   reused.Transcription,
+		Segments:        segments,
+		DurationSeconds: reused.DurationSeconds,
+		AudioHash:       audioHash,
+		Cached:          true,
+		Model:           t.model(opts),
+	}
+}
+
+// validateOptions checks the caller-supplied settings of a file before any work is done
+func validateOptions(opts TranscribeOptions) error {
+	if err := ValidateAudioFilters(opts.AudioFilters); err != nil {
+		return &StageError{Stage: StageValidate, Err: fmt.Errorf("invalid audio filters: %w", err)}
+	}
+	if err := ValidatePrompt(opts.Prompt); err != nil {
+		return &StageError{Stage: StageValidate, Err: err}
+	}
+	if err := ValidateTemperature(opts.Temperature); err != nil {
+		return &StageError{Stage: StageValidate, Err: err}
+	}
+	if err := ValidateSpeed(opts.Speed); err != nil {
+		return &StageError{Stage: StageValidate, Err: err}
+	}
+	if err := ValidateTimeRange(opts.Start, opts.End); err != nil {
+		return &StageError{Stage: StageValidate, Err: err}
+	}
+	return nil
+}
+
+// transcribeAudio chunks preprocessed audio into workDir and transcribes the chunks in parallel,
+// sending each with the settings in request, and passing stitched segments to onSegments (when
+// set) in timeline order as they become available, and the progress to progress. Chunks saved in
+// checkpoint aren't sent again, and the others are saved to it as they finish
+func (t *Transcriber) transcribeAudio(ctx context.Context, logger *slog.Logger, preprocessedPath, workDir string, request chunkRequest, checkpoint *chunkCheckpoint, onSegments func([]Segment), progress progressReporter) (*Result, error) {
+	// Get audio chunk data
+	// Long silences are cut out before the audio is chunked, and times put back as they're stitched,
+	// then scaled back to real time when the audio was sped up
+	start := time.Now()
+	var audioData chunkData
+	var skipped []NonSpeech
+	var silences *timeline
+	err := timedStage(ctx, StageAnalyze, t.opts.ChunkingTimeout, func(ctx context.Context) (err error) {
+		if request.RemoveSilence {
+			keptPath := filepath.Join(workDir, "kept.flac")
+			if silences, err = removeSilence(ctx, preprocessedPath, keptPath, t.opts.SilenceSeconds); err != nil {
+				return err
+			}
+			if silences != nil {
+				preprocessedPath = keptPath
+			}
+		}
+		chunkSeconds, overlapSeconds := t.opts.ChunkSeconds, t.opts.OverlapSeconds
+		if request.DetectLanguage {
+			chunkSeconds = min(chunkSeconds, CodeSwitchingChunkSeconds)
+			overlapSeconds = min(overlapSeconds, chunkSeconds/2)
+		}
+		audioData, err = getAudioChunkData(ctx, preprocessedPath, chunkSeconds, overlapSeconds, t.opts.MaxChunkBytes)
+		if err == nil && request.SkipNonSpeech {
+			skipped, err = DetectNonSpeech(ctx, preprocessedPath, t.opts.NonSpeechSeconds)
+		}
+		return err
+	})
+	t.observeStage(ctx, logger, StageAnalyze, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunkify audio file
+	start = time.Now()
+	var chunks []audioChunk
+	err = timedStage(ctx, StageChunk, t.opts.ChunkingTimeout, func(ctx context.Context) (err error) {
+		chunks, err = chunkifyAudioFile(ctx, preprocessedPath, workDir, audioData, speechSpans(skipped, audioData.DurationMs/1000), t.opts.MaxChunkBytes)
+		return err
+	})
+	t.observeStage(ctx, logger, StageChunk, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use a WaitGroup to track when all goroutines are done
+	start = time.Now()
+	var wg sync.WaitGroup
+
+	// Use a buffered channel as a semaphore to limit concurrency
+	semaphore := make(chan struct{}, t.opts.MaxConcurrentChunks)
+
+	// Create a mutex to protect concurrent writes to the results slice
+	var mutex sync.Mutex
+	transcriptionResults := make([]*chunkTranscription, len(chunks))
+
+	// Chunks finish in any order, but segments are stitched (and streamed) strictly in order
+	chunkDone := make([]bool, len(chunks))
+	nextChunk := 0
+	stitcher := &segmentStitcher{timeline: silences, speed: request.Speed, offset: request.Start,
+		tagLanguage: request.DetectLanguage || cmp.Or(request.Language, t.opts.Language) == LanguageAuto}
+	tracker := progress.track(chunks, audioData.DurationMs/1000, t.opts.MaxConcurrentChunks)
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk audioChunk) {
+			defer wg.Done()
+
+			transcription := checkpoint.load(i)
+			var latency time.Duration
+			if transcription == nil {
+				// Acquire a token from the semaphore, giving up if the file is abandoned while waiting
+				select {
+				case semaphore <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				chunkCtx, span := tracer.Start(ctx, "transcribe chunk", trace.WithAttributes(
+					attribute.Int("transcriber.chunk.index", i),
+					attribute.Float64("transcriber.chunk.start_seconds", chunk.StartSec),
+				))
+				chunkStart := time.Now()
+				var err error
+				transcription, err = t.transcribeChunk(chunkCtx, chunk.Path, request)
+				if err == nil && len(request.LanguageModels) > 0 {
+					transcription = t.routeChunk(chunkCtx, logger, i, chunk.Path, request, transcription)
+				}
+				endSpan(span, err)
+				<-semaphore
+				latency = time.Since(chunkStart)
+				timingRecorderFrom(ctx).chunk(latency)
+
+				if err != nil {
+					logger.Error("Chunk transcription failed", "chunk", i, "duration", time.Since(chunkStart), "error", err)
+					transcription = nil
+				} else {
+					logger.Info("Chunk transcribed", "chunk", i, "duration", time.Since(chunkStart))
+					checkpoint.save(i, transcription)
+				}
+			}
+
+			mutex.Lock()
+			transcriptionResults[i] = transcription
+			chunkDone[i] = true
+			tracker.chunkDone(i, latency)
+			for nextChunk < len(chunks) && chunkDone[nextChunk] {
+				stitchStart := time.Now()
+				added := stitcher.add(chunks[nextChunk], transcriptionResults[nextChunk])
+				timingRecorderFrom(ctx).stitch(time.Since(stitchStart))
+				if onSegments != nil && len(added) > 0 {
+					onSegments(added)
+				}
+				nextChunk++
+			}
+			mutex.Unlock()
+		}(i, chunk)
+	}
+
+	// Wait for all transcription tasks to complete
+	wg.Wait()
+	t.observeStage(ctx, logger, StageTranscribe, start)
+
+	// Chunks aborted by cancellation would otherwise look like a short transcript
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Filter out empty (failed) transcriptions and combine
+	stitchStart := time.Now()
+	var validTranscriptions []string
+	for _, result := range transcriptionResults {
+		if result != nil && result.Text != "" {
+			validTranscriptions = append(validTranscriptions, result.Text)
+		}
+	}
+	transcription := strings.Join(validTranscriptions, "")
+	timingRecorderFrom(ctx).stitch(time.Since(stitchStart))
+
+	audioSeconds := audioData.DurationMs / 1000
+	for _, stretch := range skipped {
+		audioSeconds -= stretch.End - stretch.Start
+	}
+	if len(skipped) > 0 {
+		logger.Info("Skipped stretches without speech", "stretches", len(skipped), "seconds", audioData.DurationMs/1000-audioSeconds)
+	}
+	duration := audioData.DurationMs / 1000
+	if silences != nil {
+		logger.Info("Removed silence", "seconds", silences.removed())
+		duration = silences.duration
+	}
+	for i := range skipped {
+		skipped[i].Start, skipped[i].End = stitcher.realTime(skipped[i].Start), stitcher.realTime(skipped[i].End)
+	}
+	return &Result{
+		Transcription:   transcription,
+		Segments:        stitcher.segments,
+		Words:           stitcher.words,
+		DurationSeconds: duration * stitcher.scale(),
+		Chunks:          len(chunks),
+		AudioSeconds:    audioSeconds,
+		Skipped:         skipped,
+		SilenceRemoved:  silences.removed() * stitcher.scale(),
+		Model:           request.Model,
+	}, nil
+}
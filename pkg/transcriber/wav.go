@@ -0,0 +1,456 @@
+package transcriber
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+)
+
+// Without ffmpeg and ffprobe, WAV files are still decoded, resampled, and chunked in-process, so
+// the pipeline works where FFmpeg can't be installed. Other formats, filters, and live streams
+// need FFmpeg
+
+// nativeSampleRate is the rate in-process preprocessing resamples to, as ffmpeg's does
+const nativeSampleRate = 16000
+
+// resampleZeroCrossings is how many zero crossings of the windowed-sinc low-pass filter are
+// kept on each side; more is sharper and slower
+const resampleZeroCrossings = 8
+
+// WAV format tags for integer PCM, floating-point PCM, and WAVE_FORMAT_EXTENSIBLE
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// haveExecutable reports whether path names a program that can be run
+func haveExecutable(path string) bool {
+	_, err := exec.LookPath(path)
+	return err == nil
+}
+
+// useNativeAudio reports whether a file must be handled in-process because tool isn't available
+// and the file is a WAV file, which it can be
+func useNativeAudio(ctx context.Context, tool, filePath string) bool {
+	return !haveExecutable(tool) && isWAVFile(ctx, filePath)
+}
+
+// isWAVFile reports whether a file starts with a RIFF/WAVE header
+func isWAVFile(ctx context.Context, filePath string) bool {
+	file, err := openMedia(ctx, filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	var header [12]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return false
+	}
+	return isWAVHeader(header[:])
+}
+
+// isWAVHeader reports whether the first 12 bytes of a file are a RIFF/WAVE header
+func isWAVHeader(header []byte) bool {
+	return len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE"
+}
+
+// wavFormat describes the samples in a WAV file and where they are
+type wavFormat struct {
+	Encoding      uint16
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+	BlockAlign    int
+
+	DataOffset int64
+	DataSize   int64
+}
+
+// frames returns the number of sample frames in the data chunk
+func (f wavFormat) frames() int64 {
+	return f.DataSize / int64(f.BlockAlign)
+}
+
+// duration returns the length of the audio in seconds
+func (f wavFormat) duration() float64 {
+	return float64(f.frames()) / float64(f.SampleRate)
+}
+
+// codecName returns the name ffprobe gives the format's samples
+func (f wavFormat) codecName() string {
+	switch {
+	case f.Encoding == wavFormatFloat:
+		return fmt.Sprintf("pcm_f%dle", f.BitsPerSample)
+	case f.BitsPerSample == 8:
+		return "pcm_u8"
+	default:
+		return fmt.Sprintf("pcm_s%dle", f.BitsPerSample)
+	}
+}
+
+// sample returns one channel's sample from a frame, scaled to -1..1
+func (f wavFormat) sample(frame []byte, channel int) float64 {
+	b := frame[channel*f.BitsPerSample/8:]
+	switch {
+	case f.Encoding == wavFormatFloat && f.BitsPerSample == 64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case f.Encoding == wavFormatFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case f.BitsPerSample == 8:
+		return (float64(b[0]) - 128) / 128
+	case f.BitsPerSample == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case f.BitsPerSample == 24:
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
+
+// readWAVFormat reads the fmt chunk of a WAV file and finds its data chunk
+func readWAVFormat(file mediaFile) (wavFormat, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil || !isWAVHeader(header[:]) {
+		return wavFormat{}, errors.New("not a WAV file")
+	}
+
+	var format wavFormat
+	haveFormat := false
+	offset := int64(len(header))
+	for {
+		var chunk [8]byte
+		if _, err := file.ReadAt(chunk[:], offset); err != nil {
+			return wavFormat{}, errors.New("WAV file has no data chunk")
+		}
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		offset += int64(len(chunk))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return wavFormat{}, errors.New("WAV fmt chunk is too short")
+			}
+			fmtChunk := make([]byte, min(size, 40))
+			if _, err := file.ReadAt(fmtChunk, offset); err != nil {
+				return wavFormat{}, fmt.Errorf("reading WAV fmt chunk: %w", err)
+			}
+			format.Encoding = binary.LittleEndian.Uint16(fmtChunk[0:2])
+			format.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			format.BlockAlign = int(binary.LittleEndian.Uint16(fmtChunk[12:14]))
+			format.BitsPerSample = int(binary.LittleEndian.Uint16(fmtChunk[14:16]))
+			if format.Encoding == wavFormatExtensible && len(fmtChunk) >= 26 {
+				// The real format tag starts the subformat GUID
+				format.Encoding = binary.LittleEndian.Uint16(fmtChunk[24:26])
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return wavFormat{}, errors.New("WAV data chunk comes before its fmt chunk")
+			}
+			format.DataOffset = offset
+			format.DataSize = size
+			// Streamed WAV files leave the size unset, and truncated ones overstate it
+			if size == 0 || size == math.MaxUint32 || offset+size > file.Size() {
+				format.DataSize = file.Size() - offset
+			}
+			return format, validateWAVFormat(format)
+		}
+		// Chunks are padded to an even length
+		offset += size + size%2
+	}
+}
+
+// validateWAVFormat rejects sample formats that can't be decoded
+func validateWAVFormat(format wavFormat) error {
+	switch {
+	case format.Encoding == wavFormatPCM && (format.BitsPerSample == 8 || format.BitsPerSample == 16 || format.BitsPerSample == 24 || format.BitsPerSample == 32):
+	case format.Encoding == wavFormatFloat && (format.BitsPerSample == 32 || format.BitsPerSample == 64):
+	default:
+		return fmt.Errorf("WAV encoding %#x with %d-bit samples can't be decoded without ffmpeg", format.Encoding, format.BitsPerSample)
+	}
+	if format.Channels < 1 || format.SampleRate < 1 || format.BlockAlign != format.Channels*format.BitsPerSample/8 {
+		return fmt.Errorf("WAV header is invalid: %d channel(s) at %d Hz with %d-byte frames", format.Channels, format.SampleRate, format.BlockAlign)
+	}
+	return nil
+}
+
+// openWAV opens a WAV file and reads its format
+func openWAV(ctx context.Context, filePath string) (mediaFile, wavFormat, error) {
+	file, err := openMedia(ctx, filePath)
+	if err != nil {
+		return nil, wavFormat{}, err
+	}
+	format, err := readWAVFormat(file)
+	if err != nil {
+		file.Close()
+		return nil, wavFormat{}, err
+	}
+	return file, format, nil
+}
+
+// probeWAV describes a WAV file the way ProbeMedia would
+func probeWAV(ctx context.Context, filePath string) (MediaInfo, error) {
+	file, format, err := openWAV(ctx, filePath)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	file.Close()
+
+	bitRate := format.SampleRate * format.BlockAlign * 8
+	return MediaInfo{
+		FormatName: "wav",
+		Duration:   fmt.Sprintf("%f", format.duration()),
+		BitRate:    bitRate,
+		Streams: []StreamInfo{{
+			Index:      0,
+			CodecType:  "audio",
+			CodecName:  format.codecName(),
+			Channels:   format.Channels,
+			SampleRate: format.SampleRate,
+			BitRate:    bitRate,
+		}},
+	}, nil
+}
+
+// preprocessWAV is preprocessAudioSample for a WAV file without ffmpeg: it mixes the channels
+// down to mono, or keeps only the 1-based channel when it is set, resamples to 16 kHz, and writes
+// 16-bit PCM WAV to outputPath, whatever its extension. It starts start seconds in, and stops
+// after seconds when that is set
+func preprocessWAV(ctx context.Context, inputPath, outputPath string, channel int, start, seconds float64) error {
+	file, format, err := openWAV(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if channel > format.Channels {
+		return fmt.Errorf("channel %d doesn't exist: the file has %d channel(s)", channel, format.Channels)
+	}
+	skipped := min(int64(start*float64(format.SampleRate)), format.frames())
+	if _, err := file.Seek(format.DataOffset+skipped*int64(format.BlockAlign), io.SeekStart); err != nil {
+		return err
+	}
+	input := bufio.NewReaderSize(io.LimitReader(file, format.DataSize-skipped*int64(format.BlockAlign)), 1<<16)
+	frame := make([]byte, format.BlockAlign)
+	next := func() (float64, bool) {
+		if _, err := io.ReadFull(input, frame); err != nil {
+			return 0, false
+		}
+		if channel > 0 {
+			return format.sample(frame, channel-1), true
+		}
+		var sum float64
+		for c := range format.Channels {
+			sum += format.sample(frame, c)
+		}
+		return sum / float64(format.Channels), true
+	}
+
+	total := (format.frames() - skipped) * nativeSampleRate / int64(format.SampleRate)
+	if seconds > 0 {
+		total = min(total, int64(seconds*nativeSampleRate))
+	}
+
+	out, err := createMedia(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := writeWAVHeader(out, wavFormatPCM, 1, nativeSampleRate, 16, total*2); err != nil {
+		return err
+	}
+	output := bufio.NewWriterSize(out, 1<<16)
+
+	resampler := newResampler(format.SampleRate, nativeSampleRate, next)
+	var sample [2]byte
+	for n := range total {
+		if n%nativeSampleRate == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		value := max(-1, min(1, resampler.output(n)))
+		binary.LittleEndian.PutUint16(sample[:], uint16(int16(math.Round(value*math.MaxInt16))))
+		if _, err := output.Write(sample[:]); err != nil {
+			return err
+		}
+	}
+	if err := output.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// resampler converts a stream of samples from one rate to another with a windowed-sinc filter,
+// which also low-passes the signal below the output's Nyquist frequency when downsampling
+type resampler struct {
+	next            func() (float64, bool)
+	inRate, outRate int64
+	cutoff          float64
+	half            int64
+
+	// An output sample falls one of phases fractions of the way between two input samples, and
+	// taps caches the filter for each phase, unless there are too many to keep
+	phases int64
+	taps   [][]float64
+
+	// buffer holds the input from sample base onward; ended is set once next runs out
+	buffer []float64
+	base   int64
+	ended  bool
+}
+
+// maxResamplePhases bounds the filter cache; rates with more phases compute each filter as needed
+const maxResamplePhases = 4096
+
+// resampleCutoff is how close to the output's Nyquist frequency the low-pass filter starts when
+// downsampling, leaving room for its transition band
+const resampleCutoff = 0.95
+
+func newResampler(inRate, outRate int, next func() (float64, bool)) *resampler {
+	cutoff := 1.0
+	if inRate > outRate {
+		cutoff = resampleCutoff * float64(outRate) / float64(inRate)
+	}
+	r := &resampler{
+		next:    next,
+		inRate:  int64(inRate),
+		outRate: int64(outRate),
+		cutoff:  cutoff,
+		half:    int64(math.Ceil(resampleZeroCrossings / cutoff)),
+		phases:  int64(outRate) / gcd(int64(inRate), int64(outRate)),
+	}
+	if r.phases <= maxResamplePhases {
+		r.taps = make([][]float64, r.phases)
+	}
+	return r
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// input returns input sample i, reading ahead as needed, or 0 outside the input
+func (r *resampler) input(i int64) float64 {
+	for !r.ended && i >= r.base+int64(len(r.buffer)) {
+		value, ok := r.next()
+		if !ok {
+			r.ended = true
+			break
+		}
+		r.buffer = append(r.buffer, value)
+	}
+	if i < r.base || i >= r.base+int64(len(r.buffer)) {
+		return 0
+	}
+	return r.buffer[i-r.base]
+}
+
+// filter returns the taps for the input samples around an output sample of the given phase
+func (r *resampler) filter(phase int64) []float64 {
+	if r.taps != nil && r.taps[phase] != nil {
+		return r.taps[phase]
+	}
+	fraction := float64(phase) / float64(r.phases)
+	taps := make([]float64, 2*r.half)
+	for k := range taps {
+		x := fraction - float64(int64(k)-r.half+1)
+		taps[k] = r.cutoff * sinc(r.cutoff*x) * blackman(x/float64(r.half))
+	}
+	if r.taps != nil {
+		r.taps[phase] = taps
+	}
+	return taps
+}
+
+// output returns output sample n. Samples must be asked for in order
+func (r *resampler) output(n int64) float64 {
+	position := n * r.inRate
+	center := position / r.outRate
+	phase := position % r.outRate * r.phases / r.outRate
+
+	// Input more than half a filter behind is never needed again
+	if drop := min(center-r.half-r.base, int64(len(r.buffer))); drop > 1<<14 {
+		r.buffer = append(r.buffer[:0], r.buffer[drop:]...)
+		r.base += drop
+	}
+
+	var sum float64
+	first := center - r.half + 1
+	for k, tap := range r.filter(phase) {
+		sum += r.input(first+int64(k)) * tap
+	}
+	return sum
+}
+
+// sinc is the normalized sinc function, sin(πx)/(πx)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window over -1..1, and zero outside it
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+// cutWAV cuts a chunk from a WAV file without ffmpeg: it copies duration seconds of
+// samples from startSeconds into a new WAV file of the same format
+func cutWAV(ctx context.Context, inputPath, outputPath string, startSeconds, duration float64) error {
+	file, format, err := openWAV(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	first := min(int64(startSeconds*float64(format.SampleRate)), format.frames())
+	frames := min(int64(duration*float64(format.SampleRate)), format.frames()-first)
+	size := frames * int64(format.BlockAlign)
+
+	out, err := createMedia(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := writeWAVHeader(out, format.Encoding, format.Channels, format.SampleRate, format.BitsPerSample, size); err != nil {
+		return err
+	}
+	data := io.NewSectionReader(file, format.DataOffset+first*int64(format.BlockAlign), size)
+	if _, err := io.Copy(out, data); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// writeWAVHeader writes a canonical 44-byte WAV header for dataSize bytes of samples
+func writeWAVHeader(w io.Writer, encoding uint16, channels, sampleRate, bitsPerSample int, dataSize int64) error {
+	blockAlign := channels * bitsPerSample / 8
+	header := make([]byte, 0, 44)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(36+dataSize))
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, encoding)
+	header = binary.LittleEndian.AppendUint16(header, uint16(channels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(bitsPerSample))
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(dataSize))
+	_, err := w.Write(header)
+	return err
+}
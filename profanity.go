@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// profanityFilter masks or removes profanity in what is returned to clients when they ask for it
+var profanityFilter = transcriber.NewProfanityFilter(transcriber.DefaultProfanityWords)
+
+// initProfanityFilter loads the wordlist at path, keeping the built-in list when path is empty
+func initProfanityFilter(path string) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	words, err := transcriber.ReadProfanityWords(file)
+	if err != nil {
+		return err
+	}
+	profanityFilter = transcriber.NewProfanityFilter(words)
+	return nil
+}
+
+// parseProfanityFilter validates a requested profanity_filter mode; empty means no filtering
+func parseProfanityFilter(value string) (transcriber.ProfanityMode, error) {
+	switch mode := transcriber.ProfanityMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "", transcriber.ProfanityMask, transcriber.ProfanityRemove:
+		return mode, nil
+	default:
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Unknown profanity_filter %q: expected mask or remove", value),
+		}
+	}
+}
+
+// filterResult returns a copy of result with profanity filtered from its transcript, segments, and
+// words. Stored transcripts are left as they are, so each request can choose its own filtering
+func filterResult(result *transcriber.Result, mode transcriber.ProfanityMode) *transcriber.Result {
+	if mode == "" {
+		return result
+	}
+	filtered := *result
+	filtered.Transcription = profanityFilter.Apply(result.Transcription, mode)
+	filtered.Segments = profanityFilter.ApplySegments(result.Segments, mode)
+	filtered.Words = profanityFilter.ApplyWords(result.Words, mode)
+	return &filtered
+}
+
+// filterJob returns a copy of job with profanity filtered from its transcript, segments, words,
+// summary, keywords, and chapter titles. Keywords that are nothing but profanity are dropped
+func filterJob(job *Job, mode transcriber.ProfanityMode) *Job {
+	if mode == "" {
+		return job
+	}
+	filtered := *job
+	filtered.Transcript = profanityFilter.Apply(job.Transcript, mode)
+	filtered.Segments = profanityFilter.ApplySegments(job.Segments, mode)
+	filtered.Words = profanityFilter.ApplyWords(job.Words, mode)
+	filtered.Summary = strings.TrimSpace(profanityFilter.Apply(job.Summary, mode))
+	filtered.Keywords = nil
+	for _, keyword := range job.Keywords {
+		keyword.Phrase = strings.TrimSpace(profanityFilter.Apply(keyword.Phrase, mode))
+		if keyword.Phrase != "" {
+			filtered.Keywords = append(filtered.Keywords, keyword)
+		}
+	}
+	filtered.Chapters = nil
+	for _, chapter := range job.Chapters {
+		chapter.Title = strings.TrimSpace(profanityFilter.Apply(chapter.Title, mode))
+		filtered.Chapters = append(filtered.Chapters, chapter)
+	}
+	if job.Translation != nil {
+		filtered.Translation = &Translation{
+			Language:   job.Translation.Language,
+			Transcript: profanityFilter.Apply(job.Translation.Transcript, mode),
+			Segments:   profanityFilter.ApplySegments(job.Translation.Segments, mode),
+		}
+	}
+	return &filtered
+}
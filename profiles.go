@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Profile is a named set of job options that requests select with profile, rather than passing
+// every option themselves. Options the request sets override the profile's
+type Profile struct {
+	Name     string                     `json:"name"`
+	Settings map[string]json.RawMessage `json:"settings"`
+
+	// settings is Settings as one JSON object, decoded underneath JSON requests, and fields is
+	// Settings as form values, filled in under multipart fields and upload metadata
+	settings []byte
+	fields   map[string]string
+}
+
+// ProfilesResponse lists the profiles requests may select
+type ProfilesResponse struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// profileOnlyFields are request fields that say what to transcribe or which profile to use, so a
+// profile can't set them
+var profileOnlyFields = []string{"url", "urls", "ingest", "profile"}
+
+// profilesByName holds the profiles in TRANSCRIBER_PROFILES_FILE
+var profilesByName map[string]*Profile
+
+// initProfiles loads the profiles in TRANSCRIBER_PROFILES_FILE, a JSON object of profile names
+// and their options, when it is set. Each profile is checked as a request would be, so a
+// misspelled or invalid option stops the server rather than failing every request that uses it
+func initProfiles(config Config) error {
+	if config.ProfilesFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(config.ProfilesFile)
+	if err != nil {
+		return err
+	}
+	var settings map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return errors.New("no profiles defined")
+	}
+
+	byName := map[string]*Profile{}
+	for name, options := range settings {
+		profile, err := newProfile(name, options)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		byName[name] = profile
+	}
+	profilesByName = byName
+	return nil
+}
+
+// newProfile checks a profile's options against URLTranscriptionRequest and builds its JSON and
+// form forms
+func newProfile(name string, options map[string]json.RawMessage) (*Profile, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("a profile has no name")
+	}
+	for _, field := range profileOnlyFields {
+		if _, ok := options[field]; ok {
+			return nil, fmt.Errorf("%s can't be set by a profile", field)
+		}
+	}
+	settings, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var request URLTranscriptionRequest
+	decoder := json.NewDecoder(bytes.NewReader(settings))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return nil, err
+	}
+	if _, err := urlJobOptions(nil, request); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{}
+	for field, value := range options {
+		fields[field] = formValue(value)
+	}
+	return &Profile{Name: name, Settings: options, settings: settings, fields: fields}, nil
+}
+
+// formValue renders a JSON option as the form field that sets it: strings as they are, lists
+// comma-separated, and numbers and booleans as written
+func formValue(value json.RawMessage) string {
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		return text
+	}
+	var list []string
+	if json.Unmarshal(value, &list) == nil {
+		return strings.Join(list, ",")
+	}
+	return string(value)
+}
+
+// findProfile returns the profile a request named, or a 400 when there is no such profile
+func findProfile(name string) (*Profile, error) {
+	if profile, ok := profilesByName[name]; ok {
+		return profile, nil
+	}
+	return nil, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Unknown profile %q", name)}
+}
+
+// profileFields returns a request's form fields or upload metadata with the options of the
+// profile they name filled in wherever the request didn't set them itself
+func profileFields(fields map[string]string) (map[string]string, error) {
+	if fields["profile"] == "" {
+		return fields, nil
+	}
+	profile, err := findProfile(fields["profile"])
+	if err != nil {
+		return nil, err
+	}
+	merged := maps.Clone(profile.fields)
+	maps.Copy(merged, fields)
+	return merged, nil
+}
+
+// decodeWithProfile decodes a JSON request body into request on top of the options of the
+// profile it names, so the fields the body sets override the profile's. An unknown profile is
+// left for urlJobOptions to report
+func decodeWithProfile(body io.Reader, request any) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return io.EOF
+	}
+	var named struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	if profile, ok := profilesByName[named.Profile]; ok {
+		if err := json.Unmarshal(profile.settings, request); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, request)
+}
+
+// listProfiles returns the configured profiles by name
+func listProfiles(c *gin.Context) {
+	profiles := make([]Profile, 0, len(profilesByName))
+	for _, name := range slices.Sorted(maps.Keys(profilesByName)) {
+		profiles = append(profiles, *profilesByName[name])
+	}
+	c.JSON(http.StatusOK, ProfilesResponse{Profiles: profiles})
+}
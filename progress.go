@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// progressPollInterval is how often a job's event stream checks the job store for progress
+const progressPollInterval = time.Second
+
+// JobProgress is how far a processing job's transcription has got, recorded once its audio is
+// chunked and again as each chunk finishes
+type JobProgress struct {
+	// Percent is the share of the audio transcribed, each chunk weighted by its length
+	Percent    float64 `json:"percent"`
+	ChunksDone int     `json:"chunks_done"`
+	Chunks     int     `json:"chunks"`
+
+	// ETASeconds estimates how long transcribing the rest takes, from the latency of recent chunk
+	// requests. It is left out until a chunk has been answered, and doesn't cover the summary,
+	// translation, and other work done once the transcript is ready
+	ETASeconds *float64  `json:"eta_seconds,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// jobProgressRecorder returns a callback that records a job's progress in the job store, so it
+// can be read from any instance
+func jobProgressRecorder(ctx context.Context, jobID string) func(transcriber.Progress) {
+	return func(progress transcriber.Progress) {
+		recorded := &JobProgress{
+			Percent:    math.Round(progress.Fraction*1000) / 10,
+			ChunksDone: progress.ChunksDone,
+			Chunks:     progress.Chunks,
+			UpdatedAt:  time.Now().UTC(),
+		}
+		if progress.ETA > 0 {
+			eta := math.Round(progress.ETA.Seconds())
+			recorded.ETASeconds = &eta
+		}
+		if err := jobStore.SaveJobProgress(jobID, recorded); err != nil {
+			loggerFrom(ctx).Warn("Unable to record job progress", "error", err)
+		}
+	}
+}
+
+// transcriptionEvents follows a job as server-sent events: a progress event with its JobProgress
+// whenever that changes, and a done event with the finished job, as GET /api/transcriptions/:id
+// returns it. The job is read from the job store, so it can run on any instance
+func transcriptionEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	job, err := getTenantJob(ctx, c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(ctx).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	auditJob(ctx, AuditTranscriptionRead, job, "format=events")
+
+	// Keep proxies from holding events back
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+	var sent time.Time
+	c.Stream(func(io.Writer) bool {
+		if job.Status != JobStatusProcessing {
+			c.SSEvent("done", linkedJob(ctx, job))
+			return false
+		}
+		if job.Progress != nil && !job.Progress.UpdatedAt.Equal(sent) {
+			c.SSEvent("progress", job.Progress)
+			sent = job.Progress.UpdatedAt
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false
+		}
+		if job, err = jobStore.GetJob(job.ID); err != nil {
+			loggerFrom(ctx).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+			c.SSEvent("error", ErrorResponse{Error: "Failed to load transcription"})
+			return false
+		}
+		return true
+	})
+}
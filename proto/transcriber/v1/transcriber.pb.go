@@ -0,0 +1,897 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: transcriber/v1/transcriber.proto
+
+package transcriberv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TranscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Source:
+	//
+	//	*TranscribeRequest_Audio
+	//	*TranscribeRequest_Url
+	Source isTranscribeRequest_Source `protobuf_oneof:"source"`
+	// Original filename, recorded with the job.
+	Filename string `protobuf:"bytes,3,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Zero-based position of the audio stream to use, counting only audio streams.
+	AudioTrack string `protobuf:"bytes,4,opt,name=audio_track,json=audioTrack,proto3" json:"audio_track,omitempty"`
+	// Language tag of the audio stream to use when audio_track is empty.
+	AudioLanguage string `protobuf:"bytes,5,opt,name=audio_language,json=audioLanguage,proto3" json:"audio_language,omitempty"`
+	// Skip the content-hash cache and always transcribe.
+	DisableCache bool `protobuf:"varint,6,opt,name=disable_cache,json=disableCache,proto3" json:"disable_cache,omitempty"`
+	// How soon the job gets a worker: "high", "normal" (the default), or "batch".
+	Priority string `protobuf:"bytes,7,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Apply EBU R128 loudness normalization before transcribing; helps quiet recordings.
+	Normalize bool `protobuf:"varint,8,opt,name=normalize,proto3" json:"normalize,omitempty"`
+	// Filter out constant background noise (hum, traffic) before transcribing.
+	Denoise bool `protobuf:"varint,9,opt,name=denoise,proto3" json:"denoise,omitempty"`
+	// Extra ffmpeg audio filter chain applied during preprocessing, e.g.
+	// "highpass=f=100,dynaudnorm". Only allowlisted filters are accepted.
+	AudioFilters string `protobuf:"bytes,10,opt,name=audio_filters,json=audioFilters,proto3" json:"audio_filters,omitempty"`
+	// Transcribe each channel of a recording, such as the two sides of a stereo
+	// call, separately and interleave them by time, labeling each segment's speaker.
+	SplitChannels bool `protobuf:"varint,11,opt,name=split_channels,json=splitChannels,proto3" json:"split_channels,omitempty"`
+	// Speaker labels for each channel in order; defaults to the server's
+	// configured labels ("Agent", "Customer").
+	ChannelLabels []string `protobuf:"bytes,12,rep,name=channel_labels,json=channelLabels,proto3" json:"channel_labels,omitempty"`
+	// Summarize the finished transcript with the server's configured chat model.
+	Summarize bool `protobuf:"varint,13,opt,name=summarize,proto3" json:"summarize,omitempty"`
+	// Extract the transcript's key phrases and when they are mentioned.
+	Keywords bool `protobuf:"varint,14,opt,name=keywords,proto3" json:"keywords,omitempty"`
+	// Mask emails, phone numbers, card numbers, and (when the server is
+	// configured to) names before the transcript is stored or returned.
+	Redact bool `protobuf:"varint,15,opt,name=redact,proto3" json:"redact,omitempty"`
+	// "mask" or "remove" profanity in the returned transcript; empty leaves it
+	// as is. The stored transcript is never filtered.
+	ProfanityFilter string `protobuf:"bytes,16,opt,name=profanity_filter,json=profanityFilter,proto3" json:"profanity_filter,omitempty"`
+	// Domain terms, product names, or acronyms passed to the model with every
+	// chunk, after the server's configured vocabulary. At most 896 characters.
+	Prompt string `protobuf:"bytes,17,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	// Provider and model to transcribe with, from the server's allowlist; empty
+	// uses the server default.
+	Provider string `protobuf:"bytes,18,opt,name=provider,proto3" json:"provider,omitempty"`
+	Model    string `protobuf:"bytes,19,opt,name=model,proto3" json:"model,omitempty"`
+	// Sampling temperature from 0 (the default) to 1.
+	Temperature float64 `protobuf:"fixed64,20,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	// Flag segments whose confidence is below this, from 0 to 1, and wrap their
+	// text in ⟦…⟧ in the transcription; zero flags nothing.
+	MinConfidence float64 `protobuf:"fixed64,21,opt,name=min_confidence,json=minConfidence,proto3" json:"min_confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeRequest) Reset() {
+	*x = TranscribeRequest{}
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeRequest) ProtoMessage() {}
+
+func (x *TranscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeRequest.ProtoReflect.Descriptor instead.
+func (*TranscribeRequest) Descriptor() ([]byte, []int) {
+	return file_transcriber_v1_transcriber_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TranscribeRequest) GetSource() isTranscribeRequest_Source {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetAudio() []byte {
+	if x != nil {
+		if x, ok := x.Source.(*TranscribeRequest_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetUrl() string {
+	if x != nil {
+		if x, ok := x.Source.(*TranscribeRequest_Url); ok {
+			return x.Url
+		}
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetAudioTrack() string {
+	if x != nil {
+		return x.AudioTrack
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetAudioLanguage() string {
+	if x != nil {
+		return x.AudioLanguage
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetDisableCache() bool {
+	if x != nil {
+		return x.DisableCache
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetNormalize() bool {
+	if x != nil {
+		return x.Normalize
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetDenoise() bool {
+	if x != nil {
+		return x.Denoise
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetAudioFilters() string {
+	if x != nil {
+		return x.AudioFilters
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetSplitChannels() bool {
+	if x != nil {
+		return x.SplitChannels
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetChannelLabels() []string {
+	if x != nil {
+		return x.ChannelLabels
+	}
+	return nil
+}
+
+func (x *TranscribeRequest) GetSummarize() bool {
+	if x != nil {
+		return x.Summarize
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetKeywords() bool {
+	if x != nil {
+		return x.Keywords
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetRedact() bool {
+	if x != nil {
+		return x.Redact
+	}
+	return false
+}
+
+func (x *TranscribeRequest) GetProfanityFilter() string {
+	if x != nil {
+		return x.ProfanityFilter
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *TranscribeRequest) GetTemperature() float64 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+func (x *TranscribeRequest) GetMinConfidence() float64 {
+	if x != nil {
+		return x.MinConfidence
+	}
+	return 0
+}
+
+type isTranscribeRequest_Source interface {
+	isTranscribeRequest_Source()
+}
+
+type TranscribeRequest_Audio struct {
+	// Raw media bytes. Limited by the server's maximum upload size.
+	Audio []byte `protobuf:"bytes,1,opt,name=audio,proto3,oneof"`
+}
+
+type TranscribeRequest_Url struct {
+	// An http(s), s3://, gs://, azblob://, or file:// URL the server downloads.
+	Url string `protobuf:"bytes,2,opt,name=url,proto3,oneof"`
+}
+
+func (*TranscribeRequest_Audio) isTranscribeRequest_Source() {}
+
+func (*TranscribeRequest_Url) isTranscribeRequest_Source() {}
+
+type Segment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Start float64                `protobuf:"fixed64,2,opt,name=start,proto3" json:"start,omitempty"`
+	End   float64                `protobuf:"fixed64,3,opt,name=end,proto3" json:"end,omitempty"`
+	Text  string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	// Channel label when split_channels was requested.
+	Speaker string `protobuf:"bytes,5,opt,name=speaker,proto3" json:"speaker,omitempty"`
+	// How sure the model was of the segment, from 0 to 1; unset when the provider didn't score it.
+	Confidence *float64 `protobuf:"fixed64,6,opt,name=confidence,proto3,oneof" json:"confidence,omitempty"`
+	// True when the segment's confidence is below the requested min_confidence.
+	LowConfidence bool `protobuf:"varint,7,opt,name=low_confidence,json=lowConfidence,proto3" json:"low_confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segment) Reset() {
+	*x = Segment{}
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segment) ProtoMessage() {}
+
+func (x *Segment) ProtoReflect() protoreflect.Message {
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segment.ProtoReflect.Descriptor instead.
+func (*Segment) Descriptor() ([]byte, []int) {
+	return file_transcriber_v1_transcriber_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Segment) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Segment) GetStart() float64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Segment) GetEnd() float64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Segment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Segment) GetSpeaker() string {
+	if x != nil {
+		return x.Speaker
+	}
+	return ""
+}
+
+func (x *Segment) GetConfidence() float64 {
+	if x != nil && x.Confidence != nil {
+		return *x.Confidence
+	}
+	return 0
+}
+
+func (x *Segment) GetLowConfidence() bool {
+	if x != nil {
+		return x.LowConfidence
+	}
+	return false
+}
+
+type TranscribeResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	JobId           string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Transcription   string                 `protobuf:"bytes,2,opt,name=transcription,proto3" json:"transcription,omitempty"`
+	Segments        []*Segment             `protobuf:"bytes,3,rep,name=segments,proto3" json:"segments,omitempty"`
+	DurationSeconds float64                `protobuf:"fixed64,4,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	Cached          bool                   `protobuf:"varint,5,opt,name=cached,proto3" json:"cached,omitempty"`
+	Usage           *Usage                 `protobuf:"bytes,6,opt,name=usage,proto3" json:"usage,omitempty"`
+	// The transcription broken into paragraphs at long pauses (and speaker changes).
+	ReadableText string `protobuf:"bytes,7,opt,name=readable_text,json=readableText,proto3" json:"readable_text,omitempty"`
+	// Set when summarize was requested and the summary succeeded.
+	Summary string `protobuf:"bytes,8,opt,name=summary,proto3" json:"summary,omitempty"`
+	// Set when summarize was requested and the summary failed; the transcript is still returned.
+	SummaryError string `protobuf:"bytes,9,opt,name=summary_error,json=summaryError,proto3" json:"summary_error,omitempty"`
+	// Set when keywords was requested, most relevant first.
+	Keywords []*Keyword `protobuf:"bytes,10,rep,name=keywords,proto3" json:"keywords,omitempty"`
+	// True when the transcript was redacted.
+	Redacted bool `protobuf:"varint,11,opt,name=redacted,proto3" json:"redacted,omitempty"`
+	// How many segments were flagged low_confidence.
+	LowConfidenceSegments int32 `protobuf:"varint,12,opt,name=low_confidence_segments,json=lowConfidenceSegments,proto3" json:"low_confidence_segments,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *TranscribeResponse) Reset() {
+	*x = TranscribeResponse{}
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeResponse) ProtoMessage() {}
+
+func (x *TranscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeResponse.ProtoReflect.Descriptor instead.
+func (*TranscribeResponse) Descriptor() ([]byte, []int) {
+	return file_transcriber_v1_transcriber_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TranscribeResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetTranscription() string {
+	if x != nil {
+		return x.Transcription
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetSegments() []*Segment {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *TranscribeResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *TranscribeResponse) GetUsage() *Usage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetReadableText() string {
+	if x != nil {
+		return x.ReadableText
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetSummaryError() string {
+	if x != nil {
+		return x.SummaryError
+	}
+	return ""
+}
+
+func (x *TranscribeResponse) GetKeywords() []*Keyword {
+	if x != nil {
+		return x.Keywords
+	}
+	return nil
+}
+
+func (x *TranscribeResponse) GetRedacted() bool {
+	if x != nil {
+		return x.Redacted
+	}
+	return false
+}
+
+func (x *TranscribeResponse) GetLowConfidenceSegments() int32 {
+	if x != nil {
+		return x.LowConfidenceSegments
+	}
+	return 0
+}
+
+// Keyword is a key phrase of the transcript.
+type Keyword struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Phrase string                 `protobuf:"bytes,1,opt,name=phrase,proto3" json:"phrase,omitempty"`
+	Score  float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	// How many times the phrase is mentioned.
+	Count int32 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	// Start times, in seconds, of the first few segments mentioning it.
+	Timestamps    []float64 `protobuf:"fixed64,4,rep,packed,name=timestamps,proto3" json:"timestamps,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Keyword) Reset() {
+	*x = Keyword{}
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Keyword) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Keyword) ProtoMessage() {}
+
+func (x *Keyword) ProtoReflect() protoreflect.Message {
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Keyword.ProtoReflect.Descriptor instead.
+func (*Keyword) Descriptor() ([]byte, []int) {
+	return file_transcriber_v1_transcriber_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Keyword) GetPhrase() string {
+	if x != nil {
+		return x.Phrase
+	}
+	return ""
+}
+
+func (x *Keyword) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Keyword) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Keyword) GetTimestamps() []float64 {
+	if x != nil {
+		return x.Timestamps
+	}
+	return nil
+}
+
+// Usage describes what a transcription consumed, for displaying and tracking spend.
+type Usage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	DurationSeconds float64                `protobuf:"fixed64,1,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	// Chunks sent to the provider; zero when the result was cached.
+	Chunks   int32  `protobuf:"varint,2,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	Provider string `protobuf:"bytes,3,opt,name=provider,proto3" json:"provider,omitempty"`
+	Model    string `protobuf:"bytes,4,opt,name=model,proto3" json:"model,omitempty"`
+	// Estimated from the configured per-minute rate; zero when the result was cached.
+	EstimatedCostUsd float64 `protobuf:"fixed64,5,opt,name=estimated_cost_usd,json=estimatedCostUsd,proto3" json:"estimated_cost_usd,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Usage) Reset() {
+	*x = Usage{}
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Usage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Usage) ProtoMessage() {}
+
+func (x *Usage) ProtoReflect() protoreflect.Message {
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Usage.ProtoReflect.Descriptor instead.
+func (*Usage) Descriptor() ([]byte, []int) {
+	return file_transcriber_v1_transcriber_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Usage) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *Usage) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+func (x *Usage) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Usage) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Usage) GetEstimatedCostUsd() float64 {
+	if x != nil {
+		return x.EstimatedCostUsd
+	}
+	return 0
+}
+
+type TranscribeStreamResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*TranscribeStreamResponse_Segment
+	//	*TranscribeStreamResponse_Result
+	Event         isTranscribeStreamResponse_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeStreamResponse) Reset() {
+	*x = TranscribeStreamResponse{}
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeStreamResponse) ProtoMessage() {}
+
+func (x *TranscribeStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transcriber_v1_transcriber_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeStreamResponse.ProtoReflect.Descriptor instead.
+func (*TranscribeStreamResponse) Descriptor() ([]byte, []int) {
+	return file_transcriber_v1_transcriber_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TranscribeStreamResponse) GetEvent() isTranscribeStreamResponse_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *TranscribeStreamResponse) GetSegment() *Segment {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeStreamResponse_Segment); ok {
+			return x.Segment
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeStreamResponse) GetResult() *TranscribeResponse {
+	if x != nil {
+		if x, ok := x.Event.(*TranscribeStreamResponse_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isTranscribeStreamResponse_Event interface {
+	isTranscribeStreamResponse_Event()
+}
+
+type TranscribeStreamResponse_Segment struct {
+	Segment *Segment `protobuf:"bytes,1,opt,name=segment,proto3,oneof"`
+}
+
+type TranscribeStreamResponse_Result struct {
+	Result *TranscribeResponse `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*TranscribeStreamResponse_Segment) isTranscribeStreamResponse_Event() {}
+
+func (*TranscribeStreamResponse_Result) isTranscribeStreamResponse_Event() {}
+
+var File_transcriber_v1_transcriber_proto protoreflect.FileDescriptor
+
+const file_transcriber_v1_transcriber_proto_rawDesc = "" +
+	"\n" +
+	" transcriber/v1/transcriber.proto\x12\x0etranscriber.v1\"\xa9\x05\n" +
+	"\x11TranscribeRequest\x12\x16\n" +
+	"\x05audio\x18\x01 \x01(\fH\x00R\x05audio\x12\x12\n" +
+	"\x03url\x18\x02 \x01(\tH\x00R\x03url\x12\x1a\n" +
+	"\bfilename\x18\x03 \x01(\tR\bfilename\x12\x1f\n" +
+	"\vaudio_track\x18\x04 \x01(\tR\n" +
+	"audioTrack\x12%\n" +
+	"\x0eaudio_language\x18\x05 \x01(\tR\raudioLanguage\x12#\n" +
+	"\rdisable_cache\x18\x06 \x01(\bR\fdisableCache\x12\x1a\n" +
+	"\bpriority\x18\a \x01(\tR\bpriority\x12\x1c\n" +
+	"\tnormalize\x18\b \x01(\bR\tnormalize\x12\x18\n" +
+	"\adenoise\x18\t \x01(\bR\adenoise\x12#\n" +
+	"\raudio_filters\x18\n" +
+	" \x01(\tR\faudioFilters\x12%\n" +
+	"\x0esplit_channels\x18\v \x01(\bR\rsplitChannels\x12%\n" +
+	"\x0echannel_labels\x18\f \x03(\tR\rchannelLabels\x12\x1c\n" +
+	"\tsummarize\x18\r \x01(\bR\tsummarize\x12\x1a\n" +
+	"\bkeywords\x18\x0e \x01(\bR\bkeywords\x12\x16\n" +
+	"\x06redact\x18\x0f \x01(\bR\x06redact\x12)\n" +
+	"\x10profanity_filter\x18\x10 \x01(\tR\x0fprofanityFilter\x12\x16\n" +
+	"\x06prompt\x18\x11 \x01(\tR\x06prompt\x12\x1a\n" +
+	"\bprovider\x18\x12 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05model\x18\x13 \x01(\tR\x05model\x12 \n" +
+	"\vtemperature\x18\x14 \x01(\x01R\vtemperature\x12%\n" +
+	"\x0emin_confidence\x18\x15 \x01(\x01R\rminConfidenceB\b\n" +
+	"\x06source\"\xca\x01\n" +
+	"\aSegment\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x01R\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\x01R\x03end\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x18\n" +
+	"\aspeaker\x18\x05 \x01(\tR\aspeaker\x12#\n" +
+	"\n" +
+	"confidence\x18\x06 \x01(\x01H\x00R\n" +
+	"confidence\x88\x01\x01\x12%\n" +
+	"\x0elow_confidence\x18\a \x01(\bR\rlowConfidenceB\r\n" +
+	"\v_confidence\"\xe3\x03\n" +
+	"\x12TranscribeResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12$\n" +
+	"\rtranscription\x18\x02 \x01(\tR\rtranscription\x123\n" +
+	"\bsegments\x18\x03 \x03(\v2\x17.transcriber.v1.SegmentR\bsegments\x12)\n" +
+	"\x10duration_seconds\x18\x04 \x01(\x01R\x0fdurationSeconds\x12\x16\n" +
+	"\x06cached\x18\x05 \x01(\bR\x06cached\x12+\n" +
+	"\x05usage\x18\x06 \x01(\v2\x15.transcriber.v1.UsageR\x05usage\x12#\n" +
+	"\rreadable_text\x18\a \x01(\tR\freadableText\x12\x18\n" +
+	"\asummary\x18\b \x01(\tR\asummary\x12#\n" +
+	"\rsummary_error\x18\t \x01(\tR\fsummaryError\x123\n" +
+	"\bkeywords\x18\n" +
+	" \x03(\v2\x17.transcriber.v1.KeywordR\bkeywords\x12\x1a\n" +
+	"\bredacted\x18\v \x01(\bR\bredacted\x126\n" +
+	"\x17low_confidence_segments\x18\f \x01(\x05R\x15lowConfidenceSegments\"m\n" +
+	"\aKeyword\x12\x16\n" +
+	"\x06phrase\x18\x01 \x01(\tR\x06phrase\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x05R\x05count\x12\x1e\n" +
+	"\n" +
+	"timestamps\x18\x04 \x03(\x01R\n" +
+	"timestamps\"\xaa\x01\n" +
+	"\x05Usage\x12)\n" +
+	"\x10duration_seconds\x18\x01 \x01(\x01R\x0fdurationSeconds\x12\x16\n" +
+	"\x06chunks\x18\x02 \x01(\x05R\x06chunks\x12\x1a\n" +
+	"\bprovider\x18\x03 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05model\x18\x04 \x01(\tR\x05model\x12,\n" +
+	"\x12estimated_cost_usd\x18\x05 \x01(\x01R\x10estimatedCostUsd\"\x96\x01\n" +
+	"\x18TranscribeStreamResponse\x123\n" +
+	"\asegment\x18\x01 \x01(\v2\x17.transcriber.v1.SegmentH\x00R\asegment\x12<\n" +
+	"\x06result\x18\x02 \x01(\v2\".transcriber.v1.TranscribeResponseH\x00R\x06resultB\a\n" +
+	"\x05event2\xcc\x01\n" +
+	"\x12TranscriberService\x12S\n" +
+	"\n" +
+	"Transcribe\x12!.transcriber.v1.TranscribeRequest\x1a\".transcriber.v1.TranscribeResponse\x12a\n" +
+	"\x10TranscribeStream\x12!.transcriber.v1.TranscribeRequest\x1a(.transcriber.v1.TranscribeStreamResponse0\x01B6Z4audio-transcriber/proto/transcriber/v1;transcriberv1b\x06proto3"
+
+var (
+	file_transcriber_v1_transcriber_proto_rawDescOnce sync.Once
+	file_transcriber_v1_transcriber_proto_rawDescData []byte
+)
+
+func file_transcriber_v1_transcriber_proto_rawDescGZIP() []byte {
+	file_transcriber_v1_transcriber_proto_rawDescOnce.Do(func() {
+		file_transcriber_v1_transcriber_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_transcriber_v1_transcriber_proto_rawDesc), len(file_transcriber_v1_transcriber_proto_rawDesc)))
+	})
+	return file_transcriber_v1_transcriber_proto_rawDescData
+}
+
+var file_transcriber_v1_transcriber_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_transcriber_v1_transcriber_proto_goTypes = []any{
+	(*TranscribeRequest)(nil),        // 0: transcriber.v1.TranscribeRequest
+	(*Segment)(nil),                  // 1: transcriber.v1.Segment
+	(*TranscribeResponse)(nil),       // 2: transcriber.v1.TranscribeResponse
+	(*Keyword)(nil),                  // 3: transcriber.v1.Keyword
+	(*Usage)(nil),                    // 4: transcriber.v1.Usage
+	(*TranscribeStreamResponse)(nil), // 5: transcriber.v1.TranscribeStreamResponse
+}
+var file_transcriber_v1_transcriber_proto_depIdxs = []int32{
+	1, // 0: transcriber.v1.TranscribeResponse.segments:type_name -> transcriber.v1.Segment
+	4, // 1: transcriber.v1.TranscribeResponse.usage:type_name -> transcriber.v1.Usage
+	3, // 2: transcriber.v1.TranscribeResponse.keywords:type_name -> transcriber.v1.Keyword
+	1, // 3: transcriber.v1.TranscribeStreamResponse.segment:type_name -> transcriber.v1.Segment
+	2, // 4: transcriber.v1.TranscribeStreamResponse.result:type_name -> transcriber.v1.TranscribeResponse
+	0, // 5: transcriber.v1.TranscriberService.Transcribe:input_type -> transcriber.v1.TranscribeRequest
+	0, // 6: transcriber.v1.TranscriberService.TranscribeStream:input_type -> transcriber.v1.TranscribeRequest
+	2, // 7: transcriber.v1.TranscriberService.Transcribe:output_type -> transcriber.v1.TranscribeResponse
+	5, // 8: transcriber.v1.TranscriberService.TranscribeStream:output_type -> transcriber.v1.TranscribeStreamResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_transcriber_v1_transcriber_proto_init() }
+func file_transcriber_v1_transcriber_proto_init() {
+	if File_transcriber_v1_transcriber_proto != nil {
+		return
+	}
+	file_transcriber_v1_transcriber_proto_msgTypes[0].OneofWrappers = []any{
+		(*TranscribeRequest_Audio)(nil),
+		(*TranscribeRequest_Url)(nil),
+	}
+	file_transcriber_v1_transcriber_proto_msgTypes[1].OneofWrappers = []any{}
+	file_transcriber_v1_transcriber_proto_msgTypes[5].OneofWrappers = []any{
+		(*TranscribeStreamResponse_Segment)(nil),
+		(*TranscribeStreamResponse_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_transcriber_v1_transcriber_proto_rawDesc), len(file_transcriber_v1_transcriber_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_transcriber_v1_transcriber_proto_goTypes,
+		DependencyIndexes: file_transcriber_v1_transcriber_proto_depIdxs,
+		MessageInfos:      file_transcriber_v1_transcriber_proto_msgTypes,
+	}.Build()
+	File_transcriber_v1_transcriber_proto = out.File
+	file_transcriber_v1_transcriber_proto_goTypes = nil
+	file_transcriber_v1_transcriber_proto_depIdxs = nil
+}
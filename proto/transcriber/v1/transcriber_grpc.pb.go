@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: transcriber/v1/transcriber.proto
+
+package transcriberv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TranscriberService_Transcribe_FullMethodName       = "/transcriber.v1.TranscriberService/Transcribe"
+	TranscriberService_TranscribeStream_FullMethodName = "/transcriber.v1.TranscriberService/TranscribeStream"
+)
+
+// TranscriberServiceClient is the client API for TranscriberService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TranscriberService runs media through the same preprocess/chunk/transcribe
+// pipeline as the REST API.
+type TranscriberServiceClient interface {
+	// Transcribe returns the complete transcription once every chunk is done.
+	Transcribe(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (*TranscribeResponse, error)
+	// TranscribeStream emits segments in timeline order as chunks complete,
+	// followed by a final message carrying the complete result.
+	TranscribeStream(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TranscribeStreamResponse], error)
+}
+
+type transcriberServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTranscriberServiceClient(cc grpc.ClientConnInterface) TranscriberServiceClient {
+	return &transcriberServiceClient{cc}
+}
+
+func (c *transcriberServiceClient) Transcribe(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (*TranscribeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TranscribeResponse)
+	err := c.cc.Invoke(ctx, TranscriberService_Transcribe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transcriberServiceClient) TranscribeStream(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TranscribeStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TranscriberService_ServiceDesc.Streams[0], TranscriberService_TranscribeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TranscribeRequest, TranscribeStreamResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TranscriberService_TranscribeStreamClient = grpc.ServerStreamingClient[TranscribeStreamResponse]
+
+// TranscriberServiceServer is the server API for TranscriberService service.
+// All implementations must embed UnimplementedTranscriberServiceServer
+// for forward compatibility.
+//
+// TranscriberService runs media through the same preprocess/chunk/transcribe
+// pipeline as the REST API.
+type TranscriberServiceServer interface {
+	// Transcribe returns the complete transcription once every chunk is done.
+	Transcribe(context.Context, *TranscribeRequest) (*TranscribeResponse, error)
+	// TranscribeStream emits segments in timeline order as chunks complete,
+	// followed by a final message carrying the complete result.
+	TranscribeStream(*TranscribeRequest, grpc.ServerStreamingServer[TranscribeStreamResponse]) error
+	mustEmbedUnimplementedTranscriberServiceServer()
+}
+
+// UnimplementedTranscriberServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTranscriberServiceServer struct{}
+
+func (UnimplementedTranscriberServiceServer) Transcribe(context.Context, *TranscribeRequest) (*TranscribeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Transcribe not implemented")
+}
+func (UnimplementedTranscriberServiceServer) TranscribeStream(*TranscribeRequest, grpc.ServerStreamingServer[TranscribeStreamResponse]) error {
+	return status.Error(codes.Unimplemented, "method TranscribeStream not implemented")
+}
+func (UnimplementedTranscriberServiceServer) mustEmbedUnimplementedTranscriberServiceServer() {}
+func (UnimplementedTranscriberServiceServer) testEmbeddedByValue()                            {}
+
+// UnsafeTranscriberServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TranscriberServiceServer will
+// result in compilation errors.
+type UnsafeTranscriberServiceServer interface {
+	mustEmbedUnimplementedTranscriberServiceServer()
+}
+
+func RegisterTranscriberServiceServer(s grpc.ServiceRegistrar, srv TranscriberServiceServer) {
+	// If the following call panics, it indicates UnimplementedTranscriberServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TranscriberService_ServiceDesc, srv)
+}
+
+func _TranscriberService_Transcribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranscriberServiceServer).Transcribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TranscriberService_Transcribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranscriberServiceServer).Transcribe(ctx, req.(*TranscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranscriberService_TranscribeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TranscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TranscriberServiceServer).TranscribeStream(m, &grpc.GenericServerStream[TranscribeRequest, TranscribeStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TranscriberService_TranscribeStreamServer = grpc.ServerStreamingServer[TranscribeStreamResponse]
+
+// TranscriberService_ServiceDesc is the grpc.ServiceDesc for TranscriberService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TranscriberService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transcriber.v1.TranscriberService",
+	HandlerType: (*TranscriberServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Transcribe",
+			Handler:    _TranscriberService_Transcribe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "TranscribeStream",
+			Handler:       _TranscriberService_TranscribeStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "transcriber/v1/transcriber.proto",
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// providerURLs are the OpenAI-compatible transcription endpoints of the providers the server can use
+var providerURLs = map[string]string{
+	"groq":   transcriber.DefaultAPIURL,
+	"openai": "https://api.openai.com/v1/audio/transcriptions",
+}
+
+// customURLProviders are the providers pointed at another server by TRANSCRIBER_GROQ_URL or
+// TRANSCRIBER_OPENAI_URL. Such servers are often self-hosted without authentication, so these
+// providers are usable without an API key
+var customURLProviders = map[string]bool{}
+
+// defaultAllowedModels is every model callers may pick when TRANSCRIBER_ALLOWED_MODELS isn't set
+var defaultAllowedModels = []string{
+	"groq:distil-whisper-large-v3-en",
+	"groq:whisper-large-v3",
+	"groq:whisper-large-v3-turbo",
+	"openai:whisper-1",
+}
+
+// providerSet is what a request may be transcribed with: a pipeline for each provider with an API
+// key, plus the default provider, and the models callers may pick for each provider, in
+// configured order. The server has one, and so does each tenant
+type providerSet struct {
+	defaultProvider string
+	transcribers    map[string]*transcriber.Transcriber
+	allowedModels   map[string][]string
+}
+
+// serverProviders is the provider set of the server's own configuration, used by requests that
+// don't belong to a tenant
+var serverProviders *providerSet
+
+// providersMu guards serverProviders, the tenants' provider sets, and the fields of appConfig a
+// reload changes
+var providersMu sync.RWMutex
+
+// requestLimiter is shared by every provider pipeline, the server's and the tenants', so the
+// requests of concurrent jobs add up to at most the configured limits
+var requestLimiter *transcriber.RequestLimiter
+
+// modelSelection is the provider, model, and temperature a job is transcribed with
+type modelSelection struct {
+	Provider    string
+	Model       string
+	Temperature float64
+}
+
+// initProviders sets up the server's model allowlist and a pipeline for each usable provider
+func initProviders(config Config) error {
+	if err := transcriber.ValidateLanguage(config.Language); err != nil {
+		return fmt.Errorf("invalid TRANSCRIBER_LANGUAGE: %w", err)
+	}
+	if err := initLanguageModels(config.LanguageModels); err != nil {
+		return err
+	}
+	for provider, baseURL := range map[string]string{"groq": config.GroqURL, "openai": config.OpenAIURL} {
+		if baseURL == "" {
+			continue
+		}
+		apiURL, err := transcriptionURL(baseURL)
+		if err != nil {
+			return fmt.Errorf("invalid TRANSCRIBER_%s_URL: %w", strings.ToUpper(provider), err)
+		}
+		providerURLs[provider] = apiURL.String()
+		customURLProviders[provider] = true
+		slog.Info("Using a custom transcription endpoint", "provider", provider, "url", apiURL.Redacted())
+	}
+
+	requestLimiter = transcriber.NewRequestLimiter(int(config.MaxUpstreamRequests), config.RequestsPerSecond)
+	providers, err := newProviderSet(config, config.Provider, config.Model, config.AllowedModels, serverAPIKeys(config))
+	if err != nil {
+		return err
+	}
+	serverProviders = providers
+	return nil
+}
+
+// reloadProviders applies changed provider keys, model defaults, allowlists, and upstream limits
+// from config. The server's and the tenants' provider sets are all rebuilt before any is swapped
+// in, so an invalid setting changes nothing. Jobs already running keep the pipeline they started
+// with
+func reloadProviders(config Config) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	next := appConfig
+	next.GroqAPIKey, next.OpenAIAPIKey = config.GroqAPIKey, config.OpenAIAPIKey
+	next.Provider, next.Model, next.AllowedModels = config.Provider, config.Model, config.AllowedModels
+	next.MaxUpstreamRequests, next.RequestsPerSecond = config.MaxUpstreamRequests, config.RequestsPerSecond
+
+	providers, err := newProviderSet(next, next.Provider, next.Model, next.AllowedModels, serverAPIKeys(next))
+	if err != nil {
+		return err
+	}
+	tenantProviders := make(map[*Tenant]*providerSet, len(tenantsByID))
+	for _, tenant := range tenantsByID {
+		set, err := newTenantProviders(next, tenant)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		tenantProviders[tenant] = set
+	}
+
+	// Only the reloaded fields are written, since the rest of appConfig is read without a lock
+	appConfig.GroqAPIKey, appConfig.OpenAIAPIKey = next.GroqAPIKey, next.OpenAIAPIKey
+	appConfig.Provider, appConfig.Model, appConfig.AllowedModels = next.Provider, next.Model, next.AllowedModels
+	appConfig.MaxUpstreamRequests, appConfig.RequestsPerSecond = next.MaxUpstreamRequests, next.RequestsPerSecond
+	serverProviders = providers
+	for tenant, set := range tenantProviders {
+		tenant.providers = set
+	}
+	requestLimiter.SetLimits(int(next.MaxUpstreamRequests), next.RequestsPerSecond)
+	return nil
+}
+
+// newProviderSet builds the model allowlist from "provider:model" entries and a pipeline for each
+// provider with an API key. The default provider and model are always allowed
+func newProviderSet(config Config, defaultProvider, defaultModel string, allowedEntries []string, apiKeys map[string]string) (*providerSet, error) {
+	if _, ok := providerURLs[defaultProvider]; !ok {
+		return nil, fmt.Errorf("unknown provider %q: expected one of %s", defaultProvider, strings.Join(providerNames(), ", "))
+	}
+
+	allowedModels := map[string][]string{}
+	for _, entry := range allowedEntries {
+		provider, model, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || model == "" || providerURLs[provider] == "" {
+			slog.Warn("Ignoring invalid allowed model", "entry", entry)
+			continue
+		}
+		if !slices.Contains(allowedModels[provider], model) {
+			allowedModels[provider] = append(allowedModels[provider], model)
+		}
+	}
+
+	// The default model goes first so it is also what callers get when they only pick the provider
+	if defaultModel == "" && len(allowedModels[defaultProvider]) > 0 {
+		defaultModel = allowedModels[defaultProvider][0]
+	}
+
+	transcribers := map[string]*transcriber.Transcriber{}
+	for provider, apiURL := range providerURLs {
+		if apiKeys[provider] == "" && provider != defaultProvider && !customURLProviders[provider] {
+			continue
+		}
+		// Other providers default to their first allowed model
+		model := defaultModel
+		if provider != defaultProvider {
+			model = ""
+			if len(allowedModels[provider]) > 0 {
+				model = allowedModels[provider][0]
+			}
+		}
+		transcribers[provider] = newTranscriber(config, apiURL, apiKeys[provider], model)
+	}
+
+	defaultModel = transcribers[defaultProvider].Model()
+	allowed := slices.DeleteFunc(allowedModels[defaultProvider], func(model string) bool { return model == defaultModel })
+	allowedModels[defaultProvider] = append([]string{defaultModel}, allowed...)
+	return &providerSet{defaultProvider: defaultProvider, transcribers: transcribers, allowedModels: allowedModels}, nil
+}
+
+// transcriptionURL turns an OpenAI-compatible base URL such as http://localhost:8000/v1 into its
+// transcription endpoint. A URL that already ends in /audio/transcriptions is used as it is
+func transcriptionURL(baseURL string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return nil, err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", parsed.Redacted())
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	if !strings.HasSuffix(parsed.Path, "/audio/transcriptions") {
+		parsed.Path += "/audio/transcriptions"
+	}
+	return parsed, nil
+}
+
+// setAPIKeys switches the set's pipelines to new API keys, leaving those without one alone
+func (p *providerSet) setAPIKeys(apiKeys map[string]string) {
+	for provider, t := range p.transcribers {
+		if key := apiKeys[provider]; key != "" {
+			t.SetAPIKey(key)
+		}
+	}
+}
+
+// providersFor returns a tenant's provider set, or the server's for requests without a tenant
+func providersFor(tenant *Tenant) *providerSet {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	if tenant == nil {
+		return serverProviders
+	}
+	return tenant.providers
+}
+
+// providerNames lists the known providers in a stable order
+func providerNames() []string {
+	names := make([]string, 0, len(providerURLs))
+	for name := range providerURLs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// transcriberFor returns a tenant's pipeline for a provider, falling back to its default provider
+func transcriberFor(tenant *Tenant, provider string) *transcriber.Transcriber {
+	providers := providersFor(tenant)
+	if t, ok := providers.transcribers[provider]; ok {
+		return t
+	}
+	return providers.transcribers[providers.defaultProvider]
+}
+
+// parseTemperature reads a temperature form or metadata value, defaulting to 0 when it is empty
+func parseTemperature(value string) (float64, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	temperature, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid temperature %q: expected a number between 0 and 1", value)}
+	}
+	return temperature, nil
+}
+
+// parseModelFields reads the provider, model, and temperature from form fields or upload metadata
+func parseModelFields(tenant *Tenant, fields map[string]string) (modelSelection, error) {
+	temperature, err := parseTemperature(fields["temperature"])
+	if err != nil {
+		return modelSelection{}, err
+	}
+	return parseModelSelection(tenant, fields["provider"], fields["model"], temperature)
+}
+
+// parseModelSelection validates a requested provider, model, and temperature against the
+// tenant's providers and allowlist, or the server's without a tenant. An empty provider means the
+// default one, and an empty model means the provider's default (the first allowed model for
+// other providers)
+func parseModelSelection(tenant *Tenant, provider, model string, temperature float64) (modelSelection, error) {
+	providers := providersFor(tenant)
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	model = strings.TrimSpace(model)
+	if provider == "" {
+		provider = providers.defaultProvider
+	}
+
+	if _, ok := providers.transcribers[provider]; !ok {
+		var available []string
+		for _, name := range providerNames() {
+			if _, ok := providers.transcribers[name]; ok {
+				available = append(available, name)
+			}
+		}
+		return modelSelection{}, &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Unknown or unconfigured provider %q: expected one of %s", provider, strings.Join(available, ", ")),
+		}
+	}
+
+	allowed := providers.allowedModels[provider]
+	if model == "" {
+		if len(allowed) == 0 {
+			return modelSelection{}, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("No models are allowed for provider %q", provider)}
+		}
+		model = allowed[0]
+	}
+	if !slices.Contains(allowed, model) {
+		return modelSelection{}, &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Model %q is not allowed for provider %q: expected one of %s", model, provider, strings.Join(allowed, ", ")),
+		}
+	}
+
+	if err := transcriber.ValidateTemperature(temperature); err != nil {
+		return modelSelection{}, &pipelineError{Status: http.StatusBadRequest, Message: "Invalid temperature: " + err.Error()}
+	}
+	return modelSelection{Provider: provider, Model: model, Temperature: temperature}, nil
+}
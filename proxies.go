@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configureTrustedProxies sets which proxies may report the client IP. With none configured,
+// forwarding headers are ignored and the connection's address is used
+func configureTrustedProxies(r *gin.Engine, config Config) error {
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(config.TrustedPlatform) {
+	case "":
+	case "cloudflare":
+		r.TrustedPlatform = gin.PlatformCloudflare
+	case "google":
+		r.TrustedPlatform = gin.PlatformGoogleAppEngine
+	default:
+		// Any other value is taken as the name of a header the platform sets
+		r.TrustedPlatform = config.TrustedPlatform
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Punctuation modes: restored by rule, or by the summary chat model
+const (
+	punctuationRules = "rules"
+	punctuationLLM   = "llm"
+)
+
+// punctuationPrompt asks the chat model to punctuate numbered transcript lines without changing
+// their words
+const punctuationPrompt = "Restore the punctuation, capitalization, and sentence boundaries of the " +
+	"following numbered transcript lines. Do not add, remove, reorder, or correct any words, and keep " +
+	"bracketed tokens such as [EMAIL] as they are. Reply with only the lines, one per line, each " +
+	"starting with its number.\n\n"
+
+// parsePunctuation validates the punctuation option of a request: rules, llm, or "" for none
+func parsePunctuation(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "", punctuationRules, punctuationLLM:
+		return mode, nil
+	default:
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Unknown punctuation %q: expected rules or llm", value),
+		}
+	}
+}
+
+// punctuateResult restores the punctuation and casing of a finished transcript's segments and
+// rebuilds its text from them. In llm mode a segment keeps its text when the model changed its
+// words, and the whole transcript falls back to the rules when the chat API fails, since an
+// unpunctuated transcript is still better than none
+func punctuateResult(ctx context.Context, result *transcriber.Result, mode string) {
+	if len(result.Segments) == 0 {
+		return
+	}
+	if mode == punctuationLLM {
+		punctuationCtx, cancel := jobContext(ctx)
+		defer cancel()
+		segments, err := punctuateWithChat(punctuationCtx, result.Segments)
+		if err == nil {
+			result.Segments = segments
+			result.Transcription = transcriber.JoinSegments(segments)
+			return
+		}
+		loggerFrom(ctx).Warn("Error restoring punctuation, falling back to rules", "error", err)
+	}
+	result.Segments = transcriber.RestorePunctuation(result.Segments)
+	result.Transcription = transcriber.JoinSegments(result.Segments)
+}
+
+// punctuateWithChat asks the configured chat model to punctuate segments, keeping each segment's
+// original text unless the model's only changes were punctuation and casing
+func punctuateWithChat(ctx context.Context, segments []transcriber.Segment) ([]transcriber.Segment, error) {
+	replies, err := chatLines(ctx, punctuationPrompt, segmentTexts(segments))
+	if err != nil {
+		return nil, err
+	}
+	punctuated := slices.Clone(segments)
+	for i, text := range replies {
+		if text != "" && transcriber.SameWords(segments[i].Text, text) {
+			punctuated[i].Text = text
+		}
+	}
+	return punctuated, nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Job priorities, from most to least urgent
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityBatch  = "batch"
+)
+
+// jobPriorities lists every priority in the order workers are handed out
+var jobPriorities = []string{PriorityHigh, PriorityNormal, PriorityBatch}
+
+// queueSlots bounds every admitted job, from the start of its upload or download until it
+// finishes. It is a buffered channel used as a semaphore
+var queueSlots chan struct{}
+
+// pipelineWorkers hands out the workers that actually run the pipeline
+var pipelineWorkers *workerPool
+
+// initJobQueue sizes the queue for the given number of concurrent pipelines plus waiting jobs.
+// shares are "priority=percent" entries capping the share of workers each priority may hold
+func initJobQueue(workers, queueSize int, shares []string) {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	pipelineWorkers = newWorkerPool(workers, parsePriorityShares(shares))
+	queueSlots = make(chan struct{}, workers+queueSize)
+}
+
+// parsePriority validates a requested priority, defaulting to normal when none is given
+func parsePriority(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return PriorityNormal, nil
+	}
+	for _, priority := range jobPriorities {
+		if value == priority {
+			return priority, nil
+		}
+	}
+	return "", &pipelineError{
+		Status:  http.StatusBadRequest,
+		Message: fmt.Sprintf("Unknown priority %q: expected high, normal, or batch", value),
+	}
+}
+
+// parsePriorityShares reads "priority=percent" entries, skipping invalid ones with a warning.
+// Priorities without an entry may use every worker
+func parsePriorityShares(entries []string) map[string]float64 {
+	shares := map[string]float64{}
+	for _, entry := range entries {
+		name, value, _ := strings.Cut(entry, "=")
+		priority, err := parsePriority(name)
+		percent, parseErr := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if strings.TrimSpace(name) == "" || err != nil || parseErr != nil || percent <= 0 || percent > 100 {
+			slog.Warn("Ignoring invalid priority share", "entry", entry)
+			continue
+		}
+		shares[priority] = percent
+	}
+	return shares
+}
+
+// workerPool runs waiting jobs most urgent first. Each priority may hold at most its share of the
+// workers, so a backlog of batch jobs can't keep interactive ones waiting for a free worker
+type workerPool struct {
+	mu      sync.Mutex
+	free    int
+	limits  map[string]int
+	running map[string]int
+	waiting map[string][]chan struct{}
+}
+
+// newWorkerPool creates a pool of workers, with shares given as percentages of the pool
+func newWorkerPool(workers int, shares map[string]float64) *workerPool {
+	pool := &workerPool{
+		free:    workers,
+		limits:  map[string]int{},
+		running: map[string]int{},
+		waiting: map[string][]chan struct{}{},
+	}
+	for _, priority := range jobPriorities {
+		pool.limits[priority] = workers
+		if share, ok := shares[priority]; ok {
+			// Every priority keeps at least one worker so its jobs always make progress
+			pool.limits[priority] = max(1, int(math.Ceil(float64(workers)*share/100)))
+		}
+	}
+	return pool
+}
+
+// acquire blocks until a worker is free for a job of the given priority or ctx is canceled.
+// The returned function gives the worker back
+func (p *workerPool) acquire(ctx context.Context, priority string) (func(), error) {
+	ready := make(chan struct{})
+	p.mu.Lock()
+	p.waiting[priority] = append(p.waiting[priority], ready)
+	p.dispatch()
+	p.mu.Unlock()
+
+	release := func() {
+		p.mu.Lock()
+		p.running[priority]--
+		p.free++
+		p.dispatch()
+		p.mu.Unlock()
+	}
+
+	select {
+	case <-ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, waiter := range p.waiting[priority] {
+		if waiter == ready {
+			p.waiting[priority] = append(p.waiting[priority][:i], p.waiting[priority][i+1:]...)
+			return nil, ctx.Err()
+		}
+	}
+
+	// The worker was handed over just as ctx was canceled; pass it on
+	p.running[priority]--
+	p.free++
+	p.dispatch()
+	return nil, ctx.Err()
+}
+
+// dispatch hands free workers to the longest-waiting job of the most urgent priority under its
+// share. p.mu must be held
+func (p *workerPool) dispatch() {
+	for p.free > 0 {
+		dispatched := false
+		for _, priority := range jobPriorities {
+			if len(p.waiting[priority]) == 0 || p.running[priority] >= p.limits[priority] {
+				continue
+			}
+			close(p.waiting[priority][0])
+			p.waiting[priority] = p.waiting[priority][1:]
+			p.running[priority]++
+			p.free--
+			dispatched = true
+			break
+		}
+		if !dispatched {
+			return
+		}
+	}
+}
+
+// snapshot returns how many workers each priority holds and how many of its jobs are waiting
+func (p *workerPool) snapshot() (running, waiting map[string]int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	running, waiting = map[string]int{}, map[string]int{}
+	for _, priority := range jobPriorities {
+		running[priority] = p.running[priority]
+		waiting[priority] = len(p.waiting[priority])
+	}
+	return running, waiting
+}
+
+// hasRoom reports whether a job of the given priority would get a worker without waiting
+func (p *workerPool) hasRoom(priority string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.free == 0 || p.running[priority] >= p.limits[priority] {
+		return false
+	}
+	for _, other := range jobPriorities {
+		if other == priority {
+			return true
+		}
+		if len(p.waiting[other]) > 0 && p.running[other] < p.limits[other] {
+			return false
+		}
+	}
+	return true
+}
+
+// admitJob reserves a place for a new job, or returns a 503 with Retry-After when the queue is full.
+// A job of a tenant also has to fit in the tenant's quotas. The returned function releases the
+// place once the job is done
+func admitJob(ctx context.Context) (func(), error) {
+	releaseTenant, err := admitTenantJob(tenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	releaseKey, err := admitKeyJob(ctx)
+	if err != nil {
+		releaseTenant()
+		return nil, err
+	}
+
+	select {
+	case queueSlots <- struct{}{}:
+		return func() {
+			<-queueSlots
+			releaseKey()
+			releaseTenant()
+		}, nil
+	default:
+		releaseKey()
+		releaseTenant()
+		return nil, &pipelineError{
+			Status:     http.StatusServiceUnavailable,
+			Message:    "Server is busy: too many jobs queued, try again later",
+			RetryAfter: appConfig.QueueRetryAfter,
+		}
+	}
+}
+
+// waitForWorker blocks until a pipeline worker is free for a job of the given priority or ctx is canceled
+func waitForWorker(ctx context.Context, priority string) (func(), error) {
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	queued := jobsQueued.WithLabelValues(priority)
+	queued.Inc()
+	defer queued.Dec()
+
+	return pipelineWorkers.acquire(ctx, priority)
+}
+
+// executeJob runs a job's pipeline and records the outcome. With a shared queue the job is
+// handed to whichever instance claims it, and this waits for the result. A job whose API key
+// queues its excess first waits for one of the key's slots
+func executeJob(ctx context.Context, job *Job, jobDir, inputPath string, opts JobOptions) (*transcriber.Result, error) {
+	releaseKey, err := waitForKeySlot(ctx)
+	if err != nil {
+		return completeJob(ctx, job, opts, inputPath, nil, pipelineErrorFor(err))
+	}
+	defer releaseKey()
+
+	opts.UploadMs = milliseconds(time.Since(job.CreatedAt))
+	if distQueue != nil {
+		return distQueue.execute(ctx, job, jobDir, inputPath, opts)
+	}
+	return processJob(ctx, job, jobDir, inputPath, opts)
+}
+
+// processJob runs a job's pipeline on this instance, redacts the transcript, summarizes it, and
+// extracts keywords when asked, and records the outcome. Redaction comes first so nothing
+// derived from the transcript sees what it masks
+func processJob(ctx context.Context, job *Job, jobDir, inputPath string, opts JobOptions) (*transcriber.Result, error) {
+	ctx, untrack := trackJob(ctx, job.ID)
+	defer untrack()
+	opts = prepareJob(job, opts)
+	markResumable(ctx, job, jobDir, inputPath, opts)
+	timings := &JobTimings{UploadMs: opts.UploadMs}
+	result, err := runPipeline(withJobTimings(ctx, timings), jobDir, inputPath, opts)
+	if err = interruptJob(ctx, jobDir, err); errors.Is(err, errJobInterrupted) {
+		return nil, err
+	}
+	timings.addStages(result)
+	job.Timings = timings
+	// The media of a failed job is kept too, so an admin can retry it
+	if opts.RetainAudio && !errors.Is(err, errJobCanceled) {
+		retainAudio(ctx, job, inputPath, opts)
+	}
+	return completeJob(ctx, job, opts, inputPath, result, err)
+}
+
+// prepareJob applies server-wide settings to a job's options and records who transcribes it
+func prepareJob(job *Job, opts JobOptions) JobOptions {
+	if appConfig.RedactAll {
+		opts.Redact = true
+	}
+	opts.Tenant = job.TenantID
+	opts.JobID = job.ID
+	job.StartSeconds = opts.Start
+	if opts.Provider != "" {
+		job.Provider = opts.Provider
+	}
+	if opts.Model != "" {
+		job.Model = opts.Model
+	}
+	return opts
+}
+
+// completeJob runs the post-processing steps the job asks for on a pipeline's result, such as
+// redaction, summaries, and subtitles, then records the outcome. inputPath is the job's media, or
+// "" when it has none, and canceled jobs aren't notified about
+func completeJob(ctx context.Context, job *Job, opts JobOptions, inputPath string, result *transcriber.Result, err error) (*transcriber.Result, error) {
+	if err == nil && opts.Hallucinations != "" {
+		job.HallucinatedSegments = filterHallucinations(ctx, result, opts.Hallucinations)
+		job.Hallucinations = opts.Hallucinations
+	}
+	if err == nil && opts.IdentifySpeakers && inputPath != "" {
+		identifySpeakers(ctx, job, opts, inputPath, result)
+	}
+	if err == nil && opts.AudioEvents {
+		tagAudioEvents(ctx, job, opts, inputPath, result)
+	}
+	if err == nil && opts.Redact {
+		if err = redactResult(ctx, result); err != nil {
+			result = nil
+		} else {
+			job.Redacted = true
+		}
+	}
+	if err == nil {
+		correctSpelling(job, result)
+	}
+	if err == nil && opts.Punctuation != "" {
+		punctuateResult(ctx, result, opts.Punctuation)
+		job.Punctuation = opts.Punctuation
+	}
+	if err == nil && opts.Sentiment {
+		analyzeSentiment(ctx, job, result)
+	}
+	if err == nil && opts.Summarize {
+		summarizeJob(ctx, job, result)
+	}
+	if err == nil && opts.Keywords {
+		job.Keywords = transcriber.ExtractKeywords(result.Segments, int(appConfig.KeywordLimit))
+	}
+	if err == nil && opts.Chapters {
+		job.Chapters = transcriber.DetectChapters(result.Segments, appConfig.ChapterMinLength)
+	}
+	if err == nil && opts.TranslateTo != "" {
+		translateJob(ctx, job, result, opts.TranslateTo)
+	}
+	if err == nil && opts.Subtitles != "" && inputPath != "" {
+		subtitleJobVideo(ctx, job, opts, inputPath, result)
+	}
+	if err == nil && opts.StoreResults {
+		storeJobResults(ctx, job, opts, result)
+	}
+	err = jobError(ctx, err)
+	finishJob(ctx, job, result, err)
+	if job.Status != JobStatusCanceled {
+		notifyByEmail(ctx, job, opts)
+		notifyChat(ctx, job, opts)
+		publishJob(ctx, job, opts)
+	}
+	return result, err
+}
+
+// retryAfterSeconds formats a duration for the Retry-After header, rounding up to whole seconds
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errHandlerPanicked fails the job of a request whose handler panicked
+var errHandlerPanicked = &pipelineError{Status: http.StatusInternalServerError, Message: "Transcription failed: internal server error"}
+
+// recoverPanics answers a request whose handler panicked with a 500 ErrorResponse, logging the
+// panic with its stack. The handler's deferred teardown has removed its job directory and given
+// back its worker slot by then; the job it was running, if any, is recorded as failed so it isn't
+// left processing
+func recoverPanics(c *gin.Context) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		// net/http uses this panic to drop a connection on purpose
+		if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			panic(recovered)
+		}
+
+		ctx := c.Request.Context()
+		loggerFrom(ctx).Error("Handler panicked", "panic", recovered, "stack", string(debug.Stack()))
+		if jobID := c.Writer.Header().Get(jobIDHeader); jobID != "" {
+			failPanickedJob(ctx, jobID)
+		}
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error"})
+	}()
+	c.Next()
+}
+
+// failPanickedJob records the job a panicked request was running as failed, unless it had already
+// finished
+func failPanickedJob(ctx context.Context, jobID string) {
+	job, err := jobStore.GetJob(jobID)
+	if err != nil {
+		loggerFrom(ctx).Error("Error loading job of panicked request", "error", err)
+		return
+	}
+	if job.Status == JobStatusProcessing {
+		finishJob(context.WithoutCancel(ctx), job, nil, errHandlerPanicked)
+	}
+}
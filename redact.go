@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// namesPrompt asks the chat model for the people named in a transcript
+const namesPrompt = "List the names of every person mentioned in the following transcript, " +
+	"including first names on their own. Reply with only a JSON array of strings, " +
+	"or [] if no one is named.\n\nTranscript:\n"
+
+// redactResult masks personal information in a finished transcript before it is stored or
+// returned. Names are only found when TRANSCRIBER_REDACT_NAMES is set; if that lookup fails the
+// job fails too, rather than keeping a transcript that may still hold names
+func redactResult(ctx context.Context, result *transcriber.Result) error {
+	var names []string
+	if appConfig.RedactNames && strings.TrimSpace(result.Transcription) != "" {
+		namesCtx, cancel := jobContext(ctx)
+		defer cancel()
+		found, err := detectNames(namesCtx, result.Transcription)
+		if err != nil {
+			return &pipelineError{Status: http.StatusBadGateway, Message: "Failed to find names to redact: " + err.Error()}
+		}
+		names = found
+	}
+
+	transcriber.Redact(result, names)
+	return nil
+}
+
+// detectNames asks the configured chat model which people are named in the transcript
+func detectNames(ctx context.Context, transcript string) ([]string, error) {
+	reply, err := chatCompletion(ctx, namesPrompt+transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	// Models sometimes wrap the array in prose or a code fence
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("unexpected reply from chat API: %q", reply)
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &names); err != nil {
+		return nil, fmt.Errorf("unexpected reply from chat API: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reloadableSettings are the environment variables a reload applies to the running server. The
+// rest are read once at startup and only change with a restart
+var reloadableSettings = []string{
+	"TRANSCRIBER_CORS_ORIGINS", "TRANSCRIBER_CORS_ORIGIN_PATTERN", "TRANSCRIBER_CORS_ALLOW_ALL",
+	"TRANSCRIBER_MAX_CONCURRENT_REQUESTS", "TRANSCRIBER_REQUESTS_PER_SECOND",
+	"GROQ_API_KEY", "OPENAI_API_KEY",
+	"TRANSCRIBER_PROVIDER", "TRANSCRIBER_MODEL", "TRANSCRIBER_ALLOWED_MODELS",
+}
+
+// ConfigReloadResponse lists the settings a reload found changed: those now in effect, and those
+// that need a restart to take effect
+type ConfigReloadResponse struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// reloadMu makes reloads take turns
+var reloadMu sync.Mutex
+
+// startEnv is the environment the process started with, which variables the config file stops
+// setting go back to, and fileSettings are the variables the file set when it was last read
+var (
+	startEnv     map[string]string
+	fileSettings []string
+)
+
+// loadConfigFile sets the variables in TRANSCRIBER_CONFIG_FILE over the environment, when it is
+// set. Variables an earlier read set that the file no longer does go back to their values from
+// the environment
+func loadConfigFile() error {
+	if startEnv == nil {
+		startEnv = environment()
+	}
+	path := startEnv["TRANSCRIBER_CONFIG_FILE"]
+	if path == "" {
+		return nil
+	}
+	settings, err := readEnvFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range fileSettings {
+		if _, ok := settings[key]; ok {
+			continue
+		}
+		if value, ok := startEnv[key]; ok {
+			os.Setenv(key, value)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+	fileSettings = fileSettings[:0]
+	for key, value := range settings {
+		os.Setenv(key, value)
+		fileSettings = append(fileSettings, key)
+	}
+	return nil
+}
+
+// readEnvFile reads NAME=value lines, as systemd's EnvironmentFile and Docker's --env-file take
+// them. Blank lines and lines starting with # are skipped, an export prefix is allowed, and
+// values may be wrapped in single or double quotes
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	settings := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		settings[key] = value
+	}
+	return settings, scanner.Err()
+}
+
+// environment returns the process's environment variables by name
+func environment() map[string]string {
+	env := map[string]string{}
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		env[key] = value
+	}
+	return env
+}
+
+// setEnvironment makes the process's environment variables env
+func setEnvironment(env map[string]string) {
+	for key := range environment() {
+		if _, ok := env[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+}
+
+// reloadConfig reads TRANSCRIBER_CONFIG_FILE again and applies the reloadable settings: the CORS
+// policy, the upstream request limits, the provider API keys, and the default provider, model,
+// and allowed models. Everything is checked before anything is applied, so an invalid setting
+// leaves the running configuration as it was. Jobs in flight carry on with the settings they
+// started with
+func reloadConfig(ctx context.Context) (ConfigReloadResponse, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	before := environment()
+	if err := loadConfigFile(); err != nil {
+		return ConfigReloadResponse{}, fmt.Errorf("unable to read config file: %w", err)
+	}
+	after := environment()
+	response := ConfigReloadResponse{Applied: []string{}, RestartRequired: []string{}}
+	keys := maps.Clone(before)
+	maps.Copy(keys, after)
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		value, ok := before[key]
+		if newValue, newOK := after[key]; ok == newOK && value == newValue {
+			continue
+		}
+		if slices.Contains(reloadableSettings, key) {
+			response.Applied = append(response.Applied, key)
+		} else {
+			response.RestartRequired = append(response.RestartRequired, key)
+		}
+	}
+
+	// The environment goes back as it was when the new settings are rejected, so they count as
+	// changed again on the next try
+	config := loadConfig()
+	corsCfg, err := corsConfig(config)
+	if err == nil {
+		err = reloadProviders(config)
+	}
+	if err != nil {
+		setEnvironment(before)
+		return ConfigReloadResponse{}, &pipelineError{Status: http.StatusBadRequest, Message: "Invalid configuration: " + err.Error()}
+	}
+	providersMu.Lock()
+	appConfig.CORSOrigins, appConfig.CORSOriginPattern, appConfig.CORSAllowAll = config.CORSOrigins, config.CORSOriginPattern, config.CORSAllowAll
+	providersMu.Unlock()
+	useCORSPolicy(corsCfg)
+
+	recordAudit(ctx, AuditEvent{Action: AuditConfigReloaded, Detail: strings.Join(response.Applied, ",")})
+	slog.Info("Configuration reloaded", "applied", response.Applied, "restart_required", response.RestartRequired)
+	return response, nil
+}
+
+// reloadOnSignal reloads the configuration whenever the process receives SIGHUP
+func reloadOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+		}
+		if _, err := reloadConfig(ctx); err != nil {
+			slog.Error("Unable to reload configuration; keeping the current one", "error", err)
+		}
+	}
+}
+
+// adminReloadConfig reloads the configuration, as SIGHUP does, and reports what changed
+func adminReloadConfig(c *gin.Context) {
+	response, err := reloadConfig(c.Request.Context())
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Unable to reload configuration", "error", err)
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestReloadWhileServing reloads the configuration while requests read the providers, the CORS
+// policy, and the summary key, so that go test -race catches settings written without a lock
+func TestReloadWhileServing(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "transcriber.env")
+	t.Setenv("TRANSCRIBER_CONFIG_FILE", configFile)
+	startEnv, fileSettings = nil, nil
+	t.Cleanup(func() {
+		for _, key := range fileSettings {
+			os.Unsetenv(key)
+		}
+		startEnv, fileSettings = nil, nil
+	})
+	useTestConfig(t)
+
+	store, err := openJobStore("sqlite", filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("openJobStore: %v", err)
+	}
+	defer store.Close()
+	jobStore = store
+	if err := initCORS(appConfig); err != nil {
+		t.Fatalf("initCORS: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handleCORS)
+	r.GET("/api/models", listModels)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				req := httptest.NewRequest(http.MethodGet, "/api/models", nil)
+				req.Header.Set("Origin", "http://localhost:5173")
+				recorder := httptest.NewRecorder()
+				r.ServeHTTP(recorder, req)
+				if recorder.Code != http.StatusOK && recorder.Code != http.StatusForbidden {
+					t.Errorf("GET /api/models: %d %s", recorder.Code, recorder.Body)
+					return
+				}
+				summaryAPIKey()
+			}
+		}()
+	}
+
+	models := []string{"whisper-large-v3", "whisper-large-v3-turbo"}
+	for i := range 20 {
+		settings := fmt.Sprintf("TRANSCRIBER_MODEL=%s\nGROQ_API_KEY=key-%d\nTRANSCRIBER_CORS_ORIGINS=http://localhost:%d\n", models[i%2], i, 5000+i)
+		if err := os.WriteFile(configFile, []byte(settings), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reloadConfig(context.Background()); err != nil {
+			t.Fatalf("reloadConfig: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if got := providersFor(nil).transcribers["groq"].Model(); got != models[1] {
+		t.Errorf("default model after reloads = %q, want %q", got, models[1])
+	}
+	if got := summaryAPIKey(); got != "key-19" {
+		t.Errorf("summary key after reloads = %q, want key-19", got)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// maxResultsURLExpiry is the longest S3 lets a presigned URL work for
+const maxResultsURLExpiry = 7 * 24 * time.Hour
+
+// resultFiles names the object each format is stored as, under the job's ID
+var resultFiles = map[string]string{
+	"text":     "transcript.txt",
+	"readable": "readable.txt",
+	"srt":      "transcript.srt",
+	"vtt":      "transcript.vtt",
+	"markdown": "transcript.md",
+	"lrc":      "transcript.lrc",
+	"words":    "words.json",
+	"chapters": "chapters.txt",
+	"docx":     "transcript.docx",
+	"pdf":      "transcript.pdf",
+}
+
+// errNoResultsBucket rejects store_results when there is nowhere to store them
+var errNoResultsBucket = &pipelineError{Status: http.StatusBadRequest, Message: "store_results is not available: this server has no TRANSCRIBER_RESULTS_BUCKET configured"}
+
+// checkResultsConfig validates the stored results settings at startup
+func checkResultsConfig(cfg Config) error {
+	if cfg.ResultsBucket == "" {
+		return nil
+	}
+	for _, format := range cfg.ResultsFormats {
+		if _, ok := resultFiles[format]; !ok {
+			return fmt.Errorf("unknown results format %q", format)
+		}
+	}
+	if cfg.ResultsURLExpiry <= 0 || cfg.ResultsURLExpiry > maxResultsURLExpiry {
+		return fmt.Errorf("results URL expiry must be between 1s and %s", maxResultsURLExpiry)
+	}
+	return nil
+}
+
+// resultsLocation splits TRANSCRIBER_RESULTS_BUCKET into the bucket and the prefix keys go under
+func resultsLocation() (bucket, prefix string) {
+	bucket, prefix, _ = strings.Cut(appConfig.ResultsBucket, "/")
+	return bucket, strings.Trim(prefix, "/")
+}
+
+// storeJobResults writes a finished transcript to the results bucket in each configured format,
+// filtered and flagged the way the job asks, and links the job to them with presigned URLs. Like
+// a summary, results that couldn't be stored are recorded on the job rather than failing it, and
+// the transcript is then returned in the response as usual
+func storeJobResults(ctx context.Context, job *Job, opts JobOptions, result *transcriber.Result) {
+	exported := *job
+	exported.Transcript = result.Transcription
+	exported.Segments = result.Segments
+	exported.Words = result.Words
+	exported.DurationSeconds = result.DurationSeconds
+	rendered := flagJob(filterJob(&exported, opts.ProfanityFilter), opts.MinConfidence)
+	rendered.Segments = transcriber.MarkLowConfidence(rendered.Segments)
+
+	storeCtx, cancel := jobContext(ctx)
+	defer cancel()
+	client, err := newS3Client(storeCtx)
+	if err != nil {
+		job.ResultsError = "Failed to store results: unable to configure S3 client: " + err.Error()
+		return
+	}
+	bucket, prefix := resultsLocation()
+	keys := map[string]string{}
+	for _, format := range appConfig.ResultsFormats {
+		if wordFormat(format) && len(rendered.Words) == 0 {
+			continue
+		}
+		body, contentType, err := renderResult(format, rendered)
+		if err != nil {
+			loggerFrom(ctx).Warn("Error rendering result", "format", format, "error", err)
+			job.ResultsError = fmt.Sprintf("Failed to store results: unable to render %s: %v", format, err)
+			return
+		}
+		key := path.Join(prefix, job.ID, resultFiles[format])
+		_, err = client.PutObject(storeCtx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String(contentType),
+		})
+		if err != nil {
+			loggerFrom(ctx).Warn("Error storing result", "format", format, "error", err)
+			job.ResultsError = fmt.Sprintf("Failed to store results: unable to upload %s: %v", format, err)
+			return
+		}
+		keys[format] = key
+	}
+	job.StoredResults = keys
+	job.ResultURLs = presignJobResults(ctx, job)
+}
+
+// renderResult renders a job in one of the result formats, with its Content-Type
+func renderResult(format string, job *Job) ([]byte, string, error) {
+	if contentType, ok := documentContentTypes[format]; ok {
+		document, err := renderDocument(format, job)
+		return document, contentType, err
+	}
+	return []byte(renderTranscript(format, job.Transcript, captionedSegments(format, job), job.Words)), formatContentTypes[format], nil
+}
+
+// presignJobResults returns presigned download URLs for a job's stored results by format, valid
+// for TRANSCRIBER_RESULTS_URL_EXPIRY, or nil when it has none or they can't be signed
+func presignJobResults(ctx context.Context, job *Job) map[string]string {
+	if len(job.StoredResults) == 0 {
+		return nil
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		loggerFrom(ctx).Error("Error configuring S3 client", "job_id", job.ID, "error", err)
+		return nil
+	}
+	bucket, _ := resultsLocation()
+	presigner := s3.NewPresignClient(client)
+	urls := map[string]string{}
+	for format, key := range job.StoredResults {
+		filename := documentFilename(job, strings.TrimPrefix(path.Ext(key), "."))
+		request, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket:                     aws.String(bucket),
+			Key:                        aws.String(key),
+			ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filename})),
+		}, s3.WithPresignExpires(appConfig.ResultsURLExpiry))
+		if err != nil {
+			loggerFrom(ctx).Error("Error presigning result URL", "job_id", job.ID, "format", format, "error", err)
+			return nil
+		}
+		urls[format] = request.URL
+	}
+	return urls
+}
+
+// linkedJob returns a copy of a job with its transcript, segments, and words left out in favor of
+// presigned URLs to its stored results. Jobs without stored results, or whose URLs can't be
+// signed, are returned as they are
+func linkedJob(ctx context.Context, job *Job) *Job {
+	urls := presignJobResults(ctx, job)
+	if urls == nil {
+		return job
+	}
+	linked := *job
+	linked.Transcript, linked.Segments, linked.Words = "", nil, nil
+	linked.ResultURLs = urls
+	return &linked
+}
+
+// removeStoredResults deletes a job's stored results from the results bucket
+func removeStoredResults(ctx context.Context, job *Job) {
+	if len(job.StoredResults) == 0 {
+		return
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		loggerFrom(ctx).Error("Error configuring S3 client", "job_id", job.ID, "error", err)
+		return
+	}
+	bucket, _ := resultsLocation()
+	for format, key := range job.StoredResults {
+		if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}); err != nil {
+			loggerFrom(ctx).Error("Error removing stored result", "job_id", job.ID, "format", format, "error", err)
+		}
+	}
+}
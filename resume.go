@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// errJobInterrupted is the error of a job stopped by shutdown that will run again, picking up
+// from its checkpointed chunks, once the server is back. It stays processing meanwhile
+var errJobInterrupted = &pipelineError{
+	Status:  http.StatusServiceUnavailable,
+	Message: "Transcription interrupted: server is shutting down, and the job resumes when it restarts",
+}
+
+// keptJobDirs holds the directories of interrupted jobs, which removeJobDir leaves in place for
+// the job to resume from
+var keptJobDirs sync.Map
+
+// jobCheckpoint checkpoints a job's chunks in the job store until the job finishes
+type jobCheckpoint struct {
+	jobID string
+}
+
+func (c jobCheckpoint) LoadChunks(key string) (map[int][]byte, error) {
+	return jobStore.LoadJobChunks(c.jobID, key)
+}
+
+func (c jobCheckpoint) SaveChunk(key string, index int, data []byte) error {
+	return jobStore.SaveJobChunk(c.jobID, key, index, data)
+}
+
+// markResumable records how to run a job again should the server stop before it finishes.
+// finishJob forgets it
+func markResumable(ctx context.Context, job *Job, jobDir, inputPath string, opts JobOptions) {
+	if !appConfig.ResumeJobs {
+		return
+	}
+	err := jobStore.SaveResumableJob(ResumableJob{JobID: job.ID, JobDir: jobDir, InputPath: inputPath, Options: opts, CreatedAt: job.CreatedAt})
+	if err != nil {
+		loggerFrom(ctx).Warn("Unable to record job as resumable", "error", err)
+	}
+}
+
+// interruptJob turns a pipeline error caused by shutdown into errJobInterrupted and keeps the
+// job's directory for it to resume from. Other errors are returned as they are
+func interruptJob(ctx context.Context, jobDir string, err error) error {
+	// The job's own context is only canceled by its client or a cancel request
+	if err == nil || !appConfig.ResumeJobs || jobsCtx.Err() == nil || ctx.Err() != nil {
+		return err
+	}
+	keptJobDirs.Store(jobDir, struct{}{})
+	loggerFrom(ctx).Info("Job interrupted by shutdown; it resumes when the server restarts")
+	return errJobInterrupted
+}
+
+// resumeInterruptedJobs runs the jobs a crash or shutdown interrupted again, in the background.
+// Without a shared queue, only jobs whose media is still in this instance's work directory can
+// be resumed; the others are recorded as failed
+func resumeInterruptedJobs() {
+	resumable, err := jobStore.ListResumableJobs()
+	if err != nil {
+		slog.Error("Unable to list interrupted jobs", "error", err)
+		return
+	}
+	for _, resumed := range resumable {
+		logger := slog.Default().With("job_id", resumed.JobID)
+		ctx := withLogger(context.Background(), logger)
+		job, err := jobStore.GetJob(resumed.JobID)
+		if err != nil || job.Status != JobStatusProcessing {
+			if err := jobStore.DeleteResumableJob(resumed.JobID); err != nil {
+				logger.Error("Error forgetting resumable job", "error", err)
+			}
+			continue
+		}
+		if _, err := os.Stat(resumed.InputPath); err != nil {
+			finishJob(ctx, job, nil, &pipelineError{Status: http.StatusInternalServerError, Message: "Transcription interrupted by a restart, and its media is gone"})
+			continue
+		}
+
+		adoptJobDir(resumed.JobDir)
+		logger.Info("Resuming interrupted job", "filename", job.Filename)
+		go func() {
+			defer removeJobDir(resumed.JobDir)
+			processJob(ctx, job, resumed.JobDir, resumed.InputPath, resumed.Options)
+		}()
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// errRetainEncrypted rejects retain_audio while temp files are encrypted, since the media's key
+// is gone once the job finishes
+var errRetainEncrypted = &pipelineError{Status: http.StatusBadRequest, Message: "retain_audio isn't available while TRANSCRIBER_ENCRYPT_TEMP_FILES is on"}
+
+// errRetainSharedQueue rejects retain_audio with a shared queue, since the media is kept by
+// whichever instance ran the job and a re-run may reach another one
+var errRetainSharedQueue = &pipelineError{Status: http.StatusBadRequest, Message: "retain_audio isn't available with a shared queue (TRANSCRIBER_REDIS_URL)"}
+
+// retainedAudioPath is where the media of a job submitted with retain_audio is kept. It's the
+// original upload rather than the preprocessed audio, since a re-run may pick another track,
+// range, speed, or filters, and subtitles are burned into the original video
+func retainedAudioPath(jobID string) string {
+	return filepath.Join(appConfig.AudioDir, jobID)
+}
+
+// linkOrCopy makes dst a hard link to src, or a copy when they are on different filesystems
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// retainAudio keeps a finished job's media after its directory is removed, along with the options
+// it ran with, so it can be transcribed again without another upload. A job whose media couldn't
+// be kept still completes
+func retainAudio(ctx context.Context, job *Job, inputPath string, opts JobOptions) {
+	if err := linkOrCopy(inputPath, retainedAudioPath(job.ID)); err != nil {
+		loggerFrom(ctx).Error("Unable to retain audio", "error", err)
+		return
+	}
+	job.AudioRetained = true
+	job.Options = &opts
+}
+
+// removeRetainedAudio deletes the media kept for a job, if any
+func removeRetainedAudio(jobID string) {
+	if err := os.Remove(retainedAudioPath(jobID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("Error removing retained audio", "job_id", jobID, "error", err)
+	}
+}
+
+// sweepRetainedAudio deletes the media kept for jobs that no longer exist, such as those the
+// retention period purged
+func sweepRetainedAudio() {
+	entries, err := os.ReadDir(appConfig.AudioDir)
+	if err != nil {
+		slog.Error("Unable to list retained audio", "dir", appConfig.AudioDir, "error", err)
+		return
+	}
+	for _, entry := range entries {
+		if _, err := jobStore.GetJob(entry.Name()); errors.Is(err, errJobNotFound) {
+			removeRetainedAudio(entry.Name())
+		}
+	}
+}
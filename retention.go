@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// runRetention purges jobs older than ttl, or their tenant's retention period, every interval until
+// ctx is canceled
+func runRetention(ctx context.Context, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeExpiredJobs(ttl)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retentionEnabled reports whether any jobs expire: the server's or at least one tenant's
+func retentionEnabled(ttl time.Duration) bool {
+	if ttl > 0 {
+		return true
+	}
+	for _, tenant := range tenantsByID {
+		if tenant.retentionTTL > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// purgeExpiredJobs deletes every finished job of a tenant created more than the tenant's retention
+// period ago, and every other finished job created more than ttl ago. Zero keeps jobs forever
+func purgeExpiredJobs(ttl time.Duration) {
+	now := time.Now().UTC()
+	var tenantIDs []string
+	for id, tenant := range tenantsByID {
+		tenantIDs = append(tenantIDs, id)
+		if tenant.retentionTTL > 0 {
+			cutoff := now.Add(-tenant.retentionTTL)
+			purged, err := jobStore.PurgeTenantJobsBefore(id, cutoff)
+			logPurge(purged, err, cutoff, "tenant", id)
+		}
+	}
+	if ttl > 0 {
+		cutoff := now.Add(-ttl)
+		purged, err := jobStore.PurgeJobsBefore(cutoff, tenantIDs...)
+		logPurge(purged, err, cutoff)
+	}
+	sweepRetainedAudio()
+	sweepSubtitledVideos()
+	if _, err := jobStore.PurgeOrphanedWebhookDeliveries(); err != nil {
+		slog.Error("Error purging webhook deliveries of deleted jobs", "error", err)
+	}
+}
+
+// logPurge reports the outcome of a purge, with attrs identifying whose jobs were purged
+func logPurge(purged int64, err error, cutoff time.Time, attrs ...any) {
+	if err != nil {
+		slog.Error("Error purging expired jobs", append(attrs, "error", err)...)
+		return
+	}
+	if purged > 0 {
+		slog.Info("Retention purged jobs", append(attrs, "count", purged, "cutoff", cutoff.Format(time.RFC3339))...)
+	}
+}
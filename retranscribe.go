@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"audio-transcriber/pkg/transcriber"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RetranscriptionRequest holds the settings a job's retained audio is transcribed again with.
+// Unset fields take the server's defaults, not the previous job's
+type RetranscriptionRequest struct {
+	Profile          string   `json:"profile"`
+	AudioTrack       string   `json:"audio_track"`
+	AudioLanguage    string   `json:"audio_language"`
+	Cache            *bool    `json:"cache"`
+	Normalize        bool     `json:"normalize"`
+	Denoise          bool     `json:"denoise"`
+	AudioFilters     string   `json:"audio_filters"`
+	SkipNonSpeech    bool     `json:"skip_non_speech"`
+	RemoveSilence    bool     `json:"remove_silence"`
+	Speed            float64  `json:"speed"`
+	Start            float64  `json:"start"`
+	End              float64  `json:"end"`
+	Provider         string   `json:"provider"`
+	Model            string   `json:"model"`
+	Temperature      *float64 `json:"temperature"`
+	Prompt           string   `json:"prompt"`
+	Language         string   `json:"language"`
+	CodeSwitching    bool     `json:"code_switching"`
+	SplitChannels    bool     `json:"split_channels"`
+	WordTimestamps   bool     `json:"word_timestamps"`
+	ChannelLabels    []string `json:"channel_labels"`
+	IdentifySpeakers bool     `json:"identify_speakers"`
+	AudioEvents      bool     `json:"audio_events"`
+	Summarize        bool     `json:"summarize"`
+	Sentiment        bool     `json:"sentiment"`
+	TranslateTo      string   `json:"translate_to"`
+	Keywords         bool     `json:"keywords"`
+	Chapters         bool     `json:"chapters"`
+	Punctuation      string   `json:"punctuation"`
+	Hallucinations   string   `json:"hallucinations"`
+	ProfanityFilter  string   `json:"profanity_filter"`
+	MinConfidence    float64  `json:"min_confidence"`
+	Priority         string   `json:"priority"`
+	RetainAudio      bool     `json:"retain_audio"`
+	Subtitles        string   `json:"subtitles"`
+	StoreResults     bool     `json:"store_results"`
+}
+
+// RetranscriptionResponse is the new job's transcript, with how it differs from the previous one
+type RetranscriptionResponse struct {
+	SuccessResponse
+	PreviousJobID string                     `json:"previous_job_id"`
+	Diff          transcriber.TranscriptDiff `json:"diff"`
+}
+
+// retranscribeTranscription transcribes the retained audio of a completed job again as a new job,
+// with other settings or another provider, and compares the new transcript with the stored one
+// word by word. The cache is skipped unless the request asks for it, since reusing the previous
+// transcript would compare it with itself
+func retranscribeTranscription(c *gin.Context) {
+	var request RetranscriptionRequest
+	if err := decodeWithProfile(c.Request.Body, &request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON"})
+		return
+	}
+	if request.Cache == nil {
+		request.Cache = new(bool)
+	}
+	opts, err := urlJobOptions(tenantFrom(c.Request.Context()), URLTranscriptionRequest{
+		Profile:          request.Profile,
+		AudioTrack:       request.AudioTrack,
+		AudioLanguage:    request.AudioLanguage,
+		Cache:            request.Cache,
+		Normalize:        request.Normalize,
+		Denoise:          request.Denoise,
+		AudioFilters:     request.AudioFilters,
+		SkipNonSpeech:    request.SkipNonSpeech,
+		RemoveSilence:    request.RemoveSilence,
+		Speed:            request.Speed,
+		Start:            request.Start,
+		End:              request.End,
+		Provider:         request.Provider,
+		Model:            request.Model,
+		Temperature:      request.Temperature,
+		Prompt:           request.Prompt,
+		Language:         request.Language,
+		CodeSwitching:    request.CodeSwitching,
+		SplitChannels:    request.SplitChannels,
+		WordTimestamps:   request.WordTimestamps,
+		ChannelLabels:    request.ChannelLabels,
+		IdentifySpeakers: request.IdentifySpeakers,
+		AudioEvents:      request.AudioEvents,
+		Summarize:        request.Summarize,
+		Sentiment:        request.Sentiment,
+		TranslateTo:      request.TranslateTo,
+		Keywords:         request.Keywords,
+		Chapters:         request.Chapters,
+		Punctuation:      request.Punctuation,
+		Hallucinations:   request.Hallucinations,
+		ProfanityFilter:  request.ProfanityFilter,
+		MinConfidence:    request.MinConfidence,
+		Priority:         request.Priority,
+		RetainAudio:      request.RetainAudio,
+		Subtitles:        request.Subtitles,
+		StoreResults:     request.StoreResults,
+	})
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	previous, err := getTenantJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	if previous.Status != JobStatusCompleted {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("Transcription is %s, not completed", previous.Status)})
+		return
+	}
+	audioPath := retainedAudioPath(previous.ID)
+	if _, err := os.Stat(audioPath); !previous.AudioRetained || err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription has no retained audio: submit it with retain_audio=true to transcribe it again"})
+		return
+	}
+
+	// A redacted transcript is only compared with another redacted one
+	opts.Redact = opts.Redact || previous.Redacted
+
+	release, err := admitJob(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer release()
+
+	job, jobDir, err := createJob(c.Request.Context(), &Job{ID: uuid.New().String(), Filename: previous.Filename})
+	if err != nil {
+		respondWithStartError(c, err)
+		return
+	}
+	tagJob(c, job.ID)
+	defer removeJobDir(jobDir)
+
+	inputPath := filepath.Join(jobDir, "retained-audio")
+	if err := linkOrCopy(audioPath, inputPath); err != nil {
+		failJob(c, job, &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to read retained audio"})
+		return
+	}
+	result, err := executeJob(c.Request.Context(), job, jobDir, inputPath, opts)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	response := successResponse(job, result, opts, nil)
+	c.JSON(http.StatusOK, RetranscriptionResponse{
+		SuccessResponse: response,
+		PreviousJobID:   previous.ID,
+		Diff:            transcriber.DiffTranscripts(filterJob(previous, opts.ProfanityFilter).Transcript, response.Transcription),
+	})
+}
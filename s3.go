@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3Store serves s3://bucket/key URIs. Credentials come from TRANSCRIBER_S3_ACCESS_KEY_ID and
+// TRANSCRIBER_S3_SECRET_ACCESS_KEY when set, otherwise from the standard AWS chain
+// (environment, shared config, or an attached IAM role)
+type s3Store struct{}
+
+func (s3Store) Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to configure S3 client: %w", err)
+	}
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, 0, fmt.Errorf("%w: %v", errObjectNotFound, err)
+		}
+		return nil, 0, err
+	}
+
+	size := int64(-1)
+	if output.ContentLength != nil {
+		size = *output.ContentLength
+	}
+
+	return output.Body, size, nil
+}
+
+func (s3Store) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure S3 client: %w", err)
+	}
+
+	var objects []ObjectInfo
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:     aws.ToString(object.Key),
+				Size:    aws.ToInt64(object.Size),
+				ModTime: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// newS3Client builds an S3 client from the server configuration
+func newS3Client(ctx context.Context) (*s3.Client, error) {
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if appConfig.S3Region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(appConfig.S3Region))
+	}
+	if appConfig.S3AccessKeyID != "" {
+		loadOptions = append(loadOptions, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(appConfig.S3AccessKeyID, appConfig.S3SecretAccessKey, ""),
+		))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		// Custom endpoints (MinIO, R2, etc.) generally need path-style addressing
+		if appConfig.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(appConfig.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
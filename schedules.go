@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// scheduleRunHistory is how many of a schedule's runs the admin API lists
+const scheduleRunHistory = 20
+
+// Schedule is a recurring run from TRANSCRIBER_SCHEDULES_FILE: on each tick of its cron
+// expression it either transcribes the episodes of an RSS feed that have no job yet, or the
+// objects under a storage prefix that have none at their current size and modification time
+type Schedule struct {
+	// Name identifies the schedule's jobs, which can be listed with ?schedule=
+	Name string `json:"name"`
+
+	// Cron is a five-field cron expression or a macro like @hourly
+	Cron string `json:"cron"`
+
+	// Timezone is the IANA zone Cron is read in; defaults to UTC
+	Timezone string `json:"timezone"`
+
+	// Feed is the RSS feed whose new episodes are transcribed
+	Feed string `json:"feed"`
+
+	// Prefix is an s3://, gs://, or azblob:// bucket and key prefix whose new objects are
+	// transcribed
+	Prefix string `json:"prefix"`
+
+	// Extensions are the extensions of the objects under Prefix that are transcribed
+	Extensions []string `json:"extensions"`
+
+	// Limit is the most jobs a run starts; defaults to TRANSCRIBER_MAX_BATCH_SIZE. The rest are
+	// left for later runs
+	Limit int `json:"limit"`
+
+	// Tenant is the tenant the schedule's jobs belong to
+	Tenant string `json:"tenant"`
+
+	// Options are those of a URL request, without url, applied to every job
+	Options URLTranscriptionRequest `json:"options"`
+
+	cron     *cronSchedule
+	location *time.Location
+	opts     JobOptions
+}
+
+// ScheduleStatus describes a schedule, when it next runs, and its most recent runs
+type ScheduleStatus struct {
+	Name     string        `json:"name"`
+	Cron     string        `json:"cron"`
+	Timezone string        `json:"timezone"`
+	Feed     string        `json:"feed,omitempty"`
+	Prefix   string        `json:"prefix,omitempty"`
+	Tenant   string        `json:"tenant,omitempty"`
+	NextRun  *time.Time    `json:"next_run,omitempty"`
+	Runs     []ScheduleRun `json:"runs"`
+}
+
+// ScheduleListResponse lists the configured schedules
+type ScheduleListResponse struct {
+	Schedules []ScheduleStatus `json:"schedules"`
+}
+
+// schedules are loaded once at startup from TRANSCRIBER_SCHEDULES_FILE
+var schedules []*Schedule
+
+// initSchedules loads and checks the schedules file. It must run after initTenants
+func initSchedules(cfg Config) error {
+	if cfg.SchedulesFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(cfg.SchedulesFile)
+	if err != nil {
+		return err
+	}
+
+	// Unknown fields are most likely misspelled options, which shouldn't silently be ignored
+	var loaded []*Schedule
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&loaded); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for i, schedule := range loaded {
+		if schedule.Name == "" {
+			return fmt.Errorf("schedule %d has no name", i+1)
+		}
+		if names[schedule.Name] {
+			return fmt.Errorf("schedule %s is defined twice", schedule.Name)
+		}
+		names[schedule.Name] = true
+		if err := checkSchedule(cfg, schedule); err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+	}
+	schedules = loaded
+	return nil
+}
+
+// checkSchedule validates a schedule and parses its cron expression, time zone, and options
+func checkSchedule(cfg Config, schedule *Schedule) error {
+	var err error
+	if schedule.cron, err = parseCron(schedule.Cron); err != nil {
+		return err
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if schedule.location, err = time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", schedule.Timezone)
+	}
+	if schedule.cron.next(time.Now().In(schedule.location)).IsZero() {
+		return fmt.Errorf("cron expression %q never runs", schedule.Cron)
+	}
+
+	if (schedule.Feed == "") == (schedule.Prefix == "") {
+		return errors.New("set either feed or prefix")
+	}
+	if schedule.Feed != "" {
+		if parsed, err := url.Parse(schedule.Feed); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("feed must be an absolute http or https URL")
+		}
+	}
+	if schedule.Prefix != "" {
+		parsed, err := url.Parse(schedule.Prefix)
+		if err != nil || objectStores[parsed.Scheme] == nil || parsed.Host == "" {
+			return errors.New("prefix must look like s3://bucket/prefix, gs://bucket/prefix, or azblob://container/prefix")
+		}
+	}
+	if len(schedule.Extensions) == 0 {
+		schedule.Extensions = mediaExtensions
+	}
+	schedule.Extensions = lowerList(schedule.Extensions)
+	if schedule.Limit == 0 {
+		schedule.Limit = int(cfg.MaxBatchSize)
+	}
+	if schedule.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+
+	if schedule.Tenant != "" && tenantsByID[schedule.Tenant] == nil {
+		return fmt.Errorf("unknown tenant %q", schedule.Tenant)
+	}
+	if schedule.Options.URL != "" {
+		return errors.New("options can't set url: it comes from the feed or prefix")
+	}
+	if schedule.opts, err = urlJobOptions(tenantsByID[schedule.Tenant], schedule.Options); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runSchedules runs each schedule on its cron expression until ctx is canceled
+func runSchedules(ctx context.Context) {
+	for _, schedule := range schedules {
+		go runSchedule(ctx, schedule)
+	}
+}
+
+// runSchedule waits for each of a schedule's runs and starts it. Runs due while the server was
+// down, or while an earlier run was still starting its jobs, are skipped. Instances sharing the
+// database each wait for the same runs, and whichever records a run first starts it
+func runSchedule(ctx context.Context, schedule *Schedule) {
+	logger := slog.With("schedule", schedule.Name)
+	for {
+		due := schedule.cron.next(time.Now().In(schedule.location))
+		if due.IsZero() {
+			logger.Warn("Schedule has no more runs")
+			return
+		}
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run := ScheduleRun{
+			Schedule:  schedule.Name,
+			DueAt:     due.UTC(),
+			StartedAt: time.Now().UTC(),
+			BatchID:   uuid.New().String(),
+		}
+		claimed, err := jobStore.ClaimScheduleRun(run)
+		if err != nil {
+			logger.Error("Unable to record schedule run", "error", err)
+			continue
+		}
+		if !claimed {
+			logger.Debug("Another instance started the run", "due_at", due)
+			continue
+		}
+
+		runCtx := withLogger(ctx, logger.With("batch_id", run.BatchID))
+		if schedule.Tenant != "" {
+			runCtx = withTenant(runCtx, tenantsByID[schedule.Tenant])
+		}
+		if schedule.Feed != "" {
+			err = runScheduledFeed(runCtx, schedule, &run)
+		} else {
+			err = runScheduledPrefix(runCtx, schedule, &run)
+		}
+		if err != nil {
+			run.Error = err.Error()
+			logger.Warn("Schedule run failed", "batch_id", run.BatchID, "error", err)
+		} else {
+			logger.Info("Schedule run started its jobs", "batch_id", run.BatchID, "jobs", run.Jobs, "skipped", run.Skipped)
+		}
+		if run.Jobs == 0 {
+			run.BatchID = ""
+		}
+		finishedAt := time.Now().UTC()
+		run.FinishedAt = &finishedAt
+		if err := jobStore.FinishScheduleRun(run); err != nil {
+			logger.Error("Unable to record schedule run", "error", err)
+		}
+	}
+}
+
+// runScheduledFeed starts a job, in the run's batch, for each episode of the schedule's feed
+// that has no job yet, newest first
+func runScheduledFeed(ctx context.Context, schedule *Schedule, run *ScheduleRun) error {
+	feed, err := fetchFeed(ctx, schedule.Feed)
+	if err != nil {
+		return err
+	}
+	done, err := jobStore.FeedEpisodeGUIDs(schedule.Tenant, schedule.Feed)
+	if err != nil {
+		return fmt.Errorf("unable to load earlier episodes: %w", err)
+	}
+
+	for _, item := range feed.Channel.Items {
+		if item.Enclosure.URL == "" {
+			continue
+		}
+		if done[item.guid()] {
+			run.Skipped++
+			continue
+		}
+		if run.Jobs == schedule.Limit {
+			break
+		}
+		filename := strings.TrimSpace(item.Title)
+		if filename == "" {
+			filename = item.Enclosure.URL
+		}
+		err := startScheduledJob(ctx, schedule, run, &Job{
+			ID:          uuid.New().String(),
+			Filename:    filename,
+			FeedURL:     schedule.Feed,
+			EpisodeGUID: item.guid(),
+		}, item.Enclosure.URL, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runScheduledPrefix starts a job, in the run's batch, for each object under the schedule's
+// prefix that has no job at its current size and modification time, oldest first. Objects are
+// tracked in the same ledger as watched directories
+func runScheduledPrefix(ctx context.Context, schedule *Schedule, run *ScheduleRun) error {
+	parsed, _ := url.Parse(schedule.Prefix)
+	listCtx, cancel := context.WithTimeout(ctx, appConfig.DownloadTimeout)
+	defer cancel()
+	objects, err := objectStores[parsed.Scheme].List(listCtx, parsed.Host, strings.TrimPrefix(parsed.Path, "/"))
+	if err != nil {
+		return fmt.Errorf("unable to list %s: %w", schedule.Prefix, err)
+	}
+	watched, err := jobStore.WatchedFiles(schedule.Prefix)
+	if err != nil {
+		return fmt.Errorf("unable to load earlier objects: %w", err)
+	}
+
+	slices.SortStableFunc(objects, func(a, b ObjectInfo) int {
+		return a.ModTime.Compare(b.ModTime)
+	})
+	for _, object := range objects {
+		if !slices.Contains(schedule.Extensions, strings.ToLower(strings.TrimPrefix(path.Ext(object.Key), "."))) {
+			continue
+		}
+		objectURL := (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/" + object.Key}).String()
+		if prior, ok := watched[objectURL]; ok && prior.Size == object.Size && prior.ModTime.Equal(object.ModTime) {
+			run.Skipped++
+			continue
+		}
+		if run.Jobs == schedule.Limit {
+			break
+		}
+		file := &WatchedFile{
+			Path:    objectURL,
+			Root:    schedule.Prefix,
+			Size:    object.Size,
+			ModTime: object.ModTime,
+		}
+		if err := startScheduledJob(ctx, schedule, run, &Job{ID: uuid.New().String(), Filename: objectURL}, objectURL, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startScheduledJob starts a job of a run in the background, once the queue has room for it.
+// An object's job is first claimed in the ledger, and skipped if another instance claimed it
+func startScheduledJob(ctx context.Context, schedule *Schedule, run *ScheduleRun, job *Job, mediaURL string, file *WatchedFile) error {
+	release, err := admitScheduledJob(ctx)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		file.JobID = job.ID
+		file.CreatedAt = time.Now()
+		claimed, err := jobStore.ClaimWatchedFile(*file)
+		if err != nil || !claimed {
+			release()
+			if err != nil {
+				return fmt.Errorf("unable to record %s: %w", file.Path, err)
+			}
+			run.Skipped++
+			return nil
+		}
+	}
+
+	job.BatchID = run.BatchID
+	job.Schedule = schedule.Name
+	job, jobDir, err := createJob(ctx, job)
+	if err != nil {
+		release()
+		if file != nil {
+			if err := jobStore.ReleaseWatchedFile(file.Path, file.JobID); err != nil {
+				loggerFrom(ctx).Error("Unable to release object", "error", err)
+			}
+		}
+		return fmt.Errorf("unable to start job: %w", err)
+	}
+	run.Jobs++
+
+	// Enclosures and objects are plain media files, so they are always downloaded directly, and
+	// the job outlives the run, which stops at shutdown
+	request := schedule.Options
+	request.URL = mediaURL
+	request.Ingest = ""
+	jobCtx := withLogger(context.WithoutCancel(ctx), loggerFrom(ctx).With("job_id", job.ID))
+	go runURLJob(jobCtx, job, jobDir, request, schedule.opts, release)
+	return nil
+}
+
+// admitScheduledJob waits for a place in the job queue until ctx is canceled. Unlike a full
+// queue, a tenant quota that's used up fails the run, since waiting could take until the next one
+func admitScheduledJob(ctx context.Context) (func(), error) {
+	for {
+		release, err := admitJob(ctx)
+		var pipelineErr *pipelineError
+		if err == nil || !errors.As(err, &pipelineErr) || pipelineErr.Status != http.StatusServiceUnavailable {
+			return release, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("stopped by shutdown")
+		case <-time.After(busAdmitInterval):
+		}
+	}
+}
+
+// adminSchedules lists the configured schedules, when each next runs, and its recent runs
+func adminSchedules(c *gin.Context) {
+	response := ScheduleListResponse{Schedules: []ScheduleStatus{}}
+	for _, schedule := range schedules {
+		runs, err := jobStore.ListScheduleRuns(schedule.Name, scheduleRunHistory)
+		if err != nil {
+			loggerFrom(c.Request.Context()).Error("Error listing schedule runs", "schedule", schedule.Name, "error", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list schedule runs"})
+			return
+		}
+		status := ScheduleStatus{
+			Name:     schedule.Name,
+			Cron:     schedule.Cron,
+			Timezone: schedule.Timezone,
+			Feed:     schedule.Feed,
+			Prefix:   schedule.Prefix,
+			Tenant:   schedule.Tenant,
+			Runs:     runs,
+		}
+		if next := schedule.cron.next(time.Now().In(schedule.location)); !next.IsZero() {
+			status.NextRun = &next
+		}
+		response.Schedules = append(response.Schedules, status)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// SearchResponse is a page of completed jobs whose transcripts match a search, best matches first
+type SearchResponse struct {
+	Query    string         `json:"query"`
+	Results  []SearchResult `json:"results"`
+	Total    int            `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+// SearchResult is a job whose transcript matched, with an excerpt highlighting the search terms
+// in <mark> tags and the segments they were said in
+type SearchResult struct {
+	JobID           string                `json:"job_id"`
+	Filename        string                `json:"filename"`
+	CreatedAt       time.Time             `json:"created_at"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Snippet         string                `json:"snippet"`
+	Segments        []transcriber.Segment `json:"segments"`
+}
+
+// searchTranscriptions finds the completed jobs whose transcripts contain every word of q
+func searchTranscriptions(c *gin.Context) {
+	terms := searchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "q must contain at least one word to search for"})
+		return
+	}
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "page must be a positive integer"})
+		return
+	}
+	pageSize, err := parsePositiveInt(c.Query("page_size"), defaultPageSize)
+	if err != nil || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("page_size must be between 1 and %d", maxPageSize)})
+		return
+	}
+	profanityMode, err := parseProfanityFilter(c.Query("profanity_filter"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	hits, total, err := jobStore.SearchJobs(SearchFilter{
+		TenantID: tenantIDFrom(c.Request.Context()),
+		Terms:    terms,
+		Limit:    pageSize,
+		Offset:   (page - 1) * pageSize,
+	})
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error searching transcripts", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to search transcriptions"})
+		return
+	}
+	recordAudit(c.Request.Context(), AuditEvent{Action: AuditTranscriptsSearched, Detail: c.Request.URL.RawQuery})
+
+	results := make([]SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = SearchResult{
+			JobID:           hit.Job.ID,
+			Filename:        hit.Job.Filename,
+			CreatedAt:       hit.Job.CreatedAt,
+			DurationSeconds: hit.Job.DurationSeconds,
+			Snippet:         profanityFilter.Apply(hit.Snippet, profanityMode),
+			Segments:        profanityFilter.ApplySegments(matchingSegments(hit.Job.Segments, terms), profanityMode),
+		}
+	}
+	c.JSON(http.StatusOK, SearchResponse{
+		Query:    strings.Join(terms, " "),
+		Results:  results,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// searchTerms splits a query into lowercase words, the way the search indexes split transcripts
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// matchingSegments returns the segments that contain any of the terms as a word
+func matchingSegments(segments []transcriber.Segment, terms []string) []transcriber.Segment {
+	matches := []transcriber.Segment{}
+	for _, segment := range segments {
+		words := searchTerms(segment.Text)
+		if slices.ContainsFunc(terms, func(term string) bool { return slices.Contains(words, term) }) {
+			matches = append(matches, segment)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// secretFetchTimeout bounds each read of the secret
+const secretFetchTimeout = 30 * time.Second
+
+// secretProviderFields names the field of the secret that holds each provider's API key
+var secretProviderFields = map[string]string{
+	"groq":   "groq_api_key",
+	"openai": "openai_api_key",
+}
+
+// summarySecretField is the field of the secret that holds the summary API key
+const summarySecretField = "summary_api_key"
+
+// SecretStore reads the secret holding the provider credentials out of a secrets manager
+type SecretStore interface {
+	// Fetch returns the string fields of the secret
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// currentSecret is the secret last read from the secrets manager, or nil without one
+var currentSecret atomic.Pointer[map[string]string]
+
+// newSecretStore returns the secrets manager TRANSCRIBER_SECRETS_BACKEND names, or nil when it is unset
+func newSecretStore(config Config) (SecretStore, error) {
+	switch config.SecretsBackend {
+	case "":
+		return nil, nil
+	case "vault":
+		if config.VaultAddr == "" || config.VaultToken == "" || config.VaultSecretPath == "" {
+			return nil, errors.New("vault needs VAULT_ADDR, VAULT_TOKEN, and TRANSCRIBER_VAULT_SECRET_PATH")
+		}
+		return vaultSecretStore{
+			addr:  strings.TrimSuffix(config.VaultAddr, "/"),
+			token: config.VaultToken,
+			path:  strings.Trim(config.VaultSecretPath, "/"),
+		}, nil
+	case "aws":
+		if config.AWSSecretID == "" {
+			return nil, errors.New("aws needs TRANSCRIBER_AWS_SECRET_ID")
+		}
+		return awsSecretStore{secretID: config.AWSSecretID, region: config.AWSSecretRegion}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q: expected vault or aws", config.SecretsBackend)
+	}
+}
+
+// fetchSecret reads the secret and makes it current, returning the names of the fields that
+// changed. Values are never logged or put in errors
+func fetchSecret(ctx context.Context, store SecretStore) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretFetchTimeout)
+	defer cancel()
+	secret, err := store.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous map[string]string
+	if current := currentSecret.Load(); current != nil {
+		previous = *current
+	}
+	var changed []string
+	for field := range maps.Keys(secret) {
+		if secret[field] != previous[field] {
+			changed = append(changed, field)
+		}
+	}
+	for field := range maps.Keys(previous) {
+		if _, ok := secret[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	slices.Sort(changed)
+	currentSecret.Store(&secret)
+	return changed, nil
+}
+
+// refreshSecrets reads the secret again every interval and moves the pipelines that use the
+// server's credentials onto keys that were rotated. A failed read keeps the current keys
+func refreshSecrets(ctx context.Context, store SecretStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := fetchSecret(ctx, store)
+		if err != nil {
+			slog.Error("Error refreshing secrets", "backend", appConfig.SecretsBackend, "error", err)
+			continue
+		}
+		if len(changed) > 0 {
+			rotateAPIKeys()
+			slog.Info("Secrets rotated", "backend", appConfig.SecretsBackend, "fields", changed)
+		}
+	}
+}
+
+// serverAPIKeys returns the server's API key for each provider: the secret's when it holds one,
+// otherwise the environment's
+func serverAPIKeys(config Config) map[string]string {
+	var secret map[string]string
+	if current := currentSecret.Load(); current != nil {
+		secret = *current
+	}
+	return map[string]string{
+		"groq":   cmp.Or(secret[secretProviderFields["groq"]], config.GroqAPIKey),
+		"openai": cmp.Or(secret[secretProviderFields["openai"]], config.OpenAIAPIKey),
+	}
+}
+
+// summaryAPIKey returns the key summary requests are sent with: the secret's summary key, then
+// TRANSCRIBER_SUMMARY_API_KEY, then the server's Groq key
+func summaryAPIKey() string {
+	var secret map[string]string
+	if current := currentSecret.Load(); current != nil {
+		secret = *current
+	}
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return cmp.Or(secret[summarySecretField], appConfig.SummaryAPIKey, serverAPIKeys(appConfig)["groq"])
+}
+
+// rotateAPIKeys points every pipeline that uses the server's credentials, including those of
+// tenants without their own, at the current keys. Providers that had no key before stay
+// unavailable until the configuration is reloaded
+func rotateAPIKeys() {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	keys := serverAPIKeys(appConfig)
+	serverProviders.setAPIKeys(keys)
+	for _, tenant := range tenantsByID {
+		tenant.providers.setAPIKeys(tenantAPIKeys(tenant, keys))
+	}
+}
+
+// vaultSecretStore reads a secret from HashiCorp Vault's HTTP API with a token, from a KV engine
+// of either version
+type vaultSecretStore struct {
+	addr  string
+	token string
+	path  string
+}
+
+func (s vaultSecretStore) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.addr+"/v1/"+s.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&vaultErr)
+		return nil, fmt.Errorf("vault: reading %s returned %s %s", s.path, resp.Status, strings.Join(vaultErr.Errors, "; "))
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: invalid response: %w", err)
+	}
+
+	// KV version 2 nests the secret's fields under data.data, next to its metadata
+	fields := body.Data
+	if nested, ok := fields["data"].(map[string]any); ok && fields["metadata"] != nil {
+		fields = nested
+	}
+	return stringFields(fields), nil
+}
+
+// awsSecretStore reads a secret whose value is a JSON object from AWS Secrets Manager
+type awsSecretStore struct {
+	secretID string
+	region   string
+}
+
+func (s awsSecretStore) Fetch(ctx context.Context) (map[string]string, error) {
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if s.region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(s.region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure AWS client: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, errors.New("aws: no region configured; set TRANSCRIBER_AWS_SECRET_REGION or AWS_REGION")
+	}
+	credentials, err := awsCfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+
+	// GetSecretValue is a signed JSON request to the regional Secrets Manager endpoint
+	payload, err := json.Marshal(map[string]string{"SecretId": s.secretID})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := "https://secretsmanager." + awsCfg.Region + ".amazonaws.com/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	payloadHash := sha256.Sum256(payload)
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]), "secretsmanager", awsCfg.Region, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+
+	resp, err := awsCfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&awsErr)
+		return nil, fmt.Errorf("aws: reading secret %s returned %s %s: %s", s.secretID, resp.Status, awsErr.Type, awsErr.Message)
+	}
+
+	var output struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
+		return nil, fmt.Errorf("aws: invalid response: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(output.SecretString), &fields); err != nil {
+		return nil, fmt.Errorf("aws: secret %s is not a JSON object of key/value pairs", s.secretID)
+	}
+	return stringFields(fields), nil
+}
+
+// stringFields keeps the non-empty string fields of a secret
+func stringFields(fields map[string]any) map[string]string {
+	secret := map[string]string{}
+	for field, value := range fields {
+		if value, ok := value.(string); ok && strings.TrimSpace(value) != "" {
+			secret[field] = strings.TrimSpace(value)
+		}
+	}
+	return secret
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// sentimentPrompt asks the chat model to rate each numbered line of a transcript
+const sentimentPrompt = "Rate the sentiment of each of the following numbered transcript lines as " +
+	"positive, neutral, or negative, with how strongly it comes across from 0 (mildly) to 1 (strongly), " +
+	"such as \"4. negative 0.8\" for an angry complaint. Judge each line as something said in a " +
+	"conversation. Reply with only the ratings, one per line, each starting with its number.\n\n"
+
+// analyzeSentiment rates the sentiment of each segment of the finished transcript with the chat
+// model. Segments the model leaves out are left unrated. Like a summary, a failed analysis is
+// recorded on the job rather than failing it
+func analyzeSentiment(ctx context.Context, job *Job, result *transcriber.Result) {
+	if strings.TrimSpace(result.Transcription) == "" {
+		return
+	}
+
+	sentimentCtx, cancel := jobContext(ctx)
+	defer cancel()
+	replies, err := chatLines(sentimentCtx, sentimentPrompt, segmentTexts(result.Segments))
+	if err == nil {
+		err = applySentiments(result.Segments, replies)
+	}
+	if err != nil {
+		loggerFrom(ctx).Warn("Error analyzing sentiment", "error", err)
+		job.SentimentError = "Failed to analyze sentiment: " + err.Error()
+	}
+}
+
+// applySentiments sets the sentiment of each segment from the chat model's rating of it, failing
+// when it rated none
+func applySentiments(segments []transcriber.Segment, replies []string) error {
+	rated := 0
+	for i, reply := range replies {
+		if sentiment := parseSentiment(reply); sentiment != nil {
+			segments[i].Sentiment = sentiment
+			rated++
+		}
+	}
+	if rated == 0 {
+		return errors.New("chat model rated none of the segments")
+	}
+	return nil
+}
+
+// parseSentiment reads a rating like "negative 0.8". A missing or unreadable intensity is taken
+// as 0.5, and one out of range is clamped to 0 to 1
+func parseSentiment(reply string) *transcriber.Sentiment {
+	fields := strings.Fields(strings.ToLower(strings.NewReplacer(",", " ", ":", " ", "(", " ", ")", " ").Replace(reply)))
+	if len(fields) == 0 {
+		return nil
+	}
+	label := transcriber.SentimentLabel(fields[0])
+	if label != transcriber.SentimentPositive && label != transcriber.SentimentNeutral && label != transcriber.SentimentNegative {
+		return nil
+	}
+	intensity := 0.5
+	if len(fields) > 1 {
+		if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			intensity = max(0, min(1, value))
+		}
+	}
+	return &transcriber.Sentiment{Label: label, Intensity: intensity}
+}
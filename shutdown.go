@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// jobsCtx is the parent context of every job; it is canceled when shutdown stops waiting for them
+var jobsCtx, cancelJobs = context.WithCancel(context.Background())
+
+// inFlightJobs counts jobs from createJobDir until removeJobDir
+var inFlightJobs sync.WaitGroup
+
+// jobCancelGrace is how long canceled jobs get to unwind and clean up before the process exits anyway
+const jobCancelGrace = 10 * time.Second
+
+// jobContext derives a context from parent that is also canceled when shutdown cancels in-flight jobs
+func jobContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(jobsCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// shutdown stops accepting new requests and waits up to timeout for in-flight jobs to finish.
+// Live streams are stopped first, so they wrap up with what they have and their event streams
+// close. Jobs still running after that are canceled and given jobCancelGrace to clean up
+func shutdown(srv *http.Server, grpcSrv *grpc.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stopLiveStreams()
+
+	// GracefulStop blocks until running RPCs return, so let it drain alongside HTTP
+	if grpcSrv != nil {
+		go grpcSrv.GracefulStop()
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("HTTP requests still running at shutdown timeout", "timeout", timeout, "error", err)
+	}
+
+	if !waitForJobs(ctx) {
+		slog.Warn("Canceling in-flight jobs", "timeout", timeout)
+		cancelJobs()
+
+		graceCtx, graceCancel := context.WithTimeout(context.Background(), jobCancelGrace)
+		defer graceCancel()
+		if !waitForJobs(graceCtx) {
+			slog.Error("Jobs did not stop; removing their scratch directories", "grace", jobCancelGrace)
+			removeActiveJobDirs()
+		}
+	}
+
+	if grpcSrv != nil {
+		grpcSrv.Stop()
+	}
+}
+
+// waitForJobs waits for every in-flight job to clean up, returning false if ctx expires first
+func waitForJobs(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		inFlightJobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// removeActiveJobDirs deletes the directories of jobs this instance still has in flight
+func removeActiveJobDirs() {
+	activeJobDirs.Range(func(key, _ any) bool {
+		jobDir := key.(string)
+		if err := os.RemoveAll(jobDir); err != nil {
+			slog.Error("Error removing job directory", "dir", jobDir, "error", err)
+		}
+		return true
+	})
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Limits on the audio speaker identification embeds. A voice sample's first
+// maxSpeakerSampleSeconds are enrolled, and a transcript's segments are each judged by their
+// first maxSpeakerClipSeconds, while shorter segments than minSpeakerClipSeconds say too little
+// about a voice to be judged at all
+const (
+	minSpeakerSampleSeconds = 3
+	maxSpeakerSampleSeconds = 60
+	minSpeakerClipSeconds   = 1
+	maxSpeakerClipSeconds   = 30
+)
+
+// maxSpeakerNameLength caps the name a speaker is enrolled, and segments labeled, with
+const maxSpeakerNameLength = 100
+
+// errNoSpeakerEmbedding is returned for speaker enrollment and identify_speakers when no
+// embedding service is configured
+var errNoSpeakerEmbedding = &pipelineError{Status: http.StatusBadRequest, Message: "Speaker identification is not available: this server has no TRANSCRIBER_SPEAKER_EMBEDDING_URL configured"}
+
+// speakerClient sends voice clips to the embedding service
+var speakerClient = &http.Client{
+	Timeout:   transcriber.DefaultRequestTimeout,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// speakerSamples serializes adding samples, which read and rewrite a speaker's mean embedding
+var speakerSamples sync.Mutex
+
+// SpeakerListResponse lists the speakers enrolled by the caller's tenant
+type SpeakerListResponse struct {
+	Speakers []*Speaker `json:"speakers"`
+}
+
+// embeddingResponse is the body the embedding service answers a clip with
+type embeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// embedVoice sends a WAV clip to the embedding service and returns its embedding, normalized to
+// unit length. The clip is decrypted with cipher when it is set
+func embedVoice(ctx context.Context, clipPath string, cipher *transcriber.FileCipher) ([]float64, error) {
+	var clip io.ReadCloser
+	var err error
+	if cipher != nil {
+		clip, err = cipher.Open(clipPath)
+	} else {
+		clip, err = os.Open(clipPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer clip.Close()
+	body, err := io.ReadAll(clip)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, appConfig.SpeakerEmbeddingURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "audio/wav")
+	resp, err := speakerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("embedding service returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	embedding := normalizeEmbedding(result.Embedding)
+	if embedding == nil {
+		return nil, errors.New("embedding service returned an empty embedding")
+	}
+	return embedding, nil
+}
+
+// normalizeEmbedding scales an embedding to unit length, or returns nil for one without length
+func normalizeEmbedding(embedding []float64) []float64 {
+	var sum float64
+	for _, value := range embedding {
+		sum += value * value
+	}
+	if sum == 0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return nil
+	}
+	norm := math.Sqrt(sum)
+	normalized := make([]float64, len(embedding))
+	for i, value := range embedding {
+		normalized[i] = value / norm
+	}
+	return normalized
+}
+
+// embeddingSimilarity is the cosine similarity of two embeddings, or 0 when they come from
+// models with different dimensions
+func embeddingSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// matchSpeaker returns the enrolled speaker whose voice an embedding is most similar to, or nil
+// when none reaches TRANSCRIBER_SPEAKER_THRESHOLD
+func matchSpeaker(speakers []*Speaker, embedding []float64) *Speaker {
+	var best *Speaker
+	bestSimilarity := appConfig.SpeakerThreshold
+	for _, speaker := range speakers {
+		if similarity := embeddingSimilarity(speaker.Embedding, embedding); similarity >= bestSimilarity {
+			best, bestSimilarity = speaker, similarity
+		}
+	}
+	return best
+}
+
+// identifySpeakers labels each segment of a result with the tenant's enrolled speaker whose
+// voice it sounds most like. Segments too short to judge, or like none of them, keep the label
+// they had, such as their channel's. Like a failed summary, a failure is recorded on the job
+// rather than failing it, since the transcript itself is still good
+func identifySpeakers(ctx context.Context, job *Job, opts JobOptions, inputPath string, result *transcriber.Result) {
+	speakers, err := jobStore.ListSpeakers(job.TenantID)
+	if err != nil {
+		loggerFrom(ctx).Error("Error loading enrolled speakers", "error", err)
+		job.SpeakersError = "Failed to load enrolled speakers"
+		return
+	}
+	if len(speakers) == 0 {
+		job.SpeakersError = "No speakers are enrolled to identify"
+		return
+	}
+
+	var spans []transcriber.ClipSpan
+	var indexes []int
+	for i, segment := range result.Segments {
+		if segment.End-segment.Start >= minSpeakerClipSeconds {
+			spans = append(spans, transcriber.ClipSpan{Start: segment.Start, End: min(segment.End, segment.Start+maxSpeakerClipSeconds)})
+			indexes = append(indexes, i)
+		}
+	}
+	if len(spans) == 0 {
+		return
+	}
+
+	identifyCtx, cancel := jobContext(ctx)
+	defer cancel()
+	clipDir, err := os.MkdirTemp(filepath.Dir(inputPath), "speakers-")
+	if err != nil {
+		job.SpeakersError = "Failed to identify speakers: " + err.Error()
+		return
+	}
+	defer os.RemoveAll(clipDir)
+
+	// The clips of an encrypted job are encrypted with its key, like the rest of its files
+	clipOpts := transcriber.ClipOptions{AudioTrack: opts.AudioTrack, AudioLanguage: opts.AudioLanguage}
+	if encryption := jobEncryptionFor(inputPath); encryption != nil {
+		clipOpts.Cipher = encryption.cipher
+	}
+	clips, err := transcriber.ExtractClips(identifyCtx, inputPath, clipDir, spans, clipOpts)
+	if err != nil {
+		loggerFrom(ctx).Warn("Error cutting segments for speaker identification", "error", err)
+		job.SpeakersError = "Failed to identify speakers: " + err.Error()
+		return
+	}
+
+	identified := 0
+	for i, clip := range clips {
+		embedding, err := embedVoice(identifyCtx, clip, clipOpts.Cipher)
+		if err != nil {
+			loggerFrom(ctx).Warn("Error identifying speakers", "error", err)
+			job.SpeakersError = "Failed to identify speakers: " + err.Error()
+			return
+		}
+		if speaker := matchSpeaker(speakers, embedding); speaker != nil {
+			result.Segments[indexes[i]].Speaker = speaker.Name
+			identified++
+		}
+	}
+	if identified > 0 {
+		result.Transcription = transcriber.JoinSegments(result.Segments)
+	}
+	loggerFrom(ctx).Info("Identified speakers", "segments", len(result.Segments), "judged", len(clips), "identified", identified)
+}
+
+// embedSpeakerSample embeds the voice in the first maxSpeakerSampleSeconds of an uploaded sample
+func embedSpeakerSample(ctx context.Context, inputPath, jobDir string) ([]float64, error) {
+	if err := checkMediaContainer(inputPath); err != nil {
+		return nil, err
+	}
+	if err := scanMedia(ctx, inputPath); err != nil {
+		return nil, err
+	}
+	clips, err := transcriber.ExtractClips(ctx, inputPath, jobDir, []transcriber.ClipSpan{{End: maxSpeakerSampleSeconds}}, transcriber.ClipOptions{})
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "File could not be read as audio: " + err.Error()}
+	}
+
+	// The clip is 16 kHz 16-bit mono WAV behind a 44-byte header
+	info, err := os.Stat(clips[0])
+	if err != nil {
+		return nil, err
+	}
+	if seconds := float64(info.Size()-44) / 32000; seconds < minSpeakerSampleSeconds {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Voice sample is %.1f seconds long: it must be at least %d", seconds, minSpeakerSampleSeconds)}
+	}
+	embedding, err := embedVoice(ctx, clips[0], nil)
+	if err != nil {
+		return nil, &pipelineError{Status: http.StatusBadGateway, Message: "Failed to embed voice sample: " + err.Error()}
+	}
+	return embedding, nil
+}
+
+// receiveSpeakerSample saves an uploaded voice sample into a scratch directory and embeds it,
+// returning the embedding along with the other form fields
+func receiveSpeakerSample(c *gin.Context) ([]float64, map[string]string, error) {
+	if appConfig.SpeakerEmbeddingURL == "" {
+		return nil, nil, errNoSpeakerEmbedding
+	}
+	jobDir, err := createJobDir(appConfig.WorkDir, uuid.New().String())
+	if err != nil {
+		return nil, nil, &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to create job directory"}
+	}
+	defer removeJobDir(jobDir)
+
+	inputPath, fields, err := receiveEstimateUpload(c, jobDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	embedding, err := embedSpeakerSample(c.Request.Context(), inputPath, jobDir)
+	return embedding, fields, err
+}
+
+// enrollSpeaker enrolls a named speaker from a voice sample (multipart: name and file), so jobs
+// with identify_speakers label the segments they speak with their name
+func enrollSpeaker(c *gin.Context) {
+	embedding, fields, err := receiveSpeakerSample(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	name := strings.TrimSpace(fields["name"])
+	if name == "" || utf8.RuneCountInString(name) > maxSpeakerNameLength {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("name is required and may be up to %d characters", maxSpeakerNameLength)})
+		return
+	}
+
+	tenantID := tenantIDFrom(c.Request.Context())
+	existing, err := jobStore.ListSpeakers(tenantID)
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading enrolled speakers", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load enrolled speakers"})
+		return
+	}
+	for _, speaker := range existing {
+		if strings.EqualFold(speaker.Name, name) {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("A speaker named %q is already enrolled: add samples to them with POST /api/speakers/%s/samples", speaker.Name, speaker.ID)})
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	speaker := &Speaker{
+		ID:        uuid.New().String(),
+		TenantID:  tenantID,
+		Name:      name,
+		Samples:   1,
+		Embedding: embedding,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := jobStore.SaveSpeaker(speaker); err != nil {
+		loggerFrom(c.Request.Context()).Error("Error saving speaker", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save speaker"})
+		return
+	}
+	c.JSON(http.StatusCreated, speaker)
+}
+
+// addSpeakerSample adds another voice sample (multipart: file) to an enrolled speaker, whose
+// embedding becomes the mean of all their samples', so they are recognized in more settings
+func addSpeakerSample(c *gin.Context) {
+	embedding, _, err := receiveSpeakerSample(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	speakerSamples.Lock()
+	defer speakerSamples.Unlock()
+	speaker, ok := getTenantSpeaker(c)
+	if !ok {
+		return
+	}
+	if len(speaker.Embedding) != len(embedding) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("The embedding service returned %d dimensions, but the speaker was enrolled with %d: enroll them again", len(embedding), len(speaker.Embedding))})
+		return
+	}
+	for i := range speaker.Embedding {
+		speaker.Embedding[i] = (speaker.Embedding[i]*float64(speaker.Samples) + embedding[i]) / float64(speaker.Samples+1)
+	}
+	speaker.Samples++
+	speaker.UpdatedAt = time.Now().UTC()
+	if err := jobStore.SaveSpeaker(speaker); err != nil {
+		loggerFrom(c.Request.Context()).Error("Error saving speaker", "speaker_id", speaker.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save speaker"})
+		return
+	}
+	c.JSON(http.StatusOK, speaker)
+}
+
+// listSpeakers lists the speakers the caller's tenant has enrolled
+func listSpeakers(c *gin.Context) {
+	speakers, err := jobStore.ListSpeakers(tenantIDFrom(c.Request.Context()))
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading enrolled speakers", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load enrolled speakers"})
+		return
+	}
+	c.JSON(http.StatusOK, SpeakerListResponse{Speakers: speakers})
+}
+
+// deleteSpeaker removes an enrolled speaker and their embedding. Transcripts already labeled
+// with their name keep it
+func deleteSpeaker(c *gin.Context) {
+	speaker, ok := getTenantSpeaker(c)
+	if !ok {
+		return
+	}
+	if err := jobStore.DeleteSpeaker(speaker.ID); err != nil && !errors.Is(err, errSpeakerNotFound) {
+		loggerFrom(c.Request.Context()).Error("Error deleting speaker", "speaker_id", speaker.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete speaker"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getTenantSpeaker loads the speaker named by the :id parameter, answering 404 when the caller's
+// tenant didn't enroll them. It reports false when it has answered
+func getTenantSpeaker(c *gin.Context) (*Speaker, bool) {
+	speaker, err := jobStore.GetSpeaker(c.Param("id"))
+	if errors.Is(err, errSpeakerNotFound) || (err == nil && speaker.TenantID != tenantIDFrom(c.Request.Context())) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Speaker not found"})
+		return nil, false
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading speaker", "speaker_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load speaker"})
+		return nil, false
+	}
+	return speaker, true
+}
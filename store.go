@@ -0,0 +1,1889 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Job statuses
+const (
+	JobStatusProcessing = "processing"
+	JobStatusCompleted  = "completed"
+	JobStatusFailed     = "failed"
+	JobStatusCanceled   = "canceled"
+)
+
+// errJobNotFound is returned when a job ID doesn't exist in the store
+var errJobNotFound = errors.New("job not found")
+
+// errWebhookDeliveryNotFound is returned when a webhook delivery ID doesn't exist in the store
+var errWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// Job is a single transcription request and its outcome
+type Job struct {
+	ID               string                   `json:"id"`
+	TenantID         string                   `json:"tenant_id,omitempty"`
+	Filename         string                   `json:"filename"`
+	Status           string                   `json:"status"`
+	Provider         string                   `json:"provider"`
+	Model            string                   `json:"model"`
+	DurationSeconds  float64                  `json:"duration_seconds"`
+	Transcript       string                   `json:"transcript,omitempty"`
+	Segments         []transcriber.Segment    `json:"segments,omitempty"`
+	Words            []transcriber.Word       `json:"words,omitempty"`
+	LowConfidence    int                      `json:"low_confidence_segments,omitempty"`
+	Stats            *transcriber.Stats       `json:"stats,omitempty"`
+	AudioHash        string                   `json:"audio_hash,omitempty"`
+	Chunks           int                      `json:"chunks"`
+	Skipped          []transcriber.NonSpeech  `json:"skipped,omitempty"`
+	SilenceRemoved   float64                  `json:"silence_removed_seconds,omitempty"`
+	StartSeconds     float64                  `json:"start_seconds,omitempty"`
+	EstimatedCost    float64                  `json:"estimated_cost_usd"`
+	Summary          string                   `json:"summary,omitempty"`
+	SummaryError     string                   `json:"summary_error,omitempty"`
+	Keywords         []transcriber.Keyword    `json:"keywords,omitempty"`
+	Chapters         []transcriber.Chapter    `json:"chapters,omitempty"`
+	Corrections      []transcriber.Correction `json:"corrections,omitempty"`
+	Redacted         bool                     `json:"redacted"`
+	Punctuation      string                   `json:"punctuation,omitempty"`
+	Hallucinations   string                   `json:"hallucinations,omitempty"`
+	BatchID          string                   `json:"batch_id,omitempty"`
+	FeedURL          string                   `json:"feed_url,omitempty"`
+	EpisodeGUID      string                   `json:"episode_guid,omitempty"`
+	CallSID          string                   `json:"call_sid,omitempty"`
+	Meeting          *Meeting                 `json:"meeting,omitempty"`
+	Schedule         string                   `json:"schedule,omitempty"`
+	Error            string                   `json:"error,omitempty"`
+	FailedStage      string                   `json:"failed_stage,omitempty"`
+	Timings          *JobTimings              `json:"timings,omitempty"`
+	AudioRetained    bool                     `json:"audio_retained,omitempty"`
+	Options          *JobOptions              `json:"-"`
+	SubtitledVideo   bool                     `json:"subtitled_video,omitempty"`
+	SubtitlesError   string                   `json:"subtitles_error,omitempty"`
+	Translation      *Translation             `json:"translation,omitempty"`
+	TranslationError string                   `json:"translation_error,omitempty"`
+	StoredResults    map[string]string        `json:"-"`
+	ResultsError     string                   `json:"results_error,omitempty"`
+	SpeakersError    string                   `json:"speakers_error,omitempty"`
+	SentimentError   string                   `json:"sentiment_error,omitempty"`
+	Events           []transcriber.AudioEvent `json:"events,omitempty"`
+	EventsError      string                   `json:"events_error,omitempty"`
+	Progress         *JobProgress             `json:"progress,omitempty"`
+	IdempotencyKey   string                   `json:"idempotency_key,omitempty"`
+	CreatedAt        time.Time                `json:"created_at"`
+	CompletedAt      *time.Time               `json:"completed_at,omitempty"`
+
+	// ResultURLs are presigned download URLs for StoredResults, the keys of the transcript's
+	// formats in the results bucket. They expire, so they are signed whenever the job is returned
+	// rather than stored
+	ResultURLs map[string]string `json:"result_urls,omitempty"`
+
+	// HallucinatedSegments counts the segments the hallucinations option flagged or removed when
+	// the job ran; it isn't stored, so it is only returned with the job's own response
+	HallucinatedSegments int `json:"-"`
+}
+
+// JobStore persists jobs in SQLite or Postgres
+type JobStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// jobStore is the store the server records jobs in
+var jobStore *JobStore
+
+// migration is a schema change, written once per dialect where they differ. An empty statement
+// leaves that dialect alone
+type migration struct {
+	sqlite   string
+	postgres string
+}
+
+// migrations are applied in order and tracked in schema_migrations, so only append to this list
+var migrations = []migration{
+	{
+		sqlite: `CREATE TABLE jobs (
+			id TEXT PRIMARY KEY,
+			filename TEXT NOT NULL,
+			status TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			duration_seconds REAL NOT NULL DEFAULT 0,
+			transcript TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			completed_at DATETIME
+		)`,
+		postgres: `CREATE TABLE jobs (
+			id TEXT PRIMARY KEY,
+			filename TEXT NOT NULL,
+			status TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			duration_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			transcript TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL,
+			completed_at TIMESTAMPTZ
+		)`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_created_at ON jobs (created_at)`,
+		postgres: `CREATE INDEX jobs_created_at ON jobs (created_at)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN segments TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN segments TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN audio_hash TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN audio_hash TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_audio_hash ON jobs (audio_hash)`,
+		postgres: `CREATE INDEX jobs_audio_hash ON jobs (audio_hash)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN chunks INTEGER NOT NULL DEFAULT 0`,
+		postgres: `ALTER TABLE jobs ADD COLUMN chunks INTEGER NOT NULL DEFAULT 0`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN estimated_cost_usd REAL NOT NULL DEFAULT 0`,
+		postgres: `ALTER TABLE jobs ADD COLUMN estimated_cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN summary TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN summary TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN summary_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN summary_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN keywords TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN keywords TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN redacted BOOLEAN NOT NULL DEFAULT FALSE`,
+		postgres: `ALTER TABLE jobs ADD COLUMN redacted BOOLEAN NOT NULL DEFAULT FALSE`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN batch_id TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN batch_id TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_batch_id ON jobs (batch_id)`,
+		postgres: `CREATE INDEX jobs_batch_id ON jobs (batch_id)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN feed_url TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN feed_url TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN episode_guid TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN episode_guid TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_feed_episode ON jobs (feed_url, episode_guid)`,
+		postgres: `CREATE INDEX jobs_feed_episode ON jobs (feed_url, episode_guid)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN failed_stage TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN failed_stage TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN tenant_id TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN tenant_id TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_tenant_created_at ON jobs (tenant_id, created_at)`,
+		postgres: `CREATE INDEX jobs_tenant_created_at ON jobs (tenant_id, created_at)`,
+	},
+	{
+		sqlite: `CREATE TABLE audit_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME NOT NULL,
+			action TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			job_id TEXT NOT NULL DEFAULT '',
+			client_ip TEXT NOT NULL DEFAULT '',
+			request_id TEXT NOT NULL DEFAULT '',
+			filename TEXT NOT NULL DEFAULT '',
+			provider TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			detail TEXT NOT NULL DEFAULT ''
+		)`,
+		postgres: `CREATE TABLE audit_events (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ NOT NULL,
+			action TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			job_id TEXT NOT NULL DEFAULT '',
+			client_ip TEXT NOT NULL DEFAULT '',
+			request_id TEXT NOT NULL DEFAULT '',
+			filename TEXT NOT NULL DEFAULT '',
+			provider TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			detail TEXT NOT NULL DEFAULT ''
+		)`,
+	},
+	{
+		sqlite:   `CREATE INDEX audit_events_job_id ON audit_events (job_id)`,
+		postgres: `CREATE INDEX audit_events_job_id ON audit_events (job_id)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN idempotency_key TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN idempotency_key TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE UNIQUE INDEX jobs_idempotency_key ON jobs (tenant_id, idempotency_key) WHERE idempotency_key <> ''`,
+		postgres: `CREATE UNIQUE INDEX jobs_idempotency_key ON jobs (tenant_id, idempotency_key) WHERE idempotency_key <> ''`,
+	},
+	// Full-text search: an FTS5 index over SQLite transcripts, kept in step with jobs by triggers,
+	// and an expression index over Postgres ones
+	{
+		sqlite:   `CREATE VIRTUAL TABLE jobs_search USING fts5(transcript, content='jobs', content_rowid='rowid')`,
+		postgres: `CREATE INDEX jobs_transcript_search ON jobs USING GIN (to_tsvector('simple', transcript))`,
+	},
+	{
+		sqlite: `CREATE TRIGGER jobs_search_insert AFTER INSERT ON jobs BEGIN
+			INSERT INTO jobs_search (rowid, transcript) VALUES (new.rowid, new.transcript);
+		END`,
+	},
+	{
+		sqlite: `CREATE TRIGGER jobs_search_delete AFTER DELETE ON jobs BEGIN
+			INSERT INTO jobs_search (jobs_search, rowid, transcript) VALUES ('delete', old.rowid, old.transcript);
+		END`,
+	},
+	{
+		sqlite: `CREATE TRIGGER jobs_search_update AFTER UPDATE OF transcript ON jobs BEGIN
+			INSERT INTO jobs_search (jobs_search, rowid, transcript) VALUES ('delete', old.rowid, old.transcript);
+			INSERT INTO jobs_search (rowid, transcript) VALUES (new.rowid, new.transcript);
+		END`,
+	},
+	{
+		sqlite: `INSERT INTO jobs_search (jobs_search) VALUES ('rebuild')`,
+	},
+	{
+		sqlite: `CREATE TABLE resumable_jobs (
+			job_id TEXT PRIMARY KEY,
+			job_dir TEXT NOT NULL,
+			input_path TEXT NOT NULL,
+			options TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		)`,
+		postgres: `CREATE TABLE resumable_jobs (
+			job_id TEXT PRIMARY KEY,
+			job_dir TEXT NOT NULL,
+			input_path TEXT NOT NULL,
+			options TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+	},
+	{
+		sqlite: `CREATE TABLE job_chunks (
+			job_id TEXT NOT NULL,
+			checkpoint_key TEXT NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (job_id, checkpoint_key, chunk_index)
+		)`,
+		postgres: `CREATE TABLE job_chunks (
+			job_id TEXT NOT NULL,
+			checkpoint_key TEXT NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (job_id, checkpoint_key, chunk_index)
+		)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN timings TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN timings TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN audio_retained BOOLEAN NOT NULL DEFAULT FALSE`,
+		postgres: `ALTER TABLE jobs ADD COLUMN audio_retained BOOLEAN NOT NULL DEFAULT FALSE`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN subtitled_video BOOLEAN NOT NULL DEFAULT FALSE`,
+		postgres: `ALTER TABLE jobs ADD COLUMN subtitled_video BOOLEAN NOT NULL DEFAULT FALSE`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN subtitles_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN subtitles_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN words TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN words TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN chapters TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN chapters TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN punctuation TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN punctuation TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN corrections TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN corrections TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN translation TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN translation TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN translation_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN translation_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite: `CREATE TABLE webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME NOT NULL,
+			job_id TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			service TEXT NOT NULL,
+			url TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL
+		)`,
+		postgres: `CREATE TABLE webhook_deliveries (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ NOT NULL,
+			job_id TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			service TEXT NOT NULL,
+			url TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMPTZ NOT NULL
+		)`,
+	},
+	{
+		sqlite:   `CREATE INDEX webhook_deliveries_status_next_attempt_at ON webhook_deliveries (status, next_attempt_at)`,
+		postgres: `CREATE INDEX webhook_deliveries_status_next_attempt_at ON webhook_deliveries (status, next_attempt_at)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN stored_results TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN stored_results TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN results_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN results_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN progress TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN progress TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN call_sid TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN call_sid TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_call_sid ON jobs (call_sid)`,
+		postgres: `CREATE INDEX jobs_call_sid ON jobs (call_sid)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN meeting TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN meeting TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite: `CREATE TABLE watched_files (
+			path TEXT PRIMARY KEY,
+			root TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			mod_time INTEGER NOT NULL,
+			job_id TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		)`,
+		postgres: `CREATE TABLE watched_files (
+			path TEXT PRIMARY KEY,
+			root TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			mod_time BIGINT NOT NULL,
+			job_id TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+	},
+	{
+		sqlite:   `CREATE INDEX watched_files_root ON watched_files (root)`,
+		postgres: `CREATE INDEX watched_files_root ON watched_files (root)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN schedule TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN schedule TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `CREATE INDEX jobs_schedule ON jobs (schedule)`,
+		postgres: `CREATE INDEX jobs_schedule ON jobs (schedule)`,
+	},
+	{
+		sqlite: `CREATE TABLE schedule_runs (
+			schedule TEXT NOT NULL,
+			due_at DATETIME NOT NULL,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME,
+			batch_id TEXT NOT NULL,
+			jobs INTEGER NOT NULL DEFAULT 0,
+			skipped INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (schedule, due_at)
+		)`,
+		postgres: `CREATE TABLE schedule_runs (
+			schedule TEXT NOT NULL,
+			due_at TIMESTAMPTZ NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ,
+			batch_id TEXT NOT NULL,
+			jobs INTEGER NOT NULL DEFAULT 0,
+			skipped INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (schedule, due_at)
+		)`,
+	},
+	{
+		sqlite: `CREATE TABLE speakers (
+			id TEXT PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL,
+			samples INTEGER NOT NULL,
+			embedding TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		postgres: `CREATE TABLE speakers (
+			id TEXT PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL,
+			samples INTEGER NOT NULL,
+			embedding TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		)`,
+	},
+	{
+		sqlite:   `CREATE INDEX speakers_tenant_id ON speakers (tenant_id)`,
+		postgres: `CREATE INDEX speakers_tenant_id ON speakers (tenant_id)`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN speakers_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN speakers_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN sentiment_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN sentiment_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN events TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN events TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN events_error TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN events_error TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN skipped TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN skipped TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN silence_removed_seconds REAL NOT NULL DEFAULT 0`,
+		postgres: `ALTER TABLE jobs ADD COLUMN silence_removed_seconds DOUBLE PRECISION NOT NULL DEFAULT 0`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN hallucinations TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN hallucinations TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN start_seconds REAL NOT NULL DEFAULT 0`,
+		postgres: `ALTER TABLE jobs ADD COLUMN start_seconds DOUBLE PRECISION NOT NULL DEFAULT 0`,
+	},
+	{
+		sqlite:   `ALTER TABLE jobs ADD COLUMN options TEXT NOT NULL DEFAULT ''`,
+		postgres: `ALTER TABLE jobs ADD COLUMN options TEXT NOT NULL DEFAULT ''`,
+	},
+}
+
+// openJobStore connects to the database and brings its schema up to date.
+// driver is "sqlite" or "postgres"
+func openJobStore(driver, dsn string) (*JobStore, error) {
+	if driver != "sqlite" && driver != "postgres" {
+		return nil, fmt.Errorf("unsupported database driver %q: expected sqlite or postgres", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time
+	if driver == "sqlite" {
+		db.SetMaxOpenConns(1)
+	}
+
+	store := &JobStore{db: db, driver: driver}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to migrate database: %w", err)
+	}
+
+	return store, nil
+}
+
+// Close releases the database connection
+func (s *JobStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping verifies the database connection is usable
+func (s *JobStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// migrate applies any migrations the database hasn't seen yet
+func (s *JobStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var applied int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		return err
+	}
+
+	for version := applied; version < len(migrations); version++ {
+		statement := migrations[version].sqlite
+		if s.driver == "postgres" {
+			statement = migrations[version].postgres
+		}
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if statement != "" {
+			if _, err := tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %w", version+1, err)
+			}
+		}
+		if _, err := tx.Exec(s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... for Postgres
+func (s *JobStore) rebind(query string) string {
+	if s.driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// CreateJob inserts a new job record
+func (s *JobStore) CreateJob(job *Job) error {
+	var meeting string
+	if job.Meeting != nil {
+		encoded, err := json.Marshal(job.Meeting)
+		if err != nil {
+			return err
+		}
+		meeting = string(encoded)
+	}
+	_, err := s.db.Exec(s.rebind(`
+		INSERT INTO jobs (id, tenant_id, filename, status, provider, model, duration_seconds, transcript, batch_id, feed_url, episode_guid, call_sid, meeting, schedule, idempotency_key, error, created_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		job.ID, job.TenantID, job.Filename, job.Status, job.Provider, job.Model, job.DurationSeconds,
+		job.Transcript, job.BatchID, job.FeedURL, job.EpisodeGUID, job.CallSID, meeting, job.Schedule, job.IdempotencyKey, job.Error, job.CreatedAt, job.CompletedAt,
+	)
+	return err
+}
+
+// UpdateJob saves the mutable fields of an existing job
+func (s *JobStore) UpdateJob(job *Job) error {
+	segments, err := encodeList(job.Segments)
+	if err != nil {
+		return err
+	}
+	words, err := encodeList(job.Words)
+	if err != nil {
+		return err
+	}
+	keywords, err := encodeList(job.Keywords)
+	if err != nil {
+		return err
+	}
+	chapters, err := encodeList(job.Chapters)
+	if err != nil {
+		return err
+	}
+	corrections, err := encodeList(job.Corrections)
+	if err != nil {
+		return err
+	}
+	events, err := encodeList(job.Events)
+	if err != nil {
+		return err
+	}
+	skipped, err := encodeList(job.Skipped)
+	if err != nil {
+		return err
+	}
+	var timings string
+	if job.Timings != nil {
+		encoded, err := json.Marshal(job.Timings)
+		if err != nil {
+			return err
+		}
+		timings = string(encoded)
+	}
+	var translation string
+	if job.Translation != nil {
+		encoded, err := json.Marshal(job.Translation)
+		if err != nil {
+			return err
+		}
+		translation = string(encoded)
+	}
+	var storedResults string
+	if len(job.StoredResults) > 0 {
+		encoded, err := json.Marshal(job.StoredResults)
+		if err != nil {
+			return err
+		}
+		storedResults = string(encoded)
+	}
+	var options string
+	if job.Options != nil {
+		encoded, err := json.Marshal(job.Options)
+		if err != nil {
+			return err
+		}
+		options = string(encoded)
+	}
+
+	_, err = s.db.Exec(s.rebind(`
+		UPDATE jobs
+		SET status = ?, provider = ?, model = ?, duration_seconds = ?, transcript = ?, segments = ?, words = ?, audio_hash = ?, chunks = ?, estimated_cost_usd = ?,
+			summary = ?, summary_error = ?, keywords = ?, chapters = ?, corrections = ?, redacted = ?, punctuation = ?, error = ?, failed_stage = ?, timings = ?, audio_retained = ?, options = ?, subtitled_video = ?, subtitles_error = ?, translation = ?, translation_error = ?, stored_results = ?, results_error = ?, speakers_error = ?, sentiment_error = ?, events = ?, events_error = ?, skipped = ?, silence_removed_seconds = ?, hallucinations = ?, start_seconds = ?, progress = '', completed_at = ?
+		WHERE id = ?`),
+		job.Status, job.Provider, job.Model, job.DurationSeconds, job.Transcript, segments, words, job.AudioHash, job.Chunks, job.EstimatedCost,
+		job.Summary, job.SummaryError, keywords, chapters, corrections, job.Redacted, job.Punctuation, job.Error, job.FailedStage, timings, job.AudioRetained, options, job.SubtitledVideo, job.SubtitlesError, translation, job.TranslationError, storedResults, job.ResultsError, job.SpeakersError, job.SentimentError, events, job.EventsError, skipped, job.SilenceRemoved, job.Hallucinations, job.StartSeconds, job.CompletedAt, job.ID,
+	)
+	return err
+}
+
+// SaveJobProgress records how far a job has got, unless it has already finished
+func (s *JobStore) SaveJobProgress(id string, progress *JobProgress) error {
+	encoded, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`UPDATE jobs SET progress = ? WHERE id = ? AND status = ?`), string(encoded), id, JobStatusProcessing)
+	return err
+}
+
+// SaveJobMeeting records the meeting a job's recording is of, once its participants are known
+func (s *JobStore) SaveJobMeeting(id string, meeting *Meeting) error {
+	encoded, err := json.Marshal(meeting)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`UPDATE jobs SET meeting = ? WHERE id = ?`), string(encoded), id)
+	return err
+}
+
+// UpdateTranscript saves a job's corrected transcript and segments
+func (s *JobStore) UpdateTranscript(job *Job) error {
+	segments, err := encodeList(job.Segments)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`UPDATE jobs SET transcript = ?, segments = ? WHERE id = ?`), job.Transcript, segments, job.ID)
+	return err
+}
+
+// GetJob loads a job by ID, returning errJobNotFound if it doesn't exist
+func (s *JobStore) GetJob(id string) (*Job, error) {
+	row := s.db.QueryRow(s.rebind(`
+		SELECT `+jobColumns+`
+		FROM jobs WHERE id = ?`), id)
+
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errJobNotFound
+	}
+	return job, err
+}
+
+// encodeList serializes segments, words, keywords, or chapters for their JSON column, storing nothing when there are none
+func encodeList[T any](items []T) (string, error) {
+	if len(items) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// jobColumns is the column list scanJob expects, in order
+const jobColumns = `id, filename, status, provider, model, duration_seconds, transcript, segments, words, audio_hash, chunks, estimated_cost_usd, summary, summary_error, keywords, chapters, corrections, redacted, punctuation, batch_id, feed_url, episode_guid, call_sid, meeting, schedule, error, failed_stage, timings, audio_retained, options, subtitled_video, subtitles_error, translation, translation_error, stored_results, results_error, speakers_error, sentiment_error, events, events_error, skipped, silence_removed_seconds, hallucinations, start_seconds, progress, tenant_id, idempotency_key, created_at, completed_at`
+
+// scanJob reads a job from a row selected with jobColumns
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var segments, words, keywords, chapters, corrections, events, skipped, meeting, timings, options, translation, storedResults, progress string
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.Filename, &job.Status, &job.Provider, &job.Model, &job.DurationSeconds,
+		&job.Transcript, &segments, &words, &job.AudioHash, &job.Chunks, &job.EstimatedCost, &job.Summary, &job.SummaryError, &keywords, &chapters, &corrections, &job.Redacted, &job.Punctuation, &job.BatchID, &job.FeedURL, &job.EpisodeGUID, &job.CallSID, &meeting, &job.Schedule, &job.Error, &job.FailedStage, &timings, &job.AudioRetained, &options, &job.SubtitledVideo, &job.SubtitlesError, &translation, &job.TranslationError, &storedResults, &job.ResultsError, &job.SpeakersError, &job.SentimentError, &events, &job.EventsError, &skipped, &job.SilenceRemoved, &job.Hallucinations, &job.StartSeconds, &progress, &job.TenantID, &job.IdempotencyKey, &job.CreatedAt, &completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if segments != "" {
+		if err := json.Unmarshal([]byte(segments), &job.Segments); err != nil {
+			return nil, fmt.Errorf("unable to decode segments for job %s: %w", job.ID, err)
+		}
+	}
+	if words != "" {
+		if err := json.Unmarshal([]byte(words), &job.Words); err != nil {
+			return nil, fmt.Errorf("unable to decode words for job %s: %w", job.ID, err)
+		}
+	}
+	if keywords != "" {
+		if err := json.Unmarshal([]byte(keywords), &job.Keywords); err != nil {
+			return nil, fmt.Errorf("unable to decode keywords for job %s: %w", job.ID, err)
+		}
+	}
+	if chapters != "" {
+		if err := json.Unmarshal([]byte(chapters), &job.Chapters); err != nil {
+			return nil, fmt.Errorf("unable to decode chapters for job %s: %w", job.ID, err)
+		}
+	}
+	if corrections != "" {
+		if err := json.Unmarshal([]byte(corrections), &job.Corrections); err != nil {
+			return nil, fmt.Errorf("unable to decode corrections for job %s: %w", job.ID, err)
+		}
+	}
+	if events != "" {
+		if err := json.Unmarshal([]byte(events), &job.Events); err != nil {
+			return nil, fmt.Errorf("unable to decode events for job %s: %w", job.ID, err)
+		}
+	}
+	if skipped != "" {
+		if err := json.Unmarshal([]byte(skipped), &job.Skipped); err != nil {
+			return nil, fmt.Errorf("unable to decode skipped stretches for job %s: %w", job.ID, err)
+		}
+	}
+	if meeting != "" {
+		if err := json.Unmarshal([]byte(meeting), &job.Meeting); err != nil {
+			return nil, fmt.Errorf("unable to decode meeting for job %s: %w", job.ID, err)
+		}
+	}
+	if timings != "" {
+		if err := json.Unmarshal([]byte(timings), &job.Timings); err != nil {
+			return nil, fmt.Errorf("unable to decode timings for job %s: %w", job.ID, err)
+		}
+	}
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &job.Options); err != nil {
+			return nil, fmt.Errorf("unable to decode options for job %s: %w", job.ID, err)
+		}
+	}
+	if translation != "" {
+		if err := json.Unmarshal([]byte(translation), &job.Translation); err != nil {
+			return nil, fmt.Errorf("unable to decode translation for job %s: %w", job.ID, err)
+		}
+	}
+	if storedResults != "" {
+		if err := json.Unmarshal([]byte(storedResults), &job.StoredResults); err != nil {
+			return nil, fmt.Errorf("unable to decode stored results for job %s: %w", job.ID, err)
+		}
+	}
+	if progress != "" {
+		if err := json.Unmarshal([]byte(progress), &job.Progress); err != nil {
+			return nil, fmt.Errorf("unable to decode progress for job %s: %w", job.ID, err)
+		}
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+// JobFilter narrows and orders the jobs returned by ListJobs
+type JobFilter struct {
+	TenantID    string
+	Status      string
+	From        time.Time
+	To          time.Time
+	Filename    string
+	BatchID     string
+	FeedURL     string
+	EpisodeGUID string
+	CallSID     string
+	Schedule    string
+	SortBy      string
+	Desc        bool
+	Limit       int
+	Offset      int
+}
+
+// listedJobColumns is jobColumns with the transcript and other large columns left empty, since
+// ListJobs doesn't return them
+var listedJobColumns = blankColumns(jobColumns, "transcript", "segments", "words", "summary", "keywords", "chapters", "corrections", "translation", "stored_results", "events")
+
+// blankColumns replaces the named columns of a column list with empty strings, keeping every
+// other column where scanJob expects it
+func blankColumns(columns string, blank ...string) string {
+	list := strings.Split(columns, ", ")
+	for i, column := range list {
+		for _, name := range blank {
+			if column == name {
+				list[i] = "''"
+			}
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// jobSortColumns are the columns ListJobs may order by
+var jobSortColumns = map[string]string{
+	"created_at":       "created_at",
+	"completed_at":     "completed_at",
+	"duration_seconds": "duration_seconds",
+	"filename":         "filename",
+	"status":           "status",
+}
+
+// ListJobs returns a page of jobs matching the filter, without their transcripts, along with
+// the total number of matching jobs
+func (s *JobStore) ListJobs(filter JobFilter) ([]*Job, int, error) {
+	var conditions []string
+	var args []any
+	if filter.TenantID != "" {
+		conditions = append(conditions, "tenant_id = ?")
+		args = append(args, filter.TenantID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To)
+	}
+	if filter.Filename != "" {
+		conditions = append(conditions, "LOWER(filename) LIKE ?")
+		args = append(args, "%"+strings.ToLower(filter.Filename)+"%")
+	}
+	if filter.BatchID != "" {
+		conditions = append(conditions, "batch_id = ?")
+		args = append(args, filter.BatchID)
+	}
+	if filter.FeedURL != "" {
+		conditions = append(conditions, "feed_url = ?")
+		args = append(args, filter.FeedURL)
+	}
+	if filter.EpisodeGUID != "" {
+		conditions = append(conditions, "episode_guid = ?")
+		args = append(args, filter.EpisodeGUID)
+	}
+	if filter.CallSID != "" {
+		conditions = append(conditions, "call_sid = ?")
+		args = append(args, filter.CallSID)
+	}
+	if filter.Schedule != "" {
+		conditions = append(conditions, "schedule = ?")
+		args = append(args, filter.Schedule)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM jobs `+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := jobSortColumns[filter.SortBy]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM jobs %s
+		ORDER BY %s %s, id %s
+		LIMIT ? OFFSET ?`, listedJobColumns, where, column, direction, direction)
+	rows, err := s.db.Query(s.rebind(query), append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, total, rows.Err()
+}
+
+// SearchFilter narrows a full-text search of completed transcripts to a page of one tenant's
+type SearchFilter struct {
+	TenantID string
+
+	// Terms are the words a transcript must all contain
+	Terms  []string
+	Limit  int
+	Offset int
+}
+
+// SearchHit is a job whose transcript matched a search, with an excerpt around the match in
+// which the terms are wrapped in <mark> tags
+type SearchHit struct {
+	Job     *Job
+	Snippet string
+}
+
+// SearchJobs returns a page of completed jobs whose transcripts contain every term, best matches
+// first, along with the total number of matching jobs
+func (s *JobStore) SearchJobs(filter SearchFilter) ([]SearchHit, int, error) {
+	conditions := []string{"status = 'completed'"}
+	var args []any
+	if filter.TenantID != "" {
+		conditions = append(conditions, "tenant_id = ?")
+		args = append(args, filter.TenantID)
+	}
+
+	// Postgres searches with its own parser; FTS5 gets each term quoted so none is read as syntax
+	var countQuery, query string
+	var searchArgs []any
+	if s.driver == "postgres" {
+		searchArgs = []any{strings.Join(filter.Terms, " ")}
+		countQuery = `SELECT COUNT(*) FROM jobs WHERE to_tsvector('simple', transcript) @@ plainto_tsquery('simple', ?) AND ` + strings.Join(conditions, " AND ")
+		query = `
+			SELECT ` + jobColumns + `, ts_headline('simple', transcript, search_query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=32, MinWords=12')
+			FROM jobs, plainto_tsquery('simple', ?) search_query
+			WHERE to_tsvector('simple', transcript) @@ search_query AND ` + strings.Join(conditions, " AND ") + `
+			ORDER BY ts_rank(to_tsvector('simple', transcript), search_query) DESC, created_at DESC
+			LIMIT ? OFFSET ?`
+	} else {
+		quoted := make([]string, len(filter.Terms))
+		for i, term := range filter.Terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		searchArgs = []any{strings.Join(quoted, " ")}
+		countQuery = `
+			SELECT COUNT(*) FROM jobs
+			WHERE rowid IN (SELECT rowid FROM jobs_search WHERE jobs_search MATCH ?) AND ` + strings.Join(conditions, " AND ")
+		query = `
+			WITH hits AS (
+				SELECT rowid AS hit_rowid, rank AS hit_rank, snippet(jobs_search, 0, '<mark>', '</mark>', '…', 24) AS hit_snippet
+				FROM jobs_search WHERE jobs_search MATCH ?
+			)
+			SELECT ` + jobColumns + `, hit_snippet
+			FROM jobs JOIN hits ON jobs.rowid = hits.hit_rowid
+			WHERE ` + strings.Join(conditions, " AND ") + `
+			ORDER BY hit_rank, created_at DESC
+			LIMIT ? OFFSET ?`
+	}
+
+	var total int
+	if err := s.db.QueryRow(s.rebind(countQuery), append(searchArgs, args...)...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(s.rebind(query), append(append(searchArgs, args...), filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		hit.Job, err = scanJob(extraColumns{row: rows, dest: []any{&hit.Snippet}})
+		if err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, total, rows.Err()
+}
+
+// extraColumns scans the columns selected after jobColumns into dest
+type extraColumns struct {
+	row  rowScanner
+	dest []any
+}
+
+func (r extraColumns) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.dest...)...)
+}
+
+// DeleteJob removes a job and its transcript, along with any webhook deliveries about it that
+// haven't gone through, returning errJobNotFound if it doesn't exist
+func (s *JobStore) DeleteJob(id string) error {
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM webhook_deliveries WHERE job_id = ?`), id); err != nil {
+		return err
+	}
+	result, err := s.db.Exec(s.rebind(`DELETE FROM jobs WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errJobNotFound
+	}
+	return nil
+}
+
+// PurgeJobsBefore deletes finished jobs created before cutoff, except those of the given
+// tenants, and returns how many were removed
+func (s *JobStore) PurgeJobsBefore(cutoff time.Time, exceptTenants ...string) (int64, error) {
+	query := `DELETE FROM jobs WHERE created_at < ? AND status <> ?`
+	args := []any{cutoff, JobStatusProcessing}
+	if len(exceptTenants) > 0 {
+		query += ` AND tenant_id NOT IN (?` + strings.Repeat(", ?", len(exceptTenants)-1) + `)`
+		for _, tenantID := range exceptTenants {
+			args = append(args, tenantID)
+		}
+	}
+	result, err := s.db.Exec(s.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeTenantJobsBefore deletes a tenant's finished jobs created before cutoff and returns how
+// many were removed
+func (s *JobStore) PurgeTenantJobsBefore(tenantID string, cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec(s.rebind(`DELETE FROM jobs WHERE tenant_id = ? AND created_at < ? AND status <> ?`), tenantID, cutoff, JobStatusProcessing)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AudioSecondsSince totals the audio of a tenant's completed jobs created since the given time
+func (s *JobStore) AudioSecondsSince(tenantID string, since time.Time) (float64, error) {
+	var seconds float64
+	err := s.db.QueryRow(s.rebind(`
+		SELECT COALESCE(SUM(duration_seconds), 0)
+		FROM jobs
+		WHERE tenant_id = ? AND created_at >= ? AND status = ?`), tenantID, since, JobStatusCompleted).Scan(&seconds)
+	return seconds, err
+}
+
+// FeedEpisodeGUIDs returns the GUIDs of a feed's episodes that already have a job of the tenant
+// which hasn't failed, so they can be skipped when only new episodes are wanted
+func (s *JobStore) FeedEpisodeGUIDs(tenantID, feedURL string) (map[string]bool, error) {
+	rows, err := s.db.Query(s.rebind(`
+		SELECT DISTINCT episode_guid
+		FROM jobs
+		WHERE tenant_id = ? AND feed_url = ? AND status <> ?`), tenantID, feedURL, JobStatusFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guids := map[string]bool{}
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, err
+		}
+		guids[guid] = true
+	}
+	return guids, rows.Err()
+}
+
+// JobStats summarizes the jobs created over a period
+type JobStats struct {
+	Jobs       int `json:"jobs"`
+	Processing int `json:"processing"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+	Canceled   int `json:"canceled"`
+
+	// FailuresByStage counts failed jobs by the pipeline stage that failed. Jobs that failed
+	// outside the pipeline, such as in an upload or download, or were canceled are counted as other
+	FailuresByStage map[string]int `json:"failures_by_stage"`
+
+	// AudioSeconds is the total audio transcribed by completed jobs
+	AudioSeconds float64 `json:"audio_seconds"`
+
+	// AverageAudioSeconds and AverageProcessingSeconds are the mean audio length and time from
+	// start to finish of completed jobs
+	AverageAudioSeconds      float64 `json:"average_audio_seconds"`
+	AverageProcessingSeconds float64 `json:"average_processing_seconds"`
+}
+
+// JobStats summarizes the jobs created since each cutoff, in one pass over the jobs since the
+// earliest of them. A zero cutoff covers every job
+func (s *JobStore) JobStats(cutoffs ...time.Time) ([]JobStats, error) {
+	stats := make([]JobStats, len(cutoffs))
+	earliest := time.Now()
+	for i, cutoff := range cutoffs {
+		stats[i].FailuresByStage = map[string]int{}
+		if cutoff.Before(earliest) {
+			earliest = cutoff
+		}
+	}
+
+	// Durations are worked out here rather than in SQL, which has no date arithmetic common to
+	// both databases
+	rows, err := s.db.Query(s.rebind(`
+		SELECT status, failed_stage, duration_seconds, created_at, completed_at
+		FROM jobs
+		WHERE created_at >= ?`), earliest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	processingSeconds := make([]float64, len(cutoffs))
+	for rows.Next() {
+		var status, stage string
+		var duration float64
+		var createdAt time.Time
+		var completedAt sql.NullTime
+		if err := rows.Scan(&status, &stage, &duration, &createdAt, &completedAt); err != nil {
+			return nil, err
+		}
+		if stage == "" {
+			stage = "other"
+		}
+		for i, cutoff := range cutoffs {
+			if createdAt.Before(cutoff) {
+				continue
+			}
+			stats[i].Jobs++
+			switch status {
+			case JobStatusProcessing:
+				stats[i].Processing++
+			case JobStatusFailed:
+				stats[i].Failed++
+				stats[i].FailuresByStage[stage]++
+			case JobStatusCanceled:
+				stats[i].Canceled++
+			case JobStatusCompleted:
+				stats[i].Completed++
+				stats[i].AudioSeconds += duration
+				if completedAt.Valid {
+					processingSeconds[i] += completedAt.Time.Sub(createdAt).Seconds()
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range stats {
+		if stats[i].Completed > 0 {
+			stats[i].AverageAudioSeconds = stats[i].AudioSeconds / float64(stats[i].Completed)
+			stats[i].AverageProcessingSeconds = processingSeconds[i] / float64(stats[i].Completed)
+		}
+	}
+	return stats, nil
+}
+
+// ThroughputBucket counts the jobs that finished in one interval
+type ThroughputBucket struct {
+	Start     time.Time `json:"start"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+	Canceled  int       `json:"canceled"`
+
+	// AudioSeconds is the audio transcribed by the jobs that completed
+	AudioSeconds float64 `json:"audio_seconds"`
+}
+
+// JobThroughput counts the jobs that finished from since until now in intervals of bucket, oldest
+// first, with an entry for every interval whether or not any job finished in it
+func (s *JobStore) JobThroughput(since time.Time, bucket time.Duration) ([]ThroughputBucket, error) {
+	since = since.UTC().Truncate(bucket)
+	buckets := make([]ThroughputBucket, int(time.Since(since)/bucket)+1)
+	for i := range buckets {
+		buckets[i].Start = since.Add(time.Duration(i) * bucket)
+	}
+
+	rows, err := s.db.Query(s.rebind(`
+		SELECT status, duration_seconds, completed_at
+		FROM jobs
+		WHERE completed_at >= ?`), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var duration float64
+		var completedAt time.Time
+		if err := rows.Scan(&status, &duration, &completedAt); err != nil {
+			return nil, err
+		}
+		i := int(completedAt.Sub(since) / bucket)
+		if i < 0 || i >= len(buckets) {
+			continue
+		}
+		switch status {
+		case JobStatusCompleted:
+			buckets[i].Completed++
+			buckets[i].AudioSeconds += duration
+		case JobStatusFailed:
+			buckets[i].Failed++
+		case JobStatusCanceled:
+			buckets[i].Canceled++
+		}
+	}
+	return buckets, rows.Err()
+}
+
+// FindJobByIdempotencyKey returns the tenant's job created with an idempotency key, or
+// errJobNotFound if there isn't one
+func (s *JobStore) FindJobByIdempotencyKey(tenantID, key string) (*Job, error) {
+	row := s.db.QueryRow(s.rebind(`
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE tenant_id = ? AND idempotency_key = ?`), tenantID, key)
+
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errJobNotFound
+	}
+	return job, err
+}
+
+// ClearIdempotencyKey frees a job's idempotency key for a new job
+func (s *JobStore) ClearIdempotencyKey(id string) error {
+	_, err := s.db.Exec(s.rebind(`UPDATE jobs SET idempotency_key = '' WHERE id = ?`), id)
+	return err
+}
+
+// ResumableJob is what a job needs to be run again if the server stops in the middle of it
+type ResumableJob struct {
+	JobID     string
+	JobDir    string
+	InputPath string
+	Options   JobOptions
+	CreatedAt time.Time
+}
+
+// SaveResumableJob records how to run a job again, replacing what was recorded before
+func (s *JobStore) SaveResumableJob(job ResumableJob) error {
+	options, err := json.Marshal(job.Options)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`
+		INSERT INTO resumable_jobs (job_id, job_dir, input_path, options, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (job_id) DO UPDATE SET job_dir = excluded.job_dir, input_path = excluded.input_path, options = excluded.options`),
+		job.JobID, job.JobDir, job.InputPath, string(options), job.CreatedAt,
+	)
+	return err
+}
+
+// ListResumableJobs returns every job recorded as resumable, oldest first
+func (s *JobStore) ListResumableJobs() ([]ResumableJob, error) {
+	rows, err := s.db.Query(`SELECT job_id, job_dir, input_path, options, created_at FROM resumable_jobs ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []ResumableJob
+	for rows.Next() {
+		var job ResumableJob
+		var options string
+		if err := rows.Scan(&job.JobID, &job.JobDir, &job.InputPath, &options, &job.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &job.Options); err != nil {
+			return nil, fmt.Errorf("decoding options of job %s: %w", job.JobID, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteResumableJob forgets how to run a job again, along with its chunk checkpoints
+func (s *JobStore) DeleteResumableJob(jobID string) error {
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM job_chunks WHERE job_id = ?`), jobID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(s.rebind(`DELETE FROM resumable_jobs WHERE job_id = ?`), jobID)
+	return err
+}
+
+// LoadJobChunks returns the chunk transcripts a job checkpointed under key, by chunk index
+func (s *JobStore) LoadJobChunks(jobID, key string) (map[int][]byte, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT chunk_index, data FROM job_chunks WHERE job_id = ? AND checkpoint_key = ?`), jobID, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := map[int][]byte{}
+	for rows.Next() {
+		var index int
+		var data string
+		if err := rows.Scan(&index, &data); err != nil {
+			return nil, err
+		}
+		chunks[index] = []byte(data)
+	}
+	return chunks, rows.Err()
+}
+
+// SaveJobChunk checkpoints the transcript of one of a job's chunks under key
+func (s *JobStore) SaveJobChunk(jobID, key string, index int, data []byte) error {
+	_, err := s.db.Exec(s.rebind(`
+		INSERT INTO job_chunks (job_id, checkpoint_key, chunk_index, data)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (job_id, checkpoint_key, chunk_index) DO UPDATE SET data = excluded.data`),
+		jobID, key, index, string(data),
+	)
+	return err
+}
+
+// RecordAuditEvent appends an event to the audit trail. Events are never updated or deleted,
+// not even by retention
+func (s *JobStore) RecordAuditEvent(event *AuditEvent) error {
+	_, err := s.db.Exec(s.rebind(`
+		INSERT INTO audit_events (created_at, action, tenant_id, job_id, client_ip, request_id, filename, provider, model, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		event.CreatedAt, event.Action, event.TenantID, event.JobID, event.ClientIP, event.RequestID,
+		event.Filename, event.Provider, event.Model, event.Detail,
+	)
+	return err
+}
+
+// AuditFilter narrows the events returned by ListAuditEvents
+type AuditFilter struct {
+	Action   string
+	TenantID string
+	JobID    string
+	ClientIP string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// ListAuditEvents returns a page of audit events matching the filter, newest first, along with
+// the total number of matching events
+func (s *JobStore) ListAuditEvents(filter AuditFilter) ([]*AuditEvent, int, error) {
+	var conditions []string
+	var args []any
+	for _, field := range []struct{ column, value string }{
+		{"action", filter.Action},
+		{"tenant_id", filter.TenantID},
+		{"job_id", filter.JobID},
+		{"client_ip", filter.ClientIP},
+	} {
+		if field.value != "" {
+			conditions = append(conditions, field.column+" = ?")
+			args = append(args, field.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM audit_events `+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(s.rebind(`
+		SELECT id, created_at, action, tenant_id, job_id, client_ip, request_id, filename, provider, model, detail
+		FROM audit_events `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`), append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		if err := rows.Scan(&event.ID, &event.CreatedAt, &event.Action, &event.TenantID, &event.JobID, &event.ClientIP,
+			&event.RequestID, &event.Filename, &event.Provider, &event.Model, &event.Detail); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, &event)
+	}
+	return events, total, rows.Err()
+}
+
+// AddWebhookDelivery records a webhook post that didn't go through, setting its ID
+func (s *JobStore) AddWebhookDelivery(delivery *WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (created_at, job_id, tenant_id, service, url, payload, status, attempts, last_error, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	args := []any{
+		delivery.CreatedAt, delivery.JobID, delivery.TenantID, delivery.Service, delivery.URL, delivery.Payload,
+		delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt,
+	}
+	if s.driver == "postgres" {
+		return s.db.QueryRow(s.rebind(query+` RETURNING id`), args...).Scan(&delivery.ID)
+	}
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	delivery.ID, err = result.LastInsertId()
+	return err
+}
+
+// webhookDeliveryColumns is the column list scanWebhookDelivery expects, in order
+const webhookDeliveryColumns = `id, created_at, job_id, tenant_id, service, url, payload, status, attempts, last_error, next_attempt_at`
+
+// scanWebhookDelivery reads a delivery from a row selected with webhookDeliveryColumns
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := row.Scan(&delivery.ID, &delivery.CreatedAt, &delivery.JobID, &delivery.TenantID, &delivery.Service, &delivery.URL,
+		&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.LastError, &delivery.NextAttemptAt)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetWebhookDelivery returns a delivery by ID, or errWebhookDeliveryNotFound
+func (s *JobStore) GetWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ?`), id)
+	delivery, err := scanWebhookDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// DueWebhookDeliveries returns up to limit pending deliveries whose next attempt is due
+func (s *JobStore) DueWebhookDeliveries(now time.Time, limit int) ([]*WebhookDelivery, error) {
+	rows, err := s.db.Query(s.rebind(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at
+		LIMIT ?`), WebhookPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClaimWebhookDelivery takes a pending delivery for one more attempt, pushing its next attempt
+// out to lease so no other instance tries it meanwhile. It reports false when another instance
+// claimed it first, and counts the attempt on delivery otherwise
+func (s *JobStore) ClaimWebhookDelivery(delivery *WebhookDelivery, lease time.Time) (bool, error) {
+	result, err := s.db.Exec(s.rebind(`
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id = ? AND status = ? AND attempts = ?`),
+		lease, delivery.ID, WebhookPending, delivery.Attempts,
+	)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil || claimed == 0 {
+		return false, err
+	}
+	delivery.Attempts++
+	delivery.NextAttemptAt = lease
+	return true, nil
+}
+
+// UpdateWebhookDelivery saves the outcome of an attempt at a delivery
+func (s *JobStore) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	_, err := s.db.Exec(s.rebind(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?
+		WHERE id = ?`),
+		delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt, delivery.ID,
+	)
+	return err
+}
+
+// DeleteWebhookDelivery removes a delivery once it has gone through
+func (s *JobStore) DeleteWebhookDelivery(id int64) error {
+	_, err := s.db.Exec(s.rebind(`DELETE FROM webhook_deliveries WHERE id = ?`), id)
+	return err
+}
+
+// PurgeOrphanedWebhookDeliveries removes the deliveries of jobs that no longer exist
+func (s *JobStore) PurgeOrphanedWebhookDeliveries() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM webhook_deliveries WHERE job_id NOT IN (SELECT id FROM jobs)`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// WebhookDeliveryFilter narrows the deliveries returned by ListWebhookDeliveries
+type WebhookDeliveryFilter struct {
+	Status string
+	JobID  string
+	Limit  int
+	Offset int
+}
+
+// ListWebhookDeliveries returns a page of deliveries matching the filter, newest first, along
+// with the total number of matching deliveries
+func (s *JobStore) ListWebhookDeliveries(filter WebhookDeliveryFilter) ([]*WebhookDelivery, int, error) {
+	conditions := []string{"status = ?"}
+	args := []any{filter.Status}
+	if filter.JobID != "" {
+		conditions = append(conditions, "job_id = ?")
+		args = append(args, filter.JobID)
+	}
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM webhook_deliveries `+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(s.rebind(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`), append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// FindCompletedJobByHash returns the tenant's most recent completed, unredacted, unpunctuated job
+// for the same audio and model, or errJobNotFound if there isn't one. Redacted, punctuated, and
+// hallucination-filtered transcripts are never reused, since the request hitting the cache may
+// want the provider's text as it was, and neither are ones with stretches skipped as non-speech or
+// other tenants' transcripts
+func (s *JobStore) FindCompletedJobByHash(tenantID, audioHash, model string) (*Job, error) {
+	row := s.db.QueryRow(s.rebind(`
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE tenant_id = ? AND audio_hash = ? AND model = ? AND status = ? AND redacted = ? AND punctuation = ? AND skipped = ? AND hallucinations = ? AND start_seconds = ?
+		ORDER BY created_at DESC
+		LIMIT 1`), tenantID, audioHash, model, JobStatusCompleted, false, "", "", "", 0)
+
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errJobNotFound
+	}
+	return job, err
+}
+
+// WatchedFile is a file in a watch folder, or an object under a scheduled prefix, that a job was
+// started for, as it was when the job started. Root is the folder or prefix
+type WatchedFile struct {
+	Path      string
+	Root      string
+	Size      int64
+	ModTime   time.Time
+	JobID     string
+	CreatedAt time.Time
+}
+
+// WatchedFiles returns the files under a watch folder that jobs were started for, by path
+func (s *JobStore) WatchedFiles(root string) (map[string]WatchedFile, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT path, root, size, mod_time, job_id, created_at FROM watched_files WHERE root = ?`), root)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := map[string]WatchedFile{}
+	for rows.Next() {
+		var file WatchedFile
+		var modTime int64
+		if err := rows.Scan(&file.Path, &file.Root, &file.Size, &modTime, &file.JobID, &file.CreatedAt); err != nil {
+			return nil, err
+		}
+		file.ModTime = time.Unix(0, modTime)
+		files[file.Path] = file
+	}
+	return files, rows.Err()
+}
+
+// ClaimWatchedFile records that a job was started for a file, unless one already was for the file
+// at the same size and modification time. It reports false when another instance, or an earlier
+// scan, got there first
+func (s *JobStore) ClaimWatchedFile(file WatchedFile) (bool, error) {
+	result, err := s.db.Exec(s.rebind(`
+		INSERT INTO watched_files (path, root, size, mod_time, job_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET size = excluded.size, mod_time = excluded.mod_time, job_id = excluded.job_id, created_at = excluded.created_at
+		WHERE watched_files.size <> excluded.size OR watched_files.mod_time <> excluded.mod_time`),
+		file.Path, file.Root, file.Size, file.ModTime.UnixNano(), file.JobID, file.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	return claimed > 0, err
+}
+
+// ReleaseWatchedFile forgets a claim on a file whose job never started, so the next scan tries
+// it again
+func (s *JobStore) ReleaseWatchedFile(path, jobID string) error {
+	_, err := s.db.Exec(s.rebind(`DELETE FROM watched_files WHERE path = ? AND job_id = ?`), path, jobID)
+	return err
+}
+
+// ScheduleRun is one run of a schedule: the batch of jobs it started, or why it couldn't
+type ScheduleRun struct {
+	Schedule   string     `json:"schedule"`
+	DueAt      time.Time  `json:"due_at"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	BatchID    string     `json:"batch_id,omitempty"`
+	Jobs       int        `json:"jobs"`
+	Skipped    int        `json:"skipped"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ClaimScheduleRun records the start of a schedule's run that was due at run.DueAt. It reports
+// false when another instance sharing the database started that run first
+func (s *JobStore) ClaimScheduleRun(run ScheduleRun) (bool, error) {
+	result, err := s.db.Exec(s.rebind(`
+		INSERT INTO schedule_runs (schedule, due_at, started_at, batch_id)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (schedule, due_at) DO NOTHING`),
+		run.Schedule, run.DueAt, run.StartedAt, run.BatchID,
+	)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	return claimed > 0, err
+}
+
+// FinishScheduleRun records how many jobs a run started and skipped, and its error if it failed.
+// A run that started none has no batch
+func (s *JobStore) FinishScheduleRun(run ScheduleRun) error {
+	_, err := s.db.Exec(s.rebind(`
+		UPDATE schedule_runs
+		SET finished_at = ?, batch_id = ?, jobs = ?, skipped = ?, error = ?
+		WHERE schedule = ? AND due_at = ?`),
+		run.FinishedAt, run.BatchID, run.Jobs, run.Skipped, run.Error, run.Schedule, run.DueAt,
+	)
+	return err
+}
+
+// ListScheduleRuns returns a schedule's most recent runs, newest first
+func (s *JobStore) ListScheduleRuns(schedule string, limit int) ([]ScheduleRun, error) {
+	rows, err := s.db.Query(s.rebind(`
+		SELECT schedule, due_at, started_at, finished_at, batch_id, jobs, skipped, error
+		FROM schedule_runs
+		WHERE schedule = ?
+		ORDER BY due_at DESC
+		LIMIT ?`), schedule, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ScheduleRun{}
+	for rows.Next() {
+		var run ScheduleRun
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&run.Schedule, &run.DueAt, &run.StartedAt, &finishedAt, &run.BatchID, &run.Jobs, &run.Skipped, &run.Error); err != nil {
+			return nil, err
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// errSpeakerNotFound is returned when no enrolled speaker has the requested ID
+var errSpeakerNotFound = errors.New("speaker not found")
+
+// Speaker is a person enrolled for speaker identification. Embedding is the mean of the
+// normalized embeddings of their voice samples
+type Speaker struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"-"`
+	Name      string    `json:"name"`
+	Samples   int       `json:"samples"`
+	Embedding []float64 `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SaveSpeaker inserts an enrolled speaker, or updates their samples and embedding
+func (s *JobStore) SaveSpeaker(speaker *Speaker) error {
+	embedding, err := json.Marshal(speaker.Embedding)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`
+		INSERT INTO speakers (id, tenant_id, name, samples, embedding, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET samples = excluded.samples, embedding = excluded.embedding, updated_at = excluded.updated_at`),
+		speaker.ID, speaker.TenantID, speaker.Name, speaker.Samples, string(embedding), speaker.CreatedAt, speaker.UpdatedAt,
+	)
+	return err
+}
+
+// GetSpeaker loads an enrolled speaker by ID
+func (s *JobStore) GetSpeaker(id string) (*Speaker, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT `+speakerColumns+` FROM speakers WHERE id = ?`), id)
+	speaker, err := scanSpeaker(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errSpeakerNotFound
+	}
+	return speaker, err
+}
+
+// ListSpeakers returns the speakers a tenant has enrolled, by name
+func (s *JobStore) ListSpeakers(tenantID string) ([]*Speaker, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT `+speakerColumns+` FROM speakers WHERE tenant_id = ? ORDER BY name, created_at`), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	speakers := []*Speaker{}
+	for rows.Next() {
+		speaker, err := scanSpeaker(rows)
+		if err != nil {
+			return nil, err
+		}
+		speakers = append(speakers, speaker)
+	}
+	return speakers, rows.Err()
+}
+
+// DeleteSpeaker removes an enrolled speaker and their embedding
+func (s *JobStore) DeleteSpeaker(id string) error {
+	result, err := s.db.Exec(s.rebind(`DELETE FROM speakers WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errSpeakerNotFound
+	}
+	return nil
+}
+
+const speakerColumns = `id, tenant_id, name, samples, embedding, created_at, updated_at`
+
+// scanSpeaker reads a row of speakerColumns
+func scanSpeaker(row rowScanner) (*Speaker, error) {
+	var speaker Speaker
+	var embedding string
+	if err := row.Scan(&speaker.ID, &speaker.TenantID, &speaker.Name, &speaker.Samples, &embedding, &speaker.CreatedAt, &speaker.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(embedding), &speaker.Embedding); err != nil {
+		return nil, err
+	}
+	return &speaker, nil
+}
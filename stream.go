@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// ndjsonContentType is the media type of streamed transcriptions: one JSON object per line
+const ndjsonContentType = "application/x-ndjson"
+
+// StreamChunk is a line of a streamed transcription carrying the segments of a chunk as soon as
+// it is transcribed. Index counts the chunks streamed so far, and Start and End span its segments
+type StreamChunk struct {
+	Type     string                `json:"type"`
+	Index    int                   `json:"index"`
+	Start    float64               `json:"start"`
+	End      float64               `json:"end"`
+	Text     string                `json:"text"`
+	Segments []transcriber.Segment `json:"segments"`
+}
+
+// StreamResult is the last line of a streamed transcription that succeeded, with the same
+// fields as the response to an unstreamed one
+type StreamResult struct {
+	Type string `json:"type"`
+	SuccessResponse
+}
+
+// StreamError is the last line of a streamed transcription that failed. The response status is
+// already sent by then, so Status carries the one it would have had
+type StreamError struct {
+	Type   string `json:"type"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// wantsStream reports whether a transcription request asked for stream=true, as a form field
+// or in the query
+func wantsStream(c *gin.Context, fields map[string]string) bool {
+	return fields["stream"] == "true" || c.Query("stream") == "true"
+}
+
+// respondWithStream runs a job like respondWithPipeline but keeps the response open, writing a
+// StreamChunk line as each chunk is transcribed and a StreamResult or StreamError line at the
+// end. Chunks stream only when the job runs on this instance; split channels arrive as one chunk
+// once both are done, and a cached result only as the final line
+func respondWithStream(c *gin.Context, job *Job, jobDir, inputPath string, opts JobOptions, source *SourceMetadata) {
+	c.Header("Content-Type", ndjsonContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	encoder := json.NewEncoder(c.Writer)
+	writeLine := func(line any) {
+		// A client that went away cancels the job through the request context
+		if err := encoder.Encode(line); err == nil {
+			c.Writer.Flush()
+		}
+	}
+
+	index := 0
+	opts.OnSegments = func(segments []transcriber.Segment) {
+		writeLine(StreamChunk{
+			Type:     "chunk",
+			Index:    index,
+			Start:    segments[0].Start,
+			End:      segments[len(segments)-1].End,
+			Text:     transcriber.JoinSegments(segments),
+			Segments: segments,
+		})
+		index++
+	}
+
+	result, err := executeJob(c.Request.Context(), job, jobDir, inputPath, opts)
+	if err != nil {
+		status, message := http.StatusInternalServerError, err.Error()
+		var pipelineErr *pipelineError
+		if errors.As(err, &pipelineErr) {
+			status, message = pipelineErr.Status, pipelineErr.Message
+		}
+		writeLine(StreamError{Type: "error", Status: status, Error: message})
+		return
+	}
+	writeLine(StreamResult{Type: "result", SuccessResponse: successResponse(job, result, opts, source)})
+}
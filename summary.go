@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Summary defaults: Groq's OpenAI-compatible chat completions API
+const (
+	defaultSummaryURL    = "https://api.groq.com/openai/v1/chat/completions"
+	defaultSummaryModel  = "llama-3.3-70b-versatile"
+	defaultSummaryPrompt = "Summarize the following transcript in a few short paragraphs, " +
+		"followed by a bulleted list of key points and any action items.\n\nTranscript:\n{{.Transcript}}"
+)
+
+// summaryPrompt renders the summary request for a transcript
+var summaryPrompt *template.Template
+
+// summaryClient sends chat completion requests
+var summaryClient = &http.Client{
+	Timeout:   transcriber.DefaultRequestTimeout,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// initSummaryPrompt parses the configured summary prompt template
+func initSummaryPrompt(text string) error {
+	prompt, err := template.New("summary").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return err
+	}
+	summaryPrompt = prompt
+	return nil
+}
+
+// chatCompletionRequest is the body of an OpenAI-compatible chat completions request
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatCompletionResponse is the part of a chat completions response we use
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// summarizeTranscript asks the configured chat model for a summary of the transcript
+func summarizeTranscript(ctx context.Context, transcript string) (string, error) {
+	var prompt strings.Builder
+	if err := summaryPrompt.Execute(&prompt, struct{ Transcript string }{transcript}); err != nil {
+		return "", fmt.Errorf("unable to render summary prompt: %w", err)
+	}
+	return chatCompletion(ctx, prompt.String())
+}
+
+// chatCompletion sends a single-message prompt to the configured chat model and returns its reply
+func chatCompletion(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:    appConfig.SummaryModel,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, appConfig.SummaryURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+summaryAPIKey())
+
+	release, err := requestLimiter.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	resp, err := summaryClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("chat API returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("chat API returned no choices")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// chatBatchLines is how many lines go into each chat request made by chatLines, so long
+// transcripts stay well inside the model's context
+const chatBatchLines = 100
+
+// numberedLine is one numbered line of a chat model's reply
+var numberedLine = regexp.MustCompile(`^\s*(\d+)[.:)]\s*(.*)$`)
+
+// chatLines sends lines to the configured chat model after instructions, numbered and in batches,
+// and returns its reply to each line, or "" for a line it left out
+func chatLines(ctx context.Context, instructions string, lines []string) ([]string, error) {
+	replies := make([]string, len(lines))
+	for start := 0; start < len(lines); start += chatBatchLines {
+		batch := lines[start:min(start+chatBatchLines, len(lines))]
+		var prompt strings.Builder
+		prompt.WriteString(instructions)
+		for i, line := range batch {
+			fmt.Fprintf(&prompt, "%d. %s\n", i+1, strings.TrimSpace(line))
+		}
+		reply, err := chatCompletion(ctx, prompt.String())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(reply, "\n") {
+			match := numberedLine.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			n, err := strconv.Atoi(match[1])
+			if err != nil || n < 1 || n > len(batch) {
+				continue
+			}
+			replies[start+n-1] = strings.TrimSpace(match[2])
+		}
+	}
+	return replies, nil
+}
+
+// segmentTexts lists the text of each segment
+func segmentTexts(segments []transcriber.Segment) []string {
+	texts := make([]string, len(segments))
+	for i, segment := range segments {
+		texts[i] = segment.Text
+	}
+	return texts
+}
+
+// summarizeJob adds a summary of the transcript to the job. A failed summary is recorded on the
+// job rather than failing it, since the transcript itself is still good. Like the pipeline, it
+// stops when ctx is canceled or shutdown cancels in-flight jobs
+func summarizeJob(ctx context.Context, job *Job, result *transcriber.Result) {
+	if strings.TrimSpace(result.Transcription) == "" {
+		return
+	}
+
+	summaryCtx, cancel := jobContext(ctx)
+	defer cancel()
+	summary, err := summarizeTranscript(summaryCtx, result.Transcription)
+	if err != nil {
+		loggerFrom(ctx).Warn("Error summarizing transcript", "error", err)
+		job.SummaryError = "Failed to summarize transcript: " + err.Error()
+		return
+	}
+	job.Summary = summary
+}
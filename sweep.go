@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scratchDirPrefixes name the directories requests work in under the work directory: one per job,
+// and the staging directories of ZIP archives and batches
+var scratchDirPrefixes = []string{"job-", "archive-", "batch-"}
+
+// runOrphanSweeper removes the scratch files a crash or kill left behind, once at startup and then
+// every interval until ctx is canceled
+func runOrphanSweeper(ctx context.Context, maxAge, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sweepOrphanedFiles(maxAge)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepOrphanedFiles deletes the scratch directories in the work and memory directories, and the
+// partial tus uploads, that nothing has written to for maxAge. The directories of jobs in flight
+// on this instance and of jobs waiting to resume are kept however old they are; those of jobs on
+// other instances sharing the work directory are kept by their age, since a running job writes
+// to its directory as it goes
+func sweepOrphanedFiles(maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+	keep := map[string]bool{}
+	resumable, err := jobStore.ListResumableJobs()
+	if err != nil {
+		// Without the list, a job waiting to resume can't be told from an orphan
+		slog.Error("Unable to list resumable jobs, skipping the sweep", "error", err)
+		return
+	}
+	for _, job := range resumable {
+		keep[filepath.Clean(job.JobDir)] = true
+	}
+	activeJobDirs.Range(func(dir, _ any) bool {
+		keep[filepath.Clean(dir.(string))] = true
+		return true
+	})
+
+	removed, freed := 0, int64(0)
+	sweep := func(path string) {
+		modified, size := lastModified(path)
+		if modified.After(cutoff) {
+			return
+		}
+		if err := os.RemoveAll(path); err != nil {
+			slog.Error("Error removing orphaned scratch files", "path", path, "error", err)
+			return
+		}
+		removed++
+		freed += size
+	}
+
+	for _, path := range scratchDirs(appConfig.WorkDir, scratchDirPrefixes) {
+		if !keep[path] {
+			sweep(path)
+		}
+	}
+	if appConfig.MemoryProcessing {
+		for _, path := range scratchDirs(appConfig.MemoryDir, []string{"transcriber-"}) {
+			jobDir := filepath.Join(appConfig.WorkDir, strings.TrimPrefix(filepath.Base(path), "transcriber-"))
+			if !keep[jobDir] {
+				sweep(path)
+			}
+		}
+	}
+	for _, id := range staleTusUploads(cutoff) {
+		sweep(tusInfoPath(id))
+		sweep(tusDataPath(id))
+	}
+
+	if removed > 0 {
+		slog.Info("Removed orphaned scratch files", "count", removed, "bytes", freed, "max_age", maxAge)
+	}
+}
+
+// scratchDirs lists the entries of dir whose names start with one of prefixes
+func scratchDirs(dir string, prefixes []string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Error("Unable to list scratch directory", "dir", dir, "error", err)
+		return nil
+	}
+	var paths []string
+	for _, entry := range entries {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(entry.Name(), prefix) {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// staleTusUploads returns the IDs of partial uploads whose state and data files are both older
+// than cutoff, so a client still sending data keeps its upload
+func staleTusUploads(cutoff time.Time) []string {
+	entries, err := os.ReadDir(tusUploadsDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Unable to list uploads", "dir", tusUploadsDir(), "error", err)
+		}
+		return nil
+	}
+	var stale []string
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok {
+			continue
+		}
+		infoModified, _ := lastModified(tusInfoPath(id))
+		dataModified, _ := lastModified(tusDataPath(id))
+		if infoModified.Before(cutoff) && dataModified.Before(cutoff) {
+			stale = append(stale, id)
+		}
+	}
+	return stale
+}
+
+// lastModified returns when anything under path was last written to, and the total size of its
+// files. A path that doesn't exist counts as never modified
+func lastModified(path string) (time.Time, int64) {
+	var latest time.Time
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		if !entry.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return latest, size
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant is a team sharing the server. Its API keys identify its requests, which only see its own
+// jobs and are transcribed with its own providers, within its own quotas
+type Tenant struct {
+	ID      string   `json:"id"`
+	APIKeys []string `json:"api_keys"`
+
+	// Provider, Model, and AllowedModels work like TRANSCRIBER_PROVIDER, TRANSCRIBER_MODEL, and
+	// TRANSCRIBER_ALLOWED_MODELS, which they default to
+	Provider      string   `json:"provider"`
+	Model         string   `json:"model"`
+	AllowedModels []string `json:"allowed_models"`
+
+	// GroqAPIKey and OpenAIAPIKey are the tenant's own provider credentials. Without them its jobs
+	// use the server's
+	GroqAPIKey   string `json:"groq_api_key"`
+	OpenAIAPIKey string `json:"openai_api_key"`
+
+	// MaxConcurrentJobs caps how many of the tenant's jobs each instance admits at once; zero
+	// leaves only the server's queue limit
+	MaxConcurrentJobs int `json:"max_concurrent_jobs"`
+
+	// MaxConcurrentJobsPerKey caps how many jobs each of the tenant's API keys has in progress on
+	// each instance, so a bulk import under one key leaves room for the others; zero is unlimited.
+	// OverKeyLimit is what happens to a key's jobs past it: "reject", the default, turns them away
+	// with a 429, and "queue" holds them until one of the key's jobs finishes
+	MaxConcurrentJobsPerKey int    `json:"max_concurrent_jobs_per_key"`
+	OverKeyLimit            string `json:"over_key_limit"`
+
+	// DailyAudioMinutes caps the audio the tenant's jobs transcribe each UTC day; zero is unlimited
+	DailyAudioMinutes float64 `json:"daily_audio_minutes"`
+
+	// RetentionTTL is how long the tenant's finished jobs are kept, as a duration such as "720h".
+	// Empty uses TRANSCRIBER_RETENTION_TTL, and "0" keeps them forever
+	RetentionTTL string `json:"retention_ttl"`
+
+	providers    *providerSet
+	slots        chan struct{}
+	keySlots     map[string]chan struct{}
+	retentionTTL time.Duration
+}
+
+// What happens to a key's jobs past MaxConcurrentJobsPerKey
+const (
+	overKeyLimitReject = "reject"
+	overKeyLimitQueue  = "queue"
+)
+
+// tenantsByID holds the configured tenants. Without any, the server has a single job history that
+// every request shares, and needs no API key
+var tenantsByID map[string]*Tenant
+
+// initTenants loads the tenants in TRANSCRIBER_TENANTS_FILE, a JSON array, when it is set
+func initTenants(config Config) error {
+	if config.TenantsFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(config.TenantsFile)
+	if err != nil {
+		return err
+	}
+
+	// Unknown fields are most likely misspelled quotas, which shouldn't silently go unenforced
+	var tenants []*Tenant
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&tenants); err != nil {
+		return err
+	}
+	if len(tenants) == 0 {
+		return errors.New("no tenants defined")
+	}
+
+	byID := map[string]*Tenant{}
+	keyOwners := map[string]string{}
+	for i, tenant := range tenants {
+		if tenant.ID == "" {
+			return fmt.Errorf("tenant %d has no id", i+1)
+		}
+		if _, ok := byID[tenant.ID]; ok {
+			return fmt.Errorf("tenant %s is defined twice", tenant.ID)
+		}
+		if len(tenant.APIKeys) == 0 {
+			return fmt.Errorf("tenant %s has no api_keys", tenant.ID)
+		}
+		for _, key := range tenant.APIKeys {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Errorf("tenant %s has an empty API key", tenant.ID)
+			}
+			if owner, ok := keyOwners[key]; ok {
+				return fmt.Errorf("tenants %s and %s share an API key", owner, tenant.ID)
+			}
+			keyOwners[key] = tenant.ID
+		}
+		if err := setUpTenant(config, tenant); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		byID[tenant.ID] = tenant
+	}
+
+	tenantsByID = byID
+	return nil
+}
+
+// setUpTenant fills in a tenant's settings from the server's and builds its provider set
+func setUpTenant(config Config, tenant *Tenant) error {
+	if tenant.MaxConcurrentJobs < 0 {
+		return errors.New("max_concurrent_jobs must not be negative")
+	}
+	if tenant.MaxConcurrentJobsPerKey < 0 {
+		return errors.New("max_concurrent_jobs_per_key must not be negative")
+	}
+	switch tenant.OverKeyLimit {
+	case "":
+		tenant.OverKeyLimit = overKeyLimitReject
+	case overKeyLimitReject, overKeyLimitQueue:
+	default:
+		return fmt.Errorf("unknown over_key_limit %q: expected reject or queue", tenant.OverKeyLimit)
+	}
+	if tenant.DailyAudioMinutes < 0 {
+		return errors.New("daily_audio_minutes must not be negative")
+	}
+
+	tenant.retentionTTL = config.RetentionTTL
+	if tenant.RetentionTTL != "" {
+		ttl, err := time.ParseDuration(tenant.RetentionTTL)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("invalid retention_ttl %q: expected a duration such as 720h", tenant.RetentionTTL)
+		}
+		tenant.retentionTTL = ttl
+	}
+
+	providers, err := newTenantProviders(config, tenant)
+	if err != nil {
+		return err
+	}
+	tenant.providers = providers
+
+	if tenant.MaxConcurrentJobs > 0 {
+		tenant.slots = make(chan struct{}, tenant.MaxConcurrentJobs)
+	}
+	if tenant.MaxConcurrentJobsPerKey > 0 {
+		tenant.keySlots = make(map[string]chan struct{}, len(tenant.APIKeys))
+		for _, key := range tenant.APIKeys {
+			tenant.keySlots[key] = make(chan struct{}, tenant.MaxConcurrentJobsPerKey)
+		}
+	}
+	return nil
+}
+
+// newTenantProviders builds a tenant's provider set from its own provider settings, falling back
+// to the server's
+func newTenantProviders(config Config, tenant *Tenant) (*providerSet, error) {
+	// The server's default model only carries over along with its provider
+	provider := cmp.Or(tenant.Provider, config.Provider)
+	model := tenant.Model
+	if model == "" && provider == config.Provider {
+		model = config.Model
+	}
+	allowed := tenant.AllowedModels
+	if len(allowed) == 0 {
+		allowed = config.AllowedModels
+	}
+	return newProviderSet(config, provider, model, allowed, tenantAPIKeys(tenant, serverAPIKeys(config)))
+}
+
+// tenantAPIKeys returns the tenant's own API key for each provider, falling back to the server's
+func tenantAPIKeys(tenant *Tenant, serverKeys map[string]string) map[string]string {
+	return map[string]string{
+		"groq":   cmp.Or(tenant.GroqAPIKey, serverKeys["groq"]),
+		"openai": cmp.Or(tenant.OpenAIAPIKey, serverKeys["openai"]),
+	}
+}
+
+// tenantKey is the context key for the tenant a request belongs to
+type tenantKey struct{}
+
+// withTenant returns a copy of ctx carrying tenant
+func withTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFrom returns the tenant carried by ctx, or nil when the server has no tenants
+func tenantFrom(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantKey{}).(*Tenant)
+	return tenant
+}
+
+// apiKeyKey is the context key for the API key a request was made with
+type apiKeyKey struct{}
+
+// withAPIKey returns a copy of ctx carrying the request's API key
+func withAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// keySlotsFrom returns the job slots of the API key carried by ctx, or nil when its tenant
+// doesn't limit keys
+func keySlotsFrom(ctx context.Context) chan struct{} {
+	tenant := tenantFrom(ctx)
+	if tenant == nil || tenant.keySlots == nil {
+		return nil
+	}
+	key, _ := ctx.Value(apiKeyKey{}).(string)
+	return tenant.keySlots[key]
+}
+
+// tenantIDFrom returns the ID of the tenant carried by ctx, or "" when there is none
+func tenantIDFrom(ctx context.Context) string {
+	if tenant := tenantFrom(ctx); tenant != nil {
+		return tenant.ID
+	}
+	return ""
+}
+
+// tenantOwns reports whether the request in ctx may see a job or upload of the given tenant.
+// Without tenants configured everything is shared
+func tenantOwns(ctx context.Context, tenantID string) bool {
+	return len(tenantsByID) == 0 || tenantID == tenantIDFrom(ctx)
+}
+
+// getTenantJob loads a job by ID, returning errJobNotFound for jobs the request's tenant doesn't own
+func getTenantJob(ctx context.Context, id string) (*Job, error) {
+	job, err := jobStore.GetJob(id)
+	if err == nil && !tenantOwns(ctx, job.TenantID) {
+		return nil, errJobNotFound
+	}
+	return job, err
+}
+
+// tenantForKey returns the tenant an API key belongs to, or nil. Every key is compared in
+// constant time so how long the lookup takes says nothing about them
+func tenantForKey(key string) *Tenant {
+	var found *Tenant
+	for _, tenant := range tenantsByID {
+		for _, tenantKey := range tenant.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(tenantKey)) == 1 {
+				found = tenant
+			}
+		}
+	}
+	return found
+}
+
+// requestAPIKey picks the API key out of an "Authorization: Bearer" value or an X-API-Key value
+func requestAPIKey(authorization, apiKey string) string {
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		return token
+	}
+	return apiKey
+}
+
+// tenantAuth identifies the tenant of an API request by its key, when tenants are configured, and
+// tags the request's context and logs with it. CORS preflights carry no credentials, so they pass
+func tenantAuth(c *gin.Context) {
+	if len(tenantsByID) == 0 || c.Request.Method == http.MethodOptions {
+		c.Next()
+		return
+	}
+	key := requestAPIKey(c.GetHeader("Authorization"), c.GetHeader("X-API-Key"))
+	tenant := tenantForKey(key)
+	if tenant == nil {
+		recordAudit(c.Request.Context(), AuditEvent{Action: AuditAuthFailed, Detail: c.Request.Method + " " + c.Request.URL.Path})
+		c.Header("WWW-Authenticate", `Bearer realm="api"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "A valid API key is required"})
+		return
+	}
+
+	ctx := withAPIKey(withTenant(c.Request.Context(), tenant), key)
+	c.Request = c.Request.WithContext(withLogger(ctx, loggerFrom(ctx).With("tenant", tenant.ID)))
+	c.Next()
+}
+
+// admitTenantJob checks a tenant's daily audio quota and reserves one of its job slots, returning
+// a 429 when either is used up. The returned function gives the slot back. Requests without a
+// tenant are only limited by the queue
+func admitTenantJob(tenant *Tenant) (func(), error) {
+	if tenant == nil {
+		return func() {}, nil
+	}
+
+	// Audio is only counted once a job completes, so the job that crosses the quota still finishes
+	if tenant.DailyAudioMinutes > 0 {
+		now := time.Now().UTC()
+		today := now.Truncate(24 * time.Hour)
+		seconds, err := jobStore.AudioSecondsSince(tenant.ID, today)
+		if err != nil {
+			return nil, &pipelineError{Status: http.StatusInternalServerError, Message: "Failed to check audio quota: " + err.Error()}
+		}
+		if minutes := seconds / 60; minutes >= tenant.DailyAudioMinutes {
+			return nil, &pipelineError{
+				Status:     http.StatusTooManyRequests,
+				Message:    fmt.Sprintf("Daily audio quota used up: %g of %g minutes transcribed today (UTC)", math.Round(minutes*10)/10, tenant.DailyAudioMinutes),
+				RetryAfter: today.Add(24 * time.Hour).Sub(now),
+			}
+		}
+	}
+
+	if tenant.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case tenant.slots <- struct{}{}:
+		return func() { <-tenant.slots }, nil
+	default:
+		return nil, &pipelineError{
+			Status:     http.StatusTooManyRequests,
+			Message:    fmt.Sprintf("Too many jobs in progress: at most %d may run at once, try again later", tenant.MaxConcurrentJobs),
+			RetryAfter: appConfig.QueueRetryAfter,
+		}
+	}
+}
+
+// admitKeyJob reserves one of the job slots of the request's API key when its tenant rejects the
+// jobs past them, returning a 429 when they're all taken. Keys that queue their excess wait in
+// waitForKeySlot instead
+func admitKeyJob(ctx context.Context) (func(), error) {
+	slots := keySlotsFrom(ctx)
+	if slots == nil || tenantFrom(ctx).OverKeyLimit != overKeyLimitReject {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+		return nil, &pipelineError{
+			Status:     http.StatusTooManyRequests,
+			Message:    fmt.Sprintf("Too many jobs in progress for this API key: at most %d may run at once, try again later", cap(slots)),
+			RetryAfter: appConfig.QueueRetryAfter,
+		}
+	}
+}
+
+// waitForKeySlot blocks until the request's API key has a free job slot, when its tenant queues
+// the jobs past them, or ctx is canceled. The returned function gives the slot back
+func waitForKeySlot(ctx context.Context) (func(), error) {
+	slots := keySlotsFrom(ctx)
+	if slots == nil || tenantFrom(ctx).OverKeyLimit != overKeyLimitQueue {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
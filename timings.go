@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// JobTimings breaks down where a job's time went, in milliseconds, so slowness can be pinned on
+// the upload, the queue, local ffmpeg work, or the provider
+type JobTimings struct {
+	UploadMs     float64       `json:"upload_ms"`
+	QueueMs      float64       `json:"queue_ms"`
+	ValidateMs   float64       `json:"validate_ms"`
+	PreprocessMs float64       `json:"preprocess_ms"`
+	HashMs       float64       `json:"hash_ms"`
+	AnalyzeMs    float64       `json:"analyze_ms"`
+	ChunkingMs   float64       `json:"chunking_ms"`
+	TranscribeMs float64       `json:"transcribe_ms"`
+	StitchMs     float64       `json:"stitch_ms"`
+	ChunkLatency *ChunkLatency `json:"chunk_latency,omitempty"`
+}
+
+// ChunkLatency is the spread of how long the provider took to answer each of a job's chunks
+type ChunkLatency struct {
+	Requests int     `json:"requests"`
+	MinMs    float64 `json:"min_ms"`
+	AvgMs    float64 `json:"avg_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+// jobTimingsKey is the context key of the JobTimings a job's pipeline records its wait for a
+// worker in
+type jobTimingsKey struct{}
+
+// withJobTimings makes runPipeline record the time the job waits for a worker in timings
+func withJobTimings(ctx context.Context, timings *JobTimings) context.Context {
+	return context.WithValue(ctx, jobTimingsKey{}, timings)
+}
+
+// jobTimingsFrom returns the JobTimings set on ctx, or nil when the pipeline isn't timed
+func jobTimingsFrom(ctx context.Context) *JobTimings {
+	timings, _ := ctx.Value(jobTimingsKey{}).(*JobTimings)
+	return timings
+}
+
+// milliseconds converts a duration to milliseconds, to the hundredth
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())/10) / 100
+}
+
+// addStages copies the stage timings of a pipeline's result. A cached result only has the
+// stages up to the hash that found it
+func (t *JobTimings) addStages(result *transcriber.Result) {
+	if result == nil {
+		return
+	}
+	stages := result.Timings
+	t.ValidateMs = milliseconds(stages.Validate)
+	t.PreprocessMs = milliseconds(stages.Preprocess)
+	t.HashMs = milliseconds(stages.Hash)
+	t.AnalyzeMs = milliseconds(stages.Analyze)
+	t.ChunkingMs = milliseconds(stages.Chunk)
+	t.TranscribeMs = milliseconds(stages.Transcribe)
+	t.StitchMs = milliseconds(stages.Stitch)
+	if latency := stages.ChunkLatency; latency.Count > 0 {
+		t.ChunkLatency = &ChunkLatency{
+			Requests: latency.Count,
+			MinMs:    milliseconds(latency.Min),
+			AvgMs:    milliseconds(latency.Avg),
+			MaxMs:    milliseconds(latency.Max),
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// configureTLS prepares srv for HTTPS when certificate files or autocert domains are configured.
+// It returns the plain-HTTP server that redirects to HTTPS (and answers ACME challenges), or nil
+// when TLS or the redirect is disabled
+func configureTLS(srv *http.Server, config Config) (*http.Server, error) {
+	usingFiles := config.TLSCertFile != "" || config.TLSKeyFile != ""
+	usingAutocert := len(config.AutocertDomains) > 0
+	switch {
+	case usingFiles && usingAutocert:
+		return nil, errors.New("set either TRANSCRIBER_TLS_CERT_FILE/TRANSCRIBER_TLS_KEY_FILE or TRANSCRIBER_AUTOCERT_DOMAINS, not both")
+	case usingFiles && (config.TLSCertFile == "" || config.TLSKeyFile == ""):
+		return nil, errors.New("TRANSCRIBER_TLS_CERT_FILE and TRANSCRIBER_TLS_KEY_FILE must be set together")
+	case !usingFiles && !usingAutocert:
+		return nil, nil
+	}
+
+	redirect := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectToHTTPS(w, r, srv.Addr)
+	}))
+
+	if usingAutocert {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.AutocertDomains...),
+			Cache:      autocert.DirCache(config.AutocertCacheDir),
+			Email:      config.AutocertEmail,
+		}
+		srv.TLSConfig = manager.TLSConfig()
+
+		// HTTP-01 challenges arrive over plain HTTP; everything else is redirected
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	if config.HTTPRedirectAddr == "" {
+		return nil, nil
+	}
+	return &http.Server{Addr: config.HTTPRedirectAddr, Handler: redirect}, nil
+}
+
+// listenAndServe serves srv on the configured listener, over HTTPS when TLS is configured and
+// plain HTTP otherwise
+func listenAndServe(srv *http.Server, config Config) error {
+	listener, err := listen(config)
+	if err != nil {
+		return err
+	}
+	switch {
+	case srv.TLSConfig != nil:
+		return srv.ServeTLS(listener, "", "")
+	case config.TLSCertFile != "":
+		return srv.ServeTLS(listener, config.TLSCertFile, config.TLSKeyFile)
+	default:
+		return srv.Serve(listener)
+	}
+}
+
+// redirectToHTTPS permanently redirects a request to the same host and path on the HTTPS listener
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request, tlsAddr string) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(tlsAddr); err == nil && port != "443" && port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	target := "https://" + host + r.URL.RequestURI()
+	http.Redirect(w, r, target, http.StatusPermanentRedirect)
+}
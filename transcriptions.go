@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Pagination limits for the history endpoint
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// TranscriptCorrectionRequest is the JSON body of a transcript correction
+type TranscriptCorrectionRequest struct {
+	Segments []SegmentCorrection `json:"segments" binding:"required"`
+}
+
+// SegmentCorrection replaces the text of the segment with the given ID
+type SegmentCorrection struct {
+	ID   int     `json:"id"`
+	Text *string `json:"text" binding:"required"`
+}
+
+// TranscriptionListResponse is a page of stored jobs
+type TranscriptionListResponse struct {
+	Transcriptions []*Job `json:"transcriptions"`
+	Total          int    `json:"total"`
+	Page           int    `json:"page"`
+	PageSize       int    `json:"page_size"`
+}
+
+func listTranscriptions(c *gin.Context) {
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "page must be a positive integer"})
+		return
+	}
+	pageSize, err := parsePositiveInt(c.Query("page_size"), defaultPageSize)
+	if err != nil || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("page_size must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	filter := JobFilter{
+		TenantID:    tenantIDFrom(c.Request.Context()),
+		Status:      c.Query("status"),
+		Filename:    c.Query("filename"),
+		BatchID:     c.Query("batch_id"),
+		FeedURL:     c.Query("feed_url"),
+		EpisodeGUID: c.Query("episode_guid"),
+		CallSID:     c.Query("call_sid"),
+		Schedule:    c.Query("schedule"),
+		SortBy:      c.DefaultQuery("sort", "created_at"),
+		Desc:        !strings.EqualFold(c.Query("order"), "asc"),
+		Limit:       pageSize,
+		Offset:      (page - 1) * pageSize,
+	}
+	if _, ok := jobSortColumns[filter.SortBy]; !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown sort field %q", filter.SortBy)})
+		return
+	}
+	if filter.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "from " + err.Error()})
+		return
+	}
+	if filter.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "to " + err.Error()})
+		return
+	}
+
+	jobs, total, err := jobStore.ListJobs(filter)
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error listing jobs", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list transcriptions"})
+		return
+	}
+	recordAudit(c.Request.Context(), AuditEvent{Action: AuditTranscriptionsListed, Detail: c.Request.URL.RawQuery})
+
+	c.JSON(http.StatusOK, TranscriptionListResponse{
+		Transcriptions: jobs,
+		Total:          total,
+		Page:           page,
+		PageSize:       pageSize,
+	})
+}
+
+// parsePositiveInt parses a query value as an integer >= 1, returning fallback when it is empty
+func parsePositiveInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid positive integer %q", value)
+	}
+	return n, nil
+}
+
+// parseDateParam accepts RFC 3339 timestamps or YYYY-MM-DD dates. A bare date used as the
+// end of a range covers the whole day, so to=2025-01-31 includes jobs from January 31st
+func parseDateParam(value string, endOfRange bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func getTranscription(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	contentType, ok := formatContentTypes[format]
+	if !ok {
+		contentType, ok = documentContentTypes[format]
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown format %q: expected text, json, readable, srt, vtt, markdown, lrc, words, chapters, docx, or pdf", format)})
+		return
+	}
+
+	profanityMode, err := parseProfanityFilter(c.Query("profanity_filter"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	minConfidence, err := parseMinConfidence(c.Query("min_confidence"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	translated := c.Query("translated") == "true"
+
+	job, err := getTenantJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	auditJob(c.Request.Context(), AuditTranscriptionRead, job, "format="+format)
+	if job.Status == JobStatusCompleted {
+		stats := transcriber.TranscriptStats(job.Transcript, job.Segments)
+		job.Stats = &stats
+	}
+	job = flagJob(filterJob(job, profanityMode), minConfidence)
+
+	// JSON always works so clients can poll status; the other formats need a finished transcript
+	if format == "json" {
+		c.JSON(http.StatusOK, linkedJob(c.Request.Context(), job))
+		return
+	}
+	if job.Status != JobStatusCompleted {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("Transcription is %s, not completed", job.Status)})
+		return
+	}
+
+	if translated {
+		if job.Translation == nil {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription has no translation: submit it with translate_to"})
+			return
+		}
+		if wordFormat(format) {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: "Word timings are only available in the transcript's own language"})
+			return
+		}
+		job = flagJob(translatedJob(job), minConfidence)
+	}
+	if wordFormat(format) && len(job.Words) == 0 {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription has no word timings: submit it with word_timestamps=true"})
+		return
+	}
+
+	// Plain renderings have no flags, so low-confidence text is marked in place
+	job.Segments = transcriber.MarkLowConfidence(job.Segments)
+
+	if _, ok := documentContentTypes[format]; ok {
+		document, err := renderDocument(format, job)
+		if err != nil {
+			loggerFrom(c.Request.Context()).Error("Error rendering document", "job_id", job.ID, "format", format, "error", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to render transcription"})
+			return
+		}
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": documentFilename(job, format)}))
+		c.Data(http.StatusOK, contentType, document)
+		return
+	}
+	c.Data(http.StatusOK, contentType, []byte(renderTranscript(format, job.Transcript, captionedSegments(format, job), job.Words)))
+}
+
+// correctTranscription replaces the text of segments of a completed job, keeping what the model
+// transcribed, and rebuilds the transcript from them. Correcting a segment back to its original
+// text clears its edit
+func correctTranscription(c *gin.Context) {
+	var request TranscriptCorrectionRequest
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Segments) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Request body must be JSON with a segments field of {id, text} corrections"})
+		return
+	}
+
+	job, err := getTenantJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	if job.Status != JobStatusCompleted {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("Transcription is %s, not completed", job.Status)})
+		return
+	}
+
+	positions := make(map[int]int, len(job.Segments))
+	for i, segment := range job.Segments {
+		positions[segment.ID] = i
+	}
+	edited := make([]string, 0, len(request.Segments))
+	for _, correction := range request.Segments {
+		i, ok := positions[correction.ID]
+		if !ok || correction.Text == nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Segment %d doesn't exist or has no text", correction.ID)})
+			return
+		}
+		segment := &job.Segments[i]
+		text := strings.TrimSpace(*correction.Text)
+		original := segment.Text
+		if segment.Edited {
+			original = segment.OriginalText
+		}
+		segment.Text = text
+		segment.Edited = text != original
+		segment.OriginalText = ""
+		if segment.Edited {
+			segment.OriginalText = original
+		}
+		edited = append(edited, strconv.Itoa(correction.ID))
+	}
+	job.Transcript = transcriber.JoinSegments(job.Segments)
+
+	if err := jobStore.UpdateTranscript(job); err != nil {
+		loggerFrom(c.Request.Context()).Error("Error saving corrections", "job_id", job.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save corrections"})
+		return
+	}
+	auditJob(c.Request.Context(), AuditTranscriptionEdited, job, "segments="+strings.Join(edited, ","))
+
+	c.JSON(http.StatusOK, job)
+}
+
+func deleteTranscription(c *gin.Context) {
+	job, err := getTenantJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	if job.Status == JobStatusProcessing {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Transcription is still processing"})
+		return
+	}
+
+	if err := jobStore.DeleteJob(job.ID); err != nil && !errors.Is(err, errJobNotFound) {
+		loggerFrom(c.Request.Context()).Error("Error deleting job", "job_id", job.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete transcription"})
+		return
+	}
+	removeRetainedAudio(job.ID)
+	removeSubtitledVideo(job.ID)
+	removeStoredResults(c.Request.Context(), job)
+	auditJob(c.Request.Context(), AuditTranscriptionDeleted, job, "")
+
+	c.Status(http.StatusNoContent)
+}
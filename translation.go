@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// Translation backends: the summary chat model, or DeepL's API
+const (
+	translationLLM   = "llm"
+	translationDeepL = "deepl"
+)
+
+// defaultDeepLURL is DeepL's free API; paid plans use https://api.deepl.com/v2/translate
+const defaultDeepLURL = "https://api-free.deepl.com/v2/translate"
+
+// deeplBatchTexts is the most texts DeepL translates in one request
+const deeplBatchTexts = 50
+
+// translationPrompt asks the chat model to translate numbered transcript lines one for one, given
+// the name of the target language
+const translationPrompt = "Translate the following numbered transcript lines into %s. Translate " +
+	"each line on its own, keeping one line per numbered line even where a sentence runs across " +
+	"lines, and keep bracketed tokens such as [EMAIL] as they are. Reply with only the translated " +
+	"lines, each starting with its number.\n\n"
+
+// Translation is a finished transcript in another language, segment for segment, so subtitles can
+// be made in that language with the original timings
+type Translation struct {
+	Language   string                `json:"language"`
+	Transcript string                `json:"transcript"`
+	Segments   []transcriber.Segment `json:"segments"`
+}
+
+// deeplRequest is the body of a DeepL translate request
+type deeplRequest struct {
+	Text       []string `json:"text"`
+	TargetLang string   `json:"target_lang"`
+}
+
+// deeplResponse is the part of a DeepL translate response we use
+type deeplResponse struct {
+	Translations []struct {
+		Text string `json:"text"`
+	} `json:"translations"`
+}
+
+// checkTranslationBackend validates TRANSCRIBER_TRANSLATION_BACKEND at startup
+func checkTranslationBackend(cfg Config) error {
+	if cfg.TranslationBackend != translationLLM && cfg.TranslationBackend != translationDeepL {
+		return fmt.Errorf("unknown translation backend %q: expected llm or deepl", cfg.TranslationBackend)
+	}
+	return nil
+}
+
+// parseTranslateTo validates the translate_to option of a request, a language tag such as de or
+// pt-BR, or "" for no translation
+func parseTranslateTo(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(value)
+	if err != nil {
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid translate_to %q: expected a language tag such as de or pt-BR", value),
+		}
+	}
+	if appConfig.TranslationBackend == translationDeepL && appConfig.DeepLAPIKey == "" {
+		return "", &pipelineError{Status: http.StatusBadRequest, Message: "translate_to is not available: this server has no DeepL API key configured"}
+	}
+	return tag.String(), nil
+}
+
+// translateJob adds a translation of the finished transcript to the job, segment for segment.
+// Like a summary, a failed translation is recorded on the job rather than failing it
+func translateJob(ctx context.Context, job *Job, result *transcriber.Result, target string) {
+	if strings.TrimSpace(result.Transcription) == "" {
+		return
+	}
+
+	translateCtx, cancel := jobContext(ctx)
+	defer cancel()
+	texts, err := translateTexts(translateCtx, segmentTexts(result.Segments), target)
+	if err != nil {
+		loggerFrom(ctx).Warn("Error translating transcript", "language", target, "error", err)
+		job.TranslationError = "Failed to translate transcript: " + err.Error()
+		return
+	}
+	segments := make([]transcriber.Segment, len(result.Segments))
+	for i, segment := range result.Segments {
+		segment.Text = texts[i]
+		segment.Edited, segment.OriginalText = false, ""
+		segments[i] = segment
+	}
+	job.Translation = &Translation{Language: target, Transcript: transcriber.JoinSegments(segments), Segments: segments}
+}
+
+// translateTexts translates each text into the target language with the configured backend
+func translateTexts(ctx context.Context, texts []string, target string) ([]string, error) {
+	if appConfig.TranslationBackend == translationDeepL {
+		return translateWithDeepL(ctx, texts, target)
+	}
+	return translateWithChat(ctx, texts, target)
+}
+
+// translateWithChat asks the configured chat model to translate texts, failing when it leaves any
+// out, since a translation missing lines would put the wrong text under the timings that follow
+func translateWithChat(ctx context.Context, texts []string, target string) ([]string, error) {
+	name := display.English.Tags().Name(language.Make(target))
+	replies, err := chatLines(ctx, fmt.Sprintf(translationPrompt, name), texts)
+	if err != nil {
+		return nil, err
+	}
+	for i, reply := range replies {
+		if reply == "" && strings.TrimSpace(texts[i]) != "" {
+			return nil, fmt.Errorf("chat model left segment %d untranslated", i)
+		}
+	}
+	return replies, nil
+}
+
+// translateWithDeepL sends texts to DeepL in batches of deeplBatchTexts
+func translateWithDeepL(ctx context.Context, texts []string, target string) ([]string, error) {
+	translated := make([]string, 0, len(texts))
+	for start := 0; start < len(texts); start += deeplBatchTexts {
+		batch := texts[start:min(start+deeplBatchTexts, len(texts))]
+		body, err := json.Marshal(deeplRequest{Text: batch, TargetLang: strings.ToUpper(target)})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, appConfig.DeepLURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "DeepL-Auth-Key "+appConfig.DeepLAPIKey)
+
+		result, err := sendDeepLRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Translations) != len(batch) {
+			return nil, fmt.Errorf("DeepL returned %d translations for %d texts", len(result.Translations), len(batch))
+		}
+		for _, translation := range result.Translations {
+			translated = append(translated, translation.Text)
+		}
+	}
+	return translated, nil
+}
+
+// sendDeepLRequest sends a translate request to DeepL within the upstream request limit
+func sendDeepLRequest(ctx context.Context, req *http.Request) (*deeplResponse, error) {
+	release, err := requestLimiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := summaryClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("DeepL API returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	var result deeplResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// translatedJob returns a copy of a job with its translation in place of its transcript and
+// segments, for rendering subtitles and documents in the translated language. Word timings are
+// dropped, since they are of the original words
+func translatedJob(job *Job) *Job {
+	translated := *job
+	translated.Transcript = job.Translation.Transcript
+	translated.Segments = job.Translation.Segments
+	translated.Words = nil
+	return &translated
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// tusVersion is the only version of the tus protocol this server speaks
+const tusVersion = "1.0.0"
+
+// tusUpload is the state of a resumable upload, persisted next to its data
+type tusUpload struct {
+	ID       string            `json:"id"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Length   int64             `json:"length"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// tusLocks prevents two PATCH requests from appending to the same upload at once
+var tusLocks sync.Map
+
+// tusUploadsDir is where partial uploads live until they complete
+func tusUploadsDir() string {
+	return filepath.Join(appConfig.WorkDir, "uploads")
+}
+
+// tusMiddleware enforces the Tus-Resumable header and advertises the protocol version
+func tusMiddleware(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, ErrorResponse{Error: "Unsupported tus version: expected " + tusVersion})
+		return
+	}
+	c.Next()
+}
+
+func tusOptions(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination")
+	c.Header("Tus-Max-Size", strconv.FormatInt(appConfig.MaxUploadBytes, 10))
+	c.Status(http.StatusNoContent)
+}
+
+func tusCreate(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Upload-Length header must be a non-negative integer"})
+		return
+	}
+	if length > appConfig.MaxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("File too large: maximum upload size is %d bytes", appConfig.MaxUploadBytes)})
+		return
+	}
+	if err := checkDiskSpace(appConfig.WorkDir, length, appConfig.DiskExpansionFactor); err != nil {
+		c.JSON(http.StatusInsufficientStorage, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid Upload-Metadata header: " + err.Error()})
+		return
+	}
+
+	// The profile's options are filled in now, so the upload runs with them even if it changes
+	if metadata, err = profileFields(metadata); err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if err := checkUploadExtension(metadata["filename"]); err != nil {
+		respondWithError(c, err)
+		return
+	}
+	// The options are parsed again when the upload completes, so they're checked now to reject a bad
+	// upload before its data is sent
+	if _, err := formJobOptions(tenantFrom(c.Request.Context()), metadata); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	upload := &tusUpload{ID: uuid.New().String(), Tenant: tenantIDFrom(c.Request.Context()), Length: length, Metadata: metadata}
+	if err := os.MkdirAll(tusUploadsDir(), 0o700); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create upload"})
+		return
+	}
+	if err := saveTusUpload(upload); err != nil {
+		loggerFrom(c.Request.Context()).Error("Error creating upload", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create upload"})
+		return
+	}
+	dataFile, err := os.Create(tusDataPath(upload.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create upload"})
+		return
+	}
+	dataFile.Close()
+
+	c.Header("Location", "/api/uploads/"+upload.ID)
+
+	// A zero-length upload is already complete
+	if length == 0 {
+		release, err := admitJob(c.Request.Context())
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		completeTusUpload(c, upload, release)
+	}
+	c.Status(http.StatusCreated)
+}
+
+func tusHead(c *gin.Context) {
+	upload, offset, err := loadTusUpload(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Status(http.StatusOK)
+}
+
+func tusPatch(c *gin.Context) {
+	id := c.Param("id")
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, ErrorResponse{Error: "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	lock, _ := tusLocks.LoadOrStore(id, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Upload is already being written to"})
+		return
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	upload, offset, err := loadTusUpload(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Upload not found"})
+		return
+	}
+
+	requestOffset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || requestOffset != offset {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("Upload-Offset must be %d", offset)})
+		return
+	}
+
+	dataFile, err := os.OpenFile(tusDataPath(id), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to open upload"})
+		return
+	}
+
+	// Keep whatever arrived even if the connection drops; that's the point of resuming
+	written, copyErr := io.Copy(dataFile, io.LimitReader(c.Request.Body, upload.Length-offset))
+	dataFile.Close()
+	offset += written
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	if copyErr != nil {
+		loggerFrom(c.Request.Context()).Warn("Upload interrupted", "upload_id", id, "offset", offset, "error", copyErr)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if offset == upload.Length {
+		// With the queue full the upload is kept, and re-sending the final PATCH with an empty body retries
+		release, err := admitJob(c.Request.Context())
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		completeTusUpload(c, upload, release)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func tusDelete(c *gin.Context) {
+	id := c.Param("id")
+	if _, _, err := loadTusUpload(c.Request.Context(), id); err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	removeTusUpload(id)
+	c.Status(http.StatusNoContent)
+}
+
+// completeTusUpload hands a finished upload to the pipeline as a background job whose ID is the
+// upload ID, and points the client at where the transcript will appear. release frees the job's
+// place in the queue once it is done
+func completeTusUpload(c *gin.Context, upload *tusUpload, release func()) {
+	defer removeTusUpload(upload.ID)
+
+	filename := upload.Metadata["filename"]
+	if filename == "" {
+		filename = "upload"
+	}
+
+	job, jobDir, err := startJob(c.Request.Context(), upload.ID, filename)
+	if err != nil {
+		release()
+		return
+	}
+	tagJob(c, job.ID)
+	ctx := c.Request.Context()
+
+	inputPath := filepath.Join(jobDir, "upload-"+filepath.Base(filename))
+	if err := os.Rename(tusDataPath(upload.ID), inputPath); err != nil {
+		finishJob(ctx, job, nil, err)
+		removeJobDir(jobDir)
+		release()
+		return
+	}
+
+	// The options were validated when the upload was created, but the server may have been
+	// reconfigured since
+	opts, err := formJobOptions(tenantFrom(ctx), upload.Metadata)
+	if err != nil {
+		finishJob(ctx, job, nil, err)
+		removeJobDir(jobDir)
+		release()
+		return
+	}
+	// The job outlives the PATCH request, so it isn't canceled when the request ends, but its spans
+	// and logs still belong to the request
+	go func() {
+		defer release()
+		defer removeJobDir(jobDir)
+		executeJob(context.WithoutCancel(ctx), job, jobDir, inputPath, opts)
+	}()
+
+	c.Header("Transcription-Location", "/api/transcriptions/"+job.ID)
+}
+
+// parseTusMetadata decodes an Upload-Metadata header of comma-separated "key base64value" pairs
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("value for %q is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func tusInfoPath(id string) string {
+	return filepath.Join(tusUploadsDir(), id+".info")
+}
+
+func tusDataPath(id string) string {
+	return filepath.Join(tusUploadsDir(), id+".bin")
+}
+
+// saveTusUpload writes an upload's state next to its data file
+func saveTusUpload(upload *tusUpload) error {
+	encoded, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tusInfoPath(upload.ID), encoded, 0o600)
+}
+
+// loadTusUpload reads an upload's state and derives its current offset from the data on disk.
+// Uploads of other tenants than the request's are reported as not existing
+func loadTusUpload(ctx context.Context, id string) (*tusUpload, int64, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, 0, err
+	}
+
+	encoded, err := os.ReadFile(tusInfoPath(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	var upload tusUpload
+	if err := json.Unmarshal(encoded, &upload); err != nil {
+		return nil, 0, err
+	}
+	if !tenantOwns(ctx, upload.Tenant) {
+		return nil, 0, os.ErrNotExist
+	}
+
+	info, err := os.Stat(tusDataPath(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	return &upload, info.Size(), nil
+}
+
+// removeTusUpload deletes an upload's state and any data that hasn't been handed to a job
+func removeTusUpload(id string) {
+	for _, path := range []string{tusInfoPath(id), tusDataPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Error removing upload file", "path", path, "error", err)
+		}
+	}
+	tusLocks.Delete(id)
+}
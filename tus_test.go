@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// tusMetadata encodes key and value pairs as an Upload-Metadata header
+func tusMetadata(pairs ...string) string {
+	var encoded []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		encoded = append(encoded, pairs[i]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[i+1])))
+	}
+	return strings.Join(encoded, ",")
+}
+
+func TestTusUploadOptions(t *testing.T) {
+	useTestConfig(t)
+
+	tests := []struct {
+		name   string
+		header string
+		check  func(JobOptions) bool
+	}{
+		{"defaults", tusMetadata("filename", "call.wav"), func(opts JobOptions) bool {
+			return opts.UseCache && !opts.AudioEvents && opts.Provider == "groq"
+		}},
+		{"audio events", tusMetadata("filename", "call.wav", "audio_events", "true"), func(opts JobOptions) bool {
+			return opts.AudioEvents
+		}},
+		{"sentiment", tusMetadata("filename", "call.wav", "sentiment", "true"), func(opts JobOptions) bool {
+			return opts.Sentiment
+		}},
+		{"no cache", tusMetadata("filename", "call.wav", "cache", "false"), func(opts JobOptions) bool {
+			return !opts.UseCache
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata, err := parseTusMetadata(test.header)
+			if err != nil {
+				t.Fatalf("parseTusMetadata: %v", err)
+			}
+			opts, err := formJobOptions(nil, metadata)
+			if err != nil {
+				t.Fatalf("formJobOptions: %v", err)
+			}
+			if !test.check(opts) {
+				t.Errorf("unexpected options %+v", opts)
+			}
+		})
+	}
+}
+
+func TestTusUploadOptionsInvalid(t *testing.T) {
+	useTestConfig(t)
+
+	for _, header := range []string{
+		tusMetadata("priority", "urgent"),
+		tusMetadata("min_confidence", "2"),
+		tusMetadata("language", "not a language"),
+		tusMetadata("identify_speakers", "true"),
+	} {
+		metadata, err := parseTusMetadata(header)
+		if err != nil {
+			t.Fatalf("parseTusMetadata(%q): %v", header, err)
+		}
+		if _, err := formJobOptions(nil, metadata); err == nil {
+			t.Errorf("formJobOptions accepted %v", metadata)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// twilioSignatureHeader carries Twilio's signature of a callback, made with the account's auth token
+const twilioSignatureHeader = "X-Twilio-Signature"
+
+// TwilioRecordingResponse acknowledges a recording callback with the job transcribing it
+type TwilioRecordingResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// TwilioTranscription is posted to TRANSCRIBER_TWILIO_WEBHOOK_URL once a recording's job has
+// finished. The job's fields are those of a Kafka message
+type TwilioTranscription struct {
+	CallSID      string `json:"call_sid"`
+	RecordingSID string `json:"recording_sid"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	*KafkaJobMessage
+}
+
+// twilioRecording handles Twilio's recording status callbacks, and the action callbacks of
+// <Record>, by transcribing the recording in the background. The query string of the callback
+// URL takes the options of an upload, such as redact=true. A callback Twilio sends again gets the
+// job the first one started, since jobs are keyed by RecordingSid
+func twilioRecording(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Callback must be a form"})
+		return
+	}
+	form := c.Request.PostForm
+	if !validTwilioSignature(c.GetHeader(twilioSignatureHeader), twilioCallbackURL(c.Request), form) {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid " + twilioSignatureHeader})
+		return
+	}
+	if form.Get("AccountSid") != appConfig.TwilioAccountSID {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Callback is for another Twilio account"})
+		return
+	}
+
+	// Status callbacks also report recordings that are in progress or failed
+	if status := form.Get("RecordingStatus"); status != "" && status != "completed" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	callSID, recordingSID := form.Get("CallSid"), form.Get("RecordingSid")
+	recordingURL, err := twilioRecordingURL(form.Get("RecordingUrl"))
+	if err != nil || recordingSID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Callback must have a Twilio RecordingUrl and RecordingSid"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if appConfig.TwilioTenant != "" {
+		ctx = withTenant(ctx, tenantsByID[appConfig.TwilioTenant])
+	}
+	ctx = withLogger(ctx, loggerFrom(ctx).With("call_sid", callSID, "recording_sid", recordingSID))
+	fields := map[string]string{}
+	for name := range c.Request.URL.Query() {
+		fields[name] = c.Query(name)
+	}
+	opts, err := formJobOptions(tenantFrom(ctx), fields)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	prior, err := findIdempotentJob(ctx, recordingSID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to look up recording"})
+		return
+	}
+	if prior != nil {
+		c.JSON(http.StatusOK, TwilioRecordingResponse{JobID: prior.ID})
+		return
+	}
+
+	release, err := admitJob(ctx)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	job, jobDir, err := createJob(ctx, &Job{
+		ID:             uuid.New().String(),
+		Filename:       path.Base(recordingURL.Path),
+		CallSID:        callSID,
+		IdempotencyKey: recordingSID,
+	})
+	if err != nil {
+		release()
+		// A callback Twilio sent again may have started the job first
+		if prior, findErr := findIdempotentJob(ctx, recordingSID); findErr == nil && prior != nil {
+			c.JSON(http.StatusOK, TwilioRecordingResponse{JobID: prior.ID})
+			return
+		}
+		respondWithStartError(c, err)
+		return
+	}
+	tagJob(c, job.ID)
+
+	// The job outlives the callback, which Twilio gives 15 seconds to answer
+	jobCtx := withLogger(context.WithoutCancel(ctx), loggerFrom(ctx).With("job_id", job.ID))
+	go func() {
+		runTwilioJob(jobCtx, job, jobDir, recordingURL.String(), opts, release)
+		forwardTwilioJob(jobCtx, job.ID, recordingSID, opts)
+	}()
+	c.JSON(http.StatusAccepted, TwilioRecordingResponse{JobID: job.ID})
+}
+
+// runTwilioJob downloads a recording with the account's credentials and transcribes it, then
+// gives back its place in the queue
+func runTwilioJob(ctx context.Context, job *Job, jobDir, recordingURL string, opts JobOptions, release func()) {
+	defer release()
+	defer removeJobDir(jobDir)
+	ctx, untrack := trackJob(ctx, job.ID)
+	defer untrack()
+	downloadCtx, cancel := jobContext(ctx)
+	defer cancel()
+	inputPath, err := downloadURLWithAuth(downloadCtx, recordingURL, jobDir, basicAuthorization(appConfig.TwilioAccountSID, appConfig.TwilioAuthToken))
+	if err != nil {
+		finishJob(ctx, job, nil, jobError(ctx, err))
+		return
+	}
+	executeJob(ctx, job, jobDir, inputPath, opts)
+}
+
+// forwardTwilioJob posts a recording's finished job to TRANSCRIBER_TWILIO_WEBHOOK_URL, retrying
+// the post like the chat notifications
+func forwardTwilioJob(ctx context.Context, jobID, recordingSID string, opts JobOptions) {
+	if appConfig.TwilioWebhookURL == "" {
+		return
+	}
+	job, err := jobStore.GetJob(jobID)
+	if err != nil {
+		loggerFrom(ctx).Error("Error loading job to forward", "error", err)
+		return
+	}
+	message := kafkaJobMessage(filterJob(job, opts.ProfanityFilter))
+	deliverWebhook(ctx, job, "twilio", appConfig.TwilioWebhookURL, TwilioTranscription{
+		CallSID:         job.CallSID,
+		RecordingSID:    recordingSID,
+		Status:          job.Status,
+		Error:           job.Error,
+		KafkaJobMessage: &message,
+	})
+}
+
+// checkTwilioConfig checks the settings the recording callback needs when it is enabled
+func checkTwilioConfig(cfg Config) error {
+	if cfg.TwilioAuthToken == "" {
+		return nil
+	}
+	if cfg.TwilioAccountSID == "" {
+		return errors.New("TRANSCRIBER_TWILIO_ACCOUNT_SID must be set along with TRANSCRIBER_TWILIO_AUTH_TOKEN")
+	}
+	if cfg.TwilioTenant != "" && tenantsByID[cfg.TwilioTenant] == nil {
+		return fmt.Errorf("TRANSCRIBER_TWILIO_TENANT names unknown tenant %q", cfg.TwilioTenant)
+	}
+	if cfg.TwilioWebhookURL != "" {
+		if parsed, err := url.Parse(cfg.TwilioWebhookURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return errors.New("TRANSCRIBER_TWILIO_WEBHOOK_URL must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// twilioRecordingURL checks that a callback's RecordingUrl is on Twilio, so the account's
+// credentials aren't sent anywhere else, and asks for the recording as WAV when it names no format
+func twilioRecordingURL(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := parsed.Hostname()
+	if parsed.Scheme != "https" || (host != "twilio.com" && !strings.HasSuffix(host, ".twilio.com")) {
+		return nil, &pipelineError{Status: http.StatusBadRequest, Message: "RecordingUrl is not a Twilio URL"}
+	}
+	if path.Ext(parsed.Path) == "" {
+		parsed.Path += ".wav"
+	}
+	return parsed, nil
+}
+
+// twilioCallbackURL returns the URL Twilio signed a callback for: the request's URL at
+// TRANSCRIBER_PUBLIC_URL when set, since a proxy in front of the server changes the scheme and host
+func twilioCallbackURL(r *http.Request) string {
+	if appConfig.PublicURL != "" {
+		return strings.TrimSuffix(appConfig.PublicURL, "/") + r.URL.RequestURI()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// validTwilioSignature checks a callback's signature: the base64 HMAC-SHA1, keyed with the auth
+// token, of its URL followed by each form field's name and value in name order
+func validTwilioSignature(signature, callbackURL string, form url.Values) bool {
+	var signed strings.Builder
+	signed.WriteString(callbackURL)
+	for _, name := range slices.Sorted(maps.Keys(form)) {
+		for _, value := range form[name] {
+			signed.WriteString(name)
+			signed.WriteString(value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(appConfig.TwilioAuthToken))
+	mac.Write([]byte(signed.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
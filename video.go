@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// errSubtitlesEncrypted rejects subtitles while temp files are encrypted, since the subtitled copy
+// outlives the job's key
+var errSubtitlesEncrypted = &pipelineError{Status: http.StatusBadRequest, Message: "subtitles isn't available while TRANSCRIBER_ENCRYPT_TEMP_FILES is on"}
+
+// parseSubtitles validates the subtitles option of a request: burn, soft, or "" for none
+func parseSubtitles(value string) (transcriber.SubtitleMode, error) {
+	switch mode := transcriber.SubtitleMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return mode, nil
+	case transcriber.SubtitlesBurn, transcriber.SubtitlesSoft:
+		if appConfig.EncryptTempFiles {
+			return "", errSubtitlesEncrypted
+		}
+		return mode, nil
+	default:
+		return "", &pipelineError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Unknown subtitles %q: expected burn or soft", value),
+		}
+	}
+}
+
+// subtitledVideoPath is where the subtitled copy of a job's video is kept
+func subtitledVideoPath(jobID string) string {
+	return filepath.Join(appConfig.VideoDir, jobID+".mp4")
+}
+
+// subtitledVideoURL is where a job's subtitled video is downloaded from, or "" when it has none
+func subtitledVideoURL(job *Job) string {
+	if !job.SubtitledVideo {
+		return ""
+	}
+	return "/api/transcriptions/" + job.ID + "/video"
+}
+
+// subtitleJobVideo makes a copy of a job's video with its transcript, and any tagged sounds, as subtitles, filtered the
+// way the job asks, once anything redaction masks is masked. Like a summary, a copy that couldn't
+// be made is recorded on the job rather than failing it
+func subtitleJobVideo(ctx context.Context, job *Job, opts JobOptions, inputPath string, result *transcriber.Result) {
+	subtitleCtx, cancel := jobContext(ctx)
+	defer cancel()
+	if appConfig.PreprocessTimeout > 0 {
+		subtitleCtx, cancel = context.WithTimeout(subtitleCtx, appConfig.PreprocessTimeout)
+		defer cancel()
+	}
+
+	segments := transcriber.CaptionSegments(filterResult(result, opts.ProfanityFilter).Segments, job.Events)
+	err := transcriber.SubtitleVideo(subtitleCtx, inputPath, subtitledVideoPath(job.ID), segments, opts.Subtitles)
+	if errors.Is(err, transcriber.ErrNoVideo) {
+		job.SubtitlesError = "No subtitled video: the upload has no video stream"
+		return
+	}
+	if err != nil {
+		loggerFrom(ctx).Warn("Error adding subtitles to video", "error", err)
+		job.SubtitlesError = "Failed to add subtitles to the video: " + err.Error()
+		return
+	}
+	job.SubtitledVideo = true
+}
+
+// removeSubtitledVideo deletes the subtitled copy of a job's video, if any
+func removeSubtitledVideo(jobID string) {
+	if err := os.Remove(subtitledVideoPath(jobID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("Error removing subtitled video", "job_id", jobID, "error", err)
+	}
+}
+
+// sweepSubtitledVideos deletes the subtitled videos of jobs that no longer exist
+func sweepSubtitledVideos() {
+	entries, err := os.ReadDir(appConfig.VideoDir)
+	if err != nil {
+		slog.Error("Unable to list subtitled videos", "dir", appConfig.VideoDir, "error", err)
+		return
+	}
+	for _, entry := range entries {
+		jobID := strings.TrimSuffix(entry.Name(), ".mp4")
+		if _, err := jobStore.GetJob(jobID); errors.Is(err, errJobNotFound) {
+			removeSubtitledVideo(jobID)
+		}
+	}
+}
+
+// getSubtitledVideo downloads the copy of a job's video with its subtitles, made when the job was
+// submitted with subtitles
+func getSubtitledVideo(c *gin.Context) {
+	job, err := getTenantJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errJobNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading job", "job_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load transcription"})
+		return
+	}
+	path := subtitledVideoPath(job.ID)
+	if _, err := os.Stat(path); !job.SubtitledVideo || err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Transcription has no subtitled video: submit a video with subtitles=burn or subtitles=soft"})
+		return
+	}
+	c.FileAttachment(path, documentFilename(job, "mp4"))
+}
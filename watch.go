@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"audio-transcriber/pkg/transcriber"
+)
+
+// watchRoots are the directories opened from TRANSCRIBER_WATCH_DIRS. Like file:// inputs, watched
+// files are read through os.Root, so a symlink can't lead a scan outside them
+var watchRoots []localRoot
+
+// watchOptions are the options parsed from TRANSCRIBER_WATCH_OPTIONS
+var watchOptions JobOptions
+
+// initWatchDirs opens the watched directories and parses the options their files are
+// transcribed with. It must run after initTenants
+func initWatchDirs(cfg Config) error {
+	if len(cfg.WatchDirs) == 0 {
+		return nil
+	}
+	if cfg.WatchInterval <= 0 {
+		return errors.New("TRANSCRIBER_WATCH_INTERVAL must be positive")
+	}
+	if cfg.WatchTenant != "" && tenantsByID[cfg.WatchTenant] == nil {
+		return fmt.Errorf("TRANSCRIBER_WATCH_TENANT names unknown tenant %q", cfg.WatchTenant)
+	}
+	for _, format := range cfg.WatchOutputs {
+		if _, ok := resultFiles[format]; !ok && format != "json" && format != "none" {
+			return fmt.Errorf("TRANSCRIBER_WATCH_OUTPUTS has unknown format %q", format)
+		}
+	}
+	query, err := url.ParseQuery(cfg.WatchOptions)
+	if err != nil {
+		return fmt.Errorf("TRANSCRIBER_WATCH_OPTIONS must be a query string: %w", err)
+	}
+	fields := map[string]string{}
+	for name := range query {
+		fields[name] = query.Get(name)
+	}
+	if watchOptions, err = formJobOptions(tenantsByID[cfg.WatchTenant], fields); err != nil {
+		return fmt.Errorf("invalid TRANSCRIBER_WATCH_OPTIONS: %w", err)
+	}
+
+	for _, dir := range cfg.WatchDirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		root, err := os.OpenRoot(abs)
+		if err != nil {
+			return err
+		}
+		watchRoots = append(watchRoots, localRoot{path: abs, root: root})
+	}
+	return nil
+}
+
+// runWatcher scans the watched directories every TRANSCRIBER_WATCH_INTERVAL until ctx is
+// canceled, starting a job for each new or changed recording
+func runWatcher(ctx context.Context) {
+	slog.Info("Watching directories for recordings", "dirs", appConfig.WatchDirs, "interval", appConfig.WatchInterval)
+	ticker := time.NewTicker(appConfig.WatchInterval)
+	defer ticker.Stop()
+	for {
+		for _, root := range watchRoots {
+			scanWatchDir(ctx, root)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanWatchDir starts jobs for the recordings under root that the ledger has no job for at their
+// current size and modification time. Hidden files and directories are skipped, and so are the
+// results written next to recordings, by extension. A full queue ends the scan early; the rest
+// are picked up by later scans
+func scanWatchDir(ctx context.Context, root localRoot) {
+	watched, err := jobStore.WatchedFiles(root.path)
+	if err != nil {
+		slog.Error("Unable to load watched files", "dir", root.path, "error", err)
+		return
+	}
+	settled := time.Now().Add(-appConfig.WatchInterval)
+	err = fs.WalkDir(root.root.FS(), ".", func(rel string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Warn("Unable to scan watched directory", "dir", root.path, "path", rel, "error", err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if rel != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !watchedExtension(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(settled) {
+			return nil
+		}
+		file := WatchedFile{
+			Path:    filepath.Join(root.path, filepath.FromSlash(rel)),
+			Root:    root.path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if prior, ok := watched[file.Path]; ok && prior.Size == file.Size && prior.ModTime.Equal(file.ModTime) {
+			return nil
+		}
+		return startWatchJob(ctx, root, filepath.FromSlash(rel), file)
+	})
+	if err != nil && !errors.Is(err, fs.SkipAll) && ctx.Err() == nil {
+		slog.Error("Unable to scan watched directory", "dir", root.path, "error", err)
+	}
+}
+
+// watchedExtension reports whether a file in a watched directory is transcribed
+func watchedExtension(name string) bool {
+	return slices.Contains(appConfig.WatchExtensions, strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")))
+}
+
+// startWatchJob claims a file in the ledger and starts a job for it in the background. It
+// returns fs.SkipAll when the queue is full
+func startWatchJob(ctx context.Context, root localRoot, rel string, file WatchedFile) error {
+	if appConfig.WatchTenant != "" {
+		ctx = withTenant(ctx, tenantsByID[appConfig.WatchTenant])
+	}
+	ctx = withLogger(ctx, loggerFrom(ctx).With("watched_file", file.Path))
+	release, err := admitJob(ctx)
+	if err != nil {
+		loggerFrom(ctx).Debug("Queue is full, leaving watched files for the next scan")
+		return fs.SkipAll
+	}
+
+	file.JobID = uuid.New().String()
+	file.CreatedAt = time.Now()
+	claimed, err := jobStore.ClaimWatchedFile(file)
+	if err != nil || !claimed {
+		release()
+		if err != nil {
+			loggerFrom(ctx).Error("Unable to record watched file", "error", err)
+		}
+		return nil
+	}
+	job, jobDir, err := createJob(ctx, &Job{ID: file.JobID, Filename: rel})
+	if err != nil {
+		release()
+		loggerFrom(ctx).Error("Unable to start job for watched file", "error", err)
+		if err := jobStore.ReleaseWatchedFile(file.Path, file.JobID); err != nil {
+			loggerFrom(ctx).Error("Unable to release watched file", "error", err)
+		}
+		return nil
+	}
+
+	// The job outlives the scan, which stops at shutdown
+	jobCtx := withLogger(context.WithoutCancel(ctx), loggerFrom(ctx).With("job_id", job.ID))
+	go runWatchJob(jobCtx, job, jobDir, root, rel, file, release)
+	return nil
+}
+
+// runWatchJob copies a watched file into jobDir and transcribes it, writes the results next to
+// it, then gives back its place in the queue. A job cut short by shutdown gives up its claim
+// so the file is transcribed again after a restart, unless the job itself is resumed
+func runWatchJob(ctx context.Context, job *Job, jobDir string, root localRoot, rel string, file WatchedFile, release func()) {
+	defer release()
+	defer removeJobDir(jobDir)
+	ctx, untrack := trackJob(ctx, job.ID)
+	defer untrack()
+	copyCtx, cancel := jobContext(ctx)
+	defer cancel()
+
+	inputPath, err := copyFromRoot(copyCtx, root, rel, jobDir)
+	if err != nil {
+		finishJob(ctx, job, nil, jobError(ctx, err))
+		return
+	}
+	if _, err := executeJob(ctx, job, jobDir, inputPath, watchOptions); err != nil {
+		if errors.Is(err, errJobInterrupted) && !appConfig.ResumeJobs {
+			if err := jobStore.ReleaseWatchedFile(file.Path, file.JobID); err != nil {
+				loggerFrom(ctx).Error("Unable to release watched file", "error", err)
+			}
+		}
+		return
+	}
+
+	finished, err := jobStore.GetJob(job.ID)
+	if err != nil {
+		loggerFrom(ctx).Error("Unable to load finished job", "error", err)
+		return
+	}
+	if finished.Status == JobStatusCompleted {
+		writeWatchOutputs(ctx, root, rel, finished)
+	}
+}
+
+// writeWatchOutputs writes each of TRANSCRIBER_WATCH_OUTPUTS next to a watched file, named after
+// it: call.wav gets call.txt, call.srt, and call.json, while formats that aren't a transcript
+// keep their own name, like call.words.json. Each is written to a hidden file first and renamed
+// into place, so nothing reading the directory sees half of one
+func writeWatchOutputs(ctx context.Context, root localRoot, rel string, job *Job) {
+	rendered := flagJob(filterJob(job, watchOptions.ProfanityFilter), watchOptions.MinConfidence)
+	rendered.Segments = transcriber.MarkLowConfidence(rendered.Segments)
+	dir, base := filepath.Split(rel)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	for _, format := range appConfig.WatchOutputs {
+		if format == "none" || (wordFormat(format) && len(rendered.Words) == 0) {
+			continue
+		}
+		var body []byte
+		var err error
+		name := stem + ".json"
+		if format == "json" {
+			body, err = json.MarshalIndent(rendered, "", "  ")
+		} else {
+			body, _, err = renderResult(format, rendered)
+			name = stem + "." + strings.TrimPrefix(resultFiles[format], "transcript.")
+		}
+		if err != nil {
+			loggerFrom(ctx).Warn("Error rendering result", "format", format, "error", err)
+			continue
+		}
+		if err := writeRootFile(root, filepath.Join(dir, name), body); err != nil {
+			loggerFrom(ctx).Warn("Unable to write result next to watched file", "format", format, "error", err)
+		}
+	}
+}
+
+// writeRootFile replaces the file at rel under root with body
+func writeRootFile(root localRoot, rel string, body []byte) error {
+	dir, base := filepath.Split(rel)
+	tmp := filepath.Join(dir, "."+base+".tmp")
+	if err := root.root.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	if err := root.root.Rename(tmp, rel); err != nil {
+		root.root.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webUIPage is a page for transcribing a file from the browser: it uploads with stream=true to show
+// progress, then shows the transcript as text, SRT, or VTT to copy or download
+//
+//go:embed web/index.html
+var webUIPage []byte
+
+// serveWebUI serves the upload page
+func serveWebUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", webUIPage)
+}
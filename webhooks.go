@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook delivery statuses. Pending deliveries are retried with backoff, and failed ones are the
+// dead letters left once retrying stops. Delivered is only reported, since deliveries that go
+// through aren't kept
+const (
+	WebhookPending   = "pending"
+	WebhookFailed    = "failed"
+	WebhookDelivered = "delivered"
+)
+
+const (
+	// webhookFirstBackoff is the wait before a failed post is first retried; it doubles with every
+	// attempt up to webhookMaxBackoff
+	webhookFirstBackoff = 10 * time.Second
+	webhookMaxBackoff   = 10 * time.Minute
+
+	// webhookPollInterval is how often due retries are looked for, and webhookPollBatch how many
+	// are taken at a time
+	webhookPollInterval = 5 * time.Second
+	webhookPollBatch    = 50
+
+	// webhookClaimLease keeps other instances off a delivery while it is being retried
+	webhookClaimLease = time.Minute
+)
+
+// WebhookDelivery is a webhook post about a job that didn't go through the first time
+type WebhookDelivery struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	JobID     string    `json:"job_id"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Service   string    `json:"service"`
+	URL       string    `json:"url"`
+	Payload   string    `json:"payload"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+
+	// NextAttemptAt is when a pending delivery is tried again
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// WebhookDeliveryListResponse is a page of webhook deliveries
+type WebhookDeliveryListResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Total      int                `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"page_size"`
+}
+
+// webhookStatusError is a webhook answering a post with an error status
+type webhookStatusError struct {
+	status int
+	body   string
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d: %s", e.status, e.body)
+}
+
+// permanentWebhookError reports whether a post would fail however often it is retried: the webhook
+// rejected it with a 4xx status other than a timeout or rate limit, as it does for a deleted
+// webhook or a malformed message
+func permanentWebhookError(err error) bool {
+	var statusErr *webhookStatusError
+	if !errors.As(err, &statusErr) || statusErr.status/100 != 4 {
+		return false
+	}
+	switch statusErr.status {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+// deliverWebhook posts payload about a job to a webhook. A post that fails is stored and retried
+// with backoff for TRANSCRIBER_WEBHOOK_RETRY_PERIOD, or kept as a dead letter straight away when
+// retrying can't help
+func deliverWebhook(ctx context.Context, job *Job, service, webhookURL string, payload any) {
+	logger := loggerFrom(ctx)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Error encoding job notification", "service", service, "error", err)
+		return
+	}
+	err = postWebhook(ctx, webhookURL, body)
+	if err == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	delivery := &WebhookDelivery{
+		CreatedAt: now,
+		JobID:     job.ID,
+		TenantID:  job.TenantID,
+		Service:   service,
+		URL:       webhookURL,
+		Payload:   string(body),
+		Attempts:  1,
+	}
+	failWebhookAttempt(delivery, err, now)
+	logger.Warn("Error posting job notification", "service", service, "status", delivery.Status, "error", err)
+	if err := jobStore.AddWebhookDelivery(delivery); err != nil {
+		logger.Error("Error recording webhook delivery", "service", service, "error", err)
+	}
+}
+
+// failWebhookAttempt records a failed attempt at a delivery, leaving it pending until its next
+// attempt, or failing it when the error is permanent or the retry period would be over by then
+func failWebhookAttempt(delivery *WebhookDelivery, err error, now time.Time) {
+	delivery.LastError = err.Error()
+	backoff := webhookMaxBackoff
+	if shift := delivery.Attempts - 1; shift < 16 {
+		backoff = min(webhookFirstBackoff<<shift, webhookMaxBackoff)
+	}
+	next := now.Add(backoff)
+	if permanentWebhookError(err) || next.Sub(delivery.CreatedAt) > appConfig.WebhookRetryPeriod {
+		delivery.Status = WebhookFailed
+		delivery.NextAttemptAt = now
+		return
+	}
+	delivery.Status = WebhookPending
+	delivery.NextAttemptAt = next
+}
+
+// runWebhookRetries retries due webhook deliveries every webhookPollInterval until ctx is canceled
+func runWebhookRetries(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		retryDueWebhooks(ctx)
+	}
+}
+
+// retryDueWebhooks attempts the pending deliveries that are due, claiming each first so only one
+// instance sharing the job store retries it
+func retryDueWebhooks(ctx context.Context) {
+	now := time.Now().UTC()
+	deliveries, err := jobStore.DueWebhookDeliveries(now, webhookPollBatch)
+	if err != nil {
+		slog.Error("Error loading webhook deliveries to retry", "error", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		claimed, err := jobStore.ClaimWebhookDelivery(delivery, now.Add(webhookClaimLease))
+		if err != nil {
+			slog.Error("Error claiming webhook delivery", "delivery_id", delivery.ID, "error", err)
+			continue
+		}
+		if claimed {
+			attemptWebhookDelivery(ctx, delivery)
+		}
+	}
+}
+
+// attemptWebhookDelivery posts a stored delivery again, removing it once it goes through and
+// recording the failure otherwise
+func attemptWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	logger := slog.With("delivery_id", delivery.ID, "job_id", delivery.JobID, "service", delivery.Service)
+	err := postWebhook(ctx, delivery.URL, []byte(delivery.Payload))
+	if err == nil {
+		logger.Info("Delivered job notification", "attempts", delivery.Attempts)
+		if err := jobStore.DeleteWebhookDelivery(delivery.ID); err != nil {
+			logger.Error("Error removing delivered webhook", "error", err)
+		}
+		delivery.Status = WebhookDelivered
+		return nil
+	}
+
+	failWebhookAttempt(delivery, err, time.Now().UTC())
+	if delivery.Status == WebhookFailed {
+		logger.Warn("Giving up on job notification", "attempts", delivery.Attempts, "error", err)
+	}
+	if err := jobStore.UpdateWebhookDelivery(delivery); err != nil {
+		logger.Error("Error recording webhook delivery", "error", err)
+	}
+	return err
+}
+
+// adminWebhookDeliveries lists webhook deliveries that haven't gone through, newest first: the
+// dead letters by default, or those still being retried with status=pending
+func adminWebhookDeliveries(c *gin.Context) {
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "page must be a positive integer"})
+		return
+	}
+	pageSize, err := parsePositiveInt(c.Query("page_size"), defaultPageSize)
+	if err != nil || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("page_size must be between 1 and %d", maxPageSize)})
+		return
+	}
+	status := c.DefaultQuery("status", WebhookFailed)
+	if status != WebhookFailed && status != WebhookPending {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unknown status %q: expected failed or pending", status)})
+		return
+	}
+
+	deliveries, total, err := jobStore.ListWebhookDeliveries(WebhookDeliveryFilter{
+		Status: status,
+		JobID:  c.Query("job_id"),
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error listing webhook deliveries", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list webhook deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, WebhookDeliveryListResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+	})
+}
+
+// adminRetryWebhook posts a stored delivery again right away, such as a dead letter once the
+// webhook behind it is fixed. A delivery that fails again stays as it was, with the new error
+func adminRetryWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Webhook delivery not found"})
+		return
+	}
+	delivery, err := jobStore.GetWebhookDelivery(id)
+	if errors.Is(err, errWebhookDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Webhook delivery not found"})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Error loading webhook delivery", "delivery_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to load webhook delivery"})
+		return
+	}
+
+	delivery.Attempts++
+	if err := attemptWebhookDelivery(c.Request.Context(), delivery); err != nil {
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "Webhook delivery failed again: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
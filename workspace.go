@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// activeJobDirs holds the job directories this instance created and hasn't removed yet. The work
+// directory may be shared with other instances, so these are the only ones it may clean up
+var activeJobDirs sync.Map
+
+// checkDiskSpace verifies the work directory can hold size bytes multiplied by the expansion factor
+func checkDiskSpace(workDir string, size int64, expansionFactor float64) error {
+	available, err := availableDiskSpace(workDir)
+	if err != nil {
+		// Don't reject jobs just because we couldn't stat the filesystem
+		slog.Warn("Unable to determine free space", "dir", workDir, "error", err)
+		return nil
+	}
+
+	required := uint64(float64(size) * expansionFactor)
+	if available < required {
+		return fmt.Errorf("insufficient storage: need %d bytes, %d available", required, available)
+	}
+
+	return nil
+}
+
+// createJobDir creates the scratch directory for a single job. The job counts as in flight
+// until removeJobDir is called
+func createJobDir(workDir, jobID string) (string, error) {
+	jobDir := filepath.Join(workDir, "job-"+jobID)
+	if err := os.MkdirAll(jobDir, 0o700); err != nil {
+		return "", err
+	}
+	adoptJobDir(jobDir)
+	return jobDir, nil
+}
+
+// adoptJobDir counts an existing job directory, such as that of a resumed job, as in flight
+// until removeJobDir is called
+func adoptJobDir(jobDir string) {
+	inFlightJobs.Add(1)
+	jobsInFlight.Inc()
+	activeJobDirs.Store(jobDir, struct{}{})
+}
+
+// removeJobDir deletes a job directory and everything that was written to it, unless the job
+// was interrupted by shutdown and resumes from it after a restart
+func removeJobDir(jobDir string) {
+	defer inFlightJobs.Done()
+	defer jobsInFlight.Dec()
+	defer activeJobDirs.Delete(jobDir)
+	defer jobEncryptions.Delete(jobDir)
+	if _, kept := keptJobDirs.Load(jobDir); kept {
+		return
+	}
+	if err := os.RemoveAll(jobDir); err != nil {
+		slog.Error("Error removing job directory", "dir", jobDir, "error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SourceMetadata describes where a transcribed file came from when it was fetched by yt-dlp
+type SourceMetadata struct {
+	Title   string `json:"title,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// downloadWithYtDlp fetches the best audio stream for a YouTube/SoundCloud/podcast page into
+// jobDir using yt-dlp, returning the downloaded path along with the page's title and channel.
+// yt-dlp makes its own connections, so unless TRANSCRIBER_ALLOW_PRIVATE_URLS is set the page's
+// host is checked up front. The redirects it follows and the media URLs it picks aren't
+func downloadWithYtDlp(ctx context.Context, rawURL, jobDir string) (string, *SourceMetadata, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", nil, &pipelineError{Status: http.StatusBadRequest, Message: "URL must be an absolute http or https URL"}
+	}
+	if !appConfig.AllowPrivateURLs {
+		if err := checkPublicHost(ctx, parsed.Hostname()); err != nil {
+			return "", nil, &pipelineError{Status: http.StatusBadRequest, Message: "Invalid URL: " + err.Error()}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appConfig.DownloadTimeout)
+	defer cancel()
+
+	outputTemplate := filepath.Join(jobDir, "ytdlp-media.%(ext)s")
+	cmd := exec.CommandContext(
+		ctx,
+		appConfig.YtDlpPath,
+		"--format", "bestaudio/best",
+		"--no-playlist",
+		"--no-progress",
+		"--max-filesize", strconv.FormatInt(appConfig.MaxUploadBytes, 10),
+		"--dump-json",
+		"--no-simulate",
+		"--output", outputTemplate,
+		"--", parsed.String(),
+	)
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", nil, &pipelineError{Status: http.StatusGatewayTimeout, Message: "Timed out downloading with yt-dlp"}
+		}
+		var execErr *exec.Error
+		if errors.As(err, &execErr) {
+			return "", nil, fmt.Errorf("yt-dlp is not available: %w", err)
+		}
+		return "", nil, &pipelineError{Status: http.StatusBadGateway, Message: "yt-dlp failed: " + strings.TrimSpace(stderr.String())}
+	}
+
+	var info struct {
+		Title    string `json:"title"`
+		Channel  string `json:"channel"`
+		Uploader string `json:"uploader"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		return "", nil, fmt.Errorf("unable to parse yt-dlp output: %w", err)
+	}
+
+	// yt-dlp skips (rather than fails) files over --max-filesize, so nothing may have been written
+	matches, err := filepath.Glob(filepath.Join(jobDir, "ytdlp-media.*"))
+	if err != nil || len(matches) == 0 {
+		return "", nil, &pipelineError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("yt-dlp did not download any media (the file may exceed %d bytes)", appConfig.MaxUploadBytes)}
+	}
+
+	channel := info.Channel
+	if channel == "" {
+		channel = info.Uploader
+	}
+
+	return matches[0], &SourceMetadata{Title: info.Title, Channel: channel, URL: parsed.String()}, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestYtDlpRejectsPrivateHosts(t *testing.T) {
+	useTestConfig(t)
+	// Nothing should get as far as running yt-dlp
+	appConfig.YtDlpPath = filepath.Join(t.TempDir(), "yt-dlp")
+
+	for _, rawURL := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://127.0.0.1:8080/watch?v=1",
+		"https://10.0.0.5/episode",
+		"http://[::1]/video",
+		"http://localhost/video",
+	} {
+		_, _, err := downloadWithYtDlp(context.Background(), rawURL, t.TempDir())
+		var pipelineErr *pipelineError
+		if !errors.As(err, &pipelineErr) || pipelineErr.Status != http.StatusBadRequest {
+			t.Errorf("downloadWithYtDlp(%q) = %v, want a 400", rawURL, err)
+		}
+	}
+}
+
+func TestYtDlpAllowsPrivateHostsWhenConfigured(t *testing.T) {
+	useTestConfig(t)
+	appConfig.YtDlpPath = filepath.Join(t.TempDir(), "yt-dlp")
+	appConfig.AllowPrivateURLs = true
+
+	// The missing executable fails the download once the host is let through
+	_, _, err := downloadWithYtDlp(context.Background(), "http://169.254.169.254/latest/meta-data/", t.TempDir())
+	var pipelineErr *pipelineError
+	if err == nil || (errors.As(err, &pipelineErr) && pipelineErr.Status == http.StatusBadRequest) {
+		t.Errorf("downloadWithYtDlp = %v, want the host to be let through", err)
+	}
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"audio-transcriber/pkg/transcriber"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// zoomSignatureHeader carries Zoom's signature of a webhook, made with the app's secret token
+	zoomSignatureHeader = "x-zm-signature"
+
+	// zoomTimestampHeader is when Zoom sent a webhook, in Unix seconds, which the signature covers
+	zoomTimestampHeader = "x-zm-request-timestamp"
+
+	// zoomMaxClockSkew is how far a webhook's timestamp may be from now, so a captured webhook
+	// can't be replayed later
+	zoomMaxClockSkew = 5 * time.Minute
+
+	// zoomMaxWebhookBytes bounds the body of a webhook
+	zoomMaxWebhookBytes = 1 << 20
+
+	// zoomParticipantsPageSize is the most participants Zoom lists per page
+	zoomParticipantsPageSize = 300
+)
+
+// zoomOAuthURL and zoomAPIURL are where Zoom's server-to-server OAuth tokens and REST API are
+var (
+	zoomOAuthURL = "https://zoom.us/oauth/token"
+	zoomAPIURL   = "https://api.zoom.us/v2"
+)
+
+// zoomClient calls Zoom's OAuth and REST APIs
+var zoomClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// zoomToken caches the account's OAuth access token until shortly before it expires
+var zoomToken struct {
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// Meeting is the meeting a job's recording is of
+type Meeting struct {
+	Platform     string    `json:"platform"`
+	ID           string    `json:"id"`
+	UUID         string    `json:"uuid"`
+	Topic        string    `json:"topic,omitempty"`
+	StartTime    time.Time `json:"start_time"`
+	Participants []string  `json:"participants,omitempty"`
+}
+
+// ZoomRecordingResponse acknowledges a recording webhook with the job transcribing it
+type ZoomRecordingResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// ZoomURLValidation answers the challenge Zoom sends to check the webhook endpoint is ours
+type ZoomURLValidation struct {
+	PlainToken     string `json:"plainToken"`
+	EncryptedToken string `json:"encryptedToken"`
+}
+
+// ZoomTranscription is posted to TRANSCRIBER_ZOOM_WEBHOOK_URL once a recording's job has
+// finished. The job's fields are those of a Kafka message
+type ZoomTranscription struct {
+	MeetingUUID string   `json:"meeting_uuid"`
+	Meeting     *Meeting `json:"meeting,omitempty"`
+	Status      string   `json:"status"`
+	Error       string   `json:"error,omitempty"`
+	*KafkaJobMessage
+}
+
+// zoomEvent is the body of a Zoom webhook. download_token, which authorizes downloading the
+// recording for 24 hours, is only sent when the app's event subscription asks for it
+type zoomEvent struct {
+	Event   string `json:"event"`
+	Payload struct {
+		PlainToken string        `json:"plainToken"`
+		AccountID  string        `json:"account_id"`
+		Object     zoomRecording `json:"object"`
+	} `json:"payload"`
+	DownloadToken string `json:"download_token"`
+}
+
+// zoomRecording is a meeting's cloud recording
+type zoomRecording struct {
+	UUID      string      `json:"uuid"`
+	ID        json.Number `json:"id"`
+	Topic     string      `json:"topic"`
+	StartTime string      `json:"start_time"`
+
+	// RecordingFiles are the recording's views and its mixed audio; ParticipantAudioFiles, when
+	// the account records a separate audio file for each participant, are theirs
+	RecordingFiles        []zoomRecordingFile `json:"recording_files"`
+	ParticipantAudioFiles []zoomRecordingFile `json:"participant_audio_files"`
+}
+
+// zoomRecordingFile is one file of a cloud recording
+type zoomRecordingFile struct {
+	FileType       string `json:"file_type"`
+	FileName       string `json:"file_name"`
+	RecordingStart string `json:"recording_start"`
+	DownloadURL    string `json:"download_url"`
+	Status         string `json:"status"`
+}
+
+// zoomRecordingCompleted handles Zoom's webhooks: it answers the endpoint validation challenge,
+// and transcribes each cloud recording Zoom reports as completed in the background. The query
+// string of the endpoint URL takes the options of an upload, such as summarize=true. A webhook
+// Zoom sends again gets the job the first one started, since jobs are keyed by meeting UUID
+func zoomRecordingCompleted(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, zoomMaxWebhookBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Failed to read webhook"})
+		return
+	}
+	if !validZoomSignature(c.GetHeader(zoomSignatureHeader), c.GetHeader(zoomTimestampHeader), body) {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid " + zoomSignatureHeader})
+		return
+	}
+	var event zoomEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Webhook must be JSON"})
+		return
+	}
+
+	switch event.Event {
+	case "endpoint.url_validation":
+		c.JSON(http.StatusOK, ZoomURLValidation{
+			PlainToken:     event.Payload.PlainToken,
+			EncryptedToken: zoomHMAC([]byte(event.Payload.PlainToken)),
+		})
+		return
+	case "recording.completed":
+	default:
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if appConfig.ZoomAccountID != "" && event.Payload.AccountID != appConfig.ZoomAccountID {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Webhook is for another Zoom account"})
+		return
+	}
+
+	recording := event.Payload.Object
+	audio := zoomAudioFile(recording.RecordingFiles)
+	if audio == nil {
+		// A recording of only the chat or a transcript has nothing to transcribe
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if recording.UUID == "" || !validZoomDownloadURLs(append([]zoomRecordingFile{*audio}, recording.ParticipantAudioFiles...)) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Webhook must have a meeting uuid and Zoom download URLs"})
+		return
+	}
+	if event.DownloadToken == "" && appConfig.ZoomClientID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Webhook has no download_token: include it in the event subscription or set TRANSCRIBER_ZOOM_CLIENT_ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if appConfig.ZoomTenant != "" {
+		ctx = withTenant(ctx, tenantsByID[appConfig.ZoomTenant])
+	}
+	ctx = withLogger(ctx, loggerFrom(ctx).With("meeting_uuid", recording.UUID))
+	fields := map[string]string{}
+	for name := range c.Request.URL.Query() {
+		fields[name] = c.Query(name)
+	}
+	opts, err := formJobOptions(tenantFrom(ctx), fields)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	key := "zoom:" + recording.UUID
+	prior, err := findIdempotentJob(ctx, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to look up recording"})
+		return
+	}
+	if prior != nil {
+		c.JSON(http.StatusOK, ZoomRecordingResponse{JobID: prior.ID})
+		return
+	}
+
+	release, err := admitJob(ctx)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	meeting := zoomMeeting(recording)
+	filename := meeting.Topic
+	if filename == "" {
+		filename = "Zoom meeting " + meeting.ID
+	}
+	job, jobDir, err := createJob(ctx, &Job{
+		ID:             uuid.New().String(),
+		Filename:       filename,
+		Meeting:        meeting,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		release()
+		// A webhook Zoom sent again may have started the job first
+		if prior, findErr := findIdempotentJob(ctx, key); findErr == nil && prior != nil {
+			c.JSON(http.StatusOK, ZoomRecordingResponse{JobID: prior.ID})
+			return
+		}
+		respondWithStartError(c, err)
+		return
+	}
+	tagJob(c, job.ID)
+
+	// The job outlives the webhook, which Zoom gives 3 seconds to answer
+	jobCtx := withLogger(context.WithoutCancel(ctx), loggerFrom(ctx).With("job_id", job.ID))
+	go func() {
+		runZoomJob(jobCtx, job, jobDir, recording, audio, event.DownloadToken, opts, release)
+		forwardZoomJob(jobCtx, job.ID, recording.UUID, opts)
+	}()
+	c.JSON(http.StatusOK, ZoomRecordingResponse{JobID: job.ID})
+}
+
+// runZoomJob looks up the meeting's participants, downloads its audio, and transcribes it, then
+// gives back its place in the queue. With TRANSCRIBER_ZOOM_SPLIT_SPEAKERS, each participant's
+// own audio file is downloaded instead and transcribed as a channel labeled with their name
+func runZoomJob(ctx context.Context, job *Job, jobDir string, recording zoomRecording, audio *zoomRecordingFile, downloadToken string, opts JobOptions, release func()) {
+	defer release()
+	defer removeJobDir(jobDir)
+	ctx, untrack := trackJob(ctx, job.ID)
+	defer untrack()
+	downloadCtx, cancel := jobContext(ctx)
+	defer cancel()
+
+	participants, err := zoomParticipants(downloadCtx, recording.UUID)
+	if err != nil {
+		loggerFrom(ctx).Warn("Unable to list meeting participants", "error", err)
+	} else if len(participants) > 0 {
+		job.Meeting.Participants = participants
+		if err := jobStore.SaveJobMeeting(job.ID, job.Meeting); err != nil {
+			loggerFrom(ctx).Error("Error saving meeting participants", "error", err)
+		}
+	}
+
+	authorization, err := zoomAuthorization(downloadCtx, downloadToken)
+	if err != nil {
+		finishJob(ctx, job, nil, jobError(ctx, err))
+		return
+	}
+	var inputPath string
+	tracks := zoomParticipantTracks(recording.ParticipantAudioFiles)
+	if appConfig.ZoomSplitSpeakers && len(tracks) >= 2 && len(tracks) <= maxChannelLabels {
+		inputPath, err = mergeZoomTracks(downloadCtx, jobDir, tracks, authorization)
+		opts.SplitChannels = true
+		opts.ChannelLabels = make([]string, len(tracks))
+		for i, track := range tracks {
+			opts.ChannelLabels[i] = zoomTrackLabel(track, i)
+		}
+	} else {
+		inputPath, err = downloadURLWithAuth(downloadCtx, audio.DownloadURL, jobDir, authorization)
+	}
+	if err != nil {
+		finishJob(ctx, job, nil, jobError(ctx, err))
+		return
+	}
+	executeJob(ctx, job, jobDir, inputPath, opts)
+}
+
+// mergeZoomTracks downloads each participant's audio file and merges them into one WAV in
+// jobDir, a channel for each participant, lined up by when their recording started
+func mergeZoomTracks(ctx context.Context, jobDir string, tracks []zoomRecordingFile, authorization string) (string, error) {
+	var first time.Time
+	starts := make([]time.Time, len(tracks))
+	for i, track := range tracks {
+		starts[i], _ = time.Parse(time.RFC3339, track.RecordingStart)
+		if first.IsZero() || (!starts[i].IsZero() && starts[i].Before(first)) {
+			first = starts[i]
+		}
+	}
+
+	inputPaths := make([]string, len(tracks))
+	offsets := make([]time.Duration, len(tracks))
+	for i, track := range tracks {
+		inputPath, err := downloadURLWithAuth(ctx, track.DownloadURL, jobDir, authorization)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(inputPath)
+		inputPaths[i] = inputPath
+		if !starts[i].IsZero() {
+			offsets[i] = starts[i].Sub(first)
+		}
+	}
+
+	mergedPath := filepath.Join(jobDir, "participants.wav")
+	merged, err := createJobFile(mergedPath)
+	if err != nil {
+		return "", err
+	}
+	var cipher *transcriber.FileCipher
+	if encryption := jobEncryptionFor(mergedPath); encryption != nil {
+		cipher = encryption.cipher
+	}
+	if err := transcriber.MergeTracks(ctx, inputPaths, offsets, merged, cipher); err != nil {
+		merged.Close()
+		return "", &pipelineError{Status: http.StatusUnprocessableEntity, Message: "Failed to merge participant audio: " + err.Error()}
+	}
+	if err := merged.Close(); err != nil {
+		return "", err
+	}
+	return mergedPath, nil
+}
+
+// forwardZoomJob posts a recording's finished job to TRANSCRIBER_ZOOM_WEBHOOK_URL, retrying the
+// post like the chat notifications
+func forwardZoomJob(ctx context.Context, jobID, meetingUUID string, opts JobOptions) {
+	if appConfig.ZoomWebhookURL == "" {
+		return
+	}
+	job, err := jobStore.GetJob(jobID)
+	if err != nil {
+		loggerFrom(ctx).Error("Error loading job to forward", "error", err)
+		return
+	}
+	message := kafkaJobMessage(filterJob(job, opts.ProfanityFilter))
+	deliverWebhook(ctx, job, "zoom", appConfig.ZoomWebhookURL, ZoomTranscription{
+		MeetingUUID:     meetingUUID,
+		Meeting:         job.Meeting,
+		Status:          job.Status,
+		Error:           job.Error,
+		KafkaJobMessage: &message,
+	})
+}
+
+// checkZoomConfig checks the settings the recording webhook needs when it is enabled
+func checkZoomConfig(cfg Config) error {
+	if cfg.ZoomSecretToken == "" {
+		return nil
+	}
+	if cfg.ZoomClientID != "" && (cfg.ZoomClientSecret == "" || cfg.ZoomAccountID == "") {
+		return errors.New("TRANSCRIBER_ZOOM_CLIENT_SECRET and TRANSCRIBER_ZOOM_ACCOUNT_ID must be set along with TRANSCRIBER_ZOOM_CLIENT_ID")
+	}
+	if cfg.ZoomTenant != "" && tenantsByID[cfg.ZoomTenant] == nil {
+		return fmt.Errorf("TRANSCRIBER_ZOOM_TENANT names unknown tenant %q", cfg.ZoomTenant)
+	}
+	if cfg.ZoomWebhookURL != "" {
+		if parsed, err := url.Parse(cfg.ZoomWebhookURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return errors.New("TRANSCRIBER_ZOOM_WEBHOOK_URL must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// zoomMeeting tags a job with the meeting a recording is of. Participants are filled in from the
+// names of their audio files until Zoom's API lists them
+func zoomMeeting(recording zoomRecording) *Meeting {
+	meeting := &Meeting{
+		Platform: "zoom",
+		ID:       recording.ID.String(),
+		UUID:     recording.UUID,
+		Topic:    strings.TrimSpace(recording.Topic),
+	}
+	meeting.StartTime, _ = time.Parse(time.RFC3339, recording.StartTime)
+	for i, track := range zoomParticipantTracks(recording.ParticipantAudioFiles) {
+		if label := zoomTrackLabel(track, i); !slices.Contains(meeting.Participants, label) {
+			meeting.Participants = append(meeting.Participants, label)
+		}
+	}
+	return meeting
+}
+
+// zoomAudioFile picks the file of a recording to transcribe: its audio-only M4A, or the video
+// when the account doesn't record audio separately
+func zoomAudioFile(files []zoomRecordingFile) *zoomRecordingFile {
+	for _, fileType := range []string{"M4A", "MP4"} {
+		for i, file := range files {
+			if file.FileType == fileType && (file.Status == "" || file.Status == "completed") {
+				return &files[i]
+			}
+		}
+	}
+	return nil
+}
+
+// zoomParticipantTracks returns the participant audio files that finished recording
+func zoomParticipantTracks(files []zoomRecordingFile) []zoomRecordingFile {
+	var tracks []zoomRecordingFile
+	for _, file := range files {
+		if file.Status == "" || file.Status == "completed" {
+			tracks = append(tracks, file)
+		}
+	}
+	return tracks
+}
+
+// zoomTrackLabel names a participant's audio file for its channel: the file name Zoom gives it,
+// which is the participant's name, without its extension
+func zoomTrackLabel(track zoomRecordingFile, i int) string {
+	label := strings.TrimSpace(strings.TrimSuffix(track.FileName, path.Ext(track.FileName)))
+	if label == "" {
+		return "Participant " + strconv.Itoa(i+1)
+	}
+	return label
+}
+
+// validZoomDownloadURLs checks that the files are downloaded from Zoom over https, so the
+// download token or the account's access token isn't sent anywhere else
+func validZoomDownloadURLs(files []zoomRecordingFile) bool {
+	for _, file := range files {
+		parsed, err := url.Parse(file.DownloadURL)
+		if err != nil {
+			return false
+		}
+		host := parsed.Hostname()
+		if parsed.Scheme != "https" || (host != "zoom.us" && !strings.HasSuffix(host, ".zoom.us")) {
+			return false
+		}
+	}
+	return true
+}
+
+// zoomAuthorization returns the Authorization header recordings are downloaded with: the
+// webhook's download token when it has one, otherwise the account's OAuth access token
+func zoomAuthorization(ctx context.Context, downloadToken string) (string, error) {
+	if downloadToken != "" {
+		return "Bearer " + downloadToken, nil
+	}
+	token, err := zoomAccessToken(ctx)
+	if err != nil {
+		return "", &pipelineError{Status: http.StatusBadGateway, Message: "Failed to authorize with Zoom: " + err.Error()}
+	}
+	return "Bearer " + token, nil
+}
+
+// zoomAccessToken returns an access token from the account's server-to-server OAuth app,
+// requesting a new one a minute before the last one expires
+func zoomAccessToken(ctx context.Context) (string, error) {
+	zoomToken.mu.Lock()
+	defer zoomToken.mu.Unlock()
+	if zoomToken.value != "" && time.Now().Before(zoomToken.expires) {
+		return zoomToken.value, nil
+	}
+
+	query := url.Values{"grant_type": {"account_credentials"}, "account_id": {appConfig.ZoomAccountID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, zoomOAuthURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", basicAuthorization(appConfig.ZoomClientID, appConfig.ZoomClientSecret))
+	resp, err := zoomClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Zoom OAuth returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	zoomToken.value = token.AccessToken
+	zoomToken.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return zoomToken.value, nil
+}
+
+// zoomParticipants lists the names of a past meeting's participants, each once however often
+// they joined. It returns nil when no OAuth app is configured to ask Zoom's API with
+func zoomParticipants(ctx context.Context, meetingUUID string) ([]string, error) {
+	if appConfig.ZoomClientID == "" {
+		return nil, nil
+	}
+	token, err := zoomAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A UUID that starts with a slash or has two in a row must be encoded twice
+	meetingPath := url.PathEscape(meetingUUID)
+	if strings.HasPrefix(meetingUUID, "/") || strings.Contains(meetingUUID, "//") {
+		meetingPath = url.PathEscape(meetingPath)
+	}
+
+	var names []string
+	nextPage := ""
+	for {
+		query := url.Values{"page_size": {strconv.Itoa(zoomParticipantsPageSize)}}
+		if nextPage != "" {
+			query.Set("next_page_token", nextPage)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, zoomAPIURL+"/past_meetings/"+meetingPath+"/participants?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := zoomClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Participants []struct {
+				Name string `json:"name"`
+			} `json:"participants"`
+			NextPageToken string `json:"next_page_token"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("Zoom API returned status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, participant := range page.Participants {
+			if name := strings.TrimSpace(participant.Name); name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		if page.NextPageToken == "" {
+			return names, nil
+		}
+		nextPage = page.NextPageToken
+	}
+}
+
+// validZoomSignature checks a webhook's signature: "v0=" and the hex HMAC-SHA256, keyed with the
+// secret token, of "v0:", its timestamp, ":", and its body
+func validZoomSignature(signature, timestamp string, body []byte) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(seconds, 0)).Abs() > zoomMaxClockSkew {
+		return false
+	}
+	expected := "v0=" + zoomHMAC([]byte("v0:"+timestamp+":"+string(body)))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// zoomHMAC returns the hex HMAC-SHA256 of message keyed with the secret token
+func zoomHMAC(message []byte) string {
+	mac := hmac.New(sha256.New, []byte(appConfig.ZoomSecretToken))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}